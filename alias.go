@@ -0,0 +1,81 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"math"
+	"unsafe"
+)
+
+// Seal and Open follow the aliasing rules of the standard library's AEADs:
+// dst may be plaintext[:0] (or ciphertext[:0]) to encrypt (or decrypt) in
+// place, but otherwise the spare capacity of dst which receives the output
+// must not overlap the input at all, and it must never overlap any of the
+// associated data, which is read after output has begun to be written. Other
+// combinations panic rather than silently corrupting data. The inputs may
+// overlap each other freely.
+
+// anyOverlap reports whether x and y share any memory.
+func anyOverlap(x, y []byte) bool {
+	return len(x) > 0 && len(y) > 0 &&
+		uintptr(unsafe.Pointer(&x[0])) <= uintptr(unsafe.Pointer(&y[len(y)-1])) &&
+		uintptr(unsafe.Pointer(&y[0])) <= uintptr(unsafe.Pointer(&x[len(x)-1]))
+}
+
+// inexactOverlap reports whether x and y share memory at any index other than
+// at the start of both.
+func inexactOverlap(x, y []byte) bool {
+	if len(x) == 0 || len(y) == 0 || &x[0] == &y[0] {
+		return false
+	}
+	return anyOverlap(x, y)
+}
+
+// trialOpen opens ciphertext with aead as one of several attempts with
+// different keys. A failed Open may overwrite its output, so if the spare
+// capacity of dst overlaps ciphertext, as for an in-place open, the attempt
+// opens into scratch space, leaving the ciphertext intact for the next, and
+// the plaintext is appended to dst only if it succeeds.
+func trialOpen(aead cipher.AEAD, dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	if !anyOverlap(dst[len(dst):cap(dst)], ciphertext) {
+		return aead.Open(dst, nonce, ciphertext, data)
+	}
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, data)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(plaintext)
+	return append(dst, plaintext...), nil
+}
+
+// sliceForAppend extends in by n bytes, returning the extended slice and the
+// n new bytes, or ErrOutputTooLarge if the length would overflow an int.
+func sliceForAppend(in []byte, n int) (head, tail []byte, err error) {
+	if n > math.MaxInt-len(in) {
+		return nil, nil, ErrOutputTooLarge
+	}
+
+	if total := len(in) + n; cap(in) >= total {
+		head = in[:total]
+	} else {
+		head = make([]byte, total)
+		copy(head, in)
+	}
+	tail = head[len(in):]
+	return
+}
+
+// checkAliasing returns ErrOverlap if the output buffer out overlaps the input
+// in any way other than starting at the same address, or overlaps any
+// associated data.
+func checkAliasing(out, in []byte, data [][]byte) error {
+	if inexactOverlap(out, in) {
+		return ErrOverlap
+	}
+	for _, v := range data {
+		if anyOverlap(out, v) {
+			return ErrOverlap
+		}
+	}
+	return nil
+}
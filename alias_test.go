@@ -0,0 +1,132 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"testing"
+)
+
+// aliasOffsets are the offsets into a shared backing array at which the
+// buffers in the aliasing tests start.
+var aliasOffsets = []int{0, 8, 16, 24, 32, 48, 64, 96}
+
+func panics(f func()) (panicked bool) {
+	defer func() {
+		panicked = recover() != nil
+	}()
+	f()
+	return false
+}
+
+func TestSealAliasing(t *testing.T) {
+	c, _ := New(testKey(0, 32), aes.NewCipher)
+	const ptLen, adLen, prefix = 32, 16, 8
+
+	for _, p := range aliasOffsets {
+		for _, a := range aliasOffsets {
+			for _, d := range aliasOffsets {
+				buf := make([]byte, 160)
+				for i := range buf {
+					buf[i] = byte(i)
+				}
+
+				plaintext := buf[p : p+ptLen]
+				ad := buf[a : a+adLen]
+				dst := buf[d : d+prefix]
+				out := buf[d+prefix : d+prefix+ptLen+c.Overhead()]
+
+				expected := c.Seal(append([]byte{}, dst...), nil, append([]byte{}, plaintext...), append([]byte{}, ad...))
+				adCopy := append([]byte{}, ad...)
+				supported := !anyOverlap(out, ad) && (!anyOverlap(out, plaintext) || d+prefix == p)
+
+				var actual []byte
+				if panicked := panics(func() { actual = c.Seal(dst, nil, plaintext, ad) }); panicked == supported {
+					t.Errorf("pt@%d ad@%d dst@%d: panicked was %v, but expected %v", p, a, d, panicked, !supported)
+					continue
+				}
+
+				if !supported {
+					continue
+				}
+
+				if !bytes.Equal(actual, expected) {
+					t.Errorf("pt@%d ad@%d dst@%d: Ciphertext was %x, but expected %x", p, a, d, actual, expected)
+				}
+
+				if &actual[0] != &buf[d] {
+					t.Errorf("pt@%d ad@%d dst@%d: Ciphertext was not written to dst", p, a, d)
+				}
+
+				if !bytes.Equal(ad, adCopy) {
+					t.Errorf("pt@%d ad@%d dst@%d: AD was modified", p, a, d)
+				}
+			}
+		}
+	}
+}
+
+func TestOpenAliasing(t *testing.T) {
+	c, _ := New(testKey(0, 32), aes.NewCipher)
+	const ptLen, adLen, prefix = 32, 16, 8
+	ctLen := ptLen + c.Overhead()
+
+	for _, ct := range aliasOffsets {
+		for _, a := range aliasOffsets {
+			for _, d := range aliasOffsets {
+				buf := make([]byte, 192)
+				ad := buf[a : a+adLen]
+				for i := range ad {
+					ad[i] = byte(i)
+				}
+				plaintext := bytes.Repeat([]byte{0xaa}, ptLen)
+				ciphertext := c.Seal(nil, nil, plaintext, ad)
+				if anyOverlap(buf[ct:ct+ctLen], ad) {
+					// The ciphertext and AD can't both be laid out here.
+					continue
+				}
+				copy(buf[ct:], ciphertext)
+
+				dst := buf[d : d+prefix]
+				out := buf[d+prefix : d+prefix+ptLen]
+				expected := append(append([]byte{}, dst...), plaintext...)
+				supported := !anyOverlap(out, ad) && (!anyOverlap(out, buf[ct:ct+ctLen]) || d+prefix == ct)
+
+				var actual []byte
+				var err error
+				panicked := panics(func() { actual, err = c.Open(dst, nil, buf[ct:ct+ctLen], ad) })
+				if panicked == supported {
+					t.Errorf("ct@%d ad@%d dst@%d: panicked was %v, but expected %v", ct, a, d, panicked, !supported)
+					continue
+				}
+
+				if !supported {
+					continue
+				}
+
+				if err != nil {
+					t.Errorf("ct@%d ad@%d dst@%d: %v", ct, a, d, err)
+					continue
+				}
+
+				if !bytes.Equal(actual, expected) {
+					t.Errorf("ct@%d ad@%d dst@%d: Plaintext was %x, but expected %x", ct, a, d, actual, expected)
+				}
+			}
+		}
+	}
+}
+
+func TestOpenInPlaceFailure(t *testing.T) {
+	c, _ := New(testKey(0, 32), aes.NewCipher)
+
+	ciphertext := c.Seal(nil, nil, []byte("in place"), nil)
+	ciphertext[0] ^= 1
+
+	if p, err := c.Open(ciphertext[:0], nil, ciphertext, nil); err == nil {
+		t.Fatalf("Plaintext returned instead of error: %x", p)
+	}
+
+	if p := ciphertext[:8]; !bytes.Equal(p, make([]byte, 8)) {
+		t.Errorf("Unauthenticated plaintext was left in dst: %x", p)
+	}
+}
@@ -0,0 +1,51 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"errors"
+)
+
+// AppendAEAD is implemented by the AEADs returned by New. Its methods report
+// misuse as errors rather than panicking, for long-running servers which would
+// rather fail a request than crash; Seal and Open call them and panic on the
+// errors that the standard library's AEADs panic on.
+type AppendAEAD interface {
+	cipher.AEAD
+
+	// SealAppend is like Seal, but returns ErrNonceSize, ErrMissingAD,
+	// ErrPlaintextTooLarge, ErrOutputTooLarge, or ErrOverlap rather than
+	// panicking.
+	SealAppend(dst, nonce, plaintext, data []byte) ([]byte, error)
+
+	// OpenAppend is like Open, but returns ErrNonceSize, ErrOutputTooLarge, or
+	// ErrOverlap rather than panicking.
+	OpenAppend(dst, nonce, ciphertext, data []byte) ([]byte, error)
+}
+
+var (
+	// ErrNonceSize is returned when a nonce is the wrong length for an AEAD
+	// created with WithNonceSize.
+	ErrNonceSize = errors.New("siv: incorrect nonce length given to SIV")
+
+	// ErrPlaintextTooLarge is returned when a plaintext is longer than
+	// MaxPlaintextSize.
+	ErrPlaintextTooLarge = errors.New("siv: plaintext too large")
+
+	// ErrOutputTooLarge is returned when appending the output to dst would
+	// make it longer than an int can hold.
+	ErrOutputTooLarge = errors.New("siv: output too large")
+
+	// ErrOverlap is returned when the output overlaps the input or the
+	// associated data in a way the aliasing rules forbid.
+	ErrOverlap = errors.New("siv: invalid buffer overlap")
+)
+
+// panicOnMisuse passes through ret and err, unless err is one which the
+// cipher.AEAD methods report by panicking.
+func panicOnMisuse(ret []byte, err error) ([]byte, error) {
+	switch err {
+	case ErrNonceSize, ErrPlaintextTooLarge, ErrOutputTooLarge, ErrOverlap:
+		panic(err)
+	}
+	return ret, err
+}
@@ -0,0 +1,123 @@
+package siv
+
+import (
+	"crypto/aes"
+	"testing"
+)
+
+func TestAppendErrors(t *testing.T) {
+	plain, _ := New(testKey(0, 32), aes.NewCipher)
+	configured, _ := New(testKey(0, 32), aes.NewCipher, WithNonceSize(12), WithRequiredAD())
+	nonce := make([]byte, 12)
+
+	buf := make([]byte, 64)
+	ciphertext := plain.Seal(nil, nil, []byte("yay"), []byte("ad"))
+	copy(buf[1:], ciphertext)
+
+	for _, v := range []struct {
+		name     string
+		f        func() ([]byte, error)
+		expected error
+	}{
+		{"seal overlapping input", func() ([]byte, error) {
+			return plain.(AppendAEAD).SealAppend(buf[:1], nil, buf[:8], nil)
+		}, ErrOverlap},
+		{"seal overlapping data", func() ([]byte, error) {
+			return plain.(AppendAEAD).SealAppend(buf[:0], nil, []byte("yay"), buf[:8])
+		}, ErrOverlap},
+		{"open overlapping input", func() ([]byte, error) {
+			return plain.(AppendAEAD).OpenAppend(buf[:0], nil, buf[1:1+len(ciphertext)], []byte("ad"))
+		}, ErrOverlap},
+		{"open overlapping data", func() ([]byte, error) {
+			return plain.(AppendAEAD).OpenAppend(buf[:40], nil, ciphertext, buf[40:])
+		}, ErrOverlap},
+		{"open inauthentic", func() ([]byte, error) {
+			return plain.(AppendAEAD).OpenAppend(nil, nil, ciphertext, nil)
+		}, ErrAuthentication},
+		{"open short", func() ([]byte, error) {
+			return plain.(AppendAEAD).OpenAppend(nil, nil, ciphertext[:15], nil)
+		}, ErrAuthentication},
+		{"seal nonce size", func() ([]byte, error) {
+			return configured.(AppendAEAD).SealAppend(nil, nil, []byte("yay"), []byte("ad"))
+		}, ErrNonceSize},
+		{"open nonce size", func() ([]byte, error) {
+			return configured.(AppendAEAD).OpenAppend(nil, make([]byte, 16), ciphertext, []byte("ad"))
+		}, ErrNonceSize},
+		{"seal missing data", func() ([]byte, error) {
+			return configured.(AppendAEAD).SealAppend(nil, nonce, []byte("yay"), nil)
+		}, ErrMissingAD},
+		{"open missing data", func() ([]byte, error) {
+			return configured.(AppendAEAD).OpenAppend(nil, nonce, ciphertext, nil)
+		}, ErrMissingAD},
+		{"seal configured overlapping input", func() ([]byte, error) {
+			return configured.(AppendAEAD).SealAppend(buf[:1], nonce, buf[:8], []byte("ad"))
+		}, ErrOverlap},
+	} {
+		var (
+			actual []byte
+			err    error
+		)
+		if panics(func() { actual, err = v.f() }) {
+			t.Errorf("%s: panicked", v.name)
+			continue
+		}
+
+		if err != v.expected {
+			t.Errorf("%s: error was %v, but expected %v (output %x)", v.name, err, v.expected, actual)
+		}
+	}
+
+	// ErrPlaintextTooLarge and ErrOutputTooLarge need inputs too large to
+	// allocate; see TestSizeLimits.
+}
+
+func TestAppendMisusePanics(t *testing.T) {
+	plain, _ := New(testKey(0, 32), aes.NewCipher)
+	configured, _ := New(testKey(0, 32), aes.NewCipher, WithNonceSize(12), WithRequiredAD())
+	buf := make([]byte, 64)
+
+	for _, v := range []struct {
+		name     string
+		f        func()
+		expected error
+	}{
+		{"seal overlapping input", func() { plain.Seal(buf[:1], nil, buf[:8], nil) }, ErrOverlap},
+		{"open overlapping input", func() { _, _ = plain.Open(buf[:0], nil, buf[1:20], nil) }, ErrOverlap},
+		{"seal nonce size", func() { configured.Seal(nil, nil, nil, []byte("ad")) }, ErrNonceSize},
+		{"open nonce size", func() { _, _ = configured.Open(nil, nil, buf[:20], []byte("ad")) }, ErrNonceSize},
+		{"seal missing data", func() { configured.Seal(nil, make([]byte, 12), nil, nil) }, ErrMissingAD},
+	} {
+		r := func() (r any) {
+			defer func() { r = recover() }()
+			v.f()
+			return nil
+		}()
+
+		if r != v.expected {
+			t.Errorf("%s: panic was %v, but expected %v", v.name, r, v.expected)
+		}
+	}
+}
+
+func TestAppendMatchesSeal(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher, WithTagAppended(), WithPadding(4))
+	a := aead.(AppendAEAD)
+
+	ciphertext, err := a.SealAppend([]byte("dst"), nil, []byte("yay"), []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := aead.Seal([]byte("dst"), nil, []byte("yay"), []byte("ad")); string(ciphertext) != string(expected) {
+		t.Errorf("Ciphertext was %x, but expected %x", ciphertext, expected)
+	}
+
+	actual, err := a.OpenAppend(nil, nil, ciphertext[3:], []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(actual) != "yay" {
+		t.Errorf("Plaintext was %q, but expected %q", actual, "yay")
+	}
+}
@@ -0,0 +1,122 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"sort"
+	"strings"
+)
+
+// ArmorType is the PEM block type of armored ciphertexts.
+const ArmorType = "SIV MESSAGE"
+
+var (
+	// ErrArmorMissing is returned when no armored block can be found.
+	ErrArmorMissing = errors.New("siv: no armored message found")
+
+	// ErrArmorCorrupt is returned when an armored block is malformed.
+	ErrArmorCorrupt = errors.New("siv: corrupt armored message")
+
+	// ErrArmorType is returned when an armored block is not a SIV MESSAGE.
+	ErrArmorType = errors.New("siv: armored block is not a " + ArmorType)
+
+	// ErrArmorHeader is returned when a header can't be armored.
+	ErrArmorHeader = errors.New("siv: invalid armor header")
+)
+
+// Armor encodes ciphertext as a PEM block of type SIV MESSAGE with the given
+// headers, wrapping the base64 body at 64 characters. The headers are not
+// authenticated; see ArmorSeal. It returns nil if a header key contains a
+// colon, or a header key or value contains a line break or has leading or
+// trailing whitespace.
+func Armor(ciphertext []byte, headers map[string]string) []byte {
+	if !validArmorHeaders(headers) {
+		return nil
+	}
+
+	return pem.EncodeToMemory(&pem.Block{
+		Type:    ArmorType,
+		Headers: headers,
+		Bytes:   ciphertext,
+	})
+}
+
+// Unarmor decodes the first PEM block in data, ignoring any text surrounding
+// it, and returns its contents and headers.
+func Unarmor(data []byte) ([]byte, map[string]string, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		if bytes.Contains(data, []byte("-----BEGIN ")) {
+			return nil, nil, ErrArmorCorrupt
+		}
+		return nil, nil, ErrArmorMissing
+	}
+
+	if block.Type != ArmorType {
+		return nil, nil, ErrArmorType
+	}
+
+	return block.Bytes, block.Headers, nil
+}
+
+// ArmorSeal seals plaintext and armors the result, binding the headers into
+// the associated data along with ad so that they can't be altered.
+func ArmorSeal(aead cipher.AEAD, plaintext, ad []byte, headers map[string]string) ([]byte, error) {
+	if !validArmorHeaders(headers) {
+		return nil, ErrArmorHeader
+	}
+
+	ciphertext := armorAEAD(aead, headers).Seal(nil, nil, plaintext, ad)
+	return Armor(ciphertext, headers), nil
+}
+
+// ArmorOpen unarmors and opens a message produced by ArmorSeal, returning the
+// plaintext and the authenticated headers.
+func ArmorOpen(aead cipher.AEAD, armored, ad []byte) ([]byte, map[string]string, error) {
+	ciphertext, headers, err := Unarmor(armored)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plaintext, err := armorAEAD(aead, headers).Open(nil, nil, ciphertext, ad)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plaintext, headers, nil
+}
+
+// armorAEAD binds the canonical encoding of headers to aead. Headers are sorted
+// by key, and each key and value is prefixed with its 32-bit big-endian length.
+func armorAEAD(aead cipher.AEAD, headers map[string]string) cipher.AEAD {
+	keys := make([]string, 0, len(headers))
+	for k := range headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b []byte
+	for _, k := range keys {
+		for _, v := range []string{k, headers[k]} {
+			b = binary.BigEndian.AppendUint32(b, uint32(len(v)))
+			b = append(b, v...)
+		}
+	}
+
+	return NewContext(aead, []byte("siv armor"), b)
+}
+
+func validArmorHeaders(headers map[string]string) bool {
+	for k, v := range headers {
+		if strings.Contains(k, ":") || strings.ContainsAny(k, "\r\n") || strings.ContainsAny(v, "\r\n") {
+			return false
+		}
+		// PEM decoding trims headers, so they'd no longer match those sealed.
+		if strings.TrimSpace(k) != k || strings.TrimSpace(v) != v {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,134 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"os"
+	"strings"
+	"testing"
+)
+
+func armorAEADForTest(t *testing.T) ([]byte, map[string]string, []byte) {
+	key, _ := hex.DecodeString("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	aead, err := New(key, aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headers := map[string]string{
+		"Key-Id":     "2016-ops",
+		"Created-At": "2016-06-01T00:00:00Z",
+	}
+	plaintext := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog. ", 3))
+
+	armored, err := ArmorSeal(aead, plaintext, []byte("ticket"), headers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return armored, headers, plaintext
+}
+
+func TestArmorGolden(t *testing.T) {
+	armored, _, _ := armorAEADForTest(t)
+
+	expected, err := os.ReadFile("testdata/armor.golden")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(armored, expected) {
+		t.Errorf("Armored message was\n%s\nbut expected\n%s", armored, expected)
+	}
+
+	for _, line := range strings.Split(string(armored), "\n") {
+		if len(line) > 64 {
+			t.Errorf("Line %q is longer than 64 characters", line)
+		}
+	}
+}
+
+func TestArmorRoundTrip(t *testing.T) {
+	key, _ := hex.DecodeString("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	aead, _ := New(key, aes.NewCipher)
+	armored, headers, plaintext := armorAEADForTest(t)
+
+	// Surrounding text, such as an email or ticket body, is ignored.
+	pasted := append([]byte("Hi, here's the blob:\n\n"), armored...)
+	pasted = append(pasted, "\nThanks!\n"...)
+
+	actual, actualHeaders, err := ArmorOpen(aead, pasted, []byte("ticket"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(actual, plaintext) {
+		t.Errorf("Plaintext was %x, but expected %x", actual, plaintext)
+	}
+
+	for k, v := range headers {
+		if actualHeaders[k] != v {
+			t.Errorf("Header %s was %q, but expected %q", k, actualHeaders[k], v)
+		}
+	}
+}
+
+func TestArmorHeaderTampering(t *testing.T) {
+	key, _ := hex.DecodeString("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	aead, _ := New(key, aes.NewCipher)
+	armored, _, _ := armorAEADForTest(t)
+
+	tampered := bytes.Replace(armored, []byte("2016-ops"), []byte("2017-ops"), 1)
+	if _, _, err := ArmorOpen(aead, tampered, []byte("ticket")); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+
+	stripped := bytes.Replace(armored, []byte("Key-Id: 2016-ops\n"), nil, 1)
+	if _, _, err := ArmorOpen(aead, stripped, []byte("ticket")); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+}
+
+func TestUnarmorErrors(t *testing.T) {
+	armored, _, _ := armorAEADForTest(t)
+
+	if _, _, err := Unarmor([]byte("nothing to see here")); err != ErrArmorMissing {
+		t.Errorf("Error was %v, but expected %v", err, ErrArmorMissing)
+	}
+
+	corrupt := bytes.Replace(armored, []byte("\n\n"), []byte("\n\n!!!!"), 1)
+	if _, _, err := Unarmor(corrupt); err != ErrArmorCorrupt {
+		t.Errorf("Error was %v, but expected %v", err, ErrArmorCorrupt)
+	}
+
+	truncated := armored[:len(armored)-20]
+	if _, _, err := Unarmor(truncated); err != ErrArmorCorrupt {
+		t.Errorf("Error was %v, but expected %v", err, ErrArmorCorrupt)
+	}
+
+	other := bytes.ReplaceAll(armored, []byte(ArmorType), []byte("PGP MESSAGE"))
+	if _, _, err := Unarmor(other); err != ErrArmorType {
+		t.Errorf("Error was %v, but expected %v", err, ErrArmorType)
+	}
+}
+
+func TestArmorInvalidHeaders(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+
+	for _, headers := range []map[string]string{
+		{"Key:Id": "1"},
+		{"Key-Id": "1\n2"},
+		{"Key\nId": "1"},
+		{"Key-Id": " 1 "},
+		{"Key-Id": "1\t"},
+		{" Key-Id": "1"},
+	} {
+		if v := Armor(nil, headers); v != nil {
+			t.Errorf("Armor returned %q for invalid headers %v", v, headers)
+		}
+
+		if _, err := ArmorSeal(aead, nil, nil, headers); err != ErrArmorHeader {
+			t.Errorf("Error was %v, but expected %v", err, ErrArmorHeader)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package siv
+
+import (
+	"errors"
+	"sync"
+)
+
+const boxVersion = 1
+
+var (
+	// ErrBoxVersion is returned when decoding an EncryptedBox with an unknown
+	// version or a malformed encoding.
+	ErrBoxVersion = errors.New("siv: unknown or malformed EncryptedBox encoding")
+
+	// ErrNoBoxKeyring is returned when encoding or decoding an EncryptedBox
+	// with neither its own keyring nor a default one.
+	ErrNoBoxKeyring = errors.New("siv: no keyring for EncryptedBox")
+)
+
+var boxKeyring struct {
+	sync.RWMutex
+	k *Keyring
+}
+
+// SetBoxKeyring sets the default keyring used to encode and decode
+// EncryptedBoxes which don't carry their own.
+func SetBoxKeyring(k *Keyring) {
+	boxKeyring.Lock()
+	defer boxKeyring.Unlock()
+
+	boxKeyring.k = k
+}
+
+// EncryptedBox holds bytes which are sealed when encoded with encoding/gob and
+// opened when decoded, so that selected fields of gob-encoded structs are
+// protected at rest.
+//
+// The box is sealed with the primary key of its keyring: either one given to
+// NewEncryptedBox or, failing that, the one set with SetBoxKeyring. Its
+// encoding is a version byte, the length-prefixed ID of the key, and the
+// ciphertext, with the version and key ID bound as associated data, so it is
+// decoded with the key it names. Decoding with the wrong keys returns an
+// error from the gob Decoder. An empty box decodes with a nil Data.
+type EncryptedBox struct {
+	Data []byte
+
+	keyring *Keyring
+}
+
+// NewEncryptedBox returns an EncryptedBox holding data which is encoded and
+// decoded with k rather than the default keyring.
+func NewEncryptedBox(k *Keyring, data []byte) *EncryptedBox {
+	return &EncryptedBox{Data: data, keyring: k}
+}
+
+func (b EncryptedBox) getKeyring() (*Keyring, error) {
+	if b.keyring != nil {
+		return b.keyring, nil
+	}
+
+	boxKeyring.RLock()
+	defer boxKeyring.RUnlock()
+
+	if boxKeyring.k == nil {
+		return nil, ErrNoBoxKeyring
+	}
+	return boxKeyring.k, nil
+}
+
+// GobEncode implements gob.GobEncoder.
+func (b EncryptedBox) GobEncode() ([]byte, error) {
+	k, err := b.getKeyring()
+	if err != nil {
+		return nil, err
+	}
+
+	id, aead := k.Primary()
+	if aead == nil {
+		return nil, ErrNoBoxKeyring
+	}
+	if len(id) > 255 {
+		return nil, errors.New("siv: EncryptedBox key ID longer than 255 bytes")
+	}
+
+	header := append([]byte{boxVersion, byte(len(id))}, id...)
+	return aead.Seal(header, nil, b.Data, header), nil
+}
+
+// GobDecode implements gob.GobDecoder.
+func (b *EncryptedBox) GobDecode(data []byte) error {
+	if len(data) < 2 || data[0] != boxVersion || len(data) < 2+int(data[1]) {
+		return ErrBoxVersion
+	}
+	header, ciphertext := data[:2+int(data[1])], data[2+int(data[1]):]
+
+	k, err := b.getKeyring()
+	if err != nil {
+		return err
+	}
+
+	aead, err := k.lookupOpen(string(header[2:]))
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := aead.Open(nil, nil, ciphertext, header)
+	if err != nil {
+		return err
+	}
+
+	b.Data = nil
+	if len(plaintext) > 0 {
+		b.Data = plaintext
+	}
+	return nil
+}
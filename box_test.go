@@ -0,0 +1,138 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/gob"
+	"errors"
+	"testing"
+)
+
+type boxRecord struct {
+	Name  string
+	SSN   EncryptedBox
+	Notes *EncryptedBox
+	Count int
+}
+
+func TestEncryptedBoxGob(t *testing.T) {
+	k, _ := testKeyring(t, "k1", "k2")
+	SetBoxKeyring(k)
+	defer SetBoxKeyring(nil)
+
+	records := []boxRecord{
+		{Name: "a", SSN: EncryptedBox{Data: []byte("123-45-6789")}, Notes: &EncryptedBox{Data: []byte("vip")}, Count: 1},
+		{Name: "b", SSN: EncryptedBox{Data: []byte("987-65-4321")}, Notes: &EncryptedBox{}, Count: 2},
+	}
+
+	var buf bytes.Buffer
+	e := gob.NewEncoder(&buf)
+	for _, r := range records {
+		if err := e.Encode(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if bytes.Contains(buf.Bytes(), []byte("123-45")) || bytes.Contains(buf.Bytes(), []byte("vip")) {
+		t.Fatal("Plaintext in gob stream")
+	}
+
+	// Rotating the primary key doesn't affect decoding.
+	if err := k.SetPrimary("k2"); err != nil {
+		t.Fatal(err)
+	}
+
+	d := gob.NewDecoder(&buf)
+	for _, expected := range records {
+		var actual boxRecord
+		if err := d.Decode(&actual); err != nil {
+			t.Fatal(err)
+		}
+
+		if actual.Name != expected.Name || actual.Count != expected.Count ||
+			!bytes.Equal(actual.SSN.Data, expected.SSN.Data) ||
+			!bytes.Equal(actual.Notes.Data, expected.Notes.Data) {
+			t.Errorf("Record was %+v, but expected %+v", actual, expected)
+		}
+	}
+}
+
+func TestEncryptedBoxExplicitKeyring(t *testing.T) {
+	k, _ := testKeyring(t, "explicit")
+
+	b, err := NewEncryptedBox(k, []byte("secret")).GobEncode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b[0] != 1 || b[1] != 8 || string(b[2:10]) != "explicit" {
+		t.Errorf("Header was %x", b[:10])
+	}
+
+	// Without a keyring, encoding and decoding fail.
+	var empty EncryptedBox
+	if err := empty.GobDecode(b); err != ErrNoBoxKeyring {
+		t.Errorf("Error was %v, but expected %v", err, ErrNoBoxKeyring)
+	}
+	if _, err := empty.GobEncode(); err != ErrNoBoxKeyring {
+		t.Errorf("Error was %v, but expected %v", err, ErrNoBoxKeyring)
+	}
+
+	box := NewEncryptedBox(k, nil)
+	if err := box.GobDecode(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(box.Data) != "secret" {
+		t.Errorf("Data was %q, but expected %q", box.Data, "secret")
+	}
+}
+
+func TestEncryptedBoxWrongKey(t *testing.T) {
+	k, _ := testKeyring(t, "k1")
+	SetBoxKeyring(k)
+	defer SetBoxKeyring(nil)
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(boxRecord{Name: "a", SSN: EncryptedBox{Data: []byte("x")}, Notes: &EncryptedBox{}}); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	// A different key under the same ID fails to authenticate.
+	other := NewKeyring()
+	aead, _ := New(bytes.Repeat([]byte{9}, 32), aes.NewCipher, AllowEqualHalves())
+	_ = other.Add("k1", aead)
+	SetBoxKeyring(other)
+
+	var r boxRecord
+	if err := gob.NewDecoder(bytes.NewReader(encoded)).Decode(&r); !errors.Is(err, ErrAuthentication) {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+
+	// An unknown key ID is reported as such.
+	unknown, _ := testKeyring(t, "k2")
+	SetBoxKeyring(unknown)
+	if err := gob.NewDecoder(bytes.NewReader(encoded)).Decode(&r); !errors.Is(err, ErrUnknownKeyID) {
+		t.Errorf("Error was %v, but expected %v", err, ErrUnknownKeyID)
+	}
+}
+
+func TestEncryptedBoxMalformed(t *testing.T) {
+	k, _ := testKeyring(t, "k1")
+	b, _ := NewEncryptedBox(k, []byte("x")).GobEncode()
+
+	for _, data := range [][]byte{nil, {1}, {2, 0}, {1, 5, 'k'}, append([]byte{2}, b[1:]...)} {
+		if err := NewEncryptedBox(k, nil).GobDecode(data); err != ErrBoxVersion {
+			t.Errorf("Error for %x was %v, but expected %v", data, err, ErrBoxVersion)
+		}
+	}
+
+	// The key ID is authenticated.
+	tampered := append([]byte{}, b...)
+	tampered[3] ^= 1
+	_ = k.Add(string(tampered[2:4]), k.keys[0].aead)
+	if err := NewEncryptedBox(k, nil).GobDecode(tampered); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+}
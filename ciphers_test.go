@@ -0,0 +1,123 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/des"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stripe/siv-go/internal/testciphers"
+)
+
+// The vectors below were computed with an independent SIV implementation built
+// from the OpenSSL 3.0 CMAC and CTR primitives for each cipher, using the
+// inputs from RFC 5297 appendix A.
+func TestNonAES(t *testing.T) {
+	a1 := []string{
+		"112233445566778899aabbccddee",
+		"101112131415161718191a1b1c1d1e1f2021222324252627",
+	}
+	a2 := []string{
+		"7468697320697320736f6d6520706c61696e7465787420746f20656e6372797074207573696e67205349562d414553",
+		"00112233445566778899aabbccddeeffdeaddadadeaddadaffeeddccbbaa99887766554433221100",
+		"102030405060708090a0",
+		"09f911029d74e35bd84156c5635688c0",
+	}
+	a1Key := "fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff"
+	a2Key := "7f7e7d7c7b7a79787776757473727170404142434445464748494a4b4c4d4e4f"
+	key48 := "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f202122232425262728292a2b2c2d2e2f"
+	key64 := key48 + "303132333435363738393a3b3c3d3e3f"
+
+	tests := []struct {
+		name   string
+		alg    func([]byte) (cipher.Block, error)
+		key    string
+		inputs []string // plaintext, then associated data
+		output string
+	}{
+		{
+			"SM4 A.1", testciphers.NewSM4, a1Key, a1,
+			"9a12a8d7bd932d583b0e02e45e836a7c3df7b452319cdda38530d3182a66",
+		},
+		{
+			"SM4 A.2", testciphers.NewSM4, a2Key, a2,
+			"ff1180d27c75af4950aadb9dbd4c42f2956b0f048f40fe23aa108d3f62dcf34e2ac9fc0d2ab510b6a3108f3f32d75f26bdda9d6c7cee27f897f29ae5baff16",
+		},
+		{
+			"SM4 empty", testciphers.NewSM4, a1Key, []string{""},
+			"c86b62acdb9556e5d9609aedeea11532",
+		},
+		{
+			"Camellia-128 A.1", testciphers.NewCamellia, a1Key, a1,
+			"5c40e2f2d2303b835097571173f6569b71575de40e6f46590d8434f33ec5",
+		},
+		{
+			"Camellia-128 A.2", testciphers.NewCamellia, a2Key, a2,
+			"875bb25a8e80a50c223378c9b0dd9f0733066cf69f7444011cb71c70400d3e23b4771ab89eb342bf900c0c79efbdfbc72f44ef372576de69c8641379df2153",
+		},
+		{
+			"Camellia-256 A.2", testciphers.NewCamellia, key64, a2,
+			"711b070044ae880262f168c2f4776f252657cfbba02b94f90280a30f0dd46d18720c426209d259e15be3dc00bb344ed00e61349799f49e86318b7449ec963a",
+		},
+		{
+			"ARIA-128 A.1", testciphers.NewARIA, a1Key, a1,
+			"9022e02e8f7a9dd3cdc8d43379c3c951a81fab12d706cd1e9e6bef9dd3f1",
+		},
+		{
+			"ARIA-128 A.2", testciphers.NewARIA, a2Key, a2,
+			"6bfcc00e7dcb491420bab18f558def702e4a624764ac7791d56f82c598b68bfc61cf4ffa6bf8c320135693135399fb4fcd69fdc53bb5b9de1e8d4f40ab2615",
+		},
+		{
+			"ARIA-192 A.2", testciphers.NewARIA, key48, a2,
+			"69e523f08768e6dadb5c4603d229d4a2ccb2508cab1e5f2167854c28cfee7ab63a7d8eddcdb305e8ac40ad492c811770ea8ba83ce530d66991f2c46f1fc4c7",
+		},
+		{
+			"ARIA-256 A.2", testciphers.NewARIA, key64, a2,
+			"9ff8a3f2491ad985c7eda7495535d61b3c1ca3cefd3fdd38862dbc33839cd97353992432902678c21cbecb09aef06ca6f4baf3efb3ea5e856988c9e8bfd871",
+		},
+	}
+
+	for _, test := range tests {
+		key, _ := hex.DecodeString(test.key)
+		plaintext, _ := hex.DecodeString(test.inputs[0])
+		expected, _ := hex.DecodeString(test.output)
+
+		var data [][]byte
+		for _, s := range test.inputs[1:] {
+			b, _ := hex.DecodeString(s)
+			data = append(data, b)
+		}
+
+		c, err := New(key, test.alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := c.(multiAEAD)
+
+		actual := m.SealMulti(nil, plaintext, data...)
+		if !bytes.Equal(actual, expected) {
+			t.Errorf("%s: Ciphertext was %x, but expected %x", test.name, actual, expected)
+		}
+
+		p, err := m.OpenMulti(nil, expected, data...)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+
+		if !bytes.Equal(p, plaintext) {
+			t.Errorf("%s: Plaintext was %x, but expected %x", test.name, p, plaintext)
+		}
+
+		expected[len(expected)-1] ^= 1
+		if p, err := m.OpenMulti(nil, expected, data...); err == nil {
+			t.Fatalf("%s: Plaintext returned instead of error: %x", test.name, p)
+		}
+	}
+}
+
+func TestNewBlockSize(t *testing.T) {
+	if _, err := New(testKey(0, 16), des.NewCipher); err != errBlockSize {
+		t.Errorf("Error was %v, but expected %v", err, errBlockSize)
+	}
+}
@@ -0,0 +1,117 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrCiphertextJSON is returned when a JSON ciphertext is malformed or has
+// fields other than those of Ciphertext.
+var ErrCiphertextJSON = errors.New("siv: malformed JSON ciphertext")
+
+// Ciphertext is a ciphertext along with the ID and algorithm of the key it was
+// sealed with, for embedding in JSON documents. It is encoded as an object
+// with exactly the fields "kid", "alg", and "data", in that order, with Data
+// in standard padded base64:
+//
+//	{"kid":"2024-01","alg":"AES-SIV-CMAC-256","data":"..."}
+type Ciphertext struct {
+	KeyID     string
+	Algorithm string
+	Data      []byte
+}
+
+type ciphertextJSON struct {
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Data      []byte `json:"data"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (c Ciphertext) MarshalJSON() ([]byte, error) {
+	return json.Marshal(ciphertextJSON(c))
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It requires every field to be
+// present, but ignores unknown fields; OpenJSON rejects them.
+func (c *Ciphertext) UnmarshalJSON(b []byte) error {
+	return c.unmarshal(b, false)
+}
+
+func (c *Ciphertext) unmarshal(b []byte, strict bool) error {
+	var v struct {
+		KeyID     *string `json:"kid"`
+		Algorithm *string `json:"alg"`
+		Data      *[]byte `json:"data"`
+	}
+
+	d := json.NewDecoder(bytes.NewReader(b))
+	if strict {
+		d.DisallowUnknownFields()
+	}
+
+	if err := d.Decode(&v); err != nil {
+		return fmt.Errorf("%w: %v", ErrCiphertextJSON, err)
+	}
+
+	if _, err := d.Token(); err != io.EOF {
+		return fmt.Errorf("%w: trailing data", ErrCiphertextJSON)
+	}
+
+	if v.KeyID == nil || v.Algorithm == nil || v.Data == nil {
+		return fmt.Errorf("%w: missing field", ErrCiphertextJSON)
+	}
+
+	*c = Ciphertext{KeyID: *v.KeyID, Algorithm: *v.Algorithm, Data: *v.Data}
+	return nil
+}
+
+// SealJSON seals plaintext with the keyring's primary key and returns it as a
+// JSON-encoded Ciphertext. The key ID and algorithm are authenticated along
+// with ad, so neither can be altered without OpenJSON failing. It panics if
+// the keyring is empty.
+func SealJSON(k *Keyring, plaintext, ad []byte) json.RawMessage {
+	id, aead := k.Primary()
+	if aead == nil {
+		panic("siv: keyring is empty")
+	}
+
+	c := Ciphertext{KeyID: id, Algorithm: algorithm(aead)}
+	c.Data = ciphertextAEAD(aead, c).Seal(nil, nil, plaintext, ad)
+
+	b, _ := c.MarshalJSON()
+	return b
+}
+
+// OpenJSON opens a JSON-encoded Ciphertext produced by SealJSON with the key
+// it names. Unlike Ciphertext.UnmarshalJSON, it rejects unknown fields with
+// ErrCiphertextJSON. It returns ErrUnknownKeyID if the keyring holds no key
+// with the ciphertext's ID, and a RevokedKeyError if the key is revoked.
+func OpenJSON(k *Keyring, raw json.RawMessage, ad []byte) ([]byte, error) {
+	var c Ciphertext
+	if err := c.unmarshal(raw, true); err != nil {
+		return nil, err
+	}
+
+	aead, err := k.lookupOpen(c.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	return ciphertextAEAD(aead, c).Open(nil, nil, c.Data, ad)
+}
+
+func ciphertextAEAD(aead cipher.AEAD, c Ciphertext) cipher.AEAD {
+	return NewContext(aead, []byte("siv json ciphertext"), []byte(c.KeyID), []byte(c.Algorithm))
+}
+
+// algorithm returns the name of aead's algorithm, if it reports one.
+func algorithm(aead cipher.AEAD) string {
+	if info, ok := aead.(Info); ok {
+		return info.Algorithm()
+	}
+	return ""
+}
@@ -0,0 +1,126 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSealJSONGolden(t *testing.T) {
+	k, _ := testKeyring(t, "2024-01", "2024-02")
+	if err := k.SetPrimary("2024-02"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := os.ReadFile("testdata/ciphertext.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = bytes.TrimSpace(expected)
+
+	actual := SealJSON(k, []byte("4242424242424242"), []byte("card"))
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("JSON was %s, but expected %s", actual, expected)
+	}
+
+	plaintext, err := OpenJSON(k, expected, []byte("card"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(plaintext) != "4242424242424242" {
+		t.Errorf("Plaintext was %q, but expected %q", plaintext, "4242424242424242")
+	}
+}
+
+func TestCiphertextJSON(t *testing.T) {
+	c := Ciphertext{KeyID: "k", Algorithm: "AES-SIV-CMAC-256", Data: []byte{0xff, 0xfe}}
+
+	b, err := json.Marshal(struct {
+		Card Ciphertext `json:"card"`
+	}{c})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"card":{"kid":"k","alg":"AES-SIV-CMAC-256","data":"//4="}}`
+	if string(b) != expected {
+		t.Errorf("JSON was %s, but expected %s", b, expected)
+	}
+
+	var actual struct {
+		Card Ciphertext `json:"card"`
+	}
+	if err := json.Unmarshal(b, &actual); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual.Card.KeyID != c.KeyID || actual.Card.Algorithm != c.Algorithm || !bytes.Equal(actual.Card.Data, c.Data) {
+		t.Errorf("Ciphertext was %+v, but expected %+v", actual.Card, c)
+	}
+
+	// Unmarshaling alone tolerates unknown fields.
+	if err := json.Unmarshal([]byte(`{"kid":"k","alg":"","data":"","v":1}`), &c); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOpenJSONMalformed(t *testing.T) {
+	k, _ := testKeyring(t, "2024-01")
+	valid := string(SealJSON(k, []byte("secret"), nil))
+
+	for _, raw := range []string{
+		``,
+		`null`,
+		`[]`,
+		`{"kid":"2024-01","alg":"AES-SIV-CMAC-256"}`,
+		`{"kid":"2024-01","data":"AAAA"}`,
+		`{"alg":"AES-SIV-CMAC-256","data":"AAAA"}`,
+		`{"kid":"2024-01","alg":"AES-SIV-CMAC-256","data":"!!"}`,
+		strings.TrimSuffix(valid, "}") + `,"extra":true}`,
+		valid + `{}`,
+	} {
+		if p, err := OpenJSON(k, json.RawMessage(raw), nil); !errors.Is(err, ErrCiphertextJSON) {
+			t.Fatalf("Plaintext returned instead of error for %s: %x", raw, p)
+		}
+	}
+}
+
+func TestOpenJSONTampering(t *testing.T) {
+	k, _ := testKeyring(t, "2024-01", "2024-02")
+	aead, _ := New(bytes.Repeat([]byte{1}, 64), aes.NewCipher, AllowEqualHalves())
+	if err := k.Add("2024-03", aead); err != nil {
+		t.Fatal(err)
+	}
+
+	var c Ciphertext
+	if err := json.Unmarshal(SealJSON(k, []byte("secret"), []byte("ad")), &c); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []Ciphertext{
+		{KeyID: "2024-02", Algorithm: c.Algorithm, Data: c.Data},
+		{KeyID: c.KeyID, Algorithm: "AES-SIV-CMAC-512", Data: c.Data},
+		{KeyID: c.KeyID, Algorithm: c.Algorithm, Data: c.Data[1:]},
+	} {
+		b, _ := json.Marshal(v)
+		if p, err := OpenJSON(k, b, []byte("ad")); err != ErrAuthentication {
+			t.Fatalf("Plaintext returned instead of error: %x", p)
+		}
+	}
+
+	b, _ := json.Marshal(c)
+	if p, err := OpenJSON(k, b, []byte("other")); err != ErrAuthentication {
+		t.Fatalf("Plaintext returned instead of error: %x", p)
+	}
+
+	c.KeyID = "2023-12"
+	b, _ = json.Marshal(c)
+	if _, err := OpenJSON(k, b, []byte("ad")); err != ErrUnknownKeyID {
+		t.Errorf("Error was %v, but expected %v", err, ErrUnknownKeyID)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+type fixture struct {
+	dir              string
+	oldKey, newKey   string
+	oldAEAD, newAEAD cipher.AEAD
+}
+
+func newFixture(t *testing.T) *fixture {
+	f := &fixture{dir: t.TempDir()}
+
+	oldKey, newKey := []byte("0123456789abcdef0123456789ABCDEF"), []byte("fedcba9876543210FEDCBA9876543210")
+	f.oldAEAD, _ = siv.New(oldKey, aes.NewCipher)
+	f.newAEAD, _ = siv.New(newKey, aes.NewCipher)
+
+	f.oldKey = f.write(t, "old.key", hex.EncodeToString(oldKey)+"\n")
+	f.newKey = f.write(t, "new.key", base64.StdEncoding.EncodeToString(newKey))
+	return f
+}
+
+func (f *fixture) write(t *testing.T, name, contents string) string {
+	path := filepath.Join(f.dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(contents), 0640); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func seal(aead cipher.AEAD, plaintext string) string {
+	return base64.StdEncoding.EncodeToString(aead.Seal(nil, nil, []byte(plaintext), nil))
+}
+
+func open(t *testing.T, aead cipher.AEAD, encoded string) string {
+	ciphertext, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext, err := aead.Open(nil, nil, ciphertext, nil)
+	if err != nil {
+		t.Fatalf("%s: %v", encoded, err)
+	}
+	return string(plaintext)
+}
+
+func (f *fixture) run(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	args = append([]string{"-old-key", f.oldKey, "-new-key", f.newKey, "-workers", "3"}, args...)
+	return run(args, &stdout, &stderr), stdout.String(), stderr.String()
+}
+
+func TestRotateDir(t *testing.T) {
+	f := newFixture(t)
+	root := filepath.Join(f.dir, "data")
+
+	a := f.write(t, "data/a", seal(f.oldAEAD, "a")+"\n")
+	b := f.write(t, "data/sub/b", seal(f.oldAEAD, "b"))
+	c := f.write(t, "data/sub/c", seal(f.newAEAD, "c")+"\n")
+
+	code, stdout, stderr := f.run(root)
+	if code != exitOK {
+		t.Fatalf("Exit status was %d, but expected %d: %s", code, exitOK, stderr)
+	}
+
+	if !strings.HasPrefix(stdout, "re-encrypted 2, failed 0, skipped 1") {
+		t.Errorf("Summary was %q", stdout)
+	}
+
+	for path, expected := range map[string]string{a: "a", b: "b", c: "c"} {
+		contents, _ := os.ReadFile(path)
+		if actual := open(t, f.newAEAD, string(contents)); actual != expected {
+			t.Errorf("%s: Plaintext was %q, but expected %q", path, actual, expected)
+		}
+	}
+
+	// Newlines and permissions are kept, and no temporary files are left.
+	contents, _ := os.ReadFile(a)
+	if !bytes.HasSuffix(contents, []byte("\n")) {
+		t.Errorf("Newline was dropped from %q", contents)
+	}
+
+	contents, _ = os.ReadFile(b)
+	if bytes.HasSuffix(contents, []byte("\n")) {
+		t.Errorf("Newline was added to %q", contents)
+	}
+
+	if info, _ := os.Stat(a); info.Mode().Perm() != 0640 {
+		t.Errorf("Mode was %v, but expected %v", info.Mode().Perm(), os.FileMode(0640))
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(root, "sub"))
+	if len(entries) != 2 {
+		t.Errorf("Directory held %d files, but expected %d", len(entries), 2)
+	}
+
+	// Running again skips everything.
+	if code, stdout, _ := f.run(root); code != exitOK || !strings.HasPrefix(stdout, "re-encrypted 0, failed 0, skipped 3") {
+		t.Errorf("Second run exited %d with %q", code, stdout)
+	}
+}
+
+func TestRotateDirPartialFailure(t *testing.T) {
+	f := newFixture(t)
+	root := filepath.Join(f.dir, "data")
+
+	good := f.write(t, "data/good", seal(f.oldAEAD, "good"))
+	bad := f.write(t, "data/bad", "not base64!")
+	other, _ := siv.New(bytes.Repeat([]byte{3}, 32), aes.NewCipher, siv.AllowEqualHalves())
+	wrong := f.write(t, "data/wrong", seal(other, "wrong"))
+
+	code, stdout, stderr := f.run(root)
+	if code != exitPartial {
+		t.Fatalf("Exit status was %d, but expected %d", code, exitPartial)
+	}
+
+	if !strings.HasPrefix(stdout, "re-encrypted 1, failed 2, skipped 0") {
+		t.Errorf("Summary was %q", stdout)
+	}
+
+	for _, path := range []string{bad, wrong} {
+		if !strings.Contains(stderr, path) {
+			t.Errorf("Failure of %s was not reported in %q", path, stderr)
+		}
+	}
+
+	contents, _ := os.ReadFile(good)
+	if actual := open(t, f.newAEAD, string(contents)); actual != "good" {
+		t.Errorf("Plaintext was %q, but expected %q", actual, "good")
+	}
+
+	contents, _ = os.ReadFile(bad)
+	if string(contents) != "not base64!" {
+		t.Errorf("Failed file was rewritten to %q", contents)
+	}
+}
+
+func TestRotateDryRun(t *testing.T) {
+	f := newFixture(t)
+	original := seal(f.oldAEAD, "a")
+	path := f.write(t, "data/a", original)
+
+	code, stdout, _ := f.run("-dry-run", filepath.Join(f.dir, "data"))
+	if code != exitOK || !strings.HasPrefix(stdout, "would re-encrypt 1, failed 0, skipped 0") {
+		t.Errorf("Dry run exited %d with %q", code, stdout)
+	}
+
+	contents, _ := os.ReadFile(path)
+	if string(contents) != original {
+		t.Errorf("Dry run rewrote %s", path)
+	}
+
+	ndjson := f.write(t, "dump.ndjson", `{"ct":"`+original+`"}`+"\n")
+	if code, _, _ := f.run("-dry-run", "-format", "ndjson", "-field", "ct", ndjson); code != exitOK {
+		t.Errorf("Dry run exited %d", code)
+	}
+
+	contents, _ = os.ReadFile(ndjson)
+	if string(contents) != `{"ct":"`+original+`"}`+"\n" {
+		t.Errorf("Dry run rewrote %s", ndjson)
+	}
+}
+
+func TestRotateNDJSON(t *testing.T) {
+	f := newFixture(t)
+
+	var lines []string
+	for i := 0; i < 50; i++ {
+		lines = append(lines, `{"id":`+string(rune('0'+i%10))+`,"ct":"`+seal(f.oldAEAD, strings.Repeat("x", i))+`","note":"aé"}`)
+	}
+	lines = append(lines,
+		`{"id":"new","ct":"`+seal(f.newAEAD, "new")+`"}`,
+		`{"id":"missing"}`,
+		`not json`,
+	)
+	path := f.write(t, "dump.ndjson", strings.Join(lines, "\n")+"\n")
+
+	code, stdout, stderr := f.run("-format", "ndjson", "-field", "ct", path)
+	if code != exitPartial {
+		t.Fatalf("Exit status was %d, but expected %d: %s", code, exitPartial, stderr)
+	}
+
+	if !strings.HasPrefix(stdout, "re-encrypted 50, failed 2, skipped 1") {
+		t.Errorf("Summary was %q", stdout)
+	}
+
+	if !strings.Contains(stderr, path+":52") || !strings.Contains(stderr, path+":53") {
+		t.Errorf("Failures were reported as %q", stderr)
+	}
+
+	contents, _ := os.ReadFile(path)
+	actual := strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n")
+	if len(actual) != len(lines) {
+		t.Fatalf("Output had %d lines, but expected %d", len(actual), len(lines))
+	}
+
+	for i := 0; i < 50; i++ {
+		var v struct {
+			ID   int
+			CT   string
+			Note string
+		}
+		if err := json.Unmarshal([]byte(actual[i]), &v); err != nil {
+			t.Fatal(err)
+		}
+
+		if v.ID != i%10 || v.Note != "aé" {
+			t.Errorf("Line %d was %s", i+1, actual[i])
+		}
+
+		if p := open(t, f.newAEAD, v.CT); p != strings.Repeat("x", i) {
+			t.Errorf("Line %d: Plaintext was %q, but expected %q", i+1, p, strings.Repeat("x", i))
+		}
+
+		// Field order and the other values' encoding are preserved.
+		if !strings.HasPrefix(actual[i], `{"id":`) || !strings.HasSuffix(actual[i], `"note":"aé"}`) {
+			t.Errorf("Line %d was %s", i+1, actual[i])
+		}
+	}
+
+	for i := 50; i < len(lines); i++ {
+		if actual[i] != lines[i] {
+			t.Errorf("Line %d was %s, but expected %s", i+1, actual[i], lines[i])
+		}
+	}
+}
+
+func TestRotateUsage(t *testing.T) {
+	f := newFixture(t)
+
+	for _, args := range [][]string{
+		{},
+		{"-format", "ndjson", f.dir},
+		{"-format", "xml", f.dir},
+		{"-workers", "0", f.dir},
+		{"-new-key", f.oldKey, f.dir},
+		{"-old-key", filepath.Join(f.dir, "missing"), f.dir},
+		{"-bogus"},
+	} {
+		if code, _, _ := f.run(args...); code != exitUsage {
+			t.Errorf("Exit status for %v was %d, but expected %d", args, code, exitUsage)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	siv "github.com/stripe/siv-go"
+)
+
+// outcome is the result of rotating a single ciphertext.
+type outcome int
+
+const (
+	succeeded outcome = iota
+	skipped
+	failed
+)
+
+var errNotCiphertext = errors.New("not a base64 ciphertext")
+
+type summary struct {
+	succeeded, failed, skipped int
+}
+
+// rotator re-encrypts ciphertexts from one key to another and counts the
+// results.
+type rotator struct {
+	oldAEAD, newAEAD               cipher.AEAD
+	oldFingerprint, newFingerprint string
+	ad                             []byte
+	dryRun                         bool
+	stderr                         io.Writer
+
+	mu     sync.Mutex
+	counts summary
+}
+
+func newRotator(oldKeyFile, newKeyFile, ad string) (*rotator, error) {
+	r := &rotator{stderr: io.Discard}
+	if ad != "" {
+		r.ad = []byte(ad)
+	}
+
+	var err error
+	if r.oldAEAD, r.oldFingerprint, err = readKey(oldKeyFile); err != nil {
+		return nil, err
+	}
+	if r.newAEAD, r.newFingerprint, err = readKey(newKeyFile); err != nil {
+		return nil, err
+	}
+
+	if r.oldFingerprint == r.newFingerprint {
+		return nil, errors.New("the old and new keys are the same")
+	}
+	return r, nil
+}
+
+func (r *rotator) summary() summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.counts
+}
+
+// record counts the outcome of rotating the named ciphertext, reporting any
+// error.
+func (r *rotator) record(name string, o outcome, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	switch o {
+	case succeeded:
+		r.counts.succeeded++
+	case skipped:
+		r.counts.skipped++
+	case failed:
+		r.counts.failed++
+		fmt.Fprintf(r.stderr, "%s: %v\n", name, err)
+	}
+}
+
+// rotate re-encrypts a base64 ciphertext, returning it unchanged if it is
+// already under the new key.
+func (r *rotator) rotate(encoded []byte) ([]byte, outcome, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(string(encoded))
+	if err != nil {
+		return nil, failed, errNotCiphertext
+	}
+
+	if _, err := r.newAEAD.Open(nil, nil, ciphertext, r.ad); err == nil {
+		return encoded, skipped, nil
+	}
+
+	rotated, err := siv.ReEncrypt(r.oldAEAD, r.newAEAD, ciphertext, r.ad)
+	if err != nil {
+		return nil, failed, err
+	}
+	return []byte(base64.StdEncoding.EncodeToString(rotated)), succeeded, nil
+}
+
+// rotateDir rotates the ciphertext in every regular file under root.
+func (r *rotator) rotateDir(root string, workers int) error {
+	paths := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				o, err := r.rotateFile(path)
+				r.record(path, o, err)
+			}
+		}()
+	}
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			paths <- path
+		}
+		return nil
+	})
+	close(paths)
+	wg.Wait()
+	return err
+}
+
+func (r *rotator) rotateFile(path string) (outcome, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return failed, err
+	}
+
+	trimmed := bytes.TrimSpace(b)
+	rotated, o, err := r.rotate(trimmed)
+	if o != succeeded || r.dryRun {
+		return o, err
+	}
+
+	// Keep any trailing newline.
+	if bytes.HasSuffix(b, []byte("\n")) {
+		rotated = append(rotated, '\n')
+	}
+
+	if err := replaceFile(path, func(w io.Writer) error {
+		_, err := w.Write(rotated)
+		return err
+	}); err != nil {
+		return failed, err
+	}
+	return succeeded, nil
+}
+
+// rotateNDJSON rotates the named field of each line of the NDJSON file at
+// path. Lines which fail are copied through unchanged.
+func (r *rotator) rotateNDJSON(path, field string, workers int) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	write := func(w io.Writer) error {
+		return r.rotateLines(in, w, path, field, workers)
+	}
+
+	if r.dryRun {
+		return write(io.Discard)
+	}
+	return replaceFile(path, write)
+}
+
+// rotateLines rotates each line of in concurrently, writing the results to out
+// in their original order.
+func (r *rotator) rotateLines(in io.Reader, out io.Writer, path, field string, workers int) error {
+	type job struct {
+		line   []byte
+		n      int
+		result chan []byte
+	}
+
+	jobs := make(chan job)
+	queue := make(chan job, workers*4)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				rotated, o, err := r.rotateLine(j.line, field)
+				r.record(fmt.Sprintf("%s:%d", path, j.n), o, err)
+				if o != succeeded {
+					rotated = j.line
+				}
+				j.result <- rotated
+			}
+		}()
+	}
+
+	var writeErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w := bufio.NewWriter(out)
+		for j := range queue {
+			line := <-j.result
+			if writeErr == nil {
+				_, writeErr = w.Write(append(line, '\n'))
+			}
+		}
+		if writeErr == nil {
+			writeErr = w.Flush()
+		}
+	}()
+
+	s := bufio.NewScanner(in)
+	s.Buffer(nil, 64<<20)
+	for n := 1; s.Scan(); n++ {
+		j := job{line: append([]byte{}, s.Bytes()...), n: n, result: make(chan []byte, 1)}
+		queue <- j
+		jobs <- j
+	}
+	close(jobs)
+	close(queue)
+	wg.Wait()
+	<-done
+
+	if err := s.Err(); err != nil {
+		return err
+	}
+	return writeErr
+}
+
+// rotateLine rotates the named string field of a JSON object, preserving the
+// order and encoding of its other fields.
+func (r *rotator) rotateLine(line []byte, field string) ([]byte, outcome, error) {
+	d := json.NewDecoder(bytes.NewReader(line))
+	if t, err := d.Token(); err != nil || t != json.Delim('{') {
+		return nil, failed, errors.New("not a JSON object")
+	}
+
+	var b bytes.Buffer
+	b.WriteByte('{')
+
+	o, found := failed, false
+	for i := 0; d.More(); i++ {
+		t, err := d.Token()
+		if err != nil {
+			return nil, failed, err
+		}
+		key, _ := t.(string)
+
+		var value json.RawMessage
+		if err := d.Decode(&value); err != nil {
+			return nil, failed, err
+		}
+
+		if key == field {
+			var s string
+			if err := json.Unmarshal(value, &s); err != nil {
+				return nil, failed, errNotCiphertext
+			}
+
+			rotated, outcome, err := r.rotate([]byte(s))
+			if err != nil {
+				return nil, outcome, err
+			}
+			value, _ = json.Marshal(string(rotated))
+			o, found = outcome, true
+		}
+
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+
+	if _, err := d.Token(); err != nil {
+		return nil, failed, err
+	}
+	b.WriteByte('}')
+
+	if !found {
+		return nil, failed, fmt.Errorf("no field %q", field)
+	}
+	return b.Bytes(), o, nil
+}
+
+// replaceFile atomically replaces the file at path with the output of write,
+// keeping its permissions.
+func replaceFile(path string, write func(io.Writer) error) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	err = write(f)
+	if err == nil {
+		err = f.Chmod(info.Mode().Perm())
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func csvKey(t *testing.T) string {
+	path := filepath.Join(t.TempDir(), "key")
+	if err := keygenCommand([]string{"-out", path}, nil, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runCSV(t *testing.T, input string, args ...string) (string, error) {
+	var stdout bytes.Buffer
+	err := csvCommand(args, strings.NewReader(input), &stdout)
+	return stdout.String(), err
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	key := csvKey(t)
+	input := "id,name,notes\n" +
+		"cus_1,\"Doe, Jane\",\"line one\nline two\"\n" +
+		"cus_2,\"Say \"\"hi\"\"\",\n"
+
+	encrypted, err := runCSV(t, input, "-key", key, "-columns", "name,3", "-ad-column", "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(encrypted)).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 3 || strings.Join(records[0], ",") != "id,name,notes" {
+		t.Fatalf("Output was %q", encrypted)
+	}
+
+	for _, record := range records[1:] {
+		if strings.Contains(record[1], "Jane") || strings.Contains(record[1], "hi") {
+			t.Errorf("Plaintext in output: %q", record[1])
+		}
+	}
+
+	decrypted, err := runCSV(t, encrypted, "-key", key, "-columns", "name,notes", "-ad-column", "1", "-decrypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decrypted != input {
+		t.Errorf("Output was %q, but expected %q", decrypted, input)
+	}
+}
+
+func TestCSVNoHeader(t *testing.T) {
+	key := csvKey(t)
+	input := "a,b\nc,d\n"
+
+	encrypted, err := runCSV(t, input, "-key", key, "-columns", "2", "-no-header")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(encrypted, "a,") || strings.Contains(encrypted, "b\n") {
+		t.Errorf("Output was %q", encrypted)
+	}
+
+	decrypted, err := runCSV(t, encrypted, "-key", key, "-columns", "2", "-no-header", "-decrypt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decrypted != input {
+		t.Errorf("Output was %q, but expected %q", decrypted, input)
+	}
+
+	// Without a header, names can't be resolved.
+	if _, err := runCSV(t, input, "-key", key, "-columns", "a", "-no-header"); err == nil {
+		t.Error("Expected an error for a column name without a header")
+	}
+}
+
+func TestCSVMissingColumn(t *testing.T) {
+	key := csvKey(t)
+
+	for _, args := range [][]string{
+		{"-columns", "email"},
+		{"-columns", "4"},
+		{"-columns", "0"},
+		{"-columns", "name", "-ad-column", "customer"},
+		{"-columns", "name", "-ad-column", "name"},
+		{"-columns", "name,2"},
+	} {
+		out, err := runCSV(t, "id,name,notes\n1,2,3\n", append([]string{"-key", key}, args...)...)
+		if err == nil {
+			t.Errorf("Expected an error for %v", args)
+		}
+
+		if out != "" {
+			t.Errorf("Output was %q, but expected nothing", out)
+		}
+	}
+}
+
+func TestCSVADBinding(t *testing.T) {
+	key := csvKey(t)
+
+	encrypted, err := runCSV(t, "id,ssn\n1,111\n2,222\n", "-key", key, "-columns", "ssn", "-ad-column", "id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Swap the encrypted cells between rows.
+	records, _ := csv.NewReader(strings.NewReader(encrypted)).ReadAll()
+	records[1][1], records[2][1] = records[2][1], records[1][1]
+
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	_ = w.WriteAll(records)
+
+	if out, err := runCSV(t, b.String(), "-key", key, "-columns", "ssn", "-ad-column", "id", "-decrypt"); err == nil {
+		t.Fatalf("Plaintext returned instead of error: %q", out)
+	}
+}
+
+func TestCSVColumnBinding(t *testing.T) {
+	key := csvKey(t)
+
+	for _, args := range [][]string{{}, {"-no-header"}} {
+		input := "1,2\n111,222\n"
+		if len(args) == 0 {
+			input = "ssn,phone\n111,222\n"
+		}
+
+		encrypted, err := runCSV(t, input, append([]string{"-key", key, "-columns", "1,2"}, args...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Swap the encrypted cells between columns.
+		records, _ := csv.NewReader(strings.NewReader(encrypted)).ReadAll()
+		records[1][0], records[1][1] = records[1][1], records[1][0]
+
+		var b bytes.Buffer
+		w := csv.NewWriter(&b)
+		_ = w.WriteAll(records)
+
+		if out, err := runCSV(t, b.String(), append([]string{"-key", key, "-columns", "1,2", "-decrypt"}, args...)...); err == nil {
+			t.Errorf("%v: Plaintext returned instead of error: %q", args, out)
+		}
+	}
+}
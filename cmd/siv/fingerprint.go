@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func fingerprintCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("fingerprint")
+	keyFlag := addKeyFlag(fs, "key", "key")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	key, err := keyFlag.load(stdin)
+	if err != nil {
+		return err
+	}
+	defer wipe(key)
+
+	f, err := fingerprintKey(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(stdout, f)
+	return err
+}
+
+// fingerprintKey returns the hex-encoded key check value of an AES-SIV key.
+func fingerprintKey(key []byte) (string, error) {
+	f, err := siv.KeyFingerprint(key)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(f), nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFingerprint(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, v := range []struct {
+		contents, expected string
+	}{
+		// https://tools.ietf.org/html/rfc5297#appendix-A.1
+		{
+			"fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff\n",
+			"af19846f5af826a7",
+		},
+		{
+			"//79/Pv6+fj39vX08/Lx8PDx8vP09fb3+Pn6+/z9/v8=",
+			"af19846f5af826a7",
+		},
+	} {
+		path := filepath.Join(dir, "key")
+		if err := os.WriteFile(path, []byte(v.contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		key, err := (&keyFlag{name: "key", path: path}).load(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := fingerprintKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual != v.expected {
+			t.Errorf("Fingerprint was %s, but expected %s", actual, v.expected)
+		}
+	}
+}
+
+func TestFingerprintBadKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte("00112233"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	if err := fingerprintCommand([]string{"-key", path}, nil, &stdout); err == nil {
+		t.Errorf("Fingerprint returned for a short key: %s", stdout.String())
+	}
+
+	if f, err := fingerprintKey([]byte{0, 1, 2, 3}); err == nil {
+		t.Errorf("Fingerprint returned for a short key: %s", f)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+func keygenCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("keygen")
+	size := fs.Int("size", 256, "combined key size in bits: 256, 384, or 512")
+	encoding := fs.String("encoding", "hex", "output encoding: hex or base64")
+	out := fs.String("out", "", "file to create with mode 0600, or - for standard output")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *out == "" {
+		return errors.New("-out is required")
+	}
+
+	key, err := keygen(rand.Reader, *size)
+	if err != nil {
+		return err
+	}
+
+	b, err := encode(key, *encoding)
+	if err != nil {
+		return err
+	}
+
+	if *out == "-" {
+		_, err = stdout.Write(b)
+		return err
+	}
+	return writeSecret(*out, b)
+}
+
+// keygen returns a new random key of the given size in bits.
+func keygen(r io.Reader, bits int) ([]byte, error) {
+	switch bits {
+	case 256, 384, 512:
+	default:
+		return nil, errKeySize
+	}
+
+	key := make([]byte, bits/8)
+	if _, err := io.ReadFull(r, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func TestKeygen(t *testing.T) {
+	for _, bits := range []int{256, 384, 512} {
+		key, err := keygen(bytes.NewReader(bytes.Repeat([]byte{1}, 64)), bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(key) != bits/8 {
+			t.Errorf("Key was %d bytes, but expected %d", len(key), bits/8)
+		}
+	}
+
+	if _, err := keygen(bytes.NewReader(make([]byte, 64)), 128); err != errKeySize {
+		t.Errorf("Error was %v, but expected %v", err, errKeySize)
+	}
+}
+
+func TestKeygenCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "key")
+
+	var stdout bytes.Buffer
+	if err := keygenCommand([]string{"-size", "512", "-encoding", "base64", "-out", path}, nil, &stdout); err != nil {
+		t.Fatal(err)
+	}
+
+	if stdout.Len() != 0 {
+		t.Errorf("Key was written to stdout: %q", stdout.String())
+	}
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mode := fi.Mode().Perm(); mode != 0600 {
+		t.Errorf("Mode was %o, but expected %o", mode, 0600)
+	}
+
+	key, err := readKey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(key) != 64 {
+		t.Errorf("Key was %d bytes, but expected %d", len(key), 64)
+	}
+
+	// Existing files are never overwritten.
+	if err := keygenCommand([]string{"-out", path}, nil, &stdout); !os.IsExist(err) {
+		t.Errorf("Error was %v, but expected a file exists error", err)
+	}
+
+	if k, _ := readKey(path); !bytes.Equal(k, key) {
+		t.Errorf("Key file was overwritten")
+	}
+}
+
+func TestKeygenCommandStdout(t *testing.T) {
+	var stdout bytes.Buffer
+	if err := keygenCommand(nil, nil, &stdout); err == nil {
+		t.Fatalf("Key generated without -out")
+	}
+
+	if stdout.Len() != 0 {
+		t.Errorf("Key was written to stdout: %q", stdout.String())
+	}
+
+	if err := keygenCommand([]string{"-out", "-"}, nil, &stdout); err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := siv.DecodeKey(stdout.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(key) != 32 {
+		t.Errorf("Key was %d bytes, but expected %d", len(key), 32)
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	siv "github.com/stripe/siv-go"
+)
+
+var errKeySize = errors.New("key must be 256, 384, or 512 bits")
+
+// encode returns the text encoding of b, followed by a newline.
+func encode(b []byte, encoding string) ([]byte, error) {
+	var s string
+	switch encoding {
+	case "hex":
+		s = hex.EncodeToString(b)
+	case "base64":
+		s = base64.StdEncoding.EncodeToString(b)
+	default:
+		return nil, fmt.Errorf("unknown encoding %q", encoding)
+	}
+	return []byte(s + "\n"), nil
+}
+
+// readKey reads a hex or base64 encoded SIV key from the file at path,
+// warning if the file is readable by anyone.
+func readKey(path string) ([]byte, error) {
+	if fi, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && fi.Mode().Perm()&0004 != 0 {
+		fmt.Fprintf(warnings, "warning: key file %s is world-readable\n", path)
+	}
+
+	key, err := siv.ReadKey("file:" + path)
+	if err != nil {
+		return nil, err
+	}
+	return checkKeySize(path, key)
+}
+
+func checkKeySize(name string, key []byte) ([]byte, error) {
+	switch len(key) {
+	case 32, 48, 64:
+		return key, nil
+	}
+	wipe(key)
+	return nil, fmt.Errorf("%s: %v", name, errKeySize)
+}
+
+// warnings receives warnings which don't stop a command, such as about key
+// file permissions.
+var warnings io.Writer = os.Stderr
+
+// keyFlag is a pair of flags giving a key: -name, the path of a key file, or
+// -name-from, a key source as accepted by readKeyFrom. Exactly one must be
+// given.
+type keyFlag struct {
+	name       string
+	path, from string
+}
+
+// addKeyFlag registers the flags for a key on fs, with usage describing the
+// key, such as "key to seal with".
+func addKeyFlag(fs *flag.FlagSet, name, usage string) *keyFlag {
+	k := &keyFlag{name: name}
+	fs.StringVar(&k.path, name, "", "file containing the "+usage)
+	fs.StringVar(&k.from, name+"-from", "", "source of the "+usage+": env:VAR, file:path, stdin, or provider:name")
+	return k
+}
+
+// load reads the key from whichever flag was given.
+func (k *keyFlag) load(stdin io.Reader) ([]byte, error) {
+	switch {
+	case k.path != "" && k.from != "":
+		return nil, fmt.Errorf("-%s and -%s-from are mutually exclusive", k.name, k.name)
+	case k.path != "":
+		return readKey(k.path)
+	case k.from != "":
+		return readKeyFrom(k.from, stdin)
+	}
+	return nil, fmt.Errorf("-%s or -%s-from is required", k.name, k.name)
+}
+
+// fromStdin reports whether the key is to be read from standard input.
+func (k *keyFlag) fromStdin() bool {
+	return k.from == "stdin"
+}
+
+// checkStdin returns an error if more than one of the given key flags, and
+// the command's input if inputFromStdin is set, would read standard input.
+func checkStdin(inputFromStdin bool, keys ...*keyFlag) error {
+	for _, k := range keys {
+		if !k.fromStdin() {
+			continue
+		}
+		if inputFromStdin {
+			return fmt.Errorf("-%s-from stdin can't be used when the input is read from standard input", k.name)
+		}
+		inputFromStdin = true
+	}
+	return nil
+}
+
+// readKeyFrom reads a SIV key from a source, which is "stdin" for the hex or
+// base64 key read from standard input, or any source accepted by siv.ReadKey:
+//
+//	env:VAR        the hex or base64 key in the environment variable VAR
+//	file:path      the hex or base64 key in the file at path
+//	provider:name  the raw key from the KeyProvider registered as name
+//
+// Builds which link in a key management system make its providers available
+// by registering them with siv.RegisterKeyProvider from an init function.
+func readKeyFrom(source string, stdin io.Reader) ([]byte, error) {
+	kind, arg, _ := strings.Cut(source, ":")
+	switch {
+	case source == "stdin":
+		b, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, err
+		}
+		defer wipe(b)
+
+		key, err := siv.DecodeKey(b)
+		if err != nil {
+			return nil, fmt.Errorf("standard input: %w", err)
+		}
+		return checkKeySize("standard input", key)
+
+	case kind == "file" && arg != "":
+		return readKey(arg)
+
+	case (kind == "env" || kind == "provider") && arg != "":
+		key, err := siv.ReadKey(source)
+		if err != nil {
+			return nil, err
+		}
+		return checkKeySize(source, key)
+	}
+	return nil, fmt.Errorf("invalid key source %q: must be env:VAR, file:path, stdin, or provider:name", source)
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// writeSecret writes data to a new file at path, readable only by its owner.
+// It refuses to overwrite an existing file.
+func writeSecret(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// readInput reads all of the file at path, or r if path is "-".
+func readInput(path string, r io.Reader) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(r)
+	}
+	return os.ReadFile(path)
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func init() {
+	siv.RegisterKeyProvider("test", siv.NewInsecureKeyProvider(bytes.Repeat([]byte{7}, 32)))
+	siv.RegisterKeyProvider("short", siv.NewInsecureKeyProvider(make([]byte, 16)))
+}
+
+func TestReadKeyFrom(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	hexKey := hex.EncodeToString(key)
+
+	path := filepath.Join(t.TempDir(), "key")
+	_ = os.WriteFile(path, []byte(hexKey+"\n"), 0600)
+	t.Setenv("SIV_TEST_KEY", base64.StdEncoding.EncodeToString(key))
+
+	for _, tt := range []struct {
+		source string
+		stdin  string
+	}{
+		{"env:SIV_TEST_KEY", ""},
+		{"file:" + path, ""},
+		{"stdin", " " + hexKey + "\n"},
+		{"provider:test", ""},
+	} {
+		actual, err := readKeyFrom(tt.source, strings.NewReader(tt.stdin))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.source, err)
+		}
+		if !bytes.Equal(actual, key) {
+			t.Errorf("%s: Key was %x, but expected %x", tt.source, actual, key)
+		}
+	}
+}
+
+func TestReadKeyFromErrors(t *testing.T) {
+	t.Setenv("SIV_TEST_EMPTY", "")
+	t.Setenv("SIV_TEST_SHORT", hex.EncodeToString(make([]byte, 16)))
+	t.Setenv("SIV_TEST_BAD", "not a key")
+	t.Setenv("SIV_TEST_UNPADDED", base64.RawStdEncoding.EncodeToString(make([]byte, 32)))
+
+	for source, expected := range map[string]string{
+		"env:SIV_TEST_UNSET":    "siv: environment variable SIV_TEST_UNSET is not set",
+		"env:SIV_TEST_EMPTY":    "siv: environment variable SIV_TEST_EMPTY is not set",
+		"env:SIV_TEST_SHORT":    "env:SIV_TEST_SHORT: " + errKeySize.Error(),
+		"env:SIV_TEST_BAD":      "siv: key in environment variable SIV_TEST_BAD is not valid hex or base64",
+		"env:SIV_TEST_UNPADDED": "siv: key in environment variable SIV_TEST_UNPADDED is not valid hex or base64",
+		"stdin":                 "standard input: " + errKeySize.Error(),
+		"provider:missing":      `siv: unknown key provider "missing"`,
+		"provider:short":        "provider:short: " + errKeySize.Error(),
+		"env:":                  `invalid key source "env:": must be env:VAR, file:path, stdin, or provider:name`,
+		"/etc/key":              `invalid key source "/etc/key": must be env:VAR, file:path, stdin, or provider:name`,
+	} {
+		_, err := readKeyFrom(source, strings.NewReader(""))
+		if err == nil || err.Error() != expected {
+			t.Errorf("%s: error was %v, but expected %s", source, err, expected)
+		}
+	}
+}
+
+func TestKeyFlag(t *testing.T) {
+	path := csvKey(t)
+	expected, _ := readKey(path)
+
+	load := func(args ...string) ([]byte, error) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		k := addKeyFlag(fs, "key", "key")
+		if err := fs.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return k.load(nil)
+	}
+
+	for _, args := range [][]string{{"-key", path}, {"-key-from", "file:" + path}} {
+		if key, err := load(args...); err != nil || !bytes.Equal(key, expected) {
+			t.Errorf("%v: Key was %x (%v), but expected %x", args, key, err, expected)
+		}
+	}
+
+	if _, err := load("-key", path, "-key-from", "file:"+path); err == nil || err.Error() != "-key and -key-from are mutually exclusive" {
+		t.Errorf("Error was %v, but expected the flags to be mutually exclusive", err)
+	}
+	if _, err := load(); err == nil || err.Error() != "-key or -key-from is required" {
+		t.Errorf("Error was %v, but expected a key to be required", err)
+	}
+}
+
+func TestCheckStdin(t *testing.T) {
+	file := &keyFlag{name: "key", from: "file:key"}
+	stdin := &keyFlag{name: "key", from: "stdin"}
+	newStdin := &keyFlag{name: "new-key", from: "stdin"}
+
+	if err := checkStdin(true, file); err != nil {
+		t.Error(err)
+	}
+	if err := checkStdin(false, stdin); err != nil {
+		t.Error(err)
+	}
+	if err := checkStdin(true, stdin); err == nil {
+		t.Error("No error for key and input both on standard input")
+	}
+	if err := checkStdin(false, stdin, newStdin); err == nil || !strings.HasPrefix(err.Error(), "-new-key-from stdin") {
+		t.Errorf("Error was %v, but expected -new-key-from to be rejected", err)
+	}
+}
+
+func TestKeyFilePermissions(t *testing.T) {
+	var buf bytes.Buffer
+	defer func(w io.Writer) { warnings = w }(warnings)
+	warnings = &buf
+
+	dir := t.TempDir()
+	private, public := filepath.Join(dir, "private"), filepath.Join(dir, "public")
+	key := []byte(hex.EncodeToString(make([]byte, 32)))
+	_ = os.WriteFile(private, key, 0600)
+	_ = os.WriteFile(public, key, 0644)
+	_ = os.Chmod(public, 0644)
+
+	if _, err := readKeyFrom("file:"+private, nil); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Warning for a private key file: %q", buf.String())
+	}
+
+	if _, err := readKeyFrom("file:"+public, nil); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "warning: key file " + public + " is world-readable\n"; buf.String() != expected {
+		t.Errorf("Warning was %q, but expected %q", buf.String(), expected)
+	}
+}
+
+func TestKeyFromCommands(t *testing.T) {
+	path := csvKey(t)
+	b, _ := os.ReadFile(path)
+	t.Setenv("SIV_TEST_KEY", string(b))
+
+	var sealed bytes.Buffer
+	if err := sealCommand([]string{"-key-from", "env:SIV_TEST_KEY"}, strings.NewReader("hello"), &sealed); err != nil {
+		t.Fatal(err)
+	}
+
+	in := filepath.Join(t.TempDir(), "sealed")
+	_ = os.WriteFile(in, sealed.Bytes(), 0600)
+
+	var opened bytes.Buffer
+	if err := openCommand([]string{"-key-from", "stdin", "-in", in}, bytes.NewReader(b), &opened); err != nil {
+		t.Fatal(err)
+	}
+	if opened.String() != "hello" {
+		t.Errorf("Plaintext was %q, but expected %q", opened.String(), "hello")
+	}
+
+	if err := openCommand([]string{"-key-from", "stdin"}, bytes.NewReader(b), io.Discard); err == nil {
+		t.Error("No error for key and input both on standard input")
+	}
+
+	var fp bytes.Buffer
+	if err := fingerprintCommand([]string{"-key-from", "env:SIV_TEST_KEY"}, nil, &fp); err != nil {
+		t.Fatal(err)
+	}
+	key, _ := (&keyFlag{name: "key", path: path}).load(nil)
+	if expected, _ := fingerprintKey(key); strings.TrimSpace(fp.String()) != expected {
+		t.Errorf("Fingerprint was %s, but expected %s", fp.String(), expected)
+	}
+
+	if err := fingerprintCommand(nil, nil, io.Discard); err == nil {
+		t.Error("No error without a key")
+	}
+}
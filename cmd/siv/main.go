@@ -0,0 +1,153 @@
+// Command siv manages SIV keys and data keys wrapped with them.
+//
+// Usage:
+//
+//	siv keygen [-size bits] [-encoding hex|base64] -out path
+//	siv fingerprint -key path
+//	siv rewrap -old-key path -new-key path [-ad data] [-in path] [-encoding hex|base64]
+//	siv csv -key path -columns col,... [-ad-column col] [-decrypt] [-no-header] [-in path]
+//	siv scan -in path [-format dir|ndjson] [-field name] [-max-entries n] [-spill-dir dir] [-examples n] [-json]
+//	siv seal -key path [-chunk-size n] [-in path]
+//	siv open -key path [-in path]
+//	siv verify -key path [-ad data] -in path [-encoding raw|hex|base64] [-expect-file path | -expect-sha256 hex] [-quiet]
+//	siv encrypt -passphrase path [-in path]
+//	siv decrypt -passphrase path [-in path]
+//
+// The csv subcommand encrypts or decrypts the given columns of a CSV file,
+// named by header or 1-based index, streaming the result to standard output.
+// Each cell is bound to its column's header, or its index with -no-header, so
+// cells can't be moved between columns; to bind them to their rows as well,
+// give a column which identifies each row with -ad-column.
+//
+// The scan subcommand reports groups of identical ciphertexts, which reveal
+// repeated plaintexts, by indexing base64 ciphertexts by their tags. It needs
+// no key.
+//
+// The seal and open subcommands encrypt and decrypt a stream of any length in
+// the chunked format of package stream, reading standard input and writing
+// standard output without temporary files, so that they can sit in the middle
+// of a pipeline. Open writes each chunk only once it has been verified, and
+// fails at the first chunk which doesn't; its output is then incomplete and
+// should be discarded. Both report progress to standard error when it is a
+// terminal.
+//
+// The verify subcommand checks that a ciphertext opens under a key and,
+// optionally, that its plaintext matches an expected plaintext or SHA-256
+// hash, without printing the plaintext. It exits with status 0 if it does, 1
+// if the ciphertext fails to authenticate, 2 if the plaintext doesn't match,
+// and 3 for any other error.
+//
+// The encrypt and decrypt subcommands protect a whole file with a passphrase,
+// read from the first line of a file, in the format of package sivfile. The
+// result is streamed to standard output; if decrypt fails, the output is
+// incomplete and should be discarded.
+//
+// Keys are read from and written to files containing their hex or base64
+// encoding. Key material is never written to standard output unless keygen is
+// explicitly asked to with -out -.
+//
+// Wherever a key file is given with -key (or -old-key or -new-key), the key
+// may instead be given with -key-from (or -old-key-from or -new-key-from) and
+// one of these sources:
+//
+//	env:VAR        the environment variable VAR
+//	file:path      the file at path, as with -key
+//	stdin          standard input, if the command's input isn't also read from it
+//	provider:name  a KeyProvider compiled into the command and registered
+//	               under name with siv.RegisterKeyProvider
+//
+// Giving both flags for the same key is an error. Keys in files, variables, and
+// standard input are hex, or else padded base64, with surrounding whitespace
+// ignored; key providers supply raw keys. A warning is printed if a key file
+// is readable by anyone. Keys can't be given on the command line itself, where
+// they would appear in shell history and process listings.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command runs a subcommand with the given arguments.
+type command func(args []string, stdin io.Reader, stdout io.Writer) error
+
+var commands = map[string]command{
+	"keygen":      keygenCommand,
+	"fingerprint": fingerprintCommand,
+	"rewrap":      rewrapCommand,
+	"csv":         csvCommand,
+	"scan":        scanCommand,
+	"verify":      verifyCommand,
+	"seal":        sealCommand,
+	"open":        openCommand,
+	"encrypt":     encryptCommand,
+	"decrypt":     decryptCommand,
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		usage()
+	}
+
+	if err := cmd(os.Args[2:], os.Stdin, os.Stdout); err != nil {
+		var exit *exitError
+		switch {
+		case errors.As(err, &exit):
+		case errors.Is(err, flag.ErrHelp):
+			exit = &exitError{code: 2}
+		default:
+			exit = &exitError{code: 1, err: err}
+		}
+		if exit.err != nil {
+			fmt.Fprintf(os.Stderr, "siv %s: %v\n", os.Args[1], exit.err)
+		}
+		os.Exit(exit.code)
+	}
+}
+
+// exitError is returned by a command to exit with a particular status. If err
+// is nil, nothing is printed.
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit status %d", e.code)
+	}
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
+
+func usage() {
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "usage: siv <%s> [flags]\n", strings.Join(names, "|"))
+	os.Exit(2)
+}
+
+// newFlagSet returns a flag set for the named subcommand which reports errors
+// rather than exiting.
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("siv "+name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
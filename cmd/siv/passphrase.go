@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/stripe/siv-go/sivfile"
+)
+
+func encryptCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	return passphraseCommand("encrypt", args, stdin, stdout)
+}
+
+func decryptCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	return passphraseCommand("decrypt", args, stdin, stdout)
+}
+
+// passphraseCommand encrypts or decrypts a file in the sivfile format,
+// streaming the result to standard output.
+func passphraseCommand(name string, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet(name)
+	passphraseFile := fs.String("passphrase", "", "file whose first line is the passphrase")
+	in := fs.String("in", "-", "file to "+name+", or - for standard input")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *passphraseFile == "" {
+		return errors.New("-passphrase is required")
+	}
+
+	passphrase, err := readPassphrase(*passphraseFile)
+	if err != nil {
+		return err
+	}
+
+	r := stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+
+	if name == "encrypt" {
+		return sivfile.Encrypt(stdout, r, passphrase)
+	}
+	return sivfile.Decrypt(stdout, r, passphrase)
+}
+
+// readPassphrase reads the first line of the file at path, without its line
+// ending. The passphrase is read from a file rather than a flag so that it
+// doesn't appear in shell history or process listings.
+func readPassphrase(path string) ([]byte, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		b = b[:i]
+	}
+	b = bytes.TrimSuffix(b, []byte("\r"))
+	if len(b) == 0 {
+		return nil, errors.New(path + ": empty passphrase")
+	}
+	return b, nil
+}
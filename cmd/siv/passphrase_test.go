@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func TestPassphraseCommands(t *testing.T) {
+	dir := t.TempDir()
+	passphrasePath := filepath.Join(dir, "passphrase")
+	wrongPath := filepath.Join(dir, "wrong")
+	_ = os.WriteFile(passphrasePath, []byte("correct horse battery staple\r\n"), 0600)
+	_ = os.WriteFile(wrongPath, []byte("wrong\n"), 0600)
+
+	plaintext := bytes.Repeat([]byte("tar archive "), 10000)
+
+	var encrypted bytes.Buffer
+	if err := encryptCommand([]string{"-passphrase", passphrasePath}, bytes.NewReader(plaintext), &encrypted); err != nil {
+		t.Fatal(err)
+	}
+
+	inPath := filepath.Join(dir, "in.sivf")
+	_ = os.WriteFile(inPath, encrypted.Bytes(), 0600)
+
+	var decrypted bytes.Buffer
+	if err := decryptCommand([]string{"-passphrase", passphrasePath, "-in", inPath}, nil, &decrypted); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted.Bytes(), plaintext) {
+		t.Errorf("Plaintext was %d bytes, but expected %d", decrypted.Len(), len(plaintext))
+	}
+
+	var out bytes.Buffer
+	if err := decryptCommand([]string{"-passphrase", wrongPath}, bytes.NewReader(encrypted.Bytes()), &out); !errors.Is(err, siv.ErrAuthentication) {
+		t.Errorf("Error was %v, but expected %v", err, siv.ErrAuthentication)
+	}
+	if out.Len() != 0 {
+		t.Fatalf("Plaintext returned instead of error: %x", out.Bytes())
+	}
+
+	if err := encryptCommand(nil, nil, &out); err == nil {
+		t.Error("No error without -passphrase")
+	}
+}
+
+func TestReadPassphrase(t *testing.T) {
+	dir := t.TempDir()
+
+	for contents, expected := range map[string]string{
+		"secret":            "secret",
+		"secret\n":          "secret",
+		"secret\r\n":        "secret",
+		"  two words \nxyz": "  two words ",
+		"":                  "",
+		"\n":                "",
+	} {
+		path := filepath.Join(dir, "passphrase")
+		_ = os.WriteFile(path, []byte(contents), 0600)
+
+		actual, err := readPassphrase(path)
+		if expected == "" {
+			if err == nil {
+				t.Errorf("No error for empty passphrase %q", contents)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(actual) != expected {
+			t.Errorf("Passphrase was %q, but expected %q", actual, expected)
+		}
+	}
+}
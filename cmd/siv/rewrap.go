@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/aes"
+	"io"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func rewrapCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("rewrap")
+	oldKeyFlag := addKeyFlag(fs, "old-key", "key the data key is wrapped under")
+	newKeyFlag := addKeyFlag(fs, "new-key", "key to wrap the data key under")
+	ad := fs.String("ad", "", "associated data the data key is wrapped with")
+	in := fs.String("in", "-", "file containing the hex or base64 wrapped data key, or - for standard input")
+	encoding := fs.String("encoding", "base64", "output encoding: hex or base64")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := checkStdin(*in == "-", oldKeyFlag, newKeyFlag); err != nil {
+		return err
+	}
+
+	oldKey, err := oldKeyFlag.load(stdin)
+	if err != nil {
+		return err
+	}
+	defer wipe(oldKey)
+
+	newKey, err := newKeyFlag.load(stdin)
+	if err != nil {
+		return err
+	}
+	defer wipe(newKey)
+
+	b, err := readInput(*in, stdin)
+	if err != nil {
+		return err
+	}
+
+	wrapped, err := siv.DecodeKey(b)
+	if err != nil {
+		return err
+	}
+
+	var data []byte
+	if *ad != "" {
+		data = []byte(*ad)
+	}
+
+	rewrapped, err := rewrap(oldKey, newKey, wrapped, data)
+	if err != nil {
+		return err
+	}
+
+	// The wrapped data key is a ciphertext, so is safe to print.
+	b, err = encode(rewrapped, *encoding)
+	if err != nil {
+		return err
+	}
+	_, err = stdout.Write(b)
+	return err
+}
+
+// rewrap unwraps a data key wrapped (i.e. sealed with SIV, per RFC 5297
+// section 1.3.2) under oldKey with the given associated data, and wraps it
+// under newKey. The data key itself is never returned.
+func rewrap(oldKey, newKey, wrapped, ad []byte) ([]byte, error) {
+	oldAEAD, err := siv.New(oldKey, aes.NewCipher)
+	if err != nil {
+		return nil, err
+	}
+
+	newAEAD, err := siv.New(newKey, aes.NewCipher)
+	if err != nil {
+		return nil, err
+	}
+
+	return siv.ReEncrypt(oldAEAD, newAEAD, wrapped, ad)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func TestRewrap(t *testing.T) {
+	oldKey := []byte("0123456789abcdef0123456789ABCDEF")
+	newKey := []byte("0123456789abcdefghijklmnopqrstuv0123456789ABCDEFGHIJKLMNOPQRSTUV")
+	dek := bytes.Repeat([]byte{3}, 32)
+
+	oldAEAD, _ := siv.New(oldKey, aes.NewCipher)
+	newAEAD, _ := siv.New(newKey, aes.NewCipher)
+	wrapped := oldAEAD.Seal(nil, nil, dek, []byte("ad"))
+
+	rewrapped, err := rewrap(oldKey, newKey, wrapped, []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := newAEAD.Open(nil, nil, rewrapped, []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(actual, dek) {
+		t.Errorf("Data key was %x, but expected %x", actual, dek)
+	}
+
+	if _, err := rewrap(newKey, oldKey, wrapped, []byte("ad")); err != siv.ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, siv.ErrAuthentication)
+	}
+}
+
+func TestRewrapCommand(t *testing.T) {
+	dir := t.TempDir()
+	oldPath, newPath := filepath.Join(dir, "old"), filepath.Join(dir, "new")
+
+	var stdout bytes.Buffer
+	for _, path := range []string{oldPath, newPath} {
+		if err := keygenCommand([]string{"-out", path}, nil, &stdout); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	oldKey, _ := readKey(oldPath)
+	newKey, _ := readKey(newPath)
+	oldAEAD, _ := siv.New(oldKey, aes.NewCipher)
+	newAEAD, _ := siv.New(newKey, aes.NewCipher)
+
+	dek := bytes.Repeat([]byte{3}, 32)
+	b, _ := encode(oldAEAD.Seal(nil, nil, dek, nil), "hex")
+
+	args := []string{"-old-key", oldPath, "-new-key", newPath}
+	if err := rewrapCommand(args, bytes.NewReader(b), &stdout); err != nil {
+		t.Fatal(err)
+	}
+
+	rewrapped, err := siv.DecodeKey(stdout.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := newAEAD.Open(nil, nil, rewrapped, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(actual, dek) {
+		t.Errorf("Data key was %x, but expected %x", actual, dek)
+	}
+
+	for _, key := range [][]byte{oldKey, newKey, dek} {
+		if out, _ := encode(key, "base64"); strings.Contains(stdout.String(), strings.TrimSpace(string(out))) {
+			t.Errorf("Key material was written to stdout")
+		}
+	}
+}
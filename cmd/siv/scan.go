@@ -0,0 +1,374 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// tagSize is the size of a SIV tag, which identifies a ciphertext: equal
+// plaintexts sealed under the same key with the same associated data have
+// equal tags.
+const tagSize = 16
+
+func scanCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("scan")
+	in := fs.String("in", "", "directory of files each holding a base64 ciphertext, or NDJSON file (- for standard input)")
+	format := fs.String("format", "dir", "input format: dir or ndjson")
+	field := fs.String("field", "", "the field holding the ciphertext, for ndjson")
+	maxEntries := fs.Int("max-entries", 1<<20, "distinct tags to hold in memory before spilling")
+	spillDir := fs.String("spill-dir", "", "directory for spill files; without it, exceeding -max-entries is an error")
+	examples := fs.Int("examples", 3, "example locations to report per duplicate group")
+	asJSON := fs.Bool("json", false, "write the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *in == "" {
+		return errors.New("-in is required")
+	}
+	if *format == "ndjson" && *field == "" {
+		return errors.New("-field is required for ndjson")
+	}
+	if *maxEntries < 1 || *examples < 0 {
+		return errors.New("-max-entries must be positive and -examples non-negative")
+	}
+
+	s := &scanner{
+		maxEntries: *maxEntries,
+		examples:   *examples,
+		spillDir:   *spillDir,
+		mem:        map[[tagSize]byte]*scanGroup{},
+	}
+	defer s.cleanup()
+
+	var err error
+	switch *format {
+	case "dir":
+		err = s.scanDir(*in)
+	case "ndjson":
+		err = s.scanNDJSON(*in, stdin, *field)
+	default:
+		err = fmt.Errorf("unknown format %q", *format)
+	}
+	if err != nil {
+		return err
+	}
+
+	r, err := s.report()
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		e := json.NewEncoder(stdout)
+		e.SetIndent("", "  ")
+		return e.Encode(r)
+	}
+	return r.writeTable(stdout)
+}
+
+// scanGroup is the set of ciphertexts sharing a tag.
+type scanGroup struct {
+	tag      [tagSize]byte
+	count    uint64
+	examples []string
+}
+
+// scanner indexes ciphertexts by tag. Once it holds maxEntries distinct tags,
+// it writes them in sorted order to a spill file and starts afresh; the spill
+// files and the final in-memory index are merged to produce the report.
+type scanner struct {
+	maxEntries, examples int
+	spillDir             string
+
+	mem            map[[tagSize]byte]*scanGroup
+	spills         []string
+	total, invalid uint64
+}
+
+func (s *scanner) add(encoded []byte, location string) error {
+	ciphertext := make([]byte, base64.StdEncoding.DecodedLen(len(encoded)))
+	n, err := base64.StdEncoding.Decode(ciphertext, bytes.TrimSpace(encoded))
+	if err != nil || n < tagSize {
+		s.invalid++
+		return nil
+	}
+	s.total++
+
+	tag := [tagSize]byte(ciphertext)
+	g := s.mem[tag]
+	if g == nil {
+		if len(s.mem) >= s.maxEntries {
+			if err := s.spill(); err != nil {
+				return err
+			}
+		}
+		g = &scanGroup{tag: tag}
+		s.mem[tag] = g
+	}
+
+	g.count++
+	if len(g.examples) < s.examples {
+		g.examples = append(g.examples, location)
+	}
+	return nil
+}
+
+func (s *scanner) scanDir(root string) error {
+	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return s.add(b, path)
+	})
+}
+
+func (s *scanner) scanNDJSON(path string, stdin io.Reader, field string) error {
+	r := stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 64<<20)
+	for n := 1; sc.Scan(); n++ {
+		var v map[string]json.RawMessage
+		var ciphertext string
+		if json.Unmarshal(sc.Bytes(), &v) != nil || json.Unmarshal(v[field], &ciphertext) != nil {
+			s.invalid++
+			continue
+		}
+
+		if err := s.add([]byte(ciphertext), fmt.Sprintf("%s:%d", path, n)); err != nil {
+			return err
+		}
+	}
+	return sc.Err()
+}
+
+// sorted returns the in-memory groups in tag order.
+func (s *scanner) sorted() []*scanGroup {
+	groups := make([]*scanGroup, 0, len(s.mem))
+	for _, g := range s.mem {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return bytes.Compare(groups[i].tag[:], groups[j].tag[:]) < 0
+	})
+	return groups
+}
+
+// spill writes the in-memory groups to a new spill file and clears them.
+func (s *scanner) spill() error {
+	if s.spillDir == "" {
+		return fmt.Errorf("more than %d distinct ciphertexts; use -spill-dir or raise -max-entries", s.maxEntries)
+	}
+
+	f, err := os.CreateTemp(s.spillDir, "siv-scan-*")
+	if err != nil {
+		return err
+	}
+	s.spills = append(s.spills, f.Name())
+
+	w := bufio.NewWriter(f)
+	for _, g := range s.sorted() {
+		b := append([]byte{}, g.tag[:]...)
+		b = binary.AppendUvarint(b, g.count)
+		b = binary.AppendUvarint(b, uint64(len(g.examples)))
+		for _, e := range g.examples {
+			b = binary.AppendUvarint(b, uint64(len(e)))
+			b = append(b, e...)
+		}
+		if _, err := w.Write(b); err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	s.mem = map[[tagSize]byte]*scanGroup{}
+	return f.Close()
+}
+
+func (s *scanner) cleanup() {
+	for _, name := range s.spills {
+		_ = os.Remove(name)
+	}
+}
+
+// groupSource yields groups in tag order, returning nil at the end.
+type groupSource func() (*scanGroup, error)
+
+func memorySource(groups []*scanGroup) groupSource {
+	return func() (*scanGroup, error) {
+		if len(groups) == 0 {
+			return nil, nil
+		}
+		g := groups[0]
+		groups = groups[1:]
+		return g, nil
+	}
+}
+
+func spillSource(r *bufio.Reader) groupSource {
+	return func() (*scanGroup, error) {
+		g := &scanGroup{}
+		if _, err := io.ReadFull(r, g.tag[:]); err == io.EOF {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		var err error
+		if g.count, err = binary.ReadUvarint(r); err != nil {
+			return nil, err
+		}
+
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := uint64(0); i < n; i++ {
+			l, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, err
+			}
+
+			e := make([]byte, l)
+			if _, err := io.ReadFull(r, e); err != nil {
+				return nil, err
+			}
+			g.examples = append(g.examples, string(e))
+		}
+		return g, nil
+	}
+}
+
+// scanReport is the result of a scan.
+type scanReport struct {
+	Total      uint64            `json:"total"`
+	Invalid    uint64            `json:"invalid"`
+	Distinct   uint64            `json:"distinct"`
+	Duplicates uint64            `json:"duplicates"`
+	Groups     []scanReportGroup `json:"groups"`
+}
+
+type scanReportGroup struct {
+	Tag      string   `json:"tag"`
+	Count    uint64   `json:"count"`
+	Examples []string `json:"examples"`
+}
+
+// report merges the spill files and in-memory groups, returning the groups
+// with more than one ciphertext, largest first.
+func (s *scanner) report() (*scanReport, error) {
+	// Spilled groups were seen first, so take their examples first.
+	var sources []groupSource
+	for _, name := range s.spills {
+		f, err := os.Open(name)
+		if err != nil {
+			return nil, err
+		}
+		defer func() { _ = f.Close() }()
+		sources = append(sources, spillSource(bufio.NewReader(f)))
+	}
+	sources = append(sources, memorySource(s.sorted()))
+
+	heads := make([]*scanGroup, len(sources))
+	for i, next := range sources {
+		var err error
+		if heads[i], err = next(); err != nil {
+			return nil, err
+		}
+	}
+
+	r := &scanReport{Total: s.total, Invalid: s.invalid, Groups: []scanReportGroup{}}
+	for {
+		var min *scanGroup
+		for _, h := range heads {
+			if h != nil && (min == nil || bytes.Compare(h.tag[:], min.tag[:]) < 0) {
+				min = h
+			}
+		}
+		if min == nil {
+			break
+		}
+
+		merged := &scanGroup{tag: min.tag}
+		for i, h := range heads {
+			if h == nil || h.tag != merged.tag {
+				continue
+			}
+
+			merged.count += h.count
+			for _, e := range h.examples {
+				if len(merged.examples) < s.examples {
+					merged.examples = append(merged.examples, e)
+				}
+			}
+
+			var err error
+			if heads[i], err = sources[i](); err != nil {
+				return nil, err
+			}
+		}
+
+		r.Distinct++
+		if merged.count > 1 {
+			r.Duplicates += merged.count
+			r.Groups = append(r.Groups, scanReportGroup{
+				Tag:      hex.EncodeToString(merged.tag[:]),
+				Count:    merged.count,
+				Examples: append([]string{}, merged.examples...),
+			})
+		}
+	}
+
+	sort.SliceStable(r.Groups, func(i, j int) bool {
+		return r.Groups[i].Count > r.Groups[j].Count
+	})
+	return r, nil
+}
+
+func (r *scanReport) writeTable(w io.Writer) error {
+	fmt.Fprintf(w, "%d ciphertexts (%d invalid skipped), %d distinct, %d in %d duplicate groups\n",
+		r.Total, r.Invalid, r.Distinct, r.Duplicates, len(r.Groups))
+	if len(r.Groups) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "COUNT\tTAG\tEXAMPLES")
+	for _, g := range r.Groups {
+		fmt.Fprintf(tw, "%d\t%s\t%s\n", g.Count, g.Tag, strings.Join(g.Examples, ", "))
+	}
+	return tw.Flush()
+}
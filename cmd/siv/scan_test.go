@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+// scanCorpus writes a synthetic corpus of ciphertexts to dir, with "dup-a"
+// sealed 5 times, "dup-b" 3 times, and 20 unique plaintexts, plus one file
+// which isn't a ciphertext.
+func scanCorpus(t *testing.T, dir string) []string {
+	aead, _ := siv.New(bytes.Repeat([]byte{1}, 32), aes.NewCipher, siv.AllowEqualHalves())
+
+	var plaintexts []string
+	for i := 0; i < 5; i++ {
+		plaintexts = append(plaintexts, "dup-a")
+	}
+	for i := 0; i < 3; i++ {
+		plaintexts = append(plaintexts, "dup-b")
+	}
+	for i := 0; i < 20; i++ {
+		plaintexts = append(plaintexts, fmt.Sprintf("unique-%d", i))
+	}
+
+	var encoded []string
+	for i, p := range plaintexts {
+		e := base64.StdEncoding.EncodeToString(aead.Seal(nil, nil, []byte(p), nil))
+		encoded = append(encoded, e)
+
+		path := filepath.Join(dir, fmt.Sprintf("%02d", i/10), fmt.Sprintf("%02d", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(e+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "junk"), []byte("not base64!"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
+
+func runScan(t *testing.T, args ...string) *scanReport {
+	var stdout bytes.Buffer
+	if err := scanCommand(append(args, "-json"), nil, &stdout); err != nil {
+		t.Fatal(err)
+	}
+
+	var r scanReport
+	if err := json.Unmarshal(stdout.Bytes(), &r); err != nil {
+		t.Fatal(err)
+	}
+	return &r
+}
+
+func checkScanReport(t *testing.T, r *scanReport) {
+	if r.Total != 28 || r.Invalid != 1 || r.Distinct != 22 || r.Duplicates != 8 {
+		t.Errorf("Report was %+v", r)
+	}
+
+	if len(r.Groups) != 2 {
+		t.Fatalf("Report had %d groups, but expected %d", len(r.Groups), 2)
+	}
+
+	for i, expected := range []uint64{5, 3} {
+		if r.Groups[i].Count != expected {
+			t.Errorf("Group %d had %d ciphertexts, but expected %d", i, r.Groups[i].Count, expected)
+		}
+
+		if len(r.Groups[i].Examples) != 2 {
+			t.Errorf("Group %d had examples %v, but expected 2", i, r.Groups[i].Examples)
+		}
+	}
+}
+
+func TestScanDir(t *testing.T) {
+	dir := t.TempDir()
+	scanCorpus(t, dir)
+
+	r := runScan(t, "-in", dir, "-examples", "2")
+	checkScanReport(t, r)
+
+	// The dup-a files are the first five.
+	for _, e := range r.Groups[0].Examples {
+		if !strings.HasPrefix(e, filepath.Join(dir, "00", "0")) {
+			t.Errorf("Example %s isn't a dup-a file", e)
+		}
+	}
+}
+
+func TestScanSpill(t *testing.T) {
+	dir := t.TempDir()
+	scanCorpus(t, dir)
+
+	spill := t.TempDir()
+	r := runScan(t, "-in", dir, "-examples", "2", "-max-entries", "3", "-spill-dir", spill)
+	checkScanReport(t, r)
+
+	// Spill files are removed afterwards.
+	if entries, _ := os.ReadDir(spill); len(entries) != 0 {
+		t.Errorf("%d spill files were left behind", len(entries))
+	}
+
+	var stdout bytes.Buffer
+	if err := scanCommand([]string{"-in", dir, "-max-entries", "3"}, nil, &stdout); err == nil {
+		t.Error("Expected an error for exceeding -max-entries without -spill-dir")
+	}
+}
+
+func TestScanNDJSON(t *testing.T) {
+	encoded := scanCorpus(t, t.TempDir())
+
+	var b strings.Builder
+	for i, e := range encoded {
+		fmt.Fprintf(&b, `{"id":%d,"ct":%q}`+"\n", i, e)
+	}
+	b.WriteString(`{"id":"missing"}` + "\n")
+
+	var stdout bytes.Buffer
+	if err := scanCommand([]string{"-in", "-", "-format", "ndjson", "-field", "ct", "-examples", "2", "-json"}, strings.NewReader(b.String()), &stdout); err != nil {
+		t.Fatal(err)
+	}
+
+	var r scanReport
+	if err := json.Unmarshal(stdout.Bytes(), &r); err != nil {
+		t.Fatal(err)
+	}
+	checkScanReport(t, &r)
+
+	if e := r.Groups[1].Examples; e[0] != "-:6" || e[1] != "-:7" {
+		t.Errorf("Examples were %v, but expected %v", e, []string{"-:6", "-:7"})
+	}
+}
+
+func TestScanTable(t *testing.T) {
+	dir := t.TempDir()
+	scanCorpus(t, dir)
+
+	var stdout bytes.Buffer
+	if err := scanCommand([]string{"-in", dir, "-examples", "1"}, nil, &stdout); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if lines[0] != "28 ciphertexts (1 invalid skipped), 22 distinct, 8 in 2 duplicate groups" {
+		t.Errorf("Summary was %q", lines[0])
+	}
+
+	if len(lines) != 5 || !strings.HasPrefix(lines[2], "COUNT") || !strings.HasPrefix(lines[3], "5  ") {
+		t.Errorf("Table was %q", stdout.String())
+	}
+}
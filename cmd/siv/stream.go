@@ -0,0 +1,155 @@
+package main
+
+import (
+	"crypto/aes"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	siv "github.com/stripe/siv-go"
+	"github.com/stripe/siv-go/stream"
+)
+
+func sealCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	return streamCommand("seal", args, stdin, stdout)
+}
+
+func openCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	return streamCommand("open", args, stdin, stdout)
+}
+
+// streamCommand encrypts or decrypts a stream in the format of package
+// stream, writing the result to standard output as it goes.
+func streamCommand(name string, args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet(name)
+	keyFlag := addKeyFlag(fs, "key", "key to "+name+" with")
+	in := fs.String("in", "-", "file to "+name+", or - for standard input")
+	var chunkSize *int
+	if name == "seal" {
+		chunkSize = fs.Int("chunk-size", stream.DefaultChunkSize, "plaintext bytes per chunk")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if err := checkStdin(*in == "-", keyFlag); err != nil {
+		return err
+	}
+
+	key, err := keyFlag.load(stdin)
+	if err != nil {
+		return err
+	}
+	defer wipe(key)
+
+	aead, err := siv.New(key, aes.NewCipher)
+	if err != nil {
+		return err
+	}
+
+	r := stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+
+	if out := progressOutput(); out != nil {
+		p := newProgress(stdout, out)
+		defer p.done()
+		stdout = p
+	}
+
+	if name == "open" {
+		sr, err := stream.NewReader(r, aead)
+		if err != nil {
+			return err
+		}
+		// Each chunk is written only once verified, so on error stdout has
+		// received only verified chunks, though not all of them.
+		_, err = io.Copy(stdout, sr)
+		return err
+	}
+
+	sw, err := stream.NewWriter(stdout, aead, *chunkSize)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(sw, r); err != nil {
+		return err
+	}
+	return sw.Close()
+}
+
+// progressOutput returns where to report progress, or nil not to. Progress is
+// reported only when standard error is a terminal, so that it doesn't litter
+// logs.
+var progressOutput = func() io.Writer {
+	if fi, err := os.Stderr.Stat(); err == nil && fi.Mode()&os.ModeCharDevice != 0 {
+		return os.Stderr
+	}
+	return nil
+}
+
+// progressInterval is how often progress is reported.
+const progressInterval = 500 * time.Millisecond
+
+// progress counts the bytes written to w, periodically reporting the count
+// and rate to out.
+type progress struct {
+	w, out      io.Writer
+	n           int64
+	start, last time.Time
+	now         func() time.Time
+}
+
+func newProgress(w, out io.Writer) *progress {
+	p := &progress{w: w, out: out, now: time.Now}
+	p.start = p.now()
+	p.last = p.start
+	return p
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	n, err := p.w.Write(b)
+	p.n += int64(n)
+
+	if now := p.now(); now.Sub(p.last) >= progressInterval {
+		p.last = now
+		p.report(now)
+	}
+	return n, err
+}
+
+// done reports the final count and ends the progress line.
+func (p *progress) done() {
+	p.report(p.now())
+	fmt.Fprintln(p.out)
+}
+
+func (p *progress) report(now time.Time) {
+	rate := float64(0)
+	if d := now.Sub(p.start).Seconds(); d > 0 {
+		rate = float64(p.n) / d
+	}
+	fmt.Fprintf(p.out, "\r%s written, %s/s ", formatBytes(float64(p.n)), formatBytes(rate))
+}
+
+// formatBytes formats n bytes with a binary unit prefix.
+func formatBytes(n float64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%.0f B", n)
+	}
+
+	i := -1
+	for n >= 1024 && i < len(units)-1 {
+		n /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %ciB", n, units[i])
+}
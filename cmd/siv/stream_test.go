@@ -0,0 +1,105 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+	"time"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func TestStreamCommands(t *testing.T) {
+	key := csvKey(t)
+	plaintext := make([]byte, 10000)
+	rand.New(rand.NewSource(1)).Read(plaintext)
+
+	var sealed bytes.Buffer
+	if err := sealCommand([]string{"-key", key, "-chunk-size", "1000"}, bytes.NewReader(plaintext), &sealed); err != nil {
+		t.Fatal(err)
+	}
+
+	var opened bytes.Buffer
+	if err := openCommand([]string{"-key", key}, bytes.NewReader(sealed.Bytes()), &opened); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(opened.Bytes(), plaintext) {
+		t.Errorf("Plaintext was %d bytes, but expected %d", opened.Len(), len(plaintext))
+	}
+
+	// Corrupt the fifth chunk: only the first four are written.
+	corrupted := append([]byte{}, sealed.Bytes()...)
+	corrupted[9+4*1016+100] ^= 1
+
+	var partial bytes.Buffer
+	err := openCommand([]string{"-key", key}, bytes.NewReader(corrupted), &partial)
+	if !errors.Is(err, siv.ErrAuthentication) {
+		t.Errorf("Error was %v, but expected %v", err, siv.ErrAuthentication)
+	}
+	if !bytes.Equal(partial.Bytes(), plaintext[:4000]) {
+		t.Errorf("Output was %d bytes, but expected the %d verified bytes", partial.Len(), 4000)
+	}
+
+	if err := openCommand([]string{"-key", csvKey(t)}, bytes.NewReader(sealed.Bytes()), io.Discard); !errors.Is(err, siv.ErrAuthentication) {
+		t.Errorf("Error was %v, but expected %v", err, siv.ErrAuthentication)
+	}
+
+	if err := sealCommand([]string{"-key", key, "-chunk-size", "0"}, bytes.NewReader(nil), io.Discard); err == nil {
+		t.Error("No error for chunk size 0")
+	}
+}
+
+func TestStreamProgress(t *testing.T) {
+	var stderr bytes.Buffer
+	defer func(f func() io.Writer) { progressOutput = f }(progressOutput)
+	progressOutput = func() io.Writer { return &stderr }
+
+	key := csvKey(t)
+	if err := sealCommand([]string{"-key", key}, strings.NewReader("hello"), io.Discard); err != nil {
+		t.Fatal(err)
+	}
+
+	// 9 bytes of header, and 5 bytes of plaintext plus a 16 byte tag.
+	if s := stderr.String(); !strings.HasPrefix(s, "\r30 B written, ") || !strings.HasSuffix(s, "\n") {
+		t.Errorf("Progress was %q", s)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	var out bytes.Buffer
+	start := time.Unix(1700000000, 0)
+	now := start
+
+	p := newProgress(io.Discard, &out)
+	p.start, p.last, p.now = start, start, func() time.Time { return now }
+
+	_, _ = p.Write(make([]byte, 1024))
+	if out.Len() != 0 {
+		t.Errorf("Progress was reported after %v", now.Sub(start))
+	}
+
+	now = now.Add(time.Second)
+	_, _ = p.Write(make([]byte, 1024))
+	if expected := "\r2.0 KiB written, 2.0 KiB/s "; out.String() != expected {
+		t.Errorf("Progress was %q, but expected %q", out.String(), expected)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for n, expected := range map[float64]string{
+		0:                        "0 B",
+		1023:                     "1023 B",
+		1024:                     "1.0 KiB",
+		1536:                     "1.5 KiB",
+		5 << 20:                  "5.0 MiB",
+		3 << 30:                  "3.0 GiB",
+		float64(uint64(1) << 63): "8.0 EiB",
+	} {
+		if actual := formatBytes(n); actual != expected {
+			t.Errorf("%v was formatted as %q, but expected %q", n, actual, expected)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	siv "github.com/stripe/siv-go"
+)
+
+// Exit statuses of the verify subcommand.
+const (
+	verifyOK       = 0
+	verifyAuth     = 1
+	verifyMismatch = 2
+	verifyError    = 3
+)
+
+func verifyCommand(args []string, stdin io.Reader, stdout io.Writer) error {
+	if err := verify(args, stdin, stdout); err != nil {
+		var exit *exitError
+		switch {
+		case errors.As(err, &exit):
+			return err
+		case errors.Is(err, flag.ErrHelp):
+			return &exitError{code: verifyError}
+		}
+		return &exitError{code: verifyError, err: err}
+	}
+	return nil
+}
+
+func verify(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := newFlagSet("verify")
+	keyFlag := addKeyFlag(fs, "key", "key to open with")
+	ad := fs.String("ad", "", "associated data the ciphertext was sealed with")
+	in := fs.String("in", "", "file containing the ciphertext, or - for standard input")
+	encoding := fs.String("encoding", "raw", "ciphertext encoding: raw, hex, or base64")
+	expectFile := fs.String("expect-file", "", "file containing the expected plaintext")
+	expectSHA256 := fs.String("expect-sha256", "", "hex SHA-256 hash of the expected plaintext")
+	quiet := fs.Bool("quiet", false, "print nothing; report the result only by exit status")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *in == "" {
+		return errors.New("-in is required")
+	}
+	if err := checkStdin(*in == "-", keyFlag); err != nil {
+		return err
+	}
+	if *expectFile != "" && *expectSHA256 != "" {
+		return errors.New("-expect-file and -expect-sha256 are mutually exclusive")
+	}
+
+	var expected []byte
+	if *expectSHA256 != "" {
+		h, err := hex.DecodeString(*expectSHA256)
+		if err != nil || len(h) != sha256.Size {
+			return errors.New("-expect-sha256 must be 64 hex digits")
+		}
+		expected = h
+	}
+	if *expectFile != "" {
+		b, err := os.ReadFile(*expectFile)
+		if err != nil {
+			return err
+		}
+		h := sha256.Sum256(b)
+		expected = h[:]
+	}
+
+	key, err := keyFlag.load(stdin)
+	if err != nil {
+		return err
+	}
+	defer wipe(key)
+
+	aead, err := siv.New(key, aes.NewCipher)
+	if err != nil {
+		return err
+	}
+
+	ciphertext, err := readInput(*in, stdin)
+	if err != nil {
+		return err
+	}
+	if ciphertext, err = decodeAs(ciphertext, *encoding); err != nil {
+		return err
+	}
+
+	var data []byte
+	if *ad != "" {
+		data = []byte(*ad)
+	}
+
+	status, message := verifyOK, "verified"
+	plaintext, err := aead.Open(nil, nil, ciphertext, data)
+	if err != nil {
+		status, message = verifyAuth, "authentication failed"
+	} else if expected != nil {
+		actual := sha256.Sum256(plaintext)
+		if subtle.ConstantTimeCompare(actual[:], expected) != 1 {
+			status, message = verifyMismatch, "plaintext mismatch"
+		}
+	}
+	for i := range plaintext {
+		plaintext[i] = 0
+	}
+
+	if !*quiet {
+		if _, err := fmt.Fprintln(stdout, message); err != nil {
+			return err
+		}
+	}
+	if status != verifyOK {
+		return &exitError{code: status}
+	}
+	return nil
+}
+
+// decodeAs decodes b in the given encoding: raw bytes, or hex or base64
+// ignoring surrounding whitespace.
+func decodeAs(b []byte, encoding string) ([]byte, error) {
+	s := strings.TrimSpace(string(b))
+	switch encoding {
+	case "raw":
+		return b, nil
+	case "hex":
+		return hex.DecodeString(s)
+	case "base64":
+		return base64.StdEncoding.DecodeString(s)
+	}
+	return nil, fmt.Errorf("unknown encoding %q", encoding)
+}
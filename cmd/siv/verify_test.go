@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func TestVerifyCommand(t *testing.T) {
+	dir := t.TempDir()
+	key := csvKey(t)
+	k, _ := readKey(key)
+	aead, _ := siv.New(k, aes.NewCipher)
+
+	plaintext := []byte("top secret plaintext")
+	ciphertext := aead.Seal(nil, nil, plaintext, []byte("ad"))
+	sum := sha256.Sum256(plaintext)
+
+	write := func(name string, b []byte) string {
+		path := filepath.Join(dir, name)
+		_ = os.WriteFile(path, b, 0600)
+		return path
+	}
+	blob := write("blob", ciphertext)
+	hexBlob := write("blob.hex", []byte(hex.EncodeToString(ciphertext)+"\n"))
+	b64Blob := write("blob.b64", []byte(base64.StdEncoding.EncodeToString(ciphertext)))
+	expected := write("expected", plaintext)
+	other := write("other", []byte("something else"))
+
+	tests := []struct {
+		args   []string
+		code   int
+		output string
+	}{
+		{[]string{"-ad", "ad", "-in", blob}, 0, "verified\n"},
+		{[]string{"-ad", "ad", "-in", hexBlob, "-encoding", "hex"}, 0, "verified\n"},
+		{[]string{"-ad", "ad", "-in", b64Blob, "-encoding", "base64"}, 0, "verified\n"},
+		{[]string{"-ad", "ad", "-in", blob, "-expect-file", expected}, 0, "verified\n"},
+		{[]string{"-ad", "ad", "-in", blob, "-expect-sha256", hex.EncodeToString(sum[:])}, 0, "verified\n"},
+		{[]string{"-ad", "ad", "-in", blob, "-quiet"}, 0, ""},
+		{[]string{"-ad", "other", "-in", blob}, 1, "authentication failed\n"},
+		{[]string{"-in", blob, "-expect-file", expected}, 1, "authentication failed\n"},
+		{[]string{"-ad", "other", "-in", blob, "-quiet"}, 1, ""},
+		{[]string{"-ad", "ad", "-in", blob, "-expect-file", other}, 2, "plaintext mismatch\n"},
+		{[]string{"-ad", "ad", "-in", blob, "-expect-sha256", strings.Repeat("00", 32)}, 2, "plaintext mismatch\n"},
+		{[]string{"-ad", "ad", "-in", blob, "-expect-file", other, "-quiet"}, 2, ""},
+		{[]string{"-ad", "ad"}, 3, ""},
+		{[]string{"-ad", "ad", "-in", blob, "-expect-sha256", "abcd"}, 3, ""},
+		{[]string{"-ad", "ad", "-in", blob, "-expect-file", expected, "-expect-sha256", hex.EncodeToString(sum[:])}, 3, ""},
+		{[]string{"-ad", "ad", "-in", blob, "-encoding", "hex"}, 3, ""},
+		{[]string{"-ad", "ad", "-in", filepath.Join(dir, "missing")}, 3, ""},
+		{[]string{"-bogus"}, 3, ""},
+	}
+	for _, tt := range tests {
+		var stdout bytes.Buffer
+		err := verifyCommand(append([]string{"-key", key}, tt.args...), nil, &stdout)
+
+		code := 0
+		if err != nil {
+			var exit *exitError
+			if !errors.As(err, &exit) {
+				t.Fatalf("%v: error was %v, but expected an exit status", tt.args, err)
+			}
+			code = exit.code
+		}
+		if code != tt.code {
+			t.Errorf("%v: exit status was %d, but expected %d (%v)", tt.args, code, tt.code, err)
+		}
+
+		if stdout.String() != tt.output {
+			t.Errorf("%v: output was %q, but expected %q", tt.args, stdout.String(), tt.output)
+		}
+		if err != nil && strings.Contains(err.Error(), string(plaintext)) {
+			t.Errorf("%v: error %q contains the plaintext", tt.args, err)
+		}
+	}
+}
@@ -0,0 +1,16 @@
+// Command sivcheck reports common misuse of the siv package. It is run by go
+// vet:
+//
+//	go vet -vettool=$(which sivcheck) ./...
+//
+// See package sivcheck for the checks.
+package main
+
+import (
+	"github.com/stripe/siv-go/sivcheck"
+	"golang.org/x/tools/go/analysis/unitchecker"
+)
+
+func main() {
+	unitchecker.Main(sivcheck.Analyzer)
+}
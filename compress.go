@@ -0,0 +1,101 @@
+package siv
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/cipher"
+	"errors"
+	"io"
+)
+
+const (
+	compressionNone  = 0
+	compressionFlate = 1
+)
+
+var (
+	// ErrDecompressedSize is returned by OpenCompressed when the plaintext
+	// would exceed the given limit.
+	ErrDecompressedSize = errors.New("siv: decompressed plaintext too large")
+
+	// ErrCompressionFlag is returned by OpenCompressed for a ciphertext with
+	// an unknown compression flag.
+	ErrCompressionFlag = errors.New("siv: unknown compression flag")
+)
+
+// SealCompressed compresses plaintext with flate at the given level, if doing
+// so makes it smaller, and seals it. The ciphertext is prefixed with a
+// one-byte flag recording whether it was compressed, which is also bound as
+// associated data, so flipping it causes OpenCompressed to fail rather than
+// to misinterpret the plaintext.
+//
+// Compressing before encrypting leaks information about the plaintext through
+// the ciphertext's length. If an attacker can influence part of the plaintext
+// and observe the ciphertext's length, they can recover secrets in the rest of
+// it, as in the CRIME and BREACH attacks. Only use SealCompressed on data which
+// is entirely secret or entirely public.
+func SealCompressed(aead cipher.AEAD, plaintext, ad []byte, level int) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	flag, payload := byte(compressionFlate), buf.Bytes()
+	if len(payload) >= len(plaintext) {
+		flag, payload = compressionNone, plaintext
+	}
+
+	return compressedAEAD(aead, flag).Seal([]byte{flag}, nil, payload, ad), nil
+}
+
+// OpenCompressed opens a ciphertext sealed by SealCompressed, decompressing it
+// if necessary. It returns ErrDecompressedSize rather than decompress more
+// than maxSize bytes.
+func OpenCompressed(aead cipher.AEAD, ciphertext, ad []byte, maxSize int) ([]byte, error) {
+	if len(ciphertext) < 1 {
+		return nil, ErrAuthentication
+	}
+
+	flag := ciphertext[0]
+	if flag != compressionNone && flag != compressionFlate {
+		return nil, ErrCompressionFlag
+	}
+
+	payload, err := compressedAEAD(aead, flag).Open(nil, nil, ciphertext[1:], ad)
+	if err != nil {
+		return nil, err
+	}
+
+	if flag == compressionNone {
+		if len(payload) > maxSize {
+			return nil, ErrDecompressedSize
+		}
+		return payload, nil
+	}
+
+	r := flate.NewReader(bytes.NewReader(payload))
+	defer func() { _ = r.Close() }()
+
+	plaintext, err := io.ReadAll(io.LimitReader(r, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(plaintext) > maxSize {
+		return nil, ErrDecompressedSize
+	}
+	return plaintext, nil
+}
+
+func compressedAEAD(aead cipher.AEAD, flag byte) cipher.AEAD {
+	return NewContext(aead, []byte("siv compressed"), []byte{flag})
+}
@@ -0,0 +1,106 @@
+package siv
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestSealCompressed(t *testing.T) {
+	c, _ := New(testKey(0, 32), aes.NewCipher)
+	plaintext := bytes.Repeat([]byte("compressible "), 100)
+
+	ciphertext, err := SealCompressed(c, plaintext, []byte("ad"), flate.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ciphertext[0] != compressionFlate || len(ciphertext) >= len(plaintext) {
+		t.Errorf("Plaintext was not compressed: %d bytes, flag %d", len(ciphertext), ciphertext[0])
+	}
+
+	actual, err := OpenCompressed(c, ciphertext, []byte("ad"), len(plaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(actual, plaintext) {
+		t.Errorf("Plaintext was %q, but expected %q", actual, plaintext)
+	}
+
+	if p, err := OpenCompressed(c, ciphertext, []byte("other"), len(plaintext)); err == nil {
+		t.Fatalf("Plaintext returned instead of error: %x", p)
+	}
+}
+
+func TestSealCompressedIncompressible(t *testing.T) {
+	c, _ := New(testKey(0, 32), aes.NewCipher)
+	plaintext := make([]byte, 1000)
+	_, _ = rand.Read(plaintext)
+
+	ciphertext, err := SealCompressed(c, plaintext, nil, flate.DefaultCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ciphertext[0] != compressionNone || len(ciphertext) != 1+c.Overhead()+len(plaintext) {
+		t.Errorf("Incompressible plaintext was compressed: %d bytes, flag %d", len(ciphertext), ciphertext[0])
+	}
+
+	actual, err := OpenCompressed(c, ciphertext, nil, len(plaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(actual, plaintext) {
+		t.Errorf("Plaintext was %x, but expected %x", actual, plaintext)
+	}
+
+	if _, err := OpenCompressed(c, ciphertext, nil, len(plaintext)-1); err != ErrDecompressedSize {
+		t.Errorf("Error was %v, but expected %v", err, ErrDecompressedSize)
+	}
+}
+
+func TestOpenCompressedFlagTamper(t *testing.T) {
+	c, _ := New(testKey(0, 32), aes.NewCipher)
+
+	for _, plaintext := range [][]byte{bytes.Repeat([]byte{'a'}, 100), []byte("x")} {
+		ciphertext, _ := SealCompressed(c, plaintext, nil, flate.DefaultCompression)
+
+		tampered := append([]byte{}, ciphertext...)
+		tampered[0] ^= 1
+		if p, err := OpenCompressed(c, tampered, nil, 1000); err != ErrAuthentication {
+			t.Fatalf("Plaintext returned instead of error: %x", p)
+		}
+
+		tampered[0] = 2
+		if _, err := OpenCompressed(c, tampered, nil, 1000); err != ErrCompressionFlag {
+			t.Errorf("Error was %v, but expected %v", err, ErrCompressionFlag)
+		}
+	}
+
+	if _, err := OpenCompressed(c, nil, nil, 1000); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+}
+
+func TestOpenCompressedBomb(t *testing.T) {
+	c, _ := New(testKey(0, 32), aes.NewCipher)
+
+	// 64 MiB of zeros compresses to about 64 KiB.
+	bomb := make([]byte, 64<<20)
+	ciphertext, err := SealCompressed(c, bomb, nil, flate.BestCompression)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ciphertext) > 1<<20 {
+		t.Fatalf("Ciphertext was %d bytes", len(ciphertext))
+	}
+
+	if _, err := OpenCompressed(c, ciphertext, nil, 1<<20); err != ErrDecompressedSize {
+		t.Errorf("Error was %v, but expected %v", err, ErrDecompressedSize)
+	}
+}
@@ -0,0 +1,333 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrInvalidConfig is wrapped by the errors from Config.Validate, and from
+// decoding a Config which is malformed or has unknown fields.
+var ErrInvalidConfig = errors.New("siv: invalid config")
+
+// ErrKeyEncoding is returned by DecodeKey when a key is neither hex nor
+// base64.
+var ErrKeyEncoding = errors.New("siv: key is not valid hex or base64")
+
+// Config describes an AEAD in a configuration file, so that services can
+// build one with Build rather than mapping each setting to a constructor or
+// option by hand. The zero value of every field but Algorithm and KeyFrom
+// means the default, so only the settings which differ need be written:
+//
+//	{"algorithm": "AES-SIV-CMAC-256", "key_from": "env:BILLING_KEY", "require_ad": true}
+//
+// Unknown fields are an error when decoding a Config with encoding/json, or
+// with a YAML library which supports the UnmarshalYAML(func(any) error) form,
+// as gopkg.in/yaml.v2 and v3 do.
+type Config struct {
+	// Algorithm is the name of a registered algorithm, such as
+	// AESSIVCMAC256. The other settings apply only to the built-in
+	// AES-SIV-CMAC algorithms.
+	Algorithm string `json:"algorithm" yaml:"algorithm"`
+
+	// KeyFrom is the source of the key: "env:VAR" or "file:path" for a hex
+	// or base64 key in an environment variable or file, or
+	// "provider:name" for a KeyProvider registered with
+	// RegisterKeyProvider.
+	KeyFrom string `json:"key_from" yaml:"key_from"`
+
+	// TagSize is the size of the tag in bytes. SIV tags are always 16
+	// bytes, so it may only be 0 or 16; it exists so that configuration
+	// schemas shared with other AEADs can state it.
+	TagSize int `json:"tag_size,omitempty" yaml:"tag_size,omitempty"`
+
+	// TagAppended places the tag after the ciphertext, as WithTagAppended.
+	TagAppended bool `json:"tag_appended,omitempty" yaml:"tag_appended,omitempty"`
+
+	// Padding pads plaintexts to a multiple of this many bytes, as
+	// WithPadding.
+	Padding int `json:"padding,omitempty" yaml:"padding,omitempty"`
+
+	// RequireAD requires associated data, as WithRequiredAD.
+	RequireAD bool `json:"require_ad,omitempty" yaml:"require_ad,omitempty"`
+
+	// NonceSize requires nonces of this many bytes, as WithNonceSize.
+	NonceSize int `json:"nonce_size,omitempty" yaml:"nonce_size,omitempty"`
+
+	// ZeroNonce requires all-zero 16-byte nonces, as WithZeroNonceCompat.
+	ZeroNonce bool `json:"zero_nonce,omitempty" yaml:"zero_nonce,omitempty"`
+}
+
+// configFields are the names of Config's fields in JSON and YAML.
+var configFields = map[string]bool{
+	"algorithm": true, "key_from": true, "tag_size": true, "tag_appended": true,
+	"padding": true, "require_ad": true, "nonce_size": true, "zero_nonce": true,
+}
+
+// builtinKeySizes are the key sizes of the algorithms New implements, by
+// lower-case name.
+var builtinKeySizes = map[string]int{
+	strings.ToLower(AESSIVCMAC256): 32,
+	strings.ToLower(AESSIVCMAC384): 48,
+	strings.ToLower(AESSIVCMAC512): 64,
+}
+
+// ParseConfig decodes and validates a JSON-encoded Config.
+func ParseConfig(b []byte) (*Config, error) {
+	var c Config
+	if err := c.UnmarshalJSON(b); err != nil {
+		return nil, err
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, rejecting unknown fields.
+func (c *Config) UnmarshalJSON(b []byte) error {
+	type plain Config
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.DisallowUnknownFields()
+
+	var v plain
+	if err := d.Decode(&v); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return fmt.Errorf("%w: trailing data", ErrInvalidConfig)
+	}
+	*c = Config(v)
+	return nil
+}
+
+// UnmarshalYAML decodes a Config with a YAML library, rejecting unknown
+// fields.
+func (c *Config) UnmarshalYAML(unmarshal func(any) error) error {
+	var fields map[string]any
+	if err := unmarshal(&fields); err != nil {
+		return err
+	}
+	var unknown []string
+	for k := range fields {
+		if !configFields[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return fmt.Errorf("%w: unknown fields %s", ErrInvalidConfig, strings.Join(unknown, ", "))
+	}
+
+	type plain Config
+	var v plain
+	if err := unmarshal(&v); err != nil {
+		return err
+	}
+	*c = Config(v)
+	return nil
+}
+
+// Validate checks that the algorithm is registered, that the key source is
+// well formed, and that the settings are valid and consistent, without
+// reading the key. It reports every problem, each wrapping ErrInvalidConfig.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]any{ErrInvalidConfig}, args...)...))
+	}
+
+	_, builtin := builtinKeySizes[strings.ToLower(c.Algorithm)]
+	registryMu.RLock()
+	_, registered := registry[strings.ToLower(c.Algorithm)]
+	registryMu.RUnlock()
+	switch {
+	case c.Algorithm == "":
+		invalid("algorithm is required (one of %s)", strings.Join(Names(), ", "))
+	case !registered:
+		invalid("unknown algorithm %q (known: %s)", c.Algorithm, strings.Join(Names(), ", "))
+	case !builtin && c.options():
+		invalid("tag_appended, padding, require_ad, nonce_size, and zero_nonce need a built-in AES-SIV-CMAC algorithm, not %q", c.Algorithm)
+	}
+
+	if kind, arg, _ := strings.Cut(c.KeyFrom, ":"); c.KeyFrom == "" {
+		invalid("key_from is required (env:VAR, file:path, or provider:name)")
+	} else if !validKeySource(kind, arg) {
+		invalid("key_from %q must be env:VAR, file:path, or provider:name", c.KeyFrom)
+	}
+
+	if c.TagSize != 0 && c.TagSize != blockSize {
+		invalid("tag_size %d: SIV tags are always %d bytes and can't be truncated", c.TagSize, blockSize)
+	}
+	if c.Padding < 0 {
+		invalid("padding %d must not be negative", c.Padding)
+	}
+	if c.NonceSize < 0 {
+		invalid("nonce_size %d must not be negative", c.NonceSize)
+	}
+	if c.ZeroNonce && c.NonceSize != 0 && c.NonceSize != blockSize {
+		invalid("zero_nonce requires %d-byte nonces, but nonce_size is %d; remove nonce_size", blockSize, c.NonceSize)
+	}
+	return errors.Join(errs...)
+}
+
+// options reports whether c has any settings which need options.
+func (c *Config) options() bool {
+	return c.TagAppended || c.Padding != 0 || c.RequireAD || c.NonceSize != 0 || c.ZeroNonce
+}
+
+// Build validates c, reads its key, and returns the AEAD it describes. The key
+// is wiped once the AEAD is built.
+func (c *Config) Build() (cipher.AEAD, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	key, err := ReadKey(c.KeyFrom)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(key)
+
+	if !c.options() {
+		return NewNamed(c.Algorithm, key)
+	}
+
+	if size := builtinKeySizes[strings.ToLower(c.Algorithm)]; len(key) != size {
+		return nil, fmt.Errorf("siv: key from %s is %d bytes, but %s needs %d", c.KeyFrom, len(key), c.Algorithm, size)
+	}
+
+	var opts []Option
+	if c.NonceSize != 0 {
+		opts = append(opts, WithNonceSize(c.NonceSize))
+	}
+	if c.ZeroNonce {
+		opts = append(opts, WithZeroNonceCompat())
+	}
+	if c.TagAppended {
+		opts = append(opts, WithTagAppended())
+	}
+	if c.Padding != 0 {
+		opts = append(opts, WithPadding(c.Padding))
+	}
+	if c.RequireAD {
+		opts = append(opts, WithRequiredAD())
+	}
+	return New(key, aes.NewCipher, opts...)
+}
+
+// ReadKey reads a key from source, which is one of:
+//
+//	env:VAR        the hex or base64 key in the environment variable VAR
+//	file:path      the hex or base64 key in the file at path
+//	provider:name  the raw key from the KeyProvider registered as name
+//
+// These are the sources accepted by Config's KeyFrom. The key's size is not
+// checked. The caller should wipe the key once it is no longer needed.
+func ReadKey(source string) ([]byte, error) {
+	kind, arg, _ := strings.Cut(source, ":")
+	if !validKeySource(kind, arg) {
+		return nil, fmt.Errorf("siv: invalid key source %q: must be env:VAR, file:path, or provider:name", source)
+	}
+
+	switch kind {
+	case "env":
+		v := os.Getenv(arg)
+		if v == "" {
+			return nil, fmt.Errorf("siv: environment variable %s is not set", arg)
+		}
+		return decodeKey("environment variable "+arg, []byte(v))
+
+	case "file":
+		b, err := os.ReadFile(arg)
+		if err != nil {
+			return nil, fmt.Errorf("siv: reading key: %w", err)
+		}
+		defer wipe(b)
+		return decodeKey(arg, b)
+	}
+
+	keyProvidersMu.RLock()
+	p, ok := keyProviders[arg]
+	keyProvidersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("siv: unknown key provider %q", arg)
+	}
+
+	key, release, err := p.Key()
+	if release != nil {
+		defer release()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("siv: key provider %s: %w", arg, err)
+	}
+	return append([]byte{}, key...), nil
+}
+
+// validKeySource reports whether the kind and argument of a key source are
+// well formed.
+func validKeySource(kind, arg string) bool {
+	return (kind == "env" || kind == "file" || kind == "provider") && arg != ""
+}
+
+// decodeKey decodes a hex or base64 key read from the named source.
+func decodeKey(name string, s []byte) ([]byte, error) {
+	b, err := DecodeKey(s)
+	if err != nil {
+		return nil, fmt.Errorf("siv: key in %s is not valid hex or base64", name)
+	}
+	return b, nil
+}
+
+// DecodeKey decodes a hex or base64 key, ignoring surrounding whitespace. Hex
+// is tried first, so text which is valid as both is decoded as hex. Base64
+// must be padded and canonical. It returns ErrKeyEncoding if s is neither.
+func DecodeKey(s []byte) ([]byte, error) {
+	s = bytes.TrimSpace(s)
+
+	b := make([]byte, hex.DecodedLen(len(s)))
+	if _, err := hex.Decode(b, s); err == nil {
+		return b, nil
+	}
+	wipe(b)
+
+	enc := base64.StdEncoding.Strict()
+	b = make([]byte, enc.DecodedLen(len(s)))
+	n, err := enc.Decode(b, s)
+	if err != nil {
+		wipe(b)
+		return nil, ErrKeyEncoding
+	}
+	return b[:n], nil
+}
+
+var (
+	keyProvidersMu sync.RWMutex
+	keyProviders   = make(map[string]KeyProvider)
+)
+
+// RegisterKeyProvider makes p available to Config and ReadKey as
+// "provider:name". If RegisterKeyProvider is called twice with the same name,
+// or if p is nil, it panics.
+func RegisterKeyProvider(name string, p KeyProvider) {
+	keyProvidersMu.Lock()
+	defer keyProvidersMu.Unlock()
+
+	if p == nil {
+		panic("siv: RegisterKeyProvider provider is nil")
+	}
+	if _, dup := keyProviders[name]; dup {
+		panic("siv: RegisterKeyProvider called twice for " + name)
+	}
+	keyProviders[name] = p
+}
@@ -0,0 +1,236 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func init() {
+	RegisterKeyProvider("config-test", NewInsecureKeyProvider(testKey(3, 64)))
+}
+
+func TestConfig(t *testing.T) {
+	key32, key64 := testKey(1, 32), testKey(3, 64)
+	t.Setenv("SIV_CONFIG_TEST_KEY", hex.EncodeToString(key32))
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key32)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		config   string
+		expected func() (cipher.AEAD, error)
+	}{
+		{
+			config:   `{"algorithm": "AES-SIV-CMAC-256", "key_from": "env:SIV_CONFIG_TEST_KEY"}`,
+			expected: func() (cipher.AEAD, error) { return New(key32, aes.NewCipher) },
+		},
+		{
+			config: `{"algorithm": "aes-siv-cmac-256", "key_from": "file:` + path + `", "tag_size": 16, "require_ad": true, "padding": 32}`,
+			expected: func() (cipher.AEAD, error) {
+				return New(key32, aes.NewCipher, WithRequiredAD(), WithPadding(32))
+			},
+		},
+		{
+			config: `{"algorithm": "AES-SIV-CMAC-512", "key_from": "provider:config-test", "tag_appended": true, "nonce_size": 12}`,
+			expected: func() (cipher.AEAD, error) {
+				return New(key64, aes.NewCipher, WithTagAppended(), WithNonceSize(12))
+			},
+		},
+		{
+			config: `{"algorithm": "AES-SIV-CMAC-512", "key_from": "provider:config-test", "zero_nonce": true, "nonce_size": 16}`,
+			expected: func() (cipher.AEAD, error) {
+				return New(key64, aes.NewCipher, WithZeroNonceCompat())
+			},
+		},
+	}
+
+	for _, test := range tests {
+		c, err := ParseConfig([]byte(test.config))
+		if err != nil {
+			t.Fatalf("%s: %v", test.config, err)
+		}
+
+		// The config survives a round trip.
+		b, err := json.Marshal(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c2, err := ParseConfig(b)
+		if err != nil || !reflect.DeepEqual(c, c2) {
+			t.Errorf("Config was %+v, %v, but expected %+v", c2, err, c)
+		}
+
+		aead, err := c.Build()
+		if err != nil {
+			t.Fatalf("%s: %v", test.config, err)
+		}
+		expected, err := test.expected()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		nonce := make([]byte, expected.NonceSize())
+		ciphertext := aead.Seal(nil, nonce, []byte("hello"), []byte("ad"))
+		if e := expected.Seal(nil, nonce, []byte("hello"), []byte("ad")); !bytes.Equal(ciphertext, e) {
+			t.Errorf("%s: ciphertext was %x, but expected %x", test.config, ciphertext, e)
+		}
+		if aead.NonceSize() != expected.NonceSize() || aead.Overhead() != expected.Overhead() {
+			t.Errorf("%s: sizes were %d and %d", test.config, aead.NonceSize(), aead.Overhead())
+		}
+	}
+}
+
+func TestConfigYAML(t *testing.T) {
+	t.Setenv("SIV_CONFIG_TEST_KEY", hex.EncodeToString(testKey(0, 48)))
+
+	// A stand-in for a YAML library's unmarshal function.
+	unmarshal := func(doc string) func(any) error {
+		return func(v any) error {
+			return json.Unmarshal([]byte(doc), v)
+		}
+	}
+
+	var c Config
+	if err := c.UnmarshalYAML(unmarshal(`{"algorithm": "AES-SIV-CMAC-384", "key_from": "env:SIV_CONFIG_TEST_KEY", "padding": 16}`)); err != nil {
+		t.Fatal(err)
+	}
+	expected := Config{Algorithm: AESSIVCMAC384, KeyFrom: "env:SIV_CONFIG_TEST_KEY", Padding: 16}
+	if c != expected {
+		t.Errorf("Config was %+v, but expected %+v", c, expected)
+	}
+	if _, err := c.Build(); err != nil {
+		t.Error(err)
+	}
+
+	err := c.UnmarshalYAML(unmarshal(`{"algorithm": "AES-SIV-CMAC-384", "key_from": "env:K", "padd": 16, "aad": true}`))
+	if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), "aad, padd") {
+		t.Errorf("Error was %v, but expected unknown fields", err)
+	}
+}
+
+func TestConfigInvalid(t *testing.T) {
+	tests := []struct {
+		config   string
+		expected []string // substrings of the error
+	}{
+		{`{"algorithm": "AES-SIV-CMAC-256", "key_from": "env:K", "requireAD": true}`, []string{`unknown field "requireAD"`}},
+		{`{"algorithm": "AES-SIV-CMAC-256", "key_from": "env:K"} {}`, []string{"trailing data"}},
+		{`{"key_from": "env:K"}`, []string{"algorithm is required", AESSIVCMAC256}},
+		{`{"algorithm": "AES-GCM", "key_from": "env:K"}`, []string{`unknown algorithm "AES-GCM"`, AESSIVCMAC512}},
+		{`{"algorithm": "AES-SIV-CMAC-256"}`, []string{"key_from is required"}},
+		{`{"algorithm": "AES-SIV-CMAC-256", "key_from": "K"}`, []string{`key_from "K" must be`}},
+		{`{"algorithm": "AES-SIV-CMAC-256", "key_from": "env:"}`, []string{`key_from "env:" must be`}},
+		{`{"algorithm": "AES-SIV-CMAC-256", "key_from": "vault:k"}`, []string{`key_from "vault:k" must be`}},
+		{`{"algorithm": "AES-SIV-CMAC-256", "key_from": "env:K", "tag_size": 8}`, []string{"tag_size 8", "can't be truncated"}},
+		{`{"algorithm": "AES-SIV-CMAC-256", "key_from": "env:K", "padding": -1}`, []string{"padding -1"}},
+		{`{"algorithm": "AES-SIV-CMAC-256", "key_from": "env:K", "nonce_size": -1}`, []string{"nonce_size -1"}},
+		{`{"algorithm": "AES-SIV-CMAC-256", "key_from": "env:K", "zero_nonce": true, "nonce_size": 12}`, []string{"zero_nonce requires 16-byte nonces"}},
+		{`{"algorithm": "AES-GCM", "tag_size": 12}`, []string{"unknown algorithm", "key_from is required", "tag_size 12"}},
+	}
+
+	for _, test := range tests {
+		c, err := ParseConfig([]byte(test.config))
+		if !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("%s: error was %v, but expected %v (config %+v)", test.config, err, ErrInvalidConfig, c)
+			continue
+		}
+		for _, s := range test.expected {
+			if !strings.Contains(err.Error(), s) {
+				t.Errorf("%s: error was %q, but expected it to contain %q", test.config, err, s)
+			}
+		}
+	}
+}
+
+func TestConfigRegistered(t *testing.T) {
+	name := "config-test-alg"
+	Register(name, func(key []byte) (cipher.AEAD, error) { return New(key, aes.NewCipher) })
+	t.Setenv("SIV_CONFIG_TEST_KEY", hex.EncodeToString(testKey(0, 32)))
+
+	c := Config{Algorithm: name, KeyFrom: "env:SIV_CONFIG_TEST_KEY"}
+	if _, err := c.Build(); err != nil {
+		t.Error(err)
+	}
+
+	c.RequireAD = true
+	if err := c.Validate(); !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), "built-in") {
+		t.Errorf("Error was %v, but expected %v", err, ErrInvalidConfig)
+	}
+}
+
+func TestConfigKeys(t *testing.T) {
+	t.Setenv("SIV_CONFIG_TEST_KEY", "not a key")
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(testKey(0, 48))), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []Config{
+		{Algorithm: AESSIVCMAC256, KeyFrom: "env:SIV_CONFIG_TEST_KEY"},
+		{Algorithm: AESSIVCMAC256, KeyFrom: "env:SIV_CONFIG_TEST_UNSET"},
+		{Algorithm: AESSIVCMAC256, KeyFrom: "file:" + path + ".missing"},
+		{Algorithm: AESSIVCMAC256, KeyFrom: "file:" + path},
+		{Algorithm: AESSIVCMAC256, KeyFrom: "file:" + path, RequireAD: true},
+		{Algorithm: AESSIVCMAC256, KeyFrom: "provider:config-test-missing"},
+	} {
+		if aead, err := c.Build(); err == nil {
+			t.Errorf("%+v: built %T instead of error", c, aead)
+		}
+	}
+}
+
+func TestReadKey(t *testing.T) {
+	key := testKey(3, 64)
+	t.Setenv("SIV_CONFIG_TEST_KEY", base64.StdEncoding.EncodeToString(key))
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte(" "+hex.EncodeToString(key)+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, source := range []string{"env:SIV_CONFIG_TEST_KEY", "file:" + path, "provider:config-test"} {
+		actual, err := ReadKey(source)
+		if err != nil {
+			t.Fatalf("%s: %v", source, err)
+		}
+		if !bytes.Equal(actual, key) {
+			t.Errorf("%s: Key was %x, but expected %x", source, actual, key)
+		}
+	}
+
+	for _, source := range []string{"", "env:", "file:", "provider:", "stdin", "/etc/key", "provider:config-test-missing"} {
+		if key, err := ReadKey(source); err == nil {
+			t.Errorf("%q: Key returned instead of error: %x", source, key)
+		}
+	}
+}
+
+func TestDecodeKey(t *testing.T) {
+	key := testKey(0, 32)
+	for _, s := range []string{hex.EncodeToString(key), "\t" + base64.StdEncoding.EncodeToString(key) + "\n"} {
+		if actual, err := DecodeKey([]byte(s)); err != nil || !bytes.Equal(actual, key) {
+			t.Errorf("%q: Key was %x, %v, but expected %x", s, actual, err, key)
+		}
+	}
+
+	for _, s := range []string{"not a key", base64.RawStdEncoding.EncodeToString(key)} {
+		if _, err := DecodeKey([]byte(s)); err != ErrKeyEncoding {
+			t.Errorf("%q: Error was %v, but expected %v", s, err, ErrKeyEncoding)
+		}
+	}
+}
+
+func TestRegisterKeyProvider(t *testing.T) {
+	mustPanic(t, func() { RegisterKeyProvider("config-test", NewInsecureKeyProvider(nil)) })
+	mustPanic(t, func() { RegisterKeyProvider("config-test-nil", nil) })
+}
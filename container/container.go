@@ -0,0 +1,143 @@
+// Package container implements a seekable encrypted container format, which
+// allows any byte range of the plaintext to be read by decrypting and
+// verifying only the chunks which cover it.
+//
+// A container is a header followed by the plaintext split into chunks of a
+// fixed size, each sealed separately; only the last chunk may be shorter. The
+// header holds a magic number, a version, the chunk size, the chunk count, and
+// the plaintext size, and is authenticated by a tag sealed over it. Every
+// chunk is sealed with the header and its index bound as associated data, so
+// chunks can't be reordered, dropped, or moved between containers, and a
+// truncated container is detected from its size before anything is read.
+//
+// Containers are written either in one pass by a Writer or, for long-running
+// uploads which may be interrupted, by ResumeEncryption, which records its
+// progress in a Manifest.
+package container
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	siv "github.com/stripe/siv-go"
+)
+
+const (
+	// MaxChunkSize is the largest chunk size allowed, in bytes.
+	MaxChunkSize = 16 << 20
+
+	// DefaultChunkSize is a reasonable chunk size for most uses, in bytes.
+	DefaultChunkSize = 64 << 10
+
+	version = 1
+
+	// headerSize is the size of the header, excluding its tag.
+	headerSize = 4 + 1 + 4 + 8 + 8
+)
+
+var magic = [4]byte{'S', 'I', 'V', 'C'}
+
+var (
+	// ErrFormat is returned for containers with an invalid or unsupported
+	// header.
+	ErrFormat = errors.New("container: invalid header")
+
+	// ErrTruncated is returned when a container's size does not match its
+	// header, e.g. because trailing chunks are missing.
+	ErrTruncated = errors.New("container: truncated or extended container")
+
+	// ErrSize is returned by Writer when the number of bytes written does not
+	// match the size it was created with.
+	ErrSize = errors.New("container: plaintext size mismatch")
+)
+
+// ChunkError is returned when a chunk fails to authenticate.
+type ChunkError struct {
+	Index uint64
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("container: chunk %d failed to authenticate", e.Index)
+}
+
+// Unwrap returns siv.ErrAuthentication.
+func (e *ChunkError) Unwrap() error {
+	return siv.ErrAuthentication
+}
+
+// header describes the layout of a container.
+type header struct {
+	chunkSize  int
+	chunkCount uint64
+	size       int64
+}
+
+func newHeader(size int64, chunkSize int) (header, error) {
+	if chunkSize <= 0 || chunkSize > MaxChunkSize {
+		return header{}, fmt.Errorf("container: invalid chunk size %d", chunkSize)
+	}
+
+	if size < 0 {
+		return header{}, fmt.Errorf("container: invalid size %d", size)
+	}
+
+	return header{
+		chunkSize:  chunkSize,
+		chunkCount: uint64((size + int64(chunkSize) - 1) / int64(chunkSize)),
+		size:       size,
+	}, nil
+}
+
+func (h header) marshal() []byte {
+	b := make([]byte, 0, headerSize)
+	b = append(b, magic[:]...)
+	b = append(b, version)
+	b = binary.BigEndian.AppendUint32(b, uint32(h.chunkSize))
+	b = binary.BigEndian.AppendUint64(b, h.chunkCount)
+	return binary.BigEndian.AppendUint64(b, uint64(h.size))
+}
+
+func parseHeader(b []byte) (header, error) {
+	if len(b) != headerSize || [4]byte(b[:4]) != magic || b[4] != version {
+		return header{}, ErrFormat
+	}
+
+	size := binary.BigEndian.Uint64(b[17:])
+	if size > 1<<62 {
+		return header{}, ErrFormat
+	}
+
+	h, err := newHeader(int64(size), int(binary.BigEndian.Uint32(b[5:])))
+	if err != nil || h.chunkCount != binary.BigEndian.Uint64(b[9:]) {
+		return header{}, ErrFormat
+	}
+	return h, nil
+}
+
+// chunkLen returns the plaintext length of chunk i.
+func (h header) chunkLen(i uint64) int {
+	if i == h.chunkCount-1 {
+		return int(h.size - int64(i)*int64(h.chunkSize))
+	}
+	return h.chunkSize
+}
+
+// encryptedSize returns the total size of a container with this header.
+func (h header) encryptedSize(overhead int) int64 {
+	return int64(headerSize+overhead) + h.size + int64(h.chunkCount)*int64(overhead)
+}
+
+// bind returns aead with the container's header bound to it.
+func bind(aead cipher.AEAD, hdr []byte) cipher.AEAD {
+	return siv.NewContext(aead, []byte("siv container"), hdr)
+}
+
+// headerData and chunkData return the associated data for the header tag and
+// for chunk i. They differ in length, so can't collide.
+var headerData = []byte("header")
+
+func chunkData(i uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, i)
+}
@@ -0,0 +1,313 @@
+package container
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"io"
+	"math/rand"
+	"sync"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func testAEAD(t *testing.T) cipher.AEAD {
+	aead, err := siv.New([]byte("0123456789abcdef0123456789ABCDEF"), aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func encrypt(t *testing.T, aead cipher.AEAD, plaintext []byte, chunkSize int) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, aead, int64(len(plaintext)), chunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write in uneven pieces to exercise chunk boundaries.
+	for p := plaintext; len(p) > 0; {
+		n := 1 + len(p)%37
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	aead := testAEAD(t)
+	rng := rand.New(rand.NewSource(1))
+
+	for _, size := range []int{0, 1, 99, 100, 101, 1000, 4096} {
+		plaintext := make([]byte, size)
+		rng.Read(plaintext)
+
+		c := encrypt(t, aead, plaintext, 100)
+		r, err := NewReader(bytes.NewReader(c), int64(len(c)), aead)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if r.Size() != int64(size) {
+			t.Errorf("Size was %d, but expected %d", r.Size(), size)
+		}
+
+		actual, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(actual, plaintext) {
+			t.Errorf("%d: Plaintext was %x, but expected %x", size, actual, plaintext)
+		}
+	}
+}
+
+func TestRandomAccess(t *testing.T) {
+	aead := testAEAD(t)
+	rng := rand.New(rand.NewSource(2))
+
+	plaintext := make([]byte, 10000)
+	rng.Read(plaintext)
+	c := encrypt(t, aead, plaintext, 256)
+
+	r, err := NewReader(bytes.NewReader(c), int64(len(c)), aead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 500; i++ {
+		off := rng.Int63n(int64(len(plaintext)))
+		p := make([]byte, rng.Intn(1000))
+
+		n, err := r.ReadAt(p, off)
+		end := off + int64(len(p))
+		if end > int64(len(plaintext)) {
+			end = int64(len(plaintext))
+			if err != io.EOF {
+				t.Errorf("Error was %v, but expected %v", err, io.EOF)
+			}
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(p[:n], plaintext[off:end]) {
+			t.Fatalf("ReadAt(%d, %d) was %x, but expected %x", len(p), off, p[:n], plaintext[off:end])
+		}
+	}
+
+	if pos, err := r.Seek(-10, io.SeekEnd); err != nil || pos != int64(len(plaintext))-10 {
+		t.Fatalf("Seek returned %d, %v", pos, err)
+	}
+
+	rest, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(rest, plaintext[len(plaintext)-10:]) {
+		t.Errorf("Read %x (error %v), but expected %x", rest, err, plaintext[len(plaintext)-10:])
+	}
+}
+
+func TestOutOfRange(t *testing.T) {
+	aead := testAEAD(t)
+	c := encrypt(t, aead, make([]byte, 300), 100)
+
+	r, err := NewReader(bytes.NewReader(c), int64(len(c)), aead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := r.ReadAt(make([]byte, 10), 300); n != 0 || err != io.EOF {
+		t.Errorf("ReadAt past the end returned %d, %v", n, err)
+	}
+
+	if _, err := r.ReadAt(make([]byte, 10), -1); err == nil {
+		t.Errorf("ReadAt at a negative offset succeeded")
+	}
+
+	if _, err := r.Seek(-1, io.SeekStart); err == nil {
+		t.Errorf("Seek to a negative offset succeeded")
+	}
+}
+
+func TestConcurrentReadAt(t *testing.T) {
+	aead := testAEAD(t)
+	plaintext := make([]byte, 4096)
+	rand.New(rand.NewSource(1)).Read(plaintext)
+
+	c := encrypt(t, aead, plaintext, 100)
+	r, err := NewReader(bytes.NewReader(c), int64(len(c)), aead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 16; g++ {
+		wg.Add(1)
+		go func(seed int64) {
+			defer wg.Done()
+
+			rng := rand.New(rand.NewSource(seed))
+			for i := 0; i < 500; i++ {
+				off := rng.Intn(len(plaintext))
+				p := make([]byte, 1+rng.Intn(len(plaintext)-off))
+				if _, err := r.ReadAt(p, int64(off)); err != nil {
+					t.Errorf("ReadAt(%d, %d): %v", len(p), off, err)
+					return
+				}
+				if !bytes.Equal(p, plaintext[off:off+len(p)]) {
+					t.Errorf("ReadAt(%d, %d): Plaintext was %x, but expected %x", len(p), off, p, plaintext[off:off+len(p)])
+					return
+				}
+			}
+		}(int64(g))
+	}
+	wg.Wait()
+}
+
+// eofReaderAt returns io.EOF along with the last bytes of its data, as
+// io.ReaderAt allows.
+type eofReaderAt struct {
+	b []byte
+}
+
+func (r eofReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := bytes.NewReader(r.b).ReadAt(p, off)
+	if err == nil && off+int64(n) == int64(len(r.b)) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func TestReaderAtEOF(t *testing.T) {
+	aead := testAEAD(t)
+	plaintext := bytes.Repeat([]byte("eof"), 100)
+
+	// The header and the last chunk are each read up to the end of the data.
+	for _, size := range []int{0, len(plaintext)} {
+		c := encrypt(t, aead, plaintext[:size], 100)
+		r, err := NewReader(eofReaderAt{c}, int64(len(c)), aead)
+		if err != nil {
+			t.Fatalf("%d: %v", size, err)
+		}
+
+		actual, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%d: %v", size, err)
+		}
+		if !bytes.Equal(actual, plaintext[:size]) {
+			t.Errorf("%d: Plaintext was %x, but expected %x", size, actual, plaintext[:size])
+		}
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	aead := testAEAD(t)
+	c := encrypt(t, aead, make([]byte, 300), 100)
+
+	for _, n := range []int{0, 10, len(c) - 116, len(c) - 1} {
+		if _, err := NewReader(bytes.NewReader(c[:n]), int64(n), aead); err != ErrTruncated && err != ErrFormat {
+			t.Errorf("%d bytes: Error was %v, but expected %v", n, err, ErrTruncated)
+		}
+	}
+
+	if _, err := NewReader(bytes.NewReader(append(c, 0)), int64(len(c)+1), aead); err != ErrTruncated {
+		t.Errorf("Error was %v, but expected %v", err, ErrTruncated)
+	}
+
+	// A ReaderAt which is shorter than the claimed size fails when the missing
+	// chunk is read.
+	short := c[:len(c)-50]
+	r, err := NewReader(bytes.NewReader(short), int64(len(c)), aead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := r.ReadAt(make([]byte, 10), 250); err != ErrTruncated {
+		t.Errorf("Error was %v, but expected %v", err, ErrTruncated)
+	}
+}
+
+func TestTampered(t *testing.T) {
+	aead := testAEAD(t)
+	plaintext := bytes.Repeat([]byte("container"), 100)
+	c := encrypt(t, aead, plaintext, 100)
+
+	// Flip a bit in the middle of chunk 4.
+	tampered := append([]byte{}, c...)
+	start := headerSize + aead.Overhead()
+	tampered[start+4*(100+aead.Overhead())+50] ^= 1
+
+	r, err := NewReader(bytes.NewReader(tampered), int64(len(tampered)), aead)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := make([]byte, 100)
+	if _, err := r.ReadAt(p, 0); err != nil {
+		t.Errorf("Reading an untouched chunk failed: %v", err)
+	}
+
+	_, err = r.ReadAt(p, 420)
+	var chunkErr *ChunkError
+	if !errors.As(err, &chunkErr) || chunkErr.Index != 4 || !errors.Is(err, siv.ErrAuthentication) {
+		t.Errorf("Error was %v, but expected an authentication error for chunk 4", err)
+	}
+
+	// Swapping two chunks is detected too.
+	swapped := append([]byte{}, c...)
+	n := 100 + aead.Overhead()
+	copy(swapped[start:], c[start+n:start+2*n])
+	copy(swapped[start+n:], c[start:start+n])
+
+	r, _ = NewReader(bytes.NewReader(swapped), int64(len(swapped)), aead)
+	if _, err := r.ReadAt(p, 0); !errors.Is(err, siv.ErrAuthentication) {
+		t.Errorf("Error was %v, but expected %v", err, siv.ErrAuthentication)
+	}
+
+	// As is any change to the header.
+	for i := 0; i < start; i++ {
+		tampered := append([]byte{}, c...)
+		tampered[i] ^= 1
+		if _, err := NewReader(bytes.NewReader(tampered), int64(len(tampered)), aead); err != ErrFormat {
+			t.Errorf("Header byte %d: Error was %v, but expected %v", i, err, ErrFormat)
+		}
+	}
+}
+
+func TestWriterSize(t *testing.T) {
+	aead := testAEAD(t)
+
+	w, err := NewWriter(io.Discard, aead, 10, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write(make([]byte, 11)); err != ErrSize {
+		t.Errorf("Error was %v, but expected %v", err, ErrSize)
+	}
+
+	if _, err := w.Write(make([]byte, 9)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != ErrSize {
+		t.Errorf("Error was %v, but expected %v", err, ErrSize)
+	}
+
+	for _, chunkSize := range []int{0, -1, MaxChunkSize + 1} {
+		if _, err := NewWriter(io.Discard, aead, 10, chunkSize); err == nil {
+			t.Errorf("Chunk size %d was accepted", chunkSize)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package container
+
+import (
+	"crypto/cipher"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Reader decrypts a container, implementing random access over its
+// plaintext. Each read decrypts and verifies only the chunks it covers.
+// ReadAt is safe for concurrent use, as io.ReaderAt requires, but Read and
+// Seek are not.
+type Reader struct {
+	r      io.ReaderAt
+	aead   cipher.AEAD
+	header header
+	start  int64 // offset of the first chunk
+
+	offset int64
+
+	// The most recently decrypted chunk, to serve sequential reads.
+	mu        sync.Mutex
+	cached    []byte
+	cachedIdx uint64
+	valid     bool
+	ct        []byte
+}
+
+// NewReader returns a Reader for the container of the given size in r. It
+// verifies the header, and that the size matches it.
+func NewReader(r io.ReaderAt, size int64, aead cipher.AEAD) (*Reader, error) {
+	b := make([]byte, headerSize+aead.Overhead())
+	if err := readFull(r, b, 0); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrFormat
+		}
+		return nil, err
+	}
+
+	h, err := parseHeader(b[:headerSize])
+	if err != nil {
+		return nil, err
+	}
+
+	aead = bind(aead, b[:headerSize])
+	if _, err := aead.Open(nil, nil, b[headerSize:], headerData); err != nil {
+		return nil, ErrFormat
+	}
+
+	if size != h.encryptedSize(aead.Overhead()) {
+		return nil, ErrTruncated
+	}
+
+	return &Reader{
+		r:      r,
+		aead:   aead,
+		header: h,
+		start:  int64(len(b)),
+	}, nil
+}
+
+// Size returns the size of the plaintext.
+func (r *Reader) Size() int64 {
+	return r.header.size
+}
+
+// ReadAt reads len(p) bytes of plaintext starting at off.
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("container: negative offset")
+	}
+
+	n := 0
+	for n < len(p) {
+		if off >= r.header.size {
+			return n, io.EOF
+		}
+
+		m, err := r.readChunk(p[n:], off)
+		if err != nil {
+			return n, err
+		}
+		n += m
+		off += int64(m)
+	}
+	return n, nil
+}
+
+// readChunk copies plaintext starting at off into p, up to the end of the
+// chunk which holds off.
+func (r *Reader) readChunk(p []byte, off int64) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i := uint64(off / int64(r.header.chunkSize))
+	chunk, err := r.chunk(i)
+	if err != nil {
+		return 0, err
+	}
+	return copy(p, chunk[off-int64(i)*int64(r.header.chunkSize):]), nil
+}
+
+// chunk returns the plaintext of chunk i. r.mu must be held.
+func (r *Reader) chunk(i uint64) ([]byte, error) {
+	if r.valid && r.cachedIdx == i {
+		return r.cached, nil
+	}
+	r.valid = false
+
+	overhead := int64(r.aead.Overhead())
+	off := r.start + int64(i)*(int64(r.header.chunkSize)+overhead)
+	n := r.header.chunkLen(i) + int(overhead)
+
+	if cap(r.ct) < n {
+		r.ct = make([]byte, n)
+	}
+	r.ct = r.ct[:n]
+	if err := readFull(r.r, r.ct, off); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, ErrTruncated
+		}
+		return nil, err
+	}
+
+	chunk, err := r.aead.Open(r.cached[:0], nil, r.ct, chunkData(i))
+	if err != nil {
+		return nil, &ChunkError{Index: i}
+	}
+
+	r.cached, r.cachedIdx, r.valid = chunk, i, true
+	return chunk, nil
+}
+
+// readFull reads len(b) bytes from r at off. As io.ReaderAt allows, a read of
+// the last bytes of r may return io.EOF along with them, which isn't an error.
+func readFull(r io.ReaderAt, b []byte, off int64) error {
+	n, err := r.ReadAt(b, off)
+	if n == len(b) {
+		return nil
+	}
+	return err
+}
+
+// Read reads plaintext from the current offset.
+func (r *Reader) Read(p []byte) (int, error) {
+	n, err := r.ReadAt(p, r.offset)
+	r.offset += int64(n)
+	if n > 0 && err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+// Seek sets the offset for the next Read, as described by io.Seeker.
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.header.size
+	default:
+		return 0, errors.New("container: invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, errors.New("container: negative offset")
+	}
+	r.offset = offset
+	return offset, nil
+}
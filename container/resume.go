@@ -0,0 +1,137 @@
+package container
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrManifestMismatch is returned by ResumeEncryption when a chunk recorded in
+// the manifest no longer matches the source, e.g. because the source changed
+// since encryption began.
+var ErrManifestMismatch = errors.New("container: source does not match manifest")
+
+// manifestData is the associated data the manifest is sealed with.
+var manifestData = []byte("siv container manifest")
+
+// Manifest records the progress of a resumable encryption: the layout of the
+// container and the tag of every chunk written so far. As SIV is
+// deterministic, sealing the same chunk again produces the same tag, so the
+// tags identify exactly which chunks were written consistently.
+type Manifest struct {
+	Version    int      `json:"version"`
+	ChunkSize  int      `json:"chunk_size"`
+	ChunkCount uint64   `json:"chunk_count"`
+	Size       int64    `json:"size"`
+	Tags       [][]byte `json:"tags"`
+}
+
+// NewManifest returns a manifest for encrypting size bytes of plaintext in
+// chunks of chunkSize bytes.
+func NewManifest(size int64, chunkSize int) (*Manifest, error) {
+	h, err := newHeader(size, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Manifest{
+		Version:    version,
+		ChunkSize:  h.chunkSize,
+		ChunkCount: h.chunkCount,
+		Size:       h.size,
+	}, nil
+}
+
+// Complete reports whether every chunk has been written.
+func (m *Manifest) Complete() bool {
+	return uint64(len(m.Tags)) == m.ChunkCount
+}
+
+func (m *Manifest) header() (header, error) {
+	h, err := newHeader(m.Size, m.ChunkSize)
+	if err != nil || m.Version != version || h.chunkCount != m.ChunkCount || uint64(len(m.Tags)) > h.chunkCount {
+		return header{}, ErrFormat
+	}
+	return h, nil
+}
+
+// ResumeEncryption encrypts src into dst as a container with the layout given
+// by m, starting from the first chunk not recorded in m. The chunks already
+// recorded are re-sealed from src and checked against their recorded tags
+// before continuing, and each chunk's tag is recorded in m once it has been
+// written to dst. If it returns an error, m records the progress made; once it
+// succeeds, dst holds exactly what a Writer would have produced.
+func ResumeEncryption(aead cipher.AEAD, m *Manifest, src io.ReadSeeker, dst io.WriterAt) error {
+	h, err := m.header()
+	if err != nil {
+		return err
+	}
+
+	hdr := h.marshal()
+	aead = bind(aead, hdr)
+	start := int64(headerSize + aead.Overhead())
+	if _, err := dst.WriteAt(aead.Seal(hdr, nil, nil, headerData), 0); err != nil {
+		return err
+	}
+
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	buf := make([]byte, h.chunkSize)
+	var out []byte
+	for i := uint64(0); i < h.chunkCount; i++ {
+		p := buf[:h.chunkLen(i)]
+		if _, err := io.ReadFull(src, p); err != nil {
+			return err
+		}
+
+		out = aead.Seal(out[:0], nil, p, chunkData(i))
+		tag := out[:aead.Overhead()]
+
+		if i < uint64(len(m.Tags)) {
+			if !bytes.Equal(tag, m.Tags[i]) {
+				return fmt.Errorf("%w: chunk %d", ErrManifestMismatch, i)
+			}
+			continue
+		}
+
+		off := start + int64(i)*int64(h.chunkSize+aead.Overhead())
+		if _, err := dst.WriteAt(out, off); err != nil {
+			return err
+		}
+		m.Tags = append(m.Tags, append([]byte{}, tag...))
+	}
+	return nil
+}
+
+// SealManifest encodes m and seals it under aead, so that it can be stored
+// alongside the container and later trusted.
+func SealManifest(aead cipher.AEAD, m *Manifest) ([]byte, error) {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(nil, nil, b, manifestData), nil
+}
+
+// OpenManifest opens a manifest sealed by SealManifest.
+func OpenManifest(aead cipher.AEAD, sealed []byte) (*Manifest, error) {
+	b, err := aead.Open(nil, nil, sealed, manifestData)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+
+	if _, err := m.header(); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
@@ -0,0 +1,126 @@
+package container
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+var errInterrupted = errors.New("interrupted")
+
+// interruptingWriter is an io.WriterAt over a growable buffer which fails
+// after a number of writes.
+type interruptingWriter struct {
+	buf    []byte
+	writes int
+}
+
+func (w *interruptingWriter) WriteAt(p []byte, off int64) (int, error) {
+	if w.writes == 0 {
+		return 0, errInterrupted
+	}
+	w.writes--
+
+	if end := int(off) + len(p); end > len(w.buf) {
+		w.buf = append(w.buf, make([]byte, end-len(w.buf))...)
+	}
+	return copy(w.buf[off:], p), nil
+}
+
+func TestResumeEncryption(t *testing.T) {
+	aead := testAEAD(t)
+	plaintext := make([]byte, 1050)
+	rand.New(rand.NewSource(3)).Read(plaintext)
+	expected := encrypt(t, aead, plaintext, 100)
+
+	// Interrupt after writing the header and k chunks, for several k, then
+	// resume from a manifest which has been saved and loaded.
+	for _, k := range []int{0, 1, 5, 10} {
+		m, err := NewManifest(int64(len(plaintext)), 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dst := &interruptingWriter{writes: 1 + k}
+		if err := ResumeEncryption(aead, m, bytes.NewReader(plaintext), dst); err != errInterrupted {
+			t.Fatalf("%d: Error was %v, but expected %v", k, err, errInterrupted)
+		}
+
+		if len(m.Tags) != k {
+			t.Errorf("%d: Manifest recorded %d chunks", k, len(m.Tags))
+		}
+
+		b, _ := json.Marshal(m)
+		var saved Manifest
+		if err := json.Unmarshal(b, &saved); err != nil {
+			t.Fatal(err)
+		}
+
+		dst.writes = 100
+		if err := ResumeEncryption(aead, &saved, bytes.NewReader(plaintext), dst); err != nil {
+			t.Fatal(err)
+		}
+
+		if !saved.Complete() {
+			t.Errorf("%d: Manifest is incomplete", k)
+		}
+
+		if !bytes.Equal(dst.buf, expected) {
+			t.Errorf("%d: Output differs from an uninterrupted run", k)
+		}
+	}
+}
+
+func TestResumeEncryptionMismatch(t *testing.T) {
+	aead := testAEAD(t)
+	plaintext := make([]byte, 500)
+
+	m, _ := NewManifest(int64(len(plaintext)), 100)
+	dst := &interruptingWriter{writes: 3}
+	_ = ResumeEncryption(aead, m, bytes.NewReader(plaintext), dst)
+
+	plaintext[150] ^= 1
+	dst.writes = 100
+	if err := ResumeEncryption(aead, m, bytes.NewReader(plaintext), dst); !errors.Is(err, ErrManifestMismatch) {
+		t.Errorf("Error was %v, but expected %v", err, ErrManifestMismatch)
+	}
+
+	m.ChunkCount++
+	if err := ResumeEncryption(aead, m, bytes.NewReader(plaintext), dst); err != ErrFormat {
+		t.Errorf("Error was %v, but expected %v", err, ErrFormat)
+	}
+}
+
+func TestSealManifest(t *testing.T) {
+	aead := testAEAD(t)
+	plaintext := make([]byte, 250)
+
+	m, _ := NewManifest(int64(len(plaintext)), 100)
+	if err := ResumeEncryption(aead, m, bytes.NewReader(plaintext), &interruptingWriter{writes: 100}); err != nil {
+		t.Fatal(err)
+	}
+
+	sealed, err := SealManifest(aead, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened, err := OpenManifest(aead, sealed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(opened, m) {
+		t.Errorf("Manifest was %+v, but expected %+v", opened, m)
+	}
+
+	sealed[len(sealed)-1] ^= 1
+	if _, err := OpenManifest(aead, sealed); err != siv.ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, siv.ErrAuthentication)
+	}
+}
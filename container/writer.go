@@ -0,0 +1,95 @@
+package container
+
+import (
+	"crypto/cipher"
+	"io"
+)
+
+// Writer encrypts a plaintext of a known size into a container.
+type Writer struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header header
+
+	buf     []byte
+	out     []byte
+	index   uint64
+	written int64
+	err     error
+}
+
+// NewWriter writes the header of a container holding size bytes of plaintext
+// in chunks of chunkSize bytes, and returns a Writer to which the plaintext
+// must be written. Close must be called once all size bytes are written.
+func NewWriter(w io.Writer, aead cipher.AEAD, size int64, chunkSize int) (*Writer, error) {
+	h, err := newHeader(size, chunkSize)
+	if err != nil {
+		return nil, err
+	}
+
+	hdr := h.marshal()
+	aead = bind(aead, hdr)
+	if _, err := w.Write(aead.Seal(hdr, nil, nil, headerData)); err != nil {
+		return nil, err
+	}
+
+	return &Writer{
+		w:      w,
+		aead:   aead,
+		header: h,
+		buf:    make([]byte, 0, chunkSize),
+	}, nil
+}
+
+// Write encrypts p, writing each chunk as it is filled. It returns ErrSize if
+// p would take the plaintext past the size given to NewWriter.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	if int64(len(p)) > w.header.size-w.written {
+		return 0, ErrSize
+	}
+
+	n := 0
+	for len(p) > 0 {
+		m := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+m]
+		p = p[m:]
+		n += m
+		w.written += int64(m)
+
+		if len(w.buf) == cap(w.buf) || w.written == w.header.size {
+			if err := w.flush(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (w *Writer) flush() error {
+	w.out = w.aead.Seal(w.out[:0], nil, w.buf, chunkData(w.index))
+	w.index++
+	w.buf = w.buf[:0]
+
+	if _, err := w.w.Write(w.out); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
+
+// Close checks that the whole plaintext has been written. It does not close
+// the underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+
+	if w.written != w.header.size {
+		return ErrSize
+	}
+	return nil
+}
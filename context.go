@@ -0,0 +1,96 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+)
+
+// contextLabel begins the domain separation component which precedes the
+// components bound by NewContext.
+const contextLabel = "siv context"
+
+// NewContext returns an AEAD which binds the given associated data components
+// to every Seal and Open, ahead of any per-call associated data. The bound
+// components are preceded by a fixed component, "siv context" followed by
+// their number as a 32-bit big-endian integer, so that a ciphertext sealed
+// through a context never opens without it, nor with a different number of
+// bound components.
+//
+// If aead supports multiple associated data components (as *SIV does), the
+// components are passed as separate S2V inputs, so that NewContext(aead, a,
+// b).Seal(dst, nonce, plaintext, data) is equivalent to SealMulti(dst,
+// plaintext, "siv context\x00\x00\x00\x02", a, b, data, nonce). Otherwise,
+// including for the AEADs New returns when given any options, the components
+// and the per-call data are each prefixed with their 32-bit big-endian length
+// and concatenated into a single associated data value, so the two forms
+// aren't interchangeable.
+//
+// Nested contexts compose: NewContext(NewContext(aead, a), b) behaves exactly
+// like NewContext(aead, a, b).
+func NewContext(aead cipher.AEAD, ad ...[]byte) cipher.AEAD {
+	var bound [][]byte
+	if c, ok := aead.(*contextAEAD); ok {
+		aead = c.aead
+		bound = append(bound, c.ad[1:]...)
+	}
+
+	for _, v := range ad {
+		bound = append(bound, append([]byte{}, v...))
+	}
+
+	label := binary.BigEndian.AppendUint32([]byte(contextLabel), uint32(len(bound)))
+	return &contextAEAD{
+		aead: aead,
+		ad:   append([][]byte{label}, bound...),
+	}
+}
+
+// multiAEAD is implemented by AEADs which accept a vector of associated data
+// components.
+type multiAEAD interface {
+	cipher.AEAD
+	SealMulti(dst, plaintext []byte, data ...[]byte) []byte
+	OpenMulti(dst, ciphertext []byte, data ...[]byte) ([]byte, error)
+}
+
+type contextAEAD struct {
+	aead cipher.AEAD
+	ad   [][]byte // the label, then the bound components
+}
+
+func (c *contextAEAD) NonceSize() int {
+	return c.aead.NonceSize()
+}
+
+func (c *contextAEAD) Overhead() int {
+	return c.aead.Overhead()
+}
+
+func (c *contextAEAD) Seal(dst, nonce, plaintext, data []byte) []byte {
+	if m, ok := c.aead.(multiAEAD); ok {
+		return m.SealMulti(dst, plaintext, c.components(data, nonce)...)
+	}
+	return c.aead.Seal(dst, nonce, plaintext, c.concat(data))
+}
+
+func (c *contextAEAD) Open(dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	if m, ok := c.aead.(multiAEAD); ok {
+		return m.OpenMulti(dst, ciphertext, c.components(data, nonce)...)
+	}
+	return c.aead.Open(dst, nonce, ciphertext, c.concat(data))
+}
+
+func (c *contextAEAD) components(data ...[]byte) [][]byte {
+	v := make([][]byte, 0, len(c.ad)+len(data))
+	v = append(v, c.ad...)
+	return append(v, data...)
+}
+
+func (c *contextAEAD) concat(data []byte) []byte {
+	var b []byte
+	for _, v := range c.components(data) {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(v)))
+		b = append(b, v...)
+	}
+	return b
+}
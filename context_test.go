@@ -0,0 +1,165 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"testing"
+)
+
+func TestContextMatchesSealMulti(t *testing.T) {
+	key, _ := hex.DecodeString("7f7e7d7c7b7a79787776757473727170404142434445464748494a4b4c4d4e4f")
+	ad1, _ := hex.DecodeString("00112233445566778899aabbccddeeffdeaddadadeaddadaffeeddccbbaa99887766554433221100")
+	ad2, _ := hex.DecodeString("102030405060708090a0")
+	nonce, _ := hex.DecodeString("09f911029d74e35bd84156c5635688c0")
+	plaintext, _ := hex.DecodeString("7468697320697320736f6d6520706c61696e7465787420746f20656e6372797074207573696e67205349562d414553")
+
+	aead, err := New(key, aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	label := []byte("siv context\x00\x00\x00\x01")
+	ciphertext := aead.(multiAEAD).SealMulti(nil, plaintext, label, ad1, ad2, nonce)
+
+	actual := NewContext(aead, ad1).Seal(nil, nonce, plaintext, ad2)
+	if !bytes.Equal(actual, ciphertext) {
+		t.Errorf("Ciphertext was %x, but expected %x", actual, ciphertext)
+	}
+
+	actual, err = NewContext(aead, ad1).Open(nil, nonce, ciphertext, ad2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(actual, plaintext) {
+		t.Errorf("Plaintext was %x, but expected %x", actual, plaintext)
+	}
+}
+
+func TestContextNesting(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	plaintext := []byte("yay")
+
+	nested := NewContext(NewContext(aead, []byte("service")), []byte("table"), []byte("column"))
+	flat := NewContext(aead, []byte("service"), []byte("table"), []byte("column"))
+
+	a := nested.Seal(nil, nil, plaintext, []byte("row"))
+	b := flat.Seal(nil, nil, plaintext, []byte("row"))
+	if !bytes.Equal(a, b) {
+		t.Errorf("Nested ciphertext was %x, but expected %x", a, b)
+	}
+}
+
+func TestContextBoundDataIsCopied(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	ad := []byte("service")
+
+	c := NewContext(aead, ad)
+	ciphertext := c.Seal(nil, nil, []byte("yay"), nil)
+
+	ad[0] ^= 1
+
+	if _, err := c.Open(nil, nil, ciphertext, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContextMixedUsage(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	c := NewContext(aead, []byte("service"))
+	plaintext := []byte("yay")
+	data := []byte("row")
+
+	ciphertext := c.Seal(nil, nil, plaintext, data)
+	if actual, err := aead.Open(nil, nil, ciphertext, data); err == nil {
+		t.Errorf("Plaintext returned instead of error: %x", actual)
+	}
+
+	ciphertext = aead.Seal(nil, nil, plaintext, data)
+	if actual, err := c.Open(nil, nil, ciphertext, data); err == nil {
+		t.Errorf("Plaintext returned instead of error: %x", actual)
+	}
+
+	other := NewContext(aead, []byte("other"))
+	ciphertext = c.Seal(nil, nil, plaintext, data)
+	if actual, err := other.Open(nil, nil, ciphertext, data); err == nil {
+		t.Errorf("Plaintext returned instead of error: %x", actual)
+	}
+}
+
+func TestContextWithoutData(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	c := NewContext(aead, []byte("service"))
+	plaintext := []byte("yay")
+
+	// A context with no per-call data is not the same as passing its bound
+	// component as the per-call data.
+	a := c.Seal(nil, nil, plaintext, nil)
+	b := aead.Seal(nil, nil, plaintext, []byte("service"))
+	if bytes.Equal(a, b) {
+		t.Errorf("Ciphertext was %x, but expected it to differ", a)
+	}
+
+	if actual, err := aead.Open(nil, nil, a, []byte("service")); err == nil {
+		t.Errorf("Plaintext returned instead of error: %x", actual)
+	}
+
+	if actual, err := c.Open(nil, nil, b, nil); err == nil {
+		t.Errorf("Plaintext returned instead of error: %x", actual)
+	}
+}
+
+func TestContextConfigured(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher, WithRequiredAD())
+	plaintext := []byte("yay")
+
+	// AEADs with options don't support multiple components, so the bound
+	// components are concatenated rather than passed to S2V separately.
+	c := NewContext(aead, []byte("service"))
+	ciphertext := c.Seal(nil, nil, plaintext, []byte("row"))
+
+	data, _ := hex.DecodeString("0000000f73697620636f6e7465787400000001" +
+		"000000077365727669636500000003726f77")
+	actual, err := aead.Open(nil, nil, ciphertext, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(actual, plaintext) {
+		t.Errorf("Plaintext was %x, but expected %x", actual, plaintext)
+	}
+
+	plain, _ := New(testKey(0, 32), aes.NewCipher)
+	if actual, err := NewContext(plain, []byte("service")).Open(nil, nil, ciphertext, []byte("row")); err == nil {
+		t.Errorf("Plaintext returned instead of error: %x", actual)
+	}
+}
+
+func TestContextConcatenation(t *testing.T) {
+	block, _ := aes.NewCipher(make([]byte, 16))
+	gcm, _ := cipher.NewGCM(block)
+	nonce := make([]byte, gcm.NonceSize())
+	plaintext := []byte("yay")
+
+	c := NewContext(gcm, []byte("service"), []byte("table"))
+	ciphertext := c.Seal(nil, nonce, plaintext, []byte("row"))
+
+	data, _ := hex.DecodeString("0000000f73697620636f6e7465787400000002" +
+		"0000000773657276696365000000057461626c6500000003726f77")
+	actual, err := gcm.Open(nil, nonce, ciphertext, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(actual, plaintext) {
+		t.Errorf("Plaintext was %x, but expected %x", actual, plaintext)
+	}
+
+	// Component boundaries are preserved.
+	shifted := NewContext(gcm, []byte("servicet"), []byte("able"))
+	if actual, err := shifted.Open(nil, nonce, ciphertext, []byte("row")); err == nil {
+		t.Errorf("Plaintext returned instead of error: %x", actual)
+	}
+}
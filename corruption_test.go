@@ -0,0 +1,76 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"testing"
+)
+
+// TestCorruptionMatrix checks that every single-bit flip, truncation, and
+// extension of a handful of ciphertexts fails to open with ErrAuthentication,
+// without panicking, and leaves dst's existing contents alone and its output
+// region zeroed.
+func TestCorruptionMatrix(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+
+	for _, ptLen := range []int{0, 1, 15, 16, 17, 32, 33} {
+		for _, adLen := range []int{0, 15, 16, 17} {
+			plaintext := bytes.Repeat([]byte{'p'}, ptLen)
+			ad := bytes.Repeat([]byte{'a'}, adLen)
+			ciphertext := aead.Seal(nil, nil, plaintext, ad)
+
+			check := func(kind string, i int, mutated []byte) {
+				t.Helper()
+
+				prefix := []byte("prefix")
+				dst := make([]byte, len(prefix), len(prefix)+len(mutated)+16)
+				copy(dst, prefix)
+				spare := dst[len(dst):cap(dst)]
+				for j := range spare {
+					spare[j] = 0xaa
+				}
+
+				var actual []byte
+				var err error
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							t.Fatalf("pt=%d ad=%d %s %d: panic: %v", ptLen, adLen, kind, i, r)
+						}
+					}()
+					actual, err = aead.Open(dst, nil, mutated, ad)
+				}()
+
+				if err != ErrAuthentication {
+					t.Fatalf("pt=%d ad=%d %s %d: Plaintext returned instead of error: %x", ptLen, adLen, kind, i, actual)
+				}
+
+				if !bytes.Equal(dst, prefix) {
+					t.Fatalf("pt=%d ad=%d %s %d: dst was %x, but expected %x", ptLen, adLen, kind, i, dst, prefix)
+				}
+
+				// The output region is wiped once the tag has been checked.
+				if len(mutated) >= aead.Overhead() {
+					out := spare[:len(mutated)-aead.Overhead()]
+					if !bytes.Equal(out, make([]byte, len(out))) {
+						t.Fatalf("pt=%d ad=%d %s %d: Output region was %x after failure", ptLen, adLen, kind, i, out)
+					}
+				}
+			}
+
+			for i := 0; i < len(ciphertext)*8; i++ {
+				mutated := append([]byte{}, ciphertext...)
+				mutated[i/8] ^= 1 << (i % 8)
+				check("flip", i, mutated)
+			}
+
+			for i := 0; i < len(ciphertext); i++ {
+				check("truncate", i, append([]byte{}, ciphertext[:i]...))
+			}
+
+			for _, extra := range [][]byte{{0}, {0xff}, make([]byte, 16), ciphertext} {
+				check("extend", len(extra), append(append([]byte{}, ciphertext...), extra...))
+			}
+		}
+	}
+}
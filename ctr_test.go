@@ -0,0 +1,161 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"math"
+	"math/big"
+	"math/bits"
+	"math/rand"
+	"testing"
+)
+
+func keystream(s *SIV, v []byte, n int) []byte {
+	out := make([]byte, n)
+	cipher.NewCTR(s.enc, ctr(v)).XORKeyStream(out, out)
+	return out
+}
+
+func ctrTestSIV(t *testing.T) *SIV {
+	s, err := NewSIV(testKey(0x5a, 32), aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestCTRClamping(t *testing.T) {
+	s := ctrTestSIV(t)
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 100; i++ {
+		v := make([]byte, blockSize)
+		r.Read(v)
+		orig := append([]byte{}, v...)
+		expected := keystream(s, v, 4*blockSize)
+
+		if !bytes.Equal(v, orig) {
+			t.Fatalf("ctr modified the tag: %x, but expected %x", v, orig)
+		}
+
+		// RFC 5297 section 2.5 clears bits 63 and 31 of the tag, so tags
+		// differing only in those bits share a keystream.
+		for _, mask := range [][2]byte{{0x80, 0}, {0, 0x80}, {0x80, 0x80}} {
+			w := append([]byte{}, v...)
+			w[8] ^= mask[0]
+			w[12] ^= mask[1]
+			if actual := keystream(s, w, 4*blockSize); !bytes.Equal(actual, expected) {
+				t.Errorf("Keystream for %x was %x, but expected %x", w, actual, expected)
+			}
+		}
+
+		// Any other bit changes the whole keystream.
+		for bit := 0; bit < 8*blockSize; bit++ {
+			if bit == 8*8 || bit == 12*8 {
+				continue
+			}
+			w := append([]byte{}, v...)
+			w[bit/8] ^= 0x80 >> (bit % 8)
+			actual := keystream(s, w, 4*blockSize)
+			for j := 0; j < len(actual); j += blockSize {
+				if bytes.Equal(actual[j:j+blockSize], expected[j:j+blockSize]) {
+					t.Fatalf("Bit %d: keystream block %d was unchanged", bit, j/blockSize)
+				}
+			}
+		}
+	}
+}
+
+func TestCTRCounter(t *testing.T) {
+	s := ctrTestSIV(t)
+	modulus := new(big.Int).Lsh(big.NewInt(1), 128)
+
+	for _, v := range [][]byte{
+		make([]byte, blockSize),
+		bytes.Repeat([]byte{0xff}, blockSize),
+		{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0xff, 0xff, 0xff, 0xfe},
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f, 0xff, 0xff, 0xff},
+	} {
+		// Block i of the keystream is E(Q + i mod 2^128), where Q is the
+		// tag with bits 63 and 31 cleared, so the counter carries into the
+		// cleared bits.
+		q := append([]byte{}, v...)
+		q[8] &= 0x7f
+		q[12] &= 0x7f
+		counter := new(big.Int).SetBytes(q)
+
+		actual := keystream(s, v, 4*blockSize)
+		for i := 0; i < 4; i++ {
+			block := make([]byte, blockSize)
+			c := new(big.Int).Mod(new(big.Int).Add(counter, big.NewInt(int64(i))), modulus)
+			c.FillBytes(block)
+			s.enc.Encrypt(block, block)
+
+			if !bytes.Equal(actual[i*blockSize:(i+1)*blockSize], block) {
+				t.Errorf("%x: keystream block %d was %x, but expected %x", v, i, actual[i*blockSize:(i+1)*blockSize], block)
+			}
+		}
+	}
+}
+
+func TestKeystreamCollisions(t *testing.T) {
+	s := ctrTestSIV(t)
+	r := rand.New(rand.NewSource(2))
+	seen := make(map[[blockSize]byte]int)
+
+	for i := 0; i < 20000; i++ {
+		plaintext := make([]byte, blockSize+r.Intn(64))
+		r.Read(plaintext)
+		ciphertext := s.Seal(nil, nil, plaintext, nil)
+
+		var prefix [blockSize]byte
+		for j := range prefix {
+			prefix[j] = ciphertext[blockSize+j] ^ plaintext[j]
+		}
+		if j, ok := seen[prefix]; ok {
+			t.Fatalf("Messages %d and %d share the keystream prefix %x", j, i, prefix)
+		}
+		seen[prefix] = i
+	}
+}
+
+func TestKeystreamDistribution(t *testing.T) {
+	s := ctrTestSIV(t)
+	r := rand.New(rand.NewSource(3))
+
+	// 1 MiB of keystream, from 64 messages.
+	var stream []byte
+	for i := 0; i < 64; i++ {
+		v := make([]byte, blockSize)
+		r.Read(v)
+		stream = append(stream, keystream(s, v, 16<<10)...)
+	}
+
+	// Monobit: the count of one bits should be within a few standard
+	// deviations of half. Eight is loose enough never to fail by chance.
+	var ones int
+	for _, b := range stream {
+		ones += bits.OnesCount8(b)
+	}
+	n := float64(8 * len(stream))
+	if z := math.Abs(float64(ones)-n/2) / (math.Sqrt(n) / 2); z > 8 {
+		t.Errorf("Keystream had %d one bits of %.0f (z = %.1f)", ones, n, z)
+	}
+
+	// Chi-square over byte values, with 255 degrees of freedom: a mean of
+	// 255 and a standard deviation of about 22.6. The bounds are more than
+	// seven standard deviations away, so only gross breakage fails.
+	var counts [256]float64
+	for _, b := range stream {
+		counts[b]++
+	}
+	expected := float64(len(stream)) / 256
+	var chi2 float64
+	for _, c := range counts {
+		chi2 += (c - expected) * (c - expected) / expected
+	}
+	if chi2 < 90 || chi2 > 420 {
+		t.Errorf("Chi-square statistic of keystream bytes was %.1f", chi2)
+	}
+}
@@ -0,0 +1,75 @@
+// Package cursor seals API pagination cursors into opaque tokens, so that
+// clients can't read or tamper with them.
+//
+// A cursor is any value which encoding/json can encode. Its JSON encoding is
+// sealed with siv.SealString, with associated data, such as the name of the
+// endpoint which issued it, bound so that a token issued by one endpoint
+// can't be replayed against another. As SIV is deterministic, the same cursor
+// always gives the same token.
+package cursor
+
+import (
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	siv "github.com/stripe/siv-go"
+)
+
+// MaxSize is the largest cursor, in bytes of JSON, which can be encoded or
+// decoded. Larger cursors make for unwieldy URLs, and limiting them bounds the
+// work done decoding a token supplied by a client.
+const MaxSize = 1024
+
+var (
+	// ErrTooLarge is returned for a cursor or token which exceeds MaxSize.
+	ErrTooLarge = errors.New("cursor: cursor too large")
+
+	// ErrInvalid is returned by Decode for a token which is malformed, fails
+	// to authenticate, or was issued with different associated data.
+	ErrInvalid = errors.New("cursor: invalid token")
+)
+
+// maxTokenSize is the length of the token for a cursor of MaxSize bytes.
+var maxTokenSize = base64.RawURLEncoding.EncodedLen(MaxSize + 16)
+
+// bind returns aead with the cursor label bound to it, so that tokens can't
+// be confused with other values sealed under the same key.
+func bind(aead cipher.AEAD) cipher.AEAD {
+	return siv.NewContext(aead, []byte("siv cursor"))
+}
+
+// Encode encodes v as JSON, seals it with ad, and returns the token as
+// unpadded base64url. It returns ErrTooLarge if the JSON exceeds MaxSize.
+func Encode(aead cipher.AEAD, v any, ad []byte) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("cursor: %w", err)
+	}
+	if len(b) > MaxSize {
+		return "", ErrTooLarge
+	}
+	return siv.SealString(bind(aead), b, ad), nil
+}
+
+// Decode opens token with ad and decodes the cursor into v, as with
+// json.Unmarshal, so that fields unknown to v are ignored and cursors issued
+// by newer code still decode. It returns ErrTooLarge if the token is too long
+// to hold a cursor of MaxSize bytes, and ErrInvalid if it fails to open.
+func Decode(aead cipher.AEAD, token string, ad []byte, v any) error {
+	if len(token) > maxTokenSize {
+		return ErrTooLarge
+	}
+
+	b, err := siv.OpenString(bind(aead), token, ad)
+	if err != nil {
+		return ErrInvalid
+	}
+
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("cursor: %w", err)
+	}
+	return nil
+}
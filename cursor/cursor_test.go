@@ -0,0 +1,176 @@
+package cursor
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	siv "github.com/stripe/siv-go"
+)
+
+type page struct {
+	After   string    `json:"after"`
+	Limit   int       `json:"limit"`
+	Created time.Time `json:"created"`
+}
+
+// pageV2 is a later version of page, with an extra field.
+type pageV2 struct {
+	page
+	Filter string `json:"filter"`
+}
+
+func testAEAD(t *testing.T) cipher.AEAD {
+	aead, err := siv.New(bytes.Repeat([]byte{1}, 32), aes.NewCipher, siv.AllowEqualHalves())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+var (
+	charges   = []byte("GET /v1/charges")
+	customers = []byte("GET /v1/customers")
+	cursor    = page{After: "ch_123", Limit: 10, Created: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+)
+
+func TestRoundTrip(t *testing.T) {
+	aead := testAEAD(t)
+
+	token, err := Encode(aead, cursor, charges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(token, "ch_123") || strings.ContainsAny(token, "+/=") {
+		t.Errorf("Token was %q", token)
+	}
+	if b, _ := base64.RawURLEncoding.DecodeString(token); bytes.Contains(b, []byte("ch_123")) {
+		t.Errorf("Token contains the cursor: %q", b)
+	}
+
+	var actual page
+	if err := Decode(aead, token, charges, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, cursor) {
+		t.Errorf("Cursor was %+v, but expected %+v", actual, cursor)
+	}
+}
+
+func TestEndpointBinding(t *testing.T) {
+	aead := testAEAD(t)
+	token, _ := Encode(aead, cursor, charges)
+
+	var actual page
+	if err := Decode(aead, token, customers, &actual); err != ErrInvalid {
+		t.Errorf("Error was %v, but expected %v (cursor %+v)", err, ErrInvalid, actual)
+	}
+
+	// Nor do tokens decode as other values sealed under the same key.
+	sealed := siv.SealString(aead, []byte(`{"after":"ch_999"}`), charges)
+	if err := Decode(aead, sealed, charges, &actual); err != ErrInvalid {
+		t.Errorf("Error was %v, but expected %v (cursor %+v)", err, ErrInvalid, actual)
+	}
+}
+
+func TestTampering(t *testing.T) {
+	aead := testAEAD(t)
+	token, _ := Encode(aead, cursor, charges)
+
+	for _, tampered := range []string{
+		"",
+		token[:len(token)-1],
+		token + "A",
+		token[:10] + "!" + token[11:],
+		strings.Replace(token, token[:1], string(token[0]^1), 1),
+	} {
+		var actual page
+		if err := Decode(aead, tampered, charges, &actual); err != ErrInvalid {
+			t.Errorf("Error was %v, but expected %v (cursor %+v)", err, ErrInvalid, actual)
+		}
+	}
+}
+
+func TestSize(t *testing.T) {
+	aead := testAEAD(t)
+
+	// The JSON is {"after":"..."...}, so the largest cursor has a shorter
+	// After.
+	overhead := len(`{"after":"","limit":0,"created":"0001-01-01T00:00:00Z"}`)
+	largest := page{After: strings.Repeat("a", MaxSize-overhead)}
+	token, err := Encode(aead, largest, charges)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var actual page
+	if err := Decode(aead, token, charges, &actual); err != nil || actual != largest {
+		t.Errorf("Error was %v", err)
+	}
+
+	largest.After += "a"
+	if _, err := Encode(aead, largest, charges); err != ErrTooLarge {
+		t.Errorf("Error was %v, but expected %v", err, ErrTooLarge)
+	}
+
+	if err := Decode(aead, token+"AAAA", charges, &actual); err != ErrTooLarge {
+		t.Errorf("Error was %v, but expected %v", err, ErrTooLarge)
+	}
+	if err := Decode(aead, strings.Repeat("A", 1<<20), charges, &actual); err != ErrTooLarge {
+		t.Errorf("Error was %v, but expected %v", err, ErrTooLarge)
+	}
+}
+
+func TestForwardsCompatibility(t *testing.T) {
+	aead := testAEAD(t)
+
+	// A cursor issued by newer code, with a field this code doesn't know.
+	token, err := Encode(aead, pageV2{page: cursor, Filter: "status:paid"}, charges)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var actual page
+	if err := Decode(aead, token, charges, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(actual, cursor) {
+		t.Errorf("Cursor was %+v, but expected %+v", actual, cursor)
+	}
+
+	// And the other way round.
+	old, _ := Encode(aead, cursor, charges)
+	var v2 pageV2
+	if err := Decode(aead, old, charges, &v2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v2, pageV2{page: cursor}) {
+		t.Errorf("Cursor was %+v, but expected %+v", v2, pageV2{page: cursor})
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	aead := testAEAD(t)
+
+	a, _ := Encode(aead, cursor, charges)
+	b, _ := Encode(aead, cursor, charges)
+	if a != b {
+		t.Errorf("Tokens were %q and %q, but expected them to be equal", a, b)
+	}
+}
+
+func TestEncodeError(t *testing.T) {
+	if _, err := Encode(testAEAD(t), func() {}, charges); err == nil {
+		t.Error("No error for a value which can't be encoded")
+	}
+
+	token, _ := Encode(testAEAD(t), []int{1}, charges)
+	var actual page
+	if err := Decode(testAEAD(t), token, charges, &actual); err == nil || err == ErrInvalid {
+		t.Errorf("Error was %v, but expected a JSON error", err)
+	}
+}
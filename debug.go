@@ -0,0 +1,131 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"fmt"
+
+	"github.com/ebfe/cmac"
+)
+
+// Diagnosis describes why a ciphertext did or did not open.
+type Diagnosis struct {
+	CiphertextLen int    // the length of the ciphertext, including the tag
+	Overhead      int    // the length of the tag
+	TooShort      bool   // whether the ciphertext was shorter than the tag
+	TagMismatch   bool   // whether the recomputed tag differed
+	Tag           []byte // the tag carried by the ciphertext
+	ComputedTag   []byte // the tag recomputed from the decrypted plaintext
+	Fingerprint   []byte // the fingerprint of the key used
+
+	// KeyID is the ID of the keyring key which authenticated the
+	// ciphertext, set only by Keyring.OpenDebug.
+	KeyID string
+
+	// TriedKeyIDs are the IDs of the keyring keys tried, in order, set only
+	// by Keyring.OpenDebug.
+	TriedKeyIDs []string
+}
+
+func (d Diagnosis) String() string {
+	var s string
+	switch {
+	case d.TooShort:
+		s = fmt.Sprintf("ciphertext too short (%d bytes, tag is %d) under key %x", d.CiphertextLen, d.Overhead, d.Fingerprint)
+	case d.TagMismatch:
+		s = fmt.Sprintf("tag mismatch (got %x, computed %x) for %d-byte ciphertext under key %x", d.Tag, d.ComputedTag, d.CiphertextLen, d.Fingerprint)
+	default:
+		s = fmt.Sprintf("authenticated %d-byte ciphertext under key %x", d.CiphertextLen, d.Fingerprint)
+	}
+
+	switch {
+	case d.KeyID != "":
+		s += fmt.Sprintf(" (key ID %q)", d.KeyID)
+	case len(d.TriedKeyIDs) > 0:
+		s += fmt.Sprintf(" (tried key IDs %q)", d.TriedKeyIDs)
+	}
+	return s
+}
+
+// OpenDebug opens ciphertext as Open does, but also returns a Diagnosis of the
+// attempt for use in tests and forensic tooling.
+//
+// OpenDebug is not safe for production decisions: the diagnosis reveals the
+// recomputed tag for a failed ciphertext, and its fields take variable time to
+// compute. Production code should use Open, which reports every failure as
+// ErrAuthentication and nothing more.
+func OpenDebug(aead cipher.AEAD, dst, nonce, ciphertext, data []byte) ([]byte, Diagnosis, error) {
+	d := Diagnosis{
+		CiphertextLen: len(ciphertext),
+		Overhead:      aead.Overhead(),
+	}
+
+	s, ok := aead.(*SIV)
+	if !ok {
+		plaintext, err := aead.Open(dst, nonce, ciphertext, data)
+		d.TooShort = len(ciphertext) < aead.Overhead()
+		d.TagMismatch = err != nil && !d.TooShort
+		return plaintext, d, err
+	}
+
+	d.Fingerprint = s.Fingerprint()
+	if len(ciphertext) < s.Overhead() {
+		d.TooShort = true
+		return nil, d, ErrAuthentication
+	}
+
+	v, c := ciphertext[:s.Overhead()], ciphertext[s.Overhead():]
+	plaintext := make([]byte, len(c))
+	cipher.NewCTR(s.enc, ctr(v)).XORKeyStream(plaintext, c)
+
+	h, _ := cmac.NewWithCipher(s.mac)
+	d.Tag = append([]byte{}, v...)
+	d.ComputedTag = s2v(h, components([][]byte{data, nonce}, plaintext)...)
+
+	if subtle.ConstantTimeCompare(d.Tag, d.ComputedTag) != 1 {
+		d.TagMismatch = true
+		return nil, d, ErrAuthentication
+	}
+
+	return append(dst, plaintext...), d, nil
+}
+
+// OpenDebug opens ciphertext as Open does, but also returns a Diagnosis of the
+// attempt, as the package's OpenDebug does, with the ID of the key which
+// authenticated it and the IDs of every key tried. If no key authenticates
+// it, the rest of the Diagnosis describes the attempt with the primary key.
+//
+// Like the package's OpenDebug, it is for tests and forensic tooling, not for
+// production decisions.
+func (k *Keyring) OpenDebug(dst, nonce, ciphertext, data []byte) ([]byte, Diagnosis, error) {
+	k.mu.RLock()
+	keys, revoked := k.ordered()
+	k.mu.RUnlock()
+
+	var first Diagnosis
+	var tried []string
+	for i, e := range append(keys, revoked...) {
+		tried = append(tried, e.id)
+
+		// Each attempt opens into scratch space, so that a failed one
+		// doesn't clobber a ciphertext being opened in place.
+		plaintext, d, err := OpenDebug(e.aead, nil, nonce, ciphertext, data)
+		if i == 0 {
+			first = d
+		}
+		if err != nil {
+			continue
+		}
+
+		d.KeyID, d.TriedKeyIDs = e.id, tried
+		if i >= len(keys) {
+			wipe(plaintext)
+			return nil, d, &RevokedKeyError{ID: e.id, Fingerprint: fingerprintOf(e.aead)}
+		}
+		defer wipe(plaintext)
+		return append(dst, plaintext...), d, nil
+	}
+
+	first.TriedKeyIDs = tried
+	return nil, first, ErrAuthentication
+}
@@ -0,0 +1,151 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOpenDebug(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	ciphertext := aead.Seal(nil, nil, []byte("yay"), []byte("ad"))
+
+	plaintext, d, err := OpenDebug(aead, nil, nil, ciphertext, []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(plaintext) != "yay" {
+		t.Errorf("Plaintext was %q, but expected %q", plaintext, "yay")
+	}
+
+	if d.TooShort || d.TagMismatch || d.CiphertextLen != 19 || !bytes.Equal(d.Tag, d.ComputedTag) {
+		t.Errorf("Diagnosis was %+v", d)
+	}
+
+	if !bytes.Equal(d.Fingerprint, aead.(*SIV).Fingerprint()) {
+		t.Errorf("Fingerprint was %x, but expected %x", d.Fingerprint, aead.(*SIV).Fingerprint())
+	}
+}
+
+func TestOpenDebugTooShort(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+
+	_, d, err := OpenDebug(aead, nil, nil, make([]byte, 10), nil)
+	if err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+
+	if !d.TooShort || d.TagMismatch || d.CiphertextLen != 10 || d.Overhead != 16 {
+		t.Errorf("Diagnosis was %+v", d)
+	}
+
+	if s := d.String(); !strings.Contains(s, "too short") {
+		t.Errorf("Diagnosis string was %q", s)
+	}
+}
+
+func TestOpenDebugTagMismatch(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	ciphertext := aead.Seal(nil, nil, []byte("yay"), []byte("ad"))
+
+	_, d, err := OpenDebug(aead, nil, nil, ciphertext, []byte("other"))
+	if err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+
+	if d.TooShort || !d.TagMismatch || !bytes.Equal(d.Tag, ciphertext[:16]) || bytes.Equal(d.Tag, d.ComputedTag) {
+		t.Errorf("Diagnosis was %+v", d)
+	}
+
+	if s := d.String(); !strings.Contains(s, "tag mismatch") {
+		t.Errorf("Diagnosis string was %q", s)
+	}
+}
+
+func TestOpenDebugOtherAEAD(t *testing.T) {
+	block, _ := aes.NewCipher(make([]byte, 16))
+	gcm, _ := cipher.NewGCM(block)
+	nonce := make([]byte, gcm.NonceSize())
+
+	_, d, err := OpenDebug(gcm, nil, nonce, make([]byte, 20), nil)
+	if err == nil || !d.TagMismatch || d.Fingerprint != nil {
+		t.Errorf("Diagnosis was %+v (error %v)", d, err)
+	}
+}
+
+func TestKeyringOpenDebug(t *testing.T) {
+	k, aeads := testKeyring(t, "a", "b", "c")
+	if err := k.SetState("c", KeyRevoked); err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext := aeads["b"].Seal(nil, nil, []byte("yay"), []byte("ad"))
+	plaintext, d, err := k.OpenDebug(ciphertext[:0], nil, ciphertext, []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plaintext) != "yay" {
+		t.Errorf("Plaintext was %q, but expected %q", plaintext, "yay")
+	}
+	if d.KeyID != "b" || !reflect.DeepEqual(d.TriedKeyIDs, []string{"a", "b"}) || d.TagMismatch {
+		t.Errorf("Diagnosis was %+v", d)
+	}
+	if s := d.String(); !strings.Contains(s, `key ID "b"`) {
+		t.Errorf("Diagnosis string was %q", s)
+	}
+
+	revoked := aeads["c"].Seal(nil, nil, []byte("yay"), nil)
+	if _, d, err := k.OpenDebug(nil, nil, revoked, nil); !errors.Is(err, ErrKeyRevoked) || d.KeyID != "c" {
+		t.Errorf("Diagnosis was %+v (error %v)", d, err)
+	}
+
+	_, d, err = k.OpenDebug(nil, nil, ciphertext, []byte("other"))
+	if err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+	if d.KeyID != "" || !reflect.DeepEqual(d.TriedKeyIDs, []string{"a", "b", "c"}) || !d.TagMismatch {
+		t.Errorf("Diagnosis was %+v", d)
+	}
+	if !bytes.Equal(d.Fingerprint, aeads["a"].(*SIV).Fingerprint()) {
+		t.Errorf("Fingerprint was %x, but expected the primary's %x", d.Fingerprint, aeads["a"].(*SIV).Fingerprint())
+	}
+	if s := d.String(); !strings.Contains(s, `tried key IDs ["a" "b" "c"]`) {
+		t.Errorf("Diagnosis string was %q", s)
+	}
+}
+
+func TestOpenErrorsAreOpaque(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	ciphertext := aead.Seal(nil, nil, []byte("yay"), []byte("ad"))
+	fingerprint := fmt.Sprintf("%x", aead.(*SIV).Fingerprint())
+
+	for _, c := range [][]byte{
+		nil,
+		ciphertext[:10],
+		ciphertext[:16],
+		append([]byte{1}, ciphertext[1:]...),
+		ciphertext,
+	} {
+		_, err := aead.Open(nil, nil, c, []byte("other"))
+		if err == nil {
+			t.Fatal("Plaintext returned instead of error")
+		}
+
+		msg := err.Error()
+		if msg != "message authentication failed" {
+			t.Errorf("Error was %q", msg)
+		}
+
+		for _, leak := range []string{fingerprint, fmt.Sprintf("%x", ciphertext[:16]), "short", "tag", fmt.Sprint(len(c))} {
+			if strings.Contains(msg, leak) {
+				t.Errorf("Error %q leaks %q", msg, leak)
+			}
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package siv
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+)
+
+// ErrEmptyPath is returned by DeriveAEADPath when no path elements are given.
+var ErrEmptyPath = errors.New("siv: empty derivation path")
+
+// derivePathLabel prefixes the PRF input of every derivation step.
+const derivePathLabel = "siv-go derive path"
+
+// DeriveAEADPath derives an AES-SIV-CMAC-256 AEAD from root for the given
+// path, e.g. DeriveAEADPath(root, "payments", "cards", "pan").
+//
+// Each path element is one step of an HMAC-SHA256 chain, starting with root as
+// the key:
+//
+//	k = HMAC-SHA256(k, "siv-go derive path" || uint32be(len(element)) || element)
+//
+// and the final 32-byte k is the SIV key. As every element is length-prefixed
+// and consumes a step, ("a", "bc"), ("ab", "c"), and ("abc") all derive
+// unrelated keys. A derived key reveals nothing about its parent or siblings,
+// but it does allow anyone holding it to derive its descendants.
+func DeriveAEADPath(root []byte, path ...string) (cipher.AEAD, error) {
+	if len(path) == 0 {
+		return nil, ErrEmptyPath
+	}
+
+	key := derivePath(root, path)
+	defer wipe(key)
+	return New(key, aes.NewCipher)
+}
+
+func derivePath(root []byte, path []string) []byte {
+	key := append([]byte{}, root...)
+	for _, e := range path {
+		mac := hmac.New(sha256.New, key)
+		_, _ = mac.Write([]byte(derivePathLabel))
+		_ = binary.Write(mac, binary.BigEndian, uint32(len(e)))
+		_, _ = mac.Write([]byte(e))
+
+		wipe(key)
+		key = mac.Sum(nil)
+	}
+	return key
+}
@@ -0,0 +1,85 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestDerivePathVectors(t *testing.T) {
+	root, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f")
+
+	for _, v := range []struct {
+		path     []string
+		expected string
+	}{
+		{[]string{"payments"}, "1be52757752199f8bf12610a00c4a4981c58f17a3ee15e1e59305a45f212e2f2"},
+		{[]string{"payments", "cards", "pan"}, "e832c1ecf0fafdf6dcde1eddfed669085ec3f39d2d23e7dcd4758bea3426c31c"},
+		{[]string{"payments", "cards", "cvc"}, "a082f6963a194d2a6f62ca6bd6095b6eef0686f0be683767c8ad0288070eaec6"},
+		{[]string{""}, "774d95c4cef8446b79d341b05d4770fbd71c2cb5243acfafa9c8f065ab6e11e2"},
+		{[]string{"", ""}, "c11cee33682eb1123985d4038de279a71d8555d2a2238a4225f4e26a746bee8e"},
+		{[]string{"a", "bc"}, "c2569e2dbb5f54d66a27785708e9484928ec0fce624a3a1f72e165a8b622b0d2"},
+		{[]string{"ab", "c"}, "8e099692d7060f1e341357aa2ae211dfb12cdf9d0add554db2f4cb4d153405c0"},
+		{[]string{"abc"}, "8a6c3451701ef63412225138f65525068720f438bb042b521889ae5cf442ea7e"},
+	} {
+		key := derivePath(root, v.path)
+		if actual := hex.EncodeToString(key); actual != v.expected {
+			t.Errorf("%q: Key was %s, but expected %s", v.path, actual, v.expected)
+		}
+
+		derived, err := DeriveAEADPath(root, v.path...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected, _ := New(key, aes.NewCipher)
+		if a, b := derived.Seal(nil, nil, nil, nil), expected.Seal(nil, nil, nil, nil); !bytes.Equal(a, b) {
+			t.Errorf("%q: Ciphertext was %x, but expected %x", v.path, a, b)
+		}
+	}
+}
+
+func TestDerivePathIndependence(t *testing.T) {
+	root := bytes.Repeat([]byte{1}, 32)
+
+	pan, _ := DeriveAEADPath(root, "payments", "cards", "pan")
+	cvc, _ := DeriveAEADPath(root, "payments", "cards", "cvc")
+
+	ciphertext := pan.Seal(nil, nil, []byte("4242424242424242"), nil)
+	if p, err := cvc.Open(nil, nil, ciphertext, nil); err == nil {
+		t.Fatalf("Plaintext returned instead of error: %x", p)
+	}
+
+	// The path is not a string: joining elements differently changes the key.
+	joined, _ := DeriveAEADPath(root, "payments/cards/pan")
+	if p, err := joined.Open(nil, nil, ciphertext, nil); err == nil {
+		t.Fatalf("Plaintext returned instead of error: %x", p)
+	}
+
+	// A derived key is the root of its own subtree.
+	parent := derivePath(root, []string{"payments", "cards"})
+	if a, b := derivePath(parent, []string{"pan"}), derivePath(root, []string{"payments", "cards", "pan"}); !bytes.Equal(a, b) {
+		t.Errorf("Key was %x, but expected %x", a, b)
+	}
+}
+
+func TestDerivePathDeep(t *testing.T) {
+	root := bytes.Repeat([]byte{1}, 32)
+	path := strings.Split(strings.Repeat("x/", 255)+"x", "/")
+
+	deep, err := DeriveAEADPath(root, path...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shallower, _ := DeriveAEADPath(root, path[1:]...)
+	if a, b := deep.Seal(nil, nil, nil, nil), shallower.Seal(nil, nil, nil, nil); bytes.Equal(a, b) {
+		t.Errorf("Paths of different depths derived the same key")
+	}
+
+	if _, err := DeriveAEADPath(root); err != ErrEmptyPath {
+		t.Errorf("Error was %v, but expected %v", err, ErrEmptyPath)
+	}
+}
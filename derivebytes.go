@@ -0,0 +1,64 @@
+package siv
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+
+	"github.com/ebfe/cmac"
+)
+
+var (
+	// ErrDeriveLength is returned by DeriveBytes for a non-positive length.
+	ErrDeriveLength = errors.New("siv: derived length must be positive")
+
+	// ErrDeriveKey is returned by DeriveBytes for a key which isn't an
+	// AES-SIV key.
+	ErrDeriveKey = errors.New("siv: DeriveBytes key must be 32, 48, or 64 bytes")
+)
+
+// deriveBytesLabel is the first S2V component of every DeriveBytes seed.
+const deriveBytesLabel = "siv-go DeriveBytes v1"
+
+// DeriveBytes derives n pseudorandom bytes, such as an HMAC key, from an
+// AES-SIV master key and a label. Different labels give independent outputs.
+//
+// With the key split into halves K1 and K2, as for SIV, the output is
+//
+//	seed = S2V(K1, "siv-go DeriveBytes v1", label)
+//	out  = AES-CTR(K2, iv = seed with bits 31 and 63 cleared), n bytes of keystream
+//
+// where S2V and the bit clearing are exactly as in RFC 5297 and label is the
+// final S2V component, so the output is the SIV encryption of n zero bytes,
+// except that the plaintext isn't an S2V input. The length is not an input
+// either: a shorter output is always a prefix of a longer one for the same key
+// and label. Include the length in the label where that matters, as when the
+// same label might be used for keys of different sizes.
+//
+// A nil label is the same as an empty one.
+func DeriveBytes(key, label []byte, n int) ([]byte, error) {
+	if n <= 0 {
+		return nil, ErrDeriveLength
+	}
+	switch len(key) {
+	case 32, 48, 64:
+	default:
+		return nil, ErrDeriveKey
+	}
+
+	mac, err := aes.NewCipher(key[:len(key)/2])
+	if err != nil {
+		return nil, err
+	}
+	enc, err := aes.NewCipher(key[len(key)/2:])
+	if err != nil {
+		return nil, err
+	}
+
+	h, _ := cmac.NewWithCipher(mac)
+	seed := s2v(h, []byte(deriveBytesLabel), append([]byte{}, label...))
+
+	out := make([]byte, n)
+	cipher.NewCTR(enc, ctr(seed)).XORKeyStream(out, out)
+	return out, nil
+}
@@ -0,0 +1,94 @@
+package siv
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// The vectors were computed independently with OpenSSL's CMAC and AES-CTR.
+func TestDeriveBytesVectors(t *testing.T) {
+	for _, v := range []struct {
+		key, label string
+		n          int
+		out        string
+	}{
+		{
+			key:   "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+			label: "hmac signing key",
+			n:     32,
+			out:   "cc751d35b41bf44c5aefa6071ff513028c7406f6bbe2d878e970cb3fc5199477",
+		},
+		{
+			key:   "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+			label: "",
+			n:     16,
+			out:   "bbadbe7cbe24744f344a57ba30b1c175",
+		},
+		{
+			key:   "404142434445464748494a4b4c4d4e4f505152535455565758595a5b5c5d5e5f606162636465666768696a6b6c6d6e6f",
+			label: "api key",
+			n:     40,
+			out:   "7bf9cd1c48679374513d3ecc55d0b485e559a196a172913335d9ba76e8c16ae2d3253fbf6c612a62",
+		},
+		{
+			key:   "808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9fa0a1a2a3a4a5a6a7a8a9aaabacadaeafb0b1b2b3b4b5b6b7b8b9babbbcbdbebf",
+			label: "webhook secret",
+			n:     64,
+			out:   "e03ed633fa22fddfe878acfb1f011cc312c725f2a536f7cf35657d71e49de7e96b5ca283944d85c1eac281fca830c983c830f929780ede196b4f2b7e810238c3",
+		},
+		{
+			key:   "808182838485868788898a8b8c8d8e8f909192939495969798999a9b9c9d9e9fa0a1a2a3a4a5a6a7a8a9aaabacadaeafb0b1b2b3b4b5b6b7b8b9babbbcbdbebf",
+			label: "x",
+			n:     1,
+			out:   "f2",
+		},
+	} {
+		key, _ := hex.DecodeString(v.key)
+		expected, _ := hex.DecodeString(v.out)
+
+		actual, err := DeriveBytes(key, []byte(v.label), v.n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(actual, expected) {
+			t.Errorf("DeriveBytes(%s, %q, %d) was %x, but expected %x", v.key, v.label, v.n, actual, expected)
+		}
+	}
+}
+
+func TestDeriveBytesPrefix(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+
+	long, _ := DeriveBytes(key, []byte("label"), 1000)
+	for _, n := range []int{1, 15, 16, 17, 999} {
+		short, _ := DeriveBytes(key, []byte("label"), n)
+		if !bytes.Equal(short, long[:n]) {
+			t.Errorf("%d bytes were %x, but expected %x", n, short, long[:n])
+		}
+	}
+
+	// Labels are independent, and nil is the same as empty.
+	other, _ := DeriveBytes(key, []byte("label2"), 1000)
+	if bytes.Equal(other[:16], long[:16]) {
+		t.Error("Different labels derived the same bytes")
+	}
+	empty, _ := DeriveBytes(key, []byte{}, 16)
+	if none, _ := DeriveBytes(key, nil, 16); !bytes.Equal(none, empty) {
+		t.Errorf("Nil label derived %x, but expected %x", none, empty)
+	}
+}
+
+func TestDeriveBytesErrors(t *testing.T) {
+	key := make([]byte, 32)
+	for _, n := range []int{0, -1} {
+		if out, err := DeriveBytes(key, nil, n); err != ErrDeriveLength {
+			t.Errorf("DeriveBytes(%d) returned %x, %v, but expected %v", n, out, err, ErrDeriveLength)
+		}
+	}
+	for _, size := range []int{0, 16, 33, 128} {
+		if out, err := DeriveBytes(make([]byte, size), nil, 16); err != ErrDeriveKey {
+			t.Errorf("DeriveBytes with a %d-byte key returned %x, %v, but expected %v", size, out, err, ErrDeriveKey)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Package dgram protects datagrams, such as UDP packets, with SIV. Each packet
+// carries a 64-bit sequence number in the clear, bound to its ciphertext, so a
+// receiver can reject replayed packets with a replay.Window while tolerating
+// loss and reordering.
+//
+// A packet is laid out as:
+//
+//	seq (8 bytes, big-endian) || ciphertext
+//
+// where the ciphertext is sealed with the sequence number bound to it as
+// associated data, ahead of the caller's.
+package dgram
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+
+	siv "github.com/stripe/siv-go"
+	"github.com/stripe/siv-go/replay"
+)
+
+const (
+	// MaxPacketSize is the largest packet Seal produces or Open accepts: a
+	// 1500-byte Ethernet MTU less 40 bytes of IPv6 header and 8 of UDP
+	// header.
+	MaxPacketSize = 1452
+
+	// MaxPayloadSize is the largest payload which fits in a packet, for an
+	// AEAD with a 16-byte overhead such as SIV-CMAC.
+	MaxPayloadSize = MaxPacketSize - seqSize - 16
+
+	seqSize = 8
+)
+
+var (
+	// ErrTruncated is returned by Open for a packet too short to hold a
+	// sequence number.
+	ErrTruncated = errors.New("dgram: truncated packet")
+
+	// ErrPacketSize is returned by Open for a packet larger than
+	// MaxPacketSize.
+	ErrPacketSize = errors.New("dgram: packet too large")
+)
+
+// Seal seals payload as a packet with the given sequence number, which must be
+// unique per packet. It panics if the packet would be larger than
+// MaxPacketSize.
+func Seal(aead cipher.AEAD, seq uint64, payload, ad []byte) []byte {
+	if seqSize+len(payload)+aead.Overhead() > MaxPacketSize {
+		panic("dgram: payload too large")
+	}
+
+	packet := make([]byte, seqSize, seqSize+len(payload)+aead.Overhead())
+	binary.BigEndian.PutUint64(packet, seq)
+	return bind(aead, seq).Seal(packet, nil, payload, ad)
+}
+
+// Open authenticates and decrypts a packet sealed by Seal with the same ad,
+// returning its sequence number and payload. It does not check for replays;
+// use OpenWindow for that.
+func Open(aead cipher.AEAD, packet, ad []byte) (seq uint64, payload []byte, err error) {
+	if len(packet) > MaxPacketSize {
+		return 0, nil, ErrPacketSize
+	}
+
+	if len(packet) < seqSize {
+		return 0, nil, ErrTruncated
+	}
+
+	seq = binary.BigEndian.Uint64(packet)
+	payload, err = bind(aead, seq).Open(nil, nil, packet[seqSize:], ad)
+	if err != nil {
+		return 0, nil, err
+	}
+	return seq, payload, nil
+}
+
+// OpenWindow is like Open, but also rejects packets whose sequence numbers
+// have already been accepted by w, or which have fallen behind it, with
+// replay.ErrReplay or replay.ErrTooOld. Only authenticated packets advance w.
+func OpenWindow(w *replay.Window, aead cipher.AEAD, packet, ad []byte) (seq uint64, payload []byte, err error) {
+	seq, payload, err = Open(aead, packet, ad)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if err := w.Check(seq); err != nil {
+		return 0, nil, err
+	}
+	return seq, payload, nil
+}
+
+func bind(aead cipher.AEAD, seq uint64) cipher.AEAD {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], seq)
+	return siv.NewContext(aead, []byte("siv dgram"), b[:])
+}
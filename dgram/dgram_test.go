@@ -0,0 +1,144 @@
+package dgram
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+	"github.com/stripe/siv-go/replay"
+)
+
+func testAEAD(t *testing.T) cipher.AEAD {
+	aead, err := siv.New([]byte("0123456789abcdef0123456789ABCDEF"), aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func TestRoundTrip(t *testing.T) {
+	aead := testAEAD(t)
+	payload := []byte("cpu=0.42")
+
+	packet := Seal(aead, 7, payload, []byte("telemetry"))
+	if len(packet) != 8+len(payload)+16 {
+		t.Errorf("Packet length was %d, but expected %d", len(packet), 8+len(payload)+16)
+	}
+
+	if !bytes.Equal(packet[:8], []byte{0, 0, 0, 0, 0, 0, 0, 7}) {
+		t.Errorf("Sequence number was %x, but expected %x", packet[:8], 7)
+	}
+
+	seq, actual, err := Open(aead, packet, []byte("telemetry"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if seq != 7 {
+		t.Errorf("Sequence number was %d, but expected %d", seq, 7)
+	}
+
+	if !bytes.Equal(actual, payload) {
+		t.Errorf("Payload was %x, but expected %x", actual, payload)
+	}
+}
+
+func TestMaxPayload(t *testing.T) {
+	aead := testAEAD(t)
+
+	packet := Seal(aead, 0, make([]byte, MaxPayloadSize), nil)
+	if len(packet) != MaxPacketSize {
+		t.Errorf("Packet length was %d, but expected %d", len(packet), MaxPacketSize)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for an oversized payload")
+		}
+	}()
+	Seal(aead, 0, make([]byte, MaxPayloadSize+1), nil)
+}
+
+func TestReplayed(t *testing.T) {
+	aead := testAEAD(t)
+	w := replay.NewWindow(64)
+	packet := Seal(aead, 1, []byte("a"), nil)
+
+	if _, _, err := OpenWindow(w, aead, packet, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, p, err := OpenWindow(w, aead, packet, nil); err != replay.ErrReplay {
+		t.Fatalf("Plaintext returned instead of error: %x", p)
+	}
+
+	// Open alone doesn't track replays.
+	if _, _, err := Open(aead, packet, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReordered(t *testing.T) {
+	aead := testAEAD(t)
+	w := replay.NewWindow(64)
+
+	for _, seq := range []uint64{3, 1, 2, 70} {
+		actual, _, err := OpenWindow(w, aead, Seal(aead, seq, nil, nil), nil)
+		if err != nil {
+			t.Fatalf("%d: %v", seq, err)
+		}
+
+		if actual != seq {
+			t.Errorf("Sequence number was %d, but expected %d", actual, seq)
+		}
+	}
+
+	// Packet 1 has now fallen behind the window.
+	if _, p, err := OpenWindow(w, aead, Seal(aead, 1, nil, nil), nil); err != replay.ErrTooOld {
+		t.Fatalf("Plaintext returned instead of error: %x", p)
+	}
+}
+
+func TestTruncated(t *testing.T) {
+	aead := testAEAD(t)
+	packet := Seal(aead, 1, []byte("payload"), nil)
+
+	for i := range packet {
+		_, p, err := Open(aead, packet[:i], nil)
+		if i < 8 && err != ErrTruncated {
+			t.Errorf("%d: Error was %v, but expected %v", i, err, ErrTruncated)
+		} else if i >= 8 && err != siv.ErrAuthentication {
+			t.Fatalf("Plaintext returned instead of error: %x", p)
+		}
+	}
+
+	if _, _, err := Open(aead, make([]byte, MaxPacketSize+1), nil); err != ErrPacketSize {
+		t.Errorf("Error was %v, but expected %v", err, ErrPacketSize)
+	}
+}
+
+func TestBitFlipped(t *testing.T) {
+	aead := testAEAD(t)
+	w := replay.NewWindow(64)
+	packet := Seal(aead, 1, []byte("payload"), []byte("ad"))
+
+	for i := 0; i < len(packet)*8; i++ {
+		b := append([]byte{}, packet...)
+		b[i/8] ^= 1 << (i % 8)
+
+		if _, p, err := OpenWindow(w, aead, b, []byte("ad")); err != siv.ErrAuthentication {
+			t.Fatalf("Plaintext returned instead of error: %x", p)
+		}
+	}
+
+	// Rejected packets don't advance the window.
+	if _, _, err := OpenWindow(w, aead, packet, []byte("ad")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, p, err := OpenWindow(w, aead, packet, []byte("other")); err != siv.ErrAuthentication {
+		t.Fatalf("Plaintext returned instead of error: %x", p)
+	}
+}
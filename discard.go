@@ -0,0 +1,166 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+)
+
+// OpenDiscard authenticates ciphertext as Open(nil, nonce, ciphertext, data)
+// would, but discards the plaintext, returning only whether it authenticated.
+// The ciphertext is decrypted a block at a time into scratch space which is
+// wiped afterwards, so no plaintext is retained, and it does not allocate once
+// its scratch space has been pooled.
+func (s *SIV) OpenDiscard(nonce, ciphertext, data []byte) error {
+	if len(ciphertext) < s.Overhead() {
+		return ErrAuthentication
+	}
+
+	v, _ := s.verifiers.Get().(*verifier)
+	if v == nil {
+		v = &verifier{}
+		v.mac.init(s.mac)
+	}
+	defer s.verifiers.Put(v)
+
+	if subtle.ConstantTimeCompare(ciphertext[:blockSize], v.verify(s.enc, ciphertext, data, nonce)) != 1 {
+		return ErrAuthentication
+	}
+	return nil
+}
+
+// verifier holds the scratch space for OpenDiscard.
+type verifier struct {
+	mac            cmacState
+	d, iv, ks, end [blockSize]byte
+}
+
+// verify computes S2V over the given associated data components and the
+// plaintext of ciphertext, which must include its tag.
+func (v *verifier) verify(enc cipher.Block, ciphertext, data, nonce []byte) []byte {
+	defer v.wipe()
+
+	// D = CMAC(0), then fold in each component.
+	v.mac.reset()
+	v.d = [blockSize]byte{}
+	v.mac.write(v.d[:])
+	v.mac.sum(&v.d)
+	for _, c := range [2][]byte{data, nonce} {
+		if c == nil {
+			continue
+		}
+
+		dbl(v.d[:])
+		v.mac.reset()
+		v.mac.write(c)
+		v.mac.sum(&v.ks)
+		for i := range v.d {
+			v.d[i] ^= v.ks[i]
+		}
+	}
+
+	// Decrypt the body a block at a time, feeding all but its last block (or
+	// all of it, if shorter) to the MAC and keeping the rest for the final
+	// step.
+	copy(v.iv[:], ciphertext[:blockSize])
+	v.iv[8] &= 0x7f
+	v.iv[12] &= 0x7f
+
+	body := ciphertext[blockSize:]
+	prefix := 0
+	if len(body) >= blockSize {
+		prefix = len(body) - blockSize
+	}
+
+	v.mac.reset()
+	for off := 0; off < len(body); off += blockSize {
+		enc.Encrypt(v.ks[:], v.iv[:])
+		incrementCounter(&v.iv)
+
+		n := subtle.XORBytes(v.ks[:], v.ks[:], body[off:])
+		p := v.ks[:n]
+		if off+n <= prefix {
+			v.mac.write(p)
+			continue
+		}
+
+		split := 0
+		if off < prefix {
+			split = prefix - off
+			v.mac.write(p[:split])
+		}
+		copy(v.end[off+split-prefix:], p[split:])
+	}
+
+	end := v.end[:len(body)-prefix]
+	if len(end) == blockSize {
+		subtle.XORBytes(v.d[:], v.d[:], end)
+	} else {
+		dbl(v.d[:])
+		subtle.XORBytes(v.d[:], v.d[:], end)
+		v.d[len(end)] ^= 0x80
+	}
+	v.mac.write(v.d[:])
+	v.mac.sum(&v.d)
+	return v.d[:]
+}
+
+func (v *verifier) wipe() {
+	v.ks, v.end = [blockSize]byte{}, [blockSize]byte{}
+}
+
+// incrementCounter increments a big-endian 128-bit counter, as cipher.NewCTR
+// does.
+func incrementCounter(c *[blockSize]byte) {
+	for i := len(c) - 1; i >= 0; i-- {
+		c[i]++
+		if c[i] != 0 {
+			return
+		}
+	}
+}
+
+// cmacState is a minimal CMAC (NIST SP 800-38B) over a 128-bit block cipher
+// which, unlike a hash.Hash, never allocates.
+type cmacState struct {
+	c         cipher.Block
+	k1, k2, x [blockSize]byte
+	buf       [blockSize]byte
+	n         int
+}
+
+func (m *cmacState) init(c cipher.Block) {
+	m.c = c
+	c.Encrypt(m.k1[:], m.k1[:])
+	dbl(m.k1[:])
+	m.k2 = m.k1
+	dbl(m.k2[:])
+}
+
+func (m *cmacState) reset() {
+	m.x, m.buf, m.n = [blockSize]byte{}, [blockSize]byte{}, 0
+}
+
+func (m *cmacState) write(p []byte) {
+	for len(p) > 0 {
+		if m.n == blockSize {
+			subtle.XORBytes(m.x[:], m.x[:], m.buf[:])
+			m.c.Encrypt(m.x[:], m.x[:])
+			m.n = 0
+		}
+		c := copy(m.buf[m.n:], p)
+		m.n += c
+		p = p[c:]
+	}
+}
+
+func (m *cmacState) sum(out *[blockSize]byte) {
+	if m.n == blockSize {
+		subtle.XORBytes(out[:], m.buf[:], m.k1[:])
+	} else {
+		*out = m.k2
+		subtle.XORBytes(out[:], out[:], m.buf[:m.n])
+		out[m.n] ^= 0x80
+	}
+	subtle.XORBytes(out[:], out[:], m.x[:])
+	m.c.Encrypt(out[:], out[:])
+}
@@ -0,0 +1,78 @@
+package siv
+
+import (
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestOpenDiscard(t *testing.T) {
+	key, _ := hex.DecodeString("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	aead, _ := New(key, aes.NewCipher)
+	s := aead.(*SIV)
+
+	for _, ptLen := range []int{0, 1, 15, 16, 17, 31, 32, 33, 100} {
+		for _, v := range []struct {
+			nonce, data []byte
+		}{
+			{nil, nil},
+			{nil, []byte{}},
+			{nil, []byte("ad")},
+			{make([]byte, 16), nil},
+			{[]byte("nonce"), []byte("some longer associated data")},
+		} {
+			plaintext := make([]byte, ptLen)
+			for i := range plaintext {
+				plaintext[i] = byte(i)
+			}
+			ciphertext := aead.Seal(nil, v.nonce, plaintext, v.data)
+
+			if err := s.OpenDiscard(v.nonce, ciphertext, v.data); err != nil {
+				t.Errorf("pt=%d: %v", ptLen, err)
+			}
+
+			for i := range ciphertext {
+				c := append([]byte{}, ciphertext...)
+				c[i] ^= 1
+				if err := s.OpenDiscard(v.nonce, c, v.data); err != ErrAuthentication {
+					t.Fatalf("pt=%d: Flipped byte %d was accepted", ptLen, i)
+				}
+			}
+
+			if err := s.OpenDiscard(v.nonce, ciphertext, append(v.data, 'x')); err != ErrAuthentication {
+				t.Errorf("pt=%d: Wrong associated data was accepted", ptLen)
+			}
+		}
+	}
+
+	for i := 0; i < aead.Overhead(); i++ {
+		if err := s.OpenDiscard(nil, make([]byte, i), nil); err != ErrAuthentication {
+			t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+		}
+	}
+}
+
+func TestOpenDiscardRFC(t *testing.T) {
+	key, _ := hex.DecodeString("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	ad, _ := hex.DecodeString("101112131415161718191a1b1c1d1e1f2021222324252627")
+	ciphertext, _ := hex.DecodeString("85632d07c6e8f37f950acd320a2ecc9340c02b9690c4dc04daef7f6afe5c")
+
+	aead, _ := New(key, aes.NewCipher)
+	if err := aead.(*SIV).OpenDiscard(nil, ciphertext, ad); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOpenDiscardAllocs(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	s := aead.(*SIV)
+	ciphertext := aead.Seal(nil, nil, make([]byte, 1000), []byte("ad"))
+	data := []byte("ad")
+
+	allocs := testing.AllocsPerRun(100, func() {
+		_ = s.OpenDiscard(nil, ciphertext, data)
+	})
+	if allocs != 0 {
+		t.Errorf("OpenDiscard made %v allocations, but expected none", allocs)
+	}
+}
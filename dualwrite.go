@@ -0,0 +1,78 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"sync/atomic"
+)
+
+// DualWriteAEAD is an AEAD for migrating from one key or algorithm to another:
+// Seal always uses the primary AEAD, and Open tries the primary and then the
+// secondary. Its Stats show how often the secondary is still needed, and so
+// when it is safe to remove. It is safe for concurrent use if both AEADs are.
+type DualWriteAEAD struct {
+	primary, secondary cipher.AEAD
+
+	primaryOpens, secondaryOpens, failedOpens atomic.Uint64
+}
+
+// DualWriteStats counts the Opens performed by a DualWriteAEAD.
+type DualWriteStats struct {
+	// Primary is the number of ciphertexts opened by the primary AEAD.
+	Primary uint64
+
+	// Secondary is the number of ciphertexts which the primary AEAD failed to
+	// open but the secondary opened.
+	Secondary uint64
+
+	// Failed is the number of ciphertexts which neither AEAD opened.
+	Failed uint64
+}
+
+// NewDualWrite returns a DualWriteAEAD which seals with primary and opens
+// with primary or secondary. The two must have the same nonce size and
+// overhead.
+func NewDualWrite(primary, secondary cipher.AEAD) *DualWriteAEAD {
+	return &DualWriteAEAD{primary: primary, secondary: secondary}
+}
+
+// NonceSize returns the nonce size of the primary AEAD.
+func (d *DualWriteAEAD) NonceSize() int {
+	return d.primary.NonceSize()
+}
+
+// Overhead returns the overhead of the primary AEAD.
+func (d *DualWriteAEAD) Overhead() int {
+	return d.primary.Overhead()
+}
+
+// Seal seals plaintext with the primary AEAD.
+func (d *DualWriteAEAD) Seal(dst, nonce, plaintext, data []byte) []byte {
+	return d.primary.Seal(dst, nonce, plaintext, data)
+}
+
+// Open opens ciphertext with the primary AEAD or, failing that, the
+// secondary. If both fail, it returns the primary's error.
+func (d *DualWriteAEAD) Open(dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	plaintext, err := trialOpen(d.primary, dst, nonce, ciphertext, data)
+	if err == nil {
+		d.primaryOpens.Add(1)
+		return plaintext, nil
+	}
+
+	if plaintext, serr := trialOpen(d.secondary, dst, nonce, ciphertext, data); serr == nil {
+		d.secondaryOpens.Add(1)
+		return plaintext, nil
+	}
+
+	d.failedOpens.Add(1)
+	return nil, err
+}
+
+// Stats returns the number of Opens performed so far, by outcome.
+func (d *DualWriteAEAD) Stats() DualWriteStats {
+	return DualWriteStats{
+		Primary:   d.primaryOpens.Load(),
+		Secondary: d.secondaryOpens.Load(),
+		Failed:    d.failedOpens.Load(),
+	}
+}
@@ -0,0 +1,115 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"sync"
+	"testing"
+)
+
+// failingAEAD is an AEAD whose Open always fails with err.
+type failingAEAD struct {
+	cipher.AEAD
+	err error
+}
+
+func (f failingAEAD) Open(dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	return nil, f.err
+}
+
+func TestDualWrite(t *testing.T) {
+	primary, _ := New(bytes.Repeat([]byte{1}, 32), aes.NewCipher, AllowEqualHalves())
+	secondary, _ := New(bytes.Repeat([]byte{2}, 32), aes.NewCipher, AllowEqualHalves())
+	d := NewDualWrite(primary, secondary)
+
+	ciphertext := d.Seal(nil, nil, []byte("new"), nil)
+	if _, err := primary.Open(nil, nil, ciphertext, nil); err != nil {
+		t.Errorf("Seal didn't use the primary: %v", err)
+	}
+
+	for _, v := range []struct {
+		aead      cipher.AEAD
+		plaintext string
+	}{
+		{primary, "a"},
+		{secondary, "b"},
+		{secondary, "c"},
+		{primary, "d"},
+	} {
+		actual, err := d.Open(nil, nil, v.aead.Seal(nil, nil, []byte(v.plaintext), nil), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(actual) != v.plaintext {
+			t.Errorf("Plaintext was %q, but expected %q", actual, v.plaintext)
+		}
+	}
+
+	other, _ := New(bytes.Repeat([]byte{3}, 32), aes.NewCipher, AllowEqualHalves())
+	if p, err := d.Open(nil, nil, other.Seal(nil, nil, []byte("e"), nil), nil); err != ErrAuthentication {
+		t.Fatalf("Plaintext returned instead of error: %x", p)
+	}
+
+	expected := DualWriteStats{Primary: 2, Secondary: 2, Failed: 1}
+	if s := d.Stats(); s != expected {
+		t.Errorf("Stats were %+v, but expected %+v", s, expected)
+	}
+}
+
+func TestDualWriteInPlace(t *testing.T) {
+	primary, _ := New(testKey(1, 32), aes.NewCipher)
+	secondary, _ := New(testKey(2, 32), aes.NewCipher)
+	d := NewDualWrite(primary, secondary)
+
+	for _, aead := range []cipher.AEAD{primary, secondary} {
+		ciphertext := aead.Seal(nil, nil, []byte("in place"), []byte("ad"))
+		plaintext, err := d.Open(ciphertext[:0], nil, ciphertext, []byte("ad"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(plaintext) != "in place" || &plaintext[0] != &ciphertext[0] {
+			t.Errorf("Plaintext was %q, but expected %q in place", plaintext, "in place")
+		}
+	}
+}
+
+func TestDualWritePrimaryError(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	primaryErr, secondaryErr := errors.New("primary"), errors.New("secondary")
+
+	d := NewDualWrite(failingAEAD{aead, primaryErr}, failingAEAD{aead, secondaryErr})
+	if _, err := d.Open(nil, nil, aead.Seal(nil, nil, nil, nil), nil); err != primaryErr {
+		t.Errorf("Error was %v, but expected %v", err, primaryErr)
+	}
+}
+
+func TestDualWriteConcurrent(t *testing.T) {
+	primary, _ := New(bytes.Repeat([]byte{1}, 32), aes.NewCipher, AllowEqualHalves())
+	secondary, _ := New(bytes.Repeat([]byte{2}, 32), aes.NewCipher, AllowEqualHalves())
+	d := NewDualWrite(primary, secondary)
+
+	a := primary.Seal(nil, nil, []byte("a"), nil)
+	b := secondary.Seal(nil, nil, []byte("b"), nil)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, _ = d.Open(nil, nil, a, nil)
+				_, _ = d.Open(nil, nil, b, nil)
+				_, _ = d.Open(nil, nil, b[1:], nil)
+			}
+		}()
+	}
+	wg.Wait()
+
+	expected := DualWriteStats{Primary: 800, Secondary: 800, Failed: 800}
+	if s := d.Stats(); s != expected {
+		t.Errorf("Stats were %+v, but expected %+v", s, expected)
+	}
+}
@@ -0,0 +1,76 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"sync"
+)
+
+// WithDuplicateDetection returns an AEAD which seals with aead and calls
+// onDuplicate whenever a Seal produces the same synthetic IV as one of the last
+// capacity Seals, which happens when the same plaintext and associated data
+// are sealed twice. onDuplicate is passed the SHA-256 hash of the associated
+// data of the repeated message, and is called synchronously from Seal.
+//
+// Only the first 8 bytes of each synthetic IV are kept, in a ring buffer and
+// an index over it, so memory use is bounded at roughly 50 bytes per entry
+// regardless of message size, and no plaintext is retained. Once more than
+// capacity messages have been sealed, the oldest are forgotten, so duplicates
+// further apart than that go unreported. Distinct messages are reported as
+// duplicates (a false positive) with probability about capacity/2^64 per Seal.
+//
+// The wrapped AEAD must write its tag at the start of its output, as SIV does.
+func WithDuplicateDetection(aead cipher.AEAD, capacity int, onDuplicate func(adHash []byte)) cipher.AEAD {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &duplicateDetector{
+		AEAD:        aead,
+		onDuplicate: onDuplicate,
+		ring:        make([]uint64, 0, capacity),
+		seen:        make(map[uint64]int, capacity),
+	}
+}
+
+type duplicateDetector struct {
+	cipher.AEAD
+	onDuplicate func([]byte)
+
+	mu   sync.Mutex
+	ring []uint64
+	next int
+	seen map[uint64]int // tag prefix to number of occurrences in ring
+}
+
+func (d *duplicateDetector) Seal(dst, nonce, plaintext, data []byte) []byte {
+	out := d.AEAD.Seal(dst, nonce, plaintext, data)
+	if d.observe(binary.BigEndian.Uint64(out[len(dst):])) {
+		h := sha256.Sum256(data)
+		d.onDuplicate(h[:])
+	}
+	return out
+}
+
+// observe records a tag prefix, reporting whether it was already present.
+func (d *duplicateDetector) observe(tag uint64) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	duplicate := d.seen[tag] > 0
+
+	if len(d.ring) < cap(d.ring) {
+		d.ring = append(d.ring, tag)
+	} else {
+		old := d.ring[d.next]
+		if d.seen[old]--; d.seen[old] == 0 {
+			delete(d.seen, old)
+		}
+		d.ring[d.next] = tag
+		d.next = (d.next + 1) % len(d.ring)
+	}
+	d.seen[tag]++
+
+	return duplicate
+}
@@ -0,0 +1,81 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+func TestDuplicateDetection(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+
+	var reports [][]byte
+	d := WithDuplicateDetection(aead, 16, func(adHash []byte) {
+		reports = append(reports, adHash)
+	})
+
+	a := d.Seal(nil, nil, []byte("yay"), []byte("ad"))
+	d.Seal(nil, nil, []byte("yay"), []byte("other ad"))
+	d.Seal(nil, nil, []byte("boo"), []byte("ad"))
+	if len(reports) != 0 {
+		t.Fatalf("Reported %d duplicates, but expected none", len(reports))
+	}
+
+	b := d.Seal([]byte("prefix"), nil, []byte("yay"), []byte("ad"))
+	if len(reports) != 1 {
+		t.Fatalf("Reported %d duplicates, but expected 1", len(reports))
+	}
+
+	if expected := sha256.Sum256([]byte("ad")); !bytes.Equal(reports[0], expected[:]) {
+		t.Errorf("AD hash was %x, but expected %x", reports[0], expected)
+	}
+
+	if !bytes.Equal(a, b[len("prefix"):]) {
+		t.Error("Detection changed the ciphertext")
+	}
+}
+
+func TestDuplicateDetectionEviction(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+
+	reports := 0
+	d := WithDuplicateDetection(aead, 4, func([]byte) {
+		reports++
+	})
+
+	seal := func(i int) {
+		d.Seal(nil, nil, []byte(fmt.Sprint(i)), nil)
+	}
+
+	for i := 0; i < 4; i++ {
+		seal(i)
+	}
+
+	// 0 is still within capacity.
+	seal(0)
+	if reports != 1 {
+		t.Fatalf("Reported %d duplicates, but expected 1", reports)
+	}
+
+	// 1 and 2 are evicted by these.
+	seal(4)
+	seal(5)
+	seal(1)
+	seal(2)
+	if reports != 1 {
+		t.Errorf("Reported %d duplicates, but expected 1", reports)
+	}
+
+	// 5 was sealed within the last 4.
+	seal(5)
+	if reports != 2 {
+		t.Errorf("Reported %d duplicates, but expected 2", reports)
+	}
+
+	dd := d.(*duplicateDetector)
+	if len(dd.ring) != 4 || len(dd.seen) > 4 {
+		t.Errorf("Retained %d tags in %d slots, but expected at most 4", len(dd.seen), len(dd.ring))
+	}
+}
@@ -0,0 +1,109 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"errors"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrInvalidEmail is returned for strings which are not acceptable email
+// addresses.
+var ErrInvalidEmail = errors.New("siv: invalid email address")
+
+// EmailPolicy controls how email addresses are normalized before they are
+// encrypted. The zero value is the default policy, and will not change:
+// surrounding whitespace is trimmed, the address is converted to Unicode NFC,
+// and both the local part and the domain are lowercased.
+//
+// Addresses must have a non-empty local part of at most 64 bytes and a domain
+// of at most 255 bytes, separated by a single @, and must not contain
+// whitespace, control characters, quotes, or angle brackets. Domains must not
+// begin or end with a dot or contain consecutive dots.
+type EmailPolicy struct {
+	// PreserveLocalCase leaves the case of the local part unchanged. Most
+	// providers treat local parts case-insensitively, though RFC 5321 permits
+	// them not to.
+	PreserveLocalCase bool
+
+	// RemoveSubaddress removes everything from the first + in the local part,
+	// so that user+tag@example.com becomes user@example.com.
+	RemoveSubaddress bool
+
+	// RemoveGmailDots removes dots from the local part of gmail.com and
+	// googlemail.com addresses, which Gmail ignores.
+	RemoveGmailDots bool
+}
+
+// Normalize returns the normalized form of email, or ErrInvalidEmail.
+func (p EmailPolicy) Normalize(email string) (string, error) {
+	email = norm.NFC.String(strings.TrimSpace(email))
+	if email == "" || len(email) > 254 {
+		return "", ErrInvalidEmail
+	}
+
+	for _, r := range email {
+		if unicode.IsSpace(r) || unicode.IsControl(r) || strings.ContainsRune(`"<>`, r) || r == unicode.ReplacementChar {
+			return "", ErrInvalidEmail
+		}
+	}
+
+	local, domain, ok := strings.Cut(email, "@")
+	if !ok || strings.Contains(domain, "@") || local == "" || len(local) > 64 || domain == "" || len(domain) > 255 ||
+		strings.HasPrefix(domain, ".") || strings.HasSuffix(domain, ".") || strings.Contains(domain, "..") {
+		return "", ErrInvalidEmail
+	}
+
+	domain = strings.ToLower(domain)
+	if !p.PreserveLocalCase {
+		local = strings.ToLower(local)
+	}
+
+	if p.RemoveSubaddress {
+		local, _, _ = strings.Cut(local, "+")
+	}
+
+	if p.RemoveGmailDots && (domain == "gmail.com" || domain == "googlemail.com") {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+
+	if local == "" {
+		return "", ErrInvalidEmail
+	}
+
+	return norm.NFC.String(local + "@" + domain), nil
+}
+
+// EncryptEmail normalizes email according to the default EmailPolicy and
+// deterministically encrypts it, so that equivalent addresses produce the same
+// ciphertext. It returns the ciphertext as unpadded base64url.
+func EncryptEmail(aead cipher.AEAD, email string, ad []byte) (string, error) {
+	return EmailPolicy{}.Encrypt(aead, email, ad)
+}
+
+// DecryptEmail decrypts an address encrypted by EncryptEmail, returning it in
+// normalized form.
+func DecryptEmail(aead cipher.AEAD, ciphertext string, ad []byte) (string, error) {
+	return EmailPolicy{}.Decrypt(aead, ciphertext, ad)
+}
+
+// Encrypt is like EncryptEmail, but normalizes email according to p.
+func (p EmailPolicy) Encrypt(aead cipher.AEAD, email string, ad []byte) (string, error) {
+	email, err := p.Normalize(email)
+	if err != nil {
+		return "", err
+	}
+	return SealString(aead, []byte(email), ad), nil
+}
+
+// Decrypt is like DecryptEmail. The policy is not applied, as the address was
+// normalized before it was encrypted.
+func (p EmailPolicy) Decrypt(aead cipher.AEAD, ciphertext string, ad []byte) (string, error) {
+	email, err := OpenString(aead, ciphertext, ad)
+	if err != nil {
+		return "", err
+	}
+	return string(email), nil
+}
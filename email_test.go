@@ -0,0 +1,135 @@
+package siv
+
+import (
+	"crypto/aes"
+	"strings"
+	"testing"
+)
+
+func TestEmailNormalization(t *testing.T) {
+	gmail := EmailPolicy{RemoveSubaddress: true, RemoveGmailDots: true}
+
+	for _, v := range []struct {
+		policy   EmailPolicy
+		email    string
+		expected string
+	}{
+		{EmailPolicy{}, "user@example.com", "user@example.com"},
+		{EmailPolicy{}, "  User@Example.COM\n", "user@example.com"},
+		{EmailPolicy{}, "user+tag@example.com", "user+tag@example.com"},
+		{EmailPolicy{}, "first.last@gmail.com", "first.last@gmail.com"},
+		{EmailPolicy{PreserveLocalCase: true}, "User@Example.COM", "User@example.com"},
+		{gmail, "First.Last+news@GMail.com", "firstlast@gmail.com"},
+		{gmail, "f.i.r.s.t@googlemail.com", "first@googlemail.com"},
+		{gmail, "first.last+news@example.com", "first.last@example.com"},
+		// Decomposed and precomposed forms are equivalent under NFC.
+		{EmailPolicy{}, "jose\u0301@example.com", "jos\u00e9@example.com"},
+		{EmailPolicy{}, "JOSÉ@example.com", "josé@example.com"},
+		{EmailPolicy{}, "user@BÜCHER.example", "user@bücher.example"},
+		{EmailPolicy{}, "o'brien@example.com", "o'brien@example.com"},
+		{EmailPolicy{}, "user@sub.example.co.uk", "user@sub.example.co.uk"},
+	} {
+		actual, err := v.policy.Normalize(v.email)
+		if err != nil {
+			t.Errorf("%q: %v", v.email, err)
+			continue
+		}
+
+		if actual != v.expected {
+			t.Errorf("Normalized %q to %q, but expected %q", v.email, actual, v.expected)
+		}
+	}
+}
+
+func TestEmailInvalid(t *testing.T) {
+	for _, email := range []string{
+		"",
+		"   ",
+		"user",
+		"@example.com",
+		"user@",
+		"user@@example.com",
+		"a@b@example.com",
+		"user name@example.com",
+		"user@exa mple.com",
+		"\"user\"@example.com",
+		"User <user@example.com>",
+		"user@.example.com",
+		"user@example.com.",
+		"user@example..com",
+		"user\x00@example.com",
+		"user@example.com\x7f",
+		strings.Repeat("a", 65) + "@example.com",
+		"user@" + strings.Repeat("a", 250) + ".com",
+		"\xff@example.com",
+	} {
+		if actual, err := (EmailPolicy{}).Normalize(email); err != ErrInvalidEmail {
+			t.Errorf("Normalized %q to %q, but expected %v", email, actual, ErrInvalidEmail)
+		}
+	}
+
+	// Removing a subaddress must not leave an empty local part.
+	if actual, err := (EmailPolicy{RemoveSubaddress: true}).Normalize("+tag@example.com"); err != ErrInvalidEmail {
+		t.Errorf("Normalized to %q, but expected %v", actual, ErrInvalidEmail)
+	}
+}
+
+func TestEncryptEmail(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+
+	a, err := EncryptEmail(aead, "User@Example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := EncryptEmail(aead, " user@EXAMPLE.COM", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a != b {
+		t.Errorf("Equivalent addresses encrypted to %q and %q", a, b)
+	}
+
+	email, err := DecryptEmail(aead, a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if email != "user@example.com" {
+		t.Errorf("Decrypted %q, but expected %q", email, "user@example.com")
+	}
+
+	if _, err := DecryptEmail(aead, a, []byte("other")); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+
+	if _, err := EncryptEmail(aead, "not an email", nil); err != ErrInvalidEmail {
+		t.Errorf("Error was %v, but expected %v", err, ErrInvalidEmail)
+	}
+}
+
+func TestEncryptEmailPolicy(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	gmail := EmailPolicy{RemoveSubaddress: true, RemoveGmailDots: true}
+
+	a, _ := gmail.Encrypt(aead, "first.last+news@gmail.com", nil)
+	b, _ := gmail.Encrypt(aead, "FirstLast@gmail.com", nil)
+	if a != b {
+		t.Errorf("Equivalent addresses encrypted to %q and %q", a, b)
+	}
+
+	c, _ := EncryptEmail(aead, "first.last+news@gmail.com", nil)
+	if a == c {
+		t.Error("Policies produced the same ciphertext")
+	}
+
+	email, err := gmail.Decrypt(aead, a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if email != "firstlast@gmail.com" {
+		t.Errorf("Decrypted %q, but expected %q", email, "firstlast@gmail.com")
+	}
+}
@@ -0,0 +1,269 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// envelopeKeySize is the size of the data encryption keys generated for
+// envelopes: an AES-SIV-CMAC-256 key.
+const envelopeKeySize = 32
+
+const multiEnvelopeVersion = 1
+
+var (
+	// ErrNoRecipient is returned when an envelope has no slot for the
+	// recipient's key.
+	ErrNoRecipient = errors.New("siv: no envelope slot for recipient")
+
+	// ErrFingerprint is returned when a recipient AEAD can't report its key
+	// fingerprint.
+	ErrFingerprint = errors.New("siv: recipient does not have a fingerprint")
+
+	// ErrEnvelopeFormat is returned when an encoded envelope is malformed.
+	ErrEnvelopeFormat = errors.New("siv: malformed envelope")
+)
+
+// fingerprinter is implemented by the AEADs returned by New.
+type fingerprinter interface {
+	Fingerprint() []byte
+}
+
+// MultiEnvelope is a message encrypted once under a random data encryption
+// key (DEK), with the DEK wrapped separately for each of several recipients.
+type MultiEnvelope struct {
+	// Recipients holds one slot per recipient, in the order given to
+	// SealMulti.
+	Recipients []RecipientSlot
+
+	// Ciphertext is the message, sealed under the DEK with the encoded
+	// recipient slots bound as associated data.
+	Ciphertext []byte
+}
+
+// RecipientSlot holds the DEK of a MultiEnvelope wrapped for one recipient.
+type RecipientSlot struct {
+	// Fingerprint is the fingerprint of the recipient's key.
+	Fingerprint []byte
+
+	// WrappedKey is the DEK sealed with the recipient's AEAD, with no
+	// associated data beyond a fixed label (i.e. SIV key wrap, per RFC 5297
+	// section 1.3.2).
+	WrappedKey []byte
+}
+
+// An EnvelopeOption configures SealMulti.
+type EnvelopeOption func(*envelopeConfig)
+
+type envelopeConfig struct {
+	rand io.Reader
+}
+
+// WithRand makes SealMulti read the DEK from r rather than crypto/rand, as for
+// entropy from an HSM or for deterministic tests. SealMulti reads exactly 32
+// bytes from r, and fails if r returns fewer.
+func WithRand(r io.Reader) EnvelopeOption {
+	return func(c *envelopeConfig) {
+		c.rand = r
+	}
+}
+
+// SealMulti seals plaintext under a random DEK and wraps the DEK for each of
+// the recipients, which must be AEADs returned by New. The recipient slots are
+// bound into the message's associated data along with ad, so slots can't be
+// removed, added, or altered without detection.
+func SealMulti(recipients []cipher.AEAD, plaintext, ad []byte, opts ...EnvelopeOption) (_ *MultiEnvelope, err error) {
+	end := startSpan(nil, "siv.SealMulti")
+	defer func() { end(err) }()
+
+	c := envelopeConfig{rand: rand.Reader}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	dek := make([]byte, envelopeKeySize)
+	if _, err := io.ReadFull(c.rand, dek); err != nil {
+		return nil, err
+	}
+	defer wipe(dek)
+
+	e := &MultiEnvelope{}
+	for _, r := range recipients {
+		if err := e.addSlot(r, dek); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := e.seal(dek, plaintext, ad); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Open finds the slot for the recipient's key by its fingerprint, unwraps the
+// DEK, and opens the message.
+func (e *MultiEnvelope) Open(recipient cipher.AEAD, ad []byte) (_ []byte, err error) {
+	end := startSpan(nil, "siv.MultiEnvelope.Open")
+	defer func() { end(err) }()
+
+	dek, err := e.unwrap(recipient)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(dek)
+
+	payload, err := e.payloadAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+	return payload.Open(nil, nil, e.Ciphertext, ad)
+}
+
+// AddRecipient wraps the envelope's DEK for another recipient. As the slots
+// are bound to the message, this requires an existing recipient's key to
+// unwrap the DEK and re-seal the message.
+func (e *MultiEnvelope) AddRecipient(existing, recipient cipher.AEAD, ad []byte) (err error) {
+	end := startSpan(nil, "siv.MultiEnvelope.AddRecipient")
+	defer func() { end(err) }()
+
+	dek, err := e.unwrap(existing)
+	if err != nil {
+		return err
+	}
+	defer wipe(dek)
+
+	payload, err := e.payloadAEAD(dek)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := payload.Open(nil, nil, e.Ciphertext, ad)
+	if err != nil {
+		return err
+	}
+	defer wipe(plaintext)
+
+	next := &MultiEnvelope{Recipients: append([]RecipientSlot{}, e.Recipients...)}
+	if err := next.addSlot(recipient, dek); err != nil {
+		return err
+	}
+
+	if err := next.seal(dek, plaintext, ad); err != nil {
+		return err
+	}
+	*e = *next
+	return nil
+}
+
+func (e *MultiEnvelope) addSlot(recipient cipher.AEAD, dek []byte) error {
+	f, ok := recipient.(fingerprinter)
+	if !ok {
+		return ErrFingerprint
+	}
+
+	e.Recipients = append(e.Recipients, RecipientSlot{
+		Fingerprint: f.Fingerprint(),
+		WrappedKey:  recipient.Seal(nil, nil, dek, envelopeKeyData),
+	})
+	return nil
+}
+
+func (e *MultiEnvelope) seal(dek, plaintext, ad []byte) error {
+	payload, err := e.payloadAEAD(dek)
+	if err != nil {
+		return err
+	}
+	e.Ciphertext = payload.Seal(nil, nil, plaintext, ad)
+	return nil
+}
+
+// unwrap returns the DEK from the recipient's slot.
+func (e *MultiEnvelope) unwrap(recipient cipher.AEAD) ([]byte, error) {
+	f, ok := recipient.(fingerprinter)
+	if !ok {
+		return nil, ErrFingerprint
+	}
+
+	fingerprint := f.Fingerprint()
+	for _, s := range e.Recipients {
+		if bytes.Equal(s.Fingerprint, fingerprint) {
+			return recipient.Open(nil, nil, s.WrappedKey, envelopeKeyData)
+		}
+	}
+	return nil, ErrNoRecipient
+}
+
+// payloadAEAD returns the DEK's AEAD, bound to the encoded recipient slots.
+func (e *MultiEnvelope) payloadAEAD(dek []byte) (cipher.AEAD, error) {
+	aead, err := New(dek, aes.NewCipher)
+	if err != nil {
+		return nil, err
+	}
+	return NewContext(aead, []byte("siv multi envelope"), e.appendSlots(nil)), nil
+}
+
+var envelopeKeyData = []byte("siv envelope key")
+
+// MarshalBinary encodes the envelope as a version byte, the number of slots as
+// a uvarint, each slot's 8-byte fingerprint followed by its uvarint-prefixed
+// wrapped key, and finally the ciphertext.
+func (e *MultiEnvelope) MarshalBinary() ([]byte, error) {
+	b := []byte{multiEnvelopeVersion}
+	b = e.appendSlots(b)
+	return append(b, e.Ciphertext...), nil
+}
+
+func (e *MultiEnvelope) appendSlots(b []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(e.Recipients)))
+	for _, s := range e.Recipients {
+		b = append(b, s.Fingerprint...)
+		b = binary.AppendUvarint(b, uint64(len(s.WrappedKey)))
+		b = append(b, s.WrappedKey...)
+	}
+	return b
+}
+
+// UnmarshalBinary decodes an envelope encoded by MarshalBinary. It allocates
+// no more than a small multiple of len(data), whatever the lengths it
+// declares.
+func (e *MultiEnvelope) UnmarshalBinary(data []byte) error {
+	if len(data) == 0 || data[0] != multiEnvelopeVersion {
+		return ErrEnvelopeFormat
+	}
+	data = data[1:]
+
+	// Each slot takes at least a fingerprint and a length byte, so a count
+	// the data can't hold is rejected before the slots are allocated.
+	n, k := binary.Uvarint(data)
+	if k <= 0 || n > uint64(len(data)-k)/(FingerprintSize+1) {
+		return ErrEnvelopeFormat
+	}
+	data = data[k:]
+
+	slots := make([]RecipientSlot, 0, n)
+	for i := uint64(0); i < n; i++ {
+		if len(data) < FingerprintSize {
+			return ErrEnvelopeFormat
+		}
+		s := RecipientSlot{Fingerprint: append([]byte{}, data[:FingerprintSize]...)}
+		data = data[FingerprintSize:]
+
+		m, k := binary.Uvarint(data)
+		if k <= 0 || m > uint64(len(data)-k) {
+			return ErrEnvelopeFormat
+		}
+		s.WrappedKey = append([]byte{}, data[k:k+int(m)]...)
+		data = data[k+int(m):]
+
+		slots = append(slots, s)
+	}
+
+	e.Recipients = slots
+	e.Ciphertext = append([]byte{}, data...)
+	return nil
+}
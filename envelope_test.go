@@ -0,0 +1,240 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+	"runtime"
+	"testing"
+)
+
+func recipients(t *testing.T, n int) []cipher.AEAD {
+	var v []cipher.AEAD
+	for i := 0; i < n; i++ {
+		aead, err := New(bytes.Repeat([]byte{byte(i + 1)}, 32), aes.NewCipher, AllowEqualHalves())
+		if err != nil {
+			t.Fatal(err)
+		}
+		v = append(v, aead)
+	}
+	return v
+}
+
+func TestMultiEnvelope(t *testing.T) {
+	r := recipients(t, 3)
+
+	e, err := SealMulti(r, []byte("export"), []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(e.Recipients) != 3 {
+		t.Fatalf("Envelope had %d slots, but expected 3", len(e.Recipients))
+	}
+
+	for i, aead := range r {
+		p, err := e.Open(aead, []byte("ad"))
+		if err != nil {
+			t.Fatalf("Recipient %d: %v", i, err)
+		}
+
+		if string(p) != "export" {
+			t.Errorf("Recipient %d opened %q", i, p)
+		}
+	}
+
+	if _, err := e.Open(r[0], []byte("other")); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+}
+
+func TestMultiEnvelopeWrongKey(t *testing.T) {
+	r := recipients(t, 3)
+
+	e, err := SealMulti(r[:2], []byte("export"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := e.Open(r[2], nil); err != ErrNoRecipient {
+		t.Errorf("Error was %v, but expected %v", err, ErrNoRecipient)
+	}
+
+	// A key claiming another recipient's fingerprint can't unwrap its DEK.
+	e.Recipients[0].Fingerprint = r[2].(*SIV).Fingerprint()
+	if _, err := e.Open(r[2], nil); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+
+	block, _ := aes.NewCipher(make([]byte, 16))
+	gcm, _ := cipher.NewGCM(block)
+	if _, err := e.Open(gcm, nil); err != ErrFingerprint {
+		t.Errorf("Error was %v, but expected %v", err, ErrFingerprint)
+	}
+
+	if _, err := SealMulti([]cipher.AEAD{gcm}, nil, nil); err != ErrFingerprint {
+		t.Errorf("Error was %v, but expected %v", err, ErrFingerprint)
+	}
+}
+
+func TestMultiEnvelopeSlotTampering(t *testing.T) {
+	r := recipients(t, 3)
+
+	e, err := SealMulti(r, []byte("export"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stripped := *e
+	stripped.Recipients = e.Recipients[:2]
+	if _, err := stripped.Open(r[0], nil); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+
+	reordered := *e
+	reordered.Recipients = []RecipientSlot{e.Recipients[1], e.Recipients[0], e.Recipients[2]}
+	if _, err := reordered.Open(r[0], nil); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+
+	// Adding a slot for another key, wrapped with a DEK of the attacker's
+	// choosing, is also detected.
+	mallory := recipients(t, 4)[3]
+	added := *e
+	added.Recipients = append(append([]RecipientSlot{}, e.Recipients...), RecipientSlot{
+		Fingerprint: mallory.(*SIV).Fingerprint(),
+		WrappedKey:  mallory.Seal(nil, nil, make([]byte, 32), envelopeKeyData),
+	})
+	if _, err := added.Open(r[0], nil); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+}
+
+func TestMultiEnvelopeAddRecipient(t *testing.T) {
+	r := recipients(t, 3)
+
+	e, err := SealMulti(r[:2], []byte("export"), []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := e.AddRecipient(r[2], r[2], []byte("ad")); err != ErrNoRecipient {
+		t.Errorf("Error was %v, but expected %v", err, ErrNoRecipient)
+	}
+
+	if err := e.AddRecipient(r[1], r[2], []byte("ad")); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, aead := range r {
+		if p, err := e.Open(aead, []byte("ad")); err != nil || string(p) != "export" {
+			t.Errorf("Recipient %d opened %q (error %v)", i, p, err)
+		}
+	}
+}
+
+func TestMultiEnvelopeEncoding(t *testing.T) {
+	r := recipients(t, 2)
+
+	e, err := SealMulti(r, []byte("export"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := e.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded MultiEnvelope
+	if err := decoded.UnmarshalBinary(b); err != nil {
+		t.Fatal(err)
+	}
+
+	if p, err := decoded.Open(r[1], nil); err != nil || string(p) != "export" {
+		t.Errorf("Opened %q (error %v)", p, err)
+	}
+
+	for n := 0; n < len(b)-len(e.Ciphertext); n++ {
+		if err := new(MultiEnvelope).UnmarshalBinary(b[:n]); err != ErrEnvelopeFormat {
+			t.Errorf("%d: Error was %v, but expected %v", n, err, ErrEnvelopeFormat)
+		}
+	}
+}
+
+func TestMultiEnvelopeSlotCount(t *testing.T) {
+	// An envelope declaring 2^31 slots is rejected without allocating them.
+	b := binary.AppendUvarint([]byte{multiEnvelopeVersion}, 1<<31)
+	b = append(b, make([]byte, 100)...)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	err := new(MultiEnvelope).UnmarshalBinary(b)
+	runtime.ReadMemStats(&after)
+
+	if err != ErrEnvelopeFormat {
+		t.Errorf("Error was %v, but expected %v", err, ErrEnvelopeFormat)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<10 {
+		t.Errorf("Allocated %d bytes rejecting 2^31 slots", allocated)
+	}
+}
+
+var errHSM = errors.New("HSM unavailable")
+
+// countingReader returns bytes counting up from zero, and counts how many it
+// has returned. After limit bytes, if limit is positive, it returns err.
+type countingReader struct {
+	n, limit int
+	err      error
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	if r.limit > 0 && r.n+len(p) > r.limit {
+		p = p[:r.limit-r.n]
+	}
+	for i := range p {
+		p[i] = byte(r.n + i)
+	}
+	r.n += len(p)
+	if len(p) == 0 {
+		return 0, r.err
+	}
+	return len(p), nil
+}
+
+func TestMultiEnvelopeRand(t *testing.T) {
+	r := recipients(t, 3)
+
+	cr := &countingReader{}
+	e, err := SealMulti(r, []byte("export"), nil, WithRand(cr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cr.n != 32 {
+		t.Errorf("Read %d random bytes, but expected 32", cr.n)
+	}
+
+	dek, err := e.unwrap(r[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dek, testKey(0, 32)) {
+		t.Errorf("DEK was %x, but expected %x", dek, testKey(0, 32))
+	}
+
+	again, _ := SealMulti(r, []byte("export"), nil, WithRand(&countingReader{}))
+	if !bytes.Equal(again.Ciphertext, e.Ciphertext) {
+		t.Errorf("Ciphertext was %x, but expected %x", again.Ciphertext, e.Ciphertext)
+	}
+
+	if e, err := SealMulti(r, []byte("export"), nil, WithRand(&countingReader{limit: 31, err: io.EOF})); err != io.ErrUnexpectedEOF {
+		t.Errorf("Error was %v, but expected %v (envelope %v)", err, io.ErrUnexpectedEOF, e)
+	}
+	if e, err := SealMulti(r, []byte("export"), nil, WithRand(&countingReader{limit: 16, err: errHSM})); err != errHSM {
+		t.Errorf("Error was %v, but expected %v (envelope %v)", err, errHSM, e)
+	}
+}
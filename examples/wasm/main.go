@@ -0,0 +1,51 @@
+//go:build js && wasm
+
+// Command wasm demonstrates deterministic encryption in the browser. It
+// exposes sivSealString(keyHex, plaintext, ad), which returns the ciphertext as
+// unpadded base64url, or an Error for a malformed key.
+//
+// Build it and serve it alongside index.html and the Go distribution's
+// wasm_exec.js:
+//
+//	GOOS=js GOARCH=wasm go build -o main.wasm .
+//	cp "$(go env GOROOT)/lib/wasm/wasm_exec.js" .
+//
+// or, with TinyGo:
+//
+//	tinygo build -o main.wasm -target wasm .
+//	cp "$(tinygo env TINYGOROOT)/targets/wasm_exec.js" .
+package main
+
+import (
+	"crypto/aes"
+	"encoding/hex"
+	"syscall/js"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func sealString(this js.Value, args []js.Value) any {
+	if len(args) != 3 {
+		return jsError("expected key, plaintext, and ad")
+	}
+
+	key, err := hex.DecodeString(args[0].String())
+	if err != nil {
+		return jsError("malformed key")
+	}
+
+	aead, err := siv.New(key, aes.NewCipher)
+	if err != nil {
+		return jsError(err.Error())
+	}
+	return siv.SealString(aead, []byte(args[1].String()), []byte(args[2].String()))
+}
+
+func jsError(msg string) js.Value {
+	return js.Global().Get("Error").New("sivSealString: " + msg)
+}
+
+func main() {
+	js.Global().Set("sivSealString", js.FuncOf(sealString))
+	select {}
+}
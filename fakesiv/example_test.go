@@ -0,0 +1,46 @@
+//go:build go1.21
+
+package fakesiv_test
+
+import (
+	"crypto/cipher"
+	"fmt"
+
+	"github.com/stripe/siv-go/fakesiv"
+)
+
+// Store saves encrypted values. In production it is given a siv AEAD.
+type Store struct {
+	AEAD cipher.AEAD
+	rows map[string][]byte
+}
+
+func (s *Store) Put(key, value string) {
+	s.rows[key] = s.AEAD.Seal(nil, nil, []byte(value), []byte(key))
+}
+
+func (s *Store) Get(key string) (string, error) {
+	b, err := s.AEAD.Open(nil, nil, s.rows[key], []byte(key))
+	return string(b), err
+}
+
+// Tests can inject the fake AEAD, and check the stored rows by eye.
+func Example() {
+	s := &Store{AEAD: fakesiv.New(), rows: map[string][]byte{}}
+	s.Put("email", "alice@example.com")
+
+	row := s.rows["email"]
+	fmt.Printf("%s...%s\n", row[:len(fakesiv.Marker)], row[fakesiv.Overhead:])
+
+	v, err := s.Get("email")
+	fmt.Println(v, err)
+
+	s.rows["phone"] = s.rows["email"]
+	_, err = s.Get("phone")
+	fmt.Println(err)
+
+	// Output:
+	// FAKESIV:...ALICE@EXAMPLE.COM
+	// alice@example.com <nil>
+	// message authentication failed
+}
@@ -0,0 +1,94 @@
+//go:build go1.21
+
+// Package fakesiv provides an AEAD for unit tests which looks like a siv AEAD
+// but is NOT ENCRYPTION. Its ciphertexts are the plaintext with the case of
+// its ASCII letters swapped, behind a marker and a non-cryptographic checksum,
+// so they are deterministic, fast to produce, and readable in fixtures:
+//
+//	FAKESIV:<8-byte checksum>HELLO, WORLD
+//
+// It behaves like a siv AEAD in the ways tests usually depend on: it accepts
+// nonces of any length, has the same overhead, and fails to open with
+// siv.ErrAuthentication if the ciphertext, nonce, or associated data don't
+// match. It offers no confidentiality or integrity whatsoever, and New panics
+// outside of tests.
+package fakesiv
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"hash/fnv"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+// Marker begins every fake ciphertext.
+const Marker = "FAKESIV:"
+
+// Overhead is the length of the marker and checksum, which is the same as a
+// siv AEAD's overhead.
+const Overhead = len(Marker) + 8
+
+// New returns a fake AEAD. It panics if it isn't called from a test binary,
+// so that it can't be shipped by accident.
+func New() cipher.AEAD {
+	if !testing.Testing() {
+		panic("fakesiv: the fake AEAD is insecure and may only be used in tests")
+	}
+	return fake{}
+}
+
+type fake struct{}
+
+func (fake) NonceSize() int {
+	return 0
+}
+
+func (fake) Overhead() int {
+	return Overhead
+}
+
+func (fake) Seal(dst, nonce, plaintext, data []byte) []byte {
+	ret := append(dst, Marker...)
+	ret = binary.BigEndian.AppendUint64(ret, checksum(nonce, data, plaintext))
+	for _, b := range plaintext {
+		ret = append(ret, swapCase(b))
+	}
+	return ret
+}
+
+func (fake) Open(dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	if len(ciphertext) < Overhead || string(ciphertext[:len(Marker)]) != Marker {
+		return nil, siv.ErrAuthentication
+	}
+
+	sum := binary.BigEndian.Uint64(ciphertext[len(Marker):Overhead])
+	plaintext := make([]byte, len(ciphertext)-Overhead)
+	for i, b := range ciphertext[Overhead:] {
+		plaintext[i] = swapCase(b)
+	}
+	if sum != checksum(nonce, data, plaintext) {
+		return nil, siv.ErrAuthentication
+	}
+	return append(dst, plaintext...), nil
+}
+
+// checksum is an FNV-1a hash of the length-prefixed inputs. It detects
+// mistakes in tests, not tampering.
+func checksum(parts ...[]byte) uint64 {
+	h := fnv.New64a()
+	for _, p := range parts {
+		_ = binary.Write(h, binary.BigEndian, uint64(len(p)))
+		_, _ = h.Write(p)
+	}
+	return h.Sum64()
+}
+
+// swapCase swaps the case of ASCII letters, and leaves other bytes alone.
+func swapCase(b byte) byte {
+	if (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') {
+		return b ^ 0x20
+	}
+	return b
+}
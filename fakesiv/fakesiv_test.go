@@ -0,0 +1,78 @@
+//go:build go1.21
+
+package fakesiv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func TestFake(t *testing.T) {
+	aead := New()
+	ciphertext := aead.Seal(nil, nil, []byte("Hello, world"), []byte("ad"))
+
+	if !bytes.HasPrefix(ciphertext, []byte(Marker)) || !bytes.HasSuffix(ciphertext, []byte("hELLO, WORLD")) {
+		t.Errorf("Ciphertext was %q", ciphertext)
+	}
+
+	plaintext, err := aead.Open([]byte("x"), nil, ciphertext, []byte("ad"))
+	if err != nil || string(plaintext) != "xHello, world" {
+		t.Errorf("Plaintext was %q, %v, but expected %q", plaintext, err, "xHello, world")
+	}
+
+	// It's deterministic.
+	if again := aead.Seal(nil, nil, []byte("Hello, world"), []byte("ad")); !bytes.Equal(again, ciphertext) {
+		t.Errorf("Ciphertext was %q, but expected %q", again, ciphertext)
+	}
+
+	// Empty plaintexts work.
+	if plaintext, err := aead.Open(nil, nil, aead.Seal(nil, nil, nil, nil), nil); err != nil || len(plaintext) != 0 {
+		t.Errorf("Plaintext was %q, %v", plaintext, err)
+	}
+}
+
+func TestFakeOpenErrors(t *testing.T) {
+	aead := New()
+	ciphertext := aead.Seal(nil, []byte("nonce"), []byte("Hello"), []byte("ad"))
+
+	for _, v := range []struct {
+		name              string
+		nonce, ciphertext []byte
+		data              []byte
+	}{
+		{"wrong nonce", nil, ciphertext, []byte("ad")},
+		{"wrong ad", []byte("nonce"), ciphertext, []byte("other")},
+		{"ad moved to nonce", []byte("ad"), ciphertext, []byte("nonce")},
+		{"tampered", []byte("nonce"), append(append([]byte{}, ciphertext[:len(ciphertext)-1]...), 'x'), []byte("ad")},
+		{"truncated", []byte("nonce"), ciphertext[:Overhead-1], []byte("ad")},
+		{"no marker", []byte("nonce"), append([]byte("REALSIV:"), ciphertext[len(Marker):]...), []byte("ad")},
+	} {
+		if plaintext, err := aead.Open(nil, v.nonce, v.ciphertext, v.data); err != siv.ErrAuthentication {
+			t.Fatalf("%s: Plaintext returned instead of error: %q", v.name, plaintext)
+		}
+	}
+}
+
+func TestFakeLikeSIV(t *testing.T) {
+	real, err := siv.New([]byte("0123456789abcdef0123456789ABCDEF"), aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead := New()
+
+	if aead.NonceSize() != real.NonceSize() || aead.Overhead() != real.Overhead() {
+		t.Errorf("Sizes were %d and %d, but expected %d and %d", aead.NonceSize(), aead.Overhead(), real.NonceSize(), real.Overhead())
+	}
+
+	if n, expected := len(aead.Seal(nil, nil, make([]byte, 100), nil)), len(real.Seal(nil, nil, make([]byte, 100), nil)); n != expected {
+		t.Errorf("Ciphertext was %d bytes, but expected %d", n, expected)
+	}
+
+	// Real ciphertexts don't open.
+	if plaintext, err := aead.Open(nil, nil, real.Seal(nil, nil, []byte("Hello"), nil), nil); err != siv.ErrAuthentication {
+		t.Fatalf("Plaintext returned instead of error: %q", plaintext)
+	}
+}
@@ -0,0 +1,34 @@
+package siv
+
+import (
+	"crypto/aes"
+)
+
+// FingerprintSize is the size of a key fingerprint, in bytes.
+const FingerprintSize = 8
+
+// fingerprintData is the associated data sealed to produce a fingerprint.
+var fingerprintData = []byte("siv key fingerprint")
+
+// Fingerprint returns a short, non-secret value derived from the AEAD's key,
+// which can be used to tell which of several keys a message was sealed under
+// or whether the key in use is the expected one. It identifies a key but does
+// not authenticate it: distinct keys have the same fingerprint with
+// probability 2^-64, and anyone can claim any fingerprint.
+//
+// The fingerprint is the encrypted portion of
+// Seal(nil, nil, make([]byte, FingerprintSize), []byte("siv key fingerprint")),
+// so it depends on both halves of the key.
+func (s *SIV) Fingerprint() []byte {
+	return s.Seal(nil, nil, make([]byte, FingerprintSize), fingerprintData)[s.Overhead():]
+}
+
+// KeyFingerprint returns the fingerprint of an AES-SIV key, as returned by the
+// Fingerprint method of the AEAD constructed with New(key, aes.NewCipher).
+func KeyFingerprint(key []byte) ([]byte, error) {
+	aead, err := New(key, aes.NewCipher)
+	if err != nil {
+		return nil, err
+	}
+	return aead.(*SIV).Fingerprint(), nil
+}
@@ -0,0 +1,99 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"testing"
+)
+
+func TestFingerprintVectors(t *testing.T) {
+	for _, v := range []struct {
+		key, fingerprint string
+	}{
+		{
+			key:         "fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff",
+			fingerprint: "af19846f5af826a7",
+		},
+		{
+			key:         "7f7e7d7c7b7a79787776757473727170404142434445464748494a4b4c4d4e4f",
+			fingerprint: "ccc53406db272f2d",
+		},
+		{
+			key:         "000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f202122232425262728292a2b2c2d2e2f303132333435363738393a3b3c3d3e3f",
+			fingerprint: "e119777b81818303",
+		},
+	} {
+		key, _ := hex.DecodeString(v.key)
+		expected, _ := hex.DecodeString(v.fingerprint)
+
+		actual, err := KeyFingerprint(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(actual, expected) {
+			t.Errorf("Fingerprint of %s was %x, but expected %x", v.key, actual, expected)
+		}
+	}
+}
+
+func TestFingerprintDependsOnBothHalves(t *testing.T) {
+	key := testKey(0, 32)
+	a, err := KeyFingerprint(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key[0] ^= 1
+	b, err := KeyFingerprint(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key[0] ^= 1
+	key[31] ^= 1
+	c, err := KeyFingerprint(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Equal(a, b) || bytes.Equal(a, c) || bytes.Equal(b, c) {
+		t.Errorf("Fingerprints were %x, %x, and %x, but expected them to differ", a, b, c)
+	}
+}
+
+func TestFingerprintBadKeySize(t *testing.T) {
+	if fp, err := KeyFingerprint(make([]byte, 16)); err == nil {
+		t.Fatalf("Fingerprint returned instead of error: %x", fp)
+	}
+}
+
+func TestFingerprintKeySelection(t *testing.T) {
+	// A keyring can store a message's key fingerprint alongside it, and use it
+	// to pick the right key rather than trying each in turn.
+	keys := make(map[string]cipher.AEAD)
+	for i := byte(1); i <= 3; i++ {
+		aead, _ := New(bytes.Repeat([]byte{i}, 32), aes.NewCipher, AllowEqualHalves())
+		keys[string(aead.(*SIV).Fingerprint())] = aead
+	}
+
+	sealer, _ := New(bytes.Repeat([]byte{2}, 32), aes.NewCipher, AllowEqualHalves())
+	fingerprint := sealer.(*SIV).Fingerprint()
+	ciphertext := sealer.Seal(nil, nil, []byte("yay"), nil)
+
+	aead, ok := keys[string(fingerprint)]
+	if !ok {
+		t.Fatalf("No key with fingerprint %x", fingerprint)
+	}
+
+	if _, err := aead.Open(nil, nil, ciphertext, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	stranger, _ := New(bytes.Repeat([]byte{4}, 32), aes.NewCipher, AllowEqualHalves())
+	if _, ok := keys[string(stranger.(*SIV).Fingerprint())]; ok {
+		t.Error("Unknown key matched a fingerprint")
+	}
+}
@@ -0,0 +1,95 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// ErrFIPSPolicy is returned by New in FIPS mode when the block cipher is
+	// not crypto/aes.
+	ErrFIPSPolicy = errors.New("siv: block cipher not allowed in FIPS mode")
+
+	// ErrFIPSSelfTest is returned by New in FIPS mode, from then on, if the
+	// known-answer self test fails.
+	ErrFIPSSelfTest = errors.New("siv: FIPS known-answer self test failed")
+)
+
+var (
+	fipsMode atomic.Bool
+
+	fipsOnce   sync.Once
+	fipsResult error
+
+	// fipsKnownAnswer is the test run by fipsSelfTest, replaceable by tests.
+	fipsKnownAnswer = knownAnswerTest
+)
+
+// EnableFIPSMode restricts the package to FIPS-approved building blocks: New
+// (and so every constructor built on it) accepts only crypto/aes, whose
+// implementation is the validated module when Go is built for FIPS 140. The
+// first AEAD constructed afterwards runs a known-answer test, and if it fails
+// every later constructor returns ErrFIPSSelfTest.
+//
+// FIPS mode cannot be disabled once enabled. Building with the fipsmode tag
+// enables it before main runs. AEADs constructed earlier are unaffected.
+//
+// Options which change only the ciphertext format are allowed; the package
+// offers no truncated tags or other weakened constructions to restrict.
+func EnableFIPSMode() {
+	fipsMode.Store(true)
+}
+
+// FIPSMode reports whether FIPS mode is enabled, for auditing.
+func FIPSMode() bool {
+	return fipsMode.Load()
+}
+
+// fipsSelfTest runs the known-answer test once, on first use in FIPS mode,
+// and returns ErrFIPSSelfTest if it ever failed.
+func fipsSelfTest() error {
+	if !FIPSMode() {
+		return nil
+	}
+
+	fipsOnce.Do(func() {
+		if !fipsKnownAnswer() {
+			fipsResult = ErrFIPSSelfTest
+		}
+	})
+	return fipsResult
+}
+
+// knownAnswerTest checks the vector of RFC 5297 appendix A.1 in both
+// directions.
+func knownAnswerTest() bool {
+	key := []byte{
+		0xff, 0xfe, 0xfd, 0xfc, 0xfb, 0xfa, 0xf9, 0xf8, 0xf7, 0xf6, 0xf5, 0xf4, 0xf3, 0xf2, 0xf1, 0xf0,
+		0xf0, 0xf1, 0xf2, 0xf3, 0xf4, 0xf5, 0xf6, 0xf7, 0xf8, 0xf9, 0xfa, 0xfb, 0xfc, 0xfd, 0xfe, 0xff,
+	}
+	data := []byte{
+		0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f,
+		0x20, 0x21, 0x22, 0x23, 0x24, 0x25, 0x26, 0x27,
+	}
+	plaintext := []byte{0x11, 0x22, 0x33, 0x44, 0x55, 0x66, 0x77, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee}
+	ciphertext := []byte{
+		0x85, 0x63, 0x2d, 0x07, 0xc6, 0xe8, 0xf3, 0x7f, 0x95, 0x0a, 0xcd, 0x32, 0x0a, 0x2e, 0xcc, 0x93,
+		0x40, 0xc0, 0x2b, 0x96, 0x90, 0xc4, 0xdc, 0x04, 0xda, 0xef, 0x7f, 0x6a, 0xfe, 0x5c,
+	}
+
+	s, err := newSIV(key, aes.NewCipher)
+	if err != nil {
+		return false
+	}
+
+	sealed, err := s.SealAppend(nil, nil, plaintext, data)
+	if err != nil || !bytes.Equal(sealed, ciphertext) {
+		return false
+	}
+
+	opened, err := s.OpenAppend(nil, nil, ciphertext, data)
+	return err == nil && bytes.Equal(opened, plaintext)
+}
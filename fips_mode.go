@@ -0,0 +1,7 @@
+//go:build fipsmode
+
+package siv
+
+func init() {
+	EnableFIPSMode()
+}
@@ -0,0 +1,11 @@
+//go:build fipsmode
+
+package siv
+
+import "testing"
+
+func TestFIPSModeTag(t *testing.T) {
+	if !FIPSMode() {
+		t.Error("FIPS mode not enabled by the fipsmode tag")
+	}
+}
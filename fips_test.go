@@ -0,0 +1,127 @@
+package siv
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"sync"
+	"testing"
+
+	"github.com/stripe/siv-go/internal/testciphers"
+)
+
+// withFIPSMode runs the test in FIPS mode with a fresh self test, restoring
+// the previous state afterwards.
+func withFIPSMode(t *testing.T) {
+	mode, result := fipsMode.Load(), fipsResult
+	t.Cleanup(func() {
+		fipsMode.Store(mode)
+		fipsOnce = sync.Once{}
+		fipsResult = result
+		fipsKnownAnswer = knownAnswerTest
+	})
+
+	fipsOnce = sync.Once{}
+	fipsResult = nil
+	EnableFIPSMode()
+}
+
+func TestKnownAnswerTest(t *testing.T) {
+	if !knownAnswerTest() {
+		t.Error("Known-answer test failed")
+	}
+}
+
+func TestFIPSModeDisabled(t *testing.T) {
+	if FIPSMode() {
+		t.Skip("built with the fipsmode tag")
+	}
+
+	if _, err := New(testKey(0, 32), testciphers.NewCamellia); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFIPSModeEnabled(t *testing.T) {
+	withFIPSMode(t)
+
+	if !FIPSMode() {
+		t.Fatal("FIPS mode not enabled")
+	}
+
+	if _, err := New(testKey(0, 32), aes.NewCipher, WithPadding(16)); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := NewNamed(AESSIVCMAC512, testKey(0, 64)); err != nil {
+		t.Error(err)
+	}
+
+	_ = NewAEAD256(Key256{1})
+
+	// A factory which wraps crypto/aes is refused too, as its blocks aren't
+	// known to be crypto/aes.
+	wrapped := func(key []byte) (cipher.Block, error) {
+		b, err := aes.NewCipher(key)
+		return struct{ cipher.Block }{b}, err
+	}
+
+	for _, alg := range []BlockFactory{testciphers.NewCamellia, testciphers.NewARIA, testciphers.NewSM4, wrapped} {
+		if aead, err := New(testKey(0, 32), alg); err != ErrFIPSPolicy {
+			t.Errorf("Error was %v, but expected %v (AEAD %v)", err, ErrFIPSPolicy, aead)
+		}
+	}
+
+	if aead, err := NewFromShares(testciphers.NewCamellia, testKey(0, 32), make([]byte, 32)); err != ErrFIPSPolicy {
+		t.Errorf("Error was %v, but expected %v (AEAD %v)", err, ErrFIPSPolicy, aead)
+	}
+}
+
+func TestFIPSSelfTestFailure(t *testing.T) {
+	withFIPSMode(t)
+
+	runs := 0
+	fipsKnownAnswer = func() bool {
+		runs++
+		return false
+	}
+
+	for i := 0; i < 3; i++ {
+		if aead, err := New(testKey(0, 32), aes.NewCipher); err != ErrFIPSSelfTest {
+			t.Errorf("Error was %v, but expected %v (AEAD %v)", err, ErrFIPSSelfTest, aead)
+		}
+	}
+
+	// The package stays poisoned even if the test would now pass.
+	fipsKnownAnswer = knownAnswerTest
+	if _, err := NewNamed(AESSIVCMAC256, testKey(0, 32)); err != ErrFIPSSelfTest {
+		t.Errorf("Error was %v, but expected %v", err, ErrFIPSSelfTest)
+	}
+
+	if runs != 1 {
+		t.Errorf("Self test ran %d times, but expected %d", runs, 1)
+	}
+}
+
+func TestFIPSSelfTestOnFirstUse(t *testing.T) {
+	withFIPSMode(t)
+
+	runs := 0
+	fipsKnownAnswer = func() bool {
+		runs++
+		return knownAnswerTest()
+	}
+
+	if runs != 0 {
+		t.Errorf("Self test ran %d times before first use", runs)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := New(testKey(0, 32), aes.NewCipher); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if runs != 1 {
+		t.Errorf("Self test ran %d times, but expected %d", runs, 1)
+	}
+}
@@ -0,0 +1,66 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// MaxFrameSize is the largest ciphertext Frames will accept, in bytes.
+const MaxFrameSize = 1 << 30
+
+var (
+	// ErrFrameTruncated is returned when a frame's length prefix or
+	// ciphertext is cut short.
+	ErrFrameTruncated = errors.New("siv: truncated frame")
+
+	// ErrFrameTooLarge is returned when a frame's length prefix exceeds
+	// MaxFrameSize or is not a valid uvarint.
+	ErrFrameTooLarge = errors.New("siv: frame too large")
+)
+
+// AppendFrame seals plaintext and appends it to dst, prefixed with the length
+// of the ciphertext as a uvarint. The length is that of the sealed
+// ciphertext, as Overhead is only an upper bound for AEADs such as those
+// with WithPadding.
+func AppendFrame(dst []byte, aead cipher.AEAD, plaintext, ad []byte) []byte {
+	ciphertext := aead.Seal(nil, nil, plaintext, ad)
+	dst = binary.AppendUvarint(dst, uint64(len(ciphertext)))
+	return append(dst, ciphertext...)
+}
+
+// Frames returns an iterator over the frames appended to data by AppendFrame.
+// Each call returns the next ciphertext, which must still be opened, and the
+// data following it. Once data is exhausted it returns io.EOF; after any error
+// every further call returns the same error.
+func Frames(data []byte) func() (ciphertext []byte, rest []byte, err error) {
+	var err error
+	return func() ([]byte, []byte, error) {
+		if err != nil {
+			return nil, data, err
+		}
+
+		if len(data) == 0 {
+			err = io.EOF
+			return nil, data, err
+		}
+
+		n, k := binary.Uvarint(data)
+		switch {
+		case k == 0:
+			err = ErrFrameTruncated
+		case k < 0 || n > MaxFrameSize:
+			err = ErrFrameTooLarge
+		case n > uint64(len(data)-k):
+			err = ErrFrameTruncated
+		}
+		if err != nil {
+			return nil, data, err
+		}
+
+		ciphertext := data[k : k+int(n)]
+		data = data[k+int(n):]
+		return ciphertext, data, nil
+	}
+}
@@ -0,0 +1,148 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func TestFramesRoundTrip(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	r := rand.New(rand.NewSource(1))
+
+	var plaintexts [][]byte
+	var data []byte
+	for i := 0; i < 500; i++ {
+		p := make([]byte, r.Intn(300))
+		r.Read(p)
+		if i%50 == 0 {
+			p = make([]byte, 20000)
+		}
+		plaintexts = append(plaintexts, p)
+		data = AppendFrame(data, aead, p, []byte(fmt.Sprint(i)))
+	}
+
+	next := Frames(data)
+	for i, p := range plaintexts {
+		ciphertext, _, err := next()
+		if err != nil {
+			t.Fatalf("Frame %d: %v", i, err)
+		}
+
+		actual, err := aead.Open(nil, nil, ciphertext, []byte(fmt.Sprint(i)))
+		if err != nil {
+			t.Fatalf("Frame %d: %v", i, err)
+		}
+
+		if !bytes.Equal(actual, p) {
+			t.Fatalf("Frame %d was %x, but expected %x", i, actual, p)
+		}
+	}
+
+	if _, rest, err := next(); err != io.EOF || len(rest) != 0 {
+		t.Errorf("Error was %v with %d bytes left, but expected EOF", err, len(rest))
+	}
+}
+
+func TestFramesPadding(t *testing.T) {
+	// The padded AEAD's Overhead is only an upper bound on its expansion.
+	aead, err := New(testKey(0, 32), aes.NewCipher, WithPadding(16))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data []byte
+	for _, p := range []string{"", "a", "hello, frames", "exactly sixteen!"} {
+		data = AppendFrame(data, aead, []byte(p), nil)
+	}
+
+	next := Frames(data)
+	for _, p := range []string{"", "a", "hello, frames", "exactly sixteen!"} {
+		ciphertext, _, err := next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if actual, err := aead.Open(nil, nil, ciphertext, nil); err != nil || string(actual) != p {
+			t.Errorf("Frame was %q, %v, but expected %q", actual, err, p)
+		}
+	}
+	if _, _, err := next(); err != io.EOF {
+		t.Errorf("Error was %v, but expected EOF", err)
+	}
+}
+
+func TestFramesRest(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	data := AppendFrame(nil, aead, []byte("one"), nil)
+	first := len(data)
+	data = AppendFrame(data, aead, []byte("two"), nil)
+
+	_, rest, err := Frames(data)()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(rest, data[first:]) {
+		t.Errorf("Rest was %x, but expected %x", rest, data[first:])
+	}
+}
+
+func TestFramesTruncated(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	data := AppendFrame(nil, aead, []byte("one"), nil)
+	data = AppendFrame(data, aead, make([]byte, 200), nil)
+
+	for n := len(data) - 1; n > 20; n-- {
+		next := Frames(data[:n])
+		if _, _, err := next(); err != nil {
+			t.Fatalf("%d: %v", n, err)
+		}
+
+		if _, _, err := next(); err != ErrFrameTruncated {
+			t.Errorf("%d: Error was %v, but expected %v", n, err, ErrFrameTruncated)
+		}
+
+		if _, _, err := next(); err != ErrFrameTruncated {
+			t.Errorf("%d: Error was %v, but expected it to persist", n, err)
+		}
+	}
+}
+
+func TestFramesCorruptedLength(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	data := AppendFrame(nil, aead, []byte("one"), nil)
+
+	// A length longer than the remaining data.
+	data[0] += 10
+	if _, _, err := Frames(data)(); err != ErrFrameTruncated {
+		t.Errorf("Error was %v, but expected %v", err, ErrFrameTruncated)
+	}
+
+	// A length longer than the limit.
+	huge := binary.AppendUvarint(nil, MaxFrameSize+1)
+	if _, _, err := Frames(append(huge, make([]byte, 100)...))(); err != ErrFrameTooLarge {
+		t.Errorf("Error was %v, but expected %v", err, ErrFrameTooLarge)
+	}
+
+	// A uvarint which overflows 64 bits.
+	overflow := bytes.Repeat([]byte{0xff}, 11)
+	if _, _, err := Frames(overflow)(); err != ErrFrameTooLarge {
+		t.Errorf("Error was %v, but expected %v", err, ErrFrameTooLarge)
+	}
+
+	// A corrupted length which still fits opens as garbage.
+	data = AppendFrame(nil, aead, make([]byte, 100), nil)
+	data[0]--
+	ciphertext, _, err := Frames(data)()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := aead.Open(nil, nil, ciphertext, nil); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+}
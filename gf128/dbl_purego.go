@@ -0,0 +1,8 @@
+//go:build purego
+
+package gf128
+
+// Dbl doubles b in place in the CMAC convention: it multiplies b by x.
+func Dbl(b *[16]byte) {
+	dblGeneric(b)
+}
@@ -0,0 +1,56 @@
+//go:build !purego
+
+package gf128
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+)
+
+func TestDblWords(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	edges := [][]byte{
+		make([]byte, 16),
+		bytes.Repeat([]byte{0xff}, 16),
+		append([]byte{0x80}, make([]byte, 15)...),
+		append(make([]byte, 15), 0x01),
+		append(make([]byte, 7), 0x80, 0, 0, 0, 0, 0, 0, 0, 0),
+	}
+
+	for i := 0; i < 10000; i++ {
+		var b [16]byte
+		if i < len(edges) {
+			copy(b[:], edges[i])
+		} else {
+			r.Read(b[:])
+		}
+
+		expected := b
+		dblGeneric(&expected)
+
+		actual := b
+		dblWords(&actual)
+
+		if actual != expected {
+			t.Fatalf("Double of %x was %x, but expected %x", b, actual, expected)
+		}
+	}
+}
+
+func BenchmarkDbl(b *testing.B) {
+	d := (*[16]byte)(bytes.Repeat([]byte{0xa5}, 16))
+
+	b.Run("generic", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			dblGeneric(d)
+		}
+	})
+
+	b.Run("words", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			dblWords(d)
+		}
+	})
+}
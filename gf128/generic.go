@@ -0,0 +1,14 @@
+package gf128
+
+// dblGeneric doubles b a byte at a time. It is used when built with the
+// purego tag, and to check dblWords.
+func dblGeneric(b *[16]byte) {
+	reduce := -(b[0] >> 7) & 0x87
+	var overflow byte
+	for i := len(b) - 1; i >= 0; i-- {
+		v := b[i]
+		b[i] = v<<1 | overflow
+		overflow = v >> 7
+	}
+	b[15] ^= reduce
+}
@@ -0,0 +1,98 @@
+// Package gf128 implements arithmetic in GF(2^128) for the two conventions
+// used by block cipher modes, in constant time.
+//
+// The CMAC convention, used by CMAC (NIST SP 800-38B), S2V (RFC 5297), and
+// PMAC, reads a block as a big-endian polynomial, with the most significant
+// bit of the first byte as the coefficient of x^127, modulo
+// x^128 + x^7 + x^2 + x + 1. Doubling is the left shift which CMAC derives its
+// subkeys with. Dbl and Mul use this convention.
+//
+// The POLYVAL convention (RFC 8452) reads a block as a little-endian
+// polynomial, with the least significant bit of the first byte as the
+// coefficient of x^0, modulo x^128 + x^127 + x^126 + x^121 + 1. PolyvalMulX,
+// PolyvalMul, and PolyvalDot use this convention.
+//
+// The conventions are different fields with different encodings: mixing them
+// gives wrong answers without any error.
+package gf128
+
+import "encoding/binary"
+
+// Mul returns x*y in the CMAC convention.
+func Mul(x, y *[16]byte) [16]byte {
+	xh, xl := binary.BigEndian.Uint64(x[:8]), binary.BigEndian.Uint64(x[8:])
+	yh, yl := binary.BigEndian.Uint64(y[:8]), binary.BigEndian.Uint64(y[8:])
+
+	// Horner's rule over the bits of y, most significant first.
+	var zh, zl uint64
+	for i := 0; i < 128; i++ {
+		reduce := -(zh >> 63) & 0x87
+		zh = zh<<1 | zl>>63
+		zl = zl<<1 ^ reduce
+
+		var bit uint64
+		if i < 64 {
+			bit = yh >> (63 - i) & 1
+		} else {
+			bit = yl >> (127 - i) & 1
+		}
+		mask := -bit
+		zh ^= xh & mask
+		zl ^= xl & mask
+	}
+
+	var z [16]byte
+	binary.BigEndian.PutUint64(z[:8], zh)
+	binary.BigEndian.PutUint64(z[8:], zl)
+	return z
+}
+
+// PolyvalMulX multiplies b by x in place in the POLYVAL convention: the
+// mulX_POLYVAL function of RFC 8452 appendix A.
+func PolyvalMulX(b *[16]byte) {
+	lo, hi := binary.LittleEndian.Uint64(b[:8]), binary.LittleEndian.Uint64(b[8:])
+
+	// x^128 = x^127 + x^126 + x^121 + 1.
+	carry := -(hi >> 63)
+	hi = hi<<1 | lo>>63
+	lo = lo<<1 ^ carry&1
+	hi ^= carry & 0xc200000000000000
+
+	binary.LittleEndian.PutUint64(b[:8], lo)
+	binary.LittleEndian.PutUint64(b[8:], hi)
+}
+
+// PolyvalMul returns x*y in the POLYVAL convention. This is the field
+// product; POLYVAL itself is defined with PolyvalDot.
+func PolyvalMul(x, y *[16]byte) [16]byte {
+	yl, yh := binary.LittleEndian.Uint64(y[:8]), binary.LittleEndian.Uint64(y[8:])
+
+	// Horner's rule over the bits of y, most significant first.
+	var z [16]byte
+	for i := 127; i >= 0; i-- {
+		PolyvalMulX(&z)
+
+		var bit uint64
+		if i >= 64 {
+			bit = yh >> (i - 64) & 1
+		} else {
+			bit = yl >> i & 1
+		}
+		mask := byte(-bit)
+		for j := range z {
+			z[j] ^= x[j] & mask
+		}
+	}
+	return z
+}
+
+// xInv128 is x^-128 in the POLYVAL convention.
+var xInv128 = [16]byte{0x01, 14: 0x04, 15: 0x92}
+
+// PolyvalDot returns x*y*x^-128 in the POLYVAL convention: the dot function
+// of RFC 8452 section 3, with which POLYVAL(H, X_1, ..., X_s) is
+// S_s, where S_0 = 0 and S_j = PolyvalDot(S_{j-1} xor X_j, H).
+func PolyvalDot(x, y *[16]byte) [16]byte {
+	z := PolyvalMul(x, y)
+	return PolyvalMul(&z, &xInv128)
+}
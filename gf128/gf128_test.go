@@ -0,0 +1,162 @@
+package gf128
+
+import (
+	"encoding/hex"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func block(s string) [16]byte {
+	var b [16]byte
+	if n, err := hex.Decode(b[:], []byte(s)); err != nil || n != 16 {
+		panic("bad block " + s)
+	}
+	return b
+}
+
+// TestCMACSubkeys checks Dbl against the subkey generation examples of NIST
+// SP 800-38B appendix D, where K1 = Dbl(L) and K2 = Dbl(K1).
+func TestCMACSubkeys(t *testing.T) {
+	for _, v := range []struct {
+		l, k1, k2 string
+	}{
+		{"7df76b0c1ab899b33e42f047b91b546f", "fbeed618357133667c85e08f7236a8de", "f7ddac306ae266ccf90bc11ee46d513b"},
+		{"22452d8e49a8a5939f7321ceea6d514b", "448a5b1c93514b273ee6439dd4daa296", "8914b63926a2964e7dcc873ba9b5452c"},
+		{"e568f68194cf76d6174d4cc04310a854", "cad1ed03299eedac2e9a99808621502f", "95a3da06533ddb585d3533010c42a0d9"},
+	} {
+		b := block(v.l)
+		Dbl(&b)
+		if b != block(v.k1) {
+			t.Errorf("K1 was %x, but expected %s", b, v.k1)
+		}
+		Dbl(&b)
+		if b != block(v.k2) {
+			t.Errorf("K2 was %x, but expected %s", b, v.k2)
+		}
+
+		// Doubling is multiplication by x.
+		l, x := block(v.l), [16]byte{15: 0x02}
+		if k1 := Mul(&l, &x); k1 != block(v.k1) {
+			t.Errorf("L*x was %x, but expected %s", k1, v.k1)
+		}
+	}
+}
+
+// TestPolyvalVectors checks the POLYVAL functions against RFC 8452 appendix
+// A.
+func TestPolyvalVectors(t *testing.T) {
+	for in, expected := range map[string]string{
+		"01000000000000000000000000000000": "02000000000000000000000000000000",
+		"9c98c04df9387ded828175a92ba652d8": "3931819bf271fada0503eb52574ca572",
+	} {
+		b := block(in)
+		PolyvalMulX(&b)
+		if b != block(expected) {
+			t.Errorf("mulX_POLYVAL(%s) was %x, but expected %s", in, b, expected)
+		}
+	}
+
+	h := block("25629347589242761d31f826ba4b757b")
+	var s [16]byte
+	for _, x := range []string{"4f4f95668c83dfb6401762bb2d01a262", "d1a24ddd2721d006bbe45f20d3c9f362"} {
+		xb := block(x)
+		for i := range s {
+			s[i] ^= xb[i]
+		}
+		s = PolyvalDot(&s, &h)
+	}
+	if expected := block("f7a3b47b846119fae5b7866cf5e5b77e"); s != expected {
+		t.Errorf("POLYVAL was %x, but expected %x", s, expected)
+	}
+}
+
+var (
+	cmacPoly    = polynomial(128, 7, 2, 1, 0)
+	polyvalPoly = polynomial(128, 127, 126, 121, 0)
+)
+
+func polynomial(exponents ...int) *big.Int {
+	p := new(big.Int)
+	for _, e := range exponents {
+		p.SetBit(p, e, 1)
+	}
+	return p
+}
+
+// mulReference multiplies a and b as polynomials over GF(2) and reduces the
+// product modulo p.
+func mulReference(a, b, p *big.Int) *big.Int {
+	product := new(big.Int)
+	for i := 0; i < b.BitLen(); i++ {
+		if b.Bit(i) == 1 {
+			product.Xor(product, new(big.Int).Lsh(a, uint(i)))
+		}
+	}
+	for i := product.BitLen() - 1; i >= 128; i-- {
+		if product.Bit(i) == 1 {
+			product.Xor(product, new(big.Int).Lsh(p, uint(i-128)))
+		}
+	}
+	return product
+}
+
+func bigEndian(b [16]byte) *big.Int {
+	return new(big.Int).SetBytes(b[:])
+}
+
+func littleEndian(b [16]byte) *big.Int {
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return new(big.Int).SetBytes(b[:])
+}
+
+func TestMulReference(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	for i := 0; i < 1000; i++ {
+		var x, y [16]byte
+		r.Read(x[:])
+		r.Read(y[:])
+		if i < 3 {
+			x[0], y[0] = 0xff, 0xff
+		}
+
+		if z := Mul(&x, &y); bigEndian(z).Cmp(mulReference(bigEndian(x), bigEndian(y), cmacPoly)) != 0 {
+			t.Fatalf("Mul(%x, %x) was %x", x, y, z)
+		}
+
+		if z := PolyvalMul(&x, &y); littleEndian(z).Cmp(mulReference(littleEndian(x), littleEndian(y), polyvalPoly)) != 0 {
+			t.Fatalf("PolyvalMul(%x, %x) was %x", x, y, z)
+		}
+
+		d := x
+		Dbl(&d)
+		if two := ([16]byte{15: 2}); d != Mul(&x, &two) {
+			t.Fatalf("Dbl(%x) was %x", x, d)
+		}
+
+		d = x
+		PolyvalMulX(&d)
+		if two := ([16]byte{0: 2}); d != PolyvalMul(&x, &two) {
+			t.Fatalf("PolyvalMulX(%x) was %x", x, d)
+		}
+	}
+}
+
+func BenchmarkMul(b *testing.B) {
+	x, y := block("25629347589242761d31f826ba4b757b"), block("4f4f95668c83dfb6401762bb2d01a262")
+
+	b.Run("CMAC", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			x = Mul(&x, &y)
+		}
+	})
+
+	b.Run("POLYVAL", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			x = PolyvalMul(&x, &y)
+		}
+	})
+}
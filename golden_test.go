@@ -0,0 +1,152 @@
+package siv
+
+//go:generate go run ./internal/gengolden -o testdata/golden
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type goldenEntry struct {
+	Name       string            `json:"name"`
+	Key        string            `json:"key"`
+	Context    []*string         `json:"context"`
+	AD         []*string         `json:"ad"`
+	Expires    int64             `json:"expires"`
+	Headers    map[string]string `json:"headers"`
+	Plaintext  string            `json:"plaintext"`
+	Ciphertext string            `json:"ciphertext"`
+}
+
+// TestGolden re-seals every entry of the golden corpus and checks the output
+// is byte-for-byte identical, then opens every golden ciphertext. A failure
+// here means previously stored ciphertexts may no longer open; regenerate the
+// corpus only for an intentional format change.
+func TestGolden(t *testing.T) {
+	for _, variant := range []string{
+		"seal", "multi", "context", "hashed", "padded", "appended",
+		"selfdescribing", "ttl", "headers", "compressed",
+	} {
+		b, err := os.ReadFile(filepath.Join("testdata", "golden", variant+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var entries []goldenEntry
+		if err := json.Unmarshal(b, &entries); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(entries) == 0 {
+			t.Fatalf("No golden entries for %s", variant)
+		}
+
+		for _, e := range entries {
+			testGolden(t, variant, e)
+		}
+	}
+}
+
+func testGolden(t *testing.T, variant string, e goldenEntry) {
+	key := goldenHex(t, e.Key)
+	plaintext := goldenHex(t, e.Plaintext)
+	expected := goldenHex(t, e.Ciphertext)
+	ad := goldenComponents(t, e.AD)
+
+	var opts []Option
+	switch variant {
+	case "padded":
+		opts = append(opts, WithPadding(16))
+	case "appended":
+		opts = append(opts, WithTagAppended())
+	}
+
+	aead, err := New(key, aes.NewCipher, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data []byte
+	if len(ad) == 1 {
+		data = ad[0]
+	}
+
+	var actual, p []byte
+	switch variant {
+	case "seal", "padded", "appended":
+		actual = aead.Seal(nil, nil, plaintext, data)
+		p, err = aead.Open(nil, nil, expected, data)
+	case "multi":
+		m := aead.(multiAEAD)
+		actual = m.SealMulti(nil, plaintext, ad...)
+		p, err = m.OpenMulti(nil, expected, ad...)
+	case "context":
+		c := NewContext(aead, goldenComponents(t, e.Context)...)
+		actual = c.Seal(nil, nil, plaintext, data)
+		p, err = c.Open(nil, nil, expected, data)
+	case "hashed":
+		m := WithHashedAD(aead, crypto.SHA256, 32).(multiAEAD)
+		actual = m.SealMulti(nil, plaintext, ad...)
+		p, err = m.OpenMulti(nil, expected, ad...)
+	case "selfdescribing":
+		id := map[int]byte{32: AlgAESSIVCMAC256, 48: AlgAESSIVCMAC384, 64: AlgAESSIVCMAC512}[len(key)]
+		sd, _ := NewSelfDescribing(aead, id)
+		actual = sd.Seal(nil, nil, plaintext, data)
+		p, err = OpenAuto(func(byte) (cipher.AEAD, error) { return aead, nil }, expected, data)
+	case "ttl":
+		expires := time.Unix(e.Expires, 0)
+		actual, _ = SealWithTTL(aead, plaintext, data, expires)
+		p, err = OpenWithExpiry(aead, expected, data, expires)
+	case "headers":
+		actual, _ = SealWithHeaders(aead, plaintext, e.Headers)
+		var headers map[string]string
+		p, headers, err = OpenWithHeaders(aead, expected)
+		if err == nil && len(headers)+len(e.Headers) > 0 && !reflect.DeepEqual(headers, e.Headers) {
+			t.Errorf("%s: Headers were %v, but expected %v", e.Name, headers, e.Headers)
+		}
+	case "compressed":
+		// Only opening is checked, as compress/flate's output may change
+		// between Go releases without affecting stored ciphertexts.
+		actual = expected
+		p, err = OpenCompressed(aead, expected, data, len(plaintext))
+	}
+
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("%s: Ciphertext was %x, but expected %x", e.Name, actual, expected)
+	}
+
+	if err != nil {
+		t.Errorf("%s: %v", e.Name, err)
+	} else if !bytes.Equal(p, plaintext) {
+		t.Errorf("%s: Plaintext was %x, but expected %x", e.Name, p, plaintext)
+	}
+}
+
+func goldenHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func goldenComponents(t *testing.T, v []*string) [][]byte {
+	var c [][]byte
+	for _, s := range v {
+		if s == nil {
+			c = append(c, nil)
+			continue
+		}
+		c = append(c, goldenHex(t, *s))
+	}
+	return c
+}
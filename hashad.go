@@ -0,0 +1,104 @@
+package siv
+
+import (
+	"crypto"
+	"crypto/cipher"
+	"encoding/binary"
+)
+
+// WithHashedAD returns an AEAD which replaces associated data components
+// longer than threshold bytes with their digest under h before passing them to
+// aead, so that very large associated data need only be hashed, not run
+// through CMAC, on every Seal and Open. The replacement is applied
+// identically on both sides, so messages sealed by the returned AEAD must be
+// opened by an AEAD wrapped the same way.
+//
+// To keep hashed and literal components distinct, every non-nil component is
+// prefixed with a single byte: 0x00 followed by the literal bytes for
+// components of at most threshold bytes, or 0x01, the 8-bit crypto.Hash
+// identifier of h, the 64-bit big-endian threshold, and the digest for longer
+// ones. A literal component can therefore never be mistaken for a hashed one,
+// and the associated data remains bound to the ciphertext as long as h is
+// collision resistant.
+//
+// It panics if h is not available, i.e. if its package has not been linked
+// into the binary.
+func WithHashedAD(aead cipher.AEAD, h crypto.Hash, threshold int) cipher.AEAD {
+	if !h.Available() {
+		panic("siv: hash function is not available")
+	}
+
+	a := &hashedAD{
+		aead:      aead,
+		hash:      h,
+		threshold: threshold,
+	}
+	if m, ok := aead.(multiAEAD); ok {
+		return &hashedMultiAD{hashedAD: a, multi: m}
+	}
+	return a
+}
+
+type hashedAD struct {
+	aead      cipher.AEAD
+	hash      crypto.Hash
+	threshold int
+}
+
+func (a *hashedAD) NonceSize() int {
+	return a.aead.NonceSize()
+}
+
+func (a *hashedAD) Overhead() int {
+	return a.aead.Overhead()
+}
+
+func (a *hashedAD) Seal(dst, nonce, plaintext, data []byte) []byte {
+	return a.aead.Seal(dst, nonce, plaintext, a.component(data))
+}
+
+func (a *hashedAD) Open(dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	return a.aead.Open(dst, nonce, ciphertext, a.component(data))
+}
+
+// hashedMultiAD is a hashedAD wrapping an AEAD which supports multiple
+// associated data components.
+type hashedMultiAD struct {
+	*hashedAD
+	multi multiAEAD
+}
+
+func (a *hashedMultiAD) SealMulti(dst, plaintext []byte, data ...[]byte) []byte {
+	return a.multi.SealMulti(dst, plaintext, a.components(data)...)
+}
+
+func (a *hashedMultiAD) OpenMulti(dst, ciphertext []byte, data ...[]byte) ([]byte, error) {
+	return a.multi.OpenMulti(dst, ciphertext, a.components(data)...)
+}
+
+func (a *hashedAD) components(data [][]byte) [][]byte {
+	c := make([][]byte, len(data))
+	for i, v := range data {
+		c[i] = a.component(v)
+	}
+	return c
+}
+
+func (a *hashedAD) component(v []byte) []byte {
+	if v == nil {
+		return nil
+	}
+
+	if len(v) <= a.threshold {
+		return append([]byte{0x00}, v...)
+	}
+
+	h := a.hash.New()
+	_, _ = h.Write(v)
+
+	c := make([]byte, 10, 10+h.Size())
+	c[0] = 0x01
+	c[1] = byte(a.hash)
+	binary.BigEndian.PutUint64(c[2:], uint64(a.threshold))
+	return h.Sum(c)
+}
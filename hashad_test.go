@@ -0,0 +1,129 @@
+package siv
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"testing"
+)
+
+func TestHashedADVectors(t *testing.T) {
+	key, _ := hex.DecodeString("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	aead, _ := New(key, aes.NewCipher)
+	h := WithHashedAD(aead, crypto.SHA256, 64)
+	plaintext, _ := hex.DecodeString("112233445566778899aabbccddee")
+
+	for _, v := range []struct {
+		data     []byte
+		expected string
+	}{
+		{nil, "f1c5fdeac1f15a26779c1501f9fb758827e946c669088ab06da58c5c831c"},
+		{[]byte("short"), "250277887000df09b0dfad630c88342b1a9dd8144ec19034c6494778b177"},
+		{bytes.Repeat([]byte{0xaa}, 1000), "e05c43ff92cc53c4c8d6b097e677ac21ff9e34909e50355370133bb7d753"},
+	} {
+		actual := h.Seal(nil, nil, plaintext, v.data)
+		if hex.EncodeToString(actual) != v.expected {
+			t.Errorf("Ciphertext was %x, but expected %s", actual, v.expected)
+		}
+
+		opened, err := h.Open(nil, nil, actual, v.data)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(opened, plaintext) {
+			t.Errorf("Plaintext was %x, but expected %x", opened, plaintext)
+		}
+	}
+}
+
+func TestHashedADComponents(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	h := WithHashedAD(aead, crypto.SHA256, 64)
+	plaintext := []byte("yay")
+
+	short := []byte("short")
+	if a, b := h.Seal(nil, nil, plaintext, short), aead.Seal(nil, nil, plaintext, append([]byte{0}, short...)); !bytes.Equal(a, b) {
+		t.Errorf("Ciphertext was %x, but expected %x", a, b)
+	}
+
+	long := bytes.Repeat([]byte{0xaa}, 65)
+	digest := sha256.Sum256(long)
+	component := []byte{1, byte(crypto.SHA256)}
+	component = binary.BigEndian.AppendUint64(component, 64)
+	component = append(component, digest[:]...)
+	if a, b := h.Seal(nil, nil, plaintext, long), aead.Seal(nil, nil, plaintext, component); !bytes.Equal(a, b) {
+		t.Errorf("Ciphertext was %x, but expected %x", a, b)
+	}
+
+	// The encoded form of a hashed component, supplied literally, is not
+	// confused with it.
+	if _, err := h.Open(nil, nil, h.Seal(nil, nil, plaintext, long), component[1:]); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+}
+
+func TestHashedADMismatch(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	long := bytes.Repeat([]byte{0xaa}, 1000)
+	ciphertext := WithHashedAD(aead, crypto.SHA256, 64).Seal(nil, nil, []byte("yay"), long)
+
+	for _, other := range []cipher.AEAD{
+		aead,
+		WithHashedAD(aead, crypto.SHA256, 128),
+		WithHashedAD(aead, crypto.SHA512, 64),
+	} {
+		if _, err := other.Open(nil, nil, ciphertext, long); err != ErrAuthentication {
+			t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+		}
+	}
+
+	long[999] ^= 1
+	if _, err := WithHashedAD(aead, crypto.SHA256, 64).Open(nil, nil, ciphertext, long); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+}
+
+func TestHashedADContext(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	manifest := bytes.Repeat([]byte{0xaa}, 1000)
+
+	c := NewContext(WithHashedAD(aead, crypto.SHA256, 64), manifest)
+	ciphertext := c.Seal(nil, nil, []byte("yay"), []byte("row"))
+
+	h := WithHashedAD(aead, crypto.SHA256, 64).(*hashedMultiAD)
+	expected := aead.(multiAEAD).SealMulti(nil, []byte("yay"),
+		h.component([]byte("siv context\x00\x00\x00\x01")), h.component(manifest), h.component([]byte("row")))
+	if !bytes.Equal(ciphertext, expected) {
+		t.Errorf("Ciphertext was %x, but expected %x", ciphertext, expected)
+	}
+
+	if _, err := c.Open(nil, nil, ciphertext, []byte("row")); err != nil {
+		t.Error(err)
+	}
+}
+
+func BenchmarkLargeAD(b *testing.B) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	data := make([]byte, 16<<20)
+
+	b.Run("literal", func(b *testing.B) {
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			aead.Seal(nil, nil, []byte("yay"), data)
+		}
+	})
+
+	b.Run("hashed", func(b *testing.B) {
+		h := WithHashedAD(aead, crypto.SHA256, 1024)
+		b.SetBytes(int64(len(data)))
+		for i := 0; i < b.N; i++ {
+			h.Seal(nil, nil, []byte("yay"), data)
+		}
+	})
+}
@@ -0,0 +1,132 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"sort"
+)
+
+const (
+	// MaxHeaders is the largest number of headers SealWithHeaders accepts.
+	MaxHeaders = 64
+
+	// MaxHeadersSize is the largest encoded size of the headers
+	// SealWithHeaders accepts, in bytes.
+	MaxHeadersSize = 16 << 10
+)
+
+// ErrHeadersTooLarge is returned when headers exceed MaxHeaders or
+// MaxHeadersSize.
+var ErrHeadersTooLarge = errors.New("siv: headers too large")
+
+// SealWithHeaders seals plaintext along with a map of headers, such as a key
+// ID or content type, which travel in the clear but are authenticated. The
+// headers are encoded canonically, as a 16-bit big-endian count followed by
+// each key and its value in ascending key order, each prefixed with its 16-bit
+// big-endian length. The ciphertext is the encoded headers, prefixed with
+// their 32-bit big-endian length, followed by the plaintext sealed with the
+// encoded headers bound as associated data.
+func SealWithHeaders(aead cipher.AEAD, plaintext []byte, headers map[string]string) ([]byte, error) {
+	if len(headers) > MaxHeaders {
+		return nil, ErrHeadersTooLarge
+	}
+
+	keys := make([]string, 0, len(headers))
+	size := 2
+	for k, v := range headers {
+		keys = append(keys, k)
+		size += 2 + len(k) + 2 + len(v)
+	}
+	if size > MaxHeadersSize {
+		return nil, ErrHeadersTooLarge
+	}
+	sort.Strings(keys)
+
+	out := make([]byte, 4, 4+size+aead.Overhead()+len(plaintext))
+	binary.BigEndian.PutUint32(out, uint32(size))
+	out = binary.BigEndian.AppendUint16(out, uint16(len(keys)))
+	for _, k := range keys {
+		out = binary.BigEndian.AppendUint16(out, uint16(len(k)))
+		out = append(out, k...)
+		out = binary.BigEndian.AppendUint16(out, uint16(len(headers[k])))
+		out = append(out, headers[k]...)
+	}
+
+	return bindHeaders(aead, out[4:]).Seal(out, nil, plaintext, nil), nil
+}
+
+// OpenWithHeaders opens a ciphertext sealed by SealWithHeaders, returning the
+// plaintext and the headers. Headers which have been altered, reordered, or
+// encoded other than canonically fail to authenticate. It returns
+// ErrHeadersTooLarge, before allocating anything, for headers whose declared
+// size exceeds MaxHeadersSize.
+func OpenWithHeaders(aead cipher.AEAD, ciphertext []byte) ([]byte, map[string]string, error) {
+	if len(ciphertext) < 4 {
+		return nil, nil, ErrAuthentication
+	}
+
+	size := binary.BigEndian.Uint32(ciphertext)
+	if size > MaxHeadersSize {
+		return nil, nil, ErrHeadersTooLarge
+	}
+	if uint64(len(ciphertext)-4) < uint64(size) {
+		return nil, nil, ErrAuthentication
+	}
+	encoded, ciphertext := ciphertext[4:4+size], ciphertext[4+size:]
+
+	headers, ok := parseHeaders(encoded)
+	if !ok {
+		return nil, nil, ErrAuthentication
+	}
+
+	plaintext, err := bindHeaders(aead, encoded).Open(nil, nil, ciphertext, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plaintext, headers, nil
+}
+
+// parseHeaders decodes canonically encoded headers.
+func parseHeaders(b []byte) (map[string]string, bool) {
+	next := func() (string, bool) {
+		if len(b) < 2 {
+			return "", false
+		}
+		n := int(binary.BigEndian.Uint16(b))
+		if len(b)-2 < n {
+			return "", false
+		}
+		s := string(b[2 : 2+n])
+		b = b[2+n:]
+		return s, true
+	}
+
+	if len(b) < 2 {
+		return nil, false
+	}
+	count := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	if count > MaxHeaders {
+		return nil, false
+	}
+
+	headers := make(map[string]string, count)
+	prev := ""
+	for i := 0; i < count; i++ {
+		k, ok := next()
+		if !ok || (i > 0 && k <= prev) {
+			return nil, false
+		}
+		v, ok := next()
+		if !ok {
+			return nil, false
+		}
+		headers[k], prev = v, k
+	}
+	return headers, len(b) == 0
+}
+
+func bindHeaders(aead cipher.AEAD, encoded []byte) cipher.AEAD {
+	return NewContext(aead, []byte("siv headers"), encoded)
+}
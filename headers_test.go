@@ -0,0 +1,136 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestHeadersGolden checks SealWithHeaders against fixtures computed with an
+// independent implementation, pinning the header encoding.
+func TestHeadersGolden(t *testing.T) {
+	b, err := os.ReadFile("testdata/headers.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []struct {
+		Name       string            `json:"name"`
+		Key        string            `json:"key"`
+		Headers    map[string]string `json:"headers"`
+		Plaintext  string            `json:"plaintext"`
+		Ciphertext string            `json:"ciphertext"`
+	}
+	if err := json.Unmarshal(b, &entries); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range entries {
+		key, _ := hex.DecodeString(e.Key)
+		plaintext, _ := hex.DecodeString(e.Plaintext)
+		expected, _ := hex.DecodeString(e.Ciphertext)
+		aead, _ := New(key, aes.NewCipher)
+
+		actual, err := SealWithHeaders(aead, plaintext, e.Headers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(actual, expected) {
+			t.Errorf("%s: Ciphertext was %x, but expected %x", e.Name, actual, expected)
+		}
+
+		p, headers, err := OpenWithHeaders(aead, expected)
+		if err != nil {
+			t.Fatalf("%s: %v", e.Name, err)
+		}
+		if !bytes.Equal(p, plaintext) {
+			t.Errorf("%s: Plaintext was %x, but expected %x", e.Name, p, plaintext)
+		}
+		if !reflect.DeepEqual(headers, e.Headers) {
+			t.Errorf("%s: Headers were %v, but expected %v", e.Name, headers, e.Headers)
+		}
+	}
+}
+
+func TestHeadersTampering(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	headers := map[string]string{"kid": "k1", "tenant": "acct_1"}
+	ciphertext, _ := SealWithHeaders(aead, []byte("payload"), headers)
+
+	// Flip every bit of the length and headers.
+	for i := 0; i < 4+2+2+3+2+2+2+6+2+6; i++ {
+		for bit := 0; bit < 8; bit++ {
+			tampered := append([]byte{}, ciphertext...)
+			tampered[i] ^= 1 << bit
+			if p, h, err := OpenWithHeaders(aead, tampered); err == nil {
+				t.Fatalf("Plaintext returned instead of error: %x %v", p, h)
+			}
+		}
+	}
+
+	// Swap the two headers, fixing up nothing else: they decode to the same
+	// map, but aren't canonical.
+	swapped := append([]byte{}, ciphertext[:6]...)
+	swapped = append(swapped, ciphertext[6+2+3+2+2:6+2+3+2+2+2+6+2+6]...)
+	swapped = append(swapped, ciphertext[6:6+2+3+2+2]...)
+	swapped = append(swapped, ciphertext[6+2+3+2+2+2+6+2+6:]...)
+	if len(swapped) != len(ciphertext) {
+		t.Fatalf("Swapped ciphertext was %d bytes, but expected %d", len(swapped), len(ciphertext))
+	}
+	if _, _, err := OpenWithHeaders(aead, swapped); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+
+	// Headers can't be moved to another ciphertext.
+	other, _ := SealWithHeaders(aead, []byte("payload"), map[string]string{"kid": "k2", "tenant": "acct_1"})
+	moved := append(append([]byte{}, other[:len(other)-len("payload")-16]...), ciphertext[len(ciphertext)-len("payload")-16:]...)
+	if _, _, err := OpenWithHeaders(aead, moved); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+
+	for _, short := range [][]byte{nil, ciphertext[:3], ciphertext[:10]} {
+		if _, _, err := OpenWithHeaders(aead, short); err != ErrAuthentication {
+			t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+		}
+	}
+}
+
+func TestHeadersLimits(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+
+	many := make(map[string]string)
+	for i := 0; i <= MaxHeaders; i++ {
+		many[string(rune('A'+i))] = ""
+	}
+	if _, err := SealWithHeaders(aead, nil, many); err != ErrHeadersTooLarge {
+		t.Errorf("Error was %v, but expected %v", err, ErrHeadersTooLarge)
+	}
+
+	large := map[string]string{"k": strings.Repeat("v", MaxHeadersSize)}
+	if _, err := SealWithHeaders(aead, nil, large); err != ErrHeadersTooLarge {
+		t.Errorf("Error was %v, but expected %v", err, ErrHeadersTooLarge)
+	}
+
+	// The largest headers allowed round-trip.
+	largest := map[string]string{"k": strings.Repeat("v", MaxHeadersSize-2-2-1-2)}
+	ciphertext, err := SealWithHeaders(aead, nil, largest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, h, err := OpenWithHeaders(aead, ciphertext); err != nil || !reflect.DeepEqual(h, largest) {
+		t.Errorf("Headers didn't round-trip: %v", err)
+	}
+
+	// A declared size over the limit is refused before anything else.
+	declared := make([]byte, 4)
+	binary.BigEndian.PutUint32(declared, 1<<31)
+	if _, _, err := OpenWithHeaders(aead, declared); err != ErrHeadersTooLarge {
+		t.Errorf("Error was %v, but expected %v", err, ErrHeadersTooLarge)
+	}
+}
@@ -0,0 +1,39 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+)
+
+// EncryptID deterministically encrypts id, producing a fixed-width value
+// suitable for indexing: the 16-byte synthetic IV followed by the encrypted
+// 8-byte big-endian id, exactly as Seal(nil, nil, id, context) lays it out.
+// The context is the associated data, and separates ID spaces from one
+// another: the same id encrypted under different contexts produces unrelated
+// values. It panics if aead's overhead is not 16 bytes.
+func EncryptID(aead cipher.AEAD, id uint64, context []byte) [24]byte {
+	if aead.Overhead() != 16 {
+		panic("siv: EncryptID requires a 16-byte overhead")
+	}
+
+	var p [8]byte
+	binary.BigEndian.PutUint64(p[:], id)
+
+	var b [24]byte
+	aead.Seal(b[:0], nil, p[:], context)
+	return b
+}
+
+// DecryptID decrypts an id encrypted by EncryptID under the same context.
+func DecryptID(aead cipher.AEAD, ciphertext [24]byte, context []byte) (uint64, error) {
+	var b [8]byte
+	plaintext, err := aead.Open(b[:0], nil, ciphertext[:], context)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(plaintext) != 8 {
+		return 0, ErrAuthentication
+	}
+	return binary.BigEndian.Uint64(plaintext), nil
+}
@@ -0,0 +1,95 @@
+package siv
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"encoding/hex"
+	"testing"
+)
+
+func TestEncryptIDVectors(t *testing.T) {
+	key, _ := hex.DecodeString("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	aead, _ := New(key, aes.NewCipher)
+
+	for _, v := range []struct {
+		id       uint64
+		context  string
+		expected string
+	}{
+		{0, "", "510902d2ade16b18ca56a4b73738dfb166ca3cfb48d57adb"},
+		{1, "", "e256777b8e50f2ad02ba625a862ea3f68e528f75452c87dc"},
+		{1, "users", "eff9f1249cbb315161659f73939da91198f8c5e361f00950"},
+		{1, "accounts", "0d949b95b2103ebacbcfeef50b033e0524275139d3e346e2"},
+		{0xffffffffffffffff, "users", "442852ed1ba6a6d9942f51326b430ca997293c1d6acdc0d3"},
+	} {
+		actual := EncryptID(aead, v.id, []byte(v.context))
+		if hex.EncodeToString(actual[:]) != v.expected {
+			t.Errorf("Encrypted %d in %q to %x, but expected %s", v.id, v.context, actual, v.expected)
+		}
+
+		id, err := DecryptID(aead, actual, []byte(v.context))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if id != v.id {
+			t.Errorf("Decrypted %d, but expected %d", id, v.id)
+		}
+	}
+}
+
+func TestEncryptIDMatchesSeal(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+
+	actual := EncryptID(aead, 0x0102030405060708, []byte("users"))
+	expected := aead.Seal(nil, nil, []byte{1, 2, 3, 4, 5, 6, 7, 8}, []byte("users"))
+	if hex.EncodeToString(actual[:]) != hex.EncodeToString(expected) {
+		t.Errorf("Encrypted ID was %x, but expected %x", actual, expected)
+	}
+}
+
+func TestEncryptIDContexts(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+
+	users := EncryptID(aead, 42, []byte("users"))
+	accounts := EncryptID(aead, 42, []byte("accounts"))
+
+	same := 0
+	for i := range users {
+		if users[i] == accounts[i] {
+			same++
+		}
+	}
+
+	if same > 4 {
+		t.Errorf("%x and %x share %d bytes", users, accounts, same)
+	}
+
+	if id, err := DecryptID(aead, users, []byte("accounts")); err != ErrAuthentication {
+		t.Errorf("Decrypted %d across contexts (error %v)", id, err)
+	}
+}
+
+func TestDecryptIDTampered(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+
+	c := EncryptID(aead, 42, nil)
+	c[20] ^= 1
+	if id, err := DecryptID(aead, c, nil); err != ErrAuthentication {
+		t.Errorf("Decrypted %d from tampered value (error %v)", id, err)
+	}
+}
+
+func TestEncryptIDOverhead(t *testing.T) {
+	aead, _ := New(testKey(0, 48), func(key []byte) (cipher.Block, error) {
+		return des.NewTripleDESCipher(key)
+	})
+
+	defer func() {
+		if recover() == nil {
+			t.Error("EncryptID did not panic for an 8-byte overhead")
+		}
+	}()
+	EncryptID(aead, 42, nil)
+}
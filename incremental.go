@@ -0,0 +1,240 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+	"hash"
+
+	"github.com/ebfe/cmac"
+)
+
+var (
+	// ErrADAfterMessage is returned when associated data is added to a Sealer
+	// or Opener after the plaintext or ciphertext has begun.
+	ErrADAfterMessage = errors.New("siv: associated data added after message")
+
+	// ErrFinished is returned when a Sealer or Opener is used after Finish.
+	ErrFinished = errors.New("siv: builder already finished")
+)
+
+// IncrementalAEAD is implemented by the AEADs returned by New without options,
+// and builds ciphertexts and plaintexts from pieces as they arrive.
+type IncrementalAEAD interface {
+	cipher.AEAD
+
+	// NewSealer returns a Sealer which seals as SealMulti does.
+	NewSealer() *Sealer
+
+	// NewOpener returns an Opener which opens as OpenMulti does.
+	NewOpener() *Opener
+}
+
+// s2vState computes S2V incrementally: each associated data component as it
+// is added, and then the final component as it is written, holding back only
+// its last block, which S2V treats specially.
+type s2vState struct {
+	h hash.Hash
+	d []byte
+
+	final   []byte // the final component, up to its last blockSize bytes
+	written int    // the number of bytes of final written to h
+	started bool
+}
+
+func newS2VState(mac cipher.Block) s2vState {
+	h, _ := cmac.NewWithCipher(mac)
+	return s2vState{h: h, d: s2vData(h)}
+}
+
+func (s *s2vState) addAD(ad []byte) {
+	if ad == nil {
+		return
+	}
+
+	dbl(s.d)
+	_, _ = s.h.Write(ad)
+	var sum [blockSize]byte
+	subtle.XORBytes(s.d, s.d, s.h.Sum(sum[:0]))
+	s.h.Reset()
+}
+
+// write updates the final component, which the caller retains and may only
+// extend between calls.
+func (s *s2vState) write(final []byte) {
+	s.final = final
+	if n := len(final) - blockSize; n > s.written {
+		_, _ = s.h.Write(final[s.written:n])
+		s.written = n
+	}
+}
+
+func (s *s2vState) sum() []byte {
+	return s2vFinal(s.h, s.d, s.final[s.written:])
+}
+
+// Sealer builds a ciphertext from associated data components and plaintext
+// supplied in pieces, as SealMulti(dst, plaintext, ad...) would.
+//
+// Each associated data component is processed as it is added and not kept,
+// and S2V runs over the plaintext as it is written. The whole plaintext is
+// buffered until Finish, however, as encryption can only begin once the
+// synthetic IV has been computed from all of it; the buffer is wiped
+// afterwards.
+//
+// A Sealer is single-use and not safe for concurrent use.
+type Sealer struct {
+	s    *SIV
+	s2v  s2vState
+	buf  []byte
+	done bool
+}
+
+// NewSealer returns a Sealer for the AEAD.
+func (s *SIV) NewSealer() *Sealer {
+	return &Sealer{s: s, s2v: newS2VState(s.mac)}
+}
+
+// AddAD adds an associated data component, which must come before any
+// plaintext. Nil components are skipped, as by SealMulti.
+func (s *Sealer) AddAD(ad []byte) error {
+	if s.done {
+		return ErrFinished
+	}
+	if s.s2v.started {
+		return ErrADAfterMessage
+	}
+
+	s.s2v.addAD(ad)
+	return nil
+}
+
+// WritePlaintext appends p to the plaintext. It does not retain p.
+func (s *Sealer) WritePlaintext(p []byte) (int, error) {
+	if s.done {
+		return 0, ErrFinished
+	}
+	if len(p) > MaxPlaintextSize-len(s.buf) {
+		return 0, ErrPlaintextTooLarge
+	}
+
+	s.s2v.started = true
+	s.buf = append(s.buf, p...)
+	s.s2v.write(s.buf)
+	return len(p), nil
+}
+
+// Finish appends the ciphertext to dst and returns the updated slice. The
+// Sealer may not be used afterwards.
+func (s *Sealer) Finish(dst []byte) ([]byte, error) {
+	if s.done {
+		return nil, ErrFinished
+	}
+	s.done = true
+	defer wipe(s.buf)
+
+	ret, out, err := sliceForAppend(dst, blockSize+len(s.buf))
+	if err != nil {
+		return nil, err
+	}
+
+	v := s.s2v.sum()
+	copy(out, v)
+	cipher.NewCTR(s.s.enc, ctr(v)).XORKeyStream(out[blockSize:], s.buf)
+	return ret, nil
+}
+
+// Opener recovers a plaintext from associated data components and a
+// ciphertext supplied in pieces, as OpenMulti(dst, ciphertext, ad...) would.
+//
+// Each associated data component is processed as it is added and not kept,
+// and the ciphertext is decrypted and run through S2V as it is written. Only
+// the decrypted plaintext is buffered, as it cannot be released before the
+// whole ciphertext has been authenticated; it is wiped if authentication
+// fails, and after being copied to dst if it succeeds.
+//
+// An Opener is single-use and not safe for concurrent use.
+type Opener struct {
+	s    *SIV
+	s2v  s2vState
+	tag  []byte
+	ctr  cipher.Stream
+	buf  []byte
+	done bool
+}
+
+// NewOpener returns an Opener for the AEAD.
+func (s *SIV) NewOpener() *Opener {
+	return &Opener{s: s, s2v: newS2VState(s.mac)}
+}
+
+// AddAD adds an associated data component, which must come before any
+// ciphertext. Nil components are skipped, as by OpenMulti.
+func (o *Opener) AddAD(ad []byte) error {
+	if o.done {
+		return ErrFinished
+	}
+	if o.s2v.started {
+		return ErrADAfterMessage
+	}
+
+	o.s2v.addAD(ad)
+	return nil
+}
+
+// WriteCiphertext appends p to the ciphertext. It does not retain p.
+func (o *Opener) WriteCiphertext(p []byte) (int, error) {
+	if o.done {
+		return 0, ErrFinished
+	}
+	o.s2v.started = true
+	n := len(p)
+
+	if len(o.tag) < blockSize {
+		k := blockSize - len(o.tag)
+		if k > len(p) {
+			k = len(p)
+		}
+		o.tag = append(o.tag, p[:k]...)
+		p = p[k:]
+
+		if len(o.tag) == blockSize {
+			o.ctr = cipher.NewCTR(o.s.enc, ctr(o.tag))
+		}
+	}
+
+	if len(p) > 0 {
+		start := len(o.buf)
+		o.buf = append(o.buf, p...)
+		o.ctr.XORKeyStream(o.buf[start:], o.buf[start:])
+		o.s2v.write(o.buf)
+	}
+	return n, nil
+}
+
+// Finish authenticates the ciphertext and appends the plaintext to dst,
+// returning the updated slice, or returns ErrAuthentication. The Opener may
+// not be used afterwards.
+func (o *Opener) Finish(dst []byte) ([]byte, error) {
+	if o.done {
+		return nil, ErrFinished
+	}
+	o.done = true
+	defer wipe(o.buf)
+
+	if len(o.tag) < blockSize {
+		return nil, ErrAuthentication
+	}
+
+	o.s2v.final = o.buf
+	if subtle.ConstantTimeCompare(o.tag, o.s2v.sum()) != 1 {
+		return nil, ErrAuthentication
+	}
+
+	ret, out, err := sliceForAppend(dst, len(o.buf))
+	if err != nil {
+		return nil, err
+	}
+	copy(out, o.buf)
+	return ret, nil
+}
@@ -0,0 +1,203 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+)
+
+// chunks splits b into pieces of at most n bytes.
+func chunks(b []byte, n int) [][]byte {
+	var c [][]byte
+	for len(b) > n {
+		c = append(c, b[:n])
+		b = b[n:]
+	}
+	return append(c, b)
+}
+
+func TestSealerVector(t *testing.T) {
+	// https://tools.ietf.org/html/rfc5297#appendix-A.2
+	key, _ := hex.DecodeString("7f7e7d7c7b7a79787776757473727170404142434445464748494a4b4c4d4e4f")
+	ad1, _ := hex.DecodeString("00112233445566778899aabbccddeeffdeaddadadeaddadaffeeddccbbaa99887766554433221100")
+	ad2, _ := hex.DecodeString("102030405060708090a0")
+	nonce, _ := hex.DecodeString("09f911029d74e35bd84156c5635688c0")
+	plaintext, _ := hex.DecodeString("7468697320697320736f6d6520706c61696e7465787420746f20656e6372797074207573696e67205349562d414553")
+	ciphertext, _ := hex.DecodeString("7bdb6e3b432667eb06f4d14bff2fbd0fcb900f2fddbe404326601965c889bf17dba77ceb094fa663b7a3f748ba8af829ea64ad544a272e9c485b62a3fd5c0d")
+
+	aead, _ := New(key, aes.NewCipher)
+	s := aead.(IncrementalAEAD).NewSealer()
+	for _, ad := range [][]byte{ad1, ad2, nonce} {
+		if err := s.AddAD(ad); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, c := range chunks(plaintext, 5) {
+		if _, err := s.WritePlaintext(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	actual, err := s.Finish(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(actual, ciphertext) {
+		t.Errorf("Ciphertext was %x, but expected %x", actual, ciphertext)
+	}
+}
+
+func TestIncrementalMatchesOneShot(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	s := aead.(*SIV)
+
+	for _, data := range [][][]byte{
+		nil,
+		{[]byte("ad")},
+		{nil, []byte("ad"), {}},
+		{bytes.Repeat([]byte{1}, 40), []byte("nonce")},
+	} {
+		for n := 0; n < 50; n++ {
+			plaintext := make([]byte, n)
+			for i := range plaintext {
+				plaintext[i] = byte(i)
+			}
+			expected := s.SealMulti([]byte("dst"), plaintext, data...)
+
+			for _, size := range []int{1, 7, 16, 17, 64} {
+				sealer := s.NewSealer()
+				for _, ad := range data {
+					_ = sealer.AddAD(ad)
+				}
+				for _, c := range chunks(plaintext, size) {
+					_, _ = sealer.WritePlaintext(c)
+				}
+
+				actual, err := sealer.Finish([]byte("dst"))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(actual, expected) {
+					t.Errorf("Ciphertext was %x, but expected %x", actual, expected)
+				}
+
+				opener := s.NewOpener()
+				for _, ad := range data {
+					_ = opener.AddAD(ad)
+				}
+				for _, c := range chunks(expected[3:], size) {
+					_, _ = opener.WriteCiphertext(c)
+				}
+
+				opened, err := opener.Finish([]byte("dst"))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if !bytes.Equal(opened[3:], plaintext) || string(opened[:3]) != "dst" {
+					t.Errorf("Plaintext was %x, but expected %x", opened, plaintext)
+				}
+			}
+		}
+	}
+}
+
+func TestOpenerBadCiphertext(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	i := aead.(IncrementalAEAD)
+	ciphertext := aead.Seal(nil, nil, []byte("hello, world"), []byte("ad"))
+
+	for _, v := range []struct {
+		ad, ciphertext []byte
+	}{
+		{[]byte("ad"), ciphertext[:15]},
+		{[]byte("ad"), ciphertext[:len(ciphertext)-1]},
+		{[]byte("da"), ciphertext},
+		{nil, ciphertext},
+		{[]byte("ad"), append(append([]byte{}, ciphertext...), 0)},
+	} {
+		o := i.NewOpener()
+		_ = o.AddAD(v.ad)
+		_, _ = o.WriteCiphertext(v.ciphertext)
+
+		if actual, err := o.Finish(nil); err != ErrAuthentication {
+			t.Errorf("Error was %v, but expected %v (plaintext %x)", err, ErrAuthentication, actual)
+		}
+
+		if len(o.buf) > 0 && !bytes.Equal(o.buf, make([]byte, len(o.buf))) {
+			t.Errorf("Buffered plaintext was not wiped: %x", o.buf)
+		}
+	}
+}
+
+func TestIncrementalOrder(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	i := aead.(IncrementalAEAD)
+
+	s := i.NewSealer()
+	_, _ = s.WritePlaintext([]byte("yay"))
+	if err := s.AddAD([]byte("ad")); err != ErrADAfterMessage {
+		t.Errorf("Error was %v, but expected %v", err, ErrADAfterMessage)
+	}
+
+	// An empty write still starts the plaintext.
+	s = i.NewSealer()
+	_, _ = s.WritePlaintext(nil)
+	if err := s.AddAD([]byte("ad")); err != ErrADAfterMessage {
+		t.Errorf("Error was %v, but expected %v", err, ErrADAfterMessage)
+	}
+
+	o := i.NewOpener()
+	_, _ = o.WriteCiphertext([]byte{1})
+	if err := o.AddAD([]byte("ad")); err != ErrADAfterMessage {
+		t.Errorf("Error was %v, but expected %v", err, ErrADAfterMessage)
+	}
+}
+
+func TestIncrementalSingleUse(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	i := aead.(IncrementalAEAD)
+
+	s := i.NewSealer()
+	_, _ = s.WritePlaintext([]byte("yay"))
+	ciphertext, err := s.Finish(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.AddAD(nil); err != ErrFinished {
+		t.Errorf("Error was %v, but expected %v", err, ErrFinished)
+	}
+	if _, err := s.WritePlaintext([]byte("more")); err != ErrFinished {
+		t.Errorf("Error was %v, but expected %v", err, ErrFinished)
+	}
+	if _, err := s.Finish(nil); err != ErrFinished {
+		t.Errorf("Error was %v, but expected %v", err, ErrFinished)
+	}
+
+	o := i.NewOpener()
+	_, _ = o.WriteCiphertext(ciphertext)
+	if _, err := o.Finish(nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := o.AddAD(nil); err != ErrFinished {
+		t.Errorf("Error was %v, but expected %v", err, ErrFinished)
+	}
+	if _, err := o.WriteCiphertext(ciphertext); err != ErrFinished {
+		t.Errorf("Error was %v, but expected %v", err, ErrFinished)
+	}
+	if _, err := o.Finish(nil); err != ErrFinished {
+		t.Errorf("Error was %v, but expected %v", err, ErrFinished)
+	}
+
+	// Options change the ciphertext format, so configured AEADs don't build
+	// incrementally.
+	configured, _ := New(testKey(0, 32), aes.NewCipher, WithPadding(16))
+	if _, ok := configured.(IncrementalAEAD); ok {
+		t.Error("Configured AEAD is incremental")
+	}
+}
@@ -0,0 +1,48 @@
+package siv
+
+import (
+	"crypto/aes"
+	"fmt"
+	"reflect"
+)
+
+// Info is implemented by the AEADs returned by New, and describes how they
+// were constructed.
+type Info interface {
+	// KeySize returns the size of the combined key, in bytes.
+	KeySize() int
+
+	// BlockSize returns the block size of the underlying cipher, in bytes.
+	BlockSize() int
+
+	// Algorithm returns the name of the algorithm, as registered for use
+	// with NewNamed (e.g. "AES-SIV-CMAC-512"). The size is that of the
+	// combined key, in bits. Ciphers other than crypto/aes are named
+	// "SIV-CMAC-" followed by the size.
+	Algorithm() string
+}
+
+// aesType is the type of the blocks returned by aes.NewCipher.
+var aesType = func() reflect.Type {
+	b, _ := aes.NewCipher(make([]byte, 16))
+	return reflect.TypeOf(b)
+}()
+
+// KeySize implements Info.
+func (s *SIV) KeySize() int {
+	return s.keySize
+}
+
+// BlockSize implements Info.
+func (s *SIV) BlockSize() int {
+	return s.enc.BlockSize()
+}
+
+// Algorithm implements Info.
+func (s *SIV) Algorithm() string {
+	name := "SIV-CMAC"
+	if reflect.TypeOf(s.enc) == aesType {
+		name = "AES-SIV-CMAC"
+	}
+	return fmt.Sprintf("%s-%d", name, s.keySize*8)
+}
@@ -0,0 +1,63 @@
+package siv
+
+import (
+	"crypto/aes"
+	"testing"
+
+	"github.com/stripe/siv-go/internal/testciphers"
+)
+
+func TestInfo(t *testing.T) {
+	for _, v := range []struct {
+		size      int
+		algorithm string
+	}{
+		{32, AESSIVCMAC256},
+		{48, AESSIVCMAC384},
+		{64, AESSIVCMAC512},
+	} {
+		aead, err := New(testKey(0, v.size), aes.NewCipher)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info := aead.(Info)
+		if n := info.KeySize(); n != v.size {
+			t.Errorf("Key size was %d, but expected %d", n, v.size)
+		}
+
+		if n := info.BlockSize(); n != aes.BlockSize {
+			t.Errorf("Block size was %d, but expected %d", n, aes.BlockSize)
+		}
+
+		if name := info.Algorithm(); name != v.algorithm {
+			t.Errorf("Algorithm was %q, but expected %q", name, v.algorithm)
+		}
+
+		// The name round-trips through the registry.
+		named, err := NewNamed(info.Algorithm(), testKey(0, v.size))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if name := named.(Info).Algorithm(); name != v.algorithm {
+			t.Errorf("Algorithm was %q, but expected %q", name, v.algorithm)
+		}
+	}
+}
+
+func TestInfoOtherCipher(t *testing.T) {
+	aead, err := New(testKey(0, 48), testciphers.NewCamellia)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := aead.(Info)
+	if n := info.BlockSize(); n != 16 {
+		t.Errorf("Block size was %d, but expected %d", n, 16)
+	}
+
+	if name := info.Algorithm(); name != "SIV-CMAC-384" {
+		t.Errorf("Algorithm was %q, but expected %q", name, "SIV-CMAC-384")
+	}
+}
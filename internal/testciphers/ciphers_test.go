@@ -0,0 +1,92 @@
+package testciphers
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/hex"
+	"testing"
+)
+
+func TestKnownAnswers(t *testing.T) {
+	tests := []struct {
+		name                   string
+		alg                    func([]byte) (cipher.Block, error)
+		key, plaintext, output string
+	}{
+		// GB/T 32907-2016 appendix A.1
+		{
+			"SM4", NewSM4,
+			"0123456789abcdeffedcba9876543210",
+			"0123456789abcdeffedcba9876543210",
+			"681edf34d206965e86b3e94f536e4246",
+		},
+		// https://tools.ietf.org/html/rfc3713#appendix-A
+		{
+			"Camellia-128", NewCamellia,
+			"0123456789abcdeffedcba9876543210",
+			"0123456789abcdeffedcba9876543210",
+			"67673138549669730857065648eabe43",
+		},
+		{
+			"Camellia-192", NewCamellia,
+			"0123456789abcdeffedcba98765432100011223344556677",
+			"0123456789abcdeffedcba9876543210",
+			"b4993401b3e996f84ee5cee7d79b09b9",
+		},
+		{
+			"Camellia-256", NewCamellia,
+			"0123456789abcdeffedcba987654321000112233445566778899aabbccddeeff",
+			"0123456789abcdeffedcba9876543210",
+			"9acc237dff16d76c20ef7c919e3a7509",
+		},
+		// https://tools.ietf.org/html/rfc5794#appendix-A
+		{
+			"ARIA-128", NewARIA,
+			"000102030405060708090a0b0c0d0e0f",
+			"00112233445566778899aabbccddeeff",
+			"d718fbd6ab644c739da95f3be6451778",
+		},
+		{
+			"ARIA-192", NewARIA,
+			"000102030405060708090a0b0c0d0e0f1011121314151617",
+			"00112233445566778899aabbccddeeff",
+			"26449c1805dbe7aa25a468ce263a9e79",
+		},
+		{
+			"ARIA-256", NewARIA,
+			"000102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f",
+			"00112233445566778899aabbccddeeff",
+			"f92bd7c79fb72e2f2b8f80c1972d24fc",
+		},
+	}
+
+	for _, test := range tests {
+		key, _ := hex.DecodeString(test.key)
+		plaintext, _ := hex.DecodeString(test.plaintext)
+		expected, _ := hex.DecodeString(test.output)
+
+		b, err := test.alg(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual := make([]byte, 16)
+		b.Encrypt(actual, plaintext)
+		if !bytes.Equal(actual, expected) {
+			t.Errorf("%s: Ciphertext was %x, but expected %x", test.name, actual, expected)
+		}
+
+		b.Decrypt(actual, actual)
+		if !bytes.Equal(actual, plaintext) {
+			t.Errorf("%s: Plaintext was %x, but expected %x", test.name, actual, plaintext)
+		}
+	}
+}
+
+func TestKeySize(t *testing.T) {
+	for _, alg := range []func([]byte) (cipher.Block, error){NewSM4, NewCamellia, NewARIA} {
+		if _, err := alg(make([]byte, 20)); err != KeySizeError(20) {
+			t.Errorf("Error was %v, but expected %v", err, KeySizeError(20))
+		}
+	}
+}
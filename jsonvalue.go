@@ -0,0 +1,157 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The type tags which prefix the plaintext of an encrypted JSON value.
+const (
+	jsonNull   = 'z'
+	jsonBool   = 'b'
+	jsonNumber = 'n'
+	jsonString = 's'
+)
+
+var (
+	// ErrJSONType is returned by EncryptJSONValue for values which are not
+	// JSON scalars, and by DecryptJSONValue for plaintexts with an unknown
+	// type tag or a malformed value.
+	ErrJSONType = errors.New("siv: not a JSON scalar")
+
+	// ErrJSONNumber is returned by EncryptJSONValue for numbers which can't be
+	// represented in JSON, such as NaN or infinities, or malformed
+	// json.Numbers.
+	ErrJSONNumber = errors.New("siv: invalid JSON number")
+)
+
+// EncryptJSONValue deterministically encrypts a JSON scalar, preserving its
+// type, and returns it as unpadded base64url. v may be nil, a bool, a string,
+// a json.Number, or any Go integer or floating-point type; objects and arrays
+// are rejected with ErrJSONType.
+//
+// The plaintext is a one-byte type tag followed by the canonical form of the
+// value: nothing for null, "true" or "false" for booleans, and the UTF-8 bytes
+// of strings. So that equal numbers encrypt identically regardless of how they
+// are written, numbers are canonicalized as follows:
+//
+//   - A number written as an integer (without a fraction or exponent) which
+//     fits in an int64 or uint64 is written in decimal, with no sign for zero.
+//   - Any other number is parsed as a float64. If that is an integer of
+//     magnitude less than 2^53, it is written in decimal as above; otherwise it
+//     is written in the shortest form which parses back to the same float64,
+//     in exponent form (e.g. "1e+21") only if its magnitude is below 1e-6 or
+//     at least 1e21, as JavaScript does.
+//
+// So 1, 1.0, 1e0, and 10e-1 all encrypt identically, and integer IDs of up to
+// 64 bits survive exactly.
+func EncryptJSONValue(aead cipher.AEAD, v any, ad []byte) (string, error) {
+	plaintext, err := marshalJSONValue(v)
+	if err != nil {
+		return "", err
+	}
+	return SealString(aead, plaintext, ad), nil
+}
+
+// DecryptJSONValue decrypts a value encrypted by EncryptJSONValue, returning
+// nil, a bool, a string, or a json.Number holding the canonical form of a
+// number.
+func DecryptJSONValue(aead cipher.AEAD, s string, ad []byte) (any, error) {
+	plaintext, err := OpenString(aead, s, ad)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(plaintext) == 0 {
+		return nil, ErrJSONType
+	}
+
+	tag, value := plaintext[0], string(plaintext[1:])
+	switch {
+	case tag == jsonNull && value == "":
+		return nil, nil
+	case tag == jsonBool && (value == "true" || value == "false"):
+		return value == "true", nil
+	case tag == jsonString:
+		return value, nil
+	case tag == jsonNumber:
+		if c, err := canonicalNumber(value); err == nil && c == value {
+			return json.Number(value), nil
+		}
+	}
+	return nil, ErrJSONType
+}
+
+func marshalJSONValue(v any) ([]byte, error) {
+	switch v := v.(type) {
+	case nil:
+		return []byte{jsonNull}, nil
+	case bool:
+		return append([]byte{jsonBool}, strconv.FormatBool(v)...), nil
+	case string:
+		return append([]byte{jsonString}, v...), nil
+	case json.Number:
+		n, err := canonicalNumber(string(v))
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{jsonNumber}, n...), nil
+	}
+
+	var n string
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		n = strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		f := rv.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, ErrJSONNumber
+		}
+		n = formatFloat(f)
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrJSONType, v)
+	}
+	return append([]byte{jsonNumber}, n...), nil
+}
+
+// canonicalNumber returns the canonical form of a JSON number, as described by
+// EncryptJSONValue.
+func canonicalNumber(s string) (string, error) {
+	if !json.Valid([]byte(s)) || s == "" || !(s[0] == '-' || (s[0] >= '0' && s[0] <= '9')) {
+		return "", ErrJSONNumber
+	}
+
+	if !strings.ContainsAny(s, ".eE") {
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return strconv.FormatInt(i, 10), nil
+		}
+		if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return strconv.FormatUint(u, 10), nil
+		}
+	}
+
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return "", ErrJSONNumber
+	}
+	return formatFloat(f), nil
+}
+
+func formatFloat(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1<<53 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+
+	if abs := math.Abs(f); abs < 1e-6 || abs >= 1e21 {
+		return strconv.FormatFloat(f, 'e', -1, 64)
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
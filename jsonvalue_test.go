@@ -0,0 +1,163 @@
+package siv
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"math"
+	"testing"
+)
+
+func jsonTestAEAD(t *testing.T) cipher.AEAD {
+	aead, err := New(testKey(0, 32), aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func TestJSONValueRoundTrip(t *testing.T) {
+	aead := jsonTestAEAD(t)
+	ad := []byte("users.email")
+
+	for _, v := range []struct {
+		in, expected any
+	}{
+		{nil, nil},
+		{true, true},
+		{false, false},
+		{"", ""},
+		{"hello", "hello"},
+		{json.Number("42"), json.Number("42")},
+		{json.Number("-1.5"), json.Number("-1.5")},
+		{int64(-7), json.Number("-7")},
+		{uint64(math.MaxUint64), json.Number("18446744073709551615")},
+		{3.25, json.Number("3.25")},
+		{float32(0.5), json.Number("0.5")},
+	} {
+		s, err := EncryptJSONValue(aead, v.in, ad)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := DecryptJSONValue(aead, s, ad)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual != v.expected {
+			t.Errorf("Value was %#v, but expected %#v", actual, v.expected)
+		}
+	}
+}
+
+func TestJSONValueCanonicalNumbers(t *testing.T) {
+	for _, v := range []struct {
+		in, expected string
+	}{
+		{"0", "0"},
+		{"-0", "0"},
+		{"-0.0", "0"},
+		{"1", "1"},
+		{"1.0", "1"},
+		{"1e0", "1"},
+		{"10e-1", "1"},
+		{"1.50", "1.5"},
+		{"100E-2", "1"},
+		{"9007199254740993", "9007199254740993"},
+		{"9007199254740993.0", "9007199254740992"},
+		{"18446744073709551615", "18446744073709551615"},
+		{"18446744073709551616", "18446744073709552000"},
+		{"1e20", "100000000000000000000"},
+		{"1e21", "1e+21"},
+		{"0.000001", "0.000001"},
+		{"0.0000001", "1e-07"},
+		{"-2.5E+3", "-2500"},
+	} {
+		actual, err := canonicalNumber(v.in)
+		if err != nil {
+			t.Fatalf("%s: %v", v.in, err)
+		}
+
+		if actual != v.expected {
+			t.Errorf("Canonical form of %s was %s, but expected %s", v.in, actual, v.expected)
+		}
+	}
+
+	aead := jsonTestAEAD(t)
+	a, _ := EncryptJSONValue(aead, json.Number("1e0"), nil)
+	b, _ := EncryptJSONValue(aead, 1, nil)
+	c, _ := EncryptJSONValue(aead, 1.0, nil)
+	if a != b || b != c {
+		t.Errorf("Equal numbers encrypted differently: %s, %s, %s", a, b, c)
+	}
+}
+
+func TestJSONValueTypes(t *testing.T) {
+	aead := jsonTestAEAD(t)
+
+	// The same text under different types encrypts differently.
+	seen := map[string]any{}
+	for _, v := range []any{"1", json.Number("1"), "true", true, "", nil} {
+		s, err := EncryptJSONValue(aead, v, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if prev, ok := seen[s]; ok {
+			t.Errorf("%#v and %#v encrypted identically", prev, v)
+		}
+		seen[s] = v
+	}
+}
+
+func TestJSONValueRejected(t *testing.T) {
+	aead := jsonTestAEAD(t)
+
+	for _, v := range []any{
+		map[string]any{"a": 1},
+		[]any{1, 2},
+		[]byte("bytes"),
+		struct{}{},
+		json.RawMessage("{}"),
+	} {
+		if _, err := EncryptJSONValue(aead, v, nil); !errors.Is(err, ErrJSONType) {
+			t.Errorf("Error for %#v was %v, but expected %v", v, err, ErrJSONType)
+		}
+	}
+
+	for _, v := range []any{
+		math.NaN(),
+		math.Inf(1),
+		json.Number(""),
+		json.Number("01"),
+		json.Number("1e400"),
+		json.Number("\"1\""),
+		json.Number("true"),
+	} {
+		if _, err := EncryptJSONValue(aead, v, nil); err != ErrJSONNumber {
+			t.Errorf("Error for %#v was %v, but expected %v", v, err, ErrJSONNumber)
+		}
+	}
+}
+
+func TestJSONValueTampering(t *testing.T) {
+	aead := jsonTestAEAD(t)
+
+	s, _ := EncryptJSONValue(aead, "1", nil)
+	b := []byte(s)
+	b[0] ^= 1
+	if v, err := DecryptJSONValue(aead, string(b), nil); err != ErrAuthentication {
+		t.Fatalf("Value returned instead of error: %#v", v)
+	}
+
+	// Well-authenticated plaintexts without a valid tag and canonical value are
+	// rejected.
+	for _, p := range []string{"", "x1", "btrue ", "z0", "n1.0", "n+1", "nabc"} {
+		s := SealString(aead, []byte(p), nil)
+		if v, err := DecryptJSONValue(aead, s, nil); err != ErrJSONType {
+			t.Errorf("Value returned instead of error for %q: %#v", p, v)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// The JWK "alg" values for AES-SIV-CMAC keys. No SIV algorithm is registered
+// for JOSE, so these are private names, following the pattern of "A256GCM"
+// with the number giving the size of the combined key in bits, as in
+// AESSIVCMAC256. Parties exchanging SIV keys as JWKs must agree on them.
+const (
+	JWKAlgA256SIV = "A256SIV"
+	JWKAlgA384SIV = "A384SIV"
+	JWKAlgA512SIV = "A512SIV"
+)
+
+// ErrJWK is returned when a JWK is malformed, is missing a required member,
+// or has members which contradict each other.
+var ErrJWK = errors.New("siv: invalid JWK")
+
+// jwkKeySizes are the key sizes of the JWK algorithms.
+var jwkKeySizes = map[string]int{
+	JWKAlgA256SIV: 32,
+	JWKAlgA384SIV: 48,
+	JWKAlgA512SIV: 64,
+}
+
+// jwk is a symmetric JWK (RFC 7517 and RFC 7518 section 6.4).
+type jwk struct {
+	KeyType string   `json:"kty"`
+	KeyID   string   `json:"kid,omitempty"`
+	Alg     string   `json:"alg"`
+	Use     string   `json:"use,omitempty"`
+	KeyOps  []string `json:"key_ops,omitempty"`
+	K       *string  `json:"k"`
+}
+
+// ExportJWK returns key as a JWK with "kty" "oct", the given "kid" (omitted if
+// empty), and the "alg" for its size: JWKAlgA256SIV, JWKAlgA384SIV, or
+// JWKAlgA512SIV. The JWK contains the key in the clear.
+func ExportJWK(keyID string, key []byte) ([]byte, error) {
+	var alg string
+	switch len(key) {
+	case 32:
+		alg = JWKAlgA256SIV
+	case 48:
+		alg = JWKAlgA384SIV
+	case 64:
+		alg = JWKAlgA512SIV
+	default:
+		return nil, fmt.Errorf("siv: invalid key size %d for a JWK", len(key))
+	}
+	if err := checkKey(key, false); err != nil {
+		return nil, err
+	}
+
+	k := base64.RawURLEncoding.EncodeToString(key)
+	return json.Marshal(jwk{KeyType: "oct", KeyID: keyID, Alg: alg, K: &k})
+}
+
+// ParseJWK parses a JWK as written by ExportJWK and returns its "kid", which
+// may be empty, and an AES-SIV-CMAC AEAD with its key. The "kty", "alg", and
+// "k" members are required, and "k" must be unpadded base64url of the size
+// "alg" implies. If present, "use" must be "enc" and "key_ops" may list only
+// "encrypt" and "decrypt". Other members are ignored, as RFC 7517 requires.
+func ParseJWK(b []byte) (keyID string, aead cipher.AEAD, err error) {
+	var j jwk
+	d := json.NewDecoder(bytes.NewReader(b))
+	if err := d.Decode(&j); err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrJWK, err)
+	}
+	if _, err := d.Token(); err != io.EOF {
+		return "", nil, fmt.Errorf("%w: trailing data", ErrJWK)
+	}
+
+	aead, err = j.aead()
+	if err != nil {
+		return "", nil, err
+	}
+	return j.KeyID, aead, nil
+}
+
+// ParseJWKSet returns an AEAD for the key with the given "kid" in a JWK Set
+// (RFC 7517 section 5), as ParseJWK. Other keys in the set, which may be of
+// any type, are ignored, but more than one key with the ID is an error. If
+// there is none, ParseJWKSet returns ErrUnknownKeyID.
+func ParseJWKSet(b []byte, keyID string) (cipher.AEAD, error) {
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrJWK, err)
+	}
+	if set.Keys == nil {
+		return nil, fmt.Errorf("%w: JWK Set has no \"keys\" member", ErrJWK)
+	}
+
+	var found json.RawMessage
+	for _, raw := range set.Keys {
+		var header struct {
+			KeyID string `json:"kid"`
+		}
+		if err := json.Unmarshal(raw, &header); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrJWK, err)
+		}
+		if header.KeyID != keyID {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%w: more than one key with ID %q", ErrJWK, keyID)
+		}
+		found = raw
+	}
+	if found == nil {
+		return nil, ErrUnknownKeyID
+	}
+
+	_, aead, err := ParseJWK(found)
+	return aead, err
+}
+
+// aead checks j and returns an AEAD with its key.
+func (j *jwk) aead() (cipher.AEAD, error) {
+	switch {
+	case j.KeyType == "":
+		return nil, fmt.Errorf("%w: missing \"kty\"", ErrJWK)
+	case j.KeyType != "oct":
+		return nil, fmt.Errorf("%w: \"kty\" is %q, but expected \"oct\"", ErrJWK, j.KeyType)
+	case j.Alg == "":
+		return nil, fmt.Errorf("%w: missing \"alg\"", ErrJWK)
+	case j.K == nil:
+		return nil, fmt.Errorf("%w: missing \"k\"", ErrJWK)
+	}
+
+	size, ok := jwkKeySizes[j.Alg]
+	if !ok {
+		return nil, fmt.Errorf("%w: \"alg\" is %q, but expected %s, %s, or %s", ErrJWK, j.Alg, JWKAlgA256SIV, JWKAlgA384SIV, JWKAlgA512SIV)
+	}
+
+	if j.Use != "" && j.Use != "enc" {
+		return nil, fmt.Errorf("%w: \"use\" is %q, but expected \"enc\"", ErrJWK, j.Use)
+	}
+	for _, op := range j.KeyOps {
+		if op != "encrypt" && op != "decrypt" {
+			return nil, fmt.Errorf("%w: \"key_ops\" includes %q", ErrJWK, op)
+		}
+	}
+
+	key, err := base64.RawURLEncoding.Strict().DecodeString(*j.K)
+	if err != nil {
+		return nil, fmt.Errorf("%w: \"k\" is not unpadded base64url", ErrJWK)
+	}
+	defer wipe(key)
+
+	if len(key) != size {
+		return nil, fmt.Errorf("%w: \"k\" is %d bytes, but %s needs %d", ErrJWK, len(key), j.Alg, size)
+	}
+	return New(key, aes.NewCipher)
+}
@@ -0,0 +1,147 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJWK(t *testing.T) {
+	for _, n := range []int{32, 48, 64} {
+		key := testKey(0, n)
+		b, err := ExportJWK("2026-10", key)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		id, aead, err := ParseJWK(b)
+		if err != nil {
+			t.Fatalf("%s: %v", b, err)
+		}
+		if id != "2026-10" {
+			t.Errorf("Key ID was %q, but expected %q", id, "2026-10")
+		}
+
+		expected, _ := New(key, aes.NewCipher)
+		if actual, e := aead.Seal(nil, nil, []byte("hello"), nil), expected.Seal(nil, nil, []byte("hello"), nil); !bytes.Equal(actual, e) {
+			t.Errorf("%d: ciphertext was %x, but expected %x", n, actual, e)
+		}
+	}
+
+	b, _ := ExportJWK("", testKey(0, 32))
+	if expected := `{"kty":"oct","alg":"A256SIV","k":"AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8"}`; string(b) != expected {
+		t.Errorf("JWK was %s, but expected %s", b, expected)
+	}
+
+	for _, key := range [][]byte{nil, testKey(0, 16), testKey(0, 33)} {
+		if b, err := ExportJWK("k", key); err == nil {
+			t.Errorf("%d bytes: JWK returned instead of error: %s", len(key), b)
+		}
+	}
+	if b, err := ExportJWK("k", make([]byte, 32)); err != ErrZeroKey {
+		t.Errorf("Error was %v, but expected %v (JWK %s)", err, ErrZeroKey, b)
+	}
+}
+
+func TestJWKFixtures(t *testing.T) {
+	for _, v := range []struct {
+		name, id string
+		size     int
+	}{
+		{"a256.json", "2026-10", 32},
+		{"a512.json", "2026-11", 64},
+	} {
+		b, err := os.ReadFile(filepath.Join("testdata", "jwk", v.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		id, aead, err := ParseJWK(b)
+		if err != nil {
+			t.Fatalf("%s: %v", v.name, err)
+		}
+		if id != v.id || aead.(Info).KeySize() != v.size {
+			t.Errorf("%s: key was %q with %d bytes, but expected %q with %d", v.name, id, aead.(Info).KeySize(), v.id, v.size)
+		}
+
+		expected, _ := New(testKey(0, v.size), aes.NewCipher)
+		if actual, e := aead.Seal(nil, nil, []byte("hello"), nil), expected.Seal(nil, nil, []byte("hello"), nil); !bytes.Equal(actual, e) {
+			t.Errorf("%s: ciphertext was %x, but expected %x", v.name, actual, e)
+		}
+	}
+}
+
+func TestJWKMalformed(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "jwk", "malformed", "*.json"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("No fixtures: %v", err)
+	}
+
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := ErrJWK
+		switch filepath.Base(path) {
+		case "zero-k.json":
+			expected = ErrZeroKey
+		case "equal-halves.json":
+			expected = ErrEqualHalves
+		}
+
+		id, aead, err := ParseJWK(b)
+		if !errors.Is(err, expected) {
+			t.Errorf("%s: error was %v, but expected %v (key %q, %v)", path, err, expected, id, aead)
+		}
+	}
+}
+
+func TestJWKSet(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "jwk", "set.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for id, size := range map[string]int{"2026-10": 32, "2026-12": 48} {
+		aead, err := ParseJWKSet(b, id)
+		if err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+		expected, _ := New(testKey(0, size), aes.NewCipher)
+		if actual, e := aead.Seal(nil, nil, []byte("hello"), nil), expected.Seal(nil, nil, []byte("hello"), nil); !bytes.Equal(actual, e) {
+			t.Errorf("%s: ciphertext was %x, but expected %x", id, actual, e)
+		}
+	}
+
+	if aead, err := ParseJWKSet(b, "2026-13"); err != ErrUnknownKeyID {
+		t.Errorf("Error was %v, but expected %v (AEAD %v)", err, ErrUnknownKeyID, aead)
+	}
+
+	// The EC signing key is found, but isn't a SIV key.
+	if _, err := ParseJWKSet(b, "signing"); !errors.Is(err, ErrJWK) || !strings.Contains(err.Error(), `"kty" is "EC"`) {
+		t.Errorf("Error was %v, but expected %v", err, ErrJWK)
+	}
+
+	var set struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+	_ = json.Unmarshal(b, &set)
+	set.Keys = append(set.Keys, set.Keys[1])
+	dup, _ := json.Marshal(set)
+	if _, err := ParseJWKSet(dup, "2026-10"); !errors.Is(err, ErrJWK) {
+		t.Errorf("Error was %v, but expected %v", err, ErrJWK)
+	}
+
+	for _, s := range []string{``, `[]`, `{}`, `{"keys": {}}`, `{"keys": [1]}`} {
+		if aead, err := ParseJWKSet([]byte(s), "2026-10"); !errors.Is(err, ErrJWK) {
+			t.Errorf("%s: error was %v, but expected %v (AEAD %v)", s, err, ErrJWK, aead)
+		}
+	}
+}
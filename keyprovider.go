@@ -0,0 +1,46 @@
+package siv
+
+import "crypto/cipher"
+
+// KeyProvider supplies key material on demand, such as from locked memory, so
+// that it need only be materialized while an AEAD is constructed.
+type KeyProvider interface {
+	// Key returns the key and a function which releases it, for example by
+	// wiping it and re-locking its memory. If Key returns an error, release
+	// may be nil.
+	Key() (key []byte, release func(), err error)
+}
+
+// NewFromProvider returns a new SIV AEAD, as New, with a key fetched from p.
+// The key is released before NewFromProvider returns, whether or not it
+// succeeds, and the AEAD does not retain it.
+//
+// Releasing the key can only wipe the slice p returned. The returned AEAD
+// necessarily holds the key in another form: the ciphers alg returns keep
+// their own copies or key schedules (crypto/aes, for instance, keeps expanded
+// round keys), which live on the Go heap until the AEAD is garbage collected
+// and can't be wiped.
+func NewFromProvider(p KeyProvider, alg func([]byte) (cipher.Block, error)) (cipher.AEAD, error) {
+	key, release, err := p.Key()
+	if release != nil {
+		defer release()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return New(key, alg)
+}
+
+// NewInsecureKeyProvider returns a KeyProvider which holds a copy of key in
+// ordinary memory, for tests. Each call to Key returns a fresh copy, which its
+// release function wipes.
+func NewInsecureKeyProvider(key []byte) KeyProvider {
+	return insecureKeyProvider(append([]byte{}, key...))
+}
+
+type insecureKeyProvider []byte
+
+func (p insecureKeyProvider) Key() ([]byte, func(), error) {
+	key := append([]byte{}, p...)
+	return key, func() { wipe(key) }, nil
+}
@@ -0,0 +1,87 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"testing"
+)
+
+// recordingProvider wraps a KeyProvider and records the keys it hands out and
+// whether they were released.
+type recordingProvider struct {
+	KeyProvider
+	err      error
+	keys     [][]byte
+	released int
+}
+
+func (p *recordingProvider) Key() ([]byte, func(), error) {
+	if p.err != nil {
+		return nil, nil, p.err
+	}
+
+	key, release, err := p.KeyProvider.Key()
+	p.keys = append(p.keys, key)
+	return key, func() {
+		release()
+		p.released++
+	}, err
+}
+
+func TestNewFromProvider(t *testing.T) {
+	key := testKey(7, 32)
+	p := &recordingProvider{KeyProvider: NewInsecureKeyProvider(key)}
+
+	aead, err := NewFromProvider(p, aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.released != 1 {
+		t.Errorf("Key was released %d times, but expected %d", p.released, 1)
+	}
+
+	if !bytes.Equal(p.keys[0], make([]byte, 32)) {
+		t.Errorf("Key was %x after release, but expected zeroes", p.keys[0])
+	}
+
+	expected, _ := New(key, aes.NewCipher)
+	actual := aead.Seal(nil, nil, []byte("yay"), nil)
+	if !bytes.Equal(actual, expected.Seal(nil, nil, []byte("yay"), nil)) {
+		t.Errorf("Ciphertext was %x, but expected %x", actual, expected.Seal(nil, nil, []byte("yay"), nil))
+	}
+
+	// The provider's own copy is unaffected by releasing a key.
+	if _, err := NewFromProvider(p, aes.NewCipher); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewFromProviderErrors(t *testing.T) {
+	badAlg := errors.New("bad algorithm")
+
+	for _, v := range []struct {
+		key []byte
+		alg func([]byte) (cipher.Block, error)
+	}{
+		{make([]byte, 20), aes.NewCipher},
+		{make([]byte, 32), func([]byte) (cipher.Block, error) { return nil, badAlg }},
+	} {
+		p := &recordingProvider{KeyProvider: NewInsecureKeyProvider(v.key)}
+		if aead, err := NewFromProvider(p, v.alg); err == nil {
+			t.Fatalf("AEAD returned instead of error: %v", aead)
+		}
+
+		if p.released != 1 {
+			t.Errorf("Key was released %d times, but expected %d", p.released, 1)
+		}
+	}
+
+	fetch := errors.New("enclave locked")
+	p := &recordingProvider{err: fetch}
+	if _, err := NewFromProvider(p, aes.NewCipher); err != fetch {
+		t.Errorf("Error was %v, but expected %v", err, fetch)
+	}
+}
@@ -0,0 +1,376 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	// ErrDuplicateKeyID is returned when a key is added to a Keyring under an
+	// ID it already holds.
+	ErrDuplicateKeyID = errors.New("siv: duplicate key ID")
+
+	// ErrUnknownKeyID is returned when a Keyring holds no key with a given ID.
+	ErrUnknownKeyID = errors.New("siv: unknown key ID")
+
+	// ErrKeyState is returned by SetState and SetPrimary for a transition
+	// which isn't allowed.
+	ErrKeyState = errors.New("siv: invalid key state transition")
+
+	// ErrKeyRevoked is wrapped by RevokedKeyError.
+	ErrKeyRevoked = errors.New("siv: key revoked")
+)
+
+// KeyState is the state of a key in a Keyring.
+type KeyState int
+
+const (
+	// KeyPrimary is the state of the one key which seals. It also opens.
+	KeyPrimary KeyState = iota + 1
+
+	// KeyActive keys open, and may be made the primary.
+	KeyActive
+
+	// KeyOpenOnly keys open, but may not be made the primary without first
+	// being made active again, as for a key being retired.
+	KeyOpenOnly
+
+	// KeyRevoked keys no longer open. Ciphertexts sealed under them fail with
+	// a RevokedKeyError.
+	KeyRevoked
+)
+
+func (s KeyState) String() string {
+	switch s {
+	case KeyPrimary:
+		return "primary"
+	case KeyActive:
+		return "active"
+	case KeyOpenOnly:
+		return "open-only"
+	case KeyRevoked:
+		return "revoked"
+	}
+	return fmt.Sprintf("KeyState(%d)", int(s))
+}
+
+// RevokedKeyError is returned when a ciphertext authenticates only under a
+// revoked key.
+type RevokedKeyError struct {
+	ID string
+
+	// Fingerprint is the key's fingerprint, or nil if its AEAD doesn't
+	// implement Fingerprint.
+	Fingerprint []byte
+}
+
+func (e *RevokedKeyError) Error() string {
+	if e.Fingerprint == nil {
+		return fmt.Sprintf("siv: key %q is revoked", e.ID)
+	}
+	return fmt.Sprintf("siv: key %q (fingerprint %x) is revoked", e.ID, e.Fingerprint)
+}
+
+// Unwrap returns ErrKeyRevoked.
+func (e *RevokedKeyError) Unwrap() error {
+	return ErrKeyRevoked
+}
+
+// KeyStatus describes a key in a Keyring, for monitoring.
+type KeyStatus struct {
+	ID    string
+	State KeyState
+
+	// Fingerprint is the key's fingerprint, or nil if its AEAD doesn't
+	// implement Fingerprint.
+	Fingerprint []byte
+}
+
+// Keyring is a set of AEADs identified by key ID, one of which is the primary.
+// It is itself an AEAD: Seal uses the primary key, and Open tries the primary
+// key and then every other key in the order they were added, so data sealed
+// under any key in the keyring can be opened. As each failed attempt costs a
+// full decryption, keyrings should be kept small. The AEADs must share a nonce
+// size and overhead.
+//
+// Each key has a KeyState. Keys are added as KeyActive, or KeyPrimary for the
+// first, and during a rotation can be made KeyOpenOnly, so that they still
+// open existing data but can't be made the primary again by mistake, and
+// finally KeyRevoked, so that they don't open anything.
+//
+// A Keyring is safe for concurrent use.
+type Keyring struct {
+	mu      sync.RWMutex
+	keys    []keyringEntry
+	primary int
+	tracer  Tracer
+}
+
+type keyringEntry struct {
+	id    string
+	aead  cipher.AEAD
+	state KeyState // of keys other than the primary
+
+	// purposes are the purposes the key may be used for through ForPurpose,
+	// or nil if it is unrestricted.
+	purposes []string
+}
+
+// NewKeyring returns an empty Keyring.
+func NewKeyring() *Keyring {
+	return &Keyring{primary: -1}
+}
+
+// Add adds aead to the keyring under the given ID. The first key added becomes
+// the primary.
+func (k *Keyring) Add(id string, aead cipher.AEAD) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if k.index(id) >= 0 {
+		return ErrDuplicateKeyID
+	}
+
+	k.keys = append(k.keys, keyringEntry{id: id, aead: aead, state: KeyActive})
+	if k.primary < 0 {
+		k.primary = len(k.keys) - 1
+	}
+	return nil
+}
+
+// SetPrimary makes the key with the given ID the primary, and the previous
+// primary active. It returns ErrKeyState if the key is open-only or revoked.
+func (k *Keyring) SetPrimary(id string) error {
+	return k.SetState(id, KeyPrimary)
+}
+
+// SetState sets the state of the key with the given ID. Making a key the
+// primary makes the previous primary active, and is allowed only for active
+// keys. The primary's state can't be changed directly; make another key the
+// primary first.
+func (k *Keyring) SetState(id string, state KeyState) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	i := k.index(id)
+	if i < 0 {
+		return ErrUnknownKeyID
+	}
+
+	switch {
+	case state < KeyPrimary || state > KeyRevoked:
+		return fmt.Errorf("%w: unknown state %d", ErrKeyState, int(state))
+	case i == k.primary:
+		if state != KeyPrimary {
+			return fmt.Errorf("%w: %q is the primary", ErrKeyState, id)
+		}
+	case state == KeyPrimary:
+		if k.keys[i].state != KeyActive {
+			return fmt.Errorf("%w: %q is %s", ErrKeyState, id, k.keys[i].state)
+		}
+		if k.primary >= 0 {
+			k.keys[k.primary].state = KeyActive
+		}
+		k.primary = i
+	default:
+		k.keys[i].state = state
+	}
+	return nil
+}
+
+// State returns the state of the key with the given ID.
+func (k *Keyring) State(id string) (KeyState, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	i := k.index(id)
+	if i < 0 {
+		return 0, false
+	}
+	return k.state(i), true
+}
+
+// Status returns the ID, state, and fingerprint of every key, in the order
+// they were added.
+func (k *Keyring) Status() []KeyStatus {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	status := make([]KeyStatus, len(k.keys))
+	for i, e := range k.keys {
+		status[i] = KeyStatus{ID: e.id, State: k.state(i), Fingerprint: fingerprintOf(e.aead)}
+	}
+	return status
+}
+
+func (k *Keyring) state(i int) KeyState {
+	if i == k.primary {
+		return KeyPrimary
+	}
+	return k.keys[i].state
+}
+
+// fingerprintOf returns the fingerprint of aead, or nil if it doesn't
+// implement Fingerprint.
+func fingerprintOf(aead cipher.AEAD) []byte {
+	if f, ok := aead.(interface{ Fingerprint() []byte }); ok {
+		return f.Fingerprint()
+	}
+	return nil
+}
+
+// Primary returns the ID and AEAD of the primary key, or an empty ID and nil if
+// the keyring is empty.
+func (k *Keyring) Primary() (string, cipher.AEAD) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if k.primary < 0 {
+		return "", nil
+	}
+	e := k.keys[k.primary]
+	return e.id, e.aead
+}
+
+// Lookup returns the AEAD with the given ID, whatever its state.
+func (k *Keyring) Lookup(id string) (cipher.AEAD, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	i := k.index(id)
+	if i < 0 {
+		return nil, false
+	}
+	return k.keys[i].aead, true
+}
+
+// IDs returns the IDs of the keys in the order they were added.
+func (k *Keyring) IDs() []string {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	ids := make([]string, len(k.keys))
+	for i, e := range k.keys {
+		ids[i] = e.id
+	}
+	return ids
+}
+
+// lookupOpen returns the AEAD with the given ID for opening, or an error if
+// there is none or it is revoked.
+func (k *Keyring) lookupOpen(id string) (cipher.AEAD, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	i := k.index(id)
+	if i < 0 {
+		return nil, ErrUnknownKeyID
+	}
+	if e := k.keys[i]; k.state(i) == KeyRevoked {
+		return nil, &RevokedKeyError{ID: e.id, Fingerprint: fingerprintOf(e.aead)}
+	}
+	return k.keys[i].aead, nil
+}
+
+func (k *Keyring) index(id string) int {
+	for i, e := range k.keys {
+		if e.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// NonceSize returns the nonce size of the primary key. It panics if the
+// keyring is empty.
+func (k *Keyring) NonceSize() int {
+	return k.mustPrimary().NonceSize()
+}
+
+// Overhead returns the overhead of the primary key. It panics if the keyring
+// is empty.
+func (k *Keyring) Overhead() int {
+	return k.mustPrimary().Overhead()
+}
+
+// Seal seals plaintext with the primary key. It panics if the keyring is
+// empty.
+func (k *Keyring) Seal(dst, nonce, plaintext, data []byte) []byte {
+	return k.mustPrimary().Seal(dst, nonce, plaintext, data)
+}
+
+// Open opens ciphertext with the primary key or, failing that, any other key
+// in the keyring which isn't revoked. If only a revoked key authenticates it,
+// it returns a RevokedKeyError.
+func (k *Keyring) Open(dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	k.mu.RLock()
+	t := k.tracer
+	k.mu.RUnlock()
+
+	end := startSpan(t, "siv.Keyring.Open")
+
+	plaintext, _, err := k.open(dst, nonce, ciphertext, data)
+	end(err)
+	return plaintext, err
+}
+
+// SetTracer sets the Tracer for the keyring's Open, which may try several
+// keys, in place of the one set with the package's SetTracer.
+func (k *Keyring) SetTracer(t Tracer) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.tracer = t
+}
+
+// open opens ciphertext with the first key to authenticate it, and returns
+// that key's ID. Revoked keys are tried only once every other key has failed,
+// to identify them.
+func (k *Keyring) open(dst, nonce, ciphertext, data []byte) ([]byte, string, error) {
+	k.mu.RLock()
+	keys, revoked := k.ordered()
+	k.mu.RUnlock()
+
+	for _, e := range keys {
+		if plaintext, err := trialOpen(e.aead, dst, nonce, ciphertext, data); err == nil {
+			return plaintext, e.id, nil
+		}
+	}
+
+	for _, e := range revoked {
+		if plaintext, err := trialOpen(e.aead, dst, nonce, ciphertext, data); err == nil {
+			wipe(plaintext[len(dst):])
+			return nil, "", &RevokedKeyError{ID: e.id, Fingerprint: fingerprintOf(e.aead)}
+		}
+	}
+	return nil, "", ErrAuthentication
+}
+
+// ordered returns the keys which open, with the primary first, and
+// separately the revoked keys.
+func (k *Keyring) ordered() (keys, revoked []keyringEntry) {
+	keys = make([]keyringEntry, 0, len(k.keys))
+	if k.primary >= 0 {
+		keys = append(keys, k.keys[k.primary])
+	}
+	for i, e := range k.keys {
+		switch {
+		case i == k.primary:
+		case e.state == KeyRevoked:
+			revoked = append(revoked, e)
+		default:
+			keys = append(keys, e)
+		}
+	}
+	return keys, revoked
+}
+
+func (k *Keyring) mustPrimary() cipher.AEAD {
+	_, aead := k.Primary()
+	if aead == nil {
+		panic("siv: keyring is empty")
+	}
+	return aead
+}
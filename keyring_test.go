@@ -0,0 +1,192 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func testKeyring(t *testing.T, ids ...string) (*Keyring, map[string]cipher.AEAD) {
+	k := NewKeyring()
+	aeads := make(map[string]cipher.AEAD)
+	for i, id := range ids {
+		aead, err := New(bytes.Repeat([]byte{byte(i + 1)}, 32), aes.NewCipher, AllowEqualHalves())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := k.Add(id, aead); err != nil {
+			t.Fatal(err)
+		}
+		aeads[id] = aead
+	}
+	return k, aeads
+}
+
+func TestKeyring(t *testing.T) {
+	k, aeads := testKeyring(t, "a", "b", "c")
+
+	if id, _ := k.Primary(); id != "a" {
+		t.Errorf("Primary was %q, but expected %q", id, "a")
+	}
+
+	if ids := k.IDs(); !reflect.DeepEqual(ids, []string{"a", "b", "c"}) {
+		t.Errorf("IDs were %v, but expected %v", ids, []string{"a", "b", "c"})
+	}
+
+	if err := k.SetPrimary("b"); err != nil {
+		t.Fatal(err)
+	}
+
+	ciphertext := k.Seal(nil, nil, []byte("keyring"), []byte("ad"))
+	expected := aeads["b"].Seal(nil, nil, []byte("keyring"), []byte("ad"))
+	if !bytes.Equal(ciphertext, expected) {
+		t.Errorf("Ciphertext was %x, but expected %x", ciphertext, expected)
+	}
+
+	for id, aead := range aeads {
+		ciphertext := aead.Seal(nil, nil, []byte(id), nil)
+
+		plaintext, openedBy, err := k.open(nil, nil, ciphertext, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+
+		if string(plaintext) != id || openedBy != id {
+			t.Errorf("Opened %q with %q, but expected %q", plaintext, openedBy, id)
+		}
+	}
+
+	other, _ := New(testKey(0, 32), aes.NewCipher)
+	if p, err := k.Open(nil, nil, other.Seal(nil, nil, []byte("other"), nil), nil); err != ErrAuthentication {
+		t.Fatalf("Plaintext returned instead of error: %x", p)
+	}
+}
+
+func TestKeyringInPlace(t *testing.T) {
+	k, aeads := testKeyring(t, "a", "b", "c")
+
+	for id, aead := range aeads {
+		ciphertext := aead.Seal(nil, nil, []byte("in place "+id), []byte("ad"))
+		plaintext, err := k.Open(ciphertext[:0], nil, ciphertext, []byte("ad"))
+		if err != nil {
+			t.Fatalf("%s: %v", id, err)
+		}
+		if string(plaintext) != "in place "+id || &plaintext[0] != &ciphertext[0] {
+			t.Errorf("%s: plaintext was %q, but expected %q in place", id, plaintext, "in place "+id)
+		}
+	}
+
+	if err := k.SetState("c", KeyRevoked); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext := aeads["c"].Seal(nil, nil, []byte("revoked"), nil)
+	var re *RevokedKeyError
+	if p, err := k.Open(ciphertext[:0], nil, ciphertext, nil); !errors.As(err, &re) || re.ID != "c" {
+		t.Errorf("Error was %v, but expected a RevokedKeyError (plaintext %q)", err, p)
+	}
+}
+
+func TestKeyringStates(t *testing.T) {
+	k, aeads := testKeyring(t, "a", "b", "c")
+
+	for _, tt := range []struct {
+		id       string
+		state    KeyState
+		expected error
+	}{
+		{"a", KeyOpenOnly, ErrKeyState},
+		{"b", KeyOpenOnly, nil},
+		{"b", KeyPrimary, ErrKeyState},
+		{"c", KeyRevoked, nil},
+		{"c", KeyPrimary, ErrKeyState},
+		{"c", KeyState(0), ErrKeyState},
+		{"z", KeyActive, ErrUnknownKeyID},
+		{"c", KeyActive, nil},
+		{"c", KeyPrimary, nil},
+		{"a", KeyRevoked, nil},
+	} {
+		if err := k.SetState(tt.id, tt.state); !errors.Is(err, tt.expected) {
+			t.Errorf("SetState(%q, %v) error was %v, but expected %v", tt.id, tt.state, err, tt.expected)
+		}
+	}
+
+	expected := []KeyStatus{
+		{ID: "a", State: KeyRevoked, Fingerprint: fingerprintOf(aeads["a"])},
+		{ID: "b", State: KeyOpenOnly, Fingerprint: fingerprintOf(aeads["b"])},
+		{ID: "c", State: KeyPrimary, Fingerprint: fingerprintOf(aeads["c"])},
+	}
+	if status := k.Status(); !reflect.DeepEqual(status, expected) {
+		t.Errorf("Status was %+v, but expected %+v", status, expected)
+	}
+	if len(expected[0].Fingerprint) == 0 {
+		t.Error("Status had no fingerprint")
+	}
+
+	// Only the primary seals.
+	ciphertext := k.Seal(nil, nil, []byte("keyring"), nil)
+	if e := aeads["c"].Seal(nil, nil, []byte("keyring"), nil); !bytes.Equal(ciphertext, e) {
+		t.Errorf("Ciphertext was %x, but expected %x", ciphertext, e)
+	}
+
+	// Open-only keys open.
+	if p, err := k.Open(nil, nil, aeads["b"].Seal(nil, nil, []byte("b"), nil), nil); err != nil || string(p) != "b" {
+		t.Errorf("Plaintext was %q, error %v, but expected %q", p, err, "b")
+	}
+
+	// Revoked keys don't, but are identified.
+	revoked := aeads["a"].Seal(nil, nil, []byte("a"), nil)
+	dst := []byte("dst")
+	p, err := k.Open(dst, nil, revoked, nil)
+	var re *RevokedKeyError
+	if !errors.As(err, &re) || !errors.Is(err, ErrKeyRevoked) {
+		t.Fatalf("Plaintext returned instead of error: %x", p)
+	}
+	if re.ID != "a" || !bytes.Equal(re.Fingerprint, expected[0].Fingerprint) {
+		t.Errorf("Error was %+v, but expected key %q with fingerprint %x", re, "a", expected[0].Fingerprint)
+	}
+	if !bytes.Equal(dst, []byte("dst")) {
+		t.Errorf("Destination was %q, but expected it unchanged", dst)
+	}
+
+	// Nor do they when named by an EncryptedBox.
+	header := []byte{boxVersion, 1, 'a'}
+	box := NewEncryptedBox(k, nil)
+	if err := box.GobDecode(aeads["a"].Seal(header, nil, []byte("a"), header)); !errors.As(err, &re) {
+		t.Errorf("Error was %v, but expected a RevokedKeyError", err)
+	}
+
+	if s, ok := k.State("b"); !ok || s != KeyOpenOnly {
+		t.Errorf("State was %v, but expected %v", s, KeyOpenOnly)
+	}
+	if _, ok := k.State("z"); ok {
+		t.Error("State found an unknown key")
+	}
+}
+
+func TestKeyringErrors(t *testing.T) {
+	k, aeads := testKeyring(t, "a")
+
+	if err := k.Add("a", aeads["a"]); err != ErrDuplicateKeyID {
+		t.Errorf("Error was %v, but expected %v", err, ErrDuplicateKeyID)
+	}
+
+	if err := k.SetPrimary("z"); err != ErrUnknownKeyID {
+		t.Errorf("Error was %v, but expected %v", err, ErrUnknownKeyID)
+	}
+
+	if _, ok := k.Lookup("z"); ok {
+		t.Errorf("Lookup found an unknown key")
+	}
+
+	if !panics(func() { NewKeyring().Seal(nil, nil, nil, nil) }) {
+		t.Errorf("Seal with an empty keyring did not panic")
+	}
+
+	if _, err := NewKeyring().Open(nil, nil, make([]byte, 16), nil); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+}
@@ -0,0 +1,85 @@
+package siv
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+)
+
+// Key256, Key384, and Key512 are keys for AES-SIV-CMAC-256, -384, and -512:
+// AES-128, AES-192, and AES-256 respectively, doubled. Unlike a []byte, a key
+// of the wrong size for the intended algorithm fails to compile, so new code
+// should prefer them, with NewAEAD256 and friends, to New.
+//
+// NewAEAD256 and friends panic on a key which New would reject as weak: one
+// which is all zeros, or whose halves are identical.
+type (
+	Key256 [32]byte
+	Key384 [48]byte
+	Key512 [64]byte
+)
+
+// NewAEAD256 returns an AES-SIV-CMAC-256 AEAD. It is equivalent to
+// New(key[:], aes.NewCipher).
+func NewAEAD256(key Key256) cipher.AEAD {
+	return mustNewAES(key[:])
+}
+
+// NewAEAD384 returns an AES-SIV-CMAC-384 AEAD. It is equivalent to
+// New(key[:], aes.NewCipher).
+func NewAEAD384(key Key384) cipher.AEAD {
+	return mustNewAES(key[:])
+}
+
+// NewAEAD512 returns an AES-SIV-CMAC-512 AEAD. It is equivalent to
+// New(key[:], aes.NewCipher).
+func NewAEAD512(key Key512) cipher.AEAD {
+	return mustNewAES(key[:])
+}
+
+// mustNewAES calls New with a key whose size is known to be valid.
+func mustNewAES(key []byte) cipher.AEAD {
+	aead, err := New(key, aes.NewCipher)
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+// ParseKey256 converts b to a Key256, returning an error unless it is exactly
+// 32 bytes.
+func ParseKey256(b []byte) (Key256, error) {
+	var k Key256
+	if err := parseKey(k[:], b); err != nil {
+		return Key256{}, err
+	}
+	return k, nil
+}
+
+// ParseKey384 converts b to a Key384, returning an error unless it is exactly
+// 48 bytes.
+func ParseKey384(b []byte) (Key384, error) {
+	var k Key384
+	if err := parseKey(k[:], b); err != nil {
+		return Key384{}, err
+	}
+	return k, nil
+}
+
+// ParseKey512 converts b to a Key512, returning an error unless it is exactly
+// 64 bytes.
+func ParseKey512(b []byte) (Key512, error) {
+	var k Key512
+	if err := parseKey(k[:], b); err != nil {
+		return Key512{}, err
+	}
+	return k, nil
+}
+
+func parseKey(k, b []byte) error {
+	if len(b) != len(k) {
+		return fmt.Errorf("siv: key is %d bytes, but expected %d", len(b), len(k))
+	}
+	copy(k, b)
+	return nil
+}
@@ -0,0 +1,82 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestNewAEAD256(t *testing.T) {
+	// https://tools.ietf.org/html/rfc5297#appendix-A.1
+	b, _ := hex.DecodeString("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	data, _ := hex.DecodeString("101112131415161718191a1b1c1d1e1f2021222324252627")
+	plaintext, _ := hex.DecodeString("112233445566778899aabbccddee")
+	ciphertext, _ := hex.DecodeString("85632d07c6e8f37f950acd320a2ecc9340c02b9690c4dc04daef7f6afe5c")
+
+	key, err := ParseKey256(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := NewAEAD256(key).Seal(nil, nil, plaintext, data)
+	if !bytes.Equal(actual, ciphertext) {
+		t.Errorf("Ciphertext was %x, but expected %x", actual, ciphertext)
+	}
+}
+
+func TestTypedKeysMatchNew(t *testing.T) {
+	b := make([]byte, 64)
+	for i := range b {
+		b[i] = byte(i)
+	}
+	plaintext, data := []byte("yay"), []byte("ad")
+
+	k384, _ := ParseKey384(b[:48])
+	k512, _ := ParseKey512(b)
+	for _, v := range []struct {
+		n        int
+		actual   []byte
+		expected string
+	}{
+		{48, NewAEAD384(k384).Seal(nil, nil, plaintext, data), "AES-SIV-CMAC-384"},
+		{64, NewAEAD512(k512).Seal(nil, nil, plaintext, data), "AES-SIV-CMAC-512"},
+	} {
+		aead, _ := New(b[:v.n], aes.NewCipher)
+		if expected := aead.Seal(nil, nil, plaintext, data); !bytes.Equal(v.actual, expected) {
+			t.Errorf("Ciphertext was %x, but expected %x", v.actual, expected)
+		}
+	}
+
+	if alg := NewAEAD512(k512).(Info).Algorithm(); alg != "AES-SIV-CMAC-512" {
+		t.Errorf("Algorithm was %s, but expected %s", alg, "AES-SIV-CMAC-512")
+	}
+}
+
+func TestParseKeySize(t *testing.T) {
+	for _, n := range []int{0, 16, 31, 33, 48, 64} {
+		if k, err := ParseKey256(make([]byte, n)); err == nil {
+			t.Errorf("Key returned instead of error for %d bytes: %x", n, k)
+		}
+	}
+
+	for _, n := range []int{32, 47, 49, 64} {
+		if k, err := ParseKey384(make([]byte, n)); err == nil {
+			t.Errorf("Key returned instead of error for %d bytes: %x", n, k)
+		}
+	}
+
+	for _, n := range []int{32, 48, 63, 65} {
+		if k, err := ParseKey512(make([]byte, n)); err == nil {
+			t.Errorf("Key returned instead of error for %d bytes: %x", n, k)
+		}
+	}
+
+	// The key is copied.
+	b := make([]byte, 32)
+	k, _ := ParseKey256(b)
+	b[0] = 1
+	if k[0] != 0 {
+		t.Error("Key shares storage with its input")
+	}
+}
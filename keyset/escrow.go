@@ -0,0 +1,156 @@
+package keyset
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+
+	siv "github.com/stripe/siv-go"
+	"github.com/stripe/siv-go/sivhpke"
+)
+
+// escrowVersion is the version of the escrow format.
+const escrowVersion = 1
+
+// Escrow KEMs, identifying how the payload key is encapsulated.
+const (
+	escrowX25519 = 1
+	escrowRSA    = 2
+)
+
+// MinEscrowRSABits is the smallest RSA modulus Escrow accepts.
+const MinEscrowRSABits = 2048
+
+// ErrEscrowKey is returned by Escrow and RecoverEscrow for a key of an
+// unsupported type or size, or which doesn't match the escrow's KEM.
+var ErrEscrowKey = errors.New("keyset: unsupported escrow key")
+
+// Escrow validates ks and encrypts it to recipientPub, an offline public key,
+// for disaster recovery. recipientPub is an X25519 *ecdh.PublicKey or an
+// *rsa.PublicKey of at least MinEscrowRSABits bits.
+//
+// The escrow is a version byte, a KEM byte, the big-endian uint16 length of
+// the encapsulated key, the encapsulated key, and the JSON keyset sealed with
+// AES-SIV-CMAC-512. For X25519 (KEM 1), the keyset is sealed with sivhpke and
+// the encapsulated key is the ephemeral public key. For RSA (KEM 2), the
+// encapsulated key is a random 64-byte payload key encrypted with RSA-OAEP
+// over SHA-256, and the keyset is sealed under the payload key with the
+// encapsulated key as an S2V component. Either way, the version and KEM are
+// bound as associated data, and for RSA also as the OAEP label.
+func Escrow(ks *Keyset, recipientPub any, opts ...Option) ([]byte, error) {
+	o := newOptions(opts)
+
+	var buf bytes.Buffer
+	if err := InsecureSave(&buf, ks); err != nil {
+		return nil, err
+	}
+	defer wipe(buf.Bytes())
+
+	var kem byte
+	var enc, ciphertext []byte
+	switch pub := recipientPub.(type) {
+	case *ecdh.PublicKey:
+		if pub.Curve() != ecdh.X25519() {
+			return nil, ErrEscrowKey
+		}
+
+		kem = escrowX25519
+		var err error
+		enc, ciphertext, err = sivhpke.Seal(pub.Bytes(), buf.Bytes(), escrowAD(kem), sivhpke.WithRand(o.rand))
+		if err != nil {
+			return nil, err
+		}
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < MinEscrowRSABits {
+			return nil, ErrEscrowKey
+		}
+
+		kem = escrowRSA
+		var key siv.Key512
+		defer wipe(key[:])
+		if _, err := io.ReadFull(o.rand, key[:]); err != nil {
+			return nil, err
+		}
+
+		var err error
+		enc, err = rsa.EncryptOAEP(sha256.New(), o.rand, pub, key[:], escrowAD(kem))
+		if err != nil {
+			return nil, err
+		}
+		ciphertext = siv.NewContext(siv.NewAEAD512(key), enc).Seal(nil, nil, buf.Bytes(), escrowAD(kem))
+	default:
+		return nil, ErrEscrowKey
+	}
+
+	out := []byte{escrowVersion, kem}
+	out = binary.BigEndian.AppendUint16(out, uint16(len(enc)))
+	out = append(out, enc...)
+	return append(out, ciphertext...), nil
+}
+
+// RecoverEscrow decrypts an escrow written by Escrow with recipientPriv, the
+// private key corresponding to its public key: an X25519 *ecdh.PrivateKey or
+// an *rsa.PrivateKey. It returns ErrVersion for an unknown version,
+// ErrEscrowKey if recipientPriv is of the wrong type for the escrow, and
+// ErrDecrypt if the escrow fails to decrypt, as under the wrong key.
+func RecoverEscrow(blob []byte, recipientPriv any) (*Keyset, error) {
+	if len(blob) == 0 || blob[0] != escrowVersion {
+		return nil, ErrVersion
+	}
+	if len(blob) < 4 {
+		return nil, ErrDecrypt
+	}
+
+	kem, n := blob[1], int(binary.BigEndian.Uint16(blob[2:4]))
+	if len(blob) < 4+n {
+		return nil, ErrDecrypt
+	}
+	enc, ciphertext := blob[4:4+n], blob[4+n:]
+
+	var plaintext []byte
+	switch priv := recipientPriv.(type) {
+	case *ecdh.PrivateKey:
+		if kem != escrowX25519 || priv.Curve() != ecdh.X25519() {
+			return nil, ErrEscrowKey
+		}
+
+		var err error
+		plaintext, err = sivhpke.Open(priv.Bytes(), enc, ciphertext, escrowAD(kem))
+		if err != nil {
+			return nil, ErrDecrypt
+		}
+	case *rsa.PrivateKey:
+		if kem != escrowRSA {
+			return nil, ErrEscrowKey
+		}
+
+		key, err := rsa.DecryptOAEP(sha256.New(), nil, priv, enc, escrowAD(kem))
+		if err != nil || len(key) != len(siv.Key512{}) {
+			return nil, ErrDecrypt
+		}
+
+		var k siv.Key512
+		copy(k[:], key)
+		wipe(key)
+		aead := siv.NewAEAD512(k)
+		wipe(k[:])
+
+		plaintext, err = siv.NewContext(aead, enc).Open(nil, nil, ciphertext, escrowAD(kem))
+		if err != nil {
+			return nil, ErrDecrypt
+		}
+	default:
+		return nil, ErrEscrowKey
+	}
+	defer wipe(plaintext)
+
+	return Load(bytes.NewReader(plaintext))
+}
+
+func escrowAD(kem byte) []byte {
+	return []byte{'s', 'i', 'v', ' ', 'k', 'e', 'y', 's', 'e', 't', ' ', 'e', 's', 'c', 'r', 'o', 'w', ' ', 'v', escrowVersion, kem}
+}
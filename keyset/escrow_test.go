@@ -0,0 +1,147 @@
+package keyset
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/rsa"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+var (
+	escrowRSAOnce sync.Once
+	escrowRSAKeys [2]*rsa.PrivateKey
+)
+
+// escrowKeys returns two X25519 and two RSA private keys.
+func escrowKeys(t *testing.T) []any {
+	escrowRSAOnce.Do(func() {
+		for i := range escrowRSAKeys {
+			k, err := rsa.GenerateKey(rand.Reader, MinEscrowRSABits)
+			if err != nil {
+				panic(err)
+			}
+			escrowRSAKeys[i] = k
+		}
+	})
+
+	var keys []any
+	for i := 0; i < 2; i++ {
+		k, err := ecdh.X25519().GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, k)
+	}
+	return append(keys, escrowRSAKeys[0], escrowRSAKeys[1])
+}
+
+func public(priv any) any {
+	switch priv := priv.(type) {
+	case *ecdh.PrivateKey:
+		return priv.PublicKey()
+	case *rsa.PrivateKey:
+		return &priv.PublicKey
+	}
+	return nil
+}
+
+func TestEscrow(t *testing.T) {
+	ks, _ := loadFixture(t)
+	keys := escrowKeys(t)
+
+	for i, priv := range keys {
+		blob, err := Escrow(ks, public(priv))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := RecoverEscrow(blob, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(actual, ks) {
+			t.Errorf("Keyset was %+v, but expected %+v", actual, ks)
+		}
+
+		// Keys 0 and 1 are X25519, and 2 and 3 RSA.
+		wrong := keys[i^1]
+		if actual, err := RecoverEscrow(blob, wrong); err != ErrDecrypt {
+			t.Errorf("Error was %v, but expected %v (keyset %+v)", err, ErrDecrypt, actual)
+		}
+		if _, err := RecoverEscrow(blob, keys[(i+2)%4]); err != ErrEscrowKey {
+			t.Errorf("Error was %v, but expected %v", err, ErrEscrowKey)
+		}
+
+		for j := 1; j < len(blob); j++ {
+			tampered := append([]byte{}, blob...)
+			tampered[j] ^= 0x01
+			if actual, err := RecoverEscrow(tampered, priv); err == nil {
+				t.Fatalf("Byte %d: keyset returned instead of error: %+v", j, actual)
+			}
+		}
+
+		for _, n := range []int{0, 1, 3, 4, 20, len(blob) - 1} {
+			if actual, err := RecoverEscrow(blob[:n], priv); err == nil {
+				t.Fatalf("%d bytes: keyset returned instead of error: %+v", n, actual)
+			}
+		}
+
+		blob[0] = 2
+		if _, err := RecoverEscrow(blob, priv); err != ErrVersion {
+			t.Errorf("Error was %v, but expected %v", err, ErrVersion)
+		}
+	}
+}
+
+func TestEscrowKeys(t *testing.T) {
+	ks, _ := loadFixture(t)
+
+	p256, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pub := range []any{nil, []byte{1, 2, 3}, p256.PublicKey(), &small.PublicKey} {
+		if _, err := Escrow(ks, pub); err != ErrEscrowKey {
+			t.Errorf("Error was %v, but expected %v", err, ErrEscrowKey)
+		}
+	}
+
+	if _, err := Escrow(&Keyset{}, public(escrowKeys(t)[0])); err != ErrEmpty {
+		t.Errorf("Error was %v, but expected %v", err, ErrEmpty)
+	}
+}
+
+func TestEscrowRand(t *testing.T) {
+	ks, _ := loadFixture(t)
+
+	// X25519 reads the ephemeral key; RSA reads the payload key and then the
+	// OAEP seed.
+	for i, priv := range escrowKeys(t)[1:3] {
+		expected := []int{32, 64 + 32}[i]
+
+		cr := &countingReader{}
+		blob, err := Escrow(ks, public(priv), WithRand(cr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cr.n != expected {
+			t.Errorf("%T: read %d random bytes, but expected %d", priv, cr.n, expected)
+		}
+		if actual, err := RecoverEscrow(blob, priv); err != nil || !reflect.DeepEqual(actual, ks) {
+			t.Errorf("%T: recovered %v, %v", priv, actual, err)
+		}
+
+		for _, n := range []int{1, expected - 1} {
+			if blob, err := Escrow(ks, public(priv), WithRand(&countingReader{limit: n, err: errHSM})); err == nil {
+				t.Errorf("%T: escrow returned instead of error after %d random bytes: %x", priv, n, blob)
+			}
+		}
+	}
+}
@@ -0,0 +1,265 @@
+// Package keyset reads and writes sets of SIV keys as JSON, so they can be
+// moved between services and environments in a reviewable format, and turns
+// them into keyrings.
+//
+// A keyset is a JSON object with a single "keys" array. Each key has an "id",
+// the registered name of its "algorithm" (e.g. "AES-SIV-CMAC-256"), the
+// standard base64 encoding of its key "material", a "status" of "enabled",
+// "open_only", "revoked", or "disabled", a "primary" flag set on exactly one
+// key, and a "created_at" RFC 3339 timestamp.
+//
+// Keysets which will be stored should be sealed under a master AEAD with
+// SealTo rather than written in the clear with InsecureSave. Escrow copies,
+// for disaster recovery, are encrypted to an offline public key with Escrow.
+package keyset
+
+import (
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	siv "github.com/stripe/siv-go"
+)
+
+// Status is the status of a key in a keyset.
+type Status string
+
+const (
+	// Enabled keys are added to keyrings as active keys, or as the primary.
+	Enabled Status = "enabled"
+
+	// OpenOnly keys are added to keyrings as siv.KeyOpenOnly keys.
+	OpenOnly Status = "open_only"
+
+	// Revoked keys are added to keyrings as siv.KeyRevoked keys, so that
+	// data sealed under them is identified rather than failing to open.
+	Revoked Status = "revoked"
+
+	// Disabled keys are kept in the keyset but left out of keyrings.
+	Disabled Status = "disabled"
+)
+
+var (
+	// ErrEmpty is returned for a keyset with no keys.
+	ErrEmpty = errors.New("keyset: no keys")
+
+	// ErrDuplicateID is returned when two keys share an ID.
+	ErrDuplicateID = errors.New("keyset: duplicate key ID")
+
+	// ErrMissingID is returned for a key with an empty ID.
+	ErrMissingID = errors.New("keyset: missing key ID")
+
+	// ErrNoPrimary is returned when no key is marked as the primary.
+	ErrNoPrimary = errors.New("keyset: no primary key")
+
+	// ErrMultiplePrimaries is returned when more than one key is marked as the
+	// primary.
+	ErrMultiplePrimaries = errors.New("keyset: multiple primary keys")
+
+	// ErrPrimaryDisabled is returned when the primary key is disabled.
+	ErrPrimaryDisabled = errors.New("keyset: primary key is disabled")
+
+	// ErrStatus is returned for a key with an unknown status.
+	ErrStatus = errors.New("keyset: invalid key status")
+
+	// ErrKey is returned for key material which the declared algorithm rejects,
+	// e.g. because of its length.
+	ErrKey = errors.New("keyset: invalid key material")
+)
+
+// Keyset is a set of keys, one of which is the primary.
+type Keyset struct {
+	Keys []Key `json:"keys"`
+}
+
+// Key is a single key in a keyset.
+type Key struct {
+	ID        string    `json:"id"`
+	Algorithm string    `json:"algorithm"`
+	Material  []byte    `json:"material"`
+	Status    Status    `json:"status"`
+	Primary   bool      `json:"primary"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Load reads and validates a keyset. Unknown fields are rejected.
+func Load(r io.Reader) (*Keyset, error) {
+	d := json.NewDecoder(r)
+	d.DisallowUnknownFields()
+
+	var ks Keyset
+	if err := d.Decode(&ks); err != nil {
+		return nil, fmt.Errorf("keyset: %w", err)
+	}
+
+	if _, err := d.Token(); err != io.EOF {
+		return nil, errors.New("keyset: trailing data after keyset")
+	}
+
+	if err := ks.Validate(); err != nil {
+		return nil, err
+	}
+	return &ks, nil
+}
+
+// InsecureSave validates ks and writes it to w, including all key material in
+// the clear. Use SealTo to write keysets which will be stored.
+func InsecureSave(w io.Writer, ks *Keyset) error {
+	if err := ks.Validate(); err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(ks, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// Validate checks that the keyset has at least one key, that every key has a
+// unique ID, a known status, and material of the right length for its
+// algorithm, and that exactly one enabled key is the primary.
+func (ks *Keyset) Validate() error {
+	if len(ks.Keys) == 0 {
+		return ErrEmpty
+	}
+
+	ids := make(map[string]bool)
+	primaries := 0
+	for _, k := range ks.Keys {
+		if k.ID == "" {
+			return ErrMissingID
+		}
+
+		if ids[k.ID] {
+			return fmt.Errorf("%w: %q", ErrDuplicateID, k.ID)
+		}
+		ids[k.ID] = true
+
+		switch k.Status {
+		case Enabled, OpenOnly, Revoked, Disabled:
+		default:
+			return fmt.Errorf("%w: %q for key %q", ErrStatus, k.Status, k.ID)
+		}
+
+		if _, err := k.AEAD(); err != nil {
+			return err
+		}
+
+		if k.Primary {
+			if k.Status != Enabled {
+				return fmt.Errorf("%w: %q", ErrPrimaryDisabled, k.ID)
+			}
+			primaries++
+		}
+	}
+
+	switch {
+	case primaries == 0:
+		return ErrNoPrimary
+	case primaries > 1:
+		return ErrMultiplePrimaries
+	}
+	return nil
+}
+
+// AEAD returns the AEAD for the key, constructed with siv.NewNamed.
+func (k *Key) AEAD() (cipher.AEAD, error) {
+	aead, err := siv.NewNamed(k.Algorithm, k.Material)
+	if err != nil {
+		return nil, fmt.Errorf("%w %q: %v", ErrKey, k.ID, err)
+	}
+	return aead, nil
+}
+
+// Keyring validates ks and returns a keyring holding all but its disabled
+// keys, with its primary key as the keyring's primary and the other keys in
+// the states corresponding to their statuses.
+func (ks *Keyset) Keyring() (*siv.Keyring, error) {
+	if err := ks.Validate(); err != nil {
+		return nil, err
+	}
+
+	k := siv.NewKeyring()
+	for _, key := range ks.Keys {
+		if key.Status == Disabled {
+			continue
+		}
+
+		aead, err := key.AEAD()
+		if err != nil {
+			return nil, err
+		}
+
+		if err := k.Add(key.ID, aead); err != nil {
+			return nil, err
+		}
+
+		if key.Primary {
+			if err := k.SetPrimary(key.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// States are set once the primary is, as the first key added is
+	// provisionally the primary.
+	for _, key := range ks.Keys {
+		var state siv.KeyState
+		switch key.Status {
+		case OpenOnly:
+			state = siv.KeyOpenOnly
+		case Revoked:
+			state = siv.KeyRevoked
+		default:
+			continue
+		}
+
+		if err := k.SetState(key.ID, state); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// UpdateStatuses copies the states of k's keys back to the keys in ks with the
+// same IDs, so that state changes made at runtime can be saved. Keys which
+// aren't in k, such as disabled keys, are unchanged. It returns an error if k
+// holds a key which isn't in ks, and validates the result.
+func (ks *Keyset) UpdateStatuses(k *siv.Keyring) error {
+	index := make(map[string]int, len(ks.Keys))
+	for i, key := range ks.Keys {
+		index[key.ID] = i
+	}
+
+	updated := append([]Key{}, ks.Keys...)
+	for _, status := range k.Status() {
+		i, ok := index[status.ID]
+		if !ok {
+			return fmt.Errorf("keyset: keyring key %q is not in the keyset", status.ID)
+		}
+
+		key := &updated[i]
+		key.Primary = status.State == siv.KeyPrimary
+		switch status.State {
+		case siv.KeyPrimary, siv.KeyActive:
+			key.Status = Enabled
+		case siv.KeyOpenOnly:
+			key.Status = OpenOnly
+		case siv.KeyRevoked:
+			key.Status = Revoked
+		}
+	}
+
+	next := Keyset{Keys: updated}
+	if err := next.Validate(); err != nil {
+		return err
+	}
+	ks.Keys = updated
+	return nil
+}
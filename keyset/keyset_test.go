@@ -0,0 +1,220 @@
+package keyset
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func loadFixture(t *testing.T) (*Keyset, []byte) {
+	b, err := os.ReadFile("testdata/keyset.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ks, err := Load(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ks, b
+}
+
+func TestLoad(t *testing.T) {
+	ks, _ := loadFixture(t)
+
+	if len(ks.Keys) != 3 {
+		t.Fatalf("Loaded %d keys, but expected 3", len(ks.Keys))
+	}
+
+	k := ks.Keys[2]
+	expected := Key{
+		ID:        "2026-01",
+		Algorithm: siv.AESSIVCMAC256,
+		Material:  k.Material,
+		Status:    Enabled,
+		Primary:   true,
+		CreatedAt: time.Date(2026, 1, 5, 9, 30, 0, 0, time.UTC),
+	}
+	if !reflect.DeepEqual(k, expected) {
+		t.Errorf("Key was %+v, but expected %+v", k, expected)
+	}
+
+	if k.Material[0] != 0x80 || len(k.Material) != 32 {
+		t.Errorf("Material was %x", k.Material)
+	}
+}
+
+// TestSchema pins the JSON format: saving the loaded fixture must reproduce it
+// byte for byte.
+func TestSchema(t *testing.T) {
+	ks, expected := loadFixture(t)
+
+	var buf bytes.Buffer
+	if err := InsecureSave(&buf, ks); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := buf.Bytes(); !bytes.Equal(actual, expected) {
+		t.Errorf("Saved keyset was\n%s\nbut expected\n%s", actual, expected)
+	}
+}
+
+func TestKeyring(t *testing.T) {
+	ks, _ := loadFixture(t)
+
+	k, err := ks.Keyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ids := k.IDs(); !reflect.DeepEqual(ids, []string{"2025-07", "2026-01"}) {
+		t.Errorf("IDs were %v, but expected %v", ids, []string{"2025-07", "2026-01"})
+	}
+
+	if id, _ := k.Primary(); id != "2026-01" {
+		t.Errorf("Primary was %q, but expected %q", id, "2026-01")
+	}
+
+	old, _ := ks.Keys[1].AEAD()
+	ciphertext := old.Seal(nil, nil, []byte("keyset"), nil)
+	if p, err := k.Open(nil, nil, ciphertext, nil); err != nil || string(p) != "keyset" {
+		t.Errorf("Opened %q (error %v)", p, err)
+	}
+
+	disabled, _ := ks.Keys[0].AEAD()
+	ciphertext = disabled.Seal(nil, nil, []byte("keyset"), nil)
+	if p, err := k.Open(nil, nil, ciphertext, nil); err == nil {
+		t.Fatalf("Plaintext returned instead of error: %x", p)
+	}
+}
+
+func TestKeyringStates(t *testing.T) {
+	ks, _ := loadFixture(t)
+	ks.Keys[0].Status = Revoked
+	ks.Keys[1].Status = OpenOnly
+
+	var buf bytes.Buffer
+	if err := InsecureSave(&buf, ks); err != nil {
+		t.Fatal(err)
+	}
+	ks, err := Load(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k, err := ks.Keyring()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var states []siv.KeyState
+	for _, s := range k.Status() {
+		states = append(states, s.State)
+	}
+	expected := []siv.KeyState{siv.KeyRevoked, siv.KeyOpenOnly, siv.KeyPrimary}
+	if !reflect.DeepEqual(states, expected) {
+		t.Errorf("States were %v, but expected %v", states, expected)
+	}
+
+	// Roll back to 2025-07, and retire 2026-01.
+	if err := k.SetState("2025-07", siv.KeyActive); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.SetPrimary("2025-07"); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.SetState("2026-01", siv.KeyOpenOnly); err != nil {
+		t.Fatal(err)
+	}
+	if err := ks.UpdateStatuses(k); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range []struct {
+		status  Status
+		primary bool
+	}{
+		{Revoked, false},
+		{Enabled, true},
+		{OpenOnly, false},
+	} {
+		if key := ks.Keys[i]; key.Status != expected.status || key.Primary != expected.primary {
+			t.Errorf("%s was %s (primary %v), but expected %s (primary %v)", key.ID, key.Status, key.Primary, expected.status, expected.primary)
+		}
+	}
+
+	other := siv.NewKeyring()
+	_ = other.Add("unknown", mustAEAD(t, ks.Keys[2]))
+	if err := ks.UpdateStatuses(other); err == nil {
+		t.Error("No error for a key which isn't in the keyset")
+	}
+}
+
+func mustAEAD(t *testing.T, key Key) cipher.AEAD {
+	aead, err := key.AEAD()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func TestValidation(t *testing.T) {
+	ks, _ := loadFixture(t)
+
+	for _, v := range []struct {
+		name     string
+		modify   func(ks *Keyset)
+		expected error
+	}{
+		{"empty", func(ks *Keyset) { ks.Keys = nil }, ErrEmpty},
+		{"duplicate ID", func(ks *Keyset) { ks.Keys[1].ID = ks.Keys[0].ID }, ErrDuplicateID},
+		{"missing ID", func(ks *Keyset) { ks.Keys[1].ID = "" }, ErrMissingID},
+		{"no primary", func(ks *Keyset) { ks.Keys[2].Primary = false }, ErrNoPrimary},
+		{"two primaries", func(ks *Keyset) { ks.Keys[1].Primary = true }, ErrMultiplePrimaries},
+		{"disabled primary", func(ks *Keyset) { ks.Keys[2].Status = Disabled }, ErrPrimaryDisabled},
+		{"unknown status", func(ks *Keyset) { ks.Keys[0].Status = "retired" }, ErrStatus},
+		{"open-only primary", func(ks *Keyset) { ks.Keys[2].Status = OpenOnly }, ErrPrimaryDisabled},
+		{"short key", func(ks *Keyset) { ks.Keys[1].Material = ks.Keys[1].Material[:32] }, ErrKey},
+		{"long key", func(ks *Keyset) { ks.Keys[1].Algorithm = siv.AESSIVCMAC384 }, ErrKey},
+		{"unknown algorithm", func(ks *Keyset) { ks.Keys[1].Algorithm = "AES-GCM-256" }, ErrKey},
+	} {
+		c := *ks
+		c.Keys = append([]Key{}, ks.Keys...)
+		v.modify(&c)
+
+		if err := c.Validate(); !errors.Is(err, v.expected) {
+			t.Errorf("%s: Error was %v, but expected %v", v.name, err, v.expected)
+		}
+
+		if err := InsecureSave(&bytes.Buffer{}, &c); !errors.Is(err, v.expected) {
+			t.Errorf("%s: InsecureSave error was %v, but expected %v", v.name, err, v.expected)
+		}
+
+		if _, err := c.Keyring(); !errors.Is(err, v.expected) {
+			t.Errorf("%s: Keyring error was %v, but expected %v", v.name, err, v.expected)
+		}
+	}
+}
+
+func TestLoadMalformed(t *testing.T) {
+	_, fixture := loadFixture(t)
+
+	for _, s := range []string{
+		"",
+		"[]",
+		strings.Replace(string(fixture), `"primary"`, `"primray"`, 1),
+		string(fixture) + "{}",
+		strings.Replace(string(fixture), `"AAEC`, `"!AEC`, 1),
+	} {
+		if ks, err := Load(strings.NewReader(s)); err == nil {
+			t.Errorf("Loaded %+v from %q", ks, s)
+		}
+	}
+}
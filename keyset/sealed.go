@@ -0,0 +1,110 @@
+package keyset
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"io"
+)
+
+// sealedVersion is the version of the sealed keyset format.
+const sealedVersion = 1
+
+var (
+	// ErrVersion is returned by OpenFrom for a sealed keyset with an
+	// unsupported format version.
+	ErrVersion = errors.New("keyset: unsupported sealed keyset version")
+
+	// ErrDecrypt is returned by OpenFrom when a sealed keyset fails to open
+	// under the master AEAD.
+	ErrDecrypt = errors.New("keyset: sealed keyset failed to decrypt")
+)
+
+// An Option configures SealTo and Escrow.
+type Option func(*options)
+
+type options struct {
+	rand io.Reader
+}
+
+// WithRand makes SealTo and Escrow read random bytes from r rather than
+// crypto/rand, as for entropy from an HSM or for deterministic tests. SealTo
+// reads master's nonce; Escrow reads the 32-byte ephemeral X25519 private
+// key, or the 64-byte payload key and then the 32-byte RSA-OAEP seed. Either
+// fails if r returns fewer bytes than it needs.
+func WithRand(r io.Reader) Option {
+	return func(o *options) {
+		o.rand = r
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{rand: rand.Reader}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// SealTo validates ks and writes it to w sealed under master, which may be
+// backed by a KMS or derived from a passphrase. The output is a version byte,
+// a random nonce if master requires one, and the JSON keyset sealed with the
+// format version as associated data.
+func SealTo(w io.Writer, master cipher.AEAD, ks *Keyset, opts ...Option) error {
+	o := newOptions(opts)
+
+	var buf bytes.Buffer
+	if err := InsecureSave(&buf, ks); err != nil {
+		return err
+	}
+	defer wipe(buf.Bytes())
+
+	out := make([]byte, 1+master.NonceSize(), 1+master.NonceSize()+buf.Len()+master.Overhead())
+	out[0] = sealedVersion
+	nonce := out[1:]
+	if _, err := io.ReadFull(o.rand, nonce); err != nil {
+		return err
+	}
+
+	out = master.Seal(out, nonce, buf.Bytes(), sealedAD(sealedVersion))
+	_, err := w.Write(out)
+	return err
+}
+
+// OpenFrom reads a keyset written by SealTo, opens it under master, and
+// validates it.
+func OpenFrom(r io.Reader, master cipher.AEAD) (*Keyset, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b) == 0 || b[0] != sealedVersion {
+		return nil, ErrVersion
+	}
+	b = b[1:]
+
+	if len(b) < master.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	nonce, ciphertext := b[:master.NonceSize()], b[master.NonceSize():]
+
+	plaintext, err := master.Open(nil, nonce, ciphertext, sealedAD(sealedVersion))
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	defer wipe(plaintext)
+
+	return Load(bytes.NewReader(plaintext))
+}
+
+func sealedAD(version byte) []byte {
+	return []byte{'s', 'i', 'v', ' ', 'k', 'e', 'y', 's', 'e', 't', ' ', 'v', version}
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,178 @@
+package keyset
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func masters(t *testing.T, b byte) []cipher.AEAD {
+	s, err := siv.New(bytes.Repeat([]byte{b}, 32), aes.NewCipher, siv.AllowEqualHalves())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, _ := aes.NewCipher(bytes.Repeat([]byte{b}, 16))
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []cipher.AEAD{s, gcm}
+}
+
+func TestSealTo(t *testing.T) {
+	ks, fixture := loadFixture(t)
+
+	for _, master := range masters(t, 1) {
+		var buf bytes.Buffer
+		if err := SealTo(&buf, master, ks); err != nil {
+			t.Fatal(err)
+		}
+
+		if bytes.Contains(buf.Bytes(), []byte("material")) || bytes.Contains(buf.Bytes(), fixture[:20]) {
+			t.Errorf("Sealed keyset contains plaintext: %x", buf.Bytes())
+		}
+
+		actual, err := OpenFrom(bytes.NewReader(buf.Bytes()), master)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(actual, ks) {
+			t.Errorf("Keyset was %+v, but expected %+v", actual, ks)
+		}
+	}
+}
+
+func TestOpenFromWrongMaster(t *testing.T) {
+	ks, _ := loadFixture(t)
+	wrong := masters(t, 2)
+
+	for i, master := range masters(t, 1) {
+		var buf bytes.Buffer
+		if err := SealTo(&buf, master, ks); err != nil {
+			t.Fatal(err)
+		}
+
+		if actual, err := OpenFrom(bytes.NewReader(buf.Bytes()), wrong[i]); err != ErrDecrypt {
+			t.Errorf("Error was %v, but expected %v (keyset %+v)", err, ErrDecrypt, actual)
+		}
+	}
+}
+
+func TestOpenFromCorrupted(t *testing.T) {
+	ks, _ := loadFixture(t)
+	master := masters(t, 1)[0]
+
+	var buf bytes.Buffer
+	if err := SealTo(&buf, master, ks); err != nil {
+		t.Fatal(err)
+	}
+	sealed := buf.Bytes()
+
+	for i := range sealed {
+		corrupted := append([]byte{}, sealed...)
+		corrupted[i] ^= 0x10
+
+		expected := ErrDecrypt
+		if i == 0 {
+			expected = ErrVersion
+		}
+
+		if _, err := OpenFrom(bytes.NewReader(corrupted), master); err != expected {
+			t.Fatalf("Byte %d: Error was %v, but expected %v", i, err, expected)
+		}
+	}
+
+	for _, truncated := range [][]byte{nil, sealed[:1], sealed[:len(sealed)-1]} {
+		if _, err := OpenFrom(bytes.NewReader(truncated), master); err == nil {
+			t.Errorf("Opened truncated keyset %x", truncated)
+		}
+	}
+}
+
+func TestOpenFromVersion(t *testing.T) {
+	ks, _ := loadFixture(t)
+	master := masters(t, 1)[0]
+
+	var buf bytes.Buffer
+	if err := SealTo(&buf, master, ks); err != nil {
+		t.Fatal(err)
+	}
+
+	// A keyset sealed under a future version's AD can't be relabelled as
+	// version 1.
+	var plain bytes.Buffer
+	_ = InsecureSave(&plain, ks)
+	future := master.Seal([]byte{2}, nil, plain.Bytes(), sealedAD(2))
+
+	if _, err := OpenFrom(bytes.NewReader(future), master); err != ErrVersion {
+		t.Errorf("Error was %v, but expected %v", err, ErrVersion)
+	}
+
+	future[0] = sealedVersion
+	if _, err := OpenFrom(bytes.NewReader(future), master); err != ErrDecrypt {
+		t.Errorf("Error was %v, but expected %v", err, ErrDecrypt)
+	}
+}
+
+var errHSM = errors.New("HSM unavailable")
+
+// countingReader returns bytes counting up from zero, and counts how many it
+// has returned. After limit bytes, if limit is positive, it returns err.
+type countingReader struct {
+	n, limit int
+	err      error
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	if r.limit > 0 && r.n+len(p) > r.limit {
+		p = p[:r.limit-r.n]
+	}
+	for i := range p {
+		p[i] = byte(r.n + i)
+	}
+	r.n += len(p)
+	if len(p) == 0 {
+		return 0, r.err
+	}
+	return len(p), nil
+}
+
+func TestSealToRand(t *testing.T) {
+	ks, _ := loadFixture(t)
+
+	for _, master := range masters(t, 1) {
+		cr := &countingReader{}
+		var buf bytes.Buffer
+		if err := SealTo(&buf, master, ks, WithRand(cr)); err != nil {
+			t.Fatal(err)
+		}
+		if cr.n != master.NonceSize() {
+			t.Errorf("Read %d random bytes, but expected %d", cr.n, master.NonceSize())
+		}
+		nonce, expected := buf.Bytes()[1:1+cr.n], make([]byte, cr.n)
+		_, _ = (&countingReader{}).Read(expected)
+		if !bytes.Equal(nonce, expected) {
+			t.Errorf("Nonce was %x, but expected %x", nonce, expected)
+		}
+	}
+
+	var buf bytes.Buffer
+	gcm := masters(t, 1)[1]
+	if err := SealTo(&buf, gcm, ks, WithRand(&countingReader{limit: 5, err: io.EOF})); err != io.ErrUnexpectedEOF {
+		t.Errorf("Error was %v, but expected %v", err, io.ErrUnexpectedEOF)
+	}
+	if err := SealTo(&buf, gcm, ks, WithRand(&countingReader{limit: 5, err: errHSM})); err != errHSM {
+		t.Errorf("Error was %v, but expected %v", err, errHSM)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("Wrote %d bytes after a failed read", buf.Len())
+	}
+}
@@ -0,0 +1,58 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/sha256"
+	"flag"
+	"math"
+	"testing"
+)
+
+var large = flag.Bool("large", false, "run multi-gigabyte message tests")
+
+// TestLargeMessage seals and opens a message of just over 2 GiB in place,
+// which would overflow an int32 length.
+func TestLargeMessage(t *testing.T) {
+	if !*large {
+		t.Skip("multi-gigabyte tests are disabled; use -large")
+	}
+
+	if math.MaxInt == math.MaxInt32 {
+		t.Skip("requires a 64-bit platform")
+	}
+
+	n := math.MaxInt32
+	n += 1024 + 7
+	buf := make([]byte, n+16)
+	for i := 0; i < n; i += 4093 {
+		buf[i] = byte(i)
+	}
+	expected := sha256.Sum256(buf[:n])
+
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	ciphertext := aead.Seal(buf[:0], nil, buf[:n], []byte("large"))
+	if len(ciphertext) != n+16 || &ciphertext[0] != &buf[0] {
+		t.Fatalf("Ciphertext was %d bytes, but expected %d in place", len(ciphertext), n+16)
+	}
+
+	plaintext, err := aead.Open(buf[:0], nil, ciphertext, []byte("large"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := sha256.Sum256(plaintext); !bytes.Equal(actual[:], expected[:]) {
+		t.Errorf("Plaintext hash was %x, but expected %x", actual, expected)
+	}
+}
+
+func TestSizeLimits(t *testing.T) {
+	if n := MaxPlaintextSize + blockSize; n != math.MaxInt {
+		t.Errorf("Largest ciphertext was %d, but expected %d", n, math.MaxInt)
+	}
+
+	// Lengths which would overflow an int are refused rather than wrapping.
+	if _, _, err := sliceForAppend(make([]byte, 2), math.MaxInt-1); err != ErrOutputTooLarge {
+		t.Errorf("Error was %v, but expected %v", err, ErrOutputTooLarge)
+	}
+}
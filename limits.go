@@ -0,0 +1,111 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"errors"
+	"sync"
+)
+
+// ErrKeyExhausted is returned (or, from Seal, panicked with) when sealing
+// another message would exceed a key's usage limits.
+var ErrKeyExhausted = errors.New("siv: key usage limit exhausted")
+
+// Usage is the number of Seal operations performed and plaintext bytes sealed
+// under a key.
+type Usage struct {
+	Ops, Bytes uint64
+}
+
+// A LimitOption configures a LimitedAEAD.
+type LimitOption func(*LimitedAEAD)
+
+// WarnAt registers fn to be called, once, when usage first reaches the given
+// fraction of either limit. fn is called synchronously from the Seal which
+// crosses the threshold, so it should return quickly.
+func WarnAt(fraction float64, fn func(Usage)) LimitOption {
+	return func(l *LimitedAEAD) {
+		l.warnOps = uint64(fraction * float64(l.maxOps))
+		l.warnBytes = uint64(fraction * float64(l.maxBytes))
+		l.onWarn = fn
+	}
+}
+
+// WithUsageLimits returns an AEAD which seals with aead until maxOps messages
+// or maxBytes bytes of plaintext have been sealed, after which sealing fails
+// with ErrKeyExhausted. A limit of zero means no limit. Open is not limited.
+// The counters start at zero and can only be reset by wrapping a new AEAD.
+func WithUsageLimits(aead cipher.AEAD, maxOps, maxBytes uint64, opts ...LimitOption) *LimitedAEAD {
+	l := &LimitedAEAD{
+		AEAD:     aead,
+		maxOps:   maxOps,
+		maxBytes: maxBytes,
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// LimitedAEAD is an AEAD with usage limits. It is safe for concurrent use.
+type LimitedAEAD struct {
+	cipher.AEAD
+
+	maxOps, maxBytes   uint64
+	warnOps, warnBytes uint64
+	onWarn             func(Usage)
+
+	mu     sync.Mutex
+	usage  Usage
+	warned bool
+}
+
+// Usage returns the current usage.
+func (l *LimitedAEAD) Usage() Usage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.usage
+}
+
+// Seal seals plaintext as the wrapped AEAD does. It panics with
+// ErrKeyExhausted if the usage limits would be exceeded.
+func (l *LimitedAEAD) Seal(dst, nonce, plaintext, data []byte) []byte {
+	v, err := l.SealChecked(dst, nonce, plaintext, data)
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// SealChecked is like Seal, but returns ErrKeyExhausted rather than panicking.
+func (l *LimitedAEAD) SealChecked(dst, nonce, plaintext, data []byte) ([]byte, error) {
+	if err := l.reserve(uint64(len(plaintext))); err != nil {
+		return nil, err
+	}
+	return l.AEAD.Seal(dst, nonce, plaintext, data), nil
+}
+
+// reserve counts a Seal of n bytes, failing if it would exceed the limits.
+func (l *LimitedAEAD) reserve(n uint64) error {
+	l.mu.Lock()
+
+	u := Usage{Ops: l.usage.Ops + 1, Bytes: l.usage.Bytes + n}
+	if (l.maxOps != 0 && u.Ops > l.maxOps) ||
+		(l.maxBytes != 0 && (u.Bytes > l.maxBytes || u.Bytes < l.usage.Bytes)) {
+		l.mu.Unlock()
+		return ErrKeyExhausted
+	}
+	l.usage = u
+
+	warn := l.onWarn != nil && !l.warned &&
+		((l.maxOps != 0 && u.Ops >= l.warnOps) || (l.maxBytes != 0 && u.Bytes >= l.warnBytes))
+	if warn {
+		l.warned = true
+	}
+	l.mu.Unlock()
+
+	if warn {
+		l.onWarn(u)
+	}
+	return nil
+}
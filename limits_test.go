@@ -0,0 +1,120 @@
+package siv
+
+import (
+	"crypto/aes"
+	"sync"
+	"testing"
+)
+
+func TestUsageLimitsOps(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	l := WithUsageLimits(aead, 3, 0)
+
+	for i := 0; i < 3; i++ {
+		if _, err := l.SealChecked(nil, nil, []byte("yay"), nil); err != nil {
+			t.Fatalf("Seal %d: %v", i, err)
+		}
+	}
+
+	if _, err := l.SealChecked(nil, nil, []byte("yay"), nil); err != ErrKeyExhausted {
+		t.Errorf("Error was %v, but expected %v", err, ErrKeyExhausted)
+	}
+
+	if u, expected := l.Usage(), (Usage{Ops: 3, Bytes: 9}); u != expected {
+		t.Errorf("Usage was %+v, but expected %+v", u, expected)
+	}
+
+	// Opening is unaffected.
+	if _, err := l.Open(nil, nil, aead.Seal(nil, nil, []byte("yay"), nil), nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUsageLimitsBytes(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	l := WithUsageLimits(aead, 0, 10)
+
+	if _, err := l.SealChecked(nil, nil, make([]byte, 6), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := l.SealChecked(nil, nil, make([]byte, 5), nil); err != ErrKeyExhausted {
+		t.Errorf("Error was %v, but expected %v", err, ErrKeyExhausted)
+	}
+
+	if _, err := l.SealChecked(nil, nil, make([]byte, 4), nil); err != nil {
+		t.Fatalf("Sealing exactly up to the limit failed: %v", err)
+	}
+
+	if _, err := l.SealChecked(nil, nil, nil, nil); err != nil {
+		t.Fatalf("Sealing an empty message at the limit failed: %v", err)
+	}
+
+	if u, expected := l.Usage(), (Usage{Ops: 3, Bytes: 10}); u != expected {
+		t.Errorf("Usage was %+v, but expected %+v", u, expected)
+	}
+}
+
+func TestUsageLimitsPanics(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	l := WithUsageLimits(aead, 1, 0)
+	l.Seal(nil, nil, nil, nil)
+
+	defer func() {
+		if r := recover(); r != ErrKeyExhausted {
+			t.Errorf("Panic was %v, but expected %v", r, ErrKeyExhausted)
+		}
+	}()
+	l.Seal(nil, nil, nil, nil)
+}
+
+func TestUsageLimitsWarning(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+
+	var warnings []Usage
+	l := WithUsageLimits(aead, 10, 0, WarnAt(0.8, func(u Usage) {
+		warnings = append(warnings, u)
+	}))
+
+	for i := 0; i < 10; i++ {
+		l.Seal(nil, nil, nil, nil)
+		if i < 7 && len(warnings) != 0 {
+			t.Fatalf("Warned after %d ops", i+1)
+		}
+	}
+
+	if len(warnings) != 1 || warnings[0].Ops != 8 {
+		t.Errorf("Warnings were %+v, but expected one at 8 ops", warnings)
+	}
+}
+
+func TestUsageLimitsConcurrency(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	l := WithUsageLimits(aead, 100, 0)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if _, err := l.SealChecked(nil, nil, []byte("x"), nil); err == nil {
+					mu.Lock()
+					succeeded++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if succeeded != 100 {
+		t.Errorf("%d seals succeeded, but expected 100", succeeded)
+	}
+
+	if u, expected := l.Usage(), (Usage{Ops: 100, Bytes: 100}); u != expected {
+		t.Errorf("Usage was %+v, but expected %+v", u, expected)
+	}
+}
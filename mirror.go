@@ -0,0 +1,217 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultMirrorPending is the default limit on shadow operations a
+// MirrorAEAD runs at once.
+const DefaultMirrorPending = 64
+
+// MismatchInfo describes a difference between the primary and shadow results
+// of a MirrorAEAD operation. It holds no plaintext, so it is safe to log.
+type MismatchInfo struct {
+	// PrimaryErr and ShadowErr are the errors returned by Open. They are
+	// always nil for Seal.
+	PrimaryErr, ShadowErr error
+
+	// PrimaryLen and ShadowLen are the lengths of the outputs: ciphertexts
+	// for Seal, plaintexts for Open.
+	PrimaryLen, ShadowLen int
+
+	// Offset is the index of the first byte at which the outputs differ, or
+	// -1 if they differ only in length or errors.
+	Offset int
+}
+
+// A MirrorOption configures a MirrorAEAD.
+type MirrorOption func(*MirrorAEAD)
+
+// WithSampleRate mirrors only the given fraction of operations, chosen at
+// random. The default is 1, mirroring every operation.
+func WithSampleRate(rate float64) MirrorOption {
+	return func(m *MirrorAEAD) {
+		m.rate = rate
+	}
+}
+
+// WithMaxPending limits the number of shadow operations run at once. Sampled
+// operations beyond the limit are dropped rather than queued.
+func WithMaxPending(n int) MirrorOption {
+	return func(m *MirrorAEAD) {
+		m.pending = make(chan struct{}, n)
+	}
+}
+
+// MirrorAEAD is an AEAD which serves every operation from a primary AEAD and
+// repeats a sample of them on a shadow AEAD in the background, reporting any
+// difference. It supports migrating from one implementation to another. It is
+// safe for concurrent use if both AEADs are.
+type MirrorAEAD struct {
+	primary, shadow cipher.AEAD
+	onMismatch      func(op string, detail MismatchInfo)
+	rate            float64
+	pending         chan struct{}
+
+	wg                            sync.WaitGroup
+	compared, mismatched, dropped atomic.Uint64
+}
+
+// MirrorStats counts the operations a MirrorAEAD has repeated on its shadow.
+type MirrorStats struct {
+	// Compared is the number of operations repeated on the shadow.
+	Compared uint64
+
+	// Mismatched is the number of those whose results differed.
+	Mismatched uint64
+
+	// Dropped is the number of sampled operations skipped because too many
+	// were pending.
+	Dropped uint64
+}
+
+// NewMirror returns a MirrorAEAD which serves Seal and Open from primary and
+// repeats them on shadow, comparing the ciphertexts produced by Seal and the
+// plaintexts or errors returned by Open. onMismatch is called with the
+// operation ("seal" or "open") for each difference. The shadow operations and
+// onMismatch run on separate goroutines, after the primary's result has been
+// returned; only copying the inputs is added to the caller's latency.
+func NewMirror(primary, shadow cipher.AEAD, onMismatch func(op string, detail MismatchInfo), opts ...MirrorOption) *MirrorAEAD {
+	m := &MirrorAEAD{
+		primary:    primary,
+		shadow:     shadow,
+		onMismatch: onMismatch,
+		rate:       1,
+		pending:    make(chan struct{}, DefaultMirrorPending),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// NonceSize returns the nonce size of the primary AEAD.
+func (m *MirrorAEAD) NonceSize() int {
+	return m.primary.NonceSize()
+}
+
+// Overhead returns the overhead of the primary AEAD.
+func (m *MirrorAEAD) Overhead() int {
+	return m.primary.Overhead()
+}
+
+// Seal seals plaintext with the primary AEAD.
+func (m *MirrorAEAD) Seal(dst, nonce, plaintext, data []byte) []byte {
+	if !m.sample() {
+		return m.primary.Seal(dst, nonce, plaintext, data)
+	}
+
+	// The primary may encrypt in place, so copy the plaintext first.
+	p := clone(plaintext)
+	ret := m.primary.Seal(dst, nonce, plaintext, data)
+
+	nonce, data, expected := clone(nonce), clone(data), clone(ret[len(dst):])
+	m.run(func() {
+		actual := m.shadow.Seal(nil, nonce, p, data)
+		m.compare("seal", expected, actual, nil, nil)
+		wipe(p)
+	})
+	return ret
+}
+
+// Open opens ciphertext with the primary AEAD.
+func (m *MirrorAEAD) Open(dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	if !m.sample() {
+		return m.primary.Open(dst, nonce, ciphertext, data)
+	}
+
+	// The primary may decrypt in place, so copy the ciphertext first.
+	c := clone(ciphertext)
+	ret, err := m.primary.Open(dst, nonce, ciphertext, data)
+
+	var expected []byte
+	if err == nil {
+		expected = clone(ret[len(dst):])
+	}
+
+	nonce, data = clone(nonce), clone(data)
+	m.run(func() {
+		actual, serr := m.shadow.Open(nil, nonce, c, data)
+		m.compare("open", expected, actual, err, serr)
+		wipe(expected)
+		wipe(actual)
+	})
+	return ret, err
+}
+
+// Wait waits for all pending shadow operations to finish.
+func (m *MirrorAEAD) Wait() {
+	m.wg.Wait()
+}
+
+// Stats returns the number of operations mirrored so far.
+func (m *MirrorAEAD) Stats() MirrorStats {
+	return MirrorStats{
+		Compared:   m.compared.Load(),
+		Mismatched: m.mismatched.Load(),
+		Dropped:    m.dropped.Load(),
+	}
+}
+
+func (m *MirrorAEAD) sample() bool {
+	return m.rate >= 1 || (m.rate > 0 && rand.Float64() < m.rate)
+}
+
+// run runs f in the background, unless too many operations are already
+// pending.
+func (m *MirrorAEAD) run(f func()) {
+	select {
+	case m.pending <- struct{}{}:
+	default:
+		m.dropped.Add(1)
+		return
+	}
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer func() { <-m.pending }()
+		f()
+	}()
+}
+
+func (m *MirrorAEAD) compare(op string, expected, actual []byte, primaryErr, shadowErr error) {
+	m.compared.Add(1)
+
+	info := MismatchInfo{
+		PrimaryErr: primaryErr,
+		ShadowErr:  shadowErr,
+		PrimaryLen: len(expected),
+		ShadowLen:  len(actual),
+		Offset:     -1,
+	}
+	for i := 0; i < len(expected) && i < len(actual); i++ {
+		if expected[i] != actual[i] {
+			info.Offset = i
+			break
+		}
+	}
+
+	if (primaryErr == nil) != (shadowErr == nil) || !bytes.Equal(expected, actual) {
+		m.mismatched.Add(1)
+		if m.onMismatch != nil {
+			m.onMismatch(op, info)
+		}
+	}
+}
+
+func clone(b []byte) []byte {
+	if b == nil {
+		return nil
+	}
+	return append([]byte{}, b...)
+}
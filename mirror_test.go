@@ -0,0 +1,164 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"sync"
+	"testing"
+)
+
+type mismatch struct {
+	op   string
+	info MismatchInfo
+}
+
+type mismatchRecorder struct {
+	mu         sync.Mutex
+	mismatches []mismatch
+}
+
+func (r *mismatchRecorder) record(op string, info MismatchInfo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.mismatches = append(r.mismatches, mismatch{op, info})
+}
+
+// divergentAEAD flips the last byte of every Seal and accepts nothing.
+type divergentAEAD struct {
+	cipher.AEAD
+}
+
+func (d divergentAEAD) Seal(dst, nonce, plaintext, data []byte) []byte {
+	ret := d.AEAD.Seal(dst, nonce, plaintext, data)
+	ret[len(ret)-1] ^= 1
+	return ret
+}
+
+func (d divergentAEAD) Open(dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	return nil, ErrAuthentication
+}
+
+func TestMirrorMatching(t *testing.T) {
+	primary, _ := New(testKey(0, 32), aes.NewCipher)
+	shadow, _ := New(testKey(0, 32), aes.NewCipher)
+
+	r := &mismatchRecorder{}
+	m := NewMirror(primary, shadow, r.record)
+
+	plaintext := []byte("in place")
+	ciphertext := m.Seal(plaintext[:0], nil, plaintext, []byte("ad"))
+	if _, err := m.Open(ciphertext[:0], nil, ciphertext, []byte("ad")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Open(nil, nil, []byte("garbage garbage garbage"), nil); err != ErrAuthentication {
+		t.Fatalf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+	m.Wait()
+
+	if len(r.mismatches) != 0 {
+		t.Errorf("Mismatches were reported: %+v", r.mismatches)
+	}
+
+	if s := m.Stats(); s != (MirrorStats{Compared: 3}) {
+		t.Errorf("Stats were %+v, but expected %+v", s, MirrorStats{Compared: 3})
+	}
+}
+
+func TestMirrorDivergent(t *testing.T) {
+	primary, _ := New(testKey(0, 32), aes.NewCipher)
+
+	r := &mismatchRecorder{}
+	m := NewMirror(primary, divergentAEAD{primary}, r.record)
+
+	ciphertext := m.Seal(nil, nil, []byte("yay"), nil)
+	expected := primary.Seal(nil, nil, []byte("yay"), nil)
+	if !bytes.Equal(ciphertext, expected) {
+		t.Errorf("Ciphertext was %x, but expected %x", ciphertext, expected)
+	}
+
+	plaintext, err := m.Open(nil, nil, ciphertext, nil)
+	if err != nil || string(plaintext) != "yay" {
+		t.Fatalf("Open returned %q, %v", plaintext, err)
+	}
+	m.Wait()
+
+	if len(r.mismatches) != 2 {
+		t.Fatalf("%d mismatches were reported, but expected %d", len(r.mismatches), 2)
+	}
+
+	for _, v := range r.mismatches {
+		switch v.op {
+		case "seal":
+			e := MismatchInfo{PrimaryLen: 19, ShadowLen: 19, Offset: 18}
+			if v.info != e {
+				t.Errorf("Seal mismatch was %+v, but expected %+v", v.info, e)
+			}
+		case "open":
+			e := MismatchInfo{ShadowErr: ErrAuthentication, PrimaryLen: 3, Offset: -1}
+			if v.info != e {
+				t.Errorf("Open mismatch was %+v, but expected %+v", v.info, e)
+			}
+		default:
+			t.Errorf("Unexpected operation %q", v.op)
+		}
+	}
+
+	if s := m.Stats(); s != (MirrorStats{Compared: 2, Mismatched: 2}) {
+		t.Errorf("Stats were %+v", s)
+	}
+}
+
+func TestMirrorSampling(t *testing.T) {
+	primary, _ := New(testKey(0, 32), aes.NewCipher)
+
+	r := &mismatchRecorder{}
+	m := NewMirror(primary, divergentAEAD{primary}, r.record, WithSampleRate(0))
+	for i := 0; i < 100; i++ {
+		m.Seal(nil, nil, []byte("yay"), nil)
+	}
+	m.Wait()
+
+	if s := m.Stats(); s.Compared != 0 || len(r.mismatches) != 0 {
+		t.Errorf("Unsampled operations were mirrored: %+v", s)
+	}
+
+	m = NewMirror(primary, divergentAEAD{primary}, r.record, WithSampleRate(0.5))
+	for i := 0; i < 1000; i++ {
+		m.Seal(nil, nil, []byte("yay"), nil)
+	}
+	m.Wait()
+
+	if s := m.Stats(); s.Compared+s.Dropped < 350 || s.Compared+s.Dropped > 650 {
+		n := s.Compared + s.Dropped
+		t.Errorf("%d of 1000 operations were sampled, but expected about 500", n)
+	}
+}
+
+func TestMirrorDropped(t *testing.T) {
+	primary, _ := New(testKey(0, 32), aes.NewCipher)
+
+	// Block the shadow until the test has issued every operation.
+	release := make(chan struct{})
+	m := NewMirror(primary, blockingAEAD{primary, release}, nil, WithMaxPending(2))
+	for i := 0; i < 5; i++ {
+		m.Seal(nil, nil, []byte("yay"), nil)
+	}
+	close(release)
+	m.Wait()
+
+	if s := m.Stats(); s != (MirrorStats{Compared: 2, Dropped: 3}) {
+		t.Errorf("Stats were %+v", s)
+	}
+}
+
+type blockingAEAD struct {
+	cipher.AEAD
+	release chan struct{}
+}
+
+func (b blockingAEAD) Seal(dst, nonce, plaintext, data []byte) []byte {
+	<-b.release
+	return b.AEAD.Seal(dst, nonce, plaintext, data)
+}
@@ -0,0 +1,290 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"errors"
+	"fmt"
+)
+
+// BlockFactory returns a block cipher for the given key, as aes.NewCipher does.
+type BlockFactory func(key []byte) (cipher.Block, error)
+
+// ErrInvalidOption is returned by New when an option's value is invalid or
+// conflicts with another option.
+var ErrInvalidOption = errors.New("siv: invalid option")
+
+// ErrMissingAD is returned by Open, and panicked with by Seal, when an AEAD
+// created with WithRequiredAD is given no associated data.
+var ErrMissingAD = errors.New("siv: associated data required")
+
+// ErrNonZeroNonce is returned by Open, and panicked with by Seal, when an
+// AEAD created with WithZeroNonceCompat is given a nonce which isn't all zeros.
+var ErrNonZeroNonce = errors.New("siv: nonce must be all zeros")
+
+// An Option configures an AEAD returned by New.
+type Option func(*config) error
+
+// Observer is called after every Seal and Open of an AEAD created with
+// WithObserver, with the operation ("seal" or "open"), the length of the
+// plaintext, and the error from Open. It is called synchronously, so it should
+// return quickly.
+type Observer func(op string, n int, err error)
+
+type config struct {
+	nonceSize   int
+	nonceSet    bool
+	zeroNonce   bool
+	tagAppended bool
+	padding     int
+	requiredAD  bool
+	observer    Observer
+
+	allowEqualHalves bool
+}
+
+// plain reports whether c has no options which need a configured AEAD.
+func (c *config) plain() bool {
+	return !c.nonceSet && !c.zeroNonce && !c.tagAppended && c.padding == 0 &&
+		!c.requiredAD && c.observer == nil
+}
+
+// WithNonceSize makes the AEAD require nonces of exactly n bytes, panicking
+// with ErrNonceSize on any other length as the standard library's AEADs do, and report n from
+// NonceSize. WithNonceSize(0) requires an empty nonce. By default nonces of
+// any length are accepted and NonceSize is zero.
+func WithNonceSize(n int) Option {
+	return func(c *config) error {
+		if n < 0 {
+			return fmt.Errorf("%w: negative nonce size %d", ErrInvalidOption, n)
+		}
+		if c.nonceSet && c.nonceSize != n {
+			return fmt.Errorf("%w: conflicting nonce sizes %d and %d", ErrInvalidOption, c.nonceSize, n)
+		}
+		c.nonceSize, c.nonceSet = n, true
+		return nil
+	}
+}
+
+// WithZeroNonceCompat is for frameworks which insist on passing a nonce of
+// NonceSize bytes, or which reject a NonceSize of zero. The AEAD reports a
+// NonceSize of 16 and requires every nonce to be 16 zero bytes: as with
+// WithNonceSize, any other length panics with ErrNonceSize, and any other
+// value panics from Seal and fails to Open with ErrNonZeroNonce. The nonce is
+// otherwise ignored, so ciphertexts are identical to those sealed without a
+// nonce and without the option.
+func WithZeroNonceCompat() Option {
+	return func(c *config) error {
+		if err := WithNonceSize(blockSize)(c); err != nil {
+			return err
+		}
+		c.zeroNonce = true
+		return nil
+	}
+}
+
+// WithTagAppended places the SIV tag after the ciphertext rather than before
+// it, for protocols which expect the layout of AES-GCM. The full tag is always
+// kept, as SIV uses it as the CTR mode IV; truncated tags are not supported.
+func WithTagAppended() Option {
+	return func(c *config) error {
+		c.tagAppended = true
+		return nil
+	}
+}
+
+// WithPadding pads plaintexts to a multiple of n bytes before sealing, as in
+// ISO/IEC 7816-4 (a 0x80 byte followed by zeros), to hide their exact length.
+// Padding always adds at least one byte, so Overhead grows by n.
+func WithPadding(n int) Option {
+	return func(c *config) error {
+		if n <= 0 {
+			return fmt.Errorf("%w: padding block size %d", ErrInvalidOption, n)
+		}
+		if c.padding != 0 && c.padding != n {
+			return fmt.Errorf("%w: conflicting padding block sizes %d and %d", ErrInvalidOption, c.padding, n)
+		}
+		c.padding = n
+		return nil
+	}
+}
+
+// WithRequiredAD makes Seal panic with, and Open return, ErrMissingAD when the
+// associated data is empty, catching callers which forget to bind a context.
+func WithRequiredAD() Option {
+	return func(c *config) error {
+		c.requiredAD = true
+		return nil
+	}
+}
+
+// WithObserver registers fn to observe every Seal and Open. Only one observer
+// may be registered.
+func WithObserver(fn Observer) Option {
+	return func(c *config) error {
+		if fn == nil {
+			return fmt.Errorf("%w: nil observer", ErrInvalidOption)
+		}
+		if c.observer != nil {
+			return fmt.Errorf("%w: more than one observer", ErrInvalidOption)
+		}
+		c.observer = fn
+		return nil
+	}
+}
+
+// AllowEqualHalves lets New accept a key whose S2V and CTR halves are
+// identical, which it otherwise rejects with ErrEqualHalves. It is for test
+// fixtures and vectors built from repeated bytes; real keys should be random.
+// All-zero keys are rejected regardless. On its own, it doesn't change the
+// AEAD New returns, which is still a *SIV.
+func AllowEqualHalves() Option {
+	return func(c *config) error {
+		c.allowEqualHalves = true
+		return nil
+	}
+}
+
+// configured is a SIV AEAD with options applied. It supports only a single
+// associated data value, as the options change the ciphertext format.
+type configured struct {
+	s *SIV
+	config
+}
+
+func (c *configured) KeySize() int {
+	return c.s.KeySize()
+}
+
+func (c *configured) BlockSize() int {
+	return c.s.BlockSize()
+}
+
+func (c *configured) Algorithm() string {
+	return c.s.Algorithm()
+}
+
+func (c *configured) NonceSize() int {
+	return c.nonceSize
+}
+
+func (c *configured) Overhead() int {
+	return c.s.Overhead() + c.padding
+}
+
+func (c *configured) Seal(dst, nonce, plaintext, data []byte) []byte {
+	ret, err := c.SealAppend(dst, nonce, plaintext, data)
+	if err != nil {
+		panic(err)
+	}
+	return ret
+}
+
+func (c *configured) Open(dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	return panicOnMisuse(c.OpenAppend(dst, nonce, ciphertext, data))
+}
+
+func (c *configured) SealAppend(dst, nonce, plaintext, data []byte) ([]byte, error) {
+	ret, err := c.seal(dst, nonce, plaintext, data)
+	if c.observer != nil {
+		c.observer("seal", len(plaintext), err)
+	}
+	return ret, err
+}
+
+func (c *configured) OpenAppend(dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	ret, err := c.open(dst, nonce, ciphertext, data)
+	if c.observer != nil {
+		n := 0
+		if err == nil {
+			n = len(ret) - len(dst)
+		}
+		c.observer("open", n, err)
+	}
+	return ret, err
+}
+
+func (c *configured) seal(dst, nonce, plaintext, data []byte) ([]byte, error) {
+	nonce, err := c.check(nonce)
+	if err != nil {
+		return nil, err
+	}
+	if c.requiredAD && len(data) == 0 {
+		return nil, ErrMissingAD
+	}
+
+	if n := len(plaintext); c.padding > 0 {
+		if n > MaxPlaintextSize-c.padding {
+			return nil, ErrPlaintextTooLarge
+		}
+		padded := make([]byte, n+c.padding-n%c.padding)
+		defer wipe(padded)
+		copy(padded, plaintext)
+		padded[n] = 0x80
+		plaintext = padded
+	}
+
+	ret, err := c.s.seal(dst, plaintext, data, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.tagAppended {
+		out := ret[len(dst):]
+		var tag [blockSize]byte
+		copy(tag[:], out)
+		copy(out, out[blockSize:])
+		copy(out[len(out)-blockSize:], tag[:])
+	}
+	return ret, nil
+}
+
+func (c *configured) open(dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	nonce, err := c.check(nonce)
+	if err != nil {
+		return nil, err
+	}
+	if c.requiredAD && len(data) == 0 {
+		return nil, ErrMissingAD
+	}
+
+	if c.tagAppended && len(ciphertext) >= blockSize {
+		rotated := make([]byte, len(ciphertext))
+		copy(rotated, ciphertext[len(ciphertext)-blockSize:])
+		copy(rotated[blockSize:], ciphertext)
+		ciphertext = rotated
+	}
+
+	ret, err := c.s.open(dst, ciphertext, data, nonce)
+	if err != nil || c.padding == 0 {
+		return ret, err
+	}
+
+	// The padding is authenticated, so a malformed padding means the
+	// ciphertext was sealed without it.
+	out := ret[len(dst):]
+	i := len(out) - 1
+	for i >= 0 && out[i] == 0 {
+		i--
+	}
+	if i < 0 || out[i] != 0x80 || len(out)%c.padding != 0 {
+		wipe(out)
+		return nil, ErrAuthentication
+	}
+	return ret[:len(dst)+i], nil
+}
+
+// check checks the nonce, and returns the nonce to pass to S2V.
+func (c *configured) check(nonce []byte) ([]byte, error) {
+	if c.nonceSet && len(nonce) != c.nonceSize {
+		return nil, ErrNonceSize
+	}
+	if c.zeroNonce {
+		for _, b := range nonce {
+			if b != 0 {
+				return nil, ErrNonZeroNonce
+			}
+		}
+		return nil, nil
+	}
+	return nonce, nil
+}
@@ -0,0 +1,270 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"testing"
+)
+
+func mustPanic(t *testing.T, f func()) (r any) {
+	t.Helper()
+	defer func() {
+		if r = recover(); r == nil {
+			t.Error("Did not panic")
+		}
+	}()
+	f()
+	return nil
+}
+
+func TestNewWithoutOptions(t *testing.T) {
+	var alg func([]byte) (cipher.Block, error) = aes.NewCipher
+
+	aead, err := New(testKey(0, 32), alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := aead.(multiAEAD); !ok {
+		t.Errorf("AEAD %T does not support multiple associated data components", aead)
+	}
+}
+
+func TestWithNonceSize(t *testing.T) {
+	key := testKey(0, 32)
+	plain, _ := New(key, aes.NewCipher)
+	aead, err := New(key, aes.NewCipher, WithNonceSize(12))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := aead.NonceSize(); v != 12 {
+		t.Errorf("Nonce size was %d, but expected %d", v, 12)
+	}
+
+	nonce := make([]byte, 12)
+	ciphertext := aead.Seal(nil, nonce, []byte("yay"), []byte("ad"))
+	if expected := plain.Seal(nil, nonce, []byte("yay"), []byte("ad")); !bytes.Equal(ciphertext, expected) {
+		t.Errorf("Ciphertext was %x, but expected %x", ciphertext, expected)
+	}
+
+	mustPanic(t, func() { aead.Seal(nil, nil, []byte("yay"), nil) })
+	mustPanic(t, func() { _, _ = aead.Open(nil, make([]byte, 16), ciphertext, nil) })
+
+	if _, err := New(key, aes.NewCipher, WithNonceSize(-1)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Error was %v, but expected %v", err, ErrInvalidOption)
+	}
+
+	empty, err := New(key, aes.NewCipher, WithNonceSize(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext = empty.Seal(nil, nil, []byte("yay"), nil)
+	if expected := plain.Seal(nil, nil, []byte("yay"), nil); !bytes.Equal(ciphertext, expected) {
+		t.Errorf("Ciphertext was %x, but expected %x", ciphertext, expected)
+	}
+	mustPanic(t, func() { empty.Seal(nil, nonce, []byte("yay"), nil) })
+	mustPanic(t, func() { _, _ = empty.Open(nil, nonce, ciphertext, nil) })
+}
+
+func TestWithZeroNonceCompat(t *testing.T) {
+	key := testKey(0, 32)
+	plain, _ := New(key, aes.NewCipher)
+	aead, err := New(key, aes.NewCipher, WithZeroNonceCompat())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if v := aead.NonceSize(); v != 16 {
+		t.Errorf("Nonce size was %d, but expected %d", v, 16)
+	}
+
+	// A zero nonce is ignored, as frameworks would pass it.
+	nonce := make([]byte, aead.NonceSize())
+	ciphertext := aead.Seal(nil, nonce, []byte("yay"), []byte("ad"))
+	if expected := plain.Seal(nil, nil, []byte("yay"), []byte("ad")); !bytes.Equal(ciphertext, expected) {
+		t.Errorf("Ciphertext was %x, but expected %x", ciphertext, expected)
+	}
+
+	if plaintext, err := aead.Open(nil, nonce, ciphertext, []byte("ad")); err != nil || string(plaintext) != "yay" {
+		t.Errorf("Plaintext was %q, %v, but expected %q", plaintext, err, "yay")
+	}
+
+	// Any other nonce is rejected.
+	for i := range nonce {
+		other := make([]byte, 16)
+		other[i] = 1
+		if r := mustPanic(t, func() { aead.Seal(nil, other, []byte("yay"), []byte("ad")) }); r != ErrNonZeroNonce {
+			t.Errorf("Panicked with %v, but expected %v", r, ErrNonZeroNonce)
+		}
+		if plaintext, err := aead.Open(nil, other, ciphertext, []byte("ad")); err != ErrNonZeroNonce {
+			t.Fatalf("Plaintext returned instead of error: %q", plaintext)
+		}
+	}
+
+	for _, n := range []int{0, 12, 15, 17, 32} {
+		if r := mustPanic(t, func() { aead.Seal(nil, make([]byte, n), []byte("yay"), nil) }); r != ErrNonceSize {
+			t.Errorf("%d: Panicked with %v, but expected %v", n, r, ErrNonceSize)
+		}
+		if r := mustPanic(t, func() { _, _ = aead.Open(nil, make([]byte, n), ciphertext, nil) }); r != ErrNonceSize {
+			t.Errorf("%d: Panicked with %v, but expected %v", n, r, ErrNonceSize)
+		}
+	}
+
+	if _, err := New(key, aes.NewCipher, WithZeroNonceCompat(), WithNonceSize(12)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Error was %v, but expected %v", err, ErrInvalidOption)
+	}
+}
+
+func TestWithTagAppended(t *testing.T) {
+	key := testKey(0, 32)
+	plain, _ := New(key, aes.NewCipher)
+	aead, _ := New(key, aes.NewCipher, WithTagAppended())
+
+	prefix := []byte("prefix")
+	ciphertext := aead.Seal(prefix, nil, []byte("hello, world"), []byte("ad"))
+	expected := plain.Seal(nil, nil, []byte("hello, world"), []byte("ad"))
+
+	if !bytes.Equal(ciphertext[:6], prefix) ||
+		!bytes.Equal(ciphertext[6:18], expected[16:]) ||
+		!bytes.Equal(ciphertext[18:], expected[:16]) {
+		t.Errorf("Ciphertext was %x, but expected %x", ciphertext, expected)
+	}
+
+	actual, err := aead.Open(ciphertext[6:6], nil, ciphertext[6:], []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(actual) != "hello, world" {
+		t.Errorf("Plaintext was %q, but expected %q", actual, "hello, world")
+	}
+
+	if actual, err := aead.Open(nil, nil, expected, []byte("ad")); err == nil {
+		t.Fatalf("Plaintext returned instead of error: %x", actual)
+	}
+}
+
+func TestWithPadding(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher, WithPadding(8))
+
+	if v := aead.Overhead(); v != 24 {
+		t.Errorf("Overhead was %d, but expected %d", v, 24)
+	}
+
+	for n := 0; n < 20; n++ {
+		plaintext := bytes.Repeat([]byte{0x80}, n)
+		ciphertext := aead.Seal(nil, nil, plaintext, nil)
+
+		if expected := 16 + (n/8+1)*8; len(ciphertext) != expected {
+			t.Errorf("Ciphertext length for %d bytes was %d, but expected %d", n, len(ciphertext), expected)
+		}
+
+		actual, err := aead.Open(nil, nil, ciphertext, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(actual, plaintext) {
+			t.Errorf("Plaintext was %x, but expected %x", actual, plaintext)
+		}
+	}
+
+	// An authentic ciphertext without padding is rejected.
+	plain, _ := New(testKey(0, 32), aes.NewCipher)
+	for _, v := range [][]byte{nil, make([]byte, 8), append(make([]byte, 7), 0x80, 0)} {
+		if actual, err := aead.Open(nil, nil, plain.Seal(nil, nil, v, nil), nil); err != ErrAuthentication {
+			t.Errorf("Error was %v, but expected %v (plaintext %x)", err, ErrAuthentication, actual)
+		}
+	}
+}
+
+func TestWithRequiredAD(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher, WithRequiredAD())
+
+	if r := mustPanic(t, func() { aead.Seal(nil, nil, []byte("yay"), nil) }); r != nil && r != ErrMissingAD {
+		t.Errorf("Panic was %v, but expected %v", r, ErrMissingAD)
+	}
+
+	ciphertext := aead.Seal(nil, nil, []byte("yay"), []byte("ad"))
+	if _, err := aead.Open(nil, nil, ciphertext, []byte("ad")); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := aead.Open(nil, nil, ciphertext, []byte{}); err != ErrMissingAD {
+		t.Errorf("Error was %v, but expected %v", err, ErrMissingAD)
+	}
+}
+
+func TestWithObserver(t *testing.T) {
+	var events []string
+	var sizes []int
+	var errs []error
+	aead, _ := New(testKey(0, 32), aes.NewCipher, WithObserver(func(op string, n int, err error) {
+		events = append(events, op)
+		sizes = append(sizes, n)
+		errs = append(errs, err)
+	}))
+
+	ciphertext := aead.Seal(nil, nil, []byte("yay"), nil)
+	_, _ = aead.Open([]byte("dst"), nil, ciphertext, nil)
+	_, _ = aead.Open(nil, nil, ciphertext, []byte("wrong"))
+
+	if len(events) != 3 || events[0] != "seal" || events[1] != "open" || events[2] != "open" {
+		t.Fatalf("Events were %v", events)
+	}
+
+	if sizes[0] != 3 || sizes[1] != 3 || errs[1] != nil || errs[2] != ErrAuthentication {
+		t.Errorf("Sizes were %v and errors %v", sizes, errs)
+	}
+
+	if _, err := New(testKey(0, 32), aes.NewCipher, WithObserver(nil)); !errors.Is(err, ErrInvalidOption) {
+		t.Errorf("Error was %v, but expected %v", err, ErrInvalidOption)
+	}
+}
+
+func TestOptionConflicts(t *testing.T) {
+	observer := func(string, int, error) {}
+
+	for _, opts := range [][]Option{
+		{WithNonceSize(12), WithNonceSize(16)},
+		{WithPadding(8), WithPadding(16)},
+		{WithPadding(0)},
+		{WithObserver(observer), WithObserver(observer)},
+		{WithTagAppended(), WithPadding(-1)},
+	} {
+		aead, err := New(testKey(0, 32), aes.NewCipher, opts...)
+		if !errors.Is(err, ErrInvalidOption) {
+			t.Errorf("Error was %v, but expected %v (AEAD %v)", err, ErrInvalidOption, aead)
+		}
+	}
+
+	// Repeating an option with the same value is not a conflict.
+	if _, err := New(testKey(0, 32), aes.NewCipher, WithPadding(8), WithPadding(8), WithNonceSize(0), WithNonceSize(0)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCombinedOptions(t *testing.T) {
+	aead, _ := New(testKey(0, 64), aes.NewCipher,
+		WithNonceSize(12), WithTagAppended(), WithPadding(16), WithRequiredAD())
+
+	if info := aead.(Info); info.Algorithm() != "AES-SIV-CMAC-512" {
+		t.Errorf("Algorithm was %s, but expected %s", info.Algorithm(), "AES-SIV-CMAC-512")
+	}
+
+	nonce := make([]byte, 12)
+	buf := []byte("in place")
+	ciphertext := aead.Seal(buf[:0], nonce, buf, []byte("ad"))
+
+	actual, err := aead.Open(ciphertext[:0], nonce, ciphertext, []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(actual) != "in place" {
+		t.Errorf("Plaintext was %q, but expected %q", actual, "in place")
+	}
+}
@@ -0,0 +1,39 @@
+//go:build js || wasip1 || tinygo
+
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"runtime"
+	"testing"
+)
+
+// TestPortableSmoke runs the RFC 5297 A.1 vector on the restricted targets
+// (js/wasm, wasip1, and TinyGo), so a failure there is reported on its own.
+func TestPortableSmoke(t *testing.T) {
+	key, _ := hex.DecodeString("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	ad, _ := hex.DecodeString("101112131415161718191a1b1c1d1e1f2021222324252627")
+	plaintext, _ := hex.DecodeString("112233445566778899aabbccddee")
+	expected, _ := hex.DecodeString("85632d07c6e8f37f950acd320a2ecc9340c02b9690c4dc04daef7f6afe5c")
+
+	aead, err := New(key, aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := aead.Seal(nil, nil, plaintext, ad)
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("Ciphertext on %s/%s was %x, but expected %x", runtime.GOOS, runtime.GOARCH, actual, expected)
+	}
+
+	opened, err := aead.Open(nil, nil, expected, ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(opened, plaintext) {
+		t.Errorf("Plaintext was %x, but expected %x", opened, plaintext)
+	}
+}
@@ -0,0 +1,167 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrPurpose is wrapped by PurposeError.
+var ErrPurpose = errors.New("siv: key not allowed for purpose")
+
+// PurposeError is returned when a key is used, through ForPurpose, for a
+// purpose its policy doesn't allow.
+type PurposeError struct {
+	Purpose string
+	KeyID   string
+}
+
+func (e *PurposeError) Error() string {
+	return fmt.Sprintf("siv: key %q is not allowed for purpose %q", e.KeyID, e.Purpose)
+}
+
+// Unwrap returns ErrPurpose.
+func (e *PurposeError) Unwrap() error {
+	return ErrPurpose
+}
+
+// SetPurposes restricts the key with the given ID to the given purposes, when
+// used through ForPurpose. A purpose ending in "*" allows every purpose with
+// the preceding prefix, so "*" allows every purpose. With no purposes, the
+// key is unrestricted, as keys are when added.
+func (k *Keyring) SetPurposes(id string, purposes ...string) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	i := k.index(id)
+	if i < 0 {
+		return ErrUnknownKeyID
+	}
+
+	k.keys[i].purposes = nil
+	if len(purposes) > 0 {
+		k.keys[i].purposes = append([]string{}, purposes...)
+	}
+	return nil
+}
+
+// Purposes returns the purposes the key with the given ID is restricted to,
+// or nil if it is unrestricted.
+func (k *Keyring) Purposes(id string) ([]string, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	i := k.index(id)
+	if i < 0 {
+		return nil, ErrUnknownKeyID
+	}
+	return append([]string(nil), k.keys[i].purposes...), nil
+}
+
+// CheckPurpose returns a PurposeError if the primary key isn't allowed for
+// purpose, so that callers can check before sealing.
+func (k *Keyring) CheckPurpose(purpose string) error {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if k.primary < 0 {
+		return nil
+	}
+	if e := k.keys[k.primary]; !e.allows(purpose) {
+		return &PurposeError{Purpose: purpose, KeyID: e.id}
+	}
+	return nil
+}
+
+// ForPurpose returns a view of the keyring which uses only keys allowed for
+// purpose, and binds purpose as an associated data component ahead of any
+// per-call associated data, so that data sealed for one purpose doesn't open
+// for another even under an unrestricted key.
+//
+// Seal panics with a *PurposeError if the primary key isn't allowed for
+// purpose; use CheckPurpose to check first. Open tries only the allowed keys
+// which aren't revoked, and if none of them authenticates the ciphertext but
+// a disallowed key does, returns a PurposeError naming it.
+func (k *Keyring) ForPurpose(purpose string) cipher.AEAD {
+	return &purposeAEAD{k: k, purpose: purpose}
+}
+
+func (e *keyringEntry) allows(purpose string) bool {
+	if e.purposes == nil {
+		return true
+	}
+	for _, p := range e.purposes {
+		if p == purpose {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(p, "*"); ok && strings.HasPrefix(purpose, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+type purposeAEAD struct {
+	k       *Keyring
+	purpose string
+}
+
+func (p *purposeAEAD) NonceSize() int {
+	return p.k.NonceSize()
+}
+
+func (p *purposeAEAD) Overhead() int {
+	return p.k.Overhead()
+}
+
+func (p *purposeAEAD) bind(aead cipher.AEAD) cipher.AEAD {
+	return NewContext(aead, []byte("siv purpose"), []byte(p.purpose))
+}
+
+func (p *purposeAEAD) Seal(dst, nonce, plaintext, data []byte) []byte {
+	p.k.mu.RLock()
+	if p.k.primary < 0 {
+		p.k.mu.RUnlock()
+		panic("siv: keyring is empty")
+	}
+	e := p.k.keys[p.k.primary]
+	p.k.mu.RUnlock()
+
+	if !e.allows(p.purpose) {
+		panic(&PurposeError{Purpose: p.purpose, KeyID: e.id})
+	}
+	return p.bind(e.aead).Seal(dst, nonce, plaintext, data)
+}
+
+func (p *purposeAEAD) Open(dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	p.k.mu.RLock()
+	keys, revoked := p.k.ordered()
+	p.k.mu.RUnlock()
+
+	var denied []keyringEntry
+	for _, e := range keys {
+		if !e.allows(p.purpose) {
+			denied = append(denied, e)
+			continue
+		}
+		if plaintext, err := trialOpen(p.bind(e.aead), dst, nonce, ciphertext, data); err == nil {
+			return plaintext, nil
+		}
+	}
+
+	for _, e := range denied {
+		if plaintext, err := trialOpen(p.bind(e.aead), dst, nonce, ciphertext, data); err == nil {
+			wipe(plaintext[len(dst):])
+			return nil, &PurposeError{Purpose: p.purpose, KeyID: e.id}
+		}
+	}
+
+	for _, e := range revoked {
+		if plaintext, err := trialOpen(p.bind(e.aead), dst, nonce, ciphertext, data); err == nil {
+			wipe(plaintext[len(dst):])
+			return nil, &RevokedKeyError{ID: e.id, Fingerprint: fingerprintOf(e.aead)}
+		}
+	}
+	return nil, ErrAuthentication
+}
@@ -0,0 +1,109 @@
+package siv
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestForPurpose(t *testing.T) {
+	k, aeads := testKeyring(t, "cookies", "db", "shared")
+	if err := k.SetPurposes("cookies", "cookie"); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.SetPurposes("db", "db.*"); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.SetPurposes("shared", "*"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Allowed.
+	cookies := k.ForPurpose("cookie")
+	ciphertext := cookies.Seal(nil, nil, []byte("session"), []byte("ad"))
+	expected := NewContext(aeads["cookies"], []byte("siv purpose"), []byte("cookie")).Seal(nil, nil, []byte("session"), []byte("ad"))
+	if !bytes.Equal(ciphertext, expected) {
+		t.Errorf("Ciphertext was %x, but expected %x", ciphertext, expected)
+	}
+	if p, err := cookies.Open(nil, nil, ciphertext, []byte("ad")); err != nil || string(p) != "session" {
+		t.Errorf("Plaintext was %q, error %v, but expected %q", p, err, "session")
+	}
+
+	// The purpose is bound, so data doesn't open for another purpose or
+	// without one.
+	if p, err := k.Open(nil, nil, ciphertext, []byte("ad")); err != ErrAuthentication {
+		t.Fatalf("Plaintext returned instead of error: %x", p)
+	}
+
+	// Denied, on Seal.
+	if err := k.CheckPurpose("db.users"); !isPurposeError(err, "db.users", "cookies") {
+		t.Errorf("Error was %v, but expected a PurposeError", err)
+	}
+	func() {
+		defer func() {
+			if err, _ := recover().(error); !isPurposeError(err, "db.users", "cookies") {
+				t.Errorf("Panic was %v, but expected a PurposeError", err)
+			}
+		}()
+		k.ForPurpose("db.users").Seal(nil, nil, []byte("row"), nil)
+	}()
+
+	// Denied, on Open: the cookies key opens data sealed for db.users before
+	// its policy was set, but won't any more.
+	sealed := NewContext(aeads["cookies"], []byte("siv purpose"), []byte("db.users")).Seal(nil, nil, []byte("row"), nil)
+	if p, err := k.ForPurpose("db.users").Open(nil, nil, sealed, nil); !isPurposeError(err, "db.users", "cookies") {
+		t.Fatalf("Plaintext returned instead of error: %x", p)
+	}
+	inPlace := append([]byte{}, sealed...)
+	if p, err := k.ForPurpose("db.users").Open(inPlace[:0], nil, inPlace, nil); !isPurposeError(err, "db.users", "cookies") {
+		t.Fatalf("Plaintext returned instead of error: %x", p)
+	}
+
+	// In place, with a key other than the primary.
+	inPlace = NewContext(aeads["shared"], []byte("siv purpose"), []byte("cookie")).Seal(nil, nil, []byte("session"), nil)
+	if p, err := cookies.Open(inPlace[:0], nil, inPlace, nil); err != nil || string(p) != "session" {
+		t.Errorf("Plaintext was %q, error %v, but expected %q", p, err, "session")
+	}
+
+	// Wildcards.
+	if err := k.SetPrimary("db"); err != nil {
+		t.Fatal(err)
+	}
+	for purpose, allowed := range map[string]bool{"db.users": true, "db.": true, "db": false, "cookie": false} {
+		if err := k.CheckPurpose(purpose); (err == nil) != allowed {
+			t.Errorf("%s: error was %v, but expected allowed %v", purpose, err, allowed)
+		}
+	}
+
+	if err := k.SetPrimary("shared"); err != nil {
+		t.Fatal(err)
+	}
+	for _, purpose := range []string{"db.users", "cookie", ""} {
+		view := k.ForPurpose(purpose)
+		ciphertext := view.Seal(nil, nil, []byte(purpose), nil)
+		if p, err := view.Open(nil, nil, ciphertext, nil); err != nil || string(p) != purpose {
+			t.Errorf("Plaintext was %q, error %v, but expected %q", p, err, purpose)
+		}
+	}
+
+	// Clearing the policy leaves the key unrestricted.
+	if err := k.SetPurposes("cookies"); err != nil {
+		t.Fatal(err)
+	}
+	if p, err := k.ForPurpose("db.users").Open(nil, nil, sealed, nil); err != nil || string(p) != "row" {
+		t.Errorf("Plaintext was %q, error %v, but expected %q", p, err, "row")
+	}
+
+	if purposes, _ := k.Purposes("db"); !reflect.DeepEqual(purposes, []string{"db.*"}) {
+		t.Errorf("Purposes were %v, but expected %v", purposes, []string{"db.*"})
+	}
+	if err := k.SetPurposes("z", "x"); err != ErrUnknownKeyID {
+		t.Errorf("Error was %v, but expected %v", err, ErrUnknownKeyID)
+	}
+}
+
+func isPurposeError(err error, purpose, keyID string) bool {
+	var pe *PurposeError
+	return errors.As(err, &pe) && errors.Is(err, ErrPurpose) && pe.Purpose == purpose && pe.KeyID == keyID
+}
@@ -0,0 +1,96 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"sync"
+)
+
+// RecordSealer seals a sequence of records, binding each to its position by
+// folding a 64-bit sequence number into its associated data. Records must be
+// opened in the same order by a RecordOpener, so dropped, repeated, or
+// reordered records fail to authenticate. A RecordSealer is safe for
+// concurrent use, though records sealed concurrently are numbered in an
+// unspecified order.
+type RecordSealer struct {
+	aead cipher.AEAD
+
+	mu   sync.Mutex
+	next uint64
+}
+
+// NewRecordSealer returns a RecordSealer whose first record will have the
+// sequence number next. To resume a log, pass the Next value saved from the
+// previous RecordSealer.
+func NewRecordSealer(aead cipher.AEAD, next uint64) *RecordSealer {
+	return &RecordSealer{aead: aead, next: next}
+}
+
+// Next returns the sequence number the next record will be sealed with, which
+// is also the number of records sealed if the sealer started at zero.
+func (s *RecordSealer) Next() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.next
+}
+
+// Seal seals plaintext as the next record. It panics if the sequence number
+// would wrap.
+func (s *RecordSealer) Seal(plaintext, ad []byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.next == ^uint64(0) {
+		panic("siv: record sequence number overflow")
+	}
+
+	ciphertext := recordAEAD(s.aead, s.next).Seal(nil, nil, plaintext, ad)
+	s.next++
+	return ciphertext
+}
+
+// RecordOpener opens records sealed by a RecordSealer, in order. A
+// RecordOpener is safe for concurrent use.
+type RecordOpener struct {
+	aead cipher.AEAD
+
+	mu   sync.Mutex
+	next uint64
+}
+
+// NewRecordOpener returns a RecordOpener which expects the next record to
+// have the sequence number next: zero for a new log, or the number of records
+// already read to resume one.
+func NewRecordOpener(aead cipher.AEAD, next uint64) *RecordOpener {
+	return &RecordOpener{aead: aead, next: next}
+}
+
+// Next returns the sequence number the next record is expected to have.
+func (o *RecordOpener) Next() uint64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.next
+}
+
+// Open opens ciphertext as the next record. If the record is not the one
+// expected, because a record was dropped, repeated, or reordered, it returns
+// ErrAuthentication and the expected sequence number is unchanged.
+func (o *RecordOpener) Open(ciphertext, ad []byte) ([]byte, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	plaintext, err := recordAEAD(o.aead, o.next).Open(nil, nil, ciphertext, ad)
+	if err != nil {
+		return nil, err
+	}
+	o.next++
+	return plaintext, nil
+}
+
+func recordAEAD(aead cipher.AEAD, seq uint64) cipher.AEAD {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], seq)
+	return NewContext(aead, []byte("siv record"), b[:])
+}
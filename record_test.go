@@ -0,0 +1,116 @@
+package siv
+
+import (
+	"crypto/aes"
+	"fmt"
+	"testing"
+)
+
+func sealRecords(n int) [][]byte {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	s := NewRecordSealer(aead, 0)
+
+	var records [][]byte
+	for i := 0; i < n; i++ {
+		records = append(records, s.Seal([]byte(fmt.Sprint("record ", i)), []byte("log")))
+	}
+	return records
+}
+
+func TestRecords(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	o := NewRecordOpener(aead, 0)
+
+	for i, r := range sealRecords(5) {
+		p, err := o.Open(r, []byte("log"))
+		if err != nil {
+			t.Fatalf("Record %d: %v", i, err)
+		}
+
+		if expected := fmt.Sprint("record ", i); string(p) != expected {
+			t.Errorf("Record %d was %q, but expected %q", i, p, expected)
+		}
+	}
+
+	if n := o.Next(); n != 5 {
+		t.Errorf("Next was %d, but expected 5", n)
+	}
+}
+
+func TestRecordsDropped(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	o := NewRecordOpener(aead, 0)
+	records := sealRecords(3)
+
+	if _, err := o.Open(records[0], []byte("log")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := o.Open(records[2], []byte("log")); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+
+	// The failure doesn't advance the opener, so the real next record opens.
+	if _, err := o.Open(records[1], []byte("log")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRecordsRepeated(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	o := NewRecordOpener(aead, 0)
+	records := sealRecords(2)
+
+	if _, err := o.Open(records[0], []byte("log")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := o.Open(records[0], []byte("log")); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+}
+
+func TestRecordsReordered(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	o := NewRecordOpener(aead, 0)
+	records := sealRecords(2)
+
+	if _, err := o.Open(records[1], []byte("log")); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+}
+
+func TestRecordsResume(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	records := sealRecords(5)
+
+	// A sealer resumed from the saved counter produces the same records.
+	s := NewRecordSealer(aead, 3)
+	if r := s.Seal([]byte("record 3"), []byte("log")); string(r) != string(records[3]) {
+		t.Errorf("Resumed record was %x, but expected %x", r, records[3])
+	}
+
+	if n := s.Next(); n != 4 {
+		t.Errorf("Next was %d, but expected 4", n)
+	}
+
+	o := NewRecordOpener(aead, 3)
+	if p, err := o.Open(records[3], []byte("log")); err != nil || string(p) != "record 3" {
+		t.Errorf("Opened %q (error %v)", p, err)
+	}
+
+	// Resuming from the wrong offset fails.
+	o = NewRecordOpener(aead, 2)
+	if _, err := o.Open(records[3], []byte("log")); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+}
+
+func TestRecordsNotPlainSeal(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	o := NewRecordOpener(aead, 0)
+
+	if _, err := o.Open(aead.Seal(nil, nil, []byte("record 0"), []byte("log")), []byte("log")); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+}
@@ -0,0 +1,169 @@
+package siv
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FetchFunc fetches the current key from a remote source, along with an ID
+// which changes whenever the key does. The key must be an AES-SIV key, as
+// accepted by New with aes.NewCipher.
+type FetchFunc func(ctx context.Context) (keyID string, key []byte, err error)
+
+// RefreshingAEAD is an AEAD whose key is fetched from a remote source and
+// refreshed periodically. Seal uses the current key. Open tries the current
+// key and, for a grace period after the key changes, the previous one, so that
+// data sealed just before a rotation can still be opened.
+//
+// Keys are refreshed lazily: the first Seal or Open after the TTL has expired
+// fetches the key, while any concurrent calls continue with the current key.
+// As Seal and Open take no context, the fetch is made with
+// context.Background, and the FetchFunc should impose its own timeout. If a
+// fetch fails, the current key continues to be used, the failure is reported
+// by Health, and the fetch is retried after another TTL.
+//
+// A RefreshingAEAD is safe for concurrent use.
+type RefreshingAEAD struct {
+	fetch      FetchFunc
+	ttl, grace time.Duration
+	now        func() time.Time
+
+	refreshing atomic.Bool
+
+	mu            sync.RWMutex
+	current       refreshingKey
+	previous      refreshingKey
+	previousUntil time.Time
+	nextRefresh   time.Time
+	err           error
+}
+
+type refreshingKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// NewRefreshingAEAD fetches the initial key and returns a RefreshingAEAD which
+// refreshes it every ttl, and keeps the previous key for opening for grace
+// after it changes.
+func NewRefreshingAEAD(ctx context.Context, fetch FetchFunc, ttl, grace time.Duration) (*RefreshingAEAD, error) {
+	r := &RefreshingAEAD{fetch: fetch, ttl: ttl, grace: grace, now: time.Now}
+	if err := r.Refresh(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Refresh fetches the key now. If it has changed, it becomes the current key
+// and the old one is kept for opening for the grace period. If the fetch
+// fails, the current key is kept and the error is returned and reported by
+// Health until a fetch succeeds.
+func (r *RefreshingAEAD) Refresh(ctx context.Context) error {
+	id, key, err := r.fetch(ctx)
+
+	var aead cipher.AEAD
+	if err == nil {
+		r.mu.RLock()
+		unchanged := r.current.aead != nil && id == r.current.id
+		r.mu.RUnlock()
+
+		if !unchanged {
+			aead, err = New(key, aes.NewCipher)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := r.now()
+	r.nextRefresh = now.Add(r.ttl)
+	r.err = err
+	if err != nil {
+		return err
+	}
+
+	// Another refresh may have installed the same key in the meantime.
+	if aead != nil && id != r.current.id {
+		r.previous, r.previousUntil = r.current, now.Add(r.grace)
+		r.current = refreshingKey{id: id, aead: aead}
+	}
+	return nil
+}
+
+// KeyID returns the ID of the current key.
+func (r *RefreshingAEAD) KeyID() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.current.id
+}
+
+// Health returns the error from the most recent fetch, or nil if it
+// succeeded.
+func (r *RefreshingAEAD) Health() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.err
+}
+
+// NonceSize returns the nonce size of the current key.
+func (r *RefreshingAEAD) NonceSize() int {
+	return r.keys()[0].NonceSize()
+}
+
+// Overhead returns the overhead of the current key.
+func (r *RefreshingAEAD) Overhead() int {
+	return r.keys()[0].Overhead()
+}
+
+// Seal seals plaintext with the current key, refreshing it first if it is
+// due.
+func (r *RefreshingAEAD) Seal(dst, nonce, plaintext, data []byte) []byte {
+	r.maybeRefresh()
+	return r.keys()[0].Seal(dst, nonce, plaintext, data)
+}
+
+// Open opens ciphertext with the current key or, during the grace period, the
+// previous key, refreshing the key first if it is due.
+func (r *RefreshingAEAD) Open(dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	r.maybeRefresh()
+	for _, aead := range r.keys() {
+		if plaintext, err := trialOpen(aead, dst, nonce, ciphertext, data); err == nil {
+			return plaintext, nil
+		}
+	}
+	return nil, ErrAuthentication
+}
+
+// keys returns the current key, followed by the previous key if it is within
+// its grace period.
+func (r *RefreshingAEAD) keys() []cipher.AEAD {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := []cipher.AEAD{r.current.aead}
+	if r.previous.aead != nil && r.now().Before(r.previousUntil) {
+		keys = append(keys, r.previous.aead)
+	}
+	return keys
+}
+
+// maybeRefresh refreshes the key if it is due and no other refresh is in
+// progress.
+func (r *RefreshingAEAD) maybeRefresh() {
+	r.mu.RLock()
+	due := !r.now().Before(r.nextRefresh)
+	r.mu.RUnlock()
+
+	if !due || !r.refreshing.CompareAndSwap(false, true) {
+		return
+	}
+	defer r.refreshing.Store(false)
+
+	_ = r.Refresh(context.Background())
+}
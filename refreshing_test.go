@@ -0,0 +1,205 @@
+package siv
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeFetcher struct {
+	mu    sync.Mutex
+	id    string
+	key   []byte
+	err   error
+	calls int
+}
+
+func (f *fakeFetcher) set(id string, key byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.id, f.key = id, testKey(key, 32)
+}
+
+func (f *fakeFetcher) fetch(ctx context.Context) (string, []byte, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls++
+	if f.err != nil {
+		return "", nil, f.err
+	}
+	return f.id, f.key, nil
+}
+
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func testRefreshing(t *testing.T) (*RefreshingAEAD, *fakeFetcher, *fakeClock) {
+	f := &fakeFetcher{}
+	f.set("k1", 1)
+
+	c := &fakeClock{t: time.Unix(1700000000, 0)}
+	r := &RefreshingAEAD{fetch: f.fetch, ttl: time.Minute, grace: 10 * time.Minute, now: c.now}
+	if err := r.Refresh(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	return r, f, c
+}
+
+func TestRefreshingRotation(t *testing.T) {
+	r, f, c := testRefreshing(t)
+
+	old := r.Seal(nil, nil, []byte("yay"), nil)
+	f.set("k2", 2)
+
+	// Before the TTL expires, the key isn't refetched.
+	c.t = c.t.Add(59 * time.Second)
+	r.Seal(nil, nil, []byte("yay"), nil)
+	if id := r.KeyID(); id != "k1" {
+		t.Errorf("Key ID was %q, but expected %q", id, "k1")
+	}
+
+	c.t = c.t.Add(time.Second)
+	ciphertext := r.Seal(nil, nil, []byte("yay"), nil)
+	if id := r.KeyID(); id != "k2" {
+		t.Errorf("Key ID was %q, but expected %q", id, "k2")
+	}
+
+	expected, _ := New(testKey(2, 32), aes.NewCipher)
+	if e := expected.Seal(nil, nil, []byte("yay"), nil); !bytes.Equal(ciphertext, e) {
+		t.Errorf("Ciphertext was %x, but expected %x", ciphertext, e)
+	}
+
+	// The old key opens during the grace period only.
+	if _, err := r.Open(nil, nil, old, nil); err != nil {
+		t.Fatal(err)
+	}
+	inPlace := append([]byte{}, old...)
+	if p, err := r.Open(inPlace[:0], nil, inPlace, nil); err != nil || string(p) != "yay" {
+		t.Errorf("In-place plaintext was %q, %v, but expected %q", p, err, "yay")
+	}
+
+	c.t = c.t.Add(10 * time.Minute)
+	if p, err := r.Open(nil, nil, old, nil); err != ErrAuthentication {
+		t.Fatalf("Plaintext returned instead of error: %x", p)
+	}
+
+	if _, err := r.Open(nil, nil, ciphertext, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRefreshingUnchanged(t *testing.T) {
+	r, f, c := testRefreshing(t)
+	aead := r.current.aead
+
+	c.t = c.t.Add(time.Hour)
+	r.Seal(nil, nil, nil, nil)
+
+	if f.calls != 2 {
+		t.Errorf("Fetched %d times, but expected %d", f.calls, 2)
+	}
+
+	if r.current.aead != aead || r.previous.aead != nil {
+		t.Error("AEAD was rebuilt for an unchanged key")
+	}
+}
+
+func TestRefreshingFetchFailure(t *testing.T) {
+	r, f, c := testRefreshing(t)
+
+	outage := errors.New("secrets service unavailable")
+	f.err = outage
+
+	c.t = c.t.Add(time.Minute)
+	ciphertext := r.Seal(nil, nil, []byte("yay"), nil)
+
+	if err := r.Health(); err != outage {
+		t.Errorf("Health was %v, but expected %v", err, outage)
+	}
+
+	if id := r.KeyID(); id != "k1" {
+		t.Errorf("Key ID was %q, but expected %q", id, "k1")
+	}
+
+	if _, err := r.Open(nil, nil, ciphertext, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// The fetch isn't retried until another TTL has passed.
+	if f.calls != 2 {
+		t.Errorf("Fetched %d times, but expected %d", f.calls, 2)
+	}
+
+	f.err = nil
+	f.set("k2", 2)
+	c.t = c.t.Add(time.Minute)
+	r.Seal(nil, nil, nil, nil)
+
+	if err := r.Health(); err != nil {
+		t.Errorf("Health was %v, but expected nil", err)
+	}
+
+	if id := r.KeyID(); id != "k2" {
+		t.Errorf("Key ID was %q, but expected %q", id, "k2")
+	}
+
+	// An invalid key is a failure too.
+	f.set("k3", 3)
+	f.key = f.key[:7]
+	c.t = c.t.Add(time.Minute)
+	r.Seal(nil, nil, nil, nil)
+
+	if r.Health() == nil || r.KeyID() != "k2" {
+		t.Errorf("Invalid key was accepted as %q", r.KeyID())
+	}
+}
+
+func TestNewRefreshingAEADError(t *testing.T) {
+	outage := errors.New("secrets service unavailable")
+	f := &fakeFetcher{err: outage}
+
+	if r, err := NewRefreshingAEAD(context.Background(), f.fetch, time.Minute, time.Minute); err != outage {
+		t.Fatalf("AEAD returned instead of error: %v", r)
+	}
+}
+
+func TestRefreshingConcurrent(t *testing.T) {
+	f := &fakeFetcher{}
+	f.set("k1", 1)
+
+	r, err := NewRefreshingAEAD(context.Background(), f.fetch, time.Nanosecond, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if j == 50 && i == 0 {
+					f.set("k2", 2)
+				}
+
+				ciphertext := r.Seal(nil, nil, []byte("yay"), nil)
+				if _, err := r.Open(nil, nil, ciphertext, nil); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
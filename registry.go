@@ -0,0 +1,88 @@
+package siv
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// The canonical names of the SIV-CMAC algorithms registered by this package.
+// The number is the size of the combined key, in bits.
+const (
+	AESSIVCMAC256 = "AES-SIV-CMAC-256"
+	AESSIVCMAC384 = "AES-SIV-CMAC-384"
+	AESSIVCMAC512 = "AES-SIV-CMAC-512"
+)
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]registration)
+)
+
+type registration struct {
+	name string
+	ctor func(key []byte) (cipher.AEAD, error)
+}
+
+func init() {
+	Register(AESSIVCMAC256, newAES(32))
+	Register(AESSIVCMAC384, newAES(48))
+	Register(AESSIVCMAC512, newAES(64))
+}
+
+// newAES returns a constructor for AES-SIV with the given combined key size.
+func newAES(size int) func([]byte) (cipher.AEAD, error) {
+	return func(key []byte) (cipher.AEAD, error) {
+		if len(key) != size {
+			return nil, fmt.Errorf("siv: invalid key size %d, expected %d", len(key), size)
+		}
+		return New(key, aes.NewCipher)
+	}
+}
+
+// Register makes an AEAD constructor available by the given name. Names are
+// case-insensitive. If Register is called twice with the same name, or if
+// ctor is nil, it panics.
+func Register(name string, ctor func(key []byte) (cipher.AEAD, error)) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if ctor == nil {
+		panic("siv: Register constructor is nil")
+	}
+
+	k := strings.ToLower(name)
+	if _, dup := registry[k]; dup {
+		panic("siv: Register called twice for " + name)
+	}
+	registry[k] = registration{name: name, ctor: ctor}
+}
+
+// NewNamed returns a new AEAD using the constructor registered under the given
+// name.
+func NewNamed(name string, key []byte) (cipher.AEAD, error) {
+	registryMu.RLock()
+	r, ok := registry[strings.ToLower(name)]
+	registryMu.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("siv: unknown algorithm %q (known: %s)", name, strings.Join(Names(), ", "))
+	}
+	return r.ctor(key)
+}
+
+// Names returns the sorted names of the registered constructors.
+func Names() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for _, r := range registry {
+		names = append(names, r.name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,106 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestNewNamed(t *testing.T) {
+	for name, size := range map[string]int{
+		AESSIVCMAC256:      32,
+		"aes-siv-cmac-384": 48,
+		"Aes-Siv-Cmac-512": 64,
+	} {
+		key := testKey(1, size)
+		aead, err := NewNamed(name, key)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		expected, _ := New(key, aes.NewCipher)
+		a := aead.Seal(nil, nil, []byte("yay"), nil)
+		b := expected.Seal(nil, nil, []byte("yay"), nil)
+		if !bytes.Equal(a, b) {
+			t.Errorf("%s: Ciphertext was %x, but expected %x", name, a, b)
+		}
+	}
+}
+
+func TestNewNamedWrongKeySize(t *testing.T) {
+	if aead, err := NewNamed(AESSIVCMAC512, testKey(0, 32)); err == nil {
+		t.Fatalf("AEAD returned instead of error: %v", aead)
+	}
+}
+
+func TestNewNamedUnknown(t *testing.T) {
+	_, err := NewNamed("AES_SIV_CMAC_256", testKey(0, 32))
+	if err == nil {
+		t.Fatal("AEAD returned instead of error")
+	}
+
+	for _, name := range []string{AESSIVCMAC256, AESSIVCMAC384, AESSIVCMAC512} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("Error %q did not list %s", err, name)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("test-register", func(key []byte) (cipher.AEAD, error) {
+		return New(key, aes.NewCipher)
+	})
+
+	if _, err := NewNamed("TEST-REGISTER", testKey(0, 32)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterDuplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Duplicate registration did not panic")
+		}
+	}()
+
+	Register("aes-siv-cmac-256", newAES(32))
+}
+
+func TestRegisterNil(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Nil registration did not panic")
+		}
+	}()
+
+	Register("test-register-nil", nil)
+}
+
+func TestRegistryConcurrency(t *testing.T) {
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			Register(fmt.Sprintf("test-concurrent-%d", i), newAES(32))
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, err := NewNamed(AESSIVCMAC256, testKey(0, 32)); err != nil {
+				t.Error(err)
+			}
+			_ = Names()
+		}()
+	}
+	wg.Wait()
+
+	for i := 0; i < 8; i++ {
+		if _, err := NewNamed(fmt.Sprintf("test-concurrent-%d", i), testKey(0, 32)); err != nil {
+			t.Error(err)
+		}
+	}
+}
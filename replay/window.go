@@ -0,0 +1,131 @@
+// Package replay provides replay protection for sequence-numbered messages.
+package replay
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	siv "github.com/stripe/siv-go"
+)
+
+var (
+	// ErrReplay is returned for a sequence number which has already been
+	// accepted.
+	ErrReplay = errors.New("replay: duplicate sequence number")
+
+	// ErrTooOld is returned for a sequence number which has fallen behind the
+	// window, and so can't be checked.
+	ErrTooOld = errors.New("replay: sequence number outside window")
+)
+
+// Window is a sliding window over sequence numbers which accepts each one at
+// most once. Sequence numbers may arrive out of order, as long as they are
+// within the window's size of the highest sequence number accepted so far.
+// Higher sequence numbers, however far ahead, advance the window. A Window is
+// safe for concurrent use.
+type Window struct {
+	mu      sync.Mutex
+	bits    []uint64
+	top     uint64
+	started bool
+}
+
+// NewWindow returns a window which tracks the given number of sequence numbers,
+// rounded up to a multiple of 64. It panics if size is not positive.
+func NewWindow(size int) *Window {
+	if size <= 0 {
+		panic("replay: window size must be positive")
+	}
+	return &Window{bits: make([]uint64, (size+63)/64)}
+}
+
+// Size returns the number of sequence numbers the window tracks.
+func (w *Window) Size() int {
+	return len(w.bits) * 64
+}
+
+// Check accepts seq, returning ErrReplay if it has already been accepted or
+// ErrTooOld if it is too far behind the highest accepted sequence number.
+func (w *Window) Check(seq uint64) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	size := uint64(w.Size())
+	switch {
+	case !w.started || seq > w.top:
+		w.advance(seq)
+	case w.top-seq >= size:
+		return ErrTooOld
+	case w.test(seq):
+		return ErrReplay
+	}
+
+	w.set(seq)
+	return nil
+}
+
+// advance moves the top of the window to seq, forgetting the sequence numbers
+// which fall out of it.
+func (w *Window) advance(seq uint64) {
+	if !w.started || seq-w.top >= uint64(w.Size()) {
+		for i := range w.bits {
+			w.bits[i] = 0
+		}
+	} else {
+		// Count the slots rather than walking sequence numbers up to seq,
+		// which would never end for math.MaxUint64.
+		for i, n := uint64(1), seq-w.top; i <= n; i++ {
+			w.clear(w.top + i)
+		}
+	}
+	w.top = seq
+	w.started = true
+}
+
+func (w *Window) index(seq uint64) (int, uint64) {
+	i := seq % uint64(w.Size())
+	return int(i / 64), 1 << (i % 64)
+}
+
+func (w *Window) test(seq uint64) bool {
+	i, b := w.index(seq)
+	return w.bits[i]&b != 0
+}
+
+func (w *Window) set(seq uint64) {
+	i, b := w.index(seq)
+	w.bits[i] |= b
+}
+
+func (w *Window) clear(seq uint64) {
+	i, b := w.index(seq)
+	w.bits[i] &^= b
+}
+
+// SealSequenced seals plaintext with seq bound as associated data ahead of ad.
+func SealSequenced(aead cipher.AEAD, seq uint64, plaintext, ad []byte) []byte {
+	return bind(aead, seq).Seal(nil, nil, plaintext, ad)
+}
+
+// OpenSequenced opens a ciphertext sealed by SealSequenced with the same seq
+// and ad, then checks seq against the window. The window is only consulted,
+// and so only advanced, once the ciphertext has been authenticated.
+func (w *Window) OpenSequenced(aead cipher.AEAD, seq uint64, ciphertext, ad []byte) ([]byte, error) {
+	plaintext, err := bind(aead, seq).Open(nil, nil, ciphertext, ad)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := w.Check(seq); err != nil {
+		return nil, err
+	}
+	return plaintext, nil
+}
+
+func bind(aead cipher.AEAD, seq uint64) cipher.AEAD {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], seq)
+	return siv.NewContext(aead, []byte("replay sequence"), b[:])
+}
@@ -0,0 +1,188 @@
+package replay
+
+import (
+	"crypto/aes"
+	"math"
+	"sync"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func TestWindowSize(t *testing.T) {
+	for size, expected := range map[int]int{1: 64, 64: 64, 65: 128, 1024: 1024} {
+		if actual := NewWindow(size).Size(); actual != expected {
+			t.Errorf("Size of %d was %d, but expected %d", size, actual, expected)
+		}
+	}
+}
+
+func TestWindowDuplicates(t *testing.T) {
+	w := NewWindow(64)
+
+	for _, seq := range []uint64{0, 1, 2, 5} {
+		if err := w.Check(seq); err != nil {
+			t.Fatalf("%d: %v", seq, err)
+		}
+	}
+
+	for _, seq := range []uint64{0, 1, 2, 5} {
+		if err := w.Check(seq); err != ErrReplay {
+			t.Errorf("%d: Error was %v, but expected %v", seq, err, ErrReplay)
+		}
+	}
+}
+
+func TestWindowReordering(t *testing.T) {
+	w := NewWindow(64)
+
+	for _, seq := range []uint64{10, 8, 9, 3, 11, 4} {
+		if err := w.Check(seq); err != nil {
+			t.Fatalf("%d: %v", seq, err)
+		}
+	}
+}
+
+func TestWindowAdvancement(t *testing.T) {
+	w := NewWindow(64)
+
+	if err := w.Check(100); err != nil {
+		t.Fatal(err)
+	}
+
+	// The oldest sequence number still in the window.
+	if err := w.Check(37); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Check(36); err != ErrTooOld {
+		t.Errorf("Error was %v, but expected %v", err, ErrTooOld)
+	}
+
+	// Advancing by less than the window size keeps recent history.
+	if err := w.Check(120); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Check(100); err != ErrReplay {
+		t.Errorf("Error was %v, but expected %v", err, ErrReplay)
+	}
+
+	if err := w.Check(37); err != ErrTooOld {
+		t.Errorf("Error was %v, but expected %v", err, ErrTooOld)
+	}
+
+	// Slots reused by the advance are cleared.
+	if err := w.Check(101); err != nil {
+		t.Errorf("Error was %v, but expected it to be accepted", err)
+	}
+}
+
+func TestWindowFarFutureJump(t *testing.T) {
+	w := NewWindow(64)
+
+	for seq := uint64(0); seq < 64; seq++ {
+		_ = w.Check(seq)
+	}
+
+	if err := w.Check(1 << 40); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Check(1<<40 - 1); err != nil {
+		t.Errorf("Error was %v, but expected it to be accepted", err)
+	}
+
+	if err := w.Check(63); err != ErrTooOld {
+		t.Errorf("Error was %v, but expected %v", err, ErrTooOld)
+	}
+
+	if err := w.Check(^uint64(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Check(^uint64(0)); err != ErrReplay {
+		t.Errorf("Error was %v, but expected %v", err, ErrReplay)
+	}
+}
+
+func TestWindowMaxSequence(t *testing.T) {
+	w := NewWindow(64)
+
+	for _, seq := range []uint64{math.MaxUint64 - 3, math.MaxUint64 - 1, math.MaxUint64} {
+		if err := w.Check(seq); err != nil {
+			t.Fatalf("%d: %v", seq, err)
+		}
+	}
+
+	if err := w.Check(math.MaxUint64 - 2); err != nil {
+		t.Errorf("Error was %v, but expected it to be accepted", err)
+	}
+
+	for _, seq := range []uint64{math.MaxUint64 - 3, math.MaxUint64 - 1, math.MaxUint64} {
+		if err := w.Check(seq); err != ErrReplay {
+			t.Errorf("%d: Error was %v, but expected %v", seq, err, ErrReplay)
+		}
+	}
+}
+
+func TestWindowConcurrency(t *testing.T) {
+	w := NewWindow(1024)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for seq := uint64(0); seq < 1000; seq++ {
+				if w.Check(seq) == nil {
+					mu.Lock()
+					accepted++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if accepted != 1000 {
+		t.Errorf("Accepted %d sequence numbers, but expected 1000", accepted)
+	}
+}
+
+func TestOpenSequenced(t *testing.T) {
+	aead, _ := siv.New([]byte("0123456789abcdef0123456789ABCDEF"), aes.NewCipher)
+	w := NewWindow(64)
+
+	c1 := SealSequenced(aead, 1, []byte("one"), []byte("ad"))
+	c2 := SealSequenced(aead, 2, []byte("two"), []byte("ad"))
+
+	if p, err := w.OpenSequenced(aead, 2, c2, []byte("ad")); err != nil || string(p) != "two" {
+		t.Fatalf("Opened %q (error %v)", p, err)
+	}
+
+	if p, err := w.OpenSequenced(aead, 1, c1, []byte("ad")); err != nil || string(p) != "one" {
+		t.Fatalf("Opened %q (error %v)", p, err)
+	}
+
+	if _, err := w.OpenSequenced(aead, 1, c1, []byte("ad")); err != ErrReplay {
+		t.Errorf("Error was %v, but expected %v", err, ErrReplay)
+	}
+
+	// A ciphertext can't be presented under another sequence number.
+	if _, err := w.OpenSequenced(aead, 3, c1, []byte("ad")); err != siv.ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, siv.ErrAuthentication)
+	}
+
+	// Forgeries don't advance the window.
+	if _, err := w.OpenSequenced(aead, 1000, c1, []byte("ad")); err != siv.ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, siv.ErrAuthentication)
+	}
+
+	c3 := SealSequenced(aead, 3, []byte("three"), []byte("ad"))
+	if _, err := w.OpenSequenced(aead, 3, c3, []byte("ad")); err != nil {
+		t.Errorf("Error was %v, but expected it to be accepted", err)
+	}
+}
@@ -0,0 +1,74 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"fmt"
+)
+
+// ReEncrypt opens ciphertext with oldAEAD and immediately seals the resulting
+// plaintext with newAEAD, using ad as the associated data for both. The
+// intermediate plaintext is zeroed before ReEncrypt returns. Authentication
+// failures from oldAEAD are returned unchanged.
+func ReEncrypt(oldAEAD, newAEAD cipher.AEAD, ciphertext, ad []byte) ([]byte, error) {
+	return reEncrypt(oldAEAD, newAEAD, nil, ciphertext, ad, ad)
+}
+
+// ReEncryptWithAD is like ReEncrypt, but opens with oldAD and seals with
+// newAD.
+func ReEncryptWithAD(oldAEAD, newAEAD cipher.AEAD, ciphertext, oldAD, newAD []byte) ([]byte, error) {
+	return reEncrypt(oldAEAD, newAEAD, nil, ciphertext, oldAD, newAD)
+}
+
+// ReEncryptBatch re-encrypts each of the given ciphertexts as ReEncrypt does,
+// reusing a single intermediate buffer which is zeroed after every message. If
+// any ciphertext fails to open, it returns a *BatchError identifying it.
+func ReEncryptBatch(oldAEAD, newAEAD cipher.AEAD, ciphertexts [][]byte, ad []byte) (ret [][]byte, err error) {
+	end := startSpan(nil, "siv.ReEncryptBatch")
+	defer func() { end(err) }()
+
+	var scratch []byte
+	results := make([][]byte, len(ciphertexts))
+	for i, c := range ciphertexts {
+		if n := len(c) - oldAEAD.Overhead(); n > cap(scratch) {
+			scratch = make([]byte, n)
+		}
+
+		v, err := reEncrypt(oldAEAD, newAEAD, scratch, c, ad, ad)
+		if err != nil {
+			return nil, &BatchError{Index: i, Err: err}
+		}
+		results[i] = v
+	}
+	return results, nil
+}
+
+// BatchError is returned by batch operations when one of the messages fails.
+type BatchError struct {
+	Index int   // the index of the failed message
+	Err   error // the underlying error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("message %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// reEncrypt opens ciphertext into scratch, seals it, and wipes the plaintext.
+func reEncrypt(oldAEAD, newAEAD cipher.AEAD, scratch, ciphertext, oldAD, newAD []byte) ([]byte, error) {
+	plaintext, err := oldAEAD.Open(scratch[:0], nil, ciphertext, oldAD)
+	if err != nil {
+		return nil, err
+	}
+	defer wipe(plaintext)
+
+	return newAEAD.Seal(nil, nil, plaintext, newAD), nil
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,106 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"errors"
+	"testing"
+)
+
+func TestReEncrypt(t *testing.T) {
+	oldAEAD, _ := New(bytes.Repeat([]byte{1}, 32), aes.NewCipher, AllowEqualHalves())
+	newAEAD, _ := New(bytes.Repeat([]byte{2}, 32), aes.NewCipher, AllowEqualHalves())
+	plaintext := []byte("yay for rotation")
+	data := []byte("ad")
+
+	ciphertext, err := ReEncrypt(oldAEAD, newAEAD, oldAEAD.Seal(nil, nil, plaintext, data), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := newAEAD.Seal(nil, nil, plaintext, data); !bytes.Equal(ciphertext, expected) {
+		t.Errorf("Ciphertext was %x, but expected %x", ciphertext, expected)
+	}
+}
+
+func TestReEncryptWithAD(t *testing.T) {
+	oldAEAD, _ := New(bytes.Repeat([]byte{1}, 32), aes.NewCipher, AllowEqualHalves())
+	newAEAD, _ := New(bytes.Repeat([]byte{2}, 32), aes.NewCipher, AllowEqualHalves())
+	plaintext := []byte("yay for rotation")
+
+	ciphertext, err := ReEncryptWithAD(oldAEAD, newAEAD, oldAEAD.Seal(nil, nil, plaintext, []byte("old")), []byte("old"), []byte("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := newAEAD.Open(nil, nil, ciphertext, []byte("new"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(actual, plaintext) {
+		t.Errorf("Plaintext was %x, but expected %x", actual, plaintext)
+	}
+}
+
+func TestReEncryptBadCiphertext(t *testing.T) {
+	oldAEAD, _ := New(bytes.Repeat([]byte{1}, 32), aes.NewCipher, AllowEqualHalves())
+	newAEAD, _ := New(bytes.Repeat([]byte{2}, 32), aes.NewCipher, AllowEqualHalves())
+
+	ciphertext := oldAEAD.Seal(nil, nil, []byte("yay"), nil)
+	ciphertext[0] ^= 1
+
+	actual, err := ReEncrypt(oldAEAD, newAEAD, ciphertext, nil)
+	if err != ErrAuthentication {
+		t.Fatalf("Error was %v, but expected %v (ciphertext %x)", err, ErrAuthentication, actual)
+	}
+}
+
+func TestReEncryptWipesPlaintext(t *testing.T) {
+	oldAEAD, _ := New(bytes.Repeat([]byte{1}, 32), aes.NewCipher, AllowEqualHalves())
+	newAEAD, _ := New(bytes.Repeat([]byte{2}, 32), aes.NewCipher, AllowEqualHalves())
+	plaintext := []byte("yay for rotation")
+
+	scratch := make([]byte, 64)
+	if _, err := reEncrypt(oldAEAD, newAEAD, scratch, oldAEAD.Seal(nil, nil, plaintext, nil), nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(scratch, make([]byte, len(scratch))) {
+		t.Errorf("Scratch buffer was %x, but expected zeros", scratch)
+	}
+}
+
+func TestReEncryptBatch(t *testing.T) {
+	oldAEAD, _ := New(bytes.Repeat([]byte{1}, 32), aes.NewCipher, AllowEqualHalves())
+	newAEAD, _ := New(bytes.Repeat([]byte{2}, 32), aes.NewCipher, AllowEqualHalves())
+	plaintexts := [][]byte{[]byte("one"), []byte("a much longer second message"), nil, []byte("four")}
+
+	var ciphertexts [][]byte
+	for _, p := range plaintexts {
+		ciphertexts = append(ciphertexts, oldAEAD.Seal(nil, nil, p, nil))
+	}
+
+	results, err := ReEncryptBatch(oldAEAD, newAEAD, ciphertexts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, p := range plaintexts {
+		if expected := newAEAD.Seal(nil, nil, p, nil); !bytes.Equal(results[i], expected) {
+			t.Errorf("Ciphertext %d was %x, but expected %x", i, results[i], expected)
+		}
+	}
+
+	ciphertexts[2][0] ^= 1
+
+	_, err = ReEncryptBatch(oldAEAD, newAEAD, ciphertexts, nil)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 2 {
+		t.Fatalf("Error was %v, but expected a failure at index 2", err)
+	}
+
+	if !errors.Is(err, ErrAuthentication) {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+}
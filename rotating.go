@@ -0,0 +1,57 @@
+package siv
+
+import (
+	"sync/atomic"
+)
+
+// RotatingKeyring is a Keyring which upgrades data sealed under old keys as it
+// is read, so that a key rotation completes lazily as data is accessed.
+type RotatingKeyring struct {
+	*Keyring
+
+	opened, upgraded atomic.Uint64
+}
+
+// RotationStats counts the operations performed by a RotatingKeyring.
+type RotationStats struct {
+	// Opened is the number of ciphertexts successfully opened by
+	// OpenAndUpgrade.
+	Opened uint64
+
+	// Upgraded is the number of those which were not sealed under the primary
+	// key, and so were re-sealed.
+	Upgraded uint64
+}
+
+// NewRotatingKeyring returns a RotatingKeyring using the keys in k.
+func NewRotatingKeyring(k *Keyring) *RotatingKeyring {
+	return &RotatingKeyring{Keyring: k}
+}
+
+// OpenAndUpgrade opens ciphertext with any key in the keyring. If it was not
+// sealed under the primary key, it also returns the plaintext sealed under the
+// primary key with the same associated data, for the caller to write back in
+// place of ciphertext; otherwise upgraded is nil.
+func (r *RotatingKeyring) OpenAndUpgrade(ciphertext, ad []byte) (plaintext, upgraded []byte, err error) {
+	primaryID, primary := r.Primary()
+
+	plaintext, id, err := r.open(nil, nil, ciphertext, ad)
+	if err != nil {
+		return nil, nil, err
+	}
+	r.opened.Add(1)
+
+	if id != primaryID {
+		upgraded = primary.Seal(nil, nil, plaintext, ad)
+		r.upgraded.Add(1)
+	}
+	return plaintext, upgraded, nil
+}
+
+// Stats returns the number of ciphertexts opened and upgraded so far.
+func (r *RotatingKeyring) Stats() RotationStats {
+	return RotationStats{
+		Opened:   r.opened.Load(),
+		Upgraded: r.upgraded.Load(),
+	}
+}
@@ -0,0 +1,61 @@
+package siv
+
+import (
+	"testing"
+)
+
+func TestRotatingKeyring(t *testing.T) {
+	k, aeads := testKeyring(t, "old", "new")
+	r := NewRotatingKeyring(k)
+
+	// Data written before the rotation is under the old primary.
+	stored := aeads["old"].Seal(nil, nil, []byte("record"), []byte("ad"))
+
+	if err := k.SetPrimary("new"); err != nil {
+		t.Fatal(err)
+	}
+
+	plaintext, upgraded, err := r.OpenAndUpgrade(stored, []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(plaintext) != "record" {
+		t.Errorf("Plaintext was %q, but expected %q", plaintext, "record")
+	}
+
+	if upgraded == nil {
+		t.Fatal("Ciphertext under the old key was not upgraded")
+	}
+
+	if p, err := aeads["new"].Open(nil, nil, upgraded, []byte("ad")); err != nil || string(p) != "record" {
+		t.Errorf("Upgraded ciphertext opened to %q (error %v) under the new key", p, err)
+	}
+
+	if p, err := aeads["old"].Open(nil, nil, upgraded, []byte("ad")); err == nil {
+		t.Fatalf("Plaintext returned instead of error: %x", p)
+	}
+
+	if p, err := aeads["new"].Open(nil, nil, upgraded, []byte("other")); err == nil {
+		t.Fatalf("Plaintext returned instead of error: %x", p)
+	}
+
+	// Once written back, the data is not upgraded again.
+	plaintext, again, err := r.OpenAndUpgrade(upgraded, []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(plaintext) != "record" || again != nil {
+		t.Errorf("Opened %q and upgraded %x, but expected %q and nil", plaintext, again, "record")
+	}
+
+	if _, _, err := r.OpenAndUpgrade(stored, []byte("other")); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+
+	expected := RotationStats{Opened: 2, Upgraded: 1}
+	if stats := r.Stats(); stats != expected {
+		t.Errorf("Stats were %+v, but expected %+v", stats, expected)
+	}
+}
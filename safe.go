@@ -0,0 +1,132 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync/atomic"
+)
+
+var (
+	// ErrPanicked is wrapped by PanicError.
+	ErrPanicked = errors.New("siv: wrapped AEAD panicked")
+
+	// ErrPoisoned is returned by a SafeAEAD after the wrapped AEAD has
+	// panicked once.
+	ErrPoisoned = errors.New("siv: AEAD disabled after an earlier panic")
+)
+
+// PanicError is returned by a SafeAEAD when the wrapped AEAD panics.
+type PanicError struct {
+	Op    string // "Seal" or "Open"
+	Value any    // the value passed to panic
+	Stack []byte // the panicking goroutine's stack, if captured
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("siv: %s panicked: %v", e.Op, e.Value)
+}
+
+// Unwrap returns ErrPanicked and, if the panic value was an error, that error,
+// so that errors.Is(err, ErrNonceSize) and the like work as expected.
+func (e *PanicError) Unwrap() []error {
+	if err, ok := e.Value.(error); ok {
+		return []error{ErrPanicked, err}
+	}
+	return []error{ErrPanicked}
+}
+
+// A SafeOption configures a SafeAEAD.
+type SafeOption func(*SafeAEAD)
+
+// WithStackCapture sets the function which captures the stack for
+// PanicError.Stack. It is called from the panicking goroutine before the
+// stack unwinds, so the stack includes the frame which panicked. The default
+// is debug.Stack; nil disables capture.
+func WithStackCapture(fn func() []byte) SafeOption {
+	return func(s *SafeAEAD) {
+		s.stack = fn
+	}
+}
+
+// NewSafe returns a wrapper around aead whose Seal and Open return errors
+// rather than panicking, for callers which would rather handle misuse, such
+// as a wrong nonce size, overlapping buffers, or an exhausted key, than crash.
+func NewSafe(aead cipher.AEAD, opts ...SafeOption) *SafeAEAD {
+	s := &SafeAEAD{aead: aead, stack: debug.Stack}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// SafeAEAD converts panics from a wrapped AEAD into a *PanicError. It is safe
+// for concurrent use if the wrapped AEAD is.
+//
+// A panic can leave the wrapped AEAD in an inconsistent state, so after the
+// first panic a SafeAEAD never calls it again: every later Seal and Open
+// returns ErrPoisoned, and a new SafeAEAD around a fresh AEAD is needed to
+// continue. Calls already in progress when the panic happens aren't
+// interrupted. The spare capacity of dst may have been written by the
+// panicking call, so it shouldn't be relied on either.
+type SafeAEAD struct {
+	aead  cipher.AEAD
+	stack func() []byte
+
+	poisoned atomic.Pointer[PanicError]
+}
+
+// NonceSize returns the wrapped AEAD's nonce size.
+func (s *SafeAEAD) NonceSize() int {
+	return s.aead.NonceSize()
+}
+
+// Overhead returns the wrapped AEAD's overhead.
+func (s *SafeAEAD) Overhead() int {
+	return s.aead.Overhead()
+}
+
+// Err returns the *PanicError which disabled s, or nil if the wrapped AEAD
+// hasn't panicked.
+func (s *SafeAEAD) Err() error {
+	if p := s.poisoned.Load(); p != nil {
+		return p
+	}
+	return nil
+}
+
+// Seal seals plaintext as the wrapped AEAD does, returning a *PanicError if it
+// panics, or ErrPoisoned if it has panicked before.
+func (s *SafeAEAD) Seal(dst, nonce, plaintext, data []byte) (ret []byte, err error) {
+	if s.poisoned.Load() != nil {
+		return nil, ErrPoisoned
+	}
+	defer s.recover("Seal", &ret, &err)
+	return s.aead.Seal(dst, nonce, plaintext, data), nil
+}
+
+// Open opens ciphertext as the wrapped AEAD does, returning a *PanicError if
+// it panics, or ErrPoisoned if it has panicked before.
+func (s *SafeAEAD) Open(dst, nonce, ciphertext, data []byte) (ret []byte, err error) {
+	if s.poisoned.Load() != nil {
+		return nil, ErrPoisoned
+	}
+	defer s.recover("Open", &ret, &err)
+	return s.aead.Open(dst, nonce, ciphertext, data)
+}
+
+// recover converts a panic in progress into a *PanicError and disables s.
+func (s *SafeAEAD) recover(op string, ret *[]byte, err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	p := &PanicError{Op: op, Value: r}
+	if s.stack != nil {
+		p.Stack = s.stack()
+	}
+	s.poisoned.CompareAndSwap(nil, p)
+	*ret, *err = nil, p
+}
@@ -0,0 +1,196 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// countingAEAD counts calls to the AEAD it wraps.
+type countingAEAD struct {
+	cipher.AEAD
+	calls int
+}
+
+func (c *countingAEAD) Seal(dst, nonce, plaintext, data []byte) []byte {
+	c.calls++
+	return c.AEAD.Seal(dst, nonce, plaintext, data)
+}
+
+func (c *countingAEAD) Open(dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	c.calls++
+	return c.AEAD.Open(dst, nonce, ciphertext, data)
+}
+
+func TestSafe(t *testing.T) {
+	aead, err := New(testKey(0, 32), aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSafe(aead)
+
+	ciphertext, err := s.Seal(nil, nil, []byte("hello"), []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := aead.Seal(nil, nil, []byte("hello"), []byte("ad")); !bytes.Equal(ciphertext, expected) {
+		t.Errorf("Ciphertext was %x, but expected %x", ciphertext, expected)
+	}
+
+	if p, err := s.Open(nil, nil, ciphertext, []byte("ad")); err != nil || string(p) != "hello" {
+		t.Errorf("Plaintext was %q, error %v, but expected %q", p, err, "hello")
+	}
+	if p, err := s.Open(nil, nil, ciphertext, []byte("bad")); err != ErrAuthentication {
+		t.Fatalf("Plaintext returned instead of error: %q", p)
+	}
+
+	if s.NonceSize() != aead.NonceSize() || s.Overhead() != aead.Overhead() {
+		t.Errorf("Sizes were %d and %d", s.NonceSize(), s.Overhead())
+	}
+	if err := s.Err(); err != nil {
+		t.Errorf("Err was %v, but expected nil", err)
+	}
+}
+
+func TestSafePanics(t *testing.T) {
+	newAEAD := func(opts ...Option) cipher.AEAD {
+		aead, err := New(testKey(0, 32), aes.NewCipher, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return aead
+	}
+
+	buf := make([]byte, 64)
+	restricted, _ := testKeyring(t, "a")
+	if err := restricted.SetPurposes("a", "cookie"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		aead     cipher.AEAD
+		seal     func(s *SafeAEAD) ([]byte, error)
+		expected error // nil for a non-error panic value
+	}{
+		{
+			name:     "nonce size",
+			aead:     newAEAD(WithNonceSize(16)),
+			seal:     func(s *SafeAEAD) ([]byte, error) { return s.Seal(nil, make([]byte, 8), []byte("hello"), nil) },
+			expected: ErrNonceSize,
+		},
+		{
+			name:     "nonce size on open",
+			aead:     newAEAD(WithNonceSize(16)),
+			seal:     func(s *SafeAEAD) ([]byte, error) { return s.Open(nil, make([]byte, 8), make([]byte, 32), nil) },
+			expected: ErrNonceSize,
+		},
+		{
+			name:     "overlap",
+			aead:     newAEAD(),
+			seal:     func(s *SafeAEAD) ([]byte, error) { return s.Seal(buf[1:1], nil, buf[:16], nil) },
+			expected: ErrOverlap,
+		},
+		{
+			name:     "missing AD",
+			aead:     newAEAD(WithRequiredAD()),
+			seal:     func(s *SafeAEAD) ([]byte, error) { return s.Seal(nil, nil, []byte("hello"), nil) },
+			expected: ErrMissingAD,
+		},
+		{
+			name:     "key exhausted",
+			aead:     WithUsageLimits(newAEAD(), 0, 4),
+			seal:     func(s *SafeAEAD) ([]byte, error) { return s.Seal(nil, nil, []byte("hello"), nil) },
+			expected: ErrKeyExhausted,
+		},
+		{
+			name:     "purpose",
+			aead:     restricted.ForPurpose("db"),
+			seal:     func(s *SafeAEAD) ([]byte, error) { return s.Seal(nil, nil, []byte("hello"), nil) },
+			expected: ErrPurpose,
+		},
+		{
+			name: "empty keyring",
+			aead: NewKeyring(),
+			seal: func(s *SafeAEAD) ([]byte, error) { return s.Seal(nil, nil, []byte("hello"), nil) },
+		},
+		{
+			name: "nil pointer",
+			aead: (*SIV)(nil),
+			seal: func(s *SafeAEAD) ([]byte, error) { return s.Seal(nil, nil, []byte("hello"), nil) },
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &countingAEAD{AEAD: test.aead}
+			s := NewSafe(c)
+
+			ret, err := test.seal(s)
+			var pe *PanicError
+			if ret != nil || !errors.As(err, &pe) || !errors.Is(err, ErrPanicked) {
+				t.Fatalf("Returned %x, %v, but expected a PanicError", ret, err)
+			}
+			if test.expected != nil && !errors.Is(err, test.expected) {
+				t.Errorf("Error was %v, but expected %v", err, test.expected)
+			}
+			if !bytes.Contains(pe.Stack, []byte("TestSafePanics")) {
+				t.Errorf("Stack was %s", pe.Stack)
+			}
+			if s.Err() != pe {
+				t.Errorf("Err was %v, but expected %v", s.Err(), pe)
+			}
+
+			// The wrapped AEAD isn't called again.
+			calls := c.calls
+			if _, err := s.Seal(nil, nil, []byte("hello"), []byte("ad")); err != ErrPoisoned {
+				t.Errorf("Error was %v, but expected %v", err, ErrPoisoned)
+			}
+			if _, err := s.Open(nil, nil, make([]byte, 32), []byte("ad")); err != ErrPoisoned {
+				t.Errorf("Error was %v, but expected %v", err, ErrPoisoned)
+			}
+			if c.calls != calls {
+				t.Errorf("Wrapped AEAD was called %d more times", c.calls-calls)
+			}
+		})
+	}
+}
+
+func TestSafePanicValues(t *testing.T) {
+	s := NewSafe(NewKeyring())
+	_, err := s.Seal(nil, nil, nil, nil)
+	if msg := err.Error(); msg != "siv: Seal panicked: siv: keyring is empty" {
+		t.Errorf("Message was %q", msg)
+	}
+
+	var pe *PanicError
+	_, err = NewSafe((*SIV)(nil)).Open(nil, nil, make([]byte, 16), nil)
+	if !errors.As(err, &pe) || pe.Op != "Open" {
+		t.Fatalf("Error was %v", err)
+	}
+	var re runtime.Error
+	if !errors.As(err, &re) || !strings.Contains(re.Error(), "nil pointer") {
+		t.Errorf("Panic value was %v, but expected a nil pointer dereference", pe.Value)
+	}
+}
+
+func TestSafeStackCapture(t *testing.T) {
+	calls := 0
+	s := NewSafe(NewKeyring(), WithStackCapture(func() []byte {
+		calls++
+		return []byte("stack")
+	}))
+	var pe *PanicError
+	if _, err := s.Seal(nil, nil, nil, nil); !errors.As(err, &pe) || string(pe.Stack) != "stack" || calls != 1 {
+		t.Errorf("Error was %v, with stack %q after %d calls", err, pe.Stack, calls)
+	}
+
+	s = NewSafe(NewKeyring(), WithStackCapture(nil))
+	if _, err := s.Seal(nil, nil, nil, nil); !errors.As(err, &pe) || pe.Stack != nil {
+		t.Errorf("Error was %v, with stack %q", err, pe.Stack)
+	}
+}
@@ -0,0 +1,91 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Secret holds a sensitive value, such as a password or token, sealed, so
+// that it doesn't leak when the struct holding it is logged or serialized.
+// Its String, GoString, MarshalJSON, and (with Go 1.21 or later) LogValue
+// methods emit only a redaction marker and the fingerprint of the key it is
+// sealed under, and even formatting its fields directly, as fmt does for an
+// unexported field, reveals only ciphertext. The value is available only
+// through Reveal.
+//
+// A Secret protects against accidental disclosure, not an attacker who can
+// read process memory: the key is in the same process. The zero Secret holds
+// no value.
+type Secret struct {
+	aead        cipher.AEAD
+	sealed      []byte
+	fingerprint []byte
+}
+
+var secretKey struct {
+	once sync.Once
+	aead cipher.AEAD
+}
+
+// secretAEAD returns the process-local AEAD NewSecret seals with, generating
+// its key on first use.
+func secretAEAD() cipher.AEAD {
+	secretKey.once.Do(func() {
+		var key Key256
+		if _, err := rand.Read(key[:]); err != nil {
+			panic("siv: failed to generate secret key: " + err.Error())
+		}
+		secretKey.aead = NewAEAD256(key)
+		wipe(key[:])
+	})
+	return secretKey.aead
+}
+
+// NewSecret seals value under a key generated randomly for the process, which
+// is never exported, so the Secret can only be revealed by this process. The
+// caller should wipe value once it is no longer needed.
+func NewSecret(value []byte) Secret {
+	return NewSecretWith(secretAEAD(), value)
+}
+
+// NewSecretWith seals value under aead, as for secrets which are revealed by
+// another process holding the same key.
+func NewSecretWith(aead cipher.AEAD, value []byte) Secret {
+	bound := NewContext(aead, []byte("siv secret"))
+	return Secret{
+		aead:        bound,
+		sealed:      bound.Seal(nil, nil, value, nil),
+		fingerprint: fingerprintOf(aead),
+	}
+}
+
+// Reveal opens and returns a copy of the value, which the caller should wipe
+// once it is no longer needed. It returns nil for the zero Secret.
+func (s Secret) Reveal() ([]byte, error) {
+	if s.aead == nil {
+		return nil, nil
+	}
+	return s.aead.Open(nil, nil, s.sealed, nil)
+}
+
+// String returns a redaction marker, including the key's fingerprint if its
+// AEAD implements Fingerprint.
+func (s Secret) String() string {
+	if s.fingerprint == nil {
+		return "[REDACTED]"
+	}
+	return fmt.Sprintf("[REDACTED key=%x]", s.fingerprint)
+}
+
+// GoString returns the redaction marker, for %#v.
+func (s Secret) GoString() string {
+	return "siv.Secret(" + s.String() + ")"
+}
+
+// MarshalJSON encodes the redaction marker as a JSON string.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
@@ -0,0 +1,10 @@
+//go:build go1.21
+
+package siv
+
+import "log/slog"
+
+// LogValue implements slog.LogValuer, logging the redaction marker.
+func (s Secret) LogValue() slog.Value {
+	return slog.StringValue(s.String())
+}
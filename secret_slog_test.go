@@ -0,0 +1,27 @@
+//go:build go1.21
+
+package siv
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+)
+
+func TestSecretSlog(t *testing.T) {
+	s := NewSecret(secretValue)
+
+	for _, h := range []func(*bytes.Buffer) slog.Handler{
+		func(b *bytes.Buffer) slog.Handler { return slog.NewTextHandler(b, nil) },
+		func(b *bytes.Buffer) slog.Handler { return slog.NewJSONHandler(b, nil) },
+	} {
+		var buf bytes.Buffer
+		logger := slog.New(h(&buf))
+		logger.Info("login", "password", s, slog.Any("ptr", &s), slog.Group("g", "secret", s))
+
+		checkRedacted(t, "slog", buf.Bytes())
+		if n := bytes.Count(buf.Bytes(), []byte(s.String())); n != 3 {
+			t.Errorf("Log had %d redaction markers, but expected 3: %s", n, buf.Bytes())
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package siv
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+)
+
+var secretValue = []byte("hunter2-correct-horse")
+
+func TestSecretReveal(t *testing.T) {
+	_, aeads := testKeyring(t, "a")
+	for _, s := range []Secret{NewSecret(secretValue), NewSecretWith(aeads["a"], secretValue)} {
+		actual, err := s.Reveal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(actual, secretValue) {
+			t.Errorf("Value was %q, but expected %q", actual, secretValue)
+		}
+	}
+
+	if v, err := (Secret{}).Reveal(); v != nil || err != nil {
+		t.Errorf("Zero Secret revealed %q, %v", v, err)
+	}
+}
+
+func TestSecretRedaction(t *testing.T) {
+	_, aeads := testKeyring(t, "a")
+	s := NewSecretWith(aeads["a"], secretValue)
+	marker := fmt.Sprintf("[REDACTED key=%x]", aeads["a"].(*SIV).Fingerprint())
+
+	type config struct {
+		User     string
+		Password Secret
+		token    Secret
+		ptr      *Secret
+	}
+	c := config{User: "admin", Password: s, token: s, ptr: &s}
+
+	for _, format := range []string{"%v", "%+v", "%#v", "%s", "%q", "%x", "%X", "%d"} {
+		out := fmt.Sprintf(format, c)
+		checkRedacted(t, format, []byte(out))
+	}
+	if out := fmt.Sprint(s); out != marker {
+		t.Errorf("Output was %q, but expected %q", out, marker)
+	}
+	if out := fmt.Sprintf("%#v", s); out != "siv.Secret("+marker+")" {
+		t.Errorf("Output was %q, but expected %q", out, "siv.Secret("+marker+")")
+	}
+
+	b, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRedacted(t, "JSON", b)
+	if expected := `{"User":"admin","Password":"` + marker + `"}`; string(b) != expected {
+		t.Errorf("JSON was %s, but expected %s", b, expected)
+	}
+
+	if out := NewSecret(secretValue).String(); !bytes.HasPrefix([]byte(out), []byte("[REDACTED key=")) {
+		t.Errorf("Output was %q", out)
+	}
+}
+
+// checkRedacted fails if out contains the secret value, or any 4 bytes of it,
+// in the clear or hex encoded.
+func checkRedacted(t *testing.T, name string, out []byte) {
+	t.Helper()
+
+	for i := 0; i+4 <= len(secretValue); i++ {
+		part := secretValue[i : i+4]
+		if bytes.Contains(out, part) || bytes.Contains(bytes.ToLower(out), []byte(fmt.Sprintf("%x", part))) {
+			t.Fatalf("%s output contains %q: %s", name, part, out)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"errors"
+	"fmt"
+)
+
+// The one-byte algorithm identifiers which prefix self-describing
+// ciphertexts. The table is frozen: identifiers are never reassigned, and new
+// algorithms get new identifiers.
+//
+// The AES-GCM-SIV identifiers (RFC 8452) are reserved for AEADs from other
+// packages, so their ciphertexts can share storage with SIV-CMAC ones; this
+// package doesn't implement GCM-SIV.
+const (
+	AlgAESSIVCMAC256 byte = 0x01
+	AlgAESSIVCMAC384 byte = 0x02
+	AlgAESSIVCMAC512 byte = 0x03
+	AlgAESGCMSIV128  byte = 0x10
+	AlgAESGCMSIV256  byte = 0x11
+)
+
+var algorithmNames = [...]string{
+	AlgAESSIVCMAC256: AESSIVCMAC256,
+	AlgAESSIVCMAC384: AESSIVCMAC384,
+	AlgAESSIVCMAC512: AESSIVCMAC512,
+	AlgAESGCMSIV128:  "AES-GCM-SIV-128",
+	AlgAESGCMSIV256:  "AES-GCM-SIV-256",
+}
+
+var (
+	// ErrUnknownAlgorithm is returned for an algorithm identifier which isn't
+	// in the table.
+	ErrUnknownAlgorithm = errors.New("siv: unknown algorithm identifier")
+
+	// ErrAlgorithmMismatch is returned by NewSelfDescribing when the AEAD
+	// describes itself as a different algorithm from the identifier.
+	ErrAlgorithmMismatch = errors.New("siv: AEAD does not match algorithm identifier")
+)
+
+// AlgorithmName returns the name of the algorithm with the given identifier.
+func AlgorithmName(id byte) (string, bool) {
+	if int(id) >= len(algorithmNames) || algorithmNames[id] == "" {
+		return "", false
+	}
+	return algorithmNames[id], true
+}
+
+// NewSelfDescribing returns an AEAD whose ciphertexts are prefixed with the
+// algorithm identifier id, for OpenAuto. The identifier is also bound to the
+// ciphertext as associated data, so it can't be stripped or swapped for
+// another. If aead implements Info, its algorithm must match id.
+//
+// To open in place, pass ciphertext[1:1] as dst, as the plaintext starts
+// after the prefix.
+func NewSelfDescribing(aead cipher.AEAD, id byte) (cipher.AEAD, error) {
+	name, ok := AlgorithmName(id)
+	if !ok {
+		return nil, ErrUnknownAlgorithm
+	}
+	if info, ok := aead.(Info); ok && info.Algorithm() != name {
+		return nil, fmt.Errorf("%w: %s is not %s", ErrAlgorithmMismatch, info.Algorithm(), name)
+	}
+
+	return &selfDescribing{aead: bindAlgorithm(aead, id), id: id}, nil
+}
+
+// OpenAuto opens a self-describing ciphertext with the AEAD which keyProvider
+// returns for its algorithm identifier. Ciphertexts are opened with a nil
+// nonce.
+func OpenAuto(keyProvider func(alg byte) (cipher.AEAD, error), ciphertext, ad []byte) ([]byte, error) {
+	if len(ciphertext) == 0 {
+		return nil, ErrAuthentication
+	}
+
+	id := ciphertext[0]
+	if _, ok := AlgorithmName(id); !ok {
+		return nil, ErrUnknownAlgorithm
+	}
+
+	aead, err := keyProvider(id)
+	if err != nil {
+		return nil, err
+	}
+	return bindAlgorithm(aead, id).Open(nil, nil, ciphertext[1:], ad)
+}
+
+func bindAlgorithm(aead cipher.AEAD, id byte) cipher.AEAD {
+	return NewContext(aead, []byte("siv self-describing"), []byte{id})
+}
+
+type selfDescribing struct {
+	aead cipher.AEAD
+	id   byte
+}
+
+func (s *selfDescribing) NonceSize() int {
+	return s.aead.NonceSize()
+}
+
+func (s *selfDescribing) Overhead() int {
+	return s.aead.Overhead() + 1
+}
+
+func (s *selfDescribing) Seal(dst, nonce, plaintext, data []byte) []byte {
+	ret := s.aead.Seal(dst, nonce, plaintext, data)
+	ret = append(ret, 0)
+	copy(ret[len(dst)+1:], ret[len(dst):])
+	ret[len(dst)] = s.id
+	return ret
+}
+
+func (s *selfDescribing) Open(dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	if len(ciphertext) == 0 || ciphertext[0] != s.id {
+		return nil, ErrAuthentication
+	}
+	return s.aead.Open(dst, nonce, ciphertext[1:], data)
+}
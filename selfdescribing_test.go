@@ -0,0 +1,211 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"testing"
+)
+
+// fakeGCMSIV stands in for an AES-GCM-SIV AEAD from another package: AES-GCM
+// with a fixed nonce, which like GCM-SIV is a different construction with the
+// same key sizes.
+type fakeGCMSIV struct {
+	cipher.AEAD
+}
+
+func newFakeGCMSIV(key []byte) cipher.AEAD {
+	b, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCM(b)
+	return fakeGCMSIV{gcm}
+}
+
+func (f fakeGCMSIV) NonceSize() int {
+	return 0
+}
+
+func (f fakeGCMSIV) Seal(dst, _, plaintext, data []byte) []byte {
+	return f.AEAD.Seal(dst, make([]byte, 12), plaintext, data)
+}
+
+func (f fakeGCMSIV) Open(dst, _, ciphertext, data []byte) ([]byte, error) {
+	return f.AEAD.Open(dst, make([]byte, 12), ciphertext, data)
+}
+
+func TestSelfDescribingRoundTrip(t *testing.T) {
+	key := testKey(0, 64)
+	aead512 := NewAEAD512(Key512(key))
+	gcmsiv := newFakeGCMSIV(key[:32])
+
+	keys := func(alg byte) (cipher.AEAD, error) {
+		switch alg {
+		case AlgAESSIVCMAC512:
+			return aead512, nil
+		case AlgAESGCMSIV256:
+			return gcmsiv, nil
+		}
+		return nil, errors.New("no key")
+	}
+
+	for _, v := range []struct {
+		aead cipher.AEAD
+		id   byte
+	}{
+		{aead512, AlgAESSIVCMAC512},
+		{gcmsiv, AlgAESGCMSIV256},
+	} {
+		sd, err := NewSelfDescribing(v.aead, v.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ciphertext := sd.Seal([]byte("dst"), nil, []byte("yay"), []byte("ad"))
+		if string(ciphertext[:3]) != "dst" || ciphertext[3] != v.id {
+			t.Errorf("Ciphertext was %x, but expected prefix %x", ciphertext, v.id)
+		}
+
+		if len(ciphertext) != 3+3+sd.Overhead() {
+			t.Errorf("Ciphertext length was %d, but expected %d", len(ciphertext), 3+3+sd.Overhead())
+		}
+
+		actual, err := OpenAuto(keys, ciphertext[3:], []byte("ad"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(actual) != "yay" {
+			t.Errorf("Plaintext was %q, but expected %q", actual, "yay")
+		}
+
+		actual, err = sd.Open(nil, nil, ciphertext[3:], []byte("ad"))
+		if err != nil || string(actual) != "yay" {
+			t.Errorf("Plaintext was %q, %v, but expected %q", actual, err, "yay")
+		}
+	}
+}
+
+func TestSelfDescribingInPlace(t *testing.T) {
+	sd, _ := NewSelfDescribing(NewAEAD256(Key256{1}), AlgAESSIVCMAC256)
+
+	buf := make([]byte, 5, 64)
+	copy(buf, "hello")
+	ciphertext := sd.Seal(buf[:0], nil, buf, nil)
+
+	actual, err := sd.Open(ciphertext[1:1], nil, ciphertext, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(actual) != "hello" {
+		t.Errorf("Plaintext was %q, but expected %q", actual, "hello")
+	}
+}
+
+func TestSelfDescribingConfusion(t *testing.T) {
+	key := testKey(0, 32)
+	siv256 := NewAEAD256(Key256(key))
+	gcmsiv := newFakeGCMSIV(key)
+
+	// The same key under every identifier, so only the binding of the
+	// identifier can tell them apart.
+	same := func(alg byte) (cipher.AEAD, error) {
+		if alg == AlgAESGCMSIV256 || alg == AlgAESGCMSIV128 {
+			return gcmsiv, nil
+		}
+		return siv256, nil
+	}
+
+	sd, _ := NewSelfDescribing(siv256, AlgAESSIVCMAC256)
+	sivBlob := sd.Seal(nil, nil, []byte("yay"), nil)
+
+	sdGCM, _ := NewSelfDescribing(gcmsiv, AlgAESGCMSIV256)
+	gcmBlob := sdGCM.Seal(nil, nil, []byte("yay"), nil)
+
+	for _, v := range []struct {
+		name string
+		blob []byte
+		id   byte
+	}{
+		{"SIV relabelled as SIV-512", sivBlob, AlgAESSIVCMAC512},
+		{"SIV relabelled as GCM-SIV", sivBlob, AlgAESGCMSIV256},
+		{"GCM-SIV relabelled as SIV", gcmBlob, AlgAESSIVCMAC256},
+		{"GCM-SIV relabelled as GCM-SIV-128", gcmBlob, AlgAESGCMSIV128},
+	} {
+		relabelled := append([]byte{v.id}, v.blob[1:]...)
+		if actual, err := OpenAuto(same, relabelled, nil); err == nil {
+			t.Errorf("%s: plaintext returned instead of error: %x", v.name, actual)
+		}
+	}
+
+	// Stripping the prefix doesn't yield a plain SIV ciphertext.
+	if actual, err := siv256.Open(nil, nil, sivBlob[1:], nil); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v (plaintext %x)", err, ErrAuthentication, actual)
+	}
+
+	// Nor does the self-describing AEAD open another identifier's blob.
+	if actual, err := sd.Open(nil, nil, append([]byte{AlgAESSIVCMAC384}, sivBlob[1:]...), nil); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v (plaintext %x)", err, ErrAuthentication, actual)
+	}
+}
+
+func TestSelfDescribingErrors(t *testing.T) {
+	aead := NewAEAD256(Key256{1})
+	keys := func(byte) (cipher.AEAD, error) { return aead, nil }
+
+	for _, id := range []byte{0, 4, 0x12, 0xff} {
+		if _, err := OpenAuto(keys, []byte{id, 1, 2, 3}, nil); err != ErrUnknownAlgorithm {
+			t.Errorf("Error for %#x was %v, but expected %v", id, err, ErrUnknownAlgorithm)
+		}
+
+		if _, err := NewSelfDescribing(aead, id); err != ErrUnknownAlgorithm {
+			t.Errorf("Error for %#x was %v, but expected %v", id, err, ErrUnknownAlgorithm)
+		}
+	}
+
+	if _, err := OpenAuto(keys, nil, nil); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+
+	if _, err := NewSelfDescribing(aead, AlgAESSIVCMAC512); !errors.Is(err, ErrAlgorithmMismatch) {
+		t.Errorf("Error was %v, but expected %v", err, ErrAlgorithmMismatch)
+	}
+
+	failing := errors.New("no key")
+	if _, err := OpenAuto(func(byte) (cipher.AEAD, error) { return nil, failing }, []byte{1, 2}, nil); err != failing {
+		t.Errorf("Error was %v, but expected %v", err, failing)
+	}
+}
+
+func TestAlgorithmTable(t *testing.T) {
+	// The table is frozen; these assignments must never change.
+	for id, name := range map[byte]string{
+		0x01: "AES-SIV-CMAC-256",
+		0x02: "AES-SIV-CMAC-384",
+		0x03: "AES-SIV-CMAC-512",
+		0x10: "AES-GCM-SIV-128",
+		0x11: "AES-GCM-SIV-256",
+	} {
+		if actual, ok := AlgorithmName(id); !ok || actual != name {
+			t.Errorf("Name of %#x was %q, but expected %q", id, actual, name)
+		}
+	}
+
+	n := 0
+	for id := 0; id < 256; id++ {
+		if _, ok := AlgorithmName(byte(id)); ok {
+			n++
+		}
+	}
+	if n != 5 {
+		t.Errorf("Table had %d entries, but expected %d", n, 5)
+	}
+
+	// The binding is pinned too, so stored ciphertexts stay readable.
+	sd, _ := NewSelfDescribing(NewAEAD256(Key256{1}), AlgAESSIVCMAC256)
+	expected := NewAEAD256(Key256{1}).(multiAEAD).SealMulti([]byte{1}, []byte("yay"),
+		[]byte("siv context\x00\x00\x00\x02"), []byte("siv self-describing"), []byte{1}, []byte("ad"), nil)
+	if actual := sd.Seal(nil, nil, []byte("yay"), []byte("ad")); !bytes.Equal(actual, expected) {
+		t.Errorf("Ciphertext was %x, but expected %x", actual, expected)
+	}
+}
@@ -0,0 +1,66 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"errors"
+	"io"
+)
+
+var (
+	// ErrShareCount is returned when fewer than two key shares are given.
+	ErrShareCount = errors.New("siv: at least two key shares are required")
+
+	// ErrShareLength is returned when key shares differ in length.
+	ErrShareLength = errors.New("siv: key shares differ in length")
+)
+
+// NewFromShares returns a new SIV AEAD, as New does, with the key formed by
+// XORing together two or more equal-length shares. The combined key is wiped
+// once the ciphers have been constructed, so it exists only briefly and only
+// in this process.
+//
+// Shares produced by SplitKey are n-of-n: each share, and any combination of
+// fewer than all of them, is uniformly random and independent of the key, so
+// reveals nothing about it.
+func NewFromShares(alg func([]byte) (cipher.Block, error), shares ...[]byte) (cipher.AEAD, error) {
+	if len(shares) < 2 {
+		return nil, ErrShareCount
+	}
+
+	key := make([]byte, len(shares[0]))
+	defer wipe(key)
+
+	for _, s := range shares {
+		if len(s) != len(key) {
+			return nil, ErrShareLength
+		}
+		subtle.XORBytes(key, key, s)
+	}
+
+	return New(key, alg)
+}
+
+// SplitKey splits key into n shares, using random bytes from rand, such that
+// NewFromShares with all n shares constructs the same AEAD as New with key.
+// The first n-1 shares are random and the last is the XOR of key with all of
+// them.
+func SplitKey(rand io.Reader, key []byte, n int) ([][]byte, error) {
+	if n < 2 {
+		return nil, ErrShareCount
+	}
+
+	shares := make([][]byte, n)
+	last := append([]byte{}, key...)
+	for i := range shares[:n-1] {
+		shares[i] = make([]byte, len(key))
+		if _, err := io.ReadFull(rand, shares[i]); err != nil {
+			wipe(last)
+			return nil, err
+		}
+		subtle.XORBytes(last, last, shares[i])
+	}
+	shares[n-1] = last
+
+	return shares, nil
+}
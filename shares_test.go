@@ -0,0 +1,95 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestNewFromShares(t *testing.T) {
+	key := testKey(0x5a, 64)
+	expected, _ := New(key, aes.NewCipher)
+	ciphertext := expected.Seal(nil, nil, []byte("split"), nil)
+
+	for _, n := range []int{2, 3, 5} {
+		shares, err := SplitKey(rand.Reader, key, n)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(shares) != n {
+			t.Fatalf("Got %d shares, but expected %d", len(shares), n)
+		}
+
+		aead, err := NewFromShares(aes.NewCipher, shares...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual := aead.Seal(nil, nil, []byte("split"), nil); !bytes.Equal(actual, ciphertext) {
+			t.Errorf("Ciphertext was %x, but expected %x", actual, ciphertext)
+		}
+
+		// A single share is just random bytes, so can be used as a key, but not
+		// the right one.
+		for _, share := range shares {
+			if bytes.Equal(share, key) {
+				t.Errorf("Share equals the key")
+			}
+
+			single, err := New(share, aes.NewCipher)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if p, err := single.Open(nil, nil, ciphertext, nil); err == nil {
+				t.Fatalf("Plaintext returned instead of error: %x", p)
+			}
+		}
+
+		// So are all but one of the shares.
+		if n > 2 {
+			partial, _ := NewFromShares(aes.NewCipher, shares[1:]...)
+			if p, err := partial.Open(nil, nil, ciphertext, nil); err == nil {
+				t.Fatalf("Plaintext returned instead of error: %x", p)
+			}
+		}
+	}
+}
+
+func TestNewFromSharesErrors(t *testing.T) {
+	if _, err := NewFromShares(aes.NewCipher, make([]byte, 32)); err != ErrShareCount {
+		t.Errorf("Error was %v, but expected %v", err, ErrShareCount)
+	}
+
+	if _, err := NewFromShares(aes.NewCipher, make([]byte, 32), make([]byte, 32), make([]byte, 48)); err != ErrShareLength {
+		t.Errorf("Error was %v, but expected %v", err, ErrShareLength)
+	}
+
+	if _, err := SplitKey(rand.Reader, make([]byte, 32), 1); err != ErrShareCount {
+		t.Errorf("Error was %v, but expected %v", err, ErrShareCount)
+	}
+
+	if _, err := SplitKey(bytes.NewReader(make([]byte, 8)), make([]byte, 32), 2); err == nil {
+		t.Errorf("SplitKey succeeded with a short random source")
+	}
+}
+
+func TestSplitKeyRand(t *testing.T) {
+	cr := &countingReader{}
+	shares, err := SplitKey(cr, testKey(1, 48), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cr.n != 2*48 {
+		t.Errorf("Read %d random bytes, but expected %d", cr.n, 2*48)
+	}
+	if !bytes.Equal(shares[0], testKey(0, 48)) || !bytes.Equal(shares[1], testKey(48, 48)) {
+		t.Errorf("Shares were %x and %x, but expected the random bytes in order", shares[0], shares[1])
+	}
+
+	if _, err := SplitKey(&countingReader{limit: 60, err: errHSM}, testKey(1, 48), 3); err != errHSM {
+		t.Errorf("Error was %v, but expected %v", err, errHSM)
+	}
+}
@@ -1,6 +1,17 @@
 // Package siv provides an implementation of the SIV-CMAC AEAD as described in
 // RFC 5297. SIV-CMAC does not require a nonce, allowing for both deterministic
 // encryption and resistance to nonce re- or misuse.
+//
+// Seal and Open pass the associated data and then the nonce to S2V as separate
+// components, skipping either if it is nil, as RFC 5297 section 3 describes
+// for nonce-based use. This matches other implementations such as the
+// RustCrypto aes-siv crate's Aes*SivAead types, which always include both, so
+// to interoperate with them pass a non-nil empty slice for empty associated
+// data.
+//
+// The package and its dependencies are pure Go, and are tested on js/wasm and
+// wasip1/wasm as well as the usual targets. They use only the parts of reflect
+// and unsafe which TinyGo supports; see examples/wasm for use in a browser.
 package siv
 
 import (
@@ -8,13 +19,106 @@ import (
 	"crypto/subtle"
 	"errors"
 	"hash"
+	"math"
+	"reflect"
+	"sync"
 
 	"github.com/ebfe/cmac"
+	"github.com/stripe/siv-go/gf128"
 )
 
 // New returns a new SIV AEAD with the given key and encryption algorithm. The
-// key must be twice the key size of the underlying algorithm.
-func New(key []byte, alg func([]byte) (cipher.Block, error)) (cipher.AEAD, error) {
+// key must be twice the key size of the underlying algorithm; the first half
+// keys S2V and the second half keys CTR mode. For AES, prefer NewAEAD256,
+// NewAEAD384, and NewAEAD512, whose typed keys catch size mistakes at compile
+// time.
+//
+// The algorithm need not be AES, but it must be a block cipher (a keyed
+// permutation) with a 128-bit block, as S2V's doubling and the CTR mode
+// counter are defined only for that size. Camellia, ARIA, and SM4 are all
+// suitable. Only the cipher's Encrypt method is used, and it must be safe to
+// call on a single block at a time with dst and src either equal or
+// non-overlapping.
+//
+// Like the standard library's AEADs, the returned AEAD encrypts or decrypts in
+// place if dst is plaintext[:0] or ciphertext[:0], and panics if the output
+// would otherwise overlap the input or the associated data. If Open fails,
+// the output region of dst may have been overwritten.
+//
+// Options change the AEAD's behaviour or ciphertext format, and are checked
+// for invalid values and conflicts before the key is used. Without options,
+// or with only AllowEqualHalves, the AEAD is a *SIV, which also supports
+// multiple associated data components, as SealMulti and OpenMulti.
+//
+// New rejects an all-zero key with ErrZeroKey, and a key whose two halves are
+// identical with ErrEqualHalves unless AllowEqualHalves is given.
+//
+// In FIPS mode (see EnableFIPSMode), only crypto/aes is accepted.
+func New(key []byte, alg BlockFactory, opts ...Option) (cipher.AEAD, error) {
+	var c config
+	for _, opt := range opts {
+		if err := opt(&c); err != nil {
+			return nil, err
+		}
+	}
+
+	s, err := newChecked(key, alg, c.allowEqualHalves)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.plain() {
+		return s, nil
+	}
+	return &configured{s: s, config: c}, nil
+}
+
+// NewSIV is like New without options, but returns the concrete *SIV, so that
+// its methods beyond cipher.AEAD can be called without a type assertion.
+func NewSIV(key []byte, alg BlockFactory) (*SIV, error) {
+	return newChecked(key, alg, false)
+}
+
+// newChecked checks key and the FIPS policy, and returns a new SIV.
+func newChecked(key []byte, alg BlockFactory, allowEqualHalves bool) (*SIV, error) {
+	if err := fipsSelfTest(); err != nil {
+		return nil, err
+	}
+	if err := checkKey(key, allowEqualHalves); err != nil {
+		return nil, err
+	}
+
+	s, err := newSIV(key, alg)
+	if err != nil {
+		return nil, err
+	}
+	if FIPSMode() && reflect.TypeOf(s.enc) != aesType {
+		return nil, ErrFIPSPolicy
+	}
+	return s, nil
+}
+
+// checkKey rejects keys which are all zeros, as from an unfilled template,
+// and, unless allowEqualHalves is set, keys whose S2V and CTR halves are
+// identical, which SIV's security proof assumes are independent. It runs in
+// time independent of the key's contents.
+func checkKey(key []byte, allowEqualHalves bool) error {
+	var acc byte
+	for _, b := range key {
+		acc |= b
+	}
+	if subtle.ConstantTimeByteEq(acc, 0) == 1 {
+		return ErrZeroKey
+	}
+
+	half := len(key) / 2
+	if !allowEqualHalves && subtle.ConstantTimeCompare(key[:half], key[half:2*half]) == 1 {
+		return ErrEqualHalves
+	}
+	return nil
+}
+
+func newSIV(key []byte, alg BlockFactory) (*SIV, error) {
 	mac, err := alg(key[:(len(key) / 2)])
 	if err != nil {
 		return nil, err
@@ -25,57 +129,176 @@ func New(key []byte, alg func([]byte) (cipher.Block, error)) (cipher.AEAD, error
 		return nil, err
 	}
 
-	return &siv{
-		enc: enc,
-		mac: mac,
+	if mac.BlockSize() != blockSize || enc.BlockSize() != blockSize {
+		return nil, errBlockSize
+	}
+
+	return &SIV{
+		enc:     enc,
+		mac:     mac,
+		keySize: len(key),
 	}, nil
 }
 
-type siv struct {
+// SIV is the AEAD returned by New without options, and by NewSIV. Besides
+// cipher.AEAD and Info, it supports multiple associated data components
+// (SealMulti and OpenMulti), error-returning variants of Seal and Open
+// (SealAppend and OpenAppend), streaming output (SealTo, OpenToWriter, and
+// OpenSeeker), verification without output (OpenDiscard), incremental sealing
+// and opening (NewSealer and NewOpener), and key fingerprints (Fingerprint).
+// It is safe for concurrent use.
+type SIV struct {
 	enc, mac cipher.Block
+	keySize  int
+
+	verifiers sync.Pool // of *verifier, for OpenDiscard
 }
 
-func (*siv) NonceSize() int {
+var (
+	_ cipher.AEAD = (*SIV)(nil)
+	_ Info        = (*SIV)(nil)
+)
+
+// NonceSize returns zero: nonces are optional, and may be of any length.
+func (*SIV) NonceSize() int {
 	return 0
 }
 
-func (s *siv) Overhead() int {
+// Overhead returns the size of the tag, which is the cipher's block size.
+func (s *SIV) Overhead() int {
 	return s.mac.BlockSize()
 }
 
-func (s *siv) Open(dst, nonce, ciphertext, data []byte) ([]byte, error) {
-	v, ciphertext := ciphertext[:s.Overhead()], ciphertext[s.Overhead():]
-	plaintext := make([]byte, len(ciphertext))
+// Open implements cipher.AEAD, passing data and then nonce to S2V.
+func (s *SIV) Open(dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	return panicOnMisuse(s.open(dst, ciphertext, data, nonce))
+}
+
+// Seal implements cipher.AEAD, passing data and then nonce to S2V.
+func (s *SIV) Seal(dst, nonce, plaintext, data []byte) []byte {
+	ret, _ := panicOnMisuse(s.seal(dst, plaintext, data, nonce))
+	return ret
+}
+
+// OpenAppend is like Open, but returns an error rather than panicking if the
+// buffers overlap incorrectly.
+func (s *SIV) OpenAppend(dst, nonce, ciphertext, data []byte) ([]byte, error) {
+	return s.open(dst, ciphertext, data, nonce)
+}
+
+// SealAppend is like Seal, but returns an error rather than panicking if the
+// plaintext or output is too large or the buffers overlap incorrectly.
+func (s *SIV) SealAppend(dst, nonce, plaintext, data []byte) ([]byte, error) {
+	return s.seal(dst, plaintext, data, nonce)
+}
+
+// OpenMulti decrypts and authenticates ciphertext against a vector of
+// associated data components, as described in RFC 5297 section 2.6. Nil
+// components are skipped. It follows the same aliasing rules as Open.
+func (s *SIV) OpenMulti(dst, ciphertext []byte, data ...[]byte) ([]byte, error) {
+	return panicOnMisuse(s.open(dst, ciphertext, data...))
+}
+
+// SealMulti encrypts and authenticates plaintext along with a vector of
+// associated data components, as described in RFC 5297 section 2.6. Nil
+// components are skipped. Seal(dst, nonce, plaintext, data) is equivalent to
+// SealMulti(dst, plaintext, data, nonce), and it follows the same aliasing
+// rules as Seal.
+func (s *SIV) SealMulti(dst, plaintext []byte, data ...[]byte) []byte {
+	ret, _ := panicOnMisuse(s.seal(dst, plaintext, data...))
+	return ret
+}
+
+func (s *SIV) open(dst, ciphertext []byte, data ...[]byte) ([]byte, error) {
+	if len(ciphertext) < s.Overhead() {
+		return nil, ErrAuthentication
+	}
+
+	ret, out, err := sliceForAppend(dst, len(ciphertext)-s.Overhead())
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAliasing(out, ciphertext, data); err != nil {
+		return nil, err
+	}
+
+	// Copy the tag, which decrypting in place may overwrite, and move the
+	// ciphertext body to the start of the output before decrypting it there.
+	v := append([]byte{}, ciphertext[:s.Overhead()]...)
+	copy(out, ciphertext[s.Overhead():])
 	ctr := cipher.NewCTR(s.enc, ctr(v))
-	ctr.XORKeyStream(plaintext, ciphertext)
+	ctr.XORKeyStream(out, out)
 
 	h, _ := cmac.NewWithCipher(s.mac)
-	vP := s2v(h, data, nonce, plaintext)
+	vP := s2v(h, components(data, out)...)
 
 	if subtle.ConstantTimeCompare(v, vP) != 1 {
-		return nil, errOpen
+		wipe(out)
+		return nil, ErrAuthentication
 	}
 
-	return append(dst, plaintext...), nil
+	return ret, nil
 }
 
-func (s *siv) Seal(dst, nonce, plaintext, data []byte) []byte {
-	h, _ := cmac.NewWithCipher(s.mac)
+func (s *SIV) seal(dst, plaintext []byte, data ...[]byte) ([]byte, error) {
+	if len(plaintext) > MaxPlaintextSize {
+		return nil, ErrPlaintextTooLarge
+	}
+
+	ret, out, err := sliceForAppend(dst, s.Overhead()+len(plaintext))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkAliasing(out, plaintext, data); err != nil {
+		return nil, err
+	}
 
-	v := s2v(h, data, nonce, plaintext)
+	h, _ := cmac.NewWithCipher(s.mac)
+	v := s2v(h, components(data, plaintext)...)
 
+	// The plaintext is moved after the tag before encrypting it in place, as
+	// it may share the output's storage.
+	copy(out[len(v):], plaintext)
+	copy(out, v)
 	ctr := cipher.NewCTR(s.enc, ctr(v))
-	result := make([]byte, len(v)+len(plaintext))
-	copy(result, v)
-	ctr.XORKeyStream(result[len(v):], plaintext)
+	ctr.XORKeyStream(out[len(v):], out[len(v):])
 
-	return append(dst, result...)
+	return ret, nil
 }
 
 var (
-	errOpen = errors.New("message authentication failed")
+	// ErrAuthentication is returned when a ciphertext fails to authenticate.
+	ErrAuthentication = errors.New("message authentication failed")
+
+	// ErrZeroKey is returned by New when the key is all zeros, which is
+	// almost always a key that was never filled in.
+	ErrZeroKey = errors.New("siv: key is all zeros")
+
+	// ErrEqualHalves is returned by New when the S2V and CTR halves of the
+	// key are identical, unless AllowEqualHalves is given.
+	ErrEqualHalves = errors.New("siv: key halves are identical")
+
+	errBlockSize = errors.New("siv: cipher block size must be 128 bits")
 )
 
+// blockSize is the only block size SIV is defined for.
+const blockSize = 16
+
+// MaxPlaintextSize is the largest plaintext Seal accepts, so that the
+// ciphertext's length fits in an int: just under 2 GiB on 32-bit platforms.
+// CTR mode imposes no lower limit, as even on 64-bit platforms a message has
+// fewer than 2^60 blocks, far short of the 2^64 counter values available
+// after SIV clears two of the counter's bits.
+const MaxPlaintextSize = math.MaxInt - blockSize
+
+// components returns the S2V inputs for the given associated data and
+// plaintext without appending to the caller's slice.
+func components(data [][]byte, plaintext []byte) [][]byte {
+	c := make([][]byte, 0, len(data)+1)
+	c = append(c, data...)
+	return append(c, plaintext)
+}
+
 func ctr(v []byte) []byte {
 	q := make([]byte, len(v))
 	copy(q, v)
@@ -85,12 +308,28 @@ func ctr(v []byte) []byte {
 }
 
 func s2v(h hash.Hash, data ...[]byte) []byte {
+	d := s2vData(h, data[:len(data)-1]...)
+
+	v := data[len(data)-1]
+	if len(v) >= h.BlockSize() {
+		prefix := len(v) - len(d)
+		_, _ = h.Write(v[:prefix])
+		v = v[prefix:]
+	}
+
+	return s2vFinal(h, d, v)
+}
+
+// s2vData processes all but the final S2V component, returning the
+// intermediate value D.
+func s2vData(h hash.Hash, data ...[]byte) []byte {
 	d := make([]byte, h.BlockSize())
 	_, _ = h.Write(d)
 	d = h.Sum(d[:0])
 	h.Reset()
 
-	for _, v := range data[:len(data)-1] {
+	var sum [blockSize]byte
+	for _, v := range data {
 		if v == nil {
 			continue
 		}
@@ -98,32 +337,27 @@ func s2v(h hash.Hash, data ...[]byte) []byte {
 		dbl(d)
 
 		_, _ = h.Write(v)
-
-		for i, v := range h.Sum(nil) {
-			d[i] ^= v
-		}
-
+		subtle.XORBytes(d, d, h.Sum(sum[:0]))
 		h.Reset()
 	}
 
-	v := data[len(data)-1]
+	return d
+}
 
-	if len(v) >= h.BlockSize() {
+// s2vFinal processes the end of the final S2V component. If the component is
+// at least a block long, all but its last block must already have been written
+// to h and end must be its last block; otherwise end is the whole component.
+func s2vFinal(h hash.Hash, d, end []byte) []byte {
+	if len(end) == len(d) {
 		// xorend
-		prefix := len(v) - len(d)
-		_, _ = h.Write(v[:prefix])
-		for i := range d {
-			d[i] ^= v[prefix+i]
-		}
+		subtle.XORBytes(d, d, end)
 		_, _ = h.Write(d)
 	} else {
 		dbl(d)
 
 		// pad and xor
-		for i, v := range v {
-			d[i] ^= v
-		}
-		d[len(v)] ^= 0x80
+		subtle.XORBytes(d, d, end)
+		d[len(end)] ^= 0x80
 
 		_, _ = h.Write(d)
 	}
@@ -131,20 +365,7 @@ func s2v(h hash.Hash, data ...[]byte) []byte {
 	return h.Sum(d[:0])
 }
 
+// dbl doubles the block b in GF(2^128).
 func dbl(b []byte) {
-	shifted := (b[0] >> 7) == 1
-	shiftLeft(b)
-	if shifted {
-		b[len(b)-1] ^= 0x87
-	}
-}
-
-func shiftLeft(b []byte) {
-	overflow := byte(0)
-	for i := len(b) - 1; i >= 0; i-- {
-		v := b[i]
-		b[i] <<= 1
-		b[i] |= overflow
-		overflow = (v & 0x80) >> 7
-	}
+	gf128.Dbl((*[blockSize]byte)(b))
 }
@@ -7,15 +7,95 @@ import (
 	"testing"
 )
 
+// testKey returns an n-byte key whose bytes count up from b, so that keys
+// with different b differ, and none is all zeros or has identical halves.
+func testKey(b byte, n int) []byte {
+	key := make([]byte, n)
+	for i := range key {
+		key[i] = b + byte(i)
+	}
+	return key
+}
+
 func TestBadKeySize(t *testing.T) {
-	aead, err := New(make([]byte, 16), aes.NewCipher)
+	aead, err := New(testKey(0, 16)[:15], aes.NewCipher)
 	if err == nil {
 		t.Fatalf("AEAD returned instead of error: %v", aead)
 	}
 }
 
+func TestNewSIV(t *testing.T) {
+	s, err := NewSIV(testKey(0, 32), aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	if concrete, ok := aead.(*SIV); !ok || !bytes.Equal(concrete.Fingerprint(), s.Fingerprint()) {
+		t.Errorf("New returned %T, but expected the same *SIV", aead)
+	}
+
+	plaintext := []byte("hello")
+	if actual, expected := s.SealMulti(nil, plaintext, []byte("ad")), aead.Seal(nil, nil, plaintext, []byte("ad")); !bytes.Equal(actual, expected) {
+		t.Errorf("Ciphertext was %x, but expected %x", actual, expected)
+	}
+
+	if s, err := NewSIV(testKey(0, 16), aes.NewCipher); err == nil {
+		t.Fatalf("AEAD returned instead of error: %v", s)
+	}
+}
+
+func TestWeakKeys(t *testing.T) {
+	for _, n := range []int{32, 48, 64} {
+		if aead, err := New(make([]byte, n), aes.NewCipher); err != ErrZeroKey {
+			t.Errorf("%d: error was %v, but expected %v (AEAD %v)", n, err, ErrZeroKey, aead)
+		}
+		if aead, err := New(make([]byte, n), aes.NewCipher, AllowEqualHalves()); err != ErrZeroKey {
+			t.Errorf("%d: error was %v, but expected %v (AEAD %v)", n, err, ErrZeroKey, aead)
+		}
+		if aead, err := NewSIV(make([]byte, n), aes.NewCipher); err != ErrZeroKey {
+			t.Errorf("%d: error was %v, but expected %v (AEAD %v)", n, err, ErrZeroKey, aead)
+		}
+
+		half := testKey(1, n/2)
+		equal := append(append([]byte{}, half...), half...)
+		if aead, err := New(equal, aes.NewCipher); err != ErrEqualHalves {
+			t.Errorf("%d: error was %v, but expected %v (AEAD %v)", n, err, ErrEqualHalves, aead)
+		}
+		if aead, err := New(equal, aes.NewCipher, WithPadding(16)); err != ErrEqualHalves {
+			t.Errorf("%d: error was %v, but expected %v (AEAD %v)", n, err, ErrEqualHalves, aead)
+		}
+		if aead, err := NewSIV(equal, aes.NewCipher); err != ErrEqualHalves {
+			t.Errorf("%d: error was %v, but expected %v (AEAD %v)", n, err, ErrEqualHalves, aead)
+		}
+
+		// The escape hatch leaves the AEAD unconfigured.
+		aead, err := New(equal, aes.NewCipher, AllowEqualHalves())
+		if _, ok := aead.(*SIV); err != nil || !ok {
+			t.Errorf("%d: New returned %T, %v, but expected a *SIV", n, aead, err)
+		}
+		if aead, err := New(equal, aes.NewCipher, AllowEqualHalves(), WithPadding(16)); err != nil || aead.Overhead() != 32 {
+			t.Errorf("%d: New returned %v, %v", n, aead, err)
+		}
+
+		// A single set bit anywhere is enough.
+		for _, i := range []int{0, n/2 - 1, n / 2, n - 1} {
+			key := make([]byte, n)
+			key[i] = 0x80
+			if _, err := New(key, aes.NewCipher); err != nil {
+				t.Errorf("%d: bit %d: %v", n, i, err)
+			}
+		}
+		if _, err := New(testKey(0, n), aes.NewCipher); err != nil {
+			t.Errorf("%d: %v", n, err)
+		}
+	}
+
+	mustPanic(t, func() { NewAEAD256(Key256{}) })
+}
+
 func TestNoNonceRequired(t *testing.T) {
-	aead, _ := New(make([]byte, 32), aes.NewCipher)
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
 
 	if v, want := aead.NonceSize(), 0; v != want {
 		t.Errorf("Nonce size was %d, but expected %d", v, want)
@@ -23,7 +103,7 @@ func TestNoNonceRequired(t *testing.T) {
 }
 
 func TestOverhead(t *testing.T) {
-	aead, _ := New(make([]byte, 32), aes.NewCipher)
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
 
 	if v, want := aead.Overhead(), aes.BlockSize; v != want {
 		t.Errorf("Overhead was %d, but expected %d", v, want)
@@ -48,6 +128,42 @@ func TestDeterministicEncryption(t *testing.T) {
 	}
 }
 
+func TestSealMulti(t *testing.T) {
+	// https://tools.ietf.org/html/rfc5297#appendix-A.2
+	key, _ := hex.DecodeString("7f7e7d7c7b7a79787776757473727170404142434445464748494a4b4c4d4e4f")
+	ad1, _ := hex.DecodeString("00112233445566778899aabbccddeeffdeaddadadeaddadaffeeddccbbaa99887766554433221100")
+	ad2, _ := hex.DecodeString("102030405060708090a0")
+	nonce, _ := hex.DecodeString("09f911029d74e35bd84156c5635688c0")
+	plaintext, _ := hex.DecodeString("7468697320697320736f6d6520706c61696e7465787420746f20656e6372797074207573696e67205349562d414553")
+	ciphertext, _ := hex.DecodeString("7bdb6e3b432667eb06f4d14bff2fbd0fcb900f2fddbe404326601965c889bf17dba77ceb094fa663b7a3f748ba8af829ea64ad544a272e9c485b62a3fd5c0d")
+
+	aead, err := New(key, aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := aead.(multiAEAD)
+
+	actual := m.SealMulti(nil, plaintext, ad1, ad2, nonce)
+	if !bytes.Equal(actual, ciphertext) {
+		t.Errorf("Ciphertext was %x, but expected %x", actual, ciphertext)
+	}
+
+	actual, err = m.OpenMulti(nil, ciphertext, ad1, ad2, nonce)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(actual, plaintext) {
+		t.Errorf("Plaintext was %x, but expected %x", actual, plaintext)
+	}
+
+	actual, err = m.OpenMulti(nil, ciphertext, ad2, ad1, nonce)
+	if err == nil {
+		t.Fatalf("Plaintext returned instead of error: %x", actual)
+	}
+}
+
 func TestRoundTrip(t *testing.T) {
 	key, _ := hex.DecodeString("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
 	data, _ := hex.DecodeString("101112131415161718191a1b1c1d1e1f2021222324252627")
@@ -110,6 +226,17 @@ func TestRoundTripBadCiphertext(t *testing.T) {
 	}
 }
 
+func TestOpenShortCiphertext(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+
+	for i := 0; i < aead.Overhead(); i++ {
+		actual, err := aead.Open(nil, nil, make([]byte, i), nil)
+		if err != ErrAuthentication {
+			t.Errorf("Error was %v, but expected %v (plaintext %x)", err, ErrAuthentication, actual)
+		}
+	}
+}
+
 func BenchmarkSeal(b *testing.B) {
 	key, _ := hex.DecodeString("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
 	data, _ := hex.DecodeString("101112131415161718191a1b1c1d1e1f2021222324252627")
@@ -128,3 +255,30 @@ func BenchmarkSeal(b *testing.B) {
 		aead.Seal(nil, nil, plaintext, data)
 	}
 }
+
+func BenchmarkS2V(b *testing.B) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	s := aead.(*SIV)
+
+	b.Run("many-ad", func(b *testing.B) {
+		data := make([][]byte, 64)
+		for i := range data {
+			data[i] = make([]byte, 8)
+		}
+
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s.SealMulti(nil, []byte("yay"), data...)
+		}
+	})
+
+	b.Run("long-final", func(b *testing.B) {
+		plaintext := make([]byte, 16*1024+7)
+
+		b.ReportAllocs()
+		b.SetBytes(int64(len(plaintext)))
+		for i := 0; i < b.N; i++ {
+			s.SealMulti(nil, plaintext)
+		}
+	})
+}
@@ -0,0 +1,189 @@
+// Package sivasn1 frames SIV-sealed payloads in a minimal DER-encoded
+// structure modelled on the EnvelopedData of CMS (RFC 5652), for partners
+// whose tooling expects ASN.1 rather than bare ciphertexts:
+//
+//	SIVEnvelope ::= SEQUENCE {
+//	  version        INTEGER (1),
+//	  keyIdentifier  OCTET STRING,
+//	  algorithm      OBJECT IDENTIFIER,
+//	  authAttrs      [0] IMPLICIT SET OF Attribute OPTIONAL,
+//	  ciphertext     OCTET STRING }
+//
+//	Attribute ::= SEQUENCE {
+//	  attrType       OBJECT IDENTIFIER,
+//	  attrValues     SET OF ANY }
+//
+// The associated data is the DER encoding of the same SEQUENCE without the
+// ciphertext, so the key identifier, the algorithm, and the DER-encoded
+// attributes are all authenticated. The payload is sealed without a nonce, so
+// equal payloads and headers seal identically.
+//
+// SIV has no registered OID, so the algorithm identifiers are taken from a
+// private arc under the example enterprise number of RFC 5612; partners must
+// agree to use them.
+package sivasn1
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/asn1"
+	"errors"
+
+	siv "github.com/stripe/siv-go"
+)
+
+const version = 1
+
+// The algorithm identifiers, one for each AES-SIV-CMAC key size.
+var (
+	OIDAESSIVCMAC256 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 32473, 5297, 1}
+	OIDAESSIVCMAC384 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 32473, 5297, 2}
+	OIDAESSIVCMAC512 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 32473, 5297, 3}
+)
+
+var (
+	// ErrMalformed is returned when an envelope isn't a DER-encoded
+	// SIVEnvelope, or is followed by trailing data.
+	ErrMalformed = errors.New("sivasn1: malformed envelope")
+
+	// ErrVersion is returned for envelopes with an unsupported version.
+	ErrVersion = errors.New("sivasn1: unsupported envelope version")
+
+	// ErrAlgorithm is returned by Seal for AEADs other than AES-SIV-CMAC,
+	// by Parse for envelopes with an unknown algorithm, and by Open when the
+	// envelope's algorithm doesn't match the AEAD's.
+	ErrAlgorithm = errors.New("sivasn1: unsupported algorithm")
+)
+
+// Attribute is an authenticated attribute. Each value is a complete DER
+// encoding, such as asn1.Marshal returns, in FullBytes. As DER requires, the
+// attributes and their values are encoded sorted by their encodings, so they
+// may be parsed in a different order from that given to Seal.
+type Attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+// Envelope is a parsed SIVEnvelope.
+type Envelope struct {
+	KeyID      []byte
+	Algorithm  asn1.ObjectIdentifier
+	Attributes []Attribute
+	Ciphertext []byte
+}
+
+// header is the authenticated part of an envelope.
+type header struct {
+	Version    int
+	KeyID      []byte
+	Algorithm  asn1.ObjectIdentifier
+	Attributes []Attribute `asn1:"optional,omitempty,tag:0,set"`
+}
+
+type envelope struct {
+	Version    int
+	KeyID      []byte
+	Algorithm  asn1.ObjectIdentifier
+	Attributes []Attribute `asn1:"optional,omitempty,tag:0,set"`
+	Ciphertext []byte
+}
+
+// Seal encrypts plaintext with aead, which must be an AES-SIV-CMAC AEAD
+// returned by siv.New, and returns a DER-encoded envelope carrying keyID and
+// the attributes, which may be nil.
+func Seal(aead cipher.AEAD, keyID, plaintext []byte, attrs []Attribute) ([]byte, error) {
+	oid := algorithm(aead)
+	if oid == nil {
+		return nil, ErrAlgorithm
+	}
+
+	e := &Envelope{KeyID: keyID, Algorithm: oid, Attributes: attrs}
+	ad, err := e.associatedData()
+	if err != nil {
+		return nil, err
+	}
+
+	e.Ciphertext = aead.Seal(nil, nil, plaintext, ad)
+	return asn1.Marshal(envelope{version, e.KeyID, e.Algorithm, e.Attributes, e.Ciphertext})
+}
+
+// Parse decodes an envelope without decrypting it, so that the key can be
+// chosen by its KeyID. Only DER is accepted: an envelope which doesn't
+// re-encode to exactly the same bytes is malformed.
+func Parse(der []byte) (*Envelope, error) {
+	var v envelope
+	rest, err := asn1.Unmarshal(der, &v)
+	if err != nil || len(rest) != 0 {
+		return nil, ErrMalformed
+	}
+	if v.Version != version {
+		return nil, ErrVersion
+	}
+	if b, err := asn1.Marshal(v); err != nil || !bytes.Equal(b, der) {
+		return nil, ErrMalformed
+	}
+	if !known(v.Algorithm) {
+		return nil, ErrAlgorithm
+	}
+
+	return &Envelope{
+		KeyID:      v.KeyID,
+		Algorithm:  v.Algorithm,
+		Attributes: v.Attributes,
+		Ciphertext: v.Ciphertext,
+	}, nil
+}
+
+// Open authenticates and decrypts the envelope's ciphertext.
+func (e *Envelope) Open(aead cipher.AEAD) ([]byte, error) {
+	if oid := algorithm(aead); oid == nil || !oid.Equal(e.Algorithm) {
+		return nil, ErrAlgorithm
+	}
+
+	ad, err := e.associatedData()
+	if err != nil {
+		return nil, err
+	}
+	return aead.Open(nil, nil, e.Ciphertext, ad)
+}
+
+// Open parses and opens an envelope, returning the plaintext and the parsed
+// envelope.
+func Open(aead cipher.AEAD, der []byte) ([]byte, *Envelope, error) {
+	e, err := Parse(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plaintext, err := e.Open(aead)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plaintext, e, nil
+}
+
+func (e *Envelope) associatedData() ([]byte, error) {
+	return asn1.Marshal(header{version, e.KeyID, e.Algorithm, e.Attributes})
+}
+
+// algorithm returns the OID for aead, or nil if it isn't AES-SIV-CMAC.
+func algorithm(aead cipher.AEAD) asn1.ObjectIdentifier {
+	info, ok := aead.(siv.Info)
+	if !ok {
+		return nil
+	}
+
+	switch info.Algorithm() {
+	case "AES-SIV-CMAC-256":
+		return OIDAESSIVCMAC256
+	case "AES-SIV-CMAC-384":
+		return OIDAESSIVCMAC384
+	case "AES-SIV-CMAC-512":
+		return OIDAESSIVCMAC512
+	}
+	return nil
+}
+
+func known(oid asn1.ObjectIdentifier) bool {
+	return oid.Equal(OIDAESSIVCMAC256) || oid.Equal(OIDAESSIVCMAC384) || oid.Equal(OIDAESSIVCMAC512)
+}
@@ -0,0 +1,235 @@
+package sivasn1
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/asn1"
+	"os"
+	"path/filepath"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+var (
+	oidContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidData        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidReference   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 32473, 5297, 100}
+)
+
+func newAEAD(t *testing.T, size int) cipher.AEAD {
+	key := make([]byte, size)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	aead, err := siv.New(key, aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func attribute(t *testing.T, oid asn1.ObjectIdentifier, values ...any) Attribute {
+	a := Attribute{Type: oid}
+	for _, v := range values {
+		b, err := asn1.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a.Values = append(a.Values, asn1.RawValue{FullBytes: b})
+	}
+	return a
+}
+
+func testAttributes(t *testing.T) []Attribute {
+	return []Attribute{
+		attribute(t, oidContentType, oidData),
+		attribute(t, oidReference, "invoice-42", "batch-7"),
+	}
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	b, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// TestGolden checks that sealing reproduces the committed DER fixtures, which
+// parse with `openssl asn1parse -inform DER`, and whose ciphertexts were
+// checked against an independent SIV implementation with the header SEQUENCE
+// as associated data.
+func TestGolden(t *testing.T) {
+	for _, v := range []struct {
+		name    string
+		keySize int
+		attrs   []Attribute
+	}{
+		{"attributes.der", 64, testAttributes(t)},
+		{"plain.der", 32, nil},
+	} {
+		expected := readFixture(t, v.name)
+		aead := newAEAD(t, v.keySize)
+
+		actual, err := Seal(aead, []byte("partner-2026"), []byte("hello, partner"), v.attrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(actual, expected) {
+			t.Errorf("%s: Envelope was %x, but expected %x", v.name, actual, expected)
+		}
+
+		plaintext, e, err := Open(aead, expected)
+		if err != nil {
+			t.Fatalf("%s: %v", v.name, err)
+		}
+		if string(plaintext) != "hello, partner" || string(e.KeyID) != "partner-2026" || len(e.Attributes) != len(v.attrs) {
+			t.Errorf("%s: Opened %q with key ID %q and %d attributes", v.name, plaintext, e.KeyID, len(e.Attributes))
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, size := range []int{32, 48, 64} {
+		aead := newAEAD(t, size)
+		attrs := testAttributes(t)
+
+		der, err := Seal(aead, []byte("k1"), []byte("payload"), attrs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		e, err := Parse(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !e.Algorithm.Equal(algorithm(aead)) {
+			t.Errorf("Algorithm was %v, but expected %v", e.Algorithm, algorithm(aead))
+		}
+
+		// DER sorts the values of a SET OF by their encodings.
+		for i, expected := range []string{"batch-7", "invoice-42"} {
+			var ref string
+			if _, err := asn1.Unmarshal(e.Attributes[1].Values[i].FullBytes, &ref); err != nil || ref != expected {
+				t.Errorf("Attribute was %q, %v, but expected %q", ref, err, expected)
+			}
+		}
+
+		plaintext, err := e.Open(aead)
+		if err != nil || string(plaintext) != "payload" {
+			t.Errorf("Plaintext was %q, %v, but expected %q", plaintext, err, "payload")
+		}
+
+		// The envelope's plaintext isn't visible.
+		if bytes.Contains(der, []byte("payload")) {
+			t.Errorf("Envelope contains the plaintext: %x", der)
+		}
+	}
+}
+
+func TestTrailingGarbage(t *testing.T) {
+	der := readFixture(t, "attributes.der")
+
+	for _, b := range [][]byte{
+		append(append([]byte{}, der...), 0),
+		append(append([]byte{}, der...), der...),
+		der[:len(der)-1],
+		nil,
+	} {
+		if plaintext, _, err := Open(newAEAD(t, 64), b); err != ErrMalformed {
+			t.Fatalf("Plaintext returned instead of error: %q (%v)", plaintext, err)
+		}
+	}
+
+	// A non-minimal length encoding isn't DER.
+	plain := readFixture(t, "plain.der")
+	long := append([]byte{0x30, 0x81}, plain[1:]...)
+	if _, err := Parse(long); err != ErrMalformed {
+		t.Errorf("Error was %v, but expected %v", err, ErrMalformed)
+	}
+}
+
+func TestWrongAlgorithm(t *testing.T) {
+	der := readFixture(t, "attributes.der")
+	aead := newAEAD(t, 64)
+
+	// The wrong key size for the envelope's algorithm.
+	if plaintext, _, err := Open(newAEAD(t, 32), der); err != ErrAlgorithm {
+		t.Fatalf("Plaintext returned instead of error: %q (%v)", plaintext, err)
+	}
+
+	// An unknown OID, and a known OID other than the one sealed.
+	for _, last := range []byte{9, 1} {
+		tampered := bytes.Replace(der, []byte{0xfd, 0x59, 0xa9, 0x31, 0x03}, []byte{0xfd, 0x59, 0xa9, 0x31, last}, 1)
+		if bytes.Equal(tampered, der) {
+			t.Fatal("Algorithm OID not found")
+		}
+		if plaintext, _, err := Open(aead, tampered); err != ErrAlgorithm {
+			t.Fatalf("Plaintext returned instead of error: %q (%v)", plaintext, err)
+		}
+	}
+
+	// A relabelled envelope doesn't open under a key of the relabelled size.
+	e, _ := Parse(der)
+	e.Algorithm = OIDAESSIVCMAC256
+	if plaintext, err := e.Open(newAEAD(t, 32)); err != siv.ErrAuthentication {
+		t.Fatalf("Plaintext returned instead of error: %q (%v)", plaintext, err)
+	}
+
+	if _, err := Seal(fakeAEAD{aead}, nil, []byte("x"), nil); err != ErrAlgorithm {
+		t.Errorf("Error was %v, but expected %v", err, ErrAlgorithm)
+	}
+}
+
+// fakeAEAD hides the AEAD's Info.
+type fakeAEAD struct {
+	cipher.AEAD
+}
+
+func TestAttributeTampering(t *testing.T) {
+	aead := newAEAD(t, 64)
+	e, err := Parse(readFixture(t, "attributes.der"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tamper := range []func(*Envelope){
+		func(e *Envelope) { e.Attributes = e.Attributes[:1] },
+		func(e *Envelope) { e.Attributes = nil },
+		func(e *Envelope) { e.Attributes = append(e.Attributes, attribute(t, oidReference, "extra")) },
+		func(e *Envelope) { e.Attributes[1] = attribute(t, oidReference, "invoice-43", "batch-7") },
+		func(e *Envelope) { e.Attributes[1] = attribute(t, oidReference, "invoice-42") },
+		func(e *Envelope) { e.KeyID = []byte("partner-2027") },
+	} {
+		c := *e
+		c.Attributes = append([]Attribute{}, e.Attributes...)
+		tamper(&c)
+
+		der, err := asn1.Marshal(envelope{version, c.KeyID, c.Algorithm, c.Attributes, c.Ciphertext})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if plaintext, _, err := Open(aead, der); err != siv.ErrAuthentication {
+			t.Fatalf("Plaintext returned instead of error: %q (%v)", plaintext, err)
+		}
+	}
+
+	// Flipping any bit of the envelope is detected.
+	der := readFixture(t, "attributes.der")
+	for i := range der {
+		tampered := append([]byte{}, der...)
+		tampered[i] ^= 0x01
+		if plaintext, _, err := Open(aead, tampered); err == nil {
+			t.Fatalf("Byte %d: plaintext returned instead of error: %q", i, plaintext)
+		}
+	}
+}
+
+func TestVersion(t *testing.T) {
+	der, _ := asn1.Marshal(envelope{2, []byte("k"), OIDAESSIVCMAC256, nil, make([]byte, 16)})
+	if _, err := Parse(der); err != ErrVersion {
+		t.Errorf("Error was %v, but expected %v", err, ErrVersion)
+	}
+}
@@ -0,0 +1,64 @@
+// Package sivbox is a drop-in replacement for golang.org/x/crypto/nacl/secretbox
+// backed by AES-SIV, for migrating code written against secretbox without
+// changing its call sites.
+//
+// THE OUTPUT IS NOT COMPATIBLE WITH SECRETBOX. Seal and Open have the same
+// signatures as their secretbox counterparts, and Overhead is the same, but
+// boxes sealed by one package can't be opened by the other. Existing boxes
+// must be opened with secretbox and resealed with sivbox, and every reader of
+// a box must switch packages before any writer does.
+//
+// The 32-byte key is expanded with HKDF-SHA256 (RFC 5869), with no salt and
+// the info "siv-go sivbox", into a 64-byte AES-SIV-CMAC-512 key, and the
+// 24-byte nonce is the only S2V associated data component. Unlike secretbox,
+// reusing a nonce is not catastrophic: boxes sealed under the same key and
+// nonce are still authenticated and confidential, except that identical
+// messages produce identical boxes.
+package sivbox
+
+import (
+	"crypto/cipher"
+	"crypto/sha256"
+	"io"
+
+	siv "github.com/stripe/siv-go"
+	"golang.org/x/crypto/hkdf"
+)
+
+// Overhead is the number of bytes of overhead when boxing a message, as for
+// secretbox.
+const Overhead = 16
+
+// info is the HKDF info used to expand the key.
+const info = "siv-go sivbox"
+
+// Seal appends an encrypted and authenticated copy of message to out, which
+// must not overlap message. The key and nonce pair should be unique for each
+// distinct message, though reusing them only reveals whether messages are
+// equal, and the output will be Overhead bytes longer than the original.
+func Seal(out, message []byte, nonce *[24]byte, key *[32]byte) []byte {
+	return newAEAD(key).Seal(out, nonce[:], message, nil)
+}
+
+// Open authenticates and decrypts a box produced by Seal and appends the
+// message to out, which must not overlap box. The output will be Overhead
+// bytes smaller than box.
+func Open(out, box []byte, nonce *[24]byte, key *[32]byte) ([]byte, bool) {
+	message, err := newAEAD(key).Open(out, nonce[:], box, nil)
+	if err != nil {
+		return nil, false
+	}
+	return message, true
+}
+
+func newAEAD(key *[32]byte) cipher.AEAD {
+	var k siv.Key512
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key[:], nil, []byte(info)), k[:]); err != nil {
+		panic("sivbox: " + err.Error())
+	}
+	aead := siv.NewAEAD512(k)
+	for i := range k {
+		k[i] = 0
+	}
+	return aead
+}
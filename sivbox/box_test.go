@@ -0,0 +1,127 @@
+package sivbox
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+var (
+	key   = [32]byte{1, 2, 3}
+	nonce = [24]byte{4, 5, 6}
+)
+
+func TestRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 1, 15, 16, 17, 1000} {
+		message := bytes.Repeat([]byte{'m'}, n)
+
+		box := Seal([]byte("prefix"), message, &nonce, &key)
+		if len(box) != len("prefix")+n+Overhead || string(box[:6]) != "prefix" {
+			t.Fatalf("Box was %x", box)
+		}
+
+		opened, ok := Open([]byte("out"), box[6:], &nonce, &key)
+		if !ok {
+			t.Fatalf("%d bytes: box failed to open", n)
+		}
+		if !bytes.Equal(opened, append([]byte("out"), message...)) {
+			t.Errorf("Message was %x, but expected %x", opened, message)
+		}
+	}
+}
+
+func TestKeySchedule(t *testing.T) {
+	var k siv.Key512
+	_, _ = io.ReadFull(hkdf.New(sha256.New, key[:], nil, []byte("siv-go sivbox")), k[:])
+
+	aead := siv.NewAEAD512(k).(interface {
+		SealMulti(dst, plaintext []byte, data ...[]byte) []byte
+	})
+	expected := aead.SealMulti(nil, []byte("message"), nonce[:])
+	if actual := Seal(nil, []byte("message"), &nonce, &key); !bytes.Equal(actual, expected) {
+		t.Errorf("Box was %x, but expected %x", actual, expected)
+	}
+}
+
+func TestNotSecretbox(t *testing.T) {
+	message := []byte("not compatible")
+
+	box := Seal(nil, message, &nonce, &key)
+	naclBox := secretbox.Seal(nil, message, &nonce, &key)
+	if Overhead != secretbox.Overhead || len(box) != len(naclBox) {
+		t.Errorf("Box was %d bytes, but expected %d", len(box), len(naclBox))
+	}
+	if bytes.Equal(box, naclBox) {
+		t.Error("Box was identical to secretbox's")
+	}
+
+	if m, ok := secretbox.Open(nil, box, &nonce, &key); ok {
+		t.Fatalf("Plaintext returned instead of error: %x", m)
+	}
+	if m, ok := Open(nil, naclBox, &nonce, &key); ok {
+		t.Fatalf("Plaintext returned instead of error: %x", m)
+	}
+}
+
+func TestNonceReuse(t *testing.T) {
+	a1 := Seal(nil, []byte("message a"), &nonce, &key)
+	a2 := Seal(nil, []byte("message a"), &nonce, &key)
+	b := Seal(nil, []byte("message b"), &nonce, &key)
+
+	// Reusing a nonce is deterministic: equal messages give equal boxes...
+	if !bytes.Equal(a1, a2) {
+		t.Errorf("Boxes were %x and %x, but expected them to be equal", a1, a2)
+	}
+
+	// ...but unequal messages don't share a keystream, as they would with
+	// secretbox.
+	if bytes.Equal(xor(a1[Overhead:], b[Overhead:]), xor([]byte("message a"), []byte("message b"))) {
+		t.Errorf("Boxes %x and %x share a keystream", a1, b)
+	}
+
+	// Each still authenticates.
+	for _, box := range [][]byte{a1, b} {
+		if _, ok := Open(nil, box, &nonce, &key); !ok {
+			t.Error("Box failed to open")
+		}
+	}
+}
+
+func TestForgery(t *testing.T) {
+	box := Seal(nil, []byte("message"), &nonce, &key)
+
+	otherNonce, otherKey := nonce, key
+	otherNonce[23] ^= 1
+	otherKey[31] ^= 1
+	if m, ok := Open(nil, box, &otherNonce, &key); ok {
+		t.Fatalf("Plaintext returned instead of error: %x", m)
+	}
+	if m, ok := Open(nil, box, &nonce, &otherKey); ok {
+		t.Fatalf("Plaintext returned instead of error: %x", m)
+	}
+
+	for i := range box {
+		tampered := append([]byte{}, box...)
+		tampered[i] ^= 1
+		if m, ok := Open(nil, tampered, &nonce, &key); ok {
+			t.Fatalf("Plaintext returned instead of error: %x", m)
+		}
+	}
+
+	if m, ok := Open(nil, box[:Overhead-1], &nonce, &key); ok {
+		t.Fatalf("Plaintext returned instead of error: %x", m)
+	}
+}
+
+func xor(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range out {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
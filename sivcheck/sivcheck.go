@@ -0,0 +1,249 @@
+// Package sivcheck defines an analyzer which reports common misuse of the siv
+// package:
+//
+//   - a nonce other than nil passed to Seal or Open on one of the package's
+//     AEADs, which SIV doesn't need and treats as an extra associated data
+//     component, so that it must be stored and passed back exactly;
+//   - the error from an Open method or function discarded, so that an
+//     unauthenticated ciphertext goes unnoticed;
+//   - a key, or root key, converted from a string literal, which puts the key
+//     in the source and the binary.
+//
+// An AEAD is recognized as the siv package's if its type is defined in the
+// package, or if it is a variable assigned the result of one of the package's
+// functions in the same file. The analyzer can be run with go vet:
+//
+//	go install github.com/stripe/siv-go/cmd/sivcheck@latest
+//	go vet -vettool=$(which sivcheck) ./...
+package sivcheck
+
+import (
+	"go/ast"
+	"go/types"
+	"strings"
+
+	"golang.org/x/tools/go/analysis"
+	"golang.org/x/tools/go/analysis/passes/inspect"
+	"golang.org/x/tools/go/ast/inspector"
+)
+
+// sivPath is the import path of the siv package.
+const sivPath = "github.com/stripe/siv-go"
+
+// Analyzer reports misuse of the siv package.
+var Analyzer = &analysis.Analyzer{
+	Name:     "sivcheck",
+	Doc:      "report common misuse of github.com/stripe/siv-go: nonces, ignored Open errors, and literal keys",
+	URL:      "https://pkg.go.dev/github.com/stripe/siv-go/sivcheck",
+	Requires: []*analysis.Analyzer{inspect.Analyzer},
+	Run:      run,
+}
+
+func run(pass *analysis.Pass) (any, error) {
+	ins := pass.ResultOf[inspect.Analyzer].(*inspector.Inspector)
+	c := &checker{pass: pass, aeads: make(map[types.Object]bool)}
+
+	// First find the variables holding the package's AEADs, so that calls
+	// on them are recognized wherever they appear in the file.
+	ins.Preorder([]ast.Node{(*ast.AssignStmt)(nil), (*ast.ValueSpec)(nil)}, func(n ast.Node) {
+		switch n := n.(type) {
+		case *ast.AssignStmt:
+			c.assigned(n.Lhs, n.Rhs)
+		case *ast.ValueSpec:
+			lhs := make([]ast.Expr, len(n.Names))
+			for i, name := range n.Names {
+				lhs[i] = name
+			}
+			c.assigned(lhs, n.Values)
+		}
+	})
+
+	ins.WithStack([]ast.Node{(*ast.CallExpr)(nil)}, func(n ast.Node, push bool, stack []ast.Node) bool {
+		if push {
+			c.call(n.(*ast.CallExpr), stack)
+		}
+		return true
+	})
+	return nil, nil
+}
+
+type checker struct {
+	pass  *analysis.Pass
+	aeads map[types.Object]bool
+}
+
+// assigned records the variables in lhs which are assigned an AEAD returned
+// by one of the package's functions.
+func (c *checker) assigned(lhs, rhs []ast.Expr) {
+	if len(rhs) != 1 || len(lhs) == 0 {
+		// Multiple values are assigned pairwise.
+		for i := range rhs {
+			if i < len(lhs) {
+				c.assigned(lhs[i:i+1], rhs[i:i+1])
+			}
+		}
+		return
+	}
+
+	call, ok := ast.Unparen(rhs[0]).(*ast.CallExpr)
+	if !ok {
+		return
+	}
+	fn := c.callee(call)
+	if fn == nil || !isSIV(fn.Pkg()) || !hasOpen(fn.Type().(*types.Signature).Results()) {
+		return
+	}
+
+	if id, ok := lhs[0].(*ast.Ident); ok {
+		if obj := c.pass.TypesInfo.ObjectOf(id); obj != nil {
+			c.aeads[obj] = true
+		}
+	}
+}
+
+func (c *checker) callee(call *ast.CallExpr) *types.Func {
+	var id *ast.Ident
+	switch fun := ast.Unparen(call.Fun).(type) {
+	case *ast.Ident:
+		id = fun
+	case *ast.SelectorExpr:
+		id = fun.Sel
+	default:
+		return nil
+	}
+	fn, _ := c.pass.TypesInfo.Uses[id].(*types.Func)
+	return fn
+}
+
+// hasOpen reports whether the first of results has an Open method, as an
+// AEAD does.
+func hasOpen(results *types.Tuple) bool {
+	if results.Len() == 0 {
+		return false
+	}
+	obj, _, _ := types.LookupFieldOrMethod(results.At(0).Type(), true, nil, "Open")
+	_, ok := obj.(*types.Func)
+	return ok
+}
+
+func isSIV(pkg *types.Package) bool {
+	return pkg != nil && (pkg.Path() == sivPath || strings.HasPrefix(pkg.Path(), sivPath+"/"))
+}
+
+// isSIVValue reports whether e is one of the package's AEADs.
+func (c *checker) isSIVValue(e ast.Expr) bool {
+	e = ast.Unparen(e)
+	if id, ok := e.(*ast.Ident); ok && c.aeads[c.pass.TypesInfo.ObjectOf(id)] {
+		return true
+	}
+
+	t := c.pass.TypesInfo.TypeOf(e)
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+	named, ok := t.(*types.Named)
+	return ok && isSIV(named.Obj().Pkg())
+}
+
+func (c *checker) call(call *ast.CallExpr, stack []ast.Node) {
+	fn := c.callee(call)
+	if fn == nil {
+		return
+	}
+	sig := fn.Type().(*types.Signature)
+
+	if sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr); ok && sig.Recv() != nil && c.isSIVValue(sel.X) {
+		switch fn.Name() {
+		case "Seal", "Open":
+			if len(call.Args) == 4 && !c.isNil(call.Args[1]) {
+				c.pass.Reportf(call.Args[1].Pos(), "non-nil nonce passed to SIV %s: SIV needs no nonce, and any nonce given must be passed back exactly to open", fn.Name())
+			}
+		}
+		if strings.HasPrefix(fn.Name(), "Open") {
+			c.checkErrorUsed(call, sig, stack)
+		}
+		return
+	}
+
+	if !isSIV(fn.Pkg()) {
+		return
+	}
+	if strings.HasPrefix(fn.Name(), "Open") {
+		c.checkErrorUsed(call, sig, stack)
+	}
+
+	// Keys are the parameters named key or root.
+	params := sig.Params()
+	for i := 0; i < params.Len() && i < len(call.Args); i++ {
+		switch params.At(i).Name() {
+		case "key", "root":
+			if c.isStringConversion(call.Args[i]) {
+				c.pass.Reportf(call.Args[i].Pos(), "key converted from a string literal: load keys from a secret store, not the source")
+			}
+		}
+	}
+}
+
+func (c *checker) isNil(e ast.Expr) bool {
+	tv, ok := c.pass.TypesInfo.Types[e]
+	return ok && tv.IsNil()
+}
+
+// isStringConversion reports whether e converts a constant string to a byte
+// slice, as []byte("secret") does.
+func (c *checker) isStringConversion(e ast.Expr) bool {
+	conv, ok := ast.Unparen(e).(*ast.CallExpr)
+	if !ok || len(conv.Args) != 1 {
+		return false
+	}
+	if tv, ok := c.pass.TypesInfo.Types[conv.Fun]; !ok || !tv.IsType() {
+		return false
+	}
+
+	arg := c.pass.TypesInfo.Types[conv.Args[0]]
+	if arg.Value == nil {
+		return false
+	}
+	basic, ok := arg.Type.Underlying().(*types.Basic)
+	return ok && basic.Info()&types.IsString != 0
+}
+
+// checkErrorUsed reports a call to an Open method or function whose error
+// result is discarded, either by calling it as a statement or by assigning
+// the error to the blank identifier.
+func (c *checker) checkErrorUsed(call *ast.CallExpr, sig *types.Signature, stack []ast.Node) {
+	results := sig.Results()
+	if results.Len() == 0 || !isError(results.At(results.Len()-1).Type()) {
+		return
+	}
+
+	switch parent := stack[len(stack)-2].(type) {
+	case *ast.ExprStmt:
+		c.pass.Reportf(call.Pos(), "error from %s discarded: the ciphertext may not be authentic", c.name(call))
+	case *ast.AssignStmt:
+		if len(parent.Rhs) != 1 || len(parent.Lhs) != results.Len() {
+			return
+		}
+		if id, ok := parent.Lhs[len(parent.Lhs)-1].(*ast.Ident); ok && id.Name == "_" {
+			c.pass.Reportf(call.Pos(), "error from %s discarded: the ciphertext may not be authentic", c.name(call))
+		}
+	case *ast.ValueSpec:
+		if len(parent.Values) != 1 || len(parent.Names) != results.Len() {
+			return
+		}
+		if parent.Names[len(parent.Names)-1].Name == "_" {
+			c.pass.Reportf(call.Pos(), "error from %s discarded: the ciphertext may not be authentic", c.name(call))
+		}
+	}
+}
+
+func (c *checker) name(call *ast.CallExpr) string {
+	if sel, ok := ast.Unparen(call.Fun).(*ast.SelectorExpr); ok {
+		return sel.Sel.Name
+	}
+	return c.callee(call).Name()
+}
+
+func isError(t types.Type) bool {
+	return types.Identical(t, types.Universe.Lookup("error").Type())
+}
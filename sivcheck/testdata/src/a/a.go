@@ -0,0 +1,62 @@
+package a
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func nonces(key, nonce []byte) {
+	aead, _ := siv.New(key, aes.NewCipher)
+	ct := aead.Seal(nil, nonce, []byte("hello"), nil) // want `non-nil nonce passed to SIV Seal`
+	_ = aead.Seal(nil, []byte{1, 2, 3}, nil, nil)     // want `non-nil nonce passed to SIV Seal`
+	_ = aead.Seal(nil, nil, []byte("hello"), nil)
+	if _, err := aead.Open(nil, nonce, ct, nil); err != nil { // want `non-nil nonce passed to SIV Open`
+		return
+	}
+
+	k := siv.NewKeyring()
+	_ = k.Seal(nil, nonce, nil, nil) // want `non-nil nonce passed to SIV Seal`
+	_ = k.Seal(nil, nil, nil, nil)
+
+	// Other AEADs need nonces.
+	block, _ := aes.NewCipher(key)
+	gcm, _ := cipher.NewGCM(block)
+	_ = gcm.Seal(nil, nonce, nil, nil)
+}
+
+func openErrors(key, ct []byte) []byte {
+	var aead cipher.AEAD
+	aead, _ = siv.New(key, aes.NewCipher)
+
+	aead.Open(nil, nil, ct, nil)             // want `error from Open discarded`
+	p, _ := aead.Open(nil, nil, ct, nil)     // want `error from Open discarded`
+	var q, _ = aead.Open(nil, nil, ct, nil)  // want `error from Open discarded`
+	siv.OpenString(aead, "", nil)            // want `error from OpenString discarded`
+	_, _ = siv.OpenString(aead, "", nil)     // want `error from OpenString discarded`
+	siv.NewKeyring().Open(nil, nil, ct, nil) // want `error from Open discarded`
+
+	r, err := aead.Open(nil, nil, ct, nil)
+	if err != nil {
+		return nil
+	}
+	if _, err := siv.OpenString(aead, "", nil); err != nil {
+		return nil
+	}
+	return append(append(p, q...), r...)
+}
+
+const secret = "0123456789abcdef0123456789abcdef"
+
+func literalKeys(key []byte, name string) {
+	siv.New([]byte("0123456789abcdef0123456789abcdef"), aes.NewCipher) // want `key converted from a string literal`
+	siv.New([]byte(secret), aes.NewCipher)                             // want `key converted from a string literal`
+	siv.NewNamed("AES-SIV-CMAC-256", []byte(secret))                   // want `key converted from a string literal`
+	siv.DeriveAEADPath([]byte(secret), "payments")                     // want `key converted from a string literal`
+	siv.KeyFingerprint([]byte(secret))                                 // want `key converted from a string literal`
+
+	siv.New(key, aes.NewCipher)
+	siv.New([]byte(name), aes.NewCipher)
+	siv.NewNamed("AES-SIV-CMAC-256", key)
+}
@@ -0,0 +1,24 @@
+// Package siv is a stub of the siv package's API for the analyzer's tests.
+package siv
+
+import "crypto/cipher"
+
+type BlockFactory func(key []byte) (cipher.Block, error)
+
+func New(key []byte, alg BlockFactory) (cipher.AEAD, error) { return nil, nil }
+
+func NewNamed(name string, key []byte) (cipher.AEAD, error) { return nil, nil }
+
+func DeriveAEADPath(root []byte, path ...string) (cipher.AEAD, error) { return nil, nil }
+
+func KeyFingerprint(key []byte) ([]byte, error) { return nil, nil }
+
+func OpenString(aead cipher.AEAD, s string, ad []byte) ([]byte, error) { return nil, nil }
+
+type Keyring struct{}
+
+func NewKeyring() *Keyring { return nil }
+
+func (k *Keyring) Seal(dst, nonce, plaintext, data []byte) []byte { return nil }
+
+func (k *Keyring) Open(dst, nonce, ciphertext, data []byte) ([]byte, error) { return nil, nil }
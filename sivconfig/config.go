@@ -0,0 +1,206 @@
+// Package sivconfig encrypts selected values of configuration documents in
+// place, so that files with a few secrets can be committed, and resolves them
+// again at load time.
+//
+// Documents are the generic values produced by encoding/json (with or without
+// UseNumber) and by YAML libraries decoding into an any: maps with string
+// keys, slices, and scalars. Encrypt replaces each selected scalar with Prefix
+// followed by its encryption under siv.EncryptJSONValue, which preserves its
+// type, and Resolve replaces every such string with the decrypted value. As
+// the encryption is deterministic, re-encrypting an unchanged file leaves it
+// unchanged.
+//
+// Each value's location, as a JSON Pointer (RFC 6901) such as "/db/password"
+// or "/servers/1/token", is bound to it as associated data, so encrypted values
+// can't be swapped between settings.
+package sivconfig
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	siv "github.com/stripe/siv-go"
+)
+
+const (
+	// Prefix marks an encrypted value.
+	Prefix = "siv:"
+
+	// Marker marks a string value for encryption whatever its key, in the
+	// manner of a YAML tag: "!siv hunter2" is encrypted as "hunter2".
+	Marker = "!siv "
+)
+
+// DefaultPattern selects values whose keys look like secrets.
+var DefaultPattern = regexp.MustCompile(`(?i)(passwords?|passwd|secrets?|tokens?|api_?keys?|private_?keys?)$`)
+
+// An Option configures Encrypt.
+type Option func(*options)
+
+type options struct {
+	pattern *regexp.Regexp
+}
+
+// WithPattern selects values whose keys match re, instead of DefaultPattern. A
+// selected map or array has all the scalars within it encrypted.
+func WithPattern(re *regexp.Regexp) Option {
+	return func(o *options) {
+		o.pattern = re
+	}
+}
+
+// Encrypt returns a copy of doc with the selected values encrypted: those
+// under keys matching the pattern, and strings starting with Marker. Values
+// which are already encrypted are left as they are.
+func Encrypt(aead cipher.AEAD, doc any, opts ...Option) (any, error) {
+	o := options{pattern: DefaultPattern}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return walk(doc, "", false, o.pattern, func(path string, v any, selected bool) (any, error) {
+		s, ok := v.(string)
+		if ok && strings.HasPrefix(s, Prefix) {
+			return v, nil
+		}
+		if ok && strings.HasPrefix(s, Marker) {
+			v, selected = strings.TrimPrefix(s, Marker), true
+		}
+		if !selected {
+			return v, nil
+		}
+
+		ciphertext, err := siv.EncryptJSONValue(aead, v, []byte(path))
+		if err != nil {
+			return nil, fmt.Errorf("sivconfig: %s: %w", path, err)
+		}
+		return Prefix + ciphertext, nil
+	})
+}
+
+// Resolve returns a copy of doc with every encrypted value decrypted. Numbers
+// are returned as json.Numbers.
+func Resolve(aead cipher.AEAD, doc any) (any, error) {
+	return walk(doc, "", false, nil, func(path string, v any, _ bool) (any, error) {
+		s, ok := v.(string)
+		if !ok || !strings.HasPrefix(s, Prefix) {
+			return v, nil
+		}
+
+		plaintext, err := siv.DecryptJSONValue(aead, s[len(Prefix):], []byte(path))
+		if err != nil {
+			return nil, fmt.Errorf("sivconfig: %s: %w", path, err)
+		}
+		return plaintext, nil
+	})
+}
+
+// walk copies doc, replacing each scalar with the result of f. A value is
+// selected if its key, or that of any map or array containing it, matches
+// pattern.
+func walk(doc any, path string, selected bool, pattern *regexp.Regexp, f func(path string, v any, selected bool) (any, error)) (any, error) {
+	switch doc := doc.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(doc))
+		for k, v := range doc {
+			sel := selected || (pattern != nil && pattern.MatchString(k))
+			w, err := walk(v, path+"/"+escape(k), sel, pattern, f)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = w
+		}
+		return out, nil
+	case []any:
+		out := make([]any, len(doc))
+		for i, v := range doc {
+			w, err := walk(v, path+"/"+strconv.Itoa(i), selected, pattern, f)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = w
+		}
+		return out, nil
+	default:
+		if path == "" {
+			path = "/"
+		}
+		return f(path, doc, selected)
+	}
+}
+
+// escape escapes a key as a JSON Pointer reference token.
+func escape(k string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(k)
+}
+
+// EncryptFile encrypts the selected values of the JSON file at name, replacing
+// it atomically with the output of EncryptJSON.
+func EncryptFile(aead cipher.AEAD, name string, opts ...Option) error {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	out, err := EncryptJSON(aead, b, opts...)
+	if err != nil {
+		return err
+	}
+
+	fi, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(name), filepath.Base(name)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(out); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(fi.Mode()); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
+
+// EncryptJSON encrypts the selected values of a JSON document, returning it
+// with sorted keys, indented with two spaces.
+func EncryptJSON(aead cipher.AEAD, b []byte, opts ...Option) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("sivconfig: %w", err)
+	}
+
+	doc, err := Encrypt(aead, doc, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,229 @@
+package sivconfig
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func newAEAD(t *testing.T) cipher.AEAD {
+	aead, err := siv.New([]byte("0123456789abcdef0123456789ABCDEF"), aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func decode(t *testing.T, s string) any {
+	dec := json.NewDecoder(strings.NewReader(s))
+	dec.UseNumber()
+
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	return doc
+}
+
+const config = `{
+	"name": "billing",
+	"db": {"host": "db.internal", "port": 5432, "password": "hunter2"},
+	"servers": [
+		{"host": "a", "api_key": "k-a"},
+		{"host": "b", "api_key": "k-b", "weight": 0.5}
+	],
+	"tokens": ["t1", "t2", {"nested": true}],
+	"pin": "!siv 1234",
+	"secret": null
+}`
+
+func TestRoundTrip(t *testing.T) {
+	aead := newAEAD(t)
+	doc := decode(t, config)
+
+	encrypted, err := Encrypt(aead, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := encrypted.(map[string]any)
+	for _, v := range []any{
+		e["db"].(map[string]any)["password"],
+		e["servers"].([]any)[0].(map[string]any)["api_key"],
+		e["servers"].([]any)[1].(map[string]any)["api_key"],
+		e["tokens"].([]any)[0],
+		e["tokens"].([]any)[2].(map[string]any)["nested"],
+		e["pin"],
+		e["secret"],
+	} {
+		if s, ok := v.(string); !ok || !strings.HasPrefix(s, Prefix) {
+			t.Errorf("Value was %v, but expected it to be encrypted", v)
+		}
+	}
+
+	for _, v := range []any{e["name"], e["db"].(map[string]any)["host"], e["db"].(map[string]any)["port"]} {
+		if s, ok := v.(string); ok && strings.HasPrefix(s, Prefix) {
+			t.Errorf("Value %v was encrypted", v)
+		}
+	}
+
+	// The input is not modified.
+	if !reflect.DeepEqual(doc, decode(t, config)) {
+		t.Errorf("Input was modified: %v", doc)
+	}
+
+	resolved, err := Resolve(aead, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := decode(t, config)
+	expected.(map[string]any)["pin"] = "1234"
+	if !reflect.DeepEqual(resolved, expected) {
+		t.Errorf("Resolved document was %v, but expected %v", resolved, expected)
+	}
+
+	// Encrypting again changes nothing.
+	again, err := Encrypt(aead, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(again, encrypted) {
+		t.Errorf("Re-encrypted document was %v, but expected %v", again, encrypted)
+	}
+}
+
+func TestPathBinding(t *testing.T) {
+	aead := newAEAD(t)
+	encrypted, _ := Encrypt(aead, decode(t, `{"a": {"password": "x"}, "b": {"password": "y"}, "tokens": ["1", "2"]}`))
+	e := encrypted.(map[string]any)
+
+	a, b := e["a"].(map[string]any), e["b"].(map[string]any)
+	a["password"], b["password"] = b["password"], a["password"]
+
+	if _, err := Resolve(aead, e); !errors.Is(err, siv.ErrAuthentication) {
+		t.Errorf("Error was %v, but expected %v", err, siv.ErrAuthentication)
+	}
+
+	a["password"], b["password"] = b["password"], a["password"]
+	tokens := e["tokens"].([]any)
+	tokens[0], tokens[1] = tokens[1], tokens[0]
+
+	if _, err := Resolve(aead, e); !errors.Is(err, siv.ErrAuthentication) {
+		t.Errorf("Error was %v, but expected %v", err, siv.ErrAuthentication)
+	}
+
+	// Keys which need escaping in a JSON Pointer don't collide.
+	x, _ := Encrypt(aead, map[string]any{"a/b": "!siv v"})
+	y := map[string]any{"a": map[string]any{"b": x.(map[string]any)["a/b"]}}
+	if _, err := Resolve(aead, y); !errors.Is(err, siv.ErrAuthentication) {
+		t.Errorf("Error was %v, but expected %v", err, siv.ErrAuthentication)
+	}
+}
+
+func TestPlainValues(t *testing.T) {
+	aead := newAEAD(t)
+
+	// YAML libraries decode integers as ints.
+	doc := map[string]any{"port": 8080, "password": 1234, "enabled": true}
+	encrypted, err := Encrypt(aead, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := encrypted.(map[string]any)
+	if e["port"] != 8080 || e["enabled"] != true {
+		t.Errorf("Plain values changed: %v", e)
+	}
+
+	resolved, err := Resolve(aead, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := resolved.(map[string]any)["password"]; n != json.Number("1234") {
+		t.Errorf("Password was %v, but expected %v", n, json.Number("1234"))
+	}
+}
+
+func TestWithPattern(t *testing.T) {
+	aead := newAEAD(t)
+
+	encrypted, err := Encrypt(aead, map[string]any{"password": "x", "dsn": "postgres://u:p@h/db"},
+		WithPattern(regexp.MustCompile(`^dsn$`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e := encrypted.(map[string]any)
+	if e["password"] != "x" || !strings.HasPrefix(e["dsn"].(string), Prefix) {
+		t.Errorf("Document was %v", e)
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	aead := newAEAD(t)
+
+	for _, v := range []string{Prefix, Prefix + "!!!", Prefix + "AAAAAAAAAAAAAAAAAAAAAAAA"} {
+		if _, err := Resolve(aead, map[string]any{"x": v}); err == nil {
+			t.Errorf("No error for %q", v)
+		}
+	}
+
+	if _, err := Encrypt(aead, map[string]any{"password": struct{}{}}); !errors.Is(err, siv.ErrJSONType) {
+		t.Errorf("Error was %v, but expected %v", err, siv.ErrJSONType)
+	}
+}
+
+func TestEncryptFile(t *testing.T) {
+	aead := newAEAD(t)
+	name := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(name, []byte(config), 0o640); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := EncryptFile(aead, name); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(string(b), "hunter2") || !strings.Contains(string(b), `"host": "db.internal"`) {
+		t.Errorf("File was %s", b)
+	}
+
+	if fi, err := os.Stat(name); err != nil || fi.Mode().Perm() != 0o640 {
+		t.Errorf("File mode was %v, %v, but expected %v", fi.Mode(), err, os.FileMode(0o640))
+	}
+
+	// Encrypting the file again leaves it unchanged.
+	if err := EncryptFile(aead, name); err != nil {
+		t.Fatal(err)
+	}
+
+	if again, _ := os.ReadFile(name); string(again) != string(b) {
+		t.Errorf("File was %s, but expected %s", again, b)
+	}
+
+	resolved, err := Resolve(aead, decode(t, string(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p := resolved.(map[string]any)["db"].(map[string]any)["password"]; p != "hunter2" {
+		t.Errorf("Password was %v, but expected %q", p, "hunter2")
+	}
+}
@@ -0,0 +1,283 @@
+// Package sivconn provides an authenticated, encrypted record layer over a
+// net.Conn using a deterministic AEAD such as SIV.
+//
+// A connection begins with a handshake, on its first Read or Write or on
+// Handshake: the client sends 16 random bytes, and the server replies with 16
+// of its own. Together they are the session ID, which is unique to the
+// connection.
+//
+// Each Write is then split into records of at most MaxRecordSize bytes. Every
+// record is sealed with associated data consisting of the session ID, the
+// direction of travel, and a 64-bit sequence number, and sent with a 32-bit
+// big-endian length prefix. The sequence numbers are implicit, so a record
+// which is replayed, reordered, dropped, or reflected back to its sender fails
+// to authenticate, and as each side contributes to the session ID, so does a
+// record recorded from another connection under the same key. Records with
+// the same plaintext are likewise unlinkable across connections, although the
+// AEAD is deterministic. Such failures are fatal: once a record fails to open,
+// every later Read returns the same error.
+package sivconn
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+)
+
+// MaxRecordSize is the maximum number of plaintext bytes sealed in a single
+// record.
+const MaxRecordSize = 16 * 1024
+
+var (
+	// ErrRecordAuthentication is returned by Read when a record fails to open.
+	ErrRecordAuthentication = errors.New("sivconn: record authentication failed")
+
+	// ErrRecordSize is returned by Read when a record's length prefix is
+	// invalid.
+	ErrRecordSize = errors.New("sivconn: invalid record size")
+
+	// ErrSequenceOverflow is returned when a direction's sequence number would
+	// wrap.
+	ErrSequenceOverflow = errors.New("sivconn: sequence number overflow")
+)
+
+const (
+	clientToServer = 'c'
+	serverToClient = 's'
+)
+
+// randomSize is the size of each side's contribution to the session ID.
+const randomSize = 16
+
+// An Option configures a connection.
+type Option func(*Conn)
+
+// WithRand makes the connection read its contribution to the session ID from
+// r rather than crypto/rand, as for deterministic tests. The handshake reads
+// exactly 16 bytes from r, and fails if r returns fewer.
+func WithRand(r io.Reader) Option {
+	return func(c *Conn) {
+		c.rand = r
+	}
+}
+
+// Client returns a connection which sends records from the client side of
+// conn. The peer must use Server with the same AEAD.
+func Client(conn net.Conn, aead cipher.AEAD, opts ...Option) net.Conn {
+	return newConn(conn, aead, clientToServer, serverToClient, opts)
+}
+
+// Server returns a connection which sends records from the server side of
+// conn. The peer must use Client with the same AEAD.
+func Server(conn net.Conn, aead cipher.AEAD, opts ...Option) net.Conn {
+	return newConn(conn, aead, serverToClient, clientToServer, opts)
+}
+
+func newConn(conn net.Conn, aead cipher.AEAD, out, in byte, opts []Option) *Conn {
+	c := &Conn{
+		Conn: conn,
+		aead: aead,
+		rand: rand.Reader,
+		out:  half{dir: out},
+		in:   half{dir: in},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Conn is a net.Conn which encrypts and authenticates records.
+type Conn struct {
+	net.Conn
+	aead cipher.AEAD
+	rand io.Reader
+
+	hs    sync.Once
+	hsErr error
+
+	wmu  sync.Mutex
+	out  half
+	wbuf []byte
+	werr error
+
+	rmu     sync.Mutex
+	in      half
+	rbuf    []byte
+	pbuf    []byte
+	pending []byte
+	rerr    error
+}
+
+// half is the state of one direction of a connection.
+type half struct {
+	session []byte
+	dir     byte
+	seq     uint64
+}
+
+// ad returns the associated data for the next record in this direction.
+func (h *half) ad() ([]byte, error) {
+	if h.seq == ^uint64(0) {
+		return nil, ErrSequenceOverflow
+	}
+
+	b := append(make([]byte, 0, len(h.session)+9), h.session...)
+	b = append(b, h.dir)
+	b = binary.BigEndian.AppendUint64(b, h.seq)
+	h.seq++
+	return b, nil
+}
+
+// Handshake exchanges random values with the peer to establish the session
+// ID, if that hasn't been done already. Read and Write call it as needed, so
+// it only needs to be called to handshake before either. A failed handshake
+// is fatal.
+func (c *Conn) Handshake() error {
+	c.hs.Do(func() {
+		c.hsErr = c.handshake()
+	})
+	return c.hsErr
+}
+
+// handshake sends the client's random value and then the server's, so that
+// neither side writes before the other reads. The session ID is the client's
+// value followed by the server's.
+func (c *Conn) handshake() error {
+	session := make([]byte, 2*randomSize)
+	client, server := session[:randomSize], session[randomSize:]
+
+	if c.out.dir == clientToServer {
+		if _, err := io.ReadFull(c.rand, client); err != nil {
+			return err
+		}
+		if _, err := c.Conn.Write(client); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(c.Conn, server); err != nil {
+			return err
+		}
+	} else {
+		if _, err := io.ReadFull(c.Conn, client); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(c.rand, server); err != nil {
+			return err
+		}
+		if _, err := c.Conn.Write(server); err != nil {
+			return err
+		}
+	}
+
+	c.out.session, c.in.session = session, session
+	return nil
+}
+
+// Write seals p into one or more records and writes them to the underlying
+// connection.
+func (c *Conn) Write(p []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	if c.werr != nil {
+		return 0, c.werr
+	}
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for len(p) > 0 {
+		m := len(p)
+		if m > MaxRecordSize {
+			m = MaxRecordSize
+		}
+
+		ad, err := c.out.ad()
+		if err != nil {
+			c.werr = err
+			return n, err
+		}
+
+		c.wbuf = append(c.wbuf[:0], 0, 0, 0, 0)
+		c.wbuf = c.aead.Seal(c.wbuf, nil, p[:m], ad)
+		binary.BigEndian.PutUint32(c.wbuf, uint32(len(c.wbuf)-4))
+
+		// net.Conn writes either complete or return an error.
+		if _, err := c.Conn.Write(c.wbuf); err != nil {
+			// The peer's view of the sequence is now unknown.
+			c.werr = err
+			return n, err
+		}
+
+		n += m
+		p = p[m:]
+	}
+
+	return n, nil
+}
+
+// Read reads and opens records from the underlying connection, returning
+// their plaintext.
+func (c *Conn) Read(p []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	if err := c.Handshake(); err != nil {
+		return 0, err
+	}
+
+	for len(c.pending) == 0 {
+		if c.rerr != nil {
+			return 0, c.rerr
+		}
+
+		if err := c.readRecord(); err != nil {
+			c.rerr = err
+		}
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *Conn) readRecord() error {
+	var hdr [4]byte
+	if _, err := io.ReadFull(c.Conn, hdr[:]); err != nil {
+		return err
+	}
+
+	size := binary.BigEndian.Uint32(hdr[:])
+	if size < uint32(c.aead.Overhead()) || size > uint32(MaxRecordSize+c.aead.Overhead()) {
+		return ErrRecordSize
+	}
+
+	if cap(c.rbuf) < int(size) {
+		c.rbuf = make([]byte, MaxRecordSize+c.aead.Overhead())
+	}
+	c.rbuf = c.rbuf[:size]
+
+	if _, err := io.ReadFull(c.Conn, c.rbuf); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+
+	ad, err := c.in.ad()
+	if err != nil {
+		return err
+	}
+
+	c.pbuf, err = c.aead.Open(c.pbuf[:0], nil, c.rbuf, ad)
+	if err != nil {
+		return ErrRecordAuthentication
+	}
+	c.pending = c.pbuf
+	return nil
+}
@@ -0,0 +1,300 @@
+package sivconn
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"testing/iotest"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func newAEAD(t *testing.T) cipher.AEAD {
+	aead, err := siv.New([]byte("0123456789abcdef0123456789ABCDEF"), aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func TestEcho(t *testing.T) {
+	aead := newAEAD(t)
+	a, b := net.Pipe()
+	client, server := Client(a, aead), Server(b, aead)
+	defer client.Close()
+	defer server.Close()
+
+	go func() {
+		_, _ = io.Copy(server, server)
+	}()
+
+	message := bytes.Repeat([]byte("0123456789abcdef"), 3*MaxRecordSize/16+3)
+	go func() {
+		_, _ = client.Write(message[:100])
+		_, _ = client.Write(message[100:])
+	}()
+
+	actual := make([]byte, len(message))
+	if _, err := io.ReadFull(client, actual); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(actual, message) {
+		t.Error("Echoed message did not match")
+	}
+}
+
+// records reads n framed records from conn.
+func records(t *testing.T, conn net.Conn, n int) [][]byte {
+	var v [][]byte
+	for i := 0; i < n; i++ {
+		var hdr [4]byte
+		if _, err := io.ReadFull(conn, hdr[:]); err != nil {
+			t.Fatal(err)
+		}
+
+		r := make([]byte, 4+binary.BigEndian.Uint32(hdr[:]))
+		copy(r, hdr[:])
+		if _, err := io.ReadFull(conn, r[4:]); err != nil {
+			t.Fatal(err)
+		}
+		v = append(v, r)
+	}
+	return v
+}
+
+var (
+	clientRandom = bytes.Repeat([]byte{'c'}, randomSize)
+	serverRandom = bytes.Repeat([]byte{'s'}, randomSize)
+)
+
+// capture returns the records sent by a client writing the given messages,
+// with the random values clientRandom and serverRandom.
+func capture(t *testing.T, aead cipher.AEAD, messages ...string) [][]byte {
+	return captureWith(t, aead, clientRandom, serverRandom, messages...)
+}
+
+// captureWith is capture with the given random values.
+func captureWith(t *testing.T, aead cipher.AEAD, clientRandom, serverRandom []byte, messages ...string) [][]byte {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	go func() {
+		c := Client(a, aead, WithRand(bytes.NewReader(clientRandom)))
+		for _, m := range messages {
+			_, _ = c.Write([]byte(m))
+		}
+	}()
+
+	random := make([]byte, randomSize)
+	if _, err := io.ReadFull(b, random); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(random, clientRandom) {
+		t.Fatalf("Client random was %x, but expected %x", random, clientRandom)
+	}
+	if _, err := b.Write(serverRandom); err != nil {
+		t.Fatal(err)
+	}
+
+	return records(t, b, len(messages))
+}
+
+// send handshakes with a server as a client whose random value is
+// clientRandom, writes the given records, and closes conn.
+func send(conn net.Conn, records ...[]byte) {
+	defer conn.Close()
+
+	if _, err := conn.Write(clientRandom); err != nil {
+		return
+	}
+	if _, err := io.ReadFull(conn, make([]byte, randomSize)); err != nil {
+		return
+	}
+	for _, r := range records {
+		if _, err := conn.Write(r); err != nil {
+			return
+		}
+	}
+}
+
+// deliver writes the given records to a server whose random value is
+// serverRandom, and reads one message.
+func deliver(aead cipher.AEAD, records ...[]byte) ([]byte, error) {
+	return deliverTo(aead, serverRandom, records...)
+}
+
+// deliverTo is deliver with the server's random value.
+func deliverTo(aead cipher.AEAD, random []byte, records ...[]byte) ([]byte, error) {
+	a, b := net.Pipe()
+	defer b.Close()
+
+	go send(a, records...)
+
+	server := Server(b, aead, WithRand(bytes.NewReader(random)))
+	var actual []byte
+	buf := make([]byte, 64)
+	for range records {
+		n, err := server.Read(buf)
+		if err != nil {
+			return actual, err
+		}
+		actual = append(actual, buf[:n]...)
+	}
+	return actual, nil
+}
+
+func TestDeliver(t *testing.T) {
+	aead := newAEAD(t)
+	r := capture(t, aead, "one", "two")
+
+	actual, err := deliver(aead, r...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(actual) != "onetwo" {
+		t.Errorf("Plaintext was %q, but expected %q", actual, "onetwo")
+	}
+}
+
+func TestTampering(t *testing.T) {
+	aead := newAEAD(t)
+	r := capture(t, aead, "one")
+	r[0][len(r[0])-1] ^= 1
+
+	if _, err := deliver(aead, r...); err != ErrRecordAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrRecordAuthentication)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	aead := newAEAD(t)
+	r := capture(t, aead, "one", "two")
+
+	if _, err := deliver(aead, r[0], r[0]); err != ErrRecordAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrRecordAuthentication)
+	}
+}
+
+func TestReordering(t *testing.T) {
+	aead := newAEAD(t)
+	r := capture(t, aead, "one", "two")
+
+	if _, err := deliver(aead, r[1], r[0]); err != ErrRecordAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrRecordAuthentication)
+	}
+}
+
+func TestReflection(t *testing.T) {
+	aead := newAEAD(t)
+	r := capture(t, aead, "one")
+
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+
+	go func() {
+		_, _ = io.ReadFull(a, make([]byte, randomSize))
+		_, _ = a.Write(serverRandom)
+		_, _ = a.Write(r[0])
+	}()
+
+	if _, err := Client(b, aead, WithRand(bytes.NewReader(clientRandom))).Read(make([]byte, 64)); err != ErrRecordAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrRecordAuthentication)
+	}
+}
+
+func TestFatalErrors(t *testing.T) {
+	aead := newAEAD(t)
+	r := capture(t, aead, "one", "two")
+	r[0][len(r[0])-1] ^= 1
+
+	a, b := net.Pipe()
+	defer b.Close()
+
+	go send(a, r...)
+
+	server := Server(b, aead, WithRand(bytes.NewReader(serverRandom)))
+	for i := 0; i < 2; i++ {
+		if _, err := server.Read(make([]byte, 64)); err != ErrRecordAuthentication {
+			t.Errorf("Error was %v, but expected %v", err, ErrRecordAuthentication)
+		}
+	}
+}
+
+func TestOversizedRecord(t *testing.T) {
+	aead := newAEAD(t)
+	hdr := make([]byte, 4)
+	binary.BigEndian.PutUint32(hdr, MaxRecordSize+uint32(aead.Overhead())+1)
+
+	if _, err := deliver(aead, hdr); err != ErrRecordSize {
+		t.Errorf("Error was %v, but expected %v", err, ErrRecordSize)
+	}
+}
+
+func TestTruncatedRecord(t *testing.T) {
+	aead := newAEAD(t)
+	r := capture(t, aead, "one")
+
+	a, b := net.Pipe()
+	defer b.Close()
+
+	go send(a, r[0][:len(r[0])-1])
+
+	if _, err := Server(b, aead, WithRand(bytes.NewReader(serverRandom))).Read(make([]byte, 64)); err != io.ErrUnexpectedEOF {
+		t.Errorf("Error was %v, but expected %v", err, io.ErrUnexpectedEOF)
+	}
+}
+
+func TestSessionReplay(t *testing.T) {
+	aead := newAEAD(t)
+	r := capture(t, aead, "one", "two")
+
+	// A recorded session doesn't replay into a new connection.
+	other := bytes.Repeat([]byte{'S'}, randomSize)
+	if _, err := deliverTo(aead, other, r...); err != ErrRecordAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrRecordAuthentication)
+	}
+
+	// Nor are the same messages linkable across connections, unless both
+	// random values repeat.
+	if again := capture(t, aead, "one"); !bytes.Equal(again[0], r[0]) {
+		t.Errorf("Record was %x, but expected %x", again[0], r[0])
+	}
+	for _, randoms := range [][2][]byte{{other, serverRandom}, {clientRandom, other}} {
+		if again := captureWith(t, aead, randoms[0], randoms[1], "one"); bytes.Equal(again[0], r[0]) {
+			t.Errorf("Records in different sessions were both %x", r[0])
+		}
+	}
+}
+
+func TestHandshakeFailure(t *testing.T) {
+	aead := newAEAD(t)
+	a, b := net.Pipe()
+	defer b.Close()
+
+	go func() {
+		_, _ = a.Write(clientRandom[:5])
+		_ = a.Close()
+	}()
+
+	server := Server(b, aead)
+	for i := 0; i < 2; i++ {
+		if _, err := server.Write([]byte("hi")); err != io.ErrUnexpectedEOF {
+			t.Errorf("Error was %v, but expected %v", err, io.ErrUnexpectedEOF)
+		}
+	}
+
+	errRand := errors.New("no entropy")
+	client := Client(a, aead, WithRand(iotest.ErrReader(errRand)))
+	if _, err := client.Read(make([]byte, 1)); err != errRand {
+		t.Errorf("Error was %v, but expected %v", err, errRand)
+	}
+}
@@ -0,0 +1,253 @@
+package sivcose
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+)
+
+// The subset of CBOR (RFC 8949) needed for COSE_Encrypt0 and simple headers:
+// integers, byte and text strings, booleans, null, arrays, integer-keyed maps,
+// and tags, all with definite lengths.
+const (
+	majorUint   = 0
+	majorNegInt = 1
+	majorBytes  = 2
+	majorText   = 3
+	majorArray  = 4
+	majorMap    = 5
+	majorTag    = 6
+	majorSimple = 7
+)
+
+// maxDepth bounds the nesting of decoded arrays and maps.
+const maxDepth = 16
+
+var errCBOR = errors.New("sivcose: malformed CBOR")
+
+func appendHead(b []byte, major byte, n uint64) []byte {
+	m := major << 5
+	switch {
+	case n < 24:
+		return append(b, m|byte(n))
+	case n <= math.MaxUint8:
+		return append(b, m|24, byte(n))
+	case n <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(b, m|25), uint16(n))
+	case n <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(b, m|26), uint32(n))
+	default:
+		return binary.BigEndian.AppendUint64(append(b, m|27), n)
+	}
+}
+
+func appendInt(b []byte, v int64) []byte {
+	if v < 0 {
+		return appendHead(b, majorNegInt, uint64(-(v + 1)))
+	}
+	return appendHead(b, majorUint, uint64(v))
+}
+
+// appendValue encodes v, which must be an integer, []byte, string, bool, nil,
+// []any, or map[int]any.
+func appendValue(b []byte, v any) ([]byte, error) {
+	switch v := v.(type) {
+	case int:
+		return appendInt(b, int64(v)), nil
+	case int8:
+		return appendInt(b, int64(v)), nil
+	case int16:
+		return appendInt(b, int64(v)), nil
+	case int32:
+		return appendInt(b, int64(v)), nil
+	case int64:
+		return appendInt(b, v), nil
+	case uint:
+		return appendHead(b, majorUint, uint64(v)), nil
+	case uint8:
+		return appendHead(b, majorUint, uint64(v)), nil
+	case uint16:
+		return appendHead(b, majorUint, uint64(v)), nil
+	case uint32:
+		return appendHead(b, majorUint, uint64(v)), nil
+	case uint64:
+		return appendHead(b, majorUint, v), nil
+	case []byte:
+		return append(appendHead(b, majorBytes, uint64(len(v))), v...), nil
+	case string:
+		return append(appendHead(b, majorText, uint64(len(v))), v...), nil
+	case bool:
+		if v {
+			return append(b, majorSimple<<5|21), nil
+		}
+		return append(b, majorSimple<<5|20), nil
+	case nil:
+		return append(b, majorSimple<<5|22), nil
+	case []any:
+		b = appendHead(b, majorArray, uint64(len(v)))
+		for _, e := range v {
+			var err error
+			if b, err = appendValue(b, e); err != nil {
+				return nil, err
+			}
+		}
+		return b, nil
+	case map[int]any:
+		return appendMap(b, v)
+	default:
+		return nil, fmt.Errorf("sivcose: unsupported header value type %T", v)
+	}
+}
+
+// appendMap encodes m with its keys in the deterministic order of RFC 8949
+// section 4.2.1, so that equal maps always encode identically.
+func appendMap(b []byte, m map[int]any) ([]byte, error) {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(appendInt(nil, int64(keys[i])), appendInt(nil, int64(keys[j]))) < 0
+	})
+
+	b = appendHead(b, majorMap, uint64(len(m)))
+	for _, k := range keys {
+		b = appendInt(b, int64(k))
+
+		var err error
+		if b, err = appendValue(b, m[k]); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+type decoder struct {
+	b     []byte
+	depth int
+}
+
+func (d *decoder) head() (major byte, n uint64, err error) {
+	if len(d.b) == 0 {
+		return 0, 0, errCBOR
+	}
+	major, info := d.b[0]>>5, d.b[0]&0x1f
+	d.b = d.b[1:]
+
+	var size int
+	switch {
+	case info < 24:
+		return major, uint64(info), nil
+	case info == 24:
+		size = 1
+	case info == 25:
+		size = 2
+	case info == 26:
+		size = 4
+	case info == 27:
+		size = 8
+	default:
+		// Indefinite lengths and reserved values.
+		return 0, 0, errCBOR
+	}
+
+	if len(d.b) < size {
+		return 0, 0, errCBOR
+	}
+	for _, c := range d.b[:size] {
+		n = n<<8 | uint64(c)
+	}
+	d.b = d.b[size:]
+	return major, n, nil
+}
+
+func (d *decoder) bytes(n uint64) ([]byte, error) {
+	if n > uint64(len(d.b)) {
+		return nil, errCBOR
+	}
+	v := d.b[:n:n]
+	d.b = d.b[n:]
+	return v, nil
+}
+
+// value decodes a single item. Integers decode as int64, or uint64 if too
+// large for an int64.
+func (d *decoder) value() (any, error) {
+	major, n, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+
+	switch major {
+	case majorUint:
+		if n > math.MaxInt64 {
+			return n, nil
+		}
+		return int64(n), nil
+	case majorNegInt:
+		if n > math.MaxInt64 {
+			return nil, errCBOR
+		}
+		return -1 - int64(n), nil
+	case majorBytes:
+		return d.bytes(n)
+	case majorText:
+		v, err := d.bytes(n)
+		return string(v), err
+	case majorArray:
+		if d.depth++; d.depth > maxDepth || n > uint64(len(d.b)) {
+			return nil, errCBOR
+		}
+		defer func() { d.depth-- }()
+
+		a := make([]any, 0, n)
+		for i := uint64(0); i < n; i++ {
+			v, err := d.value()
+			if err != nil {
+				return nil, err
+			}
+			a = append(a, v)
+		}
+		return a, nil
+	case majorMap:
+		if d.depth++; d.depth > maxDepth || n > uint64(len(d.b)) {
+			return nil, errCBOR
+		}
+		defer func() { d.depth-- }()
+
+		m := make(map[int]any, n)
+		for i := uint64(0); i < n; i++ {
+			k, err := d.value()
+			if err != nil {
+				return nil, err
+			}
+			ki, ok := k.(int64)
+			if !ok || int64(int(ki)) != ki {
+				return nil, errCBOR
+			}
+			if _, dup := m[int(ki)]; dup {
+				return nil, errCBOR
+			}
+
+			if m[int(ki)], err = d.value(); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case majorSimple:
+		switch n {
+		case 20:
+			return false, nil
+		case 21:
+			return true, nil
+		case 22:
+			return nil, nil
+		}
+	}
+
+	// Tags are handled by the caller, and floats aren't supported.
+	return nil, errCBOR
+}
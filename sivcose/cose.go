@@ -0,0 +1,164 @@
+// Package sivcose frames SIV-sealed payloads as COSE_Encrypt0 structures (RFC
+// 9052 section 5.2), for exchange with devices which speak CBOR and COSE.
+//
+// A message is the CBOR array [protected, unprotected, ciphertext], tagged 16,
+// where protected is the encoded protected header map as a byte string and
+// unprotected is a map. As COSE prescribes, the associated data is the
+// Enc_structure ["Encrypt0", protected, external_aad], so the protected header
+// is authenticated but the unprotected header is not.
+//
+// SIV has no registered COSE algorithm, so the protected header always carries
+// the private-use identifier Algorithm under label 1 (alg). The payload is
+// sealed without a nonce, so equal payloads and headers seal identically.
+//
+// Header values may be integers, byte and text strings, booleans, nil, and
+// arrays or integer-keyed maps of these. Decoded integers are int64, or uint64
+// if too large for an int64.
+package sivcose
+
+import (
+	"crypto/cipher"
+	"errors"
+)
+
+// Algorithm is the private-use COSE algorithm identifier for SIV-CMAC, with a
+// key size given by the key itself.
+const Algorithm = -65537
+
+const (
+	// HeaderAlgorithm is the COSE header label for the algorithm.
+	HeaderAlgorithm = 1
+
+	// HeaderKeyID is the COSE header label for the key ID.
+	HeaderKeyID = 4
+
+	encrypt0Tag = 16
+)
+
+var (
+	// ErrMalformed is returned by Open when a message is not a well-formed
+	// COSE_Encrypt0 structure.
+	ErrMalformed = errors.New("sivcose: malformed COSE_Encrypt0 message")
+
+	// ErrAlgorithm is returned by Seal when the protected header names an
+	// algorithm other than Algorithm, and by Open when a message does.
+	ErrAlgorithm = errors.New("sivcose: unsupported algorithm")
+)
+
+// Seal encrypts payload and returns a tagged COSE_Encrypt0 message with the
+// given headers, either of which may be nil. The protected header is
+// authenticated, and Algorithm is added to it if it is absent.
+func Seal(aead cipher.AEAD, payload []byte, protected, unprotected map[int]any) ([]byte, error) {
+	return SealExternal(aead, payload, nil, protected, unprotected)
+}
+
+// SealExternal is like Seal, but also authenticates external, the externally
+// supplied data of COSE, which must be passed to OpenExternal.
+func SealExternal(aead cipher.AEAD, payload, external []byte, protected, unprotected map[int]any) ([]byte, error) {
+	p := make(map[int]any, len(protected)+1)
+	for k, v := range protected {
+		p[k] = v
+	}
+	if alg, ok := p[HeaderAlgorithm]; !ok {
+		p[HeaderAlgorithm] = Algorithm
+	} else if !isAlgorithm(alg) {
+		return nil, ErrAlgorithm
+	}
+
+	encoded, err := appendMap(nil, p)
+	if err != nil {
+		return nil, err
+	}
+
+	if unprotected == nil {
+		unprotected = map[int]any{}
+	}
+
+	msg := appendHead(nil, majorTag, encrypt0Tag)
+	msg = appendHead(msg, majorArray, 3)
+	msg, _ = appendValue(msg, encoded)
+	if msg, err = appendMap(msg, unprotected); err != nil {
+		return nil, err
+	}
+
+	ciphertext := aead.Seal(nil, nil, payload, encStructure(encoded, external))
+	msg, _ = appendValue(msg, ciphertext)
+	return msg, nil
+}
+
+// Open authenticates and decrypts a COSE_Encrypt0 message, tagged or not,
+// returning the payload and both headers.
+func Open(aead cipher.AEAD, msg []byte) (payload []byte, protected, unprotected map[int]any, err error) {
+	return OpenExternal(aead, msg, nil)
+}
+
+// OpenExternal is like Open, for messages sealed with SealExternal.
+func OpenExternal(aead cipher.AEAD, msg, external []byte) (payload []byte, protected, unprotected map[int]any, err error) {
+	d := &decoder{b: msg}
+	if len(msg) > 0 && msg[0]>>5 == majorTag {
+		if major, n, err := d.head(); err != nil || major != majorTag || n != encrypt0Tag {
+			return nil, nil, nil, ErrMalformed
+		}
+	}
+
+	v, err := d.value()
+	if err != nil || len(d.b) != 0 {
+		return nil, nil, nil, ErrMalformed
+	}
+
+	a, ok := v.([]any)
+	if !ok || len(a) != 3 {
+		return nil, nil, nil, ErrMalformed
+	}
+	encoded, ok1 := a[0].([]byte)
+	unprotected, ok2 := a[1].(map[int]any)
+	ciphertext, ok3 := a[2].([]byte)
+	if !ok1 || !ok2 || !ok3 {
+		return nil, nil, nil, ErrMalformed
+	}
+
+	protected = map[int]any{}
+	if len(encoded) > 0 {
+		pd := &decoder{b: encoded}
+		pv, err := pd.value()
+		if err != nil || len(pd.b) != 0 {
+			return nil, nil, nil, ErrMalformed
+		}
+		if protected, ok = pv.(map[int]any); !ok {
+			return nil, nil, nil, ErrMalformed
+		}
+	}
+	if !isAlgorithm(protected[HeaderAlgorithm]) {
+		return nil, nil, nil, ErrAlgorithm
+	}
+
+	payload, err = aead.Open(nil, nil, ciphertext, encStructure(encoded, external))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return payload, protected, unprotected, nil
+}
+
+// encStructure returns the encoded Enc_structure which COSE_Encrypt0 uses as
+// associated data.
+func encStructure(protected, external []byte) []byte {
+	if external == nil {
+		external = []byte{}
+	}
+
+	b := appendHead(nil, majorArray, 3)
+	b, _ = appendValue(b, "Encrypt0")
+	b, _ = appendValue(b, protected)
+	b, _ = appendValue(b, external)
+	return b
+}
+
+func isAlgorithm(v any) bool {
+	switch v := v.(type) {
+	case int:
+		return v == Algorithm
+	case int64:
+		return v == Algorithm
+	}
+	return false
+}
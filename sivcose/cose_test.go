@@ -0,0 +1,233 @@
+package sivcose
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"reflect"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func newAEAD(t *testing.T) cipher.AEAD {
+	key, _ := hex.DecodeString("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	aead, err := siv.New(key, aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func TestSealLayout(t *testing.T) {
+	// 16([h'a3013a0001000004426b3120f5', {33: "x"}, h'...']), where the
+	// protected header is {1: -65537, 4: h'6b31', -1: true} and the ciphertext
+	// was computed independently over the Enc_structure
+	// ["Encrypt0", h'a301...', h''].
+	expected, _ := hex.DecodeString("d0834da3013a0001000004426b3120f5a118216178581a82e5492b3df8a03080d96b1ee9a2e463e7c7a049a8a3bd21b006")
+
+	actual, err := Seal(newAEAD(t), []byte("hello cose"),
+		map[int]any{HeaderKeyID: []byte("k1"), -1: true},
+		map[int]any{33: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(actual, expected) {
+		t.Errorf("Message was %x, but expected %x", actual, expected)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	aead := newAEAD(t)
+	protected := map[int]any{HeaderKeyID: []byte("k1"), -70000: "big", 2: []any{int64(1), "two"}}
+	unprotected := map[int]any{5: []byte{1, 2, 3}, 300: map[int]any{1: false, 2: nil}}
+
+	msg, err := Seal(aead, []byte("payload"), protected, unprotected)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, p, u, err := Open(aead, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(payload) != "payload" {
+		t.Errorf("Payload was %q, but expected %q", payload, "payload")
+	}
+
+	protected[HeaderAlgorithm] = int64(Algorithm)
+	if !reflect.DeepEqual(p, protected) {
+		t.Errorf("Protected header was %v, but expected %v", p, protected)
+	}
+
+	if !reflect.DeepEqual(u, unprotected) {
+		t.Errorf("Unprotected header was %v, but expected %v", u, unprotected)
+	}
+
+	// The tag is optional.
+	if _, _, _, err := Open(aead, msg[1:]); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestEmptyHeaders(t *testing.T) {
+	aead := newAEAD(t)
+
+	msg, err := Seal(aead, nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, p, u, err := Open(aead, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(payload) != 0 || len(p) != 1 || len(u) != 0 {
+		t.Errorf("Opened %x, %v, %v", payload, p, u)
+	}
+}
+
+func TestExternal(t *testing.T) {
+	aead := newAEAD(t)
+
+	msg, err := SealExternal(aead, []byte("payload"), []byte("device-42"), nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, _, err := OpenExternal(aead, msg, []byte("device-42")); err != nil {
+		t.Error(err)
+	}
+
+	for _, external := range [][]byte{nil, []byte("device-43")} {
+		if payload, _, _, err := OpenExternal(aead, msg, external); err != siv.ErrAuthentication {
+			t.Errorf("Error was %v, but expected %v (payload %x)", err, siv.ErrAuthentication, payload)
+		}
+	}
+}
+
+func TestProtectedHeaderTampering(t *testing.T) {
+	aead := newAEAD(t)
+
+	// Substitute a different protected header, of the same length, with the
+	// same algorithm.
+	msg, _ := Seal(aead, []byte("payload"), map[int]any{HeaderKeyID: []byte("k1")}, nil)
+	tampered := bytes.Replace(msg, []byte("k1"), []byte("k2"), 1)
+
+	if payload, _, _, err := Open(aead, tampered); err != siv.ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v (payload %x)", err, siv.ErrAuthentication, payload)
+	}
+
+	// Moving a header to the unprotected header fails too.
+	_, _, _, err := Open(aead, msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &decoder{b: msg[1:]}
+	v, _ := d.value()
+	ciphertext := v.([]any)[2].([]byte)
+
+	protected, _ := appendMap(nil, map[int]any{HeaderAlgorithm: Algorithm})
+	moved := appendHead(nil, majorArray, 3)
+	moved, _ = appendValue(moved, protected)
+	moved, _ = appendMap(moved, map[int]any{HeaderKeyID: []byte("k1")})
+	moved, _ = appendValue(moved, ciphertext)
+
+	if payload, _, _, err := Open(aead, moved); err != siv.ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v (payload %x)", err, siv.ErrAuthentication, payload)
+	}
+}
+
+func TestUnprotectedHeaderNotAuthenticated(t *testing.T) {
+	aead := newAEAD(t)
+
+	msg, _ := Seal(aead, []byte("payload"), nil, map[int]any{33: "a"})
+	tampered := bytes.Replace(msg, []byte{0x61, 'a'}, []byte{0x61, 'b'}, 1)
+
+	_, _, u, err := Open(aead, tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if u[33] != "b" {
+		t.Errorf("Unprotected header was %v, but expected %v", u, map[int]any{33: "b"})
+	}
+}
+
+func TestAlgorithm(t *testing.T) {
+	aead := newAEAD(t)
+
+	if _, err := Seal(aead, nil, map[int]any{HeaderAlgorithm: 1}, nil); err != ErrAlgorithm {
+		t.Errorf("Error was %v, but expected %v", err, ErrAlgorithm)
+	}
+
+	if _, err := Seal(aead, nil, map[int]any{HeaderAlgorithm: Algorithm}, nil); err != nil {
+		t.Error(err)
+	}
+
+	// An AES-GCM (alg 1) message with an empty protected header.
+	msg, _ := hex.DecodeString("d08340a10101581a" + hex.EncodeToString(make([]byte, 26)))
+	if _, _, _, err := Open(aead, msg); err != ErrAlgorithm {
+		t.Errorf("Error was %v, but expected %v", err, ErrAlgorithm)
+	}
+}
+
+func TestOpenMalformed(t *testing.T) {
+	aead := newAEAD(t)
+	msg, _ := Seal(aead, []byte("payload"), nil, nil)
+
+	for _, v := range []string{
+		"",
+		"d0",
+		"d1834340a040",      // wrong tag
+		"d08240a0",          // two elements
+		"d0834040a040",      // unprotected not a map
+		"d083430102034040",  // protected not a map
+		"d0834040a0",        // truncated
+		"d0839f40a040ff",    // indefinite length
+		"d083" + "41f9a040", // float in protected header
+		hex.EncodeToString(msg) + "00",
+	} {
+		b, _ := hex.DecodeString(v)
+		if _, _, _, err := Open(aead, b); err != ErrMalformed {
+			t.Errorf("Error for %s was %v, but expected %v", v, err, ErrMalformed)
+		}
+	}
+}
+
+func TestUnsupportedHeaderValue(t *testing.T) {
+	if _, err := Seal(newAEAD(t), nil, map[int]any{3: 1.5}, nil); err == nil {
+		t.Error("Float header value encoded")
+	}
+}
+
+func TestCBORIntegers(t *testing.T) {
+	for _, v := range []struct {
+		n        int64
+		expected string
+	}{
+		{0, "00"},
+		{23, "17"},
+		{24, "1818"},
+		{256, "190100"},
+		{65536, "1a00010000"},
+		{1 << 32, "1b0000000100000000"},
+		{-1, "20"},
+		{-25, "3818"},
+		{-65537, "3a00010000"},
+	} {
+		actual := appendInt(nil, v.n)
+		if hex.EncodeToString(actual) != v.expected {
+			t.Errorf("Encoding of %d was %x, but expected %s", v.n, actual, v.expected)
+		}
+
+		d, err := (&decoder{b: actual}).value()
+		if err != nil || d != v.n {
+			t.Errorf("Decoding of %x was %v, %v, but expected %d", actual, d, err, v.n)
+		}
+	}
+}
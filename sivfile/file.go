@@ -0,0 +1,479 @@
+// Package sivfile implements a passphrase-protected file format, so that a
+// file can be encrypted and handed to someone with nothing more than a
+// passphrase, and decrypted again without knowing how it was encrypted.
+//
+// A file is a header followed by the plaintext split into chunks, each sealed
+// separately. The header is self-describing:
+//
+//	magic       "SIVF"
+//	version     1 byte, currently 1
+//	kdf         1 byte, 1 for Argon2id or 2 for PBKDF2
+//	parameters  the KDF's parameters, as below
+//	salt length 1 byte
+//	salt        16 to 64 bytes
+//	chunk size  4 bytes
+//
+// with integers big-endian. The parameters of Argon2id, the default, are
+//
+//	time        4 bytes, Argon2id passes
+//	memory      4 bytes, Argon2id memory in KiB
+//	threads     1 byte, Argon2id parallelism
+//
+// and those of PBKDF2, for environments which allow only approved KDFs, are
+//
+//	iterations  4 bytes
+//	hash        1 byte, 1 for HMAC-SHA-256 or 2 for HMAC-SHA-512
+//
+// The key is derived from the passphrase and header with the KDF and used with
+// AES-SIV-CMAC-512. Each chunk holds chunk size
+// bytes of plaintext except the last, which is shorter and may be empty, and
+// is sealed with its index and whether it is the last as the nonce; the first
+// chunk also has the whole header as associated data, so that tampering with
+// the header is detected even where it doesn't change the key. Chunks can't be
+// reordered or dropped, and a file truncated at a chunk boundary is detected.
+//
+// The KDF parameters are chosen by whoever encrypts a file, so Decrypt
+// refuses parameters above MaxTime, MaxMemory, MaxThreads, and
+// MaxPBKDF2Iterations before deriving a key, rather than let a hostile file
+// use unbounded CPU and memory.
+package sivfile
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/pbkdf2"
+
+	siv "github.com/stripe/siv-go"
+)
+
+const (
+	// DefaultTime, DefaultMemory, and DefaultThreads are the Argon2id
+	// parameters used unless WithArgon2 is given, as recommended by RFC 9106
+	// section 4 for memory-constrained environments.
+	DefaultTime    = 3
+	DefaultMemory  = 64 << 10 // KiB
+	DefaultThreads = 4
+
+	// MaxTime, MaxMemory, and MaxThreads are the largest Argon2id parameters
+	// accepted.
+	MaxTime    = 32
+	MaxMemory  = 1 << 20 // KiB
+	MaxThreads = 64
+
+	// MinPBKDF2SHA256Iterations and MinPBKDF2SHA512Iterations are the
+	// fewest PBKDF2 iterations accepted with each hash, as recommended by
+	// OWASP in 2023. MaxPBKDF2Iterations is the most accepted with either.
+	MinPBKDF2SHA256Iterations = 600000
+	MinPBKDF2SHA512Iterations = 210000
+	MaxPBKDF2Iterations       = 10000000
+
+	// DefaultChunkSize is the chunk size used unless WithChunkSize is given, in
+	// bytes.
+	DefaultChunkSize = 64 << 10
+
+	// MaxChunkSize is the largest chunk size accepted, in bytes.
+	MaxChunkSize = 16 << 20
+
+	version = 1
+
+	// kdfArgon2id identifies Argon2id with the parameters time, memory, and
+	// threads.
+	kdfArgon2id = 1
+
+	// kdfPBKDF2 identifies PBKDF2 with the parameters iterations and hash.
+	kdfPBKDF2 = 2
+
+	// The PBKDF2 hash identifiers.
+	pbkdf2SHA256 = 1
+	pbkdf2SHA512 = 2
+
+	saltSize         = 16
+	minSaltSize      = 16
+	maxSaltSize      = 64
+	keySize          = 64
+	prefixSize       = 4 + 1 + 1
+	fixedHeaderSize  = prefixSize + 4 + 4 + 1 + 1 // with Argon2id
+	pbkdf2HeaderSize = prefixSize + 4 + 1 + 1
+	chunkSizeSize    = 4
+	chunkNonceLength = 8 + 1
+)
+
+var magic = [4]byte{'S', 'I', 'V', 'F'}
+
+var (
+	// ErrFormat is returned by Decrypt for input which isn't a file of a
+	// supported version and KDF.
+	ErrFormat = errors.New("sivfile: invalid or unsupported header")
+
+	// ErrParams is returned for KDF parameters or a chunk size which are out
+	// of bounds.
+	ErrParams = errors.New("sivfile: parameters out of bounds")
+
+	// ErrPassphrase is returned by Decrypt when the first chunk fails to
+	// authenticate, which is almost always because the passphrase is wrong,
+	// but may be because the header or first chunk was altered.
+	ErrPassphrase = fmt.Errorf("sivfile: wrong passphrase or corrupted file: %w", siv.ErrAuthentication)
+
+	// ErrTruncated is returned by Decrypt when the input ends before its last
+	// chunk.
+	ErrTruncated = errors.New("sivfile: truncated file")
+)
+
+// ChunkError is returned by Decrypt when a chunk after the first fails to
+// authenticate.
+type ChunkError struct {
+	Index uint64
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("sivfile: chunk %d failed to authenticate", e.Index)
+}
+
+// Unwrap returns siv.ErrAuthentication.
+func (e *ChunkError) Unwrap() error {
+	return siv.ErrAuthentication
+}
+
+// An Option configures Encrypt.
+type Option func(*options)
+
+type options struct {
+	kdf          byte
+	time, memory uint32
+	threads      uint8
+	iterations   uint32
+	hash         crypto.Hash
+	chunkSize    int
+	rand         io.Reader
+}
+
+// WithArgon2 derives the key with Argon2id, the default, with the given
+// parameters: the number of passes, the memory in KiB, and the parallelism.
+// They are recorded in the header, and must not exceed MaxTime, MaxMemory,
+// and MaxThreads.
+func WithArgon2(time, memory uint32, threads uint8) Option {
+	return func(o *options) {
+		o.kdf = kdfArgon2id
+		o.time, o.memory, o.threads = time, memory, threads
+	}
+}
+
+// WithPBKDF2 derives the key with PBKDF2 (RFC 8018) instead of Argon2id, for
+// environments such as FIPS 140 deployments which allow only approved KDFs.
+// h must be crypto.SHA256 or crypto.SHA512, and iterations must be at least
+// MinPBKDF2SHA256Iterations or MinPBKDF2SHA512Iterations respectively, and at
+// most MaxPBKDF2Iterations. They are recorded in the header, so Decrypt needs
+// no option to read the file.
+func WithPBKDF2(iterations uint32, h crypto.Hash) Option {
+	return func(o *options) {
+		o.kdf = kdfPBKDF2
+		o.iterations, o.hash = iterations, h
+	}
+}
+
+// WithChunkSize sets the number of plaintext bytes in each chunk, which is
+// also the most plaintext Decrypt buffers at once.
+func WithChunkSize(n int) Option {
+	return func(o *options) {
+		o.chunkSize = n
+	}
+}
+
+// WithRand makes Encrypt read the salt from r rather than crypto/rand, as for
+// entropy from an HSM or for deterministic tests. Encrypt reads exactly 16
+// bytes from r, before writing anything, and fails if r returns fewer.
+func WithRand(r io.Reader) Option {
+	return func(o *options) {
+		o.rand = r
+	}
+}
+
+// Encrypt reads src until EOF and writes it to dst encrypted under a key
+// derived from passphrase, with a new random salt.
+func Encrypt(dst io.Writer, src io.Reader, passphrase []byte, opts ...Option) error {
+	o := options{
+		kdf:       kdfArgon2id,
+		time:      DefaultTime,
+		memory:    DefaultMemory,
+		threads:   DefaultThreads,
+		chunkSize: DefaultChunkSize,
+		rand:      rand.Reader,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	h := header{
+		kdf:       o.kdf,
+		time:      o.time,
+		memory:    o.memory,
+		threads:   o.threads,
+		salt:      make([]byte, saltSize),
+		chunkSize: o.chunkSize,
+	}
+	if o.kdf == kdfPBKDF2 {
+		h.time, h.memory, h.threads = 0, 0, 0
+		h.iterations = o.iterations
+		switch o.hash {
+		case crypto.SHA256:
+			h.hash = pbkdf2SHA256
+		case crypto.SHA512:
+			h.hash = pbkdf2SHA512
+		default:
+			return fmt.Errorf("%w: PBKDF2 hash %v", ErrParams, o.hash)
+		}
+	}
+	if err := h.check(); err != nil {
+		return err
+	}
+	if _, err := io.ReadFull(o.rand, h.salt); err != nil {
+		return err
+	}
+
+	encoded := h.marshal()
+	if _, err := dst.Write(encoded); err != nil {
+		return err
+	}
+
+	aead := h.aead(passphrase)
+	buf := make([]byte, h.chunkSize)
+	out := make([]byte, 0, h.chunkSize+aead.Overhead())
+	ad := encoded
+	for i := uint64(0); ; i++ {
+		n, err := io.ReadFull(src, buf)
+		final := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !final {
+			return err
+		}
+
+		out = aead.Seal(out[:0], chunkNonce(i, final), buf[:n], ad)
+		if _, err := dst.Write(out); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+		ad = nil
+	}
+}
+
+// Decrypt reads a file encrypted by Encrypt from src and writes its plaintext
+// to dst. Each chunk is written only once it has been authenticated, but if
+// Decrypt returns an error after writing some chunks, dst has received only
+// part of the plaintext and should be discarded.
+func Decrypt(dst io.Writer, src io.Reader, passphrase []byte) error {
+	r := bufio.NewReader(src)
+
+	h, encoded, err := readHeader(r)
+	if err != nil {
+		return err
+	}
+
+	aead := h.aead(passphrase)
+	buf := make([]byte, h.chunkSize+aead.Overhead())
+	ad := encoded
+	for i := uint64(0); ; i++ {
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			// The last chunk is always shorter than a full one, so the
+			// input can't end after a full chunk.
+			return ErrTruncated
+		}
+		// Only the last chunk can be short.
+		final := err == io.ErrUnexpectedEOF
+		if err != nil && !final {
+			return err
+		}
+
+		plaintext, err := aead.Open(buf[:0], chunkNonce(i, final), buf[:n], ad)
+		if err != nil {
+			if i == 0 {
+				return ErrPassphrase
+			}
+			return &ChunkError{Index: i}
+		}
+		if _, err := dst.Write(plaintext); err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+		ad = nil
+	}
+}
+
+// chunkNonce returns the nonce binding a chunk to its position.
+func chunkNonce(i uint64, final bool) []byte {
+	nonce := make([]byte, chunkNonceLength)
+	binary.BigEndian.PutUint64(nonce, i)
+	if final {
+		nonce[8] = 1
+	}
+	return nonce
+}
+
+// header holds the parameters of a file.
+type header struct {
+	kdf byte
+
+	// Argon2id
+	time, memory uint32
+	threads      uint8
+
+	// PBKDF2
+	iterations uint32
+	hash       byte
+
+	salt      []byte
+	chunkSize int
+}
+
+// check returns ErrParams if any of h's parameters are out of bounds.
+func (h *header) check() error {
+	if h.kdf == kdfPBKDF2 {
+		return h.checkPBKDF2()
+	}
+
+	switch {
+	case h.time < 1 || h.time > MaxTime:
+		return fmt.Errorf("%w: Argon2id time %d", ErrParams, h.time)
+	case h.threads < 1 || h.threads > MaxThreads:
+		return fmt.Errorf("%w: Argon2id threads %d", ErrParams, h.threads)
+	case h.memory < 8*uint32(h.threads) || h.memory > MaxMemory:
+		return fmt.Errorf("%w: Argon2id memory %d KiB", ErrParams, h.memory)
+	}
+	return h.checkCommon()
+}
+
+// checkPBKDF2 is check for a header with PBKDF2 parameters.
+func (h *header) checkPBKDF2() error {
+	least := uint32(MinPBKDF2SHA256Iterations)
+	if h.hash == pbkdf2SHA512 {
+		least = MinPBKDF2SHA512Iterations
+	}
+
+	switch {
+	case h.hash != pbkdf2SHA256 && h.hash != pbkdf2SHA512:
+		return fmt.Errorf("%w: PBKDF2 hash %d", ErrParams, h.hash)
+	case h.iterations < least || h.iterations > MaxPBKDF2Iterations:
+		return fmt.Errorf("%w: PBKDF2 iterations %d", ErrParams, h.iterations)
+	}
+	return h.checkCommon()
+}
+
+// checkCommon checks the parameters which don't depend on the KDF.
+func (h *header) checkCommon() error {
+	switch {
+	case len(h.salt) < minSaltSize || len(h.salt) > maxSaltSize:
+		return fmt.Errorf("%w: salt size %d", ErrParams, len(h.salt))
+	case h.chunkSize < 1 || h.chunkSize > MaxChunkSize:
+		return fmt.Errorf("%w: chunk size %d", ErrParams, h.chunkSize)
+	}
+	return nil
+}
+
+func (h *header) marshal() []byte {
+	b := append(magic[:0:0], magic[:]...)
+	b = append(b, version, h.kdf)
+	if h.kdf == kdfPBKDF2 {
+		b = binary.BigEndian.AppendUint32(b, h.iterations)
+		b = append(b, h.hash, byte(len(h.salt)))
+	} else {
+		b = binary.BigEndian.AppendUint32(b, h.time)
+		b = binary.BigEndian.AppendUint32(b, h.memory)
+		b = append(b, h.threads, byte(len(h.salt)))
+	}
+	b = append(b, h.salt...)
+	return binary.BigEndian.AppendUint32(b, uint32(h.chunkSize))
+}
+
+// readHeader reads and checks a header, returning it along with its encoding.
+func readHeader(r io.Reader) (header, []byte, error) {
+	b := make([]byte, prefixSize, fixedHeaderSize)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return header{}, nil, unexpected(err)
+	}
+	if [4]byte(b[:4]) != magic || b[4] != version {
+		return header{}, nil, ErrFormat
+	}
+
+	h := header{kdf: b[5]}
+	switch h.kdf {
+	case kdfArgon2id:
+		b = b[:fixedHeaderSize]
+	case kdfPBKDF2:
+		b = b[:pbkdf2HeaderSize]
+	default:
+		return header{}, nil, ErrFormat
+	}
+	if _, err := io.ReadFull(r, b[prefixSize:]); err != nil {
+		return header{}, nil, unexpected(err)
+	}
+
+	if h.kdf == kdfPBKDF2 {
+		h.iterations = binary.BigEndian.Uint32(b[6:])
+		h.hash = b[10]
+	} else {
+		h.time = binary.BigEndian.Uint32(b[6:])
+		h.memory = binary.BigEndian.Uint32(b[10:])
+		h.threads = b[14]
+	}
+	h.salt = make([]byte, b[len(b)-1])
+
+	rest := make([]byte, len(h.salt)+chunkSizeSize)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return header{}, nil, unexpected(err)
+	}
+	copy(h.salt, rest)
+	size := binary.BigEndian.Uint32(rest[len(h.salt):])
+	if size > MaxChunkSize {
+		return header{}, nil, fmt.Errorf("%w: chunk size %d", ErrParams, size)
+	}
+	h.chunkSize = int(size)
+
+	if err := h.check(); err != nil {
+		return header{}, nil, err
+	}
+	return h, append(b, rest...), nil
+}
+
+// unexpected returns ErrFormat for input which ends within the header.
+func unexpected(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrFormat
+	}
+	return err
+}
+
+// aead derives the file key from passphrase with h's parameters.
+func (h *header) aead(passphrase []byte) cipher.AEAD {
+	derived := h.derive(passphrase)
+
+	var key siv.Key512
+	copy(key[:], derived)
+	for i := range derived {
+		derived[i] = 0
+	}
+	return siv.NewAEAD512(key)
+}
+
+// derive derives keySize bytes from passphrase with h's KDF and parameters.
+func (h *header) derive(passphrase []byte) []byte {
+	switch {
+	case h.kdf == kdfPBKDF2 && h.hash == pbkdf2SHA512:
+		return pbkdf2.Key(passphrase, h.salt, int(h.iterations), keySize, sha512.New)
+	case h.kdf == kdfPBKDF2:
+		return pbkdf2.Key(passphrase, h.salt, int(h.iterations), keySize, sha256.New)
+	}
+	return argon2.IDKey(passphrase, h.salt, h.time, h.memory, h.threads, keySize)
+}
@@ -0,0 +1,345 @@
+package sivfile
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+var (
+	passphrase = []byte("correct horse battery staple")
+
+	// fast makes key derivation cheap enough for tests.
+	fast = WithArgon2(1, 64, 1)
+
+	// cheapPBKDF2 is the cheapest PBKDF2 allowed.
+	cheapPBKDF2 = WithPBKDF2(MinPBKDF2SHA512Iterations, crypto.SHA512)
+)
+
+// fixedSalt makes Encrypt use a salt of incrementing bytes.
+func fixedSalt(o *options) {
+	salt := make([]byte, saltSize)
+	for i := range salt {
+		salt[i] = byte(i)
+	}
+	WithRand(bytes.NewReader(salt))(o)
+}
+
+func encrypt(t *testing.T, plaintext []byte, opts ...Option) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	opts = append([]Option{fast, WithChunkSize(32)}, opts...)
+	if err := Encrypt(&buf, bytes.NewReader(plaintext), passphrase, opts...); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, n := range []int{0, 1, 31, 32, 33, 64, 100} {
+		plaintext := bytes.Repeat([]byte{'a'}, n)
+		file := encrypt(t, plaintext)
+
+		var buf bytes.Buffer
+		if err := Decrypt(&buf, bytes.NewReader(file), passphrase); err != nil {
+			t.Fatalf("%d bytes: %v", n, err)
+		}
+		if !bytes.Equal(buf.Bytes(), plaintext) {
+			t.Errorf("Plaintext was %x, but expected %x", buf.Bytes(), plaintext)
+		}
+	}
+}
+
+func TestGolden(t *testing.T) {
+	expected, err := os.ReadFile("testdata/golden.sivf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("The quick brown fox jumps over the lazy dog, then does it again, and again, and again.")
+
+	if actual := encrypt(t, plaintext, fixedSalt); !bytes.Equal(actual, expected) {
+		t.Errorf("File was %x, but expected %x", actual, expected)
+	}
+
+	var buf bytes.Buffer
+	if err := Decrypt(&buf, bytes.NewReader(expected), passphrase); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), plaintext) {
+		t.Errorf("Plaintext was %q, but expected %q", buf.Bytes(), plaintext)
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encrypt(&buf, bytes.NewReader(nil), passphrase, fixedSalt); err != nil {
+		t.Fatal(err)
+	}
+
+	h, _, err := readHeader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.time != DefaultTime || h.memory != DefaultMemory || h.threads != DefaultThreads || h.chunkSize != DefaultChunkSize {
+		t.Errorf("Header was %+v, but expected the defaults", h)
+	}
+}
+
+func TestRand(t *testing.T) {
+	r := bytes.NewReader(bytes.Repeat([]byte{7}, saltSize+1))
+	file := encrypt(t, []byte("hello"), WithRand(r))
+	if r.Len() != 1 {
+		t.Errorf("Read %d random bytes, but expected %d", saltSize+1-r.Len(), saltSize)
+	}
+	h, _, err := readHeader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := bytes.Repeat([]byte{7}, saltSize); !bytes.Equal(h.salt, expected) {
+		t.Errorf("Salt was %x, but expected %x", h.salt, expected)
+	}
+
+	var buf bytes.Buffer
+	err = Encrypt(&buf, bytes.NewReader([]byte("hello")), passphrase, fast, WithRand(bytes.NewReader(make([]byte, saltSize-1))))
+	if err != io.ErrUnexpectedEOF || buf.Len() != 0 {
+		t.Errorf("Error was %v after writing %d bytes, but expected %v", err, buf.Len(), io.ErrUnexpectedEOF)
+	}
+}
+
+func TestWrongPassphrase(t *testing.T) {
+	file := encrypt(t, []byte("secret"))
+
+	var buf bytes.Buffer
+	err := Decrypt(&buf, bytes.NewReader(file), []byte("wrong"))
+	if err != ErrPassphrase || !errors.Is(err, siv.ErrAuthentication) {
+		t.Errorf("Error was %v, but expected %v", err, ErrPassphrase)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("Plaintext returned instead of error: %x", buf.Bytes())
+	}
+}
+
+func TestHeaderTamper(t *testing.T) {
+	file := encrypt(t, bytes.Repeat([]byte{'a'}, 100), fixedSalt)
+	headerSize := fixedHeaderSize + saltSize + chunkSizeSize
+
+	for i := 0; i < headerSize; i++ {
+		tampered := append([]byte{}, file...)
+		tampered[i] ^= 0x01
+
+		var buf bytes.Buffer
+		if err := Decrypt(&buf, bytes.NewReader(tampered), passphrase); err == nil {
+			t.Fatalf("Header byte %d: plaintext returned instead of error: %x", i, buf.Bytes())
+		}
+		if buf.Len() != 0 {
+			t.Errorf("Header byte %d: %d bytes written before the error", i, buf.Len())
+		}
+	}
+
+	// The chunk size doesn't affect the key, so only the associated data
+	// catches a change to it.
+	tampered := append([]byte{}, file...)
+	tampered[headerSize-1] = 33
+	if err := Decrypt(&bytes.Buffer{}, bytes.NewReader(tampered), passphrase); err != ErrPassphrase {
+		t.Errorf("Error was %v, but expected %v", err, ErrPassphrase)
+	}
+}
+
+func TestParams(t *testing.T) {
+	for _, opt := range []Option{
+		WithArgon2(0, 64, 1),
+		WithArgon2(MaxTime+1, 64, 1),
+		WithArgon2(1, 7, 1),
+		WithArgon2(1, MaxMemory+1, 1),
+		WithArgon2(1, 64<<10, 0),
+		WithArgon2(1, 64<<10, MaxThreads+1),
+		WithChunkSize(0),
+		WithChunkSize(MaxChunkSize + 1),
+	} {
+		if err := Encrypt(&bytes.Buffer{}, bytes.NewReader(nil), passphrase, opt); !errors.Is(err, ErrParams) {
+			t.Errorf("Error was %v, but expected %v", err, ErrParams)
+		}
+	}
+
+	// Decrypt refuses to derive a key with excessive parameters.
+	h := header{kdf: kdfArgon2id, time: 1, memory: 4 << 20, threads: 1, salt: make([]byte, saltSize), chunkSize: 32}
+	if err := Decrypt(&bytes.Buffer{}, bytes.NewReader(h.marshal()), passphrase); !errors.Is(err, ErrParams) {
+		t.Errorf("Error was %v, but expected %v", err, ErrParams)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	file := encrypt(t, nil)
+
+	for _, b := range [][]byte{
+		nil,
+		file[:3],
+		file[:fixedHeaderSize+1],
+		append([]byte("SIVC"), file[4:]...),
+		append(append([]byte{}, file[:4]...), append([]byte{2}, file[5:]...)...),
+		append(append([]byte{}, file[:5]...), append([]byte{3}, file[6:]...)...),
+	} {
+		if err := Decrypt(&bytes.Buffer{}, bytes.NewReader(b), passphrase); err != ErrFormat {
+			t.Errorf("Error was %v, but expected %v", err, ErrFormat)
+		}
+	}
+}
+
+func TestChunks(t *testing.T) {
+	file := encrypt(t, bytes.Repeat([]byte{'a'}, 100))
+	headerSize := fixedHeaderSize + saltSize + chunkSizeSize
+	chunk := func(i int) []byte {
+		start := headerSize + i*48
+		end := start + 48
+		if end > len(file) {
+			end = len(file)
+		}
+		return file[start:end]
+	}
+
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	tests := []struct {
+		name     string
+		file     []byte
+		expected error
+		written  int
+	}{
+		{"dropped last chunk", file[:headerSize+3*48], ErrTruncated, 96},
+		{"truncated last chunk", file[:len(file)-1], &ChunkError{Index: 3}, 96},
+		{"swapped chunks", join(file[:headerSize], chunk(0), chunk(2), chunk(1), chunk(3)), &ChunkError{Index: 1}, 32},
+		{"extended", append(append([]byte{}, file...), 0), &ChunkError{Index: 3}, 96},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		err := Decrypt(&buf, bytes.NewReader(tt.file), passphrase)
+
+		var ce *ChunkError
+		if expected, ok := tt.expected.(*ChunkError); ok {
+			if !errors.As(err, &ce) || ce.Index != expected.Index || !errors.Is(err, siv.ErrAuthentication) {
+				t.Errorf("%s: error was %v, but expected %v", tt.name, err, expected)
+			}
+		} else if err != tt.expected {
+			t.Errorf("%s: error was %v, but expected %v", tt.name, err, tt.expected)
+		}
+
+		if buf.Len() != tt.written {
+			t.Errorf("%s: %d bytes written, but expected %d", tt.name, buf.Len(), tt.written)
+		}
+	}
+}
+
+func TestKDFVectors(t *testing.T) {
+	salt := make([]byte, saltSize)
+	for i := range salt {
+		salt[i] = byte(i)
+	}
+
+	for _, v := range []struct {
+		name     string
+		h        header
+		expected string
+	}{
+		{
+			"Argon2id",
+			header{kdf: kdfArgon2id, time: 1, memory: 64, threads: 1},
+			"932ddde99ba6edd4bb940d45243e82dd633bb68fab0560c771adca314967abddf95f479fbc45d7fef9ba655275fc64e08530c1e5bcd6be5f3d2f628dec3814af",
+		},
+		{
+			"PBKDF2-HMAC-SHA-256",
+			header{kdf: kdfPBKDF2, iterations: MinPBKDF2SHA256Iterations, hash: pbkdf2SHA256},
+			"ef177144eec9420cbc1093d2a8b344a92bc506d0d4ec9c028dd19f8324d8c1e60dfdc1b0fa4770d8351f72180401ea727d02dfd1d37e753361e8425f304d3293",
+		},
+		{
+			"PBKDF2-HMAC-SHA-512",
+			header{kdf: kdfPBKDF2, iterations: MinPBKDF2SHA512Iterations, hash: pbkdf2SHA512},
+			"b5f3fa7459cc14b9bce1eac5142fe1583cdbe9f02300f080b3446f24b8aee716077de94f05300400380b551809cd9f1b2afbd4a56da7504c446c00db89ecee3e",
+		},
+	} {
+		v.h.salt = salt
+		if actual := hex.EncodeToString(v.h.derive(passphrase)); actual != v.expected {
+			t.Errorf("%s: key was %s, but expected %s", v.name, actual, v.expected)
+		}
+	}
+}
+
+func TestPBKDF2(t *testing.T) {
+	plaintext := []byte("The quick brown fox jumps over the lazy dog, then does it again, and again, and again.")
+	file := encrypt(t, plaintext, cheapPBKDF2, fixedSalt)
+
+	h, _, err := readHeader(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h.kdf != kdfPBKDF2 || h.iterations != MinPBKDF2SHA512Iterations || h.hash != pbkdf2SHA512 {
+		t.Errorf("Header was %+v, but expected PBKDF2-HMAC-SHA-512", h)
+	}
+
+	var buf bytes.Buffer
+	if err := Decrypt(&buf, bytes.NewReader(file), passphrase); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), plaintext) {
+		t.Errorf("Plaintext was %q, but expected %q", buf.Bytes(), plaintext)
+	}
+
+	expected, err := os.ReadFile("testdata/pbkdf2.sivf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(file, expected) {
+		t.Errorf("File was %x, but expected %x", file, expected)
+	}
+
+	// The same passphrase and salt give a different key under each KDF.
+	argon := encrypt(t, plaintext, fixedSalt)
+	headerSize := pbkdf2HeaderSize + saltSize + chunkSizeSize
+	if bytes.Equal(file[headerSize:], argon[fixedHeaderSize+saltSize+chunkSizeSize:]) {
+		t.Error("Argon2id and PBKDF2 produced the same ciphertext")
+	}
+
+	for _, i := range []int{5, 6, 9, 10, 11} {
+		tampered := append([]byte{}, file...)
+		tampered[i] ^= 0x01
+		if err := Decrypt(&buf, bytes.NewReader(tampered), passphrase); err == nil {
+			t.Fatalf("Header byte %d: plaintext returned instead of error", i)
+		}
+	}
+}
+
+func TestPBKDF2Params(t *testing.T) {
+	for _, opt := range []Option{
+		WithPBKDF2(MinPBKDF2SHA256Iterations-1, crypto.SHA256),
+		WithPBKDF2(MinPBKDF2SHA512Iterations-1, crypto.SHA512),
+		WithPBKDF2(MinPBKDF2SHA512Iterations, crypto.SHA256),
+		WithPBKDF2(MaxPBKDF2Iterations+1, crypto.SHA512),
+		WithPBKDF2(MinPBKDF2SHA256Iterations, crypto.SHA1),
+		WithPBKDF2(MinPBKDF2SHA256Iterations, crypto.SHA3_256),
+	} {
+		if err := Encrypt(&bytes.Buffer{}, bytes.NewReader(nil), passphrase, opt); !errors.Is(err, ErrParams) {
+			t.Errorf("Error was %v, but expected %v", err, ErrParams)
+		}
+	}
+
+	// Decrypt refuses to derive a key with parameters out of bounds either
+	// way.
+	for _, h := range []header{
+		{kdf: kdfPBKDF2, iterations: 1, hash: pbkdf2SHA256},
+		{kdf: kdfPBKDF2, iterations: MaxPBKDF2Iterations + 1, hash: pbkdf2SHA512},
+		{kdf: kdfPBKDF2, iterations: MinPBKDF2SHA256Iterations, hash: 3},
+	} {
+		h.salt, h.chunkSize = make([]byte, saltSize), 32
+		if err := Decrypt(&bytes.Buffer{}, bytes.NewReader(h.marshal()), passphrase); !errors.Is(err, ErrParams) {
+			t.Errorf("Error was %v, but expected %v", err, ErrParams)
+		}
+	}
+}
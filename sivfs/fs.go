@@ -0,0 +1,334 @@
+// Package sivfs exposes a tree of encrypted files as an fs.FS which decrypts
+// them transparently, so that encrypted asset bundles can be served with
+// http.FileServer or parsed with html/template.
+//
+// A tree is written with PackFS. Each file is encrypted in the stream package's
+// chunked format with its path bound as associated data, so a file which is
+// renamed, or whose contents are swapped with another file's, fails to
+// decrypt. File names, sizes, modes, and modification times are kept in a
+// sealed index file, IndexName, at the root of the tree, which is the only
+// source of directory listings and file metadata: files in the underlying
+// tree which aren't in the index can't be opened.
+//
+// Files are decrypted lazily, as they are read. As with a stream.Reader, a
+// read error part of the way through a file means the plaintext read so far
+// can't be trusted.
+package sivfs
+
+import (
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	siv "github.com/stripe/siv-go"
+	"github.com/stripe/siv-go/stream"
+)
+
+// IndexName is the name of the sealed index file at the root of a tree.
+const IndexName = ".sivfs-index"
+
+const indexVersion = 1
+
+var (
+	// ErrIndex is returned when the index is missing, fails to authenticate,
+	// or is malformed.
+	ErrIndex = errors.New("sivfs: invalid index")
+
+	// ErrSize is returned when a file's plaintext doesn't match the size
+	// recorded in the index.
+	ErrSize = errors.New("sivfs: file size doesn't match index")
+)
+
+// index is the plaintext of the index file.
+type index struct {
+	Version int          `json:"version"`
+	Files   []indexEntry `json:"files"`
+}
+
+// indexEntry describes a file or directory. The root is named ".".
+type indexEntry struct {
+	Name    string      `json:"name"`
+	Size    int64       `json:"size"`
+	Mode    fs.FileMode `json:"mode"`
+	ModTime time.Time   `json:"mod_time"`
+}
+
+// FS is a file system which decrypts the files of a tree written by PackFS.
+type FS struct {
+	fsys fs.FS
+	aead cipher.AEAD
+
+	once     sync.Once
+	err      error
+	entries  map[string]*indexEntry
+	children map[string][]*indexEntry
+}
+
+// NewFS returns a file system which decrypts the tree in underlying, written
+// by PackFS with aead. The index is read and authenticated on first use; if
+// that fails, every method returns an error wrapping ErrIndex.
+func NewFS(underlying fs.FS, aead cipher.AEAD) fs.FS {
+	return &FS{fsys: underlying, aead: aead}
+}
+
+func indexAEAD(aead cipher.AEAD) cipher.AEAD {
+	return siv.NewContext(aead, []byte("sivfs index"))
+}
+
+func fileAEAD(aead cipher.AEAD, name string) cipher.AEAD {
+	return siv.NewContext(aead, []byte("sivfs file"), []byte(name))
+}
+
+func (f *FS) load() error {
+	f.once.Do(func() {
+		f.err = f.readIndex()
+	})
+	return f.err
+}
+
+func (f *FS) readIndex() error {
+	sealed, err := fs.ReadFile(f.fsys, IndexName)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrIndex, err)
+	}
+
+	b, err := indexAEAD(f.aead).Open(nil, nil, sealed, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrIndex, err)
+	}
+
+	var idx index
+	if err := json.Unmarshal(b, &idx); err != nil || idx.Version != indexVersion {
+		return ErrIndex
+	}
+
+	f.entries = make(map[string]*indexEntry, len(idx.Files))
+	f.children = make(map[string][]*indexEntry)
+	for i := range idx.Files {
+		e := &idx.Files[i]
+		if !fs.ValidPath(e.Name) || f.entries[e.Name] != nil {
+			return ErrIndex
+		}
+		f.entries[e.Name] = e
+		if e.Name != "." {
+			dir := path.Dir(e.Name)
+			f.children[dir] = append(f.children[dir], e)
+		}
+	}
+
+	if root := f.entries["."]; root == nil || !root.Mode.IsDir() {
+		return ErrIndex
+	}
+	for dir, children := range f.children {
+		if parent := f.entries[dir]; parent == nil || !parent.Mode.IsDir() {
+			return ErrIndex
+		}
+		sort.Slice(children, func(i, j int) bool { return children[i].Name < children[j].Name })
+	}
+	return nil
+}
+
+func (f *FS) lookup(op, name string) (*indexEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if err := f.load(); err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+
+	e := f.entries[name]
+	if e == nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return e, nil
+}
+
+// Open opens the named file or directory. Files are decrypted as they are
+// read.
+func (f *FS) Open(name string) (fs.File, error) {
+	e, err := f.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if e.Mode.IsDir() {
+		return &dir{fileInfo: fileInfo{e}, entries: f.children[name]}, nil
+	}
+
+	under, err := f.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return &file{fileInfo: fileInfo{e}, fsys: f, f: under}, nil
+}
+
+// Stat returns the index's metadata for the named file, without opening it.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	e, err := f.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return fileInfo{e}, nil
+}
+
+// ReadDir lists the named directory from the index, sorted by name.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	e, err := f.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !e.Mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+	return dirEntries(f.children[name]), nil
+}
+
+func dirEntries(entries []*indexEntry) []fs.DirEntry {
+	list := make([]fs.DirEntry, len(entries))
+	for i, e := range entries {
+		list[i] = fs.FileInfoToDirEntry(fileInfo{e})
+	}
+	return list
+}
+
+// fileInfo implements fs.FileInfo for an index entry.
+type fileInfo struct {
+	e *indexEntry
+}
+
+func (fi fileInfo) Name() string       { return path.Base(fi.e.Name) }
+func (fi fileInfo) Size() int64        { return fi.e.Size }
+func (fi fileInfo) Mode() fs.FileMode  { return fi.e.Mode }
+func (fi fileInfo) ModTime() time.Time { return fi.e.ModTime }
+func (fi fileInfo) IsDir() bool        { return fi.e.Mode.IsDir() }
+func (fi fileInfo) Sys() any           { return nil }
+
+// Stat returns the file's metadata from the index.
+func (fi fileInfo) Stat() (fs.FileInfo, error) {
+	return fi, nil
+}
+
+// dir is an open directory.
+type dir struct {
+	fileInfo
+	entries []*indexEntry
+	offset  int
+}
+
+func (d *dir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.e.Name, Err: errors.New("is a directory")}
+}
+
+func (d *dir) Close() error {
+	return nil
+}
+
+func (d *dir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(rest) {
+		rest = rest[:n]
+	}
+	d.offset += len(rest)
+	return dirEntries(rest), nil
+}
+
+// file is an open file. It implements io.Seeker, as http.FileServer requires,
+// by decrypting from the start of the file again to seek backwards.
+type file struct {
+	fileInfo
+	fsys *FS
+	f    fs.File
+
+	r    *stream.Reader // nil until the first read, or after seeking back
+	rpos int64          // offset of r
+	pos  int64          // offset of the next read
+}
+
+func (f *file) Read(p []byte) (int, error) {
+	if f.pos != f.rpos {
+		if f.pos >= f.e.Size {
+			return 0, io.EOF
+		}
+		if err := f.seekReader(); err != nil {
+			return 0, err
+		}
+	}
+
+	if f.r == nil {
+		r, err := stream.NewReader(f.f, fileAEAD(f.fsys.aead, f.e.Name))
+		if err != nil {
+			return 0, err
+		}
+		f.r = r
+	}
+
+	n, err := f.r.Read(p)
+	f.rpos += int64(n)
+	f.pos = f.rpos
+	if f.rpos > f.e.Size || (err == io.EOF && f.rpos != f.e.Size) {
+		return 0, ErrSize
+	}
+	return n, err
+}
+
+// seekReader moves the reader to f.pos, restarting it if f.pos is behind it.
+func (f *file) seekReader() error {
+	if f.pos < f.rpos {
+		s, ok := f.f.(io.Seeker)
+		if !ok {
+			if err := f.f.Close(); err != nil {
+				return err
+			}
+			under, err := f.fsys.fsys.Open(f.e.Name)
+			if err != nil {
+				return err
+			}
+			f.f = under
+		} else if _, err := s.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		f.r, f.rpos = nil, 0
+	}
+
+	target := f.pos
+	f.pos = f.rpos
+	_, err := io.CopyN(io.Discard, readerFunc(f.Read), target-f.rpos)
+	return err
+}
+
+type readerFunc func([]byte) (int, error)
+
+func (r readerFunc) Read(p []byte) (int, error) {
+	return r(p)
+}
+
+func (f *file) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += f.e.Size
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.e.Name, Err: fs.ErrInvalid}
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.e.Name, Err: fs.ErrInvalid}
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+func (f *file) Close() error {
+	return f.f.Close()
+}
@@ -0,0 +1,239 @@
+package sivfs
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	siv "github.com/stripe/siv-go"
+	"github.com/stripe/siv-go/stream"
+)
+
+var modTime = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func testAEAD(t *testing.T, b byte) cipher.AEAD {
+	aead, err := siv.New(bytes.Repeat([]byte{b}, 32), aes.NewCipher, siv.AllowEqualHalves())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func source() fstest.MapFS {
+	return fstest.MapFS{
+		"index.html":      {Data: []byte("<h1>Hello</h1>"), Mode: 0o644, ModTime: modTime},
+		"a/b.txt":         {Data: []byte("the b file"), Mode: 0o600, ModTime: modTime},
+		"a/c.txt":         {Data: []byte("the c file"), Mode: 0o644, ModTime: modTime},
+		"a/empty.txt":     {Mode: 0o644, ModTime: modTime},
+		"big/large.bin":   {Data: bytes.Repeat([]byte("0123456789"), 20000), Mode: 0o644, ModTime: modTime},
+		"a/nested/d.json": {Data: []byte(`{"d":true}`), Mode: 0o644, ModTime: modTime},
+	}
+}
+
+func pack(t *testing.T, aead cipher.AEAD) string {
+	dir := t.TempDir()
+	if err := PackFS(dir, source(), aead); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestFS(t *testing.T) {
+	aead := testAEAD(t, 1)
+	dir := pack(t, aead)
+	fsys := NewFS(os.DirFS(dir), aead)
+
+	for name, f := range source() {
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(b, f.Data) {
+			t.Errorf("%s was %q, but expected %q", name, b, f.Data)
+		}
+
+		info, err := fs.Stat(fsys, name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Size() != int64(len(f.Data)) || info.Mode() != f.Mode || !info.ModTime().Equal(modTime) {
+			t.Errorf("%s had size %d, mode %v, and time %v", name, info.Size(), info.Mode(), info.ModTime())
+		}
+
+		// The ciphertext doesn't contain the plaintext.
+		raw, _ := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+		if len(f.Data) > 0 && bytes.Contains(raw, f.Data) {
+			t.Errorf("%s is stored in the clear", name)
+		}
+	}
+
+	if err := fstest.TestFS(fsys, "index.html", "a/b.txt", "a/empty.txt", "a/nested/d.json", "big/large.bin"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The index isn't part of the tree.
+	if _, err := fsys.Open(IndexName); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Error was %v, but expected %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestFSSeek(t *testing.T) {
+	aead := testAEAD(t, 1)
+	fsys := NewFS(os.DirFS(pack(t, aead)), aead)
+	expected := source()["big/large.bin"].Data
+
+	f, err := fsys.Open("big/large.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	s := f.(io.ReadSeeker)
+
+	for _, offset := range []int64{150000, 10, 0, 199990, 100000, 65536, 65535} {
+		if _, err := s.Seek(offset, io.SeekStart); err != nil {
+			t.Fatal(err)
+		}
+		b := make([]byte, 10)
+		if _, err := io.ReadFull(s, b); err != nil {
+			t.Fatalf("%d: %v", offset, err)
+		}
+		if !bytes.Equal(b, expected[offset:offset+10]) {
+			t.Errorf("%d: read %q, but expected %q", offset, b, expected[offset:offset+10])
+		}
+	}
+
+	if n, err := s.Seek(0, io.SeekEnd); err != nil || n != int64(len(expected)) {
+		t.Errorf("Seek returned %d, %v, but expected %d", n, err, len(expected))
+	}
+	if n, err := s.Read(make([]byte, 1)); n != 0 || err != io.EOF {
+		t.Errorf("Read at end returned %d, %v, but expected io.EOF", n, err)
+	}
+}
+
+func TestFSHTTP(t *testing.T) {
+	aead := testAEAD(t, 1)
+	dir := pack(t, aead)
+	srv := httptest.NewServer(http.FileServer(http.FS(NewFS(os.DirFS(dir), aead))))
+	defer srv.Close()
+
+	get := func(path string, header http.Header) (*http.Response, []byte, error) {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, err := io.ReadAll(resp.Body)
+		return resp, body, err
+	}
+
+	resp, body, err := get("/a/b.txt", nil)
+	if err != nil || resp.StatusCode != http.StatusOK || string(body) != "the b file" {
+		t.Errorf("Response was %d %q, %v", resp.StatusCode, body, err)
+	}
+
+	resp, body, err = get("/big/large.bin", http.Header{"Range": {"bytes=100000-100009"}})
+	if err != nil || resp.StatusCode != http.StatusPartialContent || string(body) != "0123456789" {
+		t.Errorf("Response was %d %q, %v", resp.StatusCode, body, err)
+	}
+
+	resp, body, err = get("/", nil)
+	if err != nil || resp.StatusCode != http.StatusOK || string(body) != "<h1>Hello</h1>" {
+		t.Errorf("Response was %d %q, %v", resp.StatusCode, body, err)
+	}
+
+	resp, body, err = get("/a/", nil)
+	if err != nil || !bytes.Contains(body, []byte(`<a href="nested/">nested/</a>`)) {
+		t.Errorf("Response was %d %q, %v", resp.StatusCode, body, err)
+	}
+
+	// A ciphertext renamed over another file isn't served.
+	if err := os.Rename(filepath.Join(dir, "a", "c.txt"), filepath.Join(dir, "a", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+	if resp, body, err := get("/a/b.txt", nil); err == nil && bytes.Contains(body, []byte("file")) {
+		t.Fatalf("Plaintext returned instead of error: %d %q", resp.StatusCode, body)
+	}
+}
+
+func TestFSRenamed(t *testing.T) {
+	aead := testAEAD(t, 1)
+	dir := pack(t, aead)
+	fsys := NewFS(os.DirFS(dir), aead)
+
+	if err := os.Rename(filepath.Join(dir, "a", "c.txt"), filepath.Join(dir, "a", "b.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := fs.ReadFile(fsys, "a/b.txt")
+	var ce *stream.ChunkError
+	if !errors.As(err, &ce) || !errors.Is(err, siv.ErrAuthentication) {
+		t.Fatalf("Plaintext returned instead of error: %q", b)
+	}
+
+	if _, err := fs.ReadFile(fsys, "a/c.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Error was %v, but expected %v", err, fs.ErrNotExist)
+	}
+}
+
+func TestFSIndex(t *testing.T) {
+	aead := testAEAD(t, 1)
+	dir := pack(t, aead)
+
+	// The wrong key.
+	if _, err := fs.ReadFile(NewFS(os.DirFS(dir), testAEAD(t, 2)), "a/b.txt"); !errors.Is(err, ErrIndex) {
+		t.Errorf("Error was %v, but expected %v", err, ErrIndex)
+	}
+
+	// A tampered index.
+	path := filepath.Join(dir, IndexName)
+	sealed, _ := os.ReadFile(path)
+	sealed[len(sealed)-1] ^= 1
+	_ = os.WriteFile(path, sealed, 0o644)
+	if _, err := fs.ReadDir(NewFS(os.DirFS(dir), aead), "."); !errors.Is(err, ErrIndex) {
+		t.Errorf("Error was %v, but expected %v", err, ErrIndex)
+	}
+
+	// No index.
+	_ = os.Remove(path)
+	if _, err := fs.Stat(NewFS(os.DirFS(dir), aead), "a"); !errors.Is(err, ErrIndex) {
+		t.Errorf("Error was %v, but expected %v", err, ErrIndex)
+	}
+}
+
+func TestFSSize(t *testing.T) {
+	aead := testAEAD(t, 1)
+	dir := pack(t, aead)
+
+	// A file truncated to a validly sealed shorter plaintext still doesn't
+	// match the index.
+	f, _ := os.Create(filepath.Join(dir, "a", "b.txt"))
+	w, _ := stream.NewWriter(f, fileAEAD(aead, "a/b.txt"), stream.DefaultChunkSize)
+	_, _ = w.Write([]byte("the b"))
+	_ = w.Close()
+	_ = f.Close()
+
+	if b, err := fs.ReadFile(NewFS(os.DirFS(dir), aead), "a/b.txt"); err != ErrSize {
+		t.Errorf("Error was %v, but expected %v (plaintext %q)", err, ErrSize, b)
+	}
+}
+
+func TestPackFSReserved(t *testing.T) {
+	src := fstest.MapFS{IndexName: {Data: []byte("x")}}
+	if err := PackFS(t.TempDir(), src, testAEAD(t, 1)); err == nil {
+		t.Error("No error for a file named like the index")
+	}
+}
@@ -0,0 +1,91 @@
+package sivfs
+
+import (
+	"crypto/cipher"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/stripe/siv-go/stream"
+)
+
+// PackFS encrypts the regular files and directories of src with aead into the
+// directory dst, which is created if it doesn't exist, and writes the sealed
+// index. Files keep their paths, so dst mirrors src's layout. Other kinds of
+// file, such as symlinks, are an error, as is a file in src named IndexName.
+// The result is read with NewFS(os.DirFS(dst), aead).
+func PackFS(dst string, src fs.FS, aead cipher.AEAD) error {
+	idx := index{Version: indexVersion}
+
+	err := fs.WalkDir(src, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == IndexName {
+			return fmt.Errorf("sivfs: %s is reserved for the index", IndexName)
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		e := indexEntry{Name: name, Mode: info.Mode(), ModTime: info.ModTime()}
+		switch {
+		case d.IsDir():
+			err = os.MkdirAll(filepath.Join(dst, filepath.FromSlash(name)), 0o755)
+		case info.Mode().IsRegular():
+			e.Size, err = packFile(dst, src, name, aead)
+		default:
+			err = fmt.Errorf("sivfs: %s is not a regular file or directory", name)
+		}
+		if err != nil {
+			return err
+		}
+
+		idx.Files = append(idx.Files, e)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+	sealed := indexAEAD(aead).Seal(nil, nil, b, nil)
+	return os.WriteFile(filepath.Join(dst, IndexName), sealed, 0o644)
+}
+
+// packFile encrypts the named file and returns its plaintext size.
+func packFile(dst string, src fs.FS, name string, aead cipher.AEAD) (int64, error) {
+	in, err := src.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = in.Close() }()
+
+	out, err := os.Create(filepath.Join(dst, filepath.FromSlash(name)))
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = out.Close() }()
+
+	w, err := stream.NewWriter(out, fileAEAD(aead, name), stream.DefaultChunkSize)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := io.Copy(w, in)
+	if err != nil {
+		return 0, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	return n, out.Close()
+}
@@ -0,0 +1,95 @@
+package sivgorm
+
+import (
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+)
+
+// settingSet marks a statement whose SET clause the plugin built.
+const settingSet = "sivgorm:set"
+
+// Plugin is a GORM plugin which seals the values of encrypted columns set by
+// Update and by Updates with a map, which GORM otherwise writes without
+// calling the serializer. A value which can't be sealed, such as a SQL
+// expression, fails the update rather than being written as is. Install it
+// once per database with db.Use(sivgorm.Plugin{}).
+type Plugin struct{}
+
+// Name implements gorm.Plugin.
+func (Plugin) Name() string {
+	return "sivgorm"
+}
+
+// Initialize implements gorm.Plugin, registering the plugin's callbacks.
+func (Plugin) Initialize(db *gorm.DB) error {
+	update := db.Callback().Update()
+	if err := update.Before("gorm:update").Register("sivgorm:seal_columns", sealColumns); err != nil {
+		return err
+	}
+	return update.After("gorm:update").Register("sivgorm:reset_columns", resetColumns)
+}
+
+// sealColumns builds the SET clause of an update from a map, as GORM would,
+// but with the values of encrypted columns sealed.
+func sealColumns(db *gorm.DB) {
+	stmt := db.Statement
+	if db.Error != nil || stmt.Schema == nil {
+		return
+	}
+	if _, ok := stmt.Clauses["SET"]; ok {
+		return
+	}
+
+	dest := reflect.ValueOf(stmt.Dest)
+	for dest.Kind() == reflect.Ptr {
+		dest = dest.Elem()
+	}
+	values, ok := dest.Interface().(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	sealed := false
+	for k := range values {
+		if field := stmt.Schema.LookUpField(k); field != nil {
+			if _, ok := field.Serializer.(*Serializer); ok {
+				sealed = true
+			}
+		}
+	}
+	if !sealed {
+		return
+	}
+
+	set := callbacks.ConvertToAssignments(stmt)
+	for i, a := range set {
+		field := stmt.Schema.LookUpField(a.Column.Name)
+		if field == nil {
+			continue
+		}
+		s, ok := field.Serializer.(*Serializer)
+		if !ok {
+			continue
+		}
+
+		v, err := s.Value(stmt.Context, field, stmt.ReflectValue, a.Value)
+		if err != nil {
+			_ = db.AddError(err)
+			return
+		}
+		set[i].Value = v
+	}
+
+	stmt.AddClause(set)
+	stmt.Settings.Store(settingSet, true)
+}
+
+// resetColumns removes the SET clause built by sealColumns, so that a reused
+// statement builds its own.
+func resetColumns(db *gorm.DB) {
+	if _, ok := db.Statement.Settings.LoadAndDelete(settingSet); ok {
+		delete(db.Statement.Clauses, "SET")
+	}
+}
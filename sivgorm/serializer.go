@@ -0,0 +1,156 @@
+// Package sivgorm provides a GORM serializer which transparently encrypts
+// columns with a deterministic AEAD such as SIV.
+//
+// Once registered, fields tagged `gorm:"serializer:siv"` are sealed when
+// written and opened when read:
+//
+//	type User struct {
+//		ID  uint
+//		SSN string `gorm:"serializer:siv"`
+//	}
+//
+// The serializer binds the table and column names to each value as associated
+// data, so a value copied to another column or table fails to open. As the
+// encryption is deterministic, equality queries on encrypted columns still
+// work, but reveal which rows share a value.
+//
+// Fields may be strings, byte slices, or pointers to either. A nil pointer is
+// stored as NULL, and NULL is read as the zero value.
+//
+// GORM applies serializers to models and struct conditions, as in Create,
+// Save, Updates(User{...}), and Where(&User{...}), but not to columns set with
+// Update("SSN", v) or Updates with a map. Install Plugin on every database
+// using the serializer, so that such updates are sealed too:
+//
+//	db.Use(sivgorm.Plugin{})
+package sivgorm
+
+import (
+	"context"
+	"crypto/cipher"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm/schema"
+
+	siv "github.com/stripe/siv-go"
+)
+
+// Name is the name the serializer is registered under.
+const Name = "siv"
+
+// An Option configures a Serializer.
+type Option func(*Serializer)
+
+// WithoutADBinding seals and opens values without binding their table and
+// column, so that they can be copied between columns, as during a migration.
+// Values sealed with and without binding are not interchangeable.
+func WithoutADBinding() Option {
+	return func(s *Serializer) {
+		s.unbound = true
+	}
+}
+
+// Serializer is a GORM serializer which seals values with an AEAD.
+type Serializer struct {
+	aead    cipher.AEAD
+	unbound bool
+}
+
+// New returns a Serializer which seals values with aead.
+func New(aead cipher.AEAD, opts ...Option) *Serializer {
+	s := &Serializer{aead: aead}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Register registers a Serializer using aead under Name. Like GORM's own
+// registrations, it is global, so it should be called once during
+// initialization.
+func Register(aead cipher.AEAD, opts ...Option) {
+	schema.RegisterSerializer(Name, New(aead, opts...))
+}
+
+// ad returns the associated data for a field: its table and column.
+func (s *Serializer) ad(field *schema.Field) []byte {
+	if s.unbound {
+		return nil
+	}
+	return []byte(field.Schema.Table + "." + field.DBName)
+}
+
+// Value implements schema.SerializerValuerInterface, sealing the field's value.
+func (s *Serializer) Value(ctx context.Context, field *schema.Field, dst reflect.Value, fieldValue interface{}) (interface{}, error) {
+	var plaintext []byte
+	switch v := fieldValue.(type) {
+	case string:
+		plaintext = []byte(v)
+	case []byte:
+		plaintext = v
+	case *string:
+		if v == nil {
+			return nil, nil
+		}
+		plaintext = []byte(*v)
+	case *[]byte:
+		if v == nil {
+			return nil, nil
+		}
+		plaintext = *v
+	case nil:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("sivgorm: unsupported type %T for field %s", fieldValue, field.Name)
+	}
+
+	return siv.SealString(s.aead, plaintext, s.ad(field)), nil
+}
+
+// Scan implements schema.SerializerInterface, opening the column's value.
+func (s *Serializer) Scan(ctx context.Context, field *schema.Field, dst reflect.Value, dbValue interface{}) error {
+	fieldValue := reflect.New(field.FieldType)
+
+	if dbValue != nil {
+		var ciphertext string
+		switch v := dbValue.(type) {
+		case string:
+			ciphertext = v
+		case []byte:
+			ciphertext = string(v)
+		default:
+			return fmt.Errorf("sivgorm: unsupported column value %T for field %s", dbValue, field.Name)
+		}
+
+		plaintext, err := siv.OpenString(s.aead, ciphertext, s.ad(field))
+		if err != nil {
+			return fmt.Errorf("sivgorm: field %s: %w", field.Name, err)
+		}
+
+		if err := set(fieldValue.Elem(), plaintext); err != nil {
+			return fmt.Errorf("sivgorm: field %s: %w", field.Name, err)
+		}
+	}
+
+	field.ReflectValueOf(ctx, dst).Set(fieldValue.Elem())
+	return nil
+}
+
+// set stores plaintext in v, a string, byte slice, or pointer to either.
+func set(v reflect.Value, plaintext []byte) error {
+	if v.Kind() == reflect.Pointer {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(string(plaintext))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8:
+		v.SetBytes(plaintext)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
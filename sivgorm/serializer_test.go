@@ -0,0 +1,237 @@
+package sivgorm
+
+import (
+	"context"
+	"crypto/aes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
+	siv "github.com/stripe/siv-go"
+)
+
+type User struct {
+	ID       uint
+	Name     string
+	SSN      string  `gorm:"serializer:siv"`
+	Key      []byte  `gorm:"serializer:siv"`
+	Nickname *string `gorm:"serializer:siv"`
+}
+
+func init() {
+	aead, err := siv.New([]byte("0123456789abcdef0123456789ABCDEF"), aes.NewCipher)
+	if err != nil {
+		panic(err)
+	}
+	Register(aead)
+}
+
+func openDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Use(Plugin{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&User{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestCreateReadUpdate(t *testing.T) {
+	db := openDB(t)
+	nick := "al"
+
+	u := User{Name: "Alice", SSN: "123-45-6789", Key: []byte{1, 2, 3}, Nickname: &nick}
+	if err := db.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var raw struct {
+		SSN      string
+		Key      string
+		Nickname string
+	}
+	if err := db.Raw("SELECT ssn, key, nickname FROM users WHERE id = ?", u.ID).Scan(&raw).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(raw.SSN, "123") || raw.Nickname == "al" || raw.Key == "" {
+		t.Errorf("Stored values were %+v", raw)
+	}
+
+	var actual User
+	if err := db.First(&actual, u.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if actual.SSN != "123-45-6789" || string(actual.Key) != "\x01\x02\x03" || actual.Nickname == nil || *actual.Nickname != "al" {
+		t.Errorf("User was %+v", actual)
+	}
+
+	if err := db.Model(&actual).Update("SSN", "987-65-4321").Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.First(&actual, u.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if actual.SSN != "987-65-4321" {
+		t.Errorf("SSN was %q, but expected %q", actual.SSN, "987-65-4321")
+	}
+
+	// Deterministic encryption allows equality queries.
+	var found User
+	if err := db.Where(&User{SSN: "987-65-4321"}).First(&found).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if found.ID != u.ID {
+		t.Errorf("Found user %d, but expected %d", found.ID, u.ID)
+	}
+}
+
+func TestUpdateColumns(t *testing.T) {
+	db := openDB(t)
+
+	u := User{Name: "Alice", SSN: "123-45-6789"}
+	if err := db.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, update := range []func() error{
+		func() error { return db.Model(&u).Update("SSN", "987-65-4321").Error },
+		func() error { return db.Model(&u).Update("ssn", "987-65-4321").Error },
+		func() error { return db.Model(&u).UpdateColumn("SSN", "987-65-4321").Error },
+		func() error {
+			return db.Model(&u).Updates(map[string]interface{}{"SSN": "987-65-4321", "Name": "Al"}).Error
+		},
+	} {
+		if err := db.Model(&u).Updates(User{SSN: "123-45-6789"}).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := update(); err != nil {
+			t.Fatal(err)
+		}
+
+		var raw string
+		if err := db.Raw("SELECT ssn FROM users WHERE id = ?", u.ID).Scan(&raw).Error; err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(raw, "987") {
+			t.Errorf("Stored value was %q", raw)
+		}
+
+		var actual User
+		if err := db.First(&actual, u.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		if actual.SSN != "987-65-4321" || u.SSN != "987-65-4321" {
+			t.Errorf("SSN was %q, and %q in the model, but expected %q", actual.SSN, u.SSN, "987-65-4321")
+		}
+	}
+
+	// Other columns are unaffected.
+	var actual User
+	if err := db.First(&actual, u.ID).Error; err != nil || actual.Name != "Al" {
+		t.Errorf("User was %+v, %v", actual, err)
+	}
+
+	// An expression can't be sealed, so fails rather than being stored.
+	if err := db.Model(&u).Update("SSN", gorm.Expr("name")).Error; err == nil {
+		t.Error("Updated an encrypted column to an expression")
+	}
+}
+
+func TestNull(t *testing.T) {
+	db := openDB(t)
+
+	u := User{Name: "Bob"}
+	if err := db.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var n int64
+	if err := db.Raw("SELECT COUNT(*) FROM users WHERE nickname IS NULL AND id = ?", u.ID).Scan(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 1 {
+		t.Error("Nil pointer was not stored as NULL")
+	}
+
+	if err := db.Exec("UPDATE users SET ssn = NULL WHERE id = ?", u.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var actual User
+	if err := db.First(&actual, u.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if actual.Nickname != nil || actual.SSN != "" {
+		t.Errorf("User was %+v", actual)
+	}
+}
+
+func TestCrossColumnMove(t *testing.T) {
+	db := openDB(t)
+	nick := "al"
+
+	u := User{Name: "Alice", SSN: "123-45-6789", Nickname: &nick}
+	if err := db.Create(&u).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Exec("UPDATE users SET nickname = ssn WHERE id = ?", u.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var actual User
+	if err := db.First(&actual, u.ID).Error; !errors.Is(err, siv.ErrAuthentication) {
+		t.Errorf("Error was %v, but expected %v (user %+v)", err, siv.ErrAuthentication, actual)
+	}
+}
+
+func TestWithoutADBinding(t *testing.T) {
+	aead, _ := siv.New([]byte("0123456789abcdef0123456789ABCDEF"), aes.NewCipher)
+	ctx := context.Background()
+
+	sch, err := schema.Parse(&User{}, &sync.Map{}, schema.NamingStrategy{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ssn, nickname := sch.LookUpField("SSN"), sch.LookUpField("Nickname")
+
+	// Without binding, a value can be moved between columns.
+	unbound := New(aead, WithoutADBinding())
+	v, err := unbound.Value(ctx, ssn, reflect.Value{}, "123-45-6789")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var u User
+	if err := unbound.Scan(ctx, nickname, reflect.ValueOf(&u).Elem(), v); err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Nickname == nil || *u.Nickname != "123-45-6789" {
+		t.Errorf("Nickname was %v, but expected %q", u.Nickname, "123-45-6789")
+	}
+
+	// With binding, it can't, and unbound values don't open.
+	bound := New(aead)
+	if err := bound.Scan(ctx, ssn, reflect.ValueOf(&u).Elem(), v); !errors.Is(err, siv.ErrAuthentication) {
+		t.Errorf("Error was %v, but expected %v", err, siv.ErrAuthentication)
+	}
+}
@@ -0,0 +1,149 @@
+// Package sivhpke encrypts data to a recipient's X25519 public key, in the
+// manner of HPKE (RFC 9180) base mode, with AES-SIV-CMAC-512 as the payload
+// AEAD so that no nonces need to be managed.
+//
+// The sender generates an ephemeral X25519 key pair, whose public key is the
+// encapsulated key enc, and derives the payload key from the Diffie-Hellman
+// shared secret dh = X25519(ephemeral private key, recipient public key pkR):
+//
+//	prk = HKDF-Extract(salt = Suite, IKM = dh)
+//	key = HKDF-Expand(prk, info = enc || pkR, L = 64)
+//
+// with HKDF (RFC 5869) over SHA-256. The ciphertext is the SIV encryption of
+// the plaintext under key with the S2V associated data components enc and
+// then, unless it is nil, ad. Binding both public keys into the key and enc
+// into the associated data means a ciphertext is only accepted together with
+// its own encapsulated key, by the intended recipient.
+//
+// Unlike HPKE, each key pair must only be used for this scheme.
+package sivhpke
+
+import (
+	"crypto/ecdh"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	siv "github.com/stripe/siv-go"
+)
+
+// Suite identifies the scheme, and is the HKDF salt.
+const Suite = "siv-go/hpke/x25519-hkdf-sha256-aes-siv-cmac-512"
+
+// EncapsulatedKeySize is the size of an encapsulated key.
+const EncapsulatedKeySize = 32
+
+// ErrEncapsulatedKey is returned by Open for a malformed encapsulated key.
+var ErrEncapsulatedKey = errors.New("sivhpke: invalid encapsulated key")
+
+// An Option configures Seal.
+type Option func(*options)
+
+type options struct {
+	rand io.Reader
+}
+
+// WithRand makes Seal read the ephemeral private key from r rather than
+// crypto/rand, as for entropy from an HSM or for deterministic tests. Seal
+// reads exactly 32 bytes from r, and fails if r returns fewer.
+func WithRand(r io.Reader) Option {
+	return func(o *options) {
+		o.rand = r
+	}
+}
+
+// GenerateKey returns a new X25519 key pair, as raw 32-byte keys. It reads
+// exactly 32 bytes from rand, the private key.
+func GenerateKey(rand io.Reader) (pub, priv []byte, err error) {
+	k, err := generateKey(rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	return k.PublicKey().Bytes(), k.Bytes(), nil
+}
+
+// generateKey reads an X25519 private key from rand. Unlike
+// ecdh.Curve.GenerateKey, which may read an extra byte or ignore rand
+// entirely depending on the Go version, it always reads exactly 32 bytes.
+func generateKey(rand io.Reader) (*ecdh.PrivateKey, error) {
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(rand, b); err != nil {
+		return nil, err
+	}
+	return ecdh.X25519().NewPrivateKey(b)
+}
+
+// Seal encrypts plaintext to the recipient's public key, returning the
+// encapsulated key and the ciphertext, both of which Open needs.
+func Seal(recipientPub, plaintext, ad []byte, opts ...Option) (enc, ciphertext []byte, err error) {
+	o := options{rand: rand.Reader}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	eph, err := generateKey(o.rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	return seal(eph, recipientPub, plaintext, ad)
+}
+
+func seal(eph *ecdh.PrivateKey, recipientPub, plaintext, ad []byte) (enc, ciphertext []byte, err error) {
+	pkR, err := ecdh.X25519().NewPublicKey(recipientPub)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dh, err := eph.ECDH(pkR)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	enc = eph.PublicKey().Bytes()
+	aead := siv.NewAEAD512(deriveKey(dh, enc, recipientPub))
+	return enc, siv.NewContext(aead, enc).Seal(nil, nil, plaintext, ad), nil
+}
+
+// Open decrypts a ciphertext sealed to the public key of recipientPriv.
+func Open(recipientPriv, enc, ciphertext, ad []byte) ([]byte, error) {
+	priv, err := ecdh.X25519().NewPrivateKey(recipientPriv)
+	if err != nil {
+		return nil, err
+	}
+
+	pkE, err := ecdh.X25519().NewPublicKey(enc)
+	if err != nil {
+		return nil, ErrEncapsulatedKey
+	}
+
+	// ECDH fails for low-order points, which give an all-zero secret.
+	dh, err := priv.ECDH(pkE)
+	if err != nil {
+		return nil, ErrEncapsulatedKey
+	}
+
+	aead := siv.NewAEAD512(deriveKey(dh, enc, priv.PublicKey().Bytes()))
+	return siv.NewContext(aead, enc).Open(nil, nil, ciphertext, ad)
+}
+
+// deriveKey runs the key schedule.
+func deriveKey(dh, enc, pkR []byte) siv.Key512 {
+	extract := hmac.New(sha256.New, []byte(Suite))
+	extract.Write(dh)
+	prk := extract.Sum(nil)
+
+	var key siv.Key512
+	var t []byte
+	for i, n := byte(1), 0; n < len(key); i++ {
+		expand := hmac.New(sha256.New, prk)
+		expand.Write(t)
+		expand.Write(enc)
+		expand.Write(pkR)
+		expand.Write([]byte{i})
+		t = expand.Sum(nil)
+		n += copy(key[n:], t)
+	}
+	return key
+}
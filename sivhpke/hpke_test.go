@@ -0,0 +1,196 @@
+package sivhpke
+
+import (
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+	"testing/iotest"
+
+	siv "github.com/stripe/siv-go"
+)
+
+// The vectors were computed independently, with a Python X25519 and HKDF and
+// an OpenSSL-based SIV, using the keys of RFC 7748 section 6.1: Alice's as the
+// ephemeral key and Bob's as the recipient's.
+const (
+	ephPriv      = "77076d0a7318a57d3c16c17251b26645df4c2f87ebc0992ab177fba51db92c2a"
+	recipientPub = "de9edb7d7b7dc1b4d35b61c2ece435373f8343c85b78674dadfc7e146f882b4f"
+	recipientKey = "5dab087e624a8a4b79e17f8b83800ee66f3bb1292618b6fd1c2f8b27ff88e0eb"
+	expectedEnc  = "8520f0098930a754748b7ddcb43ef75a0dbf3a0d26381af4eba4a98eaa9b4e6a"
+)
+
+func decodeHex(t *testing.T, s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestKeySchedule(t *testing.T) {
+	dh := decodeHex(t, "4a5d9d5ba4ce2de1728e3bf480350f25e07e21c947d19e3376f09b3c1e161742")
+	expected := decodeHex(t, "3d115050e9fbac0952adfde2105495167504a8e9bece24b61a875a1d1d2632c634299b2751e9787a3c146b2dde84184cafa7d6919293c6a374c6be8aa0925df0")
+
+	key := deriveKey(dh, decodeHex(t, expectedEnc), decodeHex(t, recipientPub))
+	if !bytes.Equal(key[:], expected) {
+		t.Errorf("Key was %x, but expected %x", key, expected)
+	}
+}
+
+func TestVectors(t *testing.T) {
+	eph, err := ecdh.X25519().NewPrivateKey(decodeHex(t, ephPriv))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []struct {
+		plaintext, ad []byte
+		expected      string
+	}{
+		{[]byte("hello, recipient"), []byte("invoice-42"), "cb6c9cbdd5d4a4850b037803e76f8613cb9ea773e34043866d9d64e84693a692"},
+		{[]byte{}, nil, "9a46601aee9e43b65879951e8f88c5b2"},
+	} {
+		enc, ciphertext, err := seal(eph, decodeHex(t, recipientPub), v.plaintext, v.ad)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if hex.EncodeToString(enc) != expectedEnc {
+			t.Errorf("Encapsulated key was %x, but expected %s", enc, expectedEnc)
+		}
+
+		if hex.EncodeToString(ciphertext) != v.expected {
+			t.Errorf("Ciphertext was %x, but expected %s", ciphertext, v.expected)
+		}
+
+		actual, err := Open(decodeHex(t, recipientKey), enc, ciphertext, v.ad)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(actual, v.plaintext) {
+			t.Errorf("Plaintext was %x, but expected %x", actual, v.plaintext)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	pub, priv, err := GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	enc, ciphertext, err := Seal(pub, []byte("yay"), []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(enc) != EncapsulatedKeySize {
+		t.Errorf("Encapsulated key size was %d, but expected %d", len(enc), EncapsulatedKeySize)
+	}
+
+	actual, err := Open(priv, enc, ciphertext, []byte("ad"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(actual) != "yay" {
+		t.Errorf("Plaintext was %q, but expected %q", actual, "yay")
+	}
+
+	// Each Seal uses a fresh ephemeral key.
+	enc2, ciphertext2, _ := Seal(pub, []byte("yay"), []byte("ad"))
+	if bytes.Equal(enc, enc2) || bytes.Equal(ciphertext, ciphertext2) {
+		t.Error("Repeated Seal was deterministic")
+	}
+}
+
+func TestWrongRecipient(t *testing.T) {
+	pub, _, _ := GenerateKey(rand.Reader)
+	_, other, _ := GenerateKey(rand.Reader)
+
+	enc, ciphertext, _ := Seal(pub, []byte("yay"), nil)
+	if actual, err := Open(other, enc, ciphertext, nil); err != siv.ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v (plaintext %x)", err, siv.ErrAuthentication, actual)
+	}
+}
+
+func TestTampering(t *testing.T) {
+	pub, priv, _ := GenerateKey(rand.Reader)
+	enc, ciphertext, _ := Seal(pub, []byte("yay"), []byte("ad"))
+
+	// Another encapsulated key, valid or not, is rejected.
+	otherEnc, _, _ := Seal(pub, []byte("yay"), []byte("ad"))
+	for i := range enc {
+		tampered := append([]byte{}, enc...)
+		tampered[i] ^= 1
+		if actual, err := Open(priv, tampered, ciphertext, []byte("ad")); err == nil {
+			t.Fatalf("Plaintext returned instead of error: %x", actual)
+		}
+	}
+
+	if actual, err := Open(priv, otherEnc, ciphertext, []byte("ad")); err != siv.ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v (plaintext %x)", err, siv.ErrAuthentication, actual)
+	}
+
+	for _, bad := range [][]byte{nil, enc[:31], make([]byte, 32)} {
+		if _, err := Open(priv, bad, ciphertext, []byte("ad")); err != ErrEncapsulatedKey {
+			t.Errorf("Error for %x was %v, but expected %v", bad, err, ErrEncapsulatedKey)
+		}
+	}
+
+	tampered := append([]byte{}, ciphertext...)
+	tampered[len(tampered)-1] ^= 1
+	if actual, err := Open(priv, enc, tampered, []byte("ad")); err != siv.ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v (plaintext %x)", err, siv.ErrAuthentication, actual)
+	}
+
+	if actual, err := Open(priv, enc, ciphertext, []byte("da")); err != siv.ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v (plaintext %x)", err, siv.ErrAuthentication, actual)
+	}
+}
+
+func TestRand(t *testing.T) {
+	// The RFC 7748 keys, read as the random bytes, give the vectors.
+	r := bytes.NewReader(append(decodeHex(t, ephPriv), "more"...))
+	enc, ciphertext, err := Seal(decodeHex(t, recipientPub), []byte("hello, recipient"), []byte("invoice-42"), WithRand(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 4 {
+		t.Errorf("Read %d random bytes, but expected 32", 36-r.Len())
+	}
+	if hex.EncodeToString(enc) != expectedEnc {
+		t.Errorf("Encapsulated key was %x, but expected %s", enc, expectedEnc)
+	}
+	if expected := "cb6c9cbdd5d4a4850b037803e76f8613cb9ea773e34043866d9d64e84693a692"; hex.EncodeToString(ciphertext) != expected {
+		t.Errorf("Ciphertext was %x, but expected %s", ciphertext, expected)
+	}
+
+	r = bytes.NewReader(append(decodeHex(t, recipientKey), "more"...))
+	pub, priv, err := GenerateKey(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Len() != 4 {
+		t.Errorf("Read %d random bytes, but expected 32", 36-r.Len())
+	}
+	if hex.EncodeToString(pub) != recipientPub || hex.EncodeToString(priv) != recipientKey {
+		t.Errorf("Key pair was %x, %x, but expected %s, %s", pub, priv, recipientPub, recipientKey)
+	}
+
+	if _, _, err := Seal(pub, nil, nil, WithRand(bytes.NewReader(make([]byte, 31)))); err != io.ErrUnexpectedEOF {
+		t.Errorf("Error was %v, but expected %v", err, io.ErrUnexpectedEOF)
+	}
+	errHSM := errors.New("HSM unavailable")
+	if _, _, err := Seal(pub, nil, nil, WithRand(iotest.ErrReader(errHSM))); err != errHSM {
+		t.Errorf("Error was %v, but expected %v", err, errHSM)
+	}
+	if _, _, err := GenerateKey(iotest.ErrReader(errHSM)); err != errHSM {
+		t.Errorf("Error was %v, but expected %v", err, errHSM)
+	}
+}
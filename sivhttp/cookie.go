@@ -0,0 +1,95 @@
+// Package sivhttp provides helpers for protecting HTTP values with a
+// deterministic AEAD such as SIV.
+package sivhttp
+
+import (
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	siv "github.com/stripe/siv-go"
+)
+
+// MaxCookieSize is the largest cookie, measured as the length of its name plus
+// its encoded value, which SealCookie will produce. Browsers are only required
+// to store cookies of up to 4096 bytes.
+const MaxCookieSize = 4096
+
+var (
+	// ErrCookieTooLarge is returned when a sealed cookie would exceed
+	// MaxCookieSize.
+	ErrCookieTooLarge = errors.New("sivhttp: cookie too large")
+
+	// ErrCookieEncoding is returned when a cookie value is not valid
+	// unpadded base64url.
+	ErrCookieEncoding = errors.New("sivhttp: malformed cookie value")
+)
+
+// A CookieOption changes which cookie attributes are bound to its value.
+type CookieOption func(*cookieOptions)
+
+type cookieOptions struct {
+	domain, path bool
+}
+
+// BindDomain binds the cookie's Domain attribute to its value.
+func BindDomain() CookieOption {
+	return func(o *cookieOptions) {
+		o.domain = true
+	}
+}
+
+// BindPath binds the cookie's Path attribute to its value.
+func BindPath() CookieOption {
+	return func(o *cookieOptions) {
+		o.path = true
+	}
+}
+
+// SealCookie replaces the value of c with its sealed, unpadded base64url
+// encoding. The cookie's name, and its domain and path if requested, are bound
+// to the value as associated data, so the value can't be moved to another
+// cookie. The same options must be passed to OpenCookie.
+func SealCookie(aead cipher.AEAD, c *http.Cookie, opts ...CookieOption) error {
+	ciphertext := cookieAEAD(aead, c, opts).Seal(nil, nil, []byte(c.Value), nil)
+
+	n := base64.RawURLEncoding.EncodedLen(len(ciphertext))
+	if len(c.Name)+n > MaxCookieSize {
+		return ErrCookieTooLarge
+	}
+
+	c.Value = base64.RawURLEncoding.EncodeToString(ciphertext)
+	return nil
+}
+
+// OpenCookie returns the plaintext of a cookie value sealed by SealCookie. The
+// cookie is not modified.
+func OpenCookie(aead cipher.AEAD, c *http.Cookie, opts ...CookieOption) ([]byte, error) {
+	if len(c.Name)+len(c.Value) > MaxCookieSize {
+		return nil, ErrCookieTooLarge
+	}
+
+	ciphertext, err := base64.RawURLEncoding.DecodeString(c.Value)
+	if err != nil {
+		return nil, ErrCookieEncoding
+	}
+
+	return cookieAEAD(aead, c, opts).Open(nil, nil, ciphertext, nil)
+}
+
+func cookieAEAD(aead cipher.AEAD, c *http.Cookie, opts []CookieOption) cipher.AEAD {
+	var o cookieOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ad := [][]byte{[]byte("sivhttp cookie"), []byte(c.Name)}
+	if o.domain {
+		ad = append(ad, []byte("domain="+c.Domain))
+	}
+	if o.path {
+		ad = append(ad, []byte("path="+c.Path))
+	}
+	return siv.NewContext(aead, ad...)
+}
@@ -0,0 +1,136 @@
+package sivhttp
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func newAEAD(t *testing.T) cipher.AEAD {
+	aead, err := siv.New([]byte("0123456789abcdef0123456789ABCDEF"), aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func TestCookieRoundTrip(t *testing.T) {
+	aead := newAEAD(t)
+
+	w := httptest.NewRecorder()
+	c := &http.Cookie{Name: "session", Value: "user=1234", Path: "/", Domain: "example.com"}
+	if err := SealCookie(aead, c, BindPath()); err != nil {
+		t.Fatal(err)
+	}
+	http.SetCookie(w, c)
+
+	r := httptest.NewRequest("GET", "/", nil)
+	for _, c := range w.Result().Cookies() {
+		r.AddCookie(c)
+	}
+
+	received, err := r.Cookie("session")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only name and value are sent back by clients.
+	received.Path = "/"
+
+	actual, err := OpenCookie(aead, received, BindPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(actual) != "user=1234" {
+		t.Errorf("Value was %q, but expected %q", actual, "user=1234")
+	}
+}
+
+func TestCookieRenamed(t *testing.T) {
+	aead := newAEAD(t)
+
+	c := &http.Cookie{Name: "session", Value: "user=1234"}
+	if err := SealCookie(aead, c); err != nil {
+		t.Fatal(err)
+	}
+
+	c.Name = "admin_session"
+	if actual, err := OpenCookie(aead, c); err == nil {
+		t.Errorf("Value returned instead of error: %q", actual)
+	}
+}
+
+func TestCookieBoundAttributes(t *testing.T) {
+	aead := newAEAD(t)
+
+	c := &http.Cookie{Name: "session", Value: "user=1234", Domain: "a.example.com", Path: "/a"}
+	if err := SealCookie(aead, c, BindDomain(), BindPath()); err != nil {
+		t.Fatal(err)
+	}
+
+	moved := *c
+	moved.Domain = "b.example.com"
+	if actual, err := OpenCookie(aead, &moved, BindDomain(), BindPath()); err == nil {
+		t.Errorf("Value returned instead of error: %q", actual)
+	}
+
+	moved = *c
+	moved.Path = "/b"
+	if actual, err := OpenCookie(aead, &moved, BindDomain(), BindPath()); err == nil {
+		t.Errorf("Value returned instead of error: %q", actual)
+	}
+
+	if actual, err := OpenCookie(aead, c); err == nil {
+		t.Errorf("Value returned instead of error: %q", actual)
+	}
+
+	if _, err := OpenCookie(aead, c, BindDomain(), BindPath()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCookieTampered(t *testing.T) {
+	aead := newAEAD(t)
+
+	c := &http.Cookie{Name: "session", Value: "user=1234"}
+	if err := SealCookie(aead, c); err != nil {
+		t.Fatal(err)
+	}
+
+	b := []byte(c.Value)
+	b[0] ^= 1
+	c.Value = string(b)
+
+	if actual, err := OpenCookie(aead, c); err == nil {
+		t.Errorf("Value returned instead of error: %q", actual)
+	}
+
+	c.Value = "not base64!"
+	if _, err := OpenCookie(aead, c); err != ErrCookieEncoding {
+		t.Errorf("Error was %v, but expected %v", err, ErrCookieEncoding)
+	}
+}
+
+func TestCookieTooLarge(t *testing.T) {
+	aead := newAEAD(t)
+
+	c := &http.Cookie{Name: "session", Value: strings.Repeat("x", 3100)}
+	if err := SealCookie(aead, c); err != ErrCookieTooLarge {
+		t.Errorf("Error was %v, but expected %v", err, ErrCookieTooLarge)
+	}
+
+	if c.Value != strings.Repeat("x", 3100) {
+		t.Error("Cookie was modified on failure")
+	}
+
+	c.Value = strings.Repeat("x", MaxCookieSize)
+	if _, err := OpenCookie(aead, c); err != ErrCookieTooLarge {
+		t.Errorf("Error was %v, but expected %v", err, ErrCookieTooLarge)
+	}
+}
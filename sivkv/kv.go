@@ -0,0 +1,156 @@
+// Package sivkv encrypts the keys and values of a key-value store, such as a
+// cache, with a deterministic AEAD such as SIV.
+//
+// Logical keys are mapped to stored keys deterministically, so lookups still
+// work, and values are sealed with their logical key bound as associated data,
+// so a value copied to another key fails to open.
+package sivkv
+
+import (
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"sync"
+
+	siv "github.com/stripe/siv-go"
+)
+
+// ErrNotFound is returned by Get when a key is not present in a Store.
+var ErrNotFound = errors.New("sivkv: key not found")
+
+// Store is a minimal key-value store.
+type Store interface {
+	// Get returns the value stored under key, or ErrNotFound.
+	Get(key string) ([]byte, error)
+
+	// Set stores value under key.
+	Set(key string, value []byte) error
+
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(key string) error
+}
+
+// An Option configures a wrapped Store.
+type Option func(*store)
+
+// WithNamespace binds ns to every key and value, and prefixes the stored keys
+// with ns and a colon. Stores wrapped with different namespaces can safely
+// share an underlying Store and AEAD.
+func WithNamespace(ns string) Option {
+	return func(s *store) {
+		s.ns = ns
+	}
+}
+
+// WithCiphertextKeys stores values under the full deterministic ciphertext of
+// their logical key rather than under its 16-byte SIV tag. This makes stored
+// keys longer and reveals the length of the logical key, but rules out two
+// logical keys ever sharing a stored key. With tag-derived keys, a collision is
+// expected only after about 2^64 distinct logical keys.
+func WithCiphertextKeys() Option {
+	return func(s *store) {
+		s.fullKeys = true
+	}
+}
+
+// Wrap returns a Store which encrypts keys and values before passing them to
+// the underlying Store.
+func Wrap(underlying Store, aead cipher.AEAD, opts ...Option) Store {
+	s := &store{store: underlying}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	s.keys = siv.NewContext(aead, []byte("sivkv key"), []byte(s.ns))
+	s.values = siv.NewContext(aead, []byte("sivkv value"), []byte(s.ns))
+	return s
+}
+
+type store struct {
+	store        Store
+	keys, values cipher.AEAD
+	ns           string
+	fullKeys     bool
+}
+
+func (s *store) Get(key string) ([]byte, error) {
+	ciphertext, err := s.store.Get(s.storedKey(key))
+	if err != nil {
+		return nil, err
+	}
+	return s.values.Open(nil, nil, ciphertext, []byte(key))
+}
+
+func (s *store) Set(key string, value []byte) error {
+	return s.store.Set(s.storedKey(key), s.values.Seal(nil, nil, value, []byte(key)))
+}
+
+func (s *store) Delete(key string) error {
+	return s.store.Delete(s.storedKey(key))
+}
+
+func (s *store) storedKey(key string) string {
+	v := s.keys.Seal(nil, nil, []byte(key), nil)
+	if !s.fullKeys {
+		v = v[:s.keys.Overhead()]
+	}
+
+	k := base64.RawURLEncoding.EncodeToString(v)
+	if s.ns != "" {
+		k = s.ns + ":" + k
+	}
+	return k
+}
+
+// MemoryStore is an in-memory Store, safe for concurrent use.
+type MemoryStore struct {
+	mu sync.Mutex
+	m  map[string][]byte
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{m: make(map[string][]byte)}
+}
+
+// Get returns a copy of the value stored under key.
+func (m *MemoryStore) Get(key string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	v, ok := m.m[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte{}, v...), nil
+}
+
+// Set stores a copy of value under key.
+func (m *MemoryStore) Set(key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.m[key] = append([]byte{}, value...)
+	return nil
+}
+
+// Delete removes key.
+func (m *MemoryStore) Delete(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.m, key)
+	return nil
+}
+
+// Keys returns the keys currently stored.
+func (m *MemoryStore) Keys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	keys := make([]string, 0, len(m.m))
+	for k := range m.m {
+		keys = append(keys, k)
+	}
+	return keys
+}
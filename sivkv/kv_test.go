@@ -0,0 +1,118 @@
+package sivkv
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"strings"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func newAEAD(t *testing.T) cipher.AEAD {
+	aead, err := siv.New([]byte("0123456789abcdef0123456789ABCDEF"), aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func TestRoundTrip(t *testing.T) {
+	mem := NewMemoryStore()
+	s := Wrap(mem, newAEAD(t))
+
+	if err := s.Set("user:1234:ssn", []byte("123-45-6789")); err != nil {
+		t.Fatal(err)
+	}
+
+	actual, err := s.Get("user:1234:ssn")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(actual) != "123-45-6789" {
+		t.Errorf("Value was %q, but expected %q", actual, "123-45-6789")
+	}
+
+	for _, k := range mem.Keys() {
+		if strings.Contains(k, "1234") {
+			t.Errorf("Stored key %q contains the logical key", k)
+		}
+	}
+
+	if err := s.Delete("user:1234:ssn"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Get("user:1234:ssn"); err != ErrNotFound {
+		t.Errorf("Error was %v, but expected %v", err, ErrNotFound)
+	}
+}
+
+func TestDeterministicKeys(t *testing.T) {
+	mem := NewMemoryStore()
+	aead := newAEAD(t)
+
+	_ = Wrap(mem, aead).Set("a", []byte("1"))
+	_ = Wrap(mem, aead).Set("a", []byte("2"))
+
+	if n := len(mem.Keys()); n != 1 {
+		t.Errorf("Stored %d keys, but expected 1", n)
+	}
+}
+
+func TestSwappedValues(t *testing.T) {
+	mem := NewMemoryStore()
+	s := Wrap(mem, newAEAD(t)).(*store)
+
+	_ = s.Set("alice", []byte("alice's secret"))
+	_ = s.Set("mallory", []byte("mallory's secret"))
+
+	v, _ := mem.Get(s.storedKey("alice"))
+	_ = mem.Set(s.storedKey("mallory"), v)
+
+	if actual, err := s.Get("mallory"); err == nil {
+		t.Errorf("Value returned instead of error: %q", actual)
+	}
+}
+
+func TestNamespaces(t *testing.T) {
+	mem := NewMemoryStore()
+	aead := newAEAD(t)
+	a := Wrap(mem, aead, WithNamespace("a")).(*store)
+	b := Wrap(mem, aead, WithNamespace("b")).(*store)
+
+	_ = a.Set("key", []byte("value"))
+	if _, err := b.Get("key"); err != ErrNotFound {
+		t.Errorf("Error was %v, but expected %v", err, ErrNotFound)
+	}
+
+	if k := a.storedKey("key"); !strings.HasPrefix(k, "a:") {
+		t.Errorf("Stored key was %q, but expected an a: prefix", k)
+	}
+
+	// Copying the value across namespaces, even under the same suffix, fails.
+	v, _ := mem.Get(a.storedKey("key"))
+	_ = mem.Set(b.storedKey("key"), v)
+	if actual, err := b.Get("key"); err == nil {
+		t.Errorf("Value returned instead of error: %q", actual)
+	}
+}
+
+func TestCiphertextKeys(t *testing.T) {
+	aead := newAEAD(t)
+	short := Wrap(NewMemoryStore(), aead).(*store)
+	long := Wrap(NewMemoryStore(), aead, WithCiphertextKeys()).(*store)
+
+	if a, b := short.storedKey("key"), long.storedKey("key"); !strings.HasPrefix(b, a[:len(a)-1]) || len(b) <= len(a) {
+		t.Errorf("Stored keys were %q and %q, but expected the tag to prefix the ciphertext", a, b)
+	}
+
+	if err := long.Set("key", []byte("value")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := long.Get("key"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,241 @@
+// Package sivstruct encrypts and decrypts selected fields of structs in place,
+// so that structs handed over by an ORM can be protected before being saved.
+//
+// Fields tagged `siv:"encrypt"` must be strings or byte slices. Encrypt
+// replaces each with Prefix followed by its ciphertext as unpadded base64url,
+// and Decrypt reverses this. Each field's path, such as "User.Address.Street",
+// is bound to it as associated data, so a value moved to another field fails
+// to decrypt. The path is made of the top-level type's name and the field
+// names, so renaming either makes existing ciphertexts undecryptable.
+//
+// Nested structs, and pointers to them, are walked whether or not they are
+// tagged; nil pointers are skipped. Unexported fields are ignored, unless
+// tagged. A nil byte slice is encrypted like an empty one, so it decrypts as
+// an empty non-nil slice unless SkipZero is used.
+package sivstruct
+
+import (
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	siv "github.com/stripe/siv-go"
+)
+
+// Prefix marks an encrypted field value.
+const Prefix = "siv:"
+
+var (
+	// ErrNotStructPointer is returned when Encrypt or Decrypt is given
+	// anything other than a non-nil pointer to a struct.
+	ErrNotStructPointer = errors.New("sivstruct: not a non-nil pointer to a struct")
+
+	// ErrAlreadyEncrypted is returned by Encrypt for a field whose value
+	// already starts with Prefix, rather than encrypting it twice. No fields
+	// are changed.
+	ErrAlreadyEncrypted = errors.New("sivstruct: field already encrypted")
+
+	// ErrNotEncrypted is returned by Decrypt for a field whose value doesn't
+	// start with Prefix. No fields are changed.
+	ErrNotEncrypted = errors.New("sivstruct: field not encrypted")
+)
+
+// An Option configures Encrypt and Decrypt.
+type Option func(*options)
+
+type options struct {
+	skipZero bool
+}
+
+// SkipZero leaves empty strings and byte slices as they are, rather than
+// encrypting them, so that empty values stay recognisably empty. It must be
+// passed to both Encrypt and Decrypt.
+func SkipZero() Option {
+	return func(o *options) {
+		o.skipZero = true
+	}
+}
+
+// Encrypt encrypts the tagged fields of the struct v points to. If any field
+// can't be encrypted, v is left unchanged.
+func Encrypt(aead cipher.AEAD, v any, opts ...Option) error {
+	return transform(v, opts, func(path string, b []byte) ([]byte, error) {
+		if strings.HasPrefix(string(b), Prefix) {
+			return nil, fmt.Errorf("%w: %s", ErrAlreadyEncrypted, path)
+		}
+		return []byte(Prefix + siv.SealString(aead, b, []byte(path))), nil
+	})
+}
+
+// Decrypt decrypts the tagged fields of the struct v points to. If any field
+// can't be decrypted, v is left unchanged.
+func Decrypt(aead cipher.AEAD, v any, opts ...Option) error {
+	return transform(v, opts, func(path string, b []byte) ([]byte, error) {
+		if !strings.HasPrefix(string(b), Prefix) {
+			return nil, fmt.Errorf("%w: %s", ErrNotEncrypted, path)
+		}
+
+		plaintext, err := siv.OpenString(aead, string(b[len(Prefix):]), []byte(path))
+		if err != nil {
+			return nil, fmt.Errorf("sivstruct: %s: %w", path, err)
+		}
+		return plaintext, nil
+	})
+}
+
+// transform applies f to every tagged field, and sets them all only if it
+// succeeds for each.
+func transform(v any, opts []Option, f func(path string, b []byte) ([]byte, error)) error {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return ErrNotStructPointer
+	}
+	rv = rv.Elem()
+
+	fields, err := tagged(rv.Type())
+	if err != nil {
+		return err
+	}
+
+	type update struct {
+		field reflect.Value
+		value []byte
+	}
+	var updates []update
+
+	var walk func(rv reflect.Value, fields []field, prefix string) error
+	walk = func(rv reflect.Value, fields []field, prefix string) error {
+		for _, fd := range fields {
+			fv := rv.Field(fd.index)
+			path := prefix + "." + fd.name
+
+			if fd.nested != nil {
+				if fv.Kind() == reflect.Pointer {
+					if fv.IsNil() {
+						continue
+					}
+					fv = fv.Elem()
+				}
+
+				nested, err := fd.nested()
+				if err != nil {
+					return err
+				}
+				if err := walk(fv, nested, path); err != nil {
+					return err
+				}
+				continue
+			}
+
+			var b []byte
+			if fv.Kind() == reflect.String {
+				b = []byte(fv.String())
+			} else {
+				b = fv.Bytes()
+			}
+			if o.skipZero && len(b) == 0 {
+				continue
+			}
+
+			value, err := f(path, b)
+			if err != nil {
+				return err
+			}
+			updates = append(updates, update{fv, value})
+		}
+		return nil
+	}
+	if err := walk(rv, fields, rv.Type().Name()); err != nil {
+		return err
+	}
+
+	for _, u := range updates {
+		if u.field.Kind() == reflect.String {
+			u.field.SetString(string(u.value))
+		} else {
+			u.field.SetBytes(u.value)
+		}
+	}
+	return nil
+}
+
+// field is a tagged field, or a struct field which may contain them.
+type field struct {
+	index int
+	name  string
+
+	// nested returns the fields of a nested struct, or is nil for a tagged
+	// field. It is resolved lazily, so that recursive types terminate.
+	nested func() ([]field, error)
+}
+
+type result struct {
+	fields []field
+	err    error
+}
+
+var cache sync.Map // of reflect.Type to result
+
+// tagged returns the fields of t which are tagged or may contain tagged
+// fields, or an error if any tagged field, however deeply nested, has an
+// unsupported kind. Results are cached per type.
+func tagged(t reflect.Type) ([]field, error) {
+	if r, ok := cache.Load(t); ok {
+		return r.(result).fields, r.(result).err
+	}
+
+	fields, err := check(t, map[reflect.Type]bool{})
+	r, _ := cache.LoadOrStore(t, result{fields, err})
+	return r.(result).fields, r.(result).err
+}
+
+// check builds the field list of t, checking nested types it hasn't seen.
+func check(t reflect.Type, seen map[reflect.Type]bool) ([]field, error) {
+	seen[t] = true
+
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+
+		if tag, ok := sf.Tag.Lookup("siv"); ok {
+			if tag != "encrypt" {
+				return nil, fmt.Errorf("sivstruct: %s.%s: unknown tag %q", t.Name(), sf.Name, tag)
+			}
+			if !sf.IsExported() {
+				return nil, fmt.Errorf("sivstruct: %s.%s: unexported field", t.Name(), sf.Name)
+			}
+			if k := sf.Type.Kind(); k != reflect.String && !(k == reflect.Slice && sf.Type.Elem().Kind() == reflect.Uint8) {
+				return nil, fmt.Errorf("sivstruct: %s.%s: unsupported kind %s", t.Name(), sf.Name, sf.Type)
+			}
+			fields = append(fields, field{index: i, name: sf.Name})
+			continue
+		}
+
+		nt := sf.Type
+		if nt.Kind() == reflect.Pointer {
+			nt = nt.Elem()
+		}
+		if !sf.IsExported() || nt.Kind() != reflect.Struct {
+			continue
+		}
+
+		if !seen[nt] {
+			if _, err := check(nt, seen); err != nil {
+				return nil, err
+			}
+		}
+
+		fields = append(fields, field{index: i, name: sf.Name, nested: func() ([]field, error) {
+			return tagged(nt)
+		}})
+	}
+	return fields, nil
+}
@@ -0,0 +1,216 @@
+package sivstruct
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func newAEAD(t *testing.T) cipher.AEAD {
+	aead, err := siv.New([]byte("0123456789abcdef0123456789ABCDEF"), aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+type Address struct {
+	Street string `siv:"encrypt"`
+	City   string
+}
+
+type User struct {
+	Name    string
+	SSN     string `siv:"encrypt"`
+	Key     []byte `siv:"encrypt"`
+	Home    Address
+	Work    *Address
+	Old     *Address
+	Created time.Time
+	Manager *User
+}
+
+func TestRoundTrip(t *testing.T) {
+	aead := newAEAD(t)
+	u := User{
+		Name:    "Alice",
+		SSN:     "123-45-6789",
+		Key:     []byte{1, 2, 3},
+		Home:    Address{Street: "1 Main St", City: "Springfield"},
+		Work:    &Address{Street: "2 Market St", City: "Shelbyville"},
+		Manager: &User{SSN: "987-65-4321"},
+	}
+	expected := u
+	expected.Work = &Address{Street: "2 Market St", City: "Shelbyville"}
+	expected.Manager = &User{SSN: "987-65-4321", Key: []byte{}}
+
+	if err := Encrypt(aead, &u); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, v := range []string{u.SSN, string(u.Key), u.Home.Street, u.Work.Street, u.Manager.SSN} {
+		if !strings.HasPrefix(v, Prefix) {
+			t.Errorf("Field was %q, but expected it to be encrypted", v)
+		}
+	}
+
+	if u.Name != "Alice" || u.Home.City != "Springfield" || u.Old != nil {
+		t.Errorf("Untagged fields changed: %+v", u)
+	}
+
+	if err := Decrypt(aead, &u); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(u, expected) {
+		t.Errorf("Struct was %+v, but expected %+v", u, expected)
+	}
+}
+
+func TestFieldPathBinding(t *testing.T) {
+	aead := newAEAD(t)
+	u := User{SSN: "123-45-6789", Home: Address{Street: "1 Main St"}, Work: &Address{Street: "2 Market St"}, Manager: &User{}}
+	if err := Encrypt(aead, &u); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, swap := range []func(*User){
+		func(u *User) { u.Home.Street, u.Work.Street = u.Work.Street, u.Home.Street },
+		func(u *User) { u.SSN, u.Manager.SSN = u.Manager.SSN, u.SSN },
+		func(u *User) { u.Key = []byte(u.SSN) },
+	} {
+		moved := u
+		w, m := *u.Work, *u.Manager
+		moved.Work, moved.Manager = &w, &m
+		swap(&moved)
+
+		before := moved
+		if err := Decrypt(aead, &moved); !errors.Is(err, siv.ErrAuthentication) {
+			t.Errorf("Error was %v, but expected %v", err, siv.ErrAuthentication)
+		}
+
+		if !reflect.DeepEqual(moved, before) {
+			t.Errorf("Struct changed on failure: %+v", moved)
+		}
+	}
+
+	// The path starts with the type's name.
+	type Other User
+	o := Other(u)
+	if err := Decrypt(aead, &o); !errors.Is(err, siv.ErrAuthentication) {
+		t.Errorf("Error was %v, but expected %v", err, siv.ErrAuthentication)
+	}
+}
+
+func TestSkipZero(t *testing.T) {
+	aead := newAEAD(t)
+
+	u := User{SSN: "123-45-6789"}
+	if err := Encrypt(aead, &u, SkipZero()); err != nil {
+		t.Fatal(err)
+	}
+
+	if u.Key != nil || u.Home.Street != "" || !strings.HasPrefix(u.SSN, Prefix) {
+		t.Errorf("Struct was %+v", u)
+	}
+
+	if err := Decrypt(aead, &u, SkipZero()); err != nil {
+		t.Fatal(err)
+	}
+
+	if u.SSN != "123-45-6789" {
+		t.Errorf("SSN was %q, but expected %q", u.SSN, "123-45-6789")
+	}
+
+	// Without SkipZero, empty values are encrypted too.
+	u = User{}
+	if err := Encrypt(aead, &u); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(u.SSN, Prefix) || !strings.HasPrefix(string(u.Key), Prefix) {
+		t.Errorf("Struct was %+v", u)
+	}
+
+	if err := Decrypt(aead, &u); err != nil {
+		t.Fatal(err)
+	}
+
+	if u.SSN != "" || len(u.Key) != 0 {
+		t.Errorf("Struct was %+v", u)
+	}
+}
+
+func TestDoubleEncryption(t *testing.T) {
+	aead := newAEAD(t)
+
+	u := User{SSN: "123-45-6789"}
+	_ = Encrypt(aead, &u, SkipZero())
+	encrypted := u.SSN
+
+	if err := Encrypt(aead, &u, SkipZero()); !errors.Is(err, ErrAlreadyEncrypted) {
+		t.Errorf("Error was %v, but expected %v", err, ErrAlreadyEncrypted)
+	}
+
+	if u.SSN != encrypted {
+		t.Errorf("SSN was %q, but expected %q", u.SSN, encrypted)
+	}
+
+	// A plaintext which happens to look encrypted is refused too, rather than
+	// being stored in the clear.
+	u = User{SSN: Prefix + "123"}
+	if err := Encrypt(aead, &u, SkipZero()); !errors.Is(err, ErrAlreadyEncrypted) {
+		t.Errorf("Error was %v, but expected %v", err, ErrAlreadyEncrypted)
+	}
+
+	u = User{SSN: "123-45-6789"}
+	if err := Decrypt(aead, &u, SkipZero()); !errors.Is(err, ErrNotEncrypted) {
+		t.Errorf("Error was %v, but expected %v", err, ErrNotEncrypted)
+	}
+}
+
+type badKind struct {
+	Name string
+	Age  int `siv:"encrypt"`
+}
+
+type badNested struct {
+	Inner *struct {
+		Codes []string `siv:"encrypt"`
+	}
+}
+
+type badTag struct {
+	Name string `siv:"encrpyt"`
+}
+
+type unexported struct {
+	name string `siv:"encrypt"`
+}
+
+func TestUnsupportedFields(t *testing.T) {
+	aead := newAEAD(t)
+
+	// Errors are reported even when the field is zero or behind a nil
+	// pointer.
+	for _, v := range []any{&badKind{Name: "x"}, &badNested{}, &badTag{}, &unexported{}} {
+		if err := Encrypt(aead, v); err == nil {
+			t.Errorf("No error for %T", v)
+		}
+		if err := Decrypt(aead, v); err == nil {
+			t.Errorf("No error for %T", v)
+		}
+	}
+
+	for _, v := range []any{nil, User{}, (*User)(nil), new(string)} {
+		if err := Encrypt(aead, v); err != ErrNotStructPointer {
+			t.Errorf("Error for %T was %v, but expected %v", v, err, ErrNotStructPointer)
+		}
+	}
+}
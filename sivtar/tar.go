@@ -0,0 +1,235 @@
+// Package sivtar reads and writes standard tar archives in which the contents
+// of each regular file are individually sealed with a deterministic AEAD such
+// as SIV, so that members can be extracted independently.
+//
+// Each member's contents are sealed with its name and plaintext size bound as
+// associated data, so a member which is renamed, or whose contents are swapped
+// with another member's, fails to authenticate. Headers, including names and
+// modes, are stored in the clear, and members other than regular files are
+// written unchanged, so they must not have contents.
+package sivtar
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	siv "github.com/stripe/siv-go"
+)
+
+// MaxMemberSize is the largest member, in bytes, which can be written or read.
+// Members are sealed and opened whole, so are held in memory.
+const MaxMemberSize = 1 << 30
+
+// ErrMemberSize is returned for members larger than MaxMemberSize, or when
+// the bytes written for a member don't match its header's size.
+var ErrMemberSize = errors.New("sivtar: invalid member size")
+
+// ErrMemberType is returned by WriteHeader for a member which has contents
+// but isn't a regular file, as its contents couldn't be sealed.
+var ErrMemberType = errors.New("sivtar: only regular files can have contents")
+
+// MemberError is returned when a member fails to authenticate.
+type MemberError struct {
+	Name string
+}
+
+func (e *MemberError) Error() string {
+	return fmt.Sprintf("sivtar: member %q failed to authenticate", e.Name)
+}
+
+// Unwrap returns siv.ErrAuthentication.
+func (e *MemberError) Unwrap() error {
+	return siv.ErrAuthentication
+}
+
+// regular reports whether hdr is a regular file, whose contents are sealed.
+// As in archive/tar, the legacy TypeRegA is a regular file unless its name
+// ends in a slash, and contiguous files are regular files.
+func regular(hdr *tar.Header) bool {
+	switch hdr.Typeflag {
+	case tar.TypeReg, tar.TypeCont:
+		return true
+	case tar.TypeRegA:
+		return !strings.HasSuffix(hdr.Name, "/")
+	}
+	return false
+}
+
+// memberData returns the associated data for a member.
+func memberData(name string, size int64) []byte {
+	b := binary.BigEndian.AppendUint64([]byte("sivtar member\x00"), uint64(size))
+	return append(b, name...)
+}
+
+// Writer writes an archive of sealed members.
+type Writer struct {
+	tw   *tar.Writer
+	aead cipher.AEAD
+
+	hdr *tar.Header
+	buf bytes.Buffer
+}
+
+// NewWriter returns a Writer which writes an archive to w, sealing members
+// with aead.
+func NewWriter(w io.Writer, aead cipher.AEAD) *Writer {
+	return &Writer{tw: tar.NewWriter(w), aead: aead}
+}
+
+// WriteHeader finishes the current member, if any, and begins a new one. For
+// regular files, hdr.Size is the size of the plaintext, which must then be
+// written in full. Other members must have a zero hdr.Size.
+func (w *Writer) WriteHeader(hdr *tar.Header) error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+
+	if !regular(hdr) {
+		if hdr.Size != 0 {
+			return ErrMemberType
+		}
+		return w.tw.WriteHeader(hdr)
+	}
+
+	if hdr.Size < 0 || hdr.Size > MaxMemberSize {
+		return ErrMemberSize
+	}
+
+	c := *hdr
+	if c.Typeflag == tar.TypeRegA {
+		c.Typeflag = tar.TypeReg
+	}
+	w.hdr = &c
+	w.buf.Reset()
+	return nil
+}
+
+// Write writes to the contents of the current member.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.hdr == nil {
+		return w.tw.Write(p)
+	}
+
+	if int64(w.buf.Len()+len(p)) > w.hdr.Size {
+		return 0, ErrMemberSize
+	}
+	return w.buf.Write(p)
+}
+
+// flush seals and writes the current member.
+func (w *Writer) flush() error {
+	if w.hdr == nil {
+		return nil
+	}
+
+	hdr := w.hdr
+	w.hdr = nil
+
+	if int64(w.buf.Len()) != hdr.Size {
+		return ErrMemberSize
+	}
+
+	ciphertext := w.aead.Seal(nil, nil, w.buf.Bytes(), memberData(hdr.Name, hdr.Size))
+	hdr.Size = int64(len(ciphertext))
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	_, err := w.tw.Write(ciphertext)
+	return err
+}
+
+// Close finishes the current member and writes the archive's trailer. It does
+// not close the underlying writer.
+func (w *Writer) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	return w.tw.Close()
+}
+
+// Reader reads an archive of sealed members.
+type Reader struct {
+	tr   *tar.Reader
+	aead cipher.AEAD
+
+	// Strict makes a member which fails to authenticate abort the whole
+	// archive: Next returns its MemberError, as does every later call.
+	// Otherwise, Next returns the member's header and only reads of its
+	// contents fail, so the rest of the archive can still be extracted.
+	Strict bool
+
+	contents *bytes.Reader
+	err      error // the current member's error
+	sticky   error
+}
+
+// NewReader returns a Reader which reads an archive from r, opening members
+// with aead.
+func NewReader(r io.Reader, aead cipher.AEAD) *Reader {
+	return &Reader{tr: tar.NewReader(r), aead: aead}
+}
+
+// Next advances to the next member, returning its header. For regular files,
+// the member's contents are read and opened, and hdr.Size is the size of the
+// plaintext.
+func (r *Reader) Next() (*tar.Header, error) {
+	if r.sticky != nil {
+		return nil, r.sticky
+	}
+	r.contents, r.err = nil, nil
+
+	hdr, err := r.tr.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	if !regular(hdr) {
+		return hdr, nil
+	}
+
+	overhead := int64(r.aead.Overhead())
+	if hdr.Size < overhead || hdr.Size-overhead > MaxMemberSize {
+		r.sticky = ErrMemberSize
+		return nil, r.sticky
+	}
+
+	ciphertext := make([]byte, hdr.Size)
+	if _, err := io.ReadFull(r.tr, ciphertext); err != nil {
+		r.sticky = err
+		return nil, err
+	}
+	hdr.Size -= overhead
+
+	plaintext, err := r.aead.Open(nil, nil, ciphertext, memberData(hdr.Name, hdr.Size))
+	if err != nil {
+		r.err = &MemberError{Name: hdr.Name}
+		if r.Strict {
+			r.sticky = r.err
+			return nil, r.err
+		}
+		return hdr, nil
+	}
+
+	r.contents = bytes.NewReader(plaintext)
+	return hdr, nil
+}
+
+// Read reads the contents of the current member. If the member failed to
+// authenticate, it returns a *MemberError and no data.
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+
+	if r.contents == nil {
+		return 0, io.EOF
+	}
+	return r.contents.Read(p)
+}
@@ -0,0 +1,266 @@
+package sivtar
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"io"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func testAEAD(t *testing.T) cipher.AEAD {
+	aead, err := siv.New([]byte("0123456789abcdef0123456789ABCDEF"), aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+type member struct {
+	name     string
+	typeflag byte
+	contents string
+}
+
+var members = []member{
+	{"docs/", tar.TypeDir, ""},
+	{"docs/2025/", tar.TypeDir, ""},
+	{"docs/2025/statement.pdf", tar.TypeReg, "%PDF-1.7 statement"},
+	{"docs/2025/empty.txt", tar.TypeReg, ""},
+	{"docs/id.png", tar.TypeReg, "\x89PNG id"},
+	{"docs/latest", tar.TypeSymlink, ""},
+}
+
+func writeArchive(t *testing.T, aead cipher.AEAD) []byte {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, aead)
+	for _, m := range members {
+		hdr := &tar.Header{Name: m.name, Typeflag: m.typeflag, Mode: 0644, Size: int64(len(m.contents))}
+		if m.typeflag == tar.TypeSymlink {
+			hdr.Linkname = "2025/statement.pdf"
+		}
+
+		if err := w.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := io.WriteString(w, m.contents); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// rewrite copies a plain tar archive, applying f to each header.
+func rewrite(t *testing.T, archive []byte, f func(i int, hdr *tar.Header, contents []byte) []byte) []byte {
+	tr := tar.NewReader(bytes.NewReader(archive))
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i := 0; ; i++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		contents, _ := io.ReadAll(tr)
+		contents = f(i, hdr, contents)
+		hdr.Size = int64(len(contents))
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(contents); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	aead := testAEAD(t)
+	archive := writeArchive(t, aead)
+
+	if bytes.Contains(archive, []byte("%PDF")) {
+		t.Errorf("Archive contains plaintext contents")
+	}
+
+	r := NewReader(bytes.NewReader(archive), aead)
+	for _, m := range members {
+		hdr, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if hdr.Name != m.name || hdr.Typeflag != m.typeflag {
+			t.Errorf("Member was %q (%c), but expected %q (%c)", hdr.Name, hdr.Typeflag, m.name, m.typeflag)
+		}
+
+		contents, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(contents) != m.contents || hdr.Size != int64(len(m.contents)) {
+			t.Errorf("%s: Contents were %q (size %d), but expected %q", m.name, contents, hdr.Size, m.contents)
+		}
+	}
+
+	if _, err := r.Next(); err != io.EOF {
+		t.Errorf("Error was %v, but expected %v", err, io.EOF)
+	}
+}
+
+func TestRenamedMember(t *testing.T) {
+	aead := testAEAD(t)
+	archive := rewrite(t, writeArchive(t, aead), func(i int, hdr *tar.Header, contents []byte) []byte {
+		if hdr.Name == "docs/id.png" {
+			hdr.Name = "docs/avatar.png"
+		}
+		return contents
+	})
+
+	r := NewReader(bytes.NewReader(archive), aead)
+	var failed []string
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := io.ReadAll(r); err != nil {
+			var memberErr *MemberError
+			if !errors.As(err, &memberErr) || memberErr.Name != hdr.Name || !errors.Is(err, siv.ErrAuthentication) {
+				t.Errorf("Error was %v, but expected a MemberError for %q", err, hdr.Name)
+			}
+			failed = append(failed, hdr.Name)
+		}
+	}
+
+	if len(failed) != 1 || failed[0] != "docs/avatar.png" {
+		t.Errorf("Failed members were %q, but expected only the renamed member", failed)
+	}
+}
+
+func TestSwappedContents(t *testing.T) {
+	aead := testAEAD(t)
+
+	var pdf, png []byte
+	archive := writeArchive(t, aead)
+	rewrite(t, archive, func(i int, hdr *tar.Header, contents []byte) []byte {
+		switch hdr.Name {
+		case "docs/2025/statement.pdf":
+			pdf = contents
+		case "docs/id.png":
+			png = contents
+		}
+		return contents
+	})
+
+	swapped := rewrite(t, archive, func(i int, hdr *tar.Header, contents []byte) []byte {
+		switch hdr.Name {
+		case "docs/2025/statement.pdf":
+			return png
+		case "docs/id.png":
+			return pdf
+		}
+		return contents
+	})
+
+	r := NewReader(bytes.NewReader(swapped), aead)
+	r.Strict = true
+
+	for {
+		hdr, err := r.Next()
+		if err != nil {
+			var memberErr *MemberError
+			if !errors.As(err, &memberErr) || memberErr.Name != "docs/2025/statement.pdf" {
+				t.Errorf("Error was %v, but expected a MemberError for the first swapped member", err)
+			}
+			break
+		}
+
+		if hdr.Name == "docs/2025/statement.pdf" {
+			t.Fatalf("Swapped member opened")
+		}
+	}
+
+	// Strict errors are sticky.
+	if _, err := r.Next(); !errors.Is(err, siv.ErrAuthentication) {
+		t.Errorf("Error was %v, but expected %v", err, siv.ErrAuthentication)
+	}
+}
+
+func TestWriterSize(t *testing.T) {
+	w := NewWriter(io.Discard, testAEAD(t))
+
+	if err := w.WriteHeader(&tar.Header{Name: "a", Typeflag: tar.TypeReg, Size: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := w.Write([]byte("abcd")); err != ErrMemberSize {
+		t.Errorf("Error was %v, but expected %v", err, ErrMemberSize)
+	}
+
+	if _, err := w.Write([]byte("ab")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Close(); err != ErrMemberSize {
+		t.Errorf("Error was %v, but expected %v", err, ErrMemberSize)
+	}
+}
+
+func TestDefaultTypeflag(t *testing.T) {
+	// A header without a Typeflag is a regular file, and is sealed.
+	var buf bytes.Buffer
+	w := NewWriter(&buf, testAEAD(t))
+	if err := w.WriteHeader(&tar.Header{Name: "secret.txt", Mode: 0644, Size: 11}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.WriteString(w, "hello world"); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(buf.Bytes(), []byte("hello world")) {
+		t.Error("Archive contains the plaintext")
+	}
+
+	r := NewReader(bytes.NewReader(buf.Bytes()), testAEAD(t))
+	hdr, err := r.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, err := io.ReadAll(r)
+	if err != nil || string(contents) != "hello world" || hdr.Typeflag != tar.TypeReg {
+		t.Errorf("Member was %c with %q, %v", hdr.Typeflag, contents, err)
+	}
+}
+
+func TestUnsealedContents(t *testing.T) {
+	w := NewWriter(io.Discard, testAEAD(t))
+	for _, typeflag := range []byte{tar.TypeSymlink, tar.TypeDir, tar.TypeChar, tar.TypeFifo, tar.TypeGNUSparse, 'Z'} {
+		if err := w.WriteHeader(&tar.Header{Name: "a", Typeflag: typeflag, Size: 3}); err != ErrMemberType {
+			t.Errorf("%c: error was %v, but expected %v", typeflag, err, ErrMemberType)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+//go:build go1.21
+
+// Package sivtls protects TLS session tickets with a siv.Keyring, through the
+// WrapSession and UnwrapSession callbacks of tls.Config, so that ticket keys
+// can be shared between servers and rotated like any other keys.
+//
+// A ticket is the length-prefixed ID of the key it was sealed under, a random
+// value which makes tickets for the same session unlinkable, and the
+// serialized tls.SessionState sealed with the key ID and random value bound
+// as associated data.
+package sivtls
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"errors"
+	"io"
+
+	siv "github.com/stripe/siv-go"
+)
+
+// randomSize is the size of a ticket's random value.
+const randomSize = 16
+
+var (
+	errEmptyKeyring = errors.New("sivtls: keyring is empty")
+	errLongKeyID    = errors.New("sivtls: key ID longer than 255 bytes")
+)
+
+// TicketKeeper seals and opens session tickets with a keyring. New tickets are
+// sealed under the primary key, and tickets sealed under any other key which
+// isn't revoked are still accepted, so a key can be rotated without forcing
+// every client into a full handshake.
+type TicketKeeper struct {
+	keyring *siv.Keyring
+	rand    io.Reader
+}
+
+// An Option configures a TicketKeeper.
+type Option func(*TicketKeeper)
+
+// WithRand makes the TicketKeeper read each ticket's random value from r
+// rather than crypto/rand, as for entropy from an HSM or for deterministic
+// tests. WrapSession reads exactly 16 bytes from r, and fails if r returns
+// fewer.
+func WithRand(r io.Reader) Option {
+	return func(t *TicketKeeper) {
+		t.rand = r
+	}
+}
+
+// NewTicketKeeper returns a TicketKeeper using keyring.
+func NewTicketKeeper(keyring *siv.Keyring, opts ...Option) *TicketKeeper {
+	t := &TicketKeeper{keyring: keyring, rand: rand.Reader}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// Configure sets the WrapSession and UnwrapSession callbacks of c.
+func (t *TicketKeeper) Configure(c *tls.Config) {
+	c.WrapSession = t.WrapSession
+	c.UnwrapSession = t.UnwrapSession
+}
+
+// WrapSession seals ss into a ticket under the primary key. It returns an
+// error if the keyring is empty.
+func (t *TicketKeeper) WrapSession(_ tls.ConnectionState, ss *tls.SessionState) ([]byte, error) {
+	id, aead := t.keyring.Primary()
+	if aead == nil {
+		return nil, errEmptyKeyring
+	}
+	if len(id) > 255 {
+		return nil, errLongKeyID
+	}
+
+	state, err := ss.Bytes()
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 1+len(id)+randomSize)
+	header[0] = byte(len(id))
+	copy(header[1:], id)
+	if _, err := io.ReadFull(t.rand, header[1+len(id):]); err != nil {
+		return nil, err
+	}
+	return aead.Seal(header, nil, state, header), nil
+}
+
+// UnwrapSession opens a ticket sealed by WrapSession. It returns (nil, nil)
+// for a ticket which is malformed, names a key which isn't in the keyring or
+// is revoked, or fails to authenticate, so that the handshake falls back to a
+// full handshake rather than failing.
+func (t *TicketKeeper) UnwrapSession(identity []byte, _ tls.ConnectionState) (*tls.SessionState, error) {
+	if len(identity) < 1 || len(identity) < 1+int(identity[0])+randomSize {
+		return nil, nil
+	}
+	header, ciphertext := identity[:1+int(identity[0])+randomSize], identity[1+int(identity[0])+randomSize:]
+
+	id := string(header[1 : 1+int(header[0])])
+	if state, ok := t.keyring.State(id); !ok || state == siv.KeyRevoked {
+		return nil, nil
+	}
+	aead, _ := t.keyring.Lookup(id)
+
+	state, err := aead.Open(nil, nil, ciphertext, header)
+	if err != nil {
+		return nil, nil
+	}
+
+	ss, err := tls.ParseSessionState(state)
+	if err != nil {
+		return nil, nil
+	}
+	return ss, nil
+}
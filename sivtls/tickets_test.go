@@ -0,0 +1,252 @@
+//go:build go1.21
+
+package sivtls
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func testKeyring(t *testing.T, ids ...string) *siv.Keyring {
+	k := siv.NewKeyring()
+	for _, id := range ids {
+		addKey(t, k, id)
+	}
+	return k
+}
+
+func addKey(t *testing.T, k *siv.Keyring, id string) {
+	aead, err := siv.New(bytes.Repeat([]byte(id[:1]), 32), aes.NewCipher, siv.AllowEqualHalves())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := k.Add(id, aead); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func testCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv}, pool
+}
+
+// handshake connects a client and server over TCP, exchanging a byte so that
+// the client receives any TLS 1.3 session ticket, and returns whether the
+// session was resumed.
+func handshake(t *testing.T, server, client *tls.Config) bool {
+	t.Helper()
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = l.Close() }()
+
+	errs := make(chan error, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			errs <- err
+			return
+		}
+		defer func() { _ = conn.Close() }()
+
+		_, err = conn.Write([]byte{1})
+		errs <- err
+	}()
+
+	conn, err := tls.Dial("tcp", l.Addr().String(), client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err := io.ReadFull(conn, make([]byte, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	return conn.ConnectionState().DidResume
+}
+
+func TestResumption(t *testing.T) {
+	cert, pool := testCertificate(t)
+
+	for _, version := range []uint16{tls.VersionTLS12, tls.VersionTLS13} {
+		k := testKeyring(t, "a")
+		keeper := NewTicketKeeper(k)
+
+		server := &tls.Config{Certificates: []tls.Certificate{cert}, MaxVersion: version}
+		keeper.Configure(server)
+		client := &tls.Config{
+			RootCAs:            pool,
+			ServerName:         "example.com",
+			ClientSessionCache: tls.NewLRUClientSessionCache(1),
+			MaxVersion:         version,
+		}
+
+		if handshake(t, server, client) {
+			t.Fatal("First handshake resumed")
+		}
+		if !handshake(t, server, client) {
+			t.Fatalf("%x: handshake didn't resume", version)
+		}
+
+		// The old key still opens tickets after a rotation.
+		addKey(t, k, "b")
+		if err := k.SetPrimary("b"); err != nil {
+			t.Fatal(err)
+		}
+		if err := k.SetState("a", siv.KeyOpenOnly); err != nil {
+			t.Fatal(err)
+		}
+		if !handshake(t, server, client) {
+			t.Fatalf("%x: handshake didn't resume after rotation", version)
+		}
+
+		// The resumed handshake issued a ticket under b, so revoking a
+		// doesn't affect the client.
+		if err := k.SetState("a", siv.KeyRevoked); err != nil {
+			t.Fatal(err)
+		}
+		if !handshake(t, server, client) {
+			t.Fatalf("%x: handshake didn't resume under the new key", version)
+		}
+
+		// Servers which don't know the key fall back to a full handshake.
+		other := &tls.Config{Certificates: []tls.Certificate{cert}, MaxVersion: version}
+		NewTicketKeeper(testKeyring(t, "c")).Configure(other)
+		if handshake(t, other, client) {
+			t.Fatalf("%x: handshake resumed with an unknown key", version)
+		}
+	}
+}
+
+func TestUnwrapSession(t *testing.T) {
+	k := testKeyring(t, "a", "b")
+	keeper := NewTicketKeeper(k)
+
+	// Capture a real session from a handshake.
+	cert, pool := testCertificate(t)
+	var session *tls.SessionState
+	server := &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.WrapSession = func(cs tls.ConnectionState, ss *tls.SessionState) ([]byte, error) {
+		session = ss
+		return keeper.WrapSession(cs, ss)
+	}
+	handshake(t, server, &tls.Config{RootCAs: pool, ServerName: "example.com", ClientSessionCache: tls.NewLRUClientSessionCache(1)})
+
+	ticket, err := keeper.WrapSession(tls.ConnectionState{}, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ss, err := keeper.UnwrapSession(ticket, tls.ConnectionState{})
+	if ss == nil || err != nil {
+		t.Fatalf("UnwrapSession returned %v, %v", ss, err)
+	}
+	actual, _ := ss.Bytes()
+	if expected, _ := session.Bytes(); !bytes.Equal(actual, expected) {
+		t.Error("UnwrapSession returned a different session")
+	}
+
+	again, _ := keeper.WrapSession(tls.ConnectionState{}, session)
+	if bytes.Equal(again, ticket) {
+		t.Error("Tickets for the same session were identical")
+	}
+
+	tampered := append([]byte{}, ticket...)
+	tampered[len(tampered)-1] ^= 1
+	renamed := append([]byte{}, ticket...)
+	renamed[1] = 'b'
+
+	for _, identity := range [][]byte{nil, {}, {5, 'a'}, ticket[:18], tampered, renamed, []byte("garbage")} {
+		if ss, err := keeper.UnwrapSession(identity, tls.ConnectionState{}); ss != nil || err != nil {
+			t.Errorf("UnwrapSession(%x) returned %v, %v, but expected nil, nil", identity, ss, err)
+		}
+	}
+
+	if err := k.SetPrimary("b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := k.SetState("a", siv.KeyRevoked); err != nil {
+		t.Fatal(err)
+	}
+	if ss, err := keeper.UnwrapSession(ticket, tls.ConnectionState{}); ss != nil || err != nil {
+		t.Errorf("UnwrapSession returned %v, %v for a revoked key", ss, err)
+	}
+
+	if _, err := NewTicketKeeper(siv.NewKeyring()).WrapSession(tls.ConnectionState{}, session); err == nil {
+		t.Error("No error with an empty keyring")
+	}
+}
+
+func TestWrapSessionRand(t *testing.T) {
+	k := testKeyring(t, "a")
+	cr := bytes.NewReader(bytes.Repeat([]byte{7}, 2*randomSize+1))
+	keeper := NewTicketKeeper(k, WithRand(cr))
+
+	cert, pool := testCertificate(t)
+	var session *tls.SessionState
+	server := &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.WrapSession = func(cs tls.ConnectionState, ss *tls.SessionState) ([]byte, error) {
+		session = ss
+		return NewTicketKeeper(k).WrapSession(cs, ss)
+	}
+	handshake(t, server, &tls.Config{RootCAs: pool, ServerName: "example.com", ClientSessionCache: tls.NewLRUClientSessionCache(1)})
+
+	ticket, err := keeper.WrapSession(tls.ConnectionState{}, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := 2*randomSize + 1 - cr.Len(); n != randomSize {
+		t.Errorf("Read %d random bytes, but expected %d", n, randomSize)
+	}
+	if random, expected := ticket[2:2+randomSize], bytes.Repeat([]byte{7}, randomSize); !bytes.Equal(random, expected) {
+		t.Errorf("Random value was %x, but expected %x", random, expected)
+	}
+
+	// The same random value gives the same ticket.
+	again, _ := keeper.WrapSession(tls.ConnectionState{}, session)
+	if !bytes.Equal(again, ticket) {
+		t.Errorf("Ticket was %x, but expected %x", again, ticket)
+	}
+
+	// One byte is left.
+	if ticket, err := keeper.WrapSession(tls.ConnectionState{}, session); err != io.ErrUnexpectedEOF {
+		t.Errorf("Error was %v, but expected %v (ticket %x)", err, io.ErrUnexpectedEOF, ticket)
+	}
+}
@@ -0,0 +1,161 @@
+// Package sivurl encodes URL query parameters as compact, confidential,
+// tamper-proof tokens using a deterministic AEAD such as SIV, for passing
+// state such as return paths or filters through redirect URLs. As encoding is
+// deterministic, the same values always produce the same token, so URLs
+// containing tokens remain cacheable.
+package sivurl
+
+import (
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+)
+
+// DefaultMaxLength is the default limit on the length of a token, chosen to
+// keep URLs well under the limits of common browsers, proxies, and servers.
+const DefaultMaxLength = 1536
+
+// ErrTokenEncoding is returned by DecodeValues for tokens which are not valid
+// unpadded base64url, or whose plaintext is malformed.
+var ErrTokenEncoding = errors.New("sivurl: malformed token")
+
+// TooLargeError is returned when a token is longer than the maximum length.
+type TooLargeError struct {
+	Length, Max int
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("sivurl: token of %d bytes exceeds maximum of %d", e.Length, e.Max)
+}
+
+// An Option configures EncodeValues and DecodeValues.
+type Option func(*options)
+
+type options struct {
+	maxLength int
+}
+
+// WithMaxLength sets the maximum length of a token, in bytes, in place of
+// DefaultMaxLength.
+func WithMaxLength(n int) Option {
+	return func(o *options) {
+		o.maxLength = n
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{maxLength: DefaultMaxLength}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// EncodeValues seals v, with ad as associated data, into an unpadded base64url
+// token. Keys are sorted and each key's values kept in order, so equal values
+// always produce the same token; keys with no values are omitted, as by
+// url.Values.Encode.
+func EncodeValues(aead cipher.AEAD, v url.Values, ad []byte, opts ...Option) (string, error) {
+	o := newOptions(opts)
+
+	ciphertext := aead.Seal(nil, nil, marshal(v), ad)
+	if n := base64.RawURLEncoding.EncodedLen(len(ciphertext)); n > o.maxLength {
+		return "", &TooLargeError{Length: n, Max: o.maxLength}
+	}
+	return base64.RawURLEncoding.EncodeToString(ciphertext), nil
+}
+
+// DecodeValues opens a token produced by EncodeValues with the same associated
+// data. Tokens longer than the maximum length are rejected before decoding.
+func DecodeValues(aead cipher.AEAD, token string, ad []byte, opts ...Option) (url.Values, error) {
+	o := newOptions(opts)
+	if len(token) > o.maxLength {
+		return nil, &TooLargeError{Length: len(token), Max: o.maxLength}
+	}
+
+	ciphertext, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrTokenEncoding
+	}
+
+	plaintext, err := aead.Open(nil, nil, ciphertext, ad)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshal(plaintext)
+}
+
+// marshal encodes v as the number of keys, then for each key in sorted order
+// the key, the number of values, and the values, with every string prefixed by
+// its length and every number encoded as a uvarint.
+func marshal(v url.Values) []byte {
+	keys := make([]string, 0, len(v))
+	for k, vs := range v {
+		if len(vs) > 0 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	b := binary.AppendUvarint(nil, uint64(len(keys)))
+	for _, k := range keys {
+		b = appendString(b, k)
+		b = binary.AppendUvarint(b, uint64(len(v[k])))
+		for _, s := range v[k] {
+			b = appendString(b, s)
+		}
+	}
+	return b
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func unmarshal(b []byte) (url.Values, error) {
+	d := decoder{b: b}
+
+	v := make(url.Values)
+	for n := d.uvarint(); n > 0 && d.err == nil; n-- {
+		k := d.string()
+		for m := d.uvarint(); m > 0 && d.err == nil; m-- {
+			v[k] = append(v[k], d.string())
+		}
+	}
+
+	if d.err != nil || len(d.b) > 0 {
+		return nil, ErrTokenEncoding
+	}
+	return v, nil
+}
+
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) uvarint() uint64 {
+	n, k := binary.Uvarint(d.b)
+	if k <= 0 || n > uint64(len(d.b)) {
+		d.err = ErrTokenEncoding
+		return 0
+	}
+	d.b = d.b[k:]
+	return n
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if d.err != nil || n > uint64(len(d.b)) {
+		d.err = ErrTokenEncoding
+		return ""
+	}
+	s := string(d.b[:n])
+	d.b = d.b[n:]
+	return s
+}
@@ -0,0 +1,139 @@
+package sivurl
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func testAEAD(t *testing.T) cipher.AEAD {
+	aead, err := siv.New([]byte("0123456789abcdef0123456789ABCDEF"), aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func TestRoundTrip(t *testing.T) {
+	aead := testAEAD(t)
+
+	for _, v := range []url.Values{
+		{},
+		{"return": {"/account?tab=billing&x=1"}},
+		{"filter": {"状態", "naïve", "🙂"}, "q": {""}},
+		{"tag": {"b", "a", "b"}, "": {"empty key"}},
+		{"sep": {"a=b&c=d", "%00", "\x00\xff"}},
+	} {
+		token, err := EncodeValues(aead, v, []byte("/redirect"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if url.QueryEscape(token) != token {
+			t.Errorf("Token %q is not URL safe", token)
+		}
+
+		actual, err := DecodeValues(aead, token, []byte("/redirect"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !reflect.DeepEqual(actual, v) {
+			t.Errorf("Values were %v, but expected %v", actual, v)
+		}
+	}
+}
+
+func TestDeterministic(t *testing.T) {
+	aead := testAEAD(t)
+
+	a := url.Values{}
+	a.Add("b", "2")
+	a.Add("a", "1")
+	a.Add("a", "3")
+	a["empty"] = nil
+
+	b := url.Values{"a": {"1", "3"}, "b": {"2"}}
+
+	ta, _ := EncodeValues(aead, a, nil)
+	tb, _ := EncodeValues(aead, b, nil)
+	if ta != tb {
+		t.Errorf("Tokens %q and %q differ", ta, tb)
+	}
+
+	// The order of repeated values is significant.
+	tc, _ := EncodeValues(aead, url.Values{"a": {"3", "1"}, "b": {"2"}}, nil)
+	if tc == ta {
+		t.Errorf("Reordered values produced the same token")
+	}
+}
+
+func TestTooLarge(t *testing.T) {
+	aead := testAEAD(t)
+	v := url.Values{"state": {strings.Repeat("x", 2000)}}
+
+	_, err := EncodeValues(aead, v, nil)
+	var tooLarge *TooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Max != DefaultMaxLength {
+		t.Fatalf("Error was %v, but expected a TooLargeError", err)
+	}
+
+	token, err := EncodeValues(aead, v, nil, WithMaxLength(4096))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := DecodeValues(aead, token, nil); !errors.As(err, &tooLarge) {
+		t.Errorf("Error was %v, but expected a TooLargeError", err)
+	}
+
+	if _, err := DecodeValues(aead, token, nil, WithMaxLength(4096)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTampered(t *testing.T) {
+	aead := testAEAD(t)
+	token, _ := EncodeValues(aead, url.Values{"return": {"/home"}}, []byte("ad"))
+
+	tampered := []byte(token)
+	tampered[10] = 'A'
+	if token[10] == 'A' {
+		tampered[10] = 'B'
+	}
+
+	for _, v := range []struct {
+		token, ad string
+		expected  error
+	}{
+		{string(tampered), "ad", siv.ErrAuthentication},
+		{token, "other", siv.ErrAuthentication},
+		{token + "=", "ad", ErrTokenEncoding},
+		{"***", "ad", ErrTokenEncoding},
+	} {
+		if values, err := DecodeValues(aead, v.token, []byte(v.ad)); err != v.expected {
+			t.Errorf("Error was %v, but expected %v (values %v)", err, v.expected, values)
+		}
+	}
+}
+
+func TestUnmarshalMalformed(t *testing.T) {
+	for _, b := range [][]byte{
+		{},
+		{1},
+		{1, 5, 'a'},
+		{1, 1, 'a', 2, 0},
+		{0, 0},
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+	} {
+		if v, err := unmarshal(b); err != ErrTokenEncoding {
+			t.Errorf("%x: Error was %v, but expected %v (values %v)", b, err, ErrTokenEncoding, v)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Package sivwebhook seals webhook payloads bound to the endpoint they are
+// destined for, so a payload stored for one endpoint can't be delivered to
+// another.
+package sivwebhook
+
+import (
+	"crypto/cipher"
+	"errors"
+	"net"
+	"net/url"
+	"strings"
+
+	siv "github.com/stripe/siv-go"
+)
+
+// ErrInvalidURL is returned for endpoint URLs which are not absolute http or
+// https URLs.
+var ErrInvalidURL = errors.New("sivwebhook: invalid endpoint URL")
+
+// Seal seals payload with the canonical form of endpointURL and eventID bound
+// as associated data.
+func Seal(aead cipher.AEAD, payload []byte, endpointURL, eventID string) ([]byte, error) {
+	a, err := bind(aead, endpointURL, eventID)
+	if err != nil {
+		return nil, err
+	}
+	return a.Seal(nil, nil, payload, nil), nil
+}
+
+// Open opens a payload sealed by Seal. It fails to authenticate unless
+// endpointURL is equivalent to the one the payload was sealed for and eventID
+// is identical.
+func Open(aead cipher.AEAD, ciphertext []byte, endpointURL, eventID string) ([]byte, error) {
+	a, err := bind(aead, endpointURL, eventID)
+	if err != nil {
+		return nil, err
+	}
+	return a.Open(nil, nil, ciphertext, nil)
+}
+
+func bind(aead cipher.AEAD, endpointURL, eventID string) (cipher.AEAD, error) {
+	u, err := CanonicalURL(endpointURL)
+	if err != nil {
+		return nil, err
+	}
+	return siv.NewContext(aead, []byte("sivwebhook"), []byte(u), []byte(eventID)), nil
+}
+
+// CanonicalURL returns the canonical form of an http or https endpoint URL:
+// the scheme and host are lowercased, default ports (80 for http, 443 for
+// https) are removed, an empty path becomes "/", and any fragment is dropped.
+// The path and query are otherwise kept exactly as given. URLs with user
+// information are rejected.
+func CanonicalURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil || u.Opaque != "" || u.User != nil || u.Host == "" {
+		return "", ErrInvalidURL
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	var defaultPort string
+	switch scheme {
+	case "http":
+		defaultPort = "80"
+	case "https":
+		defaultPort = "443"
+	default:
+		return "", ErrInvalidURL
+	}
+
+	host, port := strings.ToLower(u.Hostname()), u.Port()
+	if host == "" {
+		return "", ErrInvalidURL
+	}
+	if port == defaultPort {
+		port = ""
+	}
+	if strings.Contains(host, ":") || port != "" {
+		if port == "" {
+			host = "[" + host + "]"
+		} else {
+			host = net.JoinHostPort(host, port)
+		}
+	}
+
+	c := url.URL{
+		Scheme:   scheme,
+		Host:     host,
+		Path:     u.Path,
+		RawPath:  u.RawPath,
+		RawQuery: u.RawQuery,
+	}
+	if c.Path == "" {
+		c.Path = "/"
+	}
+	return c.String(), nil
+}
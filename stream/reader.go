@@ -0,0 +1,163 @@
+package stream
+
+import (
+	"crypto/cipher"
+	"io"
+
+	siv "github.com/stripe/siv-go"
+)
+
+// Reader decrypts a stream, returning the plaintext of each chunk once it has
+// been verified. A Reader is not safe for concurrent use.
+type Reader struct {
+	r    io.Reader
+	aead cipher.AEAD
+
+	maxChunk int64
+	maxPlain int64 // zero for no limit
+	total    int64 // plaintext read so far
+	tracer   siv.Tracer
+	end      func(error) // ends the span, or nil
+
+	buf   []byte // ciphertext of the next chunk
+	plain []byte // unread plaintext of the current chunk
+	index uint64
+	err   error // returned once plain is drained
+}
+
+// A ReaderOption configures a Reader.
+type ReaderOption func(*Reader)
+
+// WithMaxChunkSize makes the Reader reject streams whose header declares
+// chunks of more than n bytes with a SizeError, rather than allocating a
+// buffer for them. The default, and the largest limit allowed, is
+// MaxChunkSize.
+func WithMaxChunkSize(n int) ReaderOption {
+	return func(r *Reader) {
+		if n > 0 && n < MaxChunkSize {
+			r.maxChunk = int64(n)
+		}
+	}
+}
+
+// WithMaxPlaintextSize makes the Reader fail with a SizeError, without
+// returning its plaintext, on the first chunk which would take the stream's
+// plaintext past n bytes. By default the plaintext isn't limited.
+func WithMaxPlaintextSize(n int64) ReaderOption {
+	return func(r *Reader) {
+		r.maxPlain = n
+	}
+}
+
+// WithReaderTracer traces the stream with a "stream.Reader" span, from
+// NewReader until Read returns io.EOF (ending the span without an error) or
+// another error. A stream which isn't read to the end leaves its span open.
+func WithReaderTracer(t siv.Tracer) ReaderOption {
+	return func(r *Reader) {
+		r.tracer = t
+	}
+}
+
+// NewReader reads and checks the stream header from r, and returns a Reader
+// for the stream's plaintext.
+func NewReader(r io.Reader, aead cipher.AEAD, opts ...ReaderOption) (*Reader, error) {
+	sr := &Reader{r: r, maxChunk: MaxChunkSize}
+	for _, opt := range opts {
+		opt(sr)
+	}
+	if sr.tracer != nil {
+		sr.end = sr.tracer.StartSpan("stream.Reader")
+	}
+
+	if err := sr.readHeader(aead); err != nil {
+		sr.finish(err)
+		return nil, err
+	}
+	return sr, nil
+}
+
+func (r *Reader) readHeader(aead cipher.AEAD) error {
+	hdr := make([]byte, headerSize)
+	if _, err := io.ReadFull(r.r, hdr); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrFormat
+		}
+		return err
+	}
+
+	chunkSize, err := parseHeader(hdr)
+	if err != nil {
+		return err
+	}
+	if chunkSize > r.maxChunk {
+		return &SizeError{What: "chunk", Size: chunkSize, Limit: r.maxChunk}
+	}
+
+	r.aead = bind(aead, hdr)
+	r.buf = make([]byte, int(chunkSize)+r.aead.Overhead())
+	return nil
+}
+
+// finish ends the span, if it hasn't been ended, with err, or nil for io.EOF.
+func (r *Reader) finish(err error) {
+	if r.end == nil {
+		return
+	}
+	if err == io.EOF {
+		err = nil
+	}
+	r.end(err)
+	r.end = nil
+}
+
+// Read reads plaintext from verified chunks. It returns io.EOF only after the
+// last chunk has been verified; a ChunkError for a chunk which fails to
+// authenticate; or ErrTruncated if the input ends before the last chunk.
+func (r *Reader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.err != nil {
+			r.finish(r.err)
+			return 0, r.err
+		}
+		r.next()
+	}
+
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+// next decrypts the next chunk into r.plain, or sets r.err.
+func (r *Reader) next() {
+	n, err := io.ReadFull(r.r, r.buf)
+	if err == io.EOF {
+		// The last chunk is always shorter than a full one, so the input
+		// can't end after a full chunk.
+		r.err = ErrTruncated
+		return
+	}
+	// Only the last chunk can be short.
+	last := err == io.ErrUnexpectedEOF
+	if err != nil && !last {
+		r.err = err
+		return
+	}
+
+	if size := r.total + int64(n-r.aead.Overhead()); r.maxPlain > 0 && size > r.maxPlain {
+		r.err = &SizeError{What: "plaintext", Size: size, Limit: r.maxPlain}
+		return
+	}
+
+	plain, err := r.aead.Open(r.buf[:0], nil, r.buf[:n], chunkData(r.index, last))
+	if err != nil {
+		r.err = &ChunkError{Index: r.index}
+		return
+	}
+
+	r.index++
+	r.total += int64(len(plain))
+	r.plain = plain
+	if last {
+		r.err = io.EOF
+	}
+}
@@ -0,0 +1,129 @@
+// Package stream implements a chunked encryption format for data of unknown
+// length, such as a pipeline reading standard input, which can be encrypted
+// and decrypted in a single pass with bounded memory.
+//
+// A stream is a header followed by the plaintext split into chunks, each
+// sealed separately. The header holds a magic number, a version, and the
+// chunk size. Every chunk but the last holds exactly chunk size bytes of
+// plaintext; the last holds fewer, and may be empty. Each chunk is sealed with
+// the header, its index, and whether it is the last bound as associated data,
+// so chunks can't be reordered, dropped, or moved between streams, and a
+// stream truncated at a chunk boundary is detected when the input ends
+// without a last chunk.
+//
+// A Reader returns the plaintext of each chunk only once it has been
+// verified, but it can't know that the stream is complete until it reaches
+// the end, so a consumer must treat everything it has read as provisional
+// until Read returns io.EOF. Its memory use is bounded by the chunk size,
+// which it limits to MaxChunkSize, or less with WithMaxChunkSize.
+package stream
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	siv "github.com/stripe/siv-go"
+)
+
+const (
+	// MaxChunkSize is the largest chunk size allowed, in bytes, and the
+	// default limit on the chunk size a Reader accepts.
+	MaxChunkSize = 16 << 20
+
+	// DefaultChunkSize is a reasonable chunk size for most uses, in bytes.
+	DefaultChunkSize = 64 << 10
+
+	version = 1
+
+	headerSize = 4 + 1 + 4
+)
+
+var magic = [4]byte{'S', 'I', 'V', 'S'}
+
+var (
+	// ErrFormat is returned for streams with an invalid or unsupported
+	// header.
+	ErrFormat = errors.New("stream: invalid header")
+
+	// ErrTruncated is returned when a stream ends before its last chunk.
+	ErrTruncated = errors.New("stream: truncated stream")
+
+	// ErrTooLarge is wrapped by SizeError.
+	ErrTooLarge = errors.New("stream: size limit exceeded")
+)
+
+// SizeError is returned by a Reader when a stream's declared chunk size, or
+// its plaintext, exceeds the Reader's limits. A chunk size is rejected when
+// the header is read, before the chunk buffer is allocated.
+type SizeError struct {
+	What  string // "chunk" or "plaintext"
+	Size  int64
+	Limit int64
+}
+
+func (e *SizeError) Error() string {
+	return fmt.Sprintf("stream: %s size %d exceeds limit of %d bytes", e.What, e.Size, e.Limit)
+}
+
+// Unwrap returns ErrTooLarge.
+func (e *SizeError) Unwrap() error {
+	return ErrTooLarge
+}
+
+// ChunkError is returned when a chunk fails to authenticate.
+type ChunkError struct {
+	Index uint64
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("stream: chunk %d failed to authenticate", e.Index)
+}
+
+// Unwrap returns siv.ErrAuthentication.
+func (e *ChunkError) Unwrap() error {
+	return siv.ErrAuthentication
+}
+
+func checkChunkSize(chunkSize int) error {
+	if chunkSize <= 0 || chunkSize > MaxChunkSize {
+		return fmt.Errorf("stream: invalid chunk size %d", chunkSize)
+	}
+	return nil
+}
+
+func marshalHeader(chunkSize int) []byte {
+	b := make([]byte, 0, headerSize)
+	b = append(b, magic[:]...)
+	b = append(b, version)
+	return binary.BigEndian.AppendUint32(b, uint32(chunkSize))
+}
+
+// parseHeader returns the chunk size declared by a header, which the caller
+// must check against its limit before using.
+func parseHeader(b []byte) (int64, error) {
+	if len(b) != headerSize || [4]byte(b[:4]) != magic || b[4] != version {
+		return 0, ErrFormat
+	}
+
+	chunkSize := binary.BigEndian.Uint32(b[5:])
+	if chunkSize == 0 {
+		return 0, ErrFormat
+	}
+	return int64(chunkSize), nil
+}
+
+// bind returns aead with the stream's header bound to it.
+func bind(aead cipher.AEAD, hdr []byte) cipher.AEAD {
+	return siv.NewContext(aead, []byte("siv stream"), hdr)
+}
+
+// chunkData returns the associated data for chunk i.
+func chunkData(i uint64, last bool) []byte {
+	b := binary.BigEndian.AppendUint64(make([]byte, 0, 9), i)
+	if last {
+		return append(b, 1)
+	}
+	return append(b, 0)
+}
@@ -0,0 +1,302 @@
+package stream
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"io"
+	"math/rand"
+	"runtime"
+	"testing"
+
+	siv "github.com/stripe/siv-go"
+)
+
+func testAEAD(t *testing.T) cipher.AEAD {
+	aead, err := siv.New([]byte("0123456789abcdef0123456789ABCDEF"), aes.NewCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return aead
+}
+
+func encrypt(t *testing.T, aead cipher.AEAD, plaintext []byte, chunkSize int) []byte {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, aead, chunkSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Write in uneven pieces to exercise chunk boundaries.
+	for p := plaintext; len(p) > 0; {
+		n := 1 + len(p)%37
+		if n > len(p) {
+			n = len(p)
+		}
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[n:]
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRoundTrip(t *testing.T) {
+	aead := testAEAD(t)
+	rng := rand.New(rand.NewSource(1))
+
+	for _, size := range []int{0, 1, 99, 100, 101, 1000, 4096} {
+		plaintext := make([]byte, size)
+		rng.Read(plaintext)
+
+		s := encrypt(t, aead, plaintext, 100)
+		if expected := headerSize + size + (size/100+1)*aead.Overhead(); len(s) != expected {
+			t.Errorf("%d: Stream was %d bytes, but expected %d", size, len(s), expected)
+		}
+
+		r, err := NewReader(bytes.NewReader(s), aead)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(actual, plaintext) {
+			t.Errorf("%d: Plaintext was %x, but expected %x", size, actual, plaintext)
+		}
+	}
+}
+
+func TestCorruption(t *testing.T) {
+	aead := testAEAD(t)
+	plaintext := bytes.Repeat([]byte{'a'}, 250)
+	s := encrypt(t, aead, plaintext, 100)
+	chunk := func(i int) []byte {
+		start := headerSize + i*116
+		end := start + 116
+		if end > len(s) {
+			end = len(s)
+		}
+		return s[start:end]
+	}
+
+	flipped := append([]byte{}, s...)
+	flipped[headerSize+116+50] ^= 1
+
+	otherAEAD, _ := siv.New(bytes.Repeat([]byte{1}, 32), aes.NewCipher, siv.AllowEqualHalves())
+
+	tests := []struct {
+		name     string
+		stream   []byte
+		aead     cipher.AEAD
+		expected error
+		read     int
+	}{
+		{"corrupted middle chunk", flipped, aead, &ChunkError{Index: 1}, 100},
+		{"swapped chunks", bytes.Join([][]byte{s[:headerSize], chunk(1), chunk(0), chunk(2)}, nil), aead, &ChunkError{Index: 0}, 0},
+		{"dropped last chunk", s[:headerSize+2*116], aead, ErrTruncated, 200},
+		{"truncated last chunk", s[:len(s)-1], aead, &ChunkError{Index: 2}, 200},
+		{"extended", append(append([]byte{}, s...), 0), aead, &ChunkError{Index: 2}, 200},
+		{"changed chunk size", append(append([]byte{}, s[:headerSize-1]...), append([]byte{99}, s[headerSize:]...)...), aead, &ChunkError{Index: 0}, 0},
+		{"wrong key", s, otherAEAD, &ChunkError{Index: 0}, 0},
+	}
+	for _, tt := range tests {
+		r, err := NewReader(bytes.NewReader(tt.stream), tt.aead)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := io.ReadAll(r)
+		var ce *ChunkError
+		if expected, ok := tt.expected.(*ChunkError); ok {
+			if !errors.As(err, &ce) || ce.Index != expected.Index || !errors.Is(err, siv.ErrAuthentication) {
+				t.Errorf("%s: error was %v, but expected %v", tt.name, err, expected)
+			}
+		} else if err != tt.expected {
+			t.Errorf("%s: error was %v, but expected %v", tt.name, err, tt.expected)
+		}
+
+		if !bytes.Equal(actual, plaintext[:tt.read]) {
+			t.Errorf("%s: read %d bytes, but expected %d", tt.name, len(actual), tt.read)
+		}
+	}
+}
+
+func TestHeader(t *testing.T) {
+	aead := testAEAD(t)
+	s := encrypt(t, aead, nil, 100)
+
+	for _, b := range [][]byte{
+		nil,
+		s[:headerSize-1],
+		append([]byte("SIVC"), s[4:]...),
+		append(append([]byte{}, s[:4]...), append([]byte{2}, s[5:]...)...),
+		append(append([]byte{}, s[:5]...), 0, 0, 0, 0),
+	} {
+		if _, err := NewReader(bytes.NewReader(b), aead); err != ErrFormat {
+			t.Errorf("Error was %v, but expected %v", err, ErrFormat)
+		}
+	}
+
+	if _, err := NewWriter(io.Discard, aead, MaxChunkSize+1); err == nil {
+		t.Error("No error for oversized chunks")
+	}
+}
+
+func TestWriterClose(t *testing.T) {
+	var buf bytes.Buffer
+	w, _ := NewWriter(&buf, testAEAD(t), 100)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Errorf("Error was %v, but expected nil", err)
+	}
+	if _, err := w.Write([]byte("late")); err != ErrClosed {
+		t.Errorf("Error was %v, but expected %v", err, ErrClosed)
+	}
+}
+
+func TestReaderLimits(t *testing.T) {
+	aead := testAEAD(t)
+
+	// A header declaring 2 GiB chunks is rejected before anything is
+	// allocated for them.
+	s := append([]byte("SIVS\x01"), 0x80, 0, 0, 0)
+	s = append(s, make([]byte, 100)...)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	_, err := NewReader(bytes.NewReader(s), aead)
+	runtime.ReadMemStats(&after)
+
+	var se *SizeError
+	if !errors.As(err, &se) || !errors.Is(err, ErrTooLarge) || se.What != "chunk" || se.Size != 1<<31 || se.Limit != MaxChunkSize {
+		t.Errorf("Error was %v, but expected a chunk SizeError", err)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<10 {
+		t.Errorf("Allocated %d bytes rejecting a 2 GiB chunk", allocated)
+	}
+
+	plaintext := make([]byte, 1000)
+	s = encrypt(t, aead, plaintext, 100)
+
+	if _, err := NewReader(bytes.NewReader(s), aead, WithMaxChunkSize(99)); !errors.As(err, &se) || se.Limit != 99 {
+		t.Errorf("Error was %v, but expected a chunk SizeError", err)
+	}
+	if _, err := NewReader(bytes.NewReader(s), aead, WithMaxChunkSize(100)); err != nil {
+		t.Errorf("Error was %v, but expected nil", err)
+	}
+
+	r, _ := NewReader(bytes.NewReader(s), aead, WithMaxPlaintextSize(950))
+	actual, err := io.ReadAll(r)
+	if !errors.As(err, &se) || se.What != "plaintext" || se.Size != 1000 || se.Limit != 950 {
+		t.Errorf("Error was %v, but expected a plaintext SizeError", err)
+	}
+	if !bytes.Equal(actual, plaintext[:900]) {
+		t.Errorf("Read %d bytes, but expected %d", len(actual), 900)
+	}
+
+	r, _ = NewReader(bytes.NewReader(s), aead, WithMaxPlaintextSize(1000))
+	if actual, err := io.ReadAll(r); err != nil || !bytes.Equal(actual, plaintext) {
+		t.Errorf("Read %d bytes, %v, but expected %d", len(actual), err, len(plaintext))
+	}
+}
+
+type span struct {
+	name string
+	err  error
+}
+
+type recordingTracer struct {
+	spans []span
+	open  int
+}
+
+func (r *recordingTracer) StartSpan(name string) func(error) {
+	r.open++
+	return func(err error) {
+		r.open--
+		r.spans = append(r.spans, span{name, err})
+	}
+}
+
+func TestTracer(t *testing.T) {
+	aead := testAEAD(t)
+	plaintext := make([]byte, 1000)
+	tracer := &recordingTracer{}
+
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, aead, 100, WithWriterTracer(tracer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write(plaintext)
+	if tracer.open != 1 || len(tracer.spans) != 0 {
+		t.Errorf("%d spans were open and %d ended before Close", tracer.open, len(tracer.spans))
+	}
+	_ = w.Close()
+	_ = w.Close()
+
+	r, err := NewReader(bytes.NewReader(buf.Bytes()), aead, WithReaderTracer(tracer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(r); err != nil {
+		t.Fatal(err)
+	}
+	_, _ = r.Read(make([]byte, 1))
+
+	s := buf.Bytes()
+	s[len(s)-1] ^= 1
+	r, _ = NewReader(bytes.NewReader(s), aead, WithReaderTracer(tracer))
+	_, err = io.ReadAll(r)
+
+	_, _ = NewReader(bytes.NewReader(s[:3]), aead, WithReaderTracer(tracer))
+	_, cerr := NewWriter(io.Discard, aead, 0, WithWriterTracer(tracer))
+
+	w, _ = NewWriter(&failingWriter{n: headerSize + 10}, aead, 100, WithWriterTracer(tracer))
+	_, werr := w.Write(plaintext)
+
+	expected := []span{
+		{"stream.Writer", nil},
+		{"stream.Reader", nil},
+		{"stream.Reader", err},
+		{"stream.Reader", ErrFormat},
+		{"stream.Writer", cerr},
+		{"stream.Writer", werr},
+	}
+	if tracer.open != 0 || len(tracer.spans) != len(expected) {
+		t.Fatalf("Spans were %v with %d open, but expected %v", tracer.spans, tracer.open, expected)
+	}
+	for i, s := range tracer.spans {
+		if s.name != expected[i].name || (s.err == nil) != (expected[i].err == nil) || (s.err != nil && s.err != expected[i].err) {
+			t.Errorf("Span was %v, but expected %v", s, expected[i])
+		}
+	}
+
+	var ce *ChunkError
+	if !errors.As(tracer.spans[2].err, &ce) {
+		t.Errorf("Error was %v, but expected a ChunkError", tracer.spans[2].err)
+	}
+}
+
+type failingWriter struct {
+	n int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		return 0, errors.New("boom")
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
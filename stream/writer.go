@@ -0,0 +1,128 @@
+package stream
+
+import (
+	"crypto/cipher"
+	"errors"
+	"io"
+
+	siv "github.com/stripe/siv-go"
+)
+
+// ErrClosed is returned by Writer.Write after Close.
+var ErrClosed = errors.New("stream: write after close")
+
+// Writer encrypts a plaintext of any length into a stream.
+type Writer struct {
+	w    io.Writer
+	aead cipher.AEAD
+
+	buf    []byte
+	out    []byte
+	index  uint64
+	closed bool
+	err    error
+
+	tracer siv.Tracer
+	end    func(error) // ends the span, or nil
+}
+
+// A WriterOption configures a Writer.
+type WriterOption func(*Writer)
+
+// WithWriterTracer traces the stream with a "stream.Writer" span, from
+// NewWriter until Close or the first error.
+func WithWriterTracer(t siv.Tracer) WriterOption {
+	return func(w *Writer) {
+		w.tracer = t
+	}
+}
+
+// NewWriter writes the header of a stream with chunks of chunkSize bytes, and
+// returns a Writer to which the plaintext must be written. Close must be
+// called to write the last chunk, or the stream will be truncated.
+func NewWriter(w io.Writer, aead cipher.AEAD, chunkSize int, opts ...WriterOption) (*Writer, error) {
+	sw := &Writer{w: w}
+	for _, opt := range opts {
+		opt(sw)
+	}
+	if sw.tracer != nil {
+		sw.end = sw.tracer.StartSpan("stream.Writer")
+	}
+
+	if err := checkChunkSize(chunkSize); err != nil {
+		sw.finish(err)
+		return nil, err
+	}
+
+	hdr := marshalHeader(chunkSize)
+	if _, err := w.Write(hdr); err != nil {
+		sw.finish(err)
+		return nil, err
+	}
+
+	sw.aead = bind(aead, hdr)
+	sw.buf = make([]byte, 0, chunkSize)
+	return sw, nil
+}
+
+// finish ends the span, if it hasn't been ended.
+func (w *Writer) finish(err error) {
+	if w.end != nil {
+		w.end(err)
+		w.end = nil
+	}
+}
+
+// Write encrypts p, writing each chunk as it is filled.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.closed {
+		return 0, ErrClosed
+	}
+
+	n := 0
+	for len(p) > 0 {
+		m := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+m]
+		p = p[m:]
+		n += m
+
+		// A full chunk is never the last, so can be written straight away.
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flush(false); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+func (w *Writer) flush(last bool) error {
+	w.out = w.aead.Seal(w.out[:0], nil, w.buf, chunkData(w.index, last))
+	w.index++
+	w.buf = w.buf[:0]
+
+	if _, err := w.w.Write(w.out); err != nil {
+		w.err = err
+		w.finish(err)
+		return err
+	}
+	return nil
+}
+
+// Close writes the last chunk. It does not close the underlying writer.
+func (w *Writer) Close() error {
+	if w.err != nil {
+		return w.err
+	}
+	if w.closed {
+		return nil
+	}
+
+	w.closed = true
+	err := w.flush(true)
+	w.finish(err)
+	return err
+}
@@ -0,0 +1,46 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"encoding/base64"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ErrEncoding is returned by OpenString when its input is not valid unpadded
+// base64url.
+var ErrEncoding = errors.New("siv: malformed base64url ciphertext")
+
+// SealString seals plaintext and returns the ciphertext as unpadded base64url
+// (RFC 4648 section 5), suitable for JSON, URLs, and headers.
+func SealString(aead cipher.AEAD, plaintext, ad []byte) string {
+	ciphertext := aead.Seal(make([]byte, 0, len(plaintext)+aead.Overhead()), nil, plaintext, ad)
+
+	var b strings.Builder
+	b.Grow(base64.RawURLEncoding.EncodedLen(len(ciphertext)))
+	enc := base64.NewEncoder(base64.RawURLEncoding, &b)
+	_, _ = enc.Write(ciphertext)
+	_ = enc.Close()
+	return b.String()
+}
+
+// OpenString decodes and opens a ciphertext produced by SealString. Malformed
+// input results in ErrEncoding, distinct from the error returned when the
+// ciphertext fails to authenticate.
+func OpenString(aead cipher.AEAD, s string, ad []byte) ([]byte, error) {
+	ciphertext := make([]byte, base64.RawURLEncoding.DecodedLen(len(s)))
+	dec := base64.NewDecoder(base64.RawURLEncoding, strings.NewReader(s))
+	if _, err := io.ReadFull(dec, ciphertext); err != nil {
+		return nil, ErrEncoding
+	}
+
+	// Any input left over is malformed.
+	var b [1]byte
+	if _, err := dec.Read(b[:]); err != io.EOF {
+		return nil, ErrEncoding
+	}
+
+	// The plaintext is written over the decoded ciphertext.
+	return aead.Open(ciphertext[:0], nil, ciphertext, ad)
+}
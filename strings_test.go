@@ -0,0 +1,98 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestSealStringGolden(t *testing.T) {
+	key, _ := hex.DecodeString("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff")
+	aead, _ := New(key, aes.NewCipher)
+
+	for _, v := range []struct {
+		plaintext, ad, expected string
+	}{
+		// https://tools.ietf.org/html/rfc5297#appendix-A.1
+		{
+			plaintext: "112233445566778899aabbccddee",
+			ad:        "101112131415161718191a1b1c1d1e1f2021222324252627",
+			expected:  "hWMtB8bo83-VCs0yCi7Mk0DAK5aQxNwE2u9_av5c",
+		},
+		{
+			plaintext: "",
+			ad:        "",
+			expected:  "SZ45lHECGN51guDywKte0A",
+		},
+		{
+			plaintext: "00",
+			ad:        "",
+			expected:  "qGqR2cSn3kMt6cwhRKuAvvY",
+		},
+	} {
+		plaintext, _ := hex.DecodeString(v.plaintext)
+		ad, _ := hex.DecodeString(v.ad)
+
+		actual := SealString(aead, plaintext, ad)
+		if actual != v.expected {
+			t.Errorf("Ciphertext was %q, but expected %q", actual, v.expected)
+		}
+
+		opened, err := OpenString(aead, actual, ad)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(opened, plaintext) {
+			t.Errorf("Plaintext was %x, but expected %x", opened, plaintext)
+		}
+	}
+}
+
+func TestOpenStringErrors(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	s := SealString(aead, []byte("yay"), nil)
+
+	for _, bad := range []string{
+		s + "=",
+		s[:len(s)-1] + "+",
+		s[:len(s)-1] + "/",
+		"a",
+		"abcde",
+		"!",
+	} {
+		if _, err := OpenString(aead, bad, nil); err != ErrEncoding {
+			t.Errorf("Error for %q was %v, but expected %v", bad, err, ErrEncoding)
+		}
+	}
+
+	tampered := []byte(s)
+	if tampered[0] == 'A' {
+		tampered[0] = 'B'
+	} else {
+		tampered[0] = 'A'
+	}
+	if _, err := OpenString(aead, string(tampered), nil); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+
+	if _, err := OpenString(aead, s, []byte("other")); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+}
+
+func FuzzOpenString(f *testing.F) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+
+	f.Add(SealString(aead, []byte("yay"), nil))
+	f.Add("")
+	f.Add("a")
+	f.Add("AAAAAAAAAAAAAAAAAAAAAA")
+	f.Add("hWMtB8bo83-VCs0yCi7Mk0DAK5aQxNwE2u9_av5c")
+	f.Add("====")
+
+	f.Fuzz(func(t *testing.T, s string) {
+		_, _ = OpenString(aead, s, nil)
+	})
+}
@@ -0,0 +1,184 @@
+package siv
+
+import (
+	"container/list"
+	"crypto/aes"
+	"crypto/cipher"
+	"sync"
+	"time"
+)
+
+// TenantLoader fetches or derives a tenant's AES-SIV key, as accepted by New
+// with aes.NewCipher. TenantCache wipes the returned slice once it has
+// constructed the tenant's AEAD, so it must not be retained by the loader.
+type TenantLoader func(tenantID string) (key []byte, err error)
+
+// TenantCache caches an AEAD per tenant, so that hot tenants don't pay for key
+// loading and expansion on every request. It holds at most a fixed number of
+// tenants, evicting the least recently used, and optionally expires entries
+// after a TTL so that rotated keys are picked up. Concurrent misses for the
+// same tenant share a single load. Errors are not cached.
+//
+// Keys are wiped as soon as their AEADs are constructed, but, as with
+// NewFromProvider, the AEADs' key schedules live on the heap until evicted
+// entries are garbage collected, and can't be wiped: an AEAD returned by Get
+// may still be in use when it is evicted.
+//
+// A TenantCache is safe for concurrent use.
+type TenantCache struct {
+	load TenantLoader
+	size int
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu       sync.Mutex
+	lru      *list.List // of *tenantEntry, most recently used first
+	entries  map[string]*list.Element
+	inflight map[string]*tenantLoad
+}
+
+type tenantEntry struct {
+	id      string
+	aead    cipher.AEAD
+	expires time.Time
+}
+
+// tenantLoad is a load in progress, which concurrent misses wait for.
+type tenantLoad struct {
+	done chan struct{}
+	aead cipher.AEAD
+	err  error
+
+	// invalidated is set, and the load removed from inflight, if the tenant
+	// is invalidated during the load, so that a possibly stale result isn't
+	// cached or shared with later callers.
+	invalidated bool
+}
+
+// A TenantCacheOption configures a TenantCache.
+type TenantCacheOption func(*TenantCache)
+
+// WithTenantTTL expires cached AEADs ttl after they were loaded. By default
+// they are kept until evicted or invalidated.
+func WithTenantTTL(ttl time.Duration) TenantCacheOption {
+	return func(c *TenantCache) {
+		c.ttl = ttl
+	}
+}
+
+// NewTenantCache returns a TenantCache holding at most size tenants, which
+// loads their keys with load. It panics if size is not positive.
+func NewTenantCache(size int, load TenantLoader, opts ...TenantCacheOption) *TenantCache {
+	if size <= 0 {
+		panic("siv: tenant cache size must be positive")
+	}
+
+	c := &TenantCache{
+		load:     load,
+		size:     size,
+		now:      time.Now,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		inflight: make(map[string]*tenantLoad),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Get returns the AEAD for tenantID, loading its key if it isn't cached or
+// has expired.
+func (c *TenantCache) Get(tenantID string) (cipher.AEAD, error) {
+	c.mu.Lock()
+	if el, ok := c.entries[tenantID]; ok {
+		e := el.Value.(*tenantEntry)
+		if c.ttl <= 0 || c.now().Before(e.expires) {
+			c.lru.MoveToFront(el)
+			c.mu.Unlock()
+			return e.aead, nil
+		}
+		c.remove(el)
+	}
+
+	if l, ok := c.inflight[tenantID]; ok {
+		c.mu.Unlock()
+		<-l.done
+		return l.aead, l.err
+	}
+
+	l := &tenantLoad{done: make(chan struct{})}
+	c.inflight[tenantID] = l
+	c.mu.Unlock()
+
+	l.aead, l.err = c.newAEAD(tenantID)
+
+	c.mu.Lock()
+	if !l.invalidated {
+		delete(c.inflight, tenantID)
+		if l.err == nil {
+			c.add(tenantID, l.aead)
+		}
+	}
+	c.mu.Unlock()
+	close(l.done)
+
+	return l.aead, l.err
+}
+
+// Invalidate removes tenantID from the cache, so that the next Get loads its
+// key again, as after a rotation. A load already in progress completes for
+// the callers waiting for it, but its result isn't cached, and later calls to
+// Get start a new load.
+func (c *TenantCache) Invalidate(tenantID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[tenantID]; ok {
+		c.remove(el)
+	}
+	if l, ok := c.inflight[tenantID]; ok {
+		l.invalidated = true
+		delete(c.inflight, tenantID)
+	}
+}
+
+// Len returns the number of cached tenants, including any which have expired
+// but not yet been removed.
+func (c *TenantCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.lru.Len()
+}
+
+func (c *TenantCache) newAEAD(tenantID string) (cipher.AEAD, error) {
+	key, err := c.load(tenantID)
+	defer wipe(key)
+	if err != nil {
+		return nil, err
+	}
+	return New(key, aes.NewCipher)
+}
+
+// add caches aead for tenantID, evicting the least recently used tenant if
+// the cache is full. c.mu must be held.
+func (c *TenantCache) add(tenantID string, aead cipher.AEAD) {
+	e := &tenantEntry{id: tenantID, aead: aead}
+	if c.ttl > 0 {
+		e.expires = c.now().Add(c.ttl)
+	}
+	c.entries[tenantID] = c.lru.PushFront(e)
+
+	if c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+// remove drops an entry, so that its AEAD can be garbage collected once no
+// caller holds it. c.mu must be held.
+func (c *TenantCache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*tenantEntry)
+	delete(c.entries, e.id)
+	e.aead = nil
+}
@@ -0,0 +1,241 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingLoader returns a key made of the tenant ID's first byte, counting
+// loads per tenant.
+type countingLoader struct {
+	mu    sync.Mutex
+	loads map[string]int
+	keys  [][]byte
+}
+
+func (l *countingLoader) load(tenantID string) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.loads == nil {
+		l.loads = make(map[string]int)
+	}
+	l.loads[tenantID]++
+
+	key := testKey(tenantID[0], 32)
+	l.keys = append(l.keys, key)
+	return key, nil
+}
+
+func (l *countingLoader) count(tenantID string) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.loads[tenantID]
+}
+
+func TestTenantCacheKeys(t *testing.T) {
+	l := &countingLoader{}
+	c := NewTenantCache(2, l.load)
+
+	aead, err := c.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected, _ := New(testKey('a', 32), aes.NewCipher)
+	actual := aead.Seal(nil, nil, []byte("hello"), nil)
+	if e := expected.Seal(nil, nil, []byte("hello"), nil); !bytes.Equal(actual, e) {
+		t.Errorf("Ciphertext was %x, but expected %x", actual, e)
+	}
+
+	for _, key := range l.keys {
+		if !bytes.Equal(key, make([]byte, 32)) {
+			t.Errorf("Loaded key was %x, but expected it to be wiped", key)
+		}
+	}
+}
+
+func TestTenantCacheEviction(t *testing.T) {
+	l := &countingLoader{}
+	c := NewTenantCache(2, l.load)
+
+	for _, id := range []string{"a", "b", "a", "c"} {
+		if _, err := c.Get(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// b was least recently used when c was added.
+	if n := c.Len(); n != 2 {
+		t.Errorf("Len was %d, but expected %d", n, 2)
+	}
+	for id, expected := range map[string]int{"a": 1, "b": 1, "c": 1} {
+		if n := l.count(id); n != expected {
+			t.Errorf("%s was loaded %d times, but expected %d", id, n, expected)
+		}
+	}
+
+	// a and c are cached, and as a was used last, adding b again evicts c.
+	for _, id := range []string{"a", "c", "a", "b", "a", "c"} {
+		_, _ = c.Get(id)
+	}
+	for id, expected := range map[string]int{"a": 1, "b": 2, "c": 2} {
+		if n := l.count(id); n != expected {
+			t.Errorf("%s was loaded %d times, but expected %d", id, n, expected)
+		}
+	}
+}
+
+func TestTenantCacheTTL(t *testing.T) {
+	l := &countingLoader{}
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	c := NewTenantCache(2, l.load, WithTenantTTL(time.Minute))
+	c.now = clock.now
+
+	_, _ = c.Get("a")
+	clock.t = clock.t.Add(59 * time.Second)
+	_, _ = c.Get("a")
+	if n := l.count("a"); n != 1 {
+		t.Errorf("a was loaded %d times, but expected %d", n, 1)
+	}
+
+	clock.t = clock.t.Add(time.Second)
+	_, _ = c.Get("a")
+	if n := l.count("a"); n != 2 {
+		t.Errorf("a was loaded %d times, but expected %d", n, 2)
+	}
+}
+
+func TestTenantCacheInvalidate(t *testing.T) {
+	l := &countingLoader{}
+	c := NewTenantCache(2, l.load)
+
+	_, _ = c.Get("a")
+	c.Invalidate("a")
+	c.Invalidate("b")
+	if n := c.Len(); n != 0 {
+		t.Errorf("Len was %d, but expected %d", n, 0)
+	}
+
+	_, _ = c.Get("a")
+	if n := l.count("a"); n != 2 {
+		t.Errorf("a was loaded %d times, but expected %d", n, 2)
+	}
+}
+
+func TestTenantCacheErrors(t *testing.T) {
+	fail := errors.New("unavailable")
+	calls := 0
+	c := NewTenantCache(2, func(string) ([]byte, error) {
+		calls++
+		if calls == 1 {
+			return nil, fail
+		}
+		return testKey(0, 32), nil
+	})
+
+	if _, err := c.Get("a"); err != fail {
+		t.Errorf("Error was %v, but expected %v", err, fail)
+	}
+	if _, err := c.Get("a"); err != nil {
+		t.Errorf("Error was %v, but expected the load to be retried", err)
+	}
+}
+
+// blockingLoader blocks each load until released.
+type blockingLoader struct {
+	countingLoader
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingLoader() *blockingLoader {
+	return &blockingLoader{started: make(chan struct{}, 100), release: make(chan struct{})}
+}
+
+func (l *blockingLoader) load(tenantID string) ([]byte, error) {
+	l.started <- struct{}{}
+	<-l.release
+	return l.countingLoader.load(tenantID)
+}
+
+func TestTenantCacheSingleflight(t *testing.T) {
+	l := newBlockingLoader()
+	c := NewTenantCache(2, l.load)
+
+	var wg sync.WaitGroup
+	aeads := make([]any, 10)
+	for i := range aeads {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			aead, err := c.Get("a")
+			if err != nil {
+				t.Error(err)
+			}
+			aeads[i] = aead
+		}(i)
+	}
+
+	<-l.started
+	close(l.release)
+	wg.Wait()
+
+	// Callers which arrive after the load completes hit the cache, so
+	// however they interleave there is only one load.
+	if n := l.count("a"); n != 1 {
+		t.Errorf("a was loaded %d times, but expected %d", n, 1)
+	}
+	for _, aead := range aeads {
+		if aead != aeads[0] {
+			t.Fatal("Concurrent callers were given different AEADs")
+		}
+	}
+}
+
+func TestTenantCacheInvalidateDuringLoad(t *testing.T) {
+	l := newBlockingLoader()
+	c := NewTenantCache(2, l.load)
+
+	done := make(chan error)
+	go func() {
+		_, err := c.Get("a")
+		done <- err
+	}()
+
+	<-l.started
+	c.Invalidate("a")
+	close(l.release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// The stale load wasn't cached.
+	if n := c.Len(); n != 0 {
+		t.Errorf("Len was %d, but expected %d", n, 0)
+	}
+	_, _ = c.Get("a")
+	if n := l.count("a"); n != 2 {
+		t.Errorf("a was loaded %d times, but expected %d", n, 2)
+	}
+}
+
+func BenchmarkTenantCacheHit(b *testing.B) {
+	c := NewTenantCache(16, func(string) ([]byte, error) {
+		return testKey(0, 32), nil
+	})
+	_, _ = c.Get("tenant")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Get("tenant"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
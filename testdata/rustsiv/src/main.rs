@@ -0,0 +1,109 @@
+//! Generates ../rustsiv.json, the interoperability fixtures for the RustCrypto
+//! aes-siv crate. Run with `cargo run -q > ../rustsiv.json`.
+
+use aes_siv::aead::{Aead, KeyInit, Payload};
+use aes_siv::siv::{Aes128Siv, Aes256Siv};
+use aes_siv::{Aes128SivAead, Aes256SivAead, Nonce};
+
+fn bytes(n: usize, seed: u8) -> Vec<u8> {
+    (0..n)
+        .map(|i| seed.wrapping_add((i as u8).wrapping_mul(7)))
+        .collect()
+}
+
+fn hex(b: &[u8]) -> String {
+    b.iter().map(|v| format!("{:02x}", v)).collect()
+}
+
+fn from_hex(s: &str) -> Vec<u8> {
+    (0..s.len())
+        .step_by(2)
+        .map(|i| u8::from_str_radix(&s[i..i + 2], 16).unwrap())
+        .collect()
+}
+
+fn aead_entry(alg: &str, key_size: usize, aad_len: usize, pt_len: usize) -> String {
+    let key = bytes(key_size, 0x20);
+    let nonce = bytes(16, 0x90);
+    let aad = bytes(aad_len, 0x40);
+    let pt = bytes(pt_len, 0x70);
+    let payload = Payload { msg: &pt, aad: &aad };
+
+    let ct = match alg {
+        "Aes128SivAead" => Aes128SivAead::new_from_slice(&key)
+            .unwrap()
+            .encrypt(Nonce::from_slice(&nonce), payload),
+        _ => Aes256SivAead::new_from_slice(&key)
+            .unwrap()
+            .encrypt(Nonce::from_slice(&nonce), payload),
+    }
+    .unwrap();
+
+    format!(
+        "  {{\n    \"name\": \"{}/aad={}/pt={}\",\n    \"api\": \"aead\",\n    \"key\": \"{}\",\n    \"nonce\": \"{}\",\n    \"aad\": \"{}\",\n    \"plaintext\": \"{}\",\n    \"ciphertext\": \"{}\"\n  }}",
+        alg,
+        aad_len,
+        pt_len,
+        hex(&key),
+        hex(&nonce),
+        hex(&aad),
+        hex(&pt),
+        hex(&ct)
+    )
+}
+
+fn siv_entry(name: &str, alg: &str, key: Vec<u8>, headers: Vec<Vec<u8>>, pt: Vec<u8>) -> String {
+    let ct = match alg {
+        "Aes128Siv" => Aes128Siv::new_from_slice(&key).unwrap().encrypt(&headers, &pt),
+        _ => Aes256Siv::new_from_slice(&key).unwrap().encrypt(&headers, &pt),
+    }
+    .unwrap();
+
+    let headers: Vec<String> = headers.iter().map(|h| format!("\"{}\"", hex(h))).collect();
+    format!(
+        "  {{\n    \"name\": \"{}\",\n    \"api\": \"siv\",\n    \"key\": \"{}\",\n    \"headers\": [{}],\n    \"plaintext\": \"{}\",\n    \"ciphertext\": \"{}\"\n  }}",
+        name,
+        hex(&key),
+        headers.join(", "),
+        hex(&pt),
+        hex(&ct)
+    )
+}
+
+fn main() {
+    let mut entries = Vec::new();
+
+    for (alg, key_size) in [("Aes128SivAead", 32), ("Aes256SivAead", 64)] {
+        for aad_len in [0, 5, 40] {
+            for pt_len in [0, 1, 16, 33] {
+                entries.push(aead_entry(alg, key_size, aad_len, pt_len));
+            }
+        }
+    }
+
+    let layouts: [&[usize]; 5] = [&[], &[0], &[5], &[0, 0], &[3, 20, 16]];
+    for (alg, key_size) in [("Aes128Siv", 32), ("Aes256Siv", 64)] {
+        for layout in layouts {
+            for pt_len in [0, 15, 32] {
+                let headers = layout
+                    .iter()
+                    .enumerate()
+                    .map(|(j, &n)| bytes(n, 0x10 + 0x20 * j as u8))
+                    .collect();
+                let name = format!("{}/headers={:?}/pt={}", alg, layout, pt_len);
+                entries.push(siv_entry(&name, alg, bytes(key_size, 0x20), headers, bytes(pt_len, 0x70)));
+            }
+        }
+    }
+
+    // RFC 5297 appendix A.1.
+    entries.push(siv_entry(
+        "Aes128Siv/rfc5297-a1",
+        "Aes128Siv",
+        from_hex("fffefdfcfbfaf9f8f7f6f5f4f3f2f1f0f0f1f2f3f4f5f6f7f8f9fafbfcfdfeff"),
+        vec![from_hex("101112131415161718191a1b1c1d1e1f2021222324252627")],
+        from_hex("112233445566778899aabbccddee"),
+    ));
+
+    println!("[\n{}\n]", entries.join(",\n"));
+}
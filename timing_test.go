@@ -0,0 +1,124 @@
+package siv
+
+import (
+	"crypto/aes"
+	"flag"
+	"math"
+	"testing"
+	"time"
+)
+
+// Open must take the same time whichever byte of the tag is wrong, and roughly
+// the same time whether or not authentication succeeds: the tag comparison is
+// subtle.ConstantTimeCompare, and the CTR and S2V passes over the ciphertext
+// always run to completion. Changes to the comparison or the S2V loops should
+// keep these tests passing with -timing.
+var timing = flag.Bool("timing", false, "run statistical timing-leak tests")
+
+const (
+	timingSamples = 2000
+	timingBatch   = 50
+
+	// A gross leak must be both statistically significant and large.
+	timingMaxT       = 50
+	timingMaxRelDiff = 0.25
+)
+
+// measure returns interleaved per-call timings of the given functions.
+func measure(fs ...func()) [][]float64 {
+	samples := make([][]float64, len(fs))
+	for i := 0; i < timingSamples; i++ {
+		for j, f := range fs {
+			start := time.Now()
+			for k := 0; k < timingBatch; k++ {
+				f()
+			}
+			samples[j] = append(samples[j], float64(time.Since(start))/timingBatch)
+		}
+	}
+	return samples
+}
+
+// welch returns Welch's t statistic and the relative difference of the means.
+func welch(a, b []float64) (t, relDiff float64) {
+	meanVar := func(x []float64) (float64, float64) {
+		var sum float64
+		for _, v := range x {
+			sum += v
+		}
+		mean := sum / float64(len(x))
+
+		var ss float64
+		for _, v := range x {
+			ss += (v - mean) * (v - mean)
+		}
+		return mean, ss / float64(len(x)-1)
+	}
+
+	ma, va := meanVar(a)
+	mb, vb := meanVar(b)
+	t = (ma - mb) / math.Sqrt(va/float64(len(a))+vb/float64(len(b)))
+	return t, math.Abs(ma-mb) / math.Min(ma, mb)
+}
+
+func checkTiming(t *testing.T, name string, a, b []float64) {
+	tStat, relDiff := welch(a, b)
+	t.Logf("%s: t=%.2f, difference=%.1f%%", name, tStat, relDiff*100)
+	if math.Abs(tStat) > timingMaxT && relDiff > timingMaxRelDiff {
+		t.Errorf("%s: timing leak (t=%.2f, difference=%.1f%%)", name, tStat, relDiff*100)
+	}
+}
+
+func TestOpenTimingTagPosition(t *testing.T) {
+	if !*timing {
+		t.Skip("run with -timing")
+	}
+
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	ciphertext := aead.Seal(nil, nil, make([]byte, 256), nil)
+
+	first := append([]byte{}, ciphertext...)
+	first[0] ^= 1
+
+	last := append([]byte{}, ciphertext...)
+	last[aead.Overhead()-1] ^= 1
+
+	samples := measure(
+		func() { _, _ = aead.Open(nil, nil, first, nil) },
+		func() { _, _ = aead.Open(nil, nil, last, nil) },
+	)
+	checkTiming(t, "first vs last tag byte", samples[0], samples[1])
+}
+
+func TestOpenTimingSuccessFailure(t *testing.T) {
+	if !*timing {
+		t.Skip("run with -timing")
+	}
+
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	ciphertext := aead.Seal(nil, nil, make([]byte, 256), nil)
+
+	bad := append([]byte{}, ciphertext...)
+	bad[len(bad)-1] ^= 1
+
+	dst := make([]byte, 0, 256)
+	samples := measure(
+		func() { _, _ = aead.Open(dst, nil, ciphertext, nil) },
+		func() { _, _ = aead.Open(dst, nil, bad, nil) },
+	)
+	checkTiming(t, "success vs failure", samples[0], samples[1])
+}
+
+func TestWelch(t *testing.T) {
+	a := []float64{10, 11, 10, 11, 10, 11, 10, 11}
+	b := []float64{20, 21, 20, 21, 20, 21, 20, 21}
+
+	tStat, relDiff := welch(a, b)
+	if math.Abs(tStat+37.42) > 0.01 {
+		t.Errorf("t was %f, but expected -37.42", tStat)
+	}
+
+	if math.Abs(relDiff-10.0/10.5) > 0.001 {
+		t.Errorf("Relative difference was %f, but expected %f", relDiff, 10.0/10.5)
+	}
+}
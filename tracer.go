@@ -0,0 +1,72 @@
+package siv
+
+import (
+	"sync/atomic"
+)
+
+// Tracer starts spans around expensive operations, such as opening with a
+// Keyring, sealing and opening envelopes, re-encrypting batches, and reading
+// or writing streams. StartSpan is called with the operation's name, such as
+// "siv.Keyring.Open", and the function it returns is called once with the
+// operation's error, or nil, when it completes. Both must be safe for
+// concurrent use.
+//
+// Tracers let callers use their tracing system without this module depending
+// on it. For example, an adapter for OpenTelemetry might be:
+//
+//	type otelTracer struct {
+//		ctx    context.Context
+//		tracer trace.Tracer
+//	}
+//
+//	func (o otelTracer) StartSpan(name string) func(error) {
+//		_, span := o.tracer.Start(o.ctx, name)
+//		return func(err error) {
+//			if err != nil {
+//				span.RecordError(err)
+//				span.SetStatus(codes.Error, err.Error())
+//			}
+//			span.End()
+//		}
+//	}
+type Tracer interface {
+	StartSpan(name string) func(err error)
+}
+
+// NopTracer is a Tracer which does nothing. It is equivalent to passing a nil
+// Tracer, which is the default.
+var NopTracer Tracer = nopTracer{}
+
+type nopTracer struct{}
+
+func (nopTracer) StartSpan(string) func(error) {
+	return endNop
+}
+
+func endNop(error) {}
+
+type tracerHolder struct {
+	t Tracer
+}
+
+var defaultTracer atomic.Pointer[tracerHolder]
+
+// SetTracer sets the Tracer used by SealMulti, MultiEnvelope, ReEncryptBatch,
+// and Keyrings without a Tracer of their own. A nil Tracer disables tracing.
+func SetTracer(t Tracer) {
+	defaultTracer.Store(&tracerHolder{t: t})
+}
+
+// startSpan starts a span with t or, if t is nil, the default Tracer. Without
+// either, it doesn't allocate.
+func startSpan(t Tracer, name string) func(error) {
+	if t == nil {
+		if h := defaultTracer.Load(); h != nil {
+			t = h.t
+		}
+		if t == nil {
+			return endNop
+		}
+	}
+	return t.StartSpan(name)
+}
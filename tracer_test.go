@@ -0,0 +1,186 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"sync"
+	"testing"
+)
+
+type span struct {
+	name string
+	err  error
+}
+
+// recordingTracer records finished spans. An adapter for a real tracing
+// system, such as the OpenTelemetry one in Tracer's documentation, would
+// start a span in StartSpan and end it in the returned function.
+type recordingTracer struct {
+	mu    sync.Mutex
+	spans []span
+	open  int
+}
+
+func (r *recordingTracer) StartSpan(name string) func(error) {
+	r.mu.Lock()
+	r.open++
+	r.mu.Unlock()
+
+	return func(err error) {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.open--
+		r.spans = append(r.spans, span{name, err})
+	}
+}
+
+func (r *recordingTracer) take(t *testing.T) []span {
+	t.Helper()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.open != 0 {
+		t.Errorf("%d spans were not ended", r.open)
+	}
+	s := r.spans
+	r.spans = nil
+	return s
+}
+
+func checkSpans(t *testing.T, actual []span, expected ...span) {
+	t.Helper()
+	if len(actual) != len(expected) {
+		t.Fatalf("Spans were %v, but expected %v", actual, expected)
+	}
+	for i := range actual {
+		if actual[i].name != expected[i].name || !errors.Is(actual[i].err, expected[i].err) {
+			t.Errorf("Span was %v, but expected %v", actual[i], expected[i])
+		}
+	}
+}
+
+func TestTracerKeyring(t *testing.T) {
+	k, aeads := testKeyring(t, "a", "b")
+	tracer := &recordingTracer{}
+	k.SetTracer(tracer)
+
+	ciphertext := aeads["b"].Seal(nil, nil, []byte("hello"), nil)
+	if _, err := k.Open(nil, nil, ciphertext, nil); err != nil {
+		t.Fatal(err)
+	}
+	if plaintext, err := k.Open(nil, nil, ciphertext, []byte("wrong")); err == nil {
+		t.Fatalf("Plaintext returned instead of error: %q", plaintext)
+	}
+	checkSpans(t, tracer.take(t),
+		span{"siv.Keyring.Open", nil},
+		span{"siv.Keyring.Open", ErrAuthentication})
+
+	// Sealing isn't traced.
+	k.Seal(nil, nil, []byte("hello"), nil)
+	checkSpans(t, tracer.take(t))
+
+	// Without a Tracer of its own, the keyring uses the default.
+	global := &recordingTracer{}
+	SetTracer(global)
+	t.Cleanup(func() { SetTracer(nil) })
+
+	k.SetTracer(nil)
+	_, _ = k.Open(nil, nil, ciphertext, nil)
+	checkSpans(t, tracer.take(t))
+	checkSpans(t, global.take(t), span{"siv.Keyring.Open", nil})
+}
+
+func TestTracerEnvelope(t *testing.T) {
+	tracer := &recordingTracer{}
+	SetTracer(tracer)
+	t.Cleanup(func() { SetTracer(nil) })
+
+	r := recipients(t, 3)
+	e, err := SealMulti(r[:2], []byte("hello"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Open(r[0], nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := e.Open(r[2], nil); err != ErrNoRecipient {
+		t.Errorf("Error was %v, but expected %v", err, ErrNoRecipient)
+	}
+	if err := e.AddRecipient(r[1], r[2], []byte("wrong")); err == nil {
+		t.Error("No error for the wrong associated data")
+	}
+	if _, err := SealMulti([]cipher.AEAD{fingerprintless{r[0]}}, nil, nil); err != ErrFingerprint {
+		t.Errorf("Error was %v, but expected %v", err, ErrFingerprint)
+	}
+
+	checkSpans(t, tracer.take(t),
+		span{"siv.SealMulti", nil},
+		span{"siv.MultiEnvelope.Open", nil},
+		span{"siv.MultiEnvelope.Open", ErrNoRecipient},
+		span{"siv.MultiEnvelope.AddRecipient", ErrAuthentication},
+		span{"siv.SealMulti", ErrFingerprint})
+}
+
+type fingerprintless struct {
+	cipher.AEAD
+}
+
+func TestTracerReEncryptBatch(t *testing.T) {
+	tracer := &recordingTracer{}
+	SetTracer(tracer)
+	t.Cleanup(func() { SetTracer(nil) })
+
+	r := recipients(t, 2)
+	ciphertexts := [][]byte{r[0].Seal(nil, nil, []byte("a"), nil), r[0].Seal(nil, nil, []byte("b"), nil)}
+	if _, err := ReEncryptBatch(r[0], r[1], ciphertexts, nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReEncryptBatch(r[1], r[0], ciphertexts, nil); err == nil {
+		t.Error("No error for the wrong key")
+	}
+
+	checkSpans(t, tracer.take(t),
+		span{"siv.ReEncryptBatch", nil},
+		span{"siv.ReEncryptBatch", ErrAuthentication})
+}
+
+func TestNopTracer(t *testing.T) {
+	if n := testing.AllocsPerRun(100, func() { startSpan(nil, "op")(nil) }); n != 0 {
+		t.Errorf("Default span allocated %v times, but expected none", n)
+	}
+
+	SetTracer(NopTracer)
+	t.Cleanup(func() { SetTracer(nil) })
+	if n := testing.AllocsPerRun(100, func() { startSpan(nil, "op")(nil) }); n != 0 {
+		t.Errorf("NopTracer span allocated %v times, but expected none", n)
+	}
+}
+
+func benchmarkKeyringOpen(b *testing.B, tracer Tracer) {
+	k := NewKeyring()
+	for i := byte(0); i < 3; i++ {
+		aead, _ := New(bytes.Repeat([]byte{i}, 32), aes.NewCipher, AllowEqualHalves())
+		_ = k.Add(string('a'+i), aead)
+	}
+	k.SetTracer(tracer)
+
+	aead, _ := k.Lookup("c")
+	ciphertext := aead.Seal(nil, nil, make([]byte, 64), nil)
+	dst := make([]byte, 0, 64)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = k.Open(dst, nil, ciphertext, nil)
+	}
+}
+
+func BenchmarkKeyringOpen(b *testing.B) {
+	benchmarkKeyringOpen(b, nil)
+}
+
+func BenchmarkKeyringOpenNopTracer(b *testing.B) {
+	benchmarkKeyringOpen(b, NopTracer)
+}
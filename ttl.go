@@ -0,0 +1,84 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrExpired is returned by OpenWithExpiry for a ciphertext which is
+	// authentic but whose expiry time has passed.
+	ErrExpired = errors.New("siv: ciphertext expired")
+
+	// ErrInvalidExpiry is returned by SealWithTTL for an expiry time before
+	// the Unix epoch.
+	ErrInvalidExpiry = errors.New("siv: invalid expiry time")
+)
+
+// expirySize is the size of the expiry timestamp prefix.
+const expirySize = 8
+
+// An ExpiryOption configures OpenWithExpiry.
+type ExpiryOption func(*expiryConfig)
+
+type expiryConfig struct {
+	skew time.Duration
+}
+
+// WithSkew makes OpenWithExpiry accept ciphertexts up to d after their expiry
+// time, to tolerate clock differences between the hosts which seal and open
+// them.
+func WithSkew(d time.Duration) ExpiryOption {
+	return func(c *expiryConfig) {
+		c.skew = d
+	}
+}
+
+// SealWithTTL seals plaintext so that OpenWithExpiry accepts it only until
+// expires. The ciphertext is prefixed with the expiry time as 64-bit
+// big-endian Unix seconds, which is bound to it as associated data, so it can
+// be read without the key but not changed. Fractions of a second are
+// truncated, so the ciphertext may expire up to a second early.
+func SealWithTTL(aead cipher.AEAD, plaintext, ad []byte, expires time.Time) ([]byte, error) {
+	if expires.Unix() < 0 {
+		return nil, ErrInvalidExpiry
+	}
+
+	ts := binary.BigEndian.AppendUint64(make([]byte, 0, expirySize+aead.Overhead()+len(plaintext)), uint64(expires.Unix()))
+	return bindExpiry(aead, ts).Seal(ts, nil, plaintext, ad), nil
+}
+
+// OpenWithExpiry opens a ciphertext sealed by SealWithTTL, returning
+// ErrExpired if now is after its expiry time. The ciphertext is authenticated
+// before its expiry time is checked, so a tampered timestamp fails with
+// ErrAuthentication rather than ErrExpired.
+func OpenWithExpiry(aead cipher.AEAD, ciphertext, ad []byte, now time.Time, opts ...ExpiryOption) ([]byte, error) {
+	var c expiryConfig
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	if len(ciphertext) < expirySize {
+		return nil, ErrAuthentication
+	}
+	ts := ciphertext[:expirySize]
+
+	plaintext, err := bindExpiry(aead, ts).Open(nil, nil, ciphertext[expirySize:], ad)
+	if err != nil {
+		return nil, err
+	}
+
+	// An authentic timestamp was written by SealWithTTL, so fits an int64.
+	expires := time.Unix(int64(binary.BigEndian.Uint64(ts)), 0)
+	if now.After(expires.Add(c.skew)) {
+		wipe(plaintext)
+		return nil, ErrExpired
+	}
+	return plaintext, nil
+}
+
+func bindExpiry(aead cipher.AEAD, ts []byte) cipher.AEAD {
+	return NewContext(aead, []byte("siv expiry"), ts)
+}
@@ -0,0 +1,82 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestSealWithTTL(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	expires := time.Unix(1700000000, 500)
+	plaintext := []byte("reset my password")
+
+	ciphertext, err := SealWithTTL(aead, plaintext, []byte("user 42"), expires)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ts := binary.BigEndian.Uint64(ciphertext); ts != 1700000000 {
+		t.Errorf("Timestamp was %d, but expected %d", ts, 1700000000)
+	}
+	if n := len(ciphertext); n != 8+16+len(plaintext) {
+		t.Errorf("Ciphertext was %d bytes, but expected %d", n, 8+16+len(plaintext))
+	}
+
+	for _, now := range []time.Time{expires.Add(-time.Hour), time.Unix(1700000000, 0)} {
+		actual, err := OpenWithExpiry(aead, ciphertext, []byte("user 42"), now)
+		if err != nil {
+			t.Fatalf("%v: %v", now, err)
+		}
+		if !bytes.Equal(actual, plaintext) {
+			t.Errorf("Plaintext was %x, but expected %x", actual, plaintext)
+		}
+	}
+
+	expired := time.Unix(1700000001, 0)
+	if actual, err := OpenWithExpiry(aead, ciphertext, []byte("user 42"), expired); err != ErrExpired {
+		t.Errorf("Error was %v, but expected %v (plaintext %x)", err, ErrExpired, actual)
+	}
+
+	if _, err := OpenWithExpiry(aead, ciphertext, []byte("user 42"), expired, WithSkew(time.Second)); err != nil {
+		t.Errorf("Error was %v, but expected the skew to be tolerated", err)
+	}
+	if _, err := OpenWithExpiry(aead, ciphertext, []byte("user 42"), expired.Add(time.Nanosecond), WithSkew(time.Second)); err != ErrExpired {
+		t.Errorf("Error was %v, but expected %v", err, ErrExpired)
+	}
+
+	if _, err := OpenWithExpiry(aead, ciphertext, []byte("user 43"), expires); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+}
+
+func TestOpenWithExpiryTamperedTimestamp(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	ciphertext, _ := SealWithTTL(aead, []byte("grant"), nil, time.Unix(1700000000, 0))
+
+	// Extending the expiry must fail authentication, even when the
+	// ciphertext would otherwise have expired.
+	tampered := append([]byte{}, ciphertext...)
+	binary.BigEndian.PutUint64(tampered, 1800000000)
+	for _, now := range []time.Time{time.Unix(1600000000, 0), time.Unix(1750000000, 0)} {
+		if actual, err := OpenWithExpiry(aead, tampered, nil, now); err != ErrAuthentication {
+			t.Fatalf("Plaintext returned instead of error: %x (%v)", actual, err)
+		}
+	}
+
+	for _, short := range [][]byte{nil, ciphertext[:7], ciphertext[:8]} {
+		if _, err := OpenWithExpiry(aead, short, nil, time.Unix(0, 0)); err != ErrAuthentication {
+			t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+		}
+	}
+}
+
+func TestSealWithTTLInvalidExpiry(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+
+	if _, err := SealWithTTL(aead, nil, nil, time.Time{}); err != ErrInvalidExpiry {
+		t.Errorf("Error was %v, but expected %v", err, ErrInvalidExpiry)
+	}
+}
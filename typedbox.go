@@ -0,0 +1,114 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrBoxDecode is returned by Box's Open methods when the plaintext
+// authenticates but can't be decoded into the box's type, which usually means
+// it was sealed as a different type. It is distinct from ErrAuthentication.
+var ErrBoxDecode = errors.New("siv: can't decode box plaintext")
+
+// A Codec serializes the values held in a Box.
+type Codec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec is the Codec used by SealBox and Box.Open, which serializes values
+// with encoding/json. As usual for encoding/json, unknown object fields are
+// ignored when decoding, so a box decodes into a struct type it wasn't sealed
+// as if their fields don't conflict.
+var JSONCodec Codec = jsonCodec{}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// Box is an encrypted value of type T. Its zero value holds no ciphertext, and
+// fails to open with ErrAuthentication.
+//
+// It encodes to JSON as a string of the ciphertext in standard padded base64,
+// so it can be embedded in API responses in place of the value.
+type Box[T any] struct {
+	ciphertext []byte
+}
+
+func boxAEAD(aead cipher.AEAD) cipher.AEAD {
+	return NewContext(aead, []byte("siv box"))
+}
+
+// SealBox serializes value with JSONCodec and seals it with aead and the
+// associated data ad.
+func SealBox[T any](aead cipher.AEAD, value T, ad []byte) (Box[T], error) {
+	return SealBoxCodec(aead, JSONCodec, value, ad)
+}
+
+// SealBoxCodec is like SealBox, but serializes value with codec. The box must
+// be opened with OpenCodec and the same codec.
+func SealBoxCodec[T any](aead cipher.AEAD, codec Codec, value T, ad []byte) (Box[T], error) {
+	plaintext, err := codec.Marshal(value)
+	if err != nil {
+		return Box[T]{}, err
+	}
+	defer wipe(plaintext)
+
+	return Box[T]{ciphertext: boxAEAD(aead).Seal(nil, nil, plaintext, ad)}, nil
+}
+
+// Open authenticates and decrypts the box with aead and the associated data
+// ad, and decodes its value with JSONCodec. A decoding failure is returned
+// wrapping ErrBoxDecode.
+func (b Box[T]) Open(aead cipher.AEAD, ad []byte) (T, error) {
+	return b.OpenCodec(aead, JSONCodec, ad)
+}
+
+// OpenCodec is like Open, but decodes the value with codec.
+func (b Box[T]) OpenCodec(aead cipher.AEAD, codec Codec, ad []byte) (T, error) {
+	var value T
+	plaintext, err := boxAEAD(aead).Open(nil, nil, b.ciphertext, ad)
+	if err != nil {
+		return value, err
+	}
+	defer wipe(plaintext)
+
+	if err := codec.Unmarshal(plaintext, &value); err != nil {
+		var zero T
+		return zero, fmt.Errorf("%w: %v", ErrBoxDecode, err)
+	}
+	return value, nil
+}
+
+// Bytes returns the box's ciphertext, for storage. BoxFromBytes reverses it.
+func (b Box[T]) Bytes() []byte {
+	return append([]byte(nil), b.ciphertext...)
+}
+
+// BoxFromBytes returns a box holding ciphertext, as returned by Bytes.
+func BoxFromBytes[T any](ciphertext []byte) Box[T] {
+	return Box[T]{ciphertext: append([]byte(nil), ciphertext...)}
+}
+
+// MarshalJSON implements json.Marshaler.
+func (b Box[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(b.ciphertext)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (b *Box[T]) UnmarshalJSON(data []byte) error {
+	var ciphertext []byte
+	if err := json.Unmarshal(data, &ciphertext); err != nil {
+		return err
+	}
+	b.ciphertext = ciphertext
+	return nil
+}
@@ -0,0 +1,156 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type boxUser struct {
+	Name  string   `json:"name"`
+	Email string   `json:"email"`
+	Tags  []string `json:"tags"`
+}
+
+func TestBox(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	ad := []byte("users/1")
+	user := boxUser{Name: "Alice", Email: "alice@example.com", Tags: []string{"admin"}}
+
+	b, err := SealBox(aead, user, ad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual, err := b.Open(aead, ad); err != nil || !reflect.DeepEqual(actual, user) {
+		t.Errorf("Value was %+v, %v, but expected %+v", actual, err, user)
+	}
+
+	s, _ := SealBox(aead, []int{1, 2, 3}, ad)
+	if actual, err := s.Open(aead, ad); err != nil || !reflect.DeepEqual(actual, []int{1, 2, 3}) {
+		t.Errorf("Value was %v, %v, but expected [1 2 3]", actual, err)
+	}
+
+	p, _ := SealBox(aead, &user, ad)
+	if actual, err := p.Open(aead, ad); err != nil || actual == &user || !reflect.DeepEqual(*actual, user) {
+		t.Errorf("Value was %+v, %v, but expected a copy of %+v", actual, err, user)
+	}
+
+	n, _ := SealBox[*boxUser](aead, nil, ad)
+	if actual, err := n.Open(aead, ad); err != nil || actual != nil {
+		t.Errorf("Value was %+v, %v, but expected nil", actual, err)
+	}
+
+	if actual, err := b.Open(aead, []byte("users/2")); err != ErrAuthentication {
+		t.Fatalf("Plaintext returned instead of error: %+v", actual)
+	}
+
+	other, _ := New(bytes.Repeat([]byte{1}, 32), aes.NewCipher, AllowEqualHalves())
+	if actual, err := b.Open(other, ad); err != ErrAuthentication {
+		t.Fatalf("Plaintext returned instead of error: %+v", actual)
+	}
+
+	if _, err := (Box[string]{}).Open(aead, ad); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+
+	// The box isn't a plain ciphertext of the JSON encoding.
+	plain := aead.Seal(nil, nil, []byte(`"x"`), ad)
+	if _, err := BoxFromBytes[string](plain).Open(aead, ad); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+}
+
+func TestBoxTypeMismatch(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	b, _ := SealBox(aead, boxUser{Name: "Alice"}, nil)
+	raw := b.Bytes()
+
+	if actual, err := BoxFromBytes[[]string](raw).Open(aead, nil); !errors.Is(err, ErrBoxDecode) || actual != nil {
+		t.Errorf("Value was %v, %v, but expected %v", actual, err, ErrBoxDecode)
+	}
+	if actual, err := BoxFromBytes[int](raw).Open(aead, nil); !errors.Is(err, ErrBoxDecode) || actual != 0 {
+		t.Errorf("Value was %v, %v, but expected %v", actual, err, ErrBoxDecode)
+	}
+
+	s, _ := SealBox(aead, []string{"a"}, nil)
+	if actual, err := BoxFromBytes[*boxUser](s.Bytes()).Open(aead, nil); !errors.Is(err, ErrBoxDecode) || actual != nil {
+		t.Errorf("Value was %+v, %v, but expected %v", actual, err, ErrBoxDecode)
+	}
+}
+
+func TestBoxJSON(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	type response struct {
+		ID    int            `json:"id"`
+		Email Box[string]    `json:"email"`
+		User  *Box[*boxUser] `json:"user"`
+	}
+
+	email, _ := SealBox(aead, "alice@example.com", nil)
+	user, _ := SealBox(aead, &boxUser{Name: "Alice"}, nil)
+	b, err := json.Marshal(response{ID: 1, Email: email, User: &user})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(b, []byte("alice")) || bytes.Contains(b, []byte("Alice")) {
+		t.Errorf("Response contains the plaintext: %s", b)
+	}
+
+	var fields map[string]any
+	_ = json.Unmarshal(b, &fields)
+	if s, ok := fields["email"].(string); !ok || s == "" {
+		t.Errorf("Email was %#v, but expected a base64 string", fields["email"])
+	}
+
+	var actual response
+	if err := json.Unmarshal(b, &actual); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := actual.Email.Open(aead, nil); err != nil || v != "alice@example.com" {
+		t.Errorf("Email was %q, %v", v, err)
+	}
+	if v, err := actual.User.Open(aead, nil); err != nil || v.Name != "Alice" {
+		t.Errorf("User was %+v, %v", v, err)
+	}
+
+	if err := json.Unmarshal([]byte(`{"email":5}`), &actual); err == nil {
+		t.Error("No error for a malformed box")
+	}
+}
+
+type gobCodec struct{}
+
+func (gobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+func (gobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+func TestBoxCodec(t *testing.T) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	user := boxUser{Name: "Alice", Tags: []string{"a", "b"}}
+
+	b, err := SealBoxCodec(aead, gobCodec{}, user, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual, err := b.OpenCodec(aead, gobCodec{}, nil); err != nil || !reflect.DeepEqual(actual, user) {
+		t.Errorf("Value was %+v, %v, but expected %+v", actual, err, user)
+	}
+
+	if _, err := b.Open(aead, nil); !errors.Is(err, ErrBoxDecode) {
+		t.Errorf("Error was %v, but expected %v", err, ErrBoxDecode)
+	}
+
+	if _, err := SealBox(aead, make(chan int), nil); err == nil {
+		t.Error("No error for an unencodable value")
+	}
+}
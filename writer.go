@@ -0,0 +1,262 @@
+package siv
+
+import (
+	"crypto/cipher"
+	"crypto/subtle"
+	"io"
+
+	"github.com/ebfe/cmac"
+)
+
+// streamChunkSize is the size of the buffer used to decrypt ciphertexts in
+// pieces.
+const streamChunkSize = 16 * 1024
+
+// OpenToWriter decrypts and authenticates ciphertext, writing the plaintext to
+// w only once the ciphertext has been authenticated. It is equivalent to
+// Open(nil, nil, ciphertext, data) but never holds more than a small, fixed
+// amount of plaintext in memory.
+//
+// The ciphertext is decrypted twice: the first CTR pass feeds the plaintext to
+// the MAC in pieces, and the second, performed only if the recomputed tag
+// matches, writes the plaintext to w in pieces. Nothing is written to w if
+// authentication fails. It returns the number of bytes written and any error
+// returned by w; if w accepts fewer bytes than it is given without returning an
+// error, io.ErrShortWrite is returned.
+func (s *SIV) OpenToWriter(w io.Writer, ciphertext, data []byte) (int64, error) {
+	if len(ciphertext) < s.Overhead() {
+		return 0, ErrAuthentication
+	}
+
+	v, ciphertext := ciphertext[:s.Overhead()], ciphertext[s.Overhead():]
+	buf := make([]byte, streamChunkSize)
+	defer wipe(buf)
+
+	if subtle.ConstantTimeCompare(v, s.s2vCiphertext(buf, v, ciphertext, data)) != 1 {
+		return 0, ErrAuthentication
+	}
+
+	var written int64
+	ctr := cipher.NewCTR(s.enc, ctr(v))
+	for len(ciphertext) > 0 {
+		p := buf
+		if len(ciphertext) < len(p) {
+			p = p[:len(ciphertext)]
+		}
+		ctr.XORKeyStream(p, ciphertext[:len(p)])
+		ciphertext = ciphertext[len(p):]
+
+		n, err := w.Write(p)
+		written += int64(n)
+		if err == nil && n < len(p) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
+
+// OpenSeeker decrypts and authenticates the ciphertext read from src, from its
+// current offset to its end, writing the plaintext to w only once the
+// ciphertext has been authenticated. It is equivalent to
+// Open(nil, nil, ciphertext, data) but never holds more than a small, fixed
+// amount of the ciphertext or plaintext in memory, so files sealed whole can
+// be opened whatever their size.
+//
+// As for OpenToWriter, the ciphertext is decrypted twice: the first pass
+// recomputes the tag, and the second, performed only if it matches, seeks src
+// back to the start of the ciphertext and writes the plaintext to w in pieces.
+// src must not change between the passes, as the second isn't authenticated.
+// Nothing is written to w if authentication fails or src fails to read or
+// seek before the second pass. It returns the number of bytes written and any
+// error returned by src or w; if src ends before the size its end was sought
+// to, io.ErrUnexpectedEOF is returned, and if w accepts fewer bytes than it is
+// given without returning an error, io.ErrShortWrite is returned.
+func (s *SIV) OpenSeeker(w io.Writer, src io.ReadSeeker, data []byte) (int64, error) {
+	start, err := src.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	end, err := src.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if end-start < int64(s.Overhead()) {
+		return 0, ErrAuthentication
+	}
+	if _, err := src.Seek(start, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	v := make([]byte, s.Overhead())
+	if err := readFull(src, v); err != nil {
+		return 0, err
+	}
+	size := end - start - int64(len(v))
+
+	buf := make([]byte, streamChunkSize)
+	defer wipe(buf)
+
+	// The first pass feeds all but the last block of the plaintext to the
+	// MAC, and the last block, or the whole plaintext if it is shorter, to
+	// s2vFinal.
+	h, _ := cmac.NewWithCipher(s.mac)
+	d := s2vData(h, data)
+
+	prefix := size - int64(len(d))
+	if prefix < 0 {
+		prefix = 0
+	}
+
+	stream := cipher.NewCTR(s.enc, ctr(v))
+	err = readCTR(src, stream, buf, prefix, func(p []byte) error {
+		_, _ = h.Write(p)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	last := buf[:size-prefix]
+	if err := readFull(src, last); err != nil {
+		return 0, err
+	}
+	stream.XORKeyStream(last, last)
+
+	if subtle.ConstantTimeCompare(v, s2vFinal(h, d, last)) != 1 {
+		return 0, ErrAuthentication
+	}
+
+	if _, err := src.Seek(start+int64(len(v)), io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	var written int64
+	err = readCTR(src, cipher.NewCTR(s.enc, ctr(v)), buf, size, func(p []byte) error {
+		n, err := w.Write(p)
+		written += int64(n)
+		if err == nil && n < len(p) {
+			err = io.ErrShortWrite
+		}
+		return err
+	})
+	return written, err
+}
+
+// readCTR reads n bytes from r in pieces using buf, decrypting each piece with
+// stream and passing it to f.
+func readCTR(r io.Reader, stream cipher.Stream, buf []byte, n int64, f func([]byte) error) error {
+	for n > 0 {
+		p := buf
+		if n < int64(len(p)) {
+			p = p[:n]
+		}
+		if err := readFull(r, p); err != nil {
+			return err
+		}
+		stream.XORKeyStream(p, p)
+		n -= int64(len(p))
+
+		if err := f(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readFull is io.ReadFull, except that an EOF before p is filled is always
+// io.ErrUnexpectedEOF, as the caller knows how much it expects to read.
+func readFull(r io.Reader, p []byte) error {
+	_, err := io.ReadFull(r, p)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// s2vCiphertext computes S2V over the given associated data and the plaintext
+// of ciphertext, decrypting it in pieces using buf.
+func (s *SIV) s2vCiphertext(buf, v, ciphertext []byte, data ...[]byte) []byte {
+	h, _ := cmac.NewWithCipher(s.mac)
+	d := s2vData(h, data...)
+
+	ctr := cipher.NewCTR(s.enc, ctr(v))
+	end := len(ciphertext)
+	if end >= len(d) {
+		end -= len(d)
+	} else {
+		end = 0
+	}
+
+	for c := ciphertext[:end]; len(c) > 0; {
+		p := buf
+		if len(c) < len(p) {
+			p = p[:len(c)]
+		}
+		ctr.XORKeyStream(p, c[:len(p)])
+		_, _ = h.Write(p)
+		c = c[len(p):]
+	}
+
+	last := buf[:len(ciphertext)-end]
+	ctr.XORKeyStream(last, ciphertext[end:])
+
+	return s2vFinal(h, d, last)
+}
+
+// SealTo encrypts and authenticates plaintext, writing the ciphertext to w. Its
+// output is identical to that of Seal(nil, nonce, plaintext, data), but the
+// ciphertext is written in pieces, so it never holds more than a small, fixed
+// amount of it in memory.
+//
+// It returns the number of bytes written and any error returned by w; if w
+// accepts fewer bytes than it is given without returning an error,
+// io.ErrShortWrite is returned. After an error, w holds a prefix of the
+// ciphertext, which should be discarded.
+func (s *SIV) SealTo(w io.Writer, nonce, plaintext, data []byte) (int, error) {
+	if len(plaintext) > MaxPlaintextSize {
+		return 0, ErrPlaintextTooLarge
+	}
+
+	h, _ := cmac.NewWithCipher(s.mac)
+	v := s2v(h, components([][]byte{data, nonce}, plaintext)...)
+
+	written, err := w.Write(v)
+	if err == nil && written < len(v) {
+		err = io.ErrShortWrite
+	}
+	if err != nil {
+		return written, err
+	}
+
+	size := streamChunkSize
+	if len(plaintext) < size {
+		size = len(plaintext)
+	}
+	buf := make([]byte, size)
+	defer wipe(buf)
+
+	ctr := cipher.NewCTR(s.enc, ctr(v))
+	for len(plaintext) > 0 {
+		p := buf
+		if len(plaintext) < len(p) {
+			p = p[:len(plaintext)]
+		}
+		ctr.XORKeyStream(p, plaintext[:len(p)])
+		plaintext = plaintext[len(p):]
+
+		n, err := w.Write(p)
+		written += n
+		if err == nil && n < len(p) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			return written, err
+		}
+	}
+
+	return written, nil
+}
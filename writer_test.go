@@ -0,0 +1,404 @@
+package siv
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenToWriter(t *testing.T) {
+	s, _ := NewSIV(testKey(0, 32), aes.NewCipher)
+	data := []byte("ad")
+
+	for _, n := range []int{0, 1, 15, 16, 17, 32, streamChunkSize - 1, streamChunkSize + 15, 3*streamChunkSize + 17} {
+		plaintext := bytes.Repeat([]byte{0xa5}, n)
+		ciphertext := s.Seal(nil, nil, plaintext, data)
+
+		var buf bytes.Buffer
+		written, err := s.OpenToWriter(&buf, ciphertext, data)
+		if err != nil {
+			t.Fatalf("%d: %v", n, err)
+		}
+
+		if written != int64(n) {
+			t.Errorf("%d: Wrote %d bytes, but expected %d", n, written, n)
+		}
+
+		if !bytes.Equal(buf.Bytes(), plaintext) {
+			t.Errorf("%d: Plaintext was %x, but expected %x", n, buf.Bytes(), plaintext)
+		}
+	}
+}
+
+func TestOpenToWriterBadCiphertext(t *testing.T) {
+	s, _ := NewSIV(testKey(0, 32), aes.NewCipher)
+
+	for _, n := range []int{0, 15, 16, 3*streamChunkSize + 17} {
+		ciphertext := s.Seal(nil, nil, make([]byte, n), nil)
+		ciphertext[len(ciphertext)-1] ^= 1
+
+		var buf bytes.Buffer
+		if _, err := s.OpenToWriter(&buf, ciphertext, nil); err != ErrAuthentication {
+			t.Errorf("%d: Error was %v, but expected %v", n, err, ErrAuthentication)
+		}
+
+		if buf.Len() != 0 {
+			t.Errorf("%d: Wrote %d bytes on failure", n, buf.Len())
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := s.OpenToWriter(&buf, make([]byte, 15), nil); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+}
+
+type failingWriter struct {
+	n     int
+	short bool
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) <= w.n {
+		w.n -= len(p)
+		return len(p), nil
+	}
+
+	n := w.n
+	w.n = 0
+	if w.short {
+		return n, nil
+	}
+	return n, errors.New("boom")
+}
+
+func TestOpenToWriterFailingWriter(t *testing.T) {
+	s, _ := NewSIV(testKey(0, 32), aes.NewCipher)
+	ciphertext := s.Seal(nil, nil, make([]byte, 3*streamChunkSize), nil)
+
+	written, err := s.OpenToWriter(&failingWriter{n: streamChunkSize + 10}, ciphertext, nil)
+	if err == nil || err.Error() != "boom" {
+		t.Errorf("Error was %v, but expected boom", err)
+	}
+
+	if written != streamChunkSize+10 {
+		t.Errorf("Wrote %d bytes, but expected %d", written, streamChunkSize+10)
+	}
+
+	written, err = s.OpenToWriter(&failingWriter{n: 10, short: true}, ciphertext, nil)
+	if err != io.ErrShortWrite {
+		t.Errorf("Error was %v, but expected %v", err, io.ErrShortWrite)
+	}
+
+	if written != 10 {
+		t.Errorf("Wrote %d bytes, but expected %d", written, 10)
+	}
+}
+
+func TestOpenSeeker(t *testing.T) {
+	s, _ := NewSIV(testKey(0, 32), aes.NewCipher)
+
+	for _, n := range []int{0, 1, 15, 16, 17, 32, streamChunkSize - 1, streamChunkSize, streamChunkSize + 15, 3*streamChunkSize + 17} {
+		plaintext := testKey(0, n)
+		for _, data := range [][]byte{nil, []byte("ad")} {
+			ciphertext := s.Seal(nil, nil, plaintext, data)
+
+			// The ciphertext is read from the current offset.
+			src := bytes.NewReader(append([]byte("header"), ciphertext...))
+			_, _ = src.Seek(6, io.SeekStart)
+
+			var buf bytes.Buffer
+			written, err := s.OpenSeeker(&buf, src, data)
+			if err != nil {
+				t.Fatalf("%d: %v", n, err)
+			}
+			if written != int64(n) {
+				t.Errorf("%d: Wrote %d bytes, but expected %d", n, written, n)
+			}
+			if !bytes.Equal(buf.Bytes(), plaintext) {
+				t.Errorf("%d: Plaintext was %x, but expected %x", n, buf.Bytes(), plaintext)
+			}
+		}
+	}
+}
+
+func TestOpenSeekerBadCiphertext(t *testing.T) {
+	s, _ := NewSIV(testKey(0, 32), aes.NewCipher)
+
+	for _, n := range []int{0, 15, 16, 3*streamChunkSize + 17} {
+		ciphertext := s.Seal(nil, nil, make([]byte, n), []byte("ad"))
+		for _, i := range []int{0, len(ciphertext) - 1} {
+			tampered := append([]byte{}, ciphertext...)
+			tampered[i] ^= 1
+
+			var buf bytes.Buffer
+			if _, err := s.OpenSeeker(&buf, bytes.NewReader(tampered), []byte("ad")); err != ErrAuthentication {
+				t.Errorf("%d: Error was %v, but expected %v", n, err, ErrAuthentication)
+			}
+			if buf.Len() != 0 {
+				t.Errorf("%d: Wrote %d bytes on failure", n, buf.Len())
+			}
+		}
+
+		var buf bytes.Buffer
+		if _, err := s.OpenSeeker(&buf, bytes.NewReader(ciphertext), []byte("other")); err != ErrAuthentication || buf.Len() != 0 {
+			t.Errorf("%d: Error was %v after writing %d bytes, but expected %v", n, err, buf.Len(), ErrAuthentication)
+		}
+	}
+
+	var buf bytes.Buffer
+	if _, err := s.OpenSeeker(&buf, bytes.NewReader(make([]byte, 15)), nil); err != ErrAuthentication {
+		t.Errorf("Error was %v, but expected %v", err, ErrAuthentication)
+	}
+}
+
+// failingSeeker fails its seek'th call to Seek and, unless read is negative,
+// any read after the first read bytes. If end is non-zero, Seek reports it as
+// the end of the data.
+type failingSeeker struct {
+	*bytes.Reader
+	seek, seeks int
+	read        int
+	end         int64
+}
+
+var errSeek = errors.New("seek failed")
+
+func (f *failingSeeker) Seek(offset int64, whence int) (int64, error) {
+	f.seeks++
+	if f.seeks == f.seek {
+		return 0, errSeek
+	}
+	if whence == io.SeekEnd && f.end != 0 {
+		return f.end, nil
+	}
+	return f.Reader.Seek(offset, whence)
+}
+
+func (f *failingSeeker) Read(p []byte) (int, error) {
+	if f.read < 0 {
+		return f.Reader.Read(p)
+	}
+	if f.read == 0 {
+		return 0, errors.New("boom")
+	}
+	if len(p) > f.read {
+		p = p[:f.read]
+	}
+	n, err := f.Reader.Read(p)
+	f.read -= n
+	return n, err
+}
+
+func TestOpenSeekerFailures(t *testing.T) {
+	s, _ := NewSIV(testKey(0, 32), aes.NewCipher)
+	ciphertext := s.Seal(nil, nil, make([]byte, 3*streamChunkSize), nil)
+
+	// Seek fails: finding the start, the end, returning to the start, and
+	// rewinding for the second pass.
+	for seek := 1; seek <= 4; seek++ {
+		var buf bytes.Buffer
+		src := &failingSeeker{Reader: bytes.NewReader(ciphertext), seek: seek, read: -1}
+		if written, err := s.OpenSeeker(&buf, src, nil); err != errSeek || written != 0 || buf.Len() != 0 {
+			t.Errorf("Seek %d: error was %v after writing %d bytes, but expected %v", seek, err, buf.Len(), errSeek)
+		}
+	}
+
+	// Reads fail: in the tag, the first pass, the last block, and the second
+	// pass, which has already written what it decrypted.
+	for _, test := range []struct{ read, written int }{
+		{0, 0},
+		{10, 0},
+		{16 + streamChunkSize, 0},
+		{len(ciphertext) - 5, 0},
+		{len(ciphertext) + streamChunkSize + 10, streamChunkSize},
+	} {
+		var buf bytes.Buffer
+		src := &failingSeeker{Reader: bytes.NewReader(ciphertext), read: test.read}
+		written, err := s.OpenSeeker(&buf, src, nil)
+		if err == nil || err.Error() != "boom" {
+			t.Errorf("Read %d: error was %v, but expected boom", test.read, err)
+		}
+		if written != int64(test.written) || buf.Len() != test.written {
+			t.Errorf("Read %d: wrote %d bytes, but expected %d", test.read, buf.Len(), test.written)
+		}
+	}
+
+	// src is shorter than its end implies.
+	var buf bytes.Buffer
+	src := &failingSeeker{Reader: bytes.NewReader(ciphertext), read: -1, end: int64(len(ciphertext)) + 1}
+	if written, err := s.OpenSeeker(&buf, src, nil); err != io.ErrUnexpectedEOF || written != 0 {
+		t.Errorf("Error was %v after writing %d bytes, but expected %v", err, written, io.ErrUnexpectedEOF)
+	}
+
+	written, err := s.OpenSeeker(&failingWriter{n: streamChunkSize + 10}, bytes.NewReader(ciphertext), nil)
+	if err == nil || err.Error() != "boom" || written != streamChunkSize+10 {
+		t.Errorf("Error was %v after writing %d bytes, but expected boom", err, written)
+	}
+	written, err = s.OpenSeeker(&failingWriter{n: 10, short: true}, bytes.NewReader(ciphertext), nil)
+	if err != io.ErrShortWrite || written != 10 {
+		t.Errorf("Error was %v after writing %d bytes, but expected %v", err, written, io.ErrShortWrite)
+	}
+}
+
+// TestOpenSeekerLarge compares OpenSeeker with Open for a file of a few hundred
+// megabytes.
+func TestOpenSeekerLarge(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping a large message in short mode")
+	}
+
+	n := 320<<20 + 7
+	buf := make([]byte, n+16)
+	for i := 0; i < n; i += 4093 {
+		buf[i] = byte(i)
+	}
+
+	s, _ := NewSIV(testKey(0, 32), aes.NewCipher)
+	ciphertext := s.Seal(buf[:0], nil, buf[:n], []byte("large"))
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "large"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(ciphertext); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+
+	last := ciphertext[n+15]
+
+	h := sha256.New()
+	written, err := s.OpenSeeker(h, f, []byte("large"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if written != int64(n) {
+		t.Errorf("Wrote %d bytes, but expected %d", written, n)
+	}
+
+	plaintext, err := s.Open(buf[:0], nil, ciphertext, []byte("large"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual, expected := h.Sum(nil), sha256.Sum256(plaintext); !bytes.Equal(actual, expected[:]) {
+		t.Errorf("Plaintext hash was %x, but expected %x", actual, expected)
+	}
+
+	// Changing the file's last byte makes it fail without output.
+	if _, err := f.WriteAt([]byte{^last}, int64(n+15)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	if _, err := s.OpenSeeker(&out, f, []byte("large")); err != ErrAuthentication || out.Len() != 0 {
+		t.Errorf("Error was %v after writing %d bytes, but expected %v", err, out.Len(), ErrAuthentication)
+	}
+}
+
+func TestSealTo(t *testing.T) {
+	s, _ := NewSIV(testKey(0, 32), aes.NewCipher)
+
+	for _, n := range []int{0, 1, 15, 16, 17, 32, streamChunkSize - 1, streamChunkSize + 15, 3*streamChunkSize + 17} {
+		plaintext := bytes.Repeat([]byte{0xa5}, n)
+		for _, args := range [][2][]byte{{nil, nil}, {[]byte("nonce"), nil}, {nil, []byte("ad")}, {[]byte("nonce"), []byte("ad")}} {
+			nonce, data := args[0], args[1]
+			expected := s.Seal(nil, nonce, plaintext, data)
+
+			var buf bytes.Buffer
+			written, err := s.SealTo(&buf, nonce, plaintext, data)
+			if err != nil {
+				t.Fatalf("%d: %v", n, err)
+			}
+
+			if written != len(expected) {
+				t.Errorf("%d: Wrote %d bytes, but expected %d", n, written, len(expected))
+			}
+
+			if !bytes.Equal(buf.Bytes(), expected) {
+				t.Errorf("%d: Ciphertext was %x, but expected %x", n, buf.Bytes(), expected)
+			}
+		}
+	}
+}
+
+func TestSealToFailingWriter(t *testing.T) {
+	s, _ := NewSIV(testKey(0, 32), aes.NewCipher)
+	plaintext := make([]byte, 3*streamChunkSize)
+	expected := s.Seal(nil, nil, plaintext, nil)
+
+	for _, n := range []int{0, 10, streamChunkSize + 10} {
+		w := &failingWriter{n: n}
+		written, err := s.SealTo(w, nil, plaintext, nil)
+		if err == nil || err.Error() != "boom" {
+			t.Errorf("%d: Error was %v, but expected boom", n, err)
+		}
+
+		if written != n {
+			t.Errorf("%d: Wrote %d bytes, but expected %d", n, written, n)
+		}
+
+		written, err = s.SealTo(&failingWriter{n: n, short: true}, nil, plaintext, nil)
+		if err != io.ErrShortWrite {
+			t.Errorf("%d: Error was %v, but expected %v", n, err, io.ErrShortWrite)
+		}
+
+		if written != n {
+			t.Errorf("%d: Wrote %d bytes, but expected %d", n, written, n)
+		}
+	}
+
+	// A writer which fails after the whole ciphertext has no effect.
+	written, err := s.SealTo(&failingWriter{n: len(expected)}, nil, plaintext, nil)
+	if err != nil || written != len(expected) {
+		t.Errorf("Wrote %d bytes, %v, but expected %d", written, err, len(expected))
+	}
+}
+
+func TestSealToAllocs(t *testing.T) {
+	s, _ := NewSIV(testKey(0, 32), aes.NewCipher)
+	plaintext := make([]byte, 1<<20)
+
+	res := testing.Benchmark(func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			_, _ = s.SealTo(io.Discard, nil, plaintext, nil)
+		}
+	})
+
+	// The ciphertext is never buffered whole.
+	if allocated := res.AllocedBytesPerOp(); allocated >= int64(len(plaintext)/4) {
+		t.Errorf("Allocated %d bytes per 1 MiB message, but expected far fewer", allocated)
+	}
+}
+
+func BenchmarkSealTo(b *testing.B) {
+	s, _ := NewSIV(testKey(0, 32), aes.NewCipher)
+	plaintext := make([]byte, 1<<20)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(plaintext)))
+	for i := 0; i < b.N; i++ {
+		_, _ = s.SealTo(io.Discard, nil, plaintext, nil)
+	}
+}
+
+func BenchmarkSealToSeal(b *testing.B) {
+	aead, _ := New(testKey(0, 32), aes.NewCipher)
+	plaintext := make([]byte, 1<<20)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(plaintext)))
+	for i := 0; i < b.N; i++ {
+		_, _ = io.Discard.Write(aead.Seal(nil, nil, plaintext, nil))
+	}
+}